@@ -0,0 +1,356 @@
+package main
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"path"
+	"slices"
+	"strings"
+	"sync/atomic"
+
+	"go.yaml.in/yaml/v2"
+)
+
+// =============================================================================
+// Access Policy
+// =============================================================================
+//
+// Per-path authorization without an identity provider: ACCESS_POLICY_FILE
+// names a YAML file of API keys (each with roles) and rules saying which
+// roles or keys may call which paths. Callers identify themselves with the
+// X-API-Key header, the same one quotas and client stats use:
+//
+//	mode: enforce        # or audit: log would-be denials, refuse nothing
+//	default: allow       # for paths no rule matches; or deny
+//	keys:
+//	  - name: ops
+//	    key: ops-secret
+//	    roles: [admin, writer]
+//	  - name: ci
+//	    key: ci-secret
+//	    roles: [writer]
+//	  - name: billing
+//	    key_secret: BILLING_API_KEY  # from Vault, SECRETS_FILE, or the env
+//	    roles: [reader]
+//	rules:
+//	  - path: /api/admin/**
+//	    roles: [admin]
+//	  - path: /api/items/**
+//	    methods: [POST, PUT, DELETE]
+//	    roles: [writer]
+//	  - path: /api/kv/*
+//	    api_keys: [ci]     # by key name
+//	  - path: /api/items/**  # everything else under /api/items is public
+//
+//	ACCESS_POLICY_FILE=./policy.yaml ./demo-app
+//	curl -X POST -d '{"name":"x"}' http://localhost:8080/api/items       # 401
+//	curl -X POST -H 'X-API-Key: ci-secret' -d '{"name":"x"}' \
+//	  http://localhost:8080/api/items                                    # 201
+//	curl -H 'X-API-Key: ci-secret' http://localhost:8080/api/admin/fsck  # 403
+//
+// Rules are checked in order and the first one whose path and method match
+// decides. A rule allows a caller holding any of its roles or being any of
+// its keys; a rule with neither is public. No key (or an unknown one) where
+// one is needed is a 401, the wrong key a 403. Paths are globs where "*"
+// stays within one segment; a trailing "/**" matches the path and
+// everything below it.
+//
+// The policy comes on top of ADMIN_TOKEN: admin routes still want the
+// bearer token when one is set. An edited file is picked up without a
+// restart (configwatch.go). YAML is a superset of JSON, so a JSON file
+// works too. GET /api/admin/access-policy shows the loaded policy (key
+// names and roles, never the keys) and how many requests it allowed,
+// denied, and would have denied.
+//
+// Requests the app makes itself (queue messages, load_generation jobs,
+// cluster, standby, and display sync) don't hold a policy key. They send
+// INTERNAL_API_KEY in X-Internal-Key instead, which the policy lets
+// through. Without INTERNAL_API_KEY each process makes up its own, so
+// calls to itself work; replicas calling each other need it shared. A
+// separate header keeps the key out of client stats and quotas.
+
+// Policy modes
+const (
+	accessEnforce = "enforce"
+	accessAudit   = "audit"
+)
+
+// AccessKey is one API key and the roles it holds
+type AccessKey struct {
+	Name      string   `yaml:"name" json:"name"`
+	Key       string   `yaml:"key" json:"-"`                                     // never shown by the admin API
+	KeySecret string   `yaml:"key_secret,omitempty" json:"key_secret,omitempty"` // or a secret's name (secrets.go)
+	Roles     []string `yaml:"roles" json:"roles"`
+}
+
+// AccessRule says who may call the paths and methods it matches
+type AccessRule struct {
+	Path    string   `yaml:"path" json:"path"`                           // glob; trailing "/**" matches a subtree
+	Methods []string `yaml:"methods,omitempty" json:"methods,omitempty"` // empty matches every method
+	Roles   []string `yaml:"roles,omitempty" json:"roles,omitempty"`
+	APIKeys []string `yaml:"api_keys,omitempty" json:"api_keys,omitempty"` // key names
+}
+
+// AccessPolicy is the ACCESS_POLICY_FILE document
+type AccessPolicy struct {
+	Mode    string       `yaml:"mode" json:"mode"`
+	Default string       `yaml:"default" json:"default"`
+	Keys    []AccessKey  `yaml:"keys" json:"keys"`
+	Rules   []AccessRule `yaml:"rules" json:"rules"`
+}
+
+// Header internal callers send INTERNAL_API_KEY in
+const internalKeyHeader = "X-Internal-Key"
+
+// Key made up at startup for when INTERNAL_API_KEY isn't set
+var fallbackInternalKey = func() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}()
+
+// internalAPIKey is the key the app's own requests carry
+func internalAPIKey() string {
+	if key := secret("INTERNAL_API_KEY"); key != "" {
+		return key
+	}
+	return fallbackInternalKey
+}
+
+// setInternalKey marks a request the app makes to itself or a replica
+func setInternalKey(req *http.Request) {
+	req.Header.Set(internalKeyHeader, internalAPIKey())
+}
+
+// isInternalCaller reports whether a request carries the internal key
+func isInternalCaller(r *http.Request) bool {
+	given := r.Header.Get(internalKeyHeader)
+	return given != "" && subtle.ConstantTimeCompare([]byte(given), []byte(internalAPIKey())) == 1
+}
+
+// Active policy, nil unless ACCESS_POLICY_FILE is set
+var accessPolicy atomic.Pointer[AccessPolicy]
+
+// Decisions since startup, for the admin API
+var accessDecisions struct {
+	allowed, denied, wouldDeny atomic.Uint64
+}
+
+// parseAccessPolicy reads and checks a policy document, filling in defaults
+func parseAccessPolicy(data []byte) (*AccessPolicy, error) {
+	var p AccessPolicy
+	if err := yaml.UnmarshalStrict(data, &p); err != nil {
+		return nil, fmt.Errorf("invalid access policy: %w", err)
+	}
+
+	switch p.Mode {
+	case "":
+		p.Mode = accessEnforce
+	case accessEnforce, accessAudit:
+	default:
+		return nil, fmt.Errorf("access policy mode must be enforce or audit, got %q", p.Mode)
+	}
+	switch p.Default {
+	case "":
+		p.Default = "allow"
+	case "allow", "deny":
+	default:
+		return nil, fmt.Errorf("access policy default must be allow or deny, got %q", p.Default)
+	}
+
+	names := map[string]bool{}
+	for i, k := range p.Keys {
+		switch {
+		case k.Name == "" || (k.Key == "") == (k.KeySecret == ""):
+			return nil, fmt.Errorf("access policy key %d needs a name and either key or key_secret", i+1)
+		case names[k.Name]:
+			return nil, fmt.Errorf("access policy key %q is listed twice", k.Name)
+		}
+		names[k.Name] = true
+	}
+	for i, rule := range p.Rules {
+		if !strings.HasPrefix(rule.Path, "/") {
+			return nil, fmt.Errorf("access policy rule %d: path must start with /, got %q", i+1, rule.Path)
+		}
+		if _, err := path.Match(strings.TrimSuffix(rule.Path, "/**"), ""); err != nil {
+			return nil, fmt.Errorf("access policy rule %d: bad path %q: %w", i+1, rule.Path, err)
+		}
+		for _, name := range rule.APIKeys {
+			if !names[name] {
+				return nil, fmt.Errorf("access policy rule %d: unknown key %q", i+1, name)
+			}
+		}
+	}
+	return &p, nil
+}
+
+// loadAccessPolicyFile reads ACCESS_POLICY_FILE and makes it the active policy
+func loadAccessPolicyFile(filename string) (*AccessPolicy, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	p, err := parseAccessPolicy(data)
+	if err != nil {
+		return nil, err
+	}
+	accessPolicy.Store(p)
+	return p, nil
+}
+
+// matches reports whether a rule covers this method and path
+func (rule *AccessRule) matches(method, urlPath string) bool {
+	if len(rule.Methods) > 0 && !slices.ContainsFunc(rule.Methods, func(m string) bool { return strings.EqualFold(m, method) }) {
+		return false
+	}
+	if prefix, ok := strings.CutSuffix(rule.Path, "/**"); ok {
+		if urlPath == prefix || strings.HasPrefix(urlPath, prefix+"/") {
+			return true
+		}
+		ok, _ := path.Match(prefix, urlPath)
+		return ok
+	}
+	ok, _ := path.Match(rule.Path, urlPath)
+	return ok
+}
+
+// caller finds the key sent in X-API-Key, or nil for none or an unknown one
+func (p *AccessPolicy) caller(r *http.Request) *AccessKey {
+	given := r.Header.Get(apiKeyHeader)
+	if given == "" {
+		return nil
+	}
+	var found *AccessKey
+	// Compare against every key, in constant time, like adminMiddleware
+	for i := range p.Keys {
+		key := p.Keys[i].Key
+		if p.Keys[i].KeySecret != "" {
+			key = secret(p.Keys[i].KeySecret) // read each time, so rotations apply
+		}
+		if key != "" && subtle.ConstantTimeCompare([]byte(given), []byte(key)) == 1 {
+			found = &p.Keys[i]
+		}
+	}
+	return found
+}
+
+// allows reports whether a rule lets this caller through
+func (rule *AccessRule) allows(caller *AccessKey) bool {
+	if len(rule.Roles) == 0 && len(rule.APIKeys) == 0 {
+		return true // public
+	}
+	if caller == nil {
+		return false
+	}
+	if slices.Contains(rule.APIKeys, caller.Name) {
+		return true
+	}
+	for _, role := range caller.Roles {
+		if slices.Contains(rule.Roles, role) {
+			return true
+		}
+	}
+	return false
+}
+
+// decide returns the status to refuse a request with (401 or 403), or 0 to
+// let it through, and the rule path that decided ("default" for none)
+func (p *AccessPolicy) decide(r *http.Request) (int, string) {
+	caller := p.caller(r)
+	refuse := func() int {
+		if caller == nil {
+			return http.StatusUnauthorized
+		}
+		return http.StatusForbidden
+	}
+	for i := range p.Rules {
+		rule := &p.Rules[i]
+		if !rule.matches(r.Method, r.URL.Path) {
+			continue
+		}
+		if rule.allows(caller) {
+			return 0, rule.Path
+		}
+		return refuse(), rule.Path
+	}
+	if p.Default == "deny" {
+		return refuse(), "default"
+	}
+	return 0, "default"
+}
+
+// accessPolicyMiddleware applies ACCESS_POLICY_FILE to every request. It
+// wraps the whole router so mocked paths (rules.go) are covered too.
+func accessPolicyMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		p := accessPolicy.Load()
+		if p == nil || isInternalCaller(r) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		status, rule := p.decide(r)
+		if status == 0 {
+			accessDecisions.allowed.Add(1)
+			accessDecisionsTotal.WithLabelValues("allow").Inc()
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		caller := "(none)"
+		if key := p.caller(r); key != nil {
+			caller = key.Name
+		}
+		if p.Mode == accessAudit {
+			accessDecisions.wouldDeny.Add(1)
+			accessDecisionsTotal.WithLabelValues("would_deny").Inc()
+			slog.WarnContext(r.Context(), "access policy would deny",
+				"method", r.Method, "path", r.URL.Path, "rule", rule, "caller", caller, "status", status)
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		accessDecisions.denied.Add(1)
+		accessDecisionsTotal.WithLabelValues("deny").Inc()
+		slog.WarnContext(r.Context(), "access policy denied",
+			"method", r.Method, "path", r.URL.Path, "rule", rule, "caller", caller, "status", status)
+		w.Header().Set("Content-Type", "application/json")
+		if status == http.StatusUnauthorized {
+			http.Error(w, `{"error":"api key required (X-API-Key)"}`, status)
+			return
+		}
+		http.Error(w, `{"error":"forbidden by access policy"}`, status)
+	})
+}
+
+// AccessPolicyStatus is GET /api/admin/access-policy
+type AccessPolicyStatus struct {
+	Enabled   bool          `json:"enabled"`
+	Policy    *AccessPolicy `json:"policy,omitempty"`
+	Allowed   uint64        `json:"allowed"`
+	Denied    uint64        `json:"denied"`
+	WouldDeny uint64        `json:"would_deny"` // audit mode
+}
+
+// accessPolicyAdminHandler handles GET /api/admin/access-policy
+func accessPolicyAdminHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	if r.Method != http.MethodGet {
+		http.Error(w, `{"error":"method not allowed"}`, http.StatusMethodNotAllowed)
+		return
+	}
+	p := accessPolicy.Load()
+	json.NewEncoder(w).Encode(AccessPolicyStatus{
+		Enabled:   p != nil,
+		Policy:    p,
+		Allowed:   accessDecisions.allowed.Load(),
+		Denied:    accessDecisions.denied.Load(),
+		WouldDeny: accessDecisions.wouldDeny.Load(),
+	})
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const testAccessPolicy = `
+mode: %s
+default: deny
+keys:
+  - name: ops
+    key: ops-secret
+    roles: [admin, writer]
+  - name: ci
+    key: ci-secret
+    roles: [writer]
+rules:
+  - path: /health
+  - path: /api/admin/**
+    roles: [admin]
+  - path: /api/items/**
+    methods: [post, PUT, DELETE]
+    roles: [writer]
+  - path: /api/items/**
+  - path: /api/kv/*
+    api_keys: [ci]
+`
+
+// withAccessPolicy loads a policy for one test
+func withAccessPolicy(t *testing.T, mode string) {
+	t.Helper()
+	file := filepath.Join(t.TempDir(), "policy.yaml")
+	if err := os.WriteFile(file, fmt.Appendf(nil, testAccessPolicy, mode), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := loadAccessPolicyFile(file); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { accessPolicy.Store(nil) })
+}
+
+// accessStatus runs one request through the middleware
+func accessStatus(method, path, key string) int {
+	handler := accessPolicyMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	req := httptest.NewRequest(method, path, nil)
+	if key != "" {
+		req.Header.Set(apiKeyHeader, key)
+	}
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	return rec.Code
+}
+
+func TestAccessPolicy_Enforce(t *testing.T) {
+	withAccessPolicy(t, "enforce")
+
+	tests := []struct {
+		method, path, key string
+		want              int
+	}{
+		{"GET", "/health", "", 200},                  // public rule
+		{"GET", "/api/items", "", 200},               // public rule after the write rule
+		{"GET", "/api/items/7/links", "", 200},       // "/**" covers the subtree
+		{"POST", "/api/items", "", 401},              // no key
+		{"POST", "/api/items", "wrong", 401},         // unknown key
+		{"POST", "/api/items", "ci-secret", 200},     // method matched case-insensitively
+		{"DELETE", "/api/items/3", "ci-secret", 200}, // writer role
+		{"GET", "/api/admin/fsck", "ci-secret", 403}, // not an admin
+		{"GET", "/api/admin/fsck", "ops-secret", 200},
+		{"PUT", "/api/kv/color", "ci-secret", 200}, // by key name
+		{"PUT", "/api/kv/color", "ops-secret", 403},
+		{"GET", "/api/system", "ops-secret", 403}, // no rule: default deny
+	}
+	for _, tt := range tests {
+		if got := accessStatus(tt.method, tt.path, tt.key); got != tt.want {
+			t.Errorf("%s %s key=%q = %d, want %d", tt.method, tt.path, tt.key, got, tt.want)
+		}
+	}
+}
+
+func TestAccessPolicy_InternalCallers(t *testing.T) {
+	withAccessPolicy(t, "enforce")
+	handler := accessPolicyMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	status := func(internalKey string) int {
+		req := httptest.NewRequest("POST", "/api/items", nil)
+		req.Header.Set(internalKeyHeader, internalKey)
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec.Code
+	}
+	if got := status(internalAPIKey()); got != http.StatusOK {
+		t.Errorf("with the internal key = %d, want 200", got)
+	}
+	if got := status("guess"); got != http.StatusUnauthorized {
+		t.Errorf("with a wrong internal key = %d, want 401", got)
+	}
+
+	t.Setenv("INTERNAL_API_KEY", "shared-secret")
+	req := httptest.NewRequest("GET", "/", nil)
+	setInternalKey(req)
+	if got := req.Header.Get(internalKeyHeader); got != "shared-secret" {
+		t.Errorf("internal key = %q, want INTERNAL_API_KEY", got)
+	}
+}
+
+func TestAccessPolicy_Audit(t *testing.T) {
+	withAccessPolicy(t, "audit")
+	before := accessDecisions.wouldDeny.Load()
+
+	if got := accessStatus("POST", "/api/items", ""); got != http.StatusOK {
+		t.Errorf("audit mode refused a request: %d", got)
+	}
+	if got := accessDecisions.wouldDeny.Load() - before; got != 1 {
+		t.Errorf("would_deny went up by %d, want 1", got)
+	}
+}
+
+func TestAccessPolicy_Invalid(t *testing.T) {
+	for name, doc := range map[string]string{
+		"mode":        "mode: strict",
+		"default":     "default: maybe",
+		"unknown key": "rules:\n  - path: /x\n    api_keys: [nobody]",
+		"no slash":    "rules:\n  - path: api/items",
+		"bad glob":    "rules:\n  - path: /api/[",
+		"duplicate":   "keys:\n  - {name: a, key: x}\n  - {name: a, key: y}",
+		"typo":        "rulez: []",
+	} {
+		if _, err := parseAccessPolicy([]byte(doc)); err == nil {
+			t.Errorf("%s: parsed %q without an error", name, doc)
+		}
+	}
+}
+
+func TestAccessPolicy_AdminHidesKeys(t *testing.T) {
+	srv := newTestServer(t)
+	withAccessPolicy(t, "enforce")
+
+	code, body := doRequest(t, srv, http.MethodGet, "/api/admin/access-policy", "")
+	if code != http.StatusOK || !strings.Contains(string(body), `"name":"ops"`) || strings.Contains(string(body), "ops-secret") {
+		t.Errorf("GET /api/admin/access-policy = %d %s", code, body)
+	}
+}
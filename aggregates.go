@@ -0,0 +1,345 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync/atomic"
+	"time"
+
+	badger "github.com/dgraph-io/badger/v4"
+)
+
+// =============================================================================
+// Item Aggregates
+// =============================================================================
+//
+// Counting tags by reading every item gets slow once there are a lot of
+// items. Instead, every item write also records how it changes the totals
+// (item count, per-tag counts, untagged items, description length), in the
+// same transaction, so the totals can't disagree with the items:
+//
+//	create {"tags":["sale"]}      -> delta {"items":1,"tags":{"sale":1}}
+//	update tags ["sale"] -> ["new"] -> delta {"tags":{"sale":-1,"new":1}}
+//
+// Each delta is a new key ("agg:items:delta:<time>-<n>"). Nothing reads and
+// rewrites a shared total, so concurrent writes never conflict over it. A
+// reader adds the pending deltas to the last folded total ("agg:items").
+// In the background:
+//
+//   - every 10s the deltas are folded into the total, so reads stay cheap
+//   - every AGGREGATES_RECONCILE_INTERVAL the totals are recomputed from the
+//     items themselves. Any difference (writes that skipped the deltas, like
+//     fsck --fix) is logged and reported as drift.
+//
+// GET /api/items/stats (itemstats.go) reads them, with how stale they are:
+//
+//	"aggregates":{"pending_deltas":3,"folded_at":"...","reconciled_at":"...","last_drift":0}
+//
+// The keys replicate with the items, and only the leader folds and
+// reconciles, so followers report the leader's totals.
+
+// Keys: the folded total, and one delta per item write
+const (
+	aggregatesKeyPrefix  = "agg:"
+	itemAggregatesKey    = "agg:items"
+	aggregateDeltaPrefix = "agg:items:delta:"
+)
+
+// How often deltas are folded, and the most folded in one transaction
+const (
+	aggregateFoldInterval = 10 * time.Second
+	maxAggregateFold      = 10000
+)
+
+// aggregatesReconcileInterval is AGGREGATES_RECONCILE_INTERVAL (set in main)
+var aggregatesReconcileInterval = 5 * time.Minute
+
+// aggregateDeltaSeq makes delta keys written in the same nanosecond unique
+var aggregateDeltaSeq atomic.Uint64
+
+// itemAggregates are the maintained totals, and the shape of each delta
+type itemAggregates struct {
+	Items            int64            `json:"items"`
+	Untagged         int64            `json:"untagged,omitempty"`
+	DescriptionChars int64            `json:"description_chars,omitempty"`
+	Tags             map[string]int64 `json:"tags,omitempty"`
+}
+
+// aggregateSnapshot is the folded total with its bookkeeping
+type aggregateSnapshot struct {
+	itemAggregates
+	FoldedAt     time.Time `json:"folded_at"`
+	ReconciledAt time.Time `json:"reconciled_at"`
+	LastDrift    int64     `json:"last_drift"`
+}
+
+// AggregateStatus says how fresh the totals in GET /api/items/stats are
+type AggregateStatus struct {
+	PendingDeltas int        `json:"pending_deltas"` // writes not folded in yet
+	FoldedAt      *time.Time `json:"folded_at"`      // null until the first fold
+	ReconciledAt  *time.Time `json:"reconciled_at"`  // null until the first reconciliation
+	LastDrift     int64      `json:"last_drift"`     // how far off the last reconciliation found them
+}
+
+// addItem adds (sign 1) or removes (sign -1) an item's contribution
+func (a *itemAggregates) addItem(item *Item, sign int64) {
+	a.Items += sign
+	if len(item.Tags) == 0 {
+		a.Untagged += sign
+	}
+	a.DescriptionChars += sign * int64(len([]rune(item.Description)))
+	for _, tag := range item.Tags {
+		if a.Tags == nil {
+			a.Tags = map[string]int64{}
+		}
+		a.Tags[tag] += sign
+		if a.Tags[tag] == 0 {
+			delete(a.Tags, tag)
+		}
+	}
+}
+
+// merge adds another set of totals (or a delta) to a
+func (a *itemAggregates) merge(b itemAggregates) {
+	a.Items += b.Items
+	a.Untagged += b.Untagged
+	a.DescriptionChars += b.DescriptionChars
+	for tag, n := range b.Tags {
+		if a.Tags == nil {
+			a.Tags = map[string]int64{}
+		}
+		a.Tags[tag] += n
+		if a.Tags[tag] == 0 {
+			delete(a.Tags, tag)
+		}
+	}
+}
+
+// drift is how many units a and b differ by, summed over every total
+func (a itemAggregates) drift(b itemAggregates) int64 {
+	abs := func(n int64) int64 { return max(n, -n) }
+	d := abs(a.Items-b.Items) + abs(a.Untagged-b.Untagged)
+	for tag, n := range a.Tags {
+		d += abs(n - b.Tags[tag])
+	}
+	for tag, n := range b.Tags {
+		if _, ok := a.Tags[tag]; !ok {
+			d += abs(n)
+		}
+	}
+	return d
+}
+
+// recordItemChange writes the delta for an item going from before to after
+// (nil for a create or a delete) inside the item's transaction
+func (k keyspace) recordItemChange(txn *badger.Txn, before, after *Item) error {
+	var delta itemAggregates
+	if before != nil {
+		delta.addItem(before, -1)
+	}
+	if after != nil {
+		delta.addItem(after, 1)
+	}
+	if delta.Items == 0 && delta.Untagged == 0 && delta.DescriptionChars == 0 && len(delta.Tags) == 0 {
+		return nil // a rename: nothing counted changed
+	}
+	value, err := json.Marshal(delta)
+	if err != nil {
+		return err
+	}
+	key := fmt.Sprintf("%s%019d-%06d", aggregateDeltaPrefix, time.Now().UnixNano(), aggregateDeltaSeq.Add(1)%1_000_000)
+	return txn.Set(k.key(key), value)
+}
+
+// readAggregateSnapshot reads the folded total; zero before the first fold
+func (k keyspace) readAggregateSnapshot(txn *badger.Txn) (aggregateSnapshot, error) {
+	var snap aggregateSnapshot
+	entry, err := txn.Get(k.key(itemAggregatesKey))
+	if errors.Is(err, badger.ErrKeyNotFound) {
+		return snap, nil
+	}
+	if err != nil {
+		return snap, err
+	}
+	err = entry.Value(func(val []byte) error { return json.Unmarshal(val, &snap) })
+	return snap, err
+}
+
+// sumAggregateDeltas adds up to limit pending deltas (0 = all), returning
+// their keys for a fold to delete
+func (k keyspace) sumAggregateDeltas(txn *badger.Txn, limit int) (itemAggregates, [][]byte, error) {
+	var sum itemAggregates
+	var keys [][]byte
+	prefix := k.key(aggregateDeltaPrefix)
+	it := txn.NewIterator(badger.IteratorOptions{Prefix: prefix, PrefetchValues: true, PrefetchSize: 100})
+	defer it.Close()
+	for it.Seek(prefix); it.ValidForPrefix(prefix); it.Next() {
+		if limit > 0 && len(keys) >= limit {
+			break
+		}
+		var delta itemAggregates
+		if err := it.Item().Value(func(val []byte) error { return json.Unmarshal(val, &delta) }); err != nil {
+			return sum, nil, err
+		}
+		sum.merge(delta)
+		keys = append(keys, it.Item().KeyCopy(nil))
+	}
+	return sum, keys, nil
+}
+
+// aggregates are the tenant's current totals: the folded total plus every
+// pending delta, read in one transaction
+func (k keyspace) aggregates() (itemAggregates, AggregateStatus, error) {
+	var totals itemAggregates
+	var status AggregateStatus
+	err := dbView("aggregates_read", itemAggregatesKey, func(txn *badger.Txn) error {
+		snap, err := k.readAggregateSnapshot(txn)
+		if err != nil {
+			return err
+		}
+		pending, keys, err := k.sumAggregateDeltas(txn, 0)
+		if err != nil {
+			return err
+		}
+		totals = snap.itemAggregates
+		totals.merge(pending)
+		status.PendingDeltas = len(keys)
+		status.LastDrift = snap.LastDrift
+		if !snap.FoldedAt.IsZero() {
+			status.FoldedAt = &snap.FoldedAt
+		}
+		if !snap.ReconciledAt.IsZero() {
+			status.ReconciledAt = &snap.ReconciledAt
+		}
+		return nil
+	})
+	return totals, status, err
+}
+
+// foldAggregates folds pending deltas into the total, deleting them
+func (k keyspace) foldAggregates() error {
+	return dbUpdate("aggregates_fold", itemAggregatesKey, func(txn *badger.Txn) error {
+		pending, keys, err := k.sumAggregateDeltas(txn, maxAggregateFold)
+		if err != nil || len(keys) == 0 {
+			return err
+		}
+		snap, err := k.readAggregateSnapshot(txn)
+		if err != nil {
+			return err
+		}
+		snap.merge(pending)
+		snap.FoldedAt = time.Now().UTC()
+		for _, key := range keys {
+			if err := txn.Delete(key); err != nil {
+				return err
+			}
+		}
+		return k.writeAggregateSnapshot(txn, snap)
+	})
+}
+
+// reconcileAggregates recomputes the totals from the items and replaces
+// the maintained ones, returning how far off they were. An item write
+// during the scan fails it with ErrConflict; the next run tries again.
+func (k keyspace) reconcileAggregates() (int64, error) {
+	var drift int64
+	err := dbUpdate("aggregates_reconcile", itemAggregatesKey, func(txn *badger.Txn) error {
+		snap, err := k.readAggregateSnapshot(txn)
+		if err != nil {
+			return err
+		}
+		pending, keys, err := k.sumAggregateDeltas(txn, 0)
+		if err != nil {
+			return err
+		}
+		believed := snap.itemAggregates
+		believed.merge(pending)
+
+		var actual itemAggregates
+		prefix := k.key(itemKeyPrefix)
+		it := txn.NewIterator(badger.DefaultIteratorOptions)
+		for it.Seek(prefix); it.ValidForPrefix(prefix); it.Next() {
+			var item Item
+			if err := it.Item().Value(func(val []byte) error { return decodeItem(val, &item) }); err != nil {
+				continue // fsck.go quarantines these; they aren't items
+			}
+			actual.addItem(&item, 1)
+		}
+		it.Close()
+
+		drift = believed.drift(actual)
+		now := time.Now().UTC()
+		for _, key := range keys {
+			if err := txn.Delete(key); err != nil {
+				return err
+			}
+		}
+		return k.writeAggregateSnapshot(txn, aggregateSnapshot{
+			itemAggregates: actual,
+			FoldedAt:       now,
+			ReconciledAt:   now,
+			LastDrift:      drift,
+		})
+	})
+	return drift, err
+}
+
+func (k keyspace) writeAggregateSnapshot(txn *badger.Txn, snap aggregateSnapshot) error {
+	value, err := json.Marshal(snap)
+	if err != nil {
+		return err
+	}
+	return txn.Set(k.key(itemAggregatesKey), value)
+}
+
+// maintainAggregates folds every tenant's deltas, and reconciles them too
+// when reconcile is set. Followers leave it to the leader.
+func maintainAggregates(reconcile bool) {
+	if cluster != nil {
+		if _, isLeader := cluster.leader(); !isLeader {
+			return
+		}
+	}
+	for _, k := range tenants.keyspaces() {
+		if !reconcile {
+			if err := k.foldAggregates(); err != nil && !errors.Is(err, badger.ErrConflict) {
+				slog.Warn("failed to fold item aggregates", "tenant", dataSubject(k), "error", err)
+			}
+			// Creations per minute are folded the same way (timeseries.go)
+			if err := k.foldItemTimeseries(); err != nil && !errors.Is(err, badger.ErrConflict) {
+				slog.Warn("failed to fold item time series", "tenant", dataSubject(k), "error", err)
+			}
+			continue
+		}
+		drift, err := k.reconcileAggregates()
+		switch {
+		case errors.Is(err, badger.ErrConflict):
+			slog.Debug("item aggregates changed during reconciliation, retrying later", "tenant", dataSubject(k))
+		case err != nil:
+			slog.Warn("failed to reconcile item aggregates", "tenant", dataSubject(k), "error", err)
+		case drift != 0:
+			slog.Warn("item aggregates drifted, corrected", "tenant", dataSubject(k), "drift", drift)
+		}
+	}
+}
+
+// startAggregatesMaintainer reconciles once now (a database from before
+// aggregates existed has items but no totals), then folds every 10s and
+// reconciles every AGGREGATES_RECONCILE_INTERVAL (0 = only at startup)
+func startAggregatesMaintainer() {
+	maintainAggregates(true)
+	go func() {
+		ticker := time.NewTicker(aggregateFoldInterval)
+		defer ticker.Stop()
+		lastReconcile := time.Now()
+		for range ticker.C {
+			reconcile := aggregatesReconcileInterval > 0 && time.Since(lastReconcile) >= aggregatesReconcileInterval
+			if reconcile {
+				lastReconcile = time.Now()
+			}
+			maintainAggregates(reconcile)
+		}
+	}()
+}
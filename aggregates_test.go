@@ -0,0 +1,93 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"testing"
+
+	badger "github.com/dgraph-io/badger/v4"
+)
+
+func TestAggregates_MaintainedByWrites(t *testing.T) {
+	srv := newTestServer(t)
+
+	a := createTestItem(t, srv, `{"name":"a","description":"abcd","tags":["sale","new"]}`)
+	createTestItem(t, srv, `{"name":"b","tags":["sale"]}`)
+	doRequest(t, srv, http.MethodPut, "/api/items/"+strconv.FormatInt(a.ID, 10), `{"name":"a","tags":["clearance"]}`)
+
+	totals, status, err := rootKeyspace.aggregates()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if totals.Items != 2 || totals.Tags["sale"] != 1 || totals.Tags["clearance"] != 1 || totals.Tags["new"] != 0 {
+		t.Errorf("totals = %+v", totals)
+	}
+	if totals.DescriptionChars != 0 {
+		t.Errorf("description chars = %d after clearing the description", totals.DescriptionChars)
+	}
+	if status.PendingDeltas != 3 || status.FoldedAt != nil {
+		t.Errorf("status before folding = %+v", status)
+	}
+
+	// Folding keeps the totals and clears the deltas
+	if err := rootKeyspace.foldAggregates(); err != nil {
+		t.Fatal(err)
+	}
+	folded, status, _ := rootKeyspace.aggregates()
+	if folded.Items != 2 || folded.Tags["sale"] != 1 || status.PendingDeltas != 0 || status.FoldedAt == nil {
+		t.Errorf("after fold: totals %+v, status %+v", folded, status)
+	}
+
+	// Deletes count down from the folded total
+	doRequest(t, srv, http.MethodDelete, "/api/items/"+strconv.FormatInt(a.ID, 10), "")
+	totals, _, _ = rootKeyspace.aggregates()
+	if totals.Items != 1 || totals.Tags["clearance"] != 0 {
+		t.Errorf("after delete = %+v", totals)
+	}
+}
+
+func TestAggregates_ReconcileFindsDrift(t *testing.T) {
+	srv := newTestServer(t)
+	createTestItem(t, srv, `{"name":"a","tags":["sale"]}`)
+
+	// An item written behind the API's back, without a delta
+	err := db.Update(func(txn *badger.Txn) error {
+		value, _ := json.Marshal(Item{ID: 99, Name: "sneaky", Tags: []string{"sale"}})
+		return txn.Set(itemKey(99), value)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	drift, err := rootKeyspace.reconcileAggregates()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if drift != 2 { // one item, one "sale" tag
+		t.Errorf("drift = %d, want 2", drift)
+	}
+	totals, status, _ := rootKeyspace.aggregates()
+	if totals.Items != 2 || totals.Tags["sale"] != 2 || status.LastDrift != 2 || status.ReconciledAt == nil || status.PendingDeltas != 0 {
+		t.Errorf("after reconcile: totals %+v, status %+v", totals, status)
+	}
+
+	// Reconciled totals are exact, so the next run finds nothing
+	if drift, _ := rootKeyspace.reconcileAggregates(); drift != 0 {
+		t.Errorf("second reconcile drift = %d, want 0", drift)
+	}
+}
+
+func TestAggregates_PerTenant(t *testing.T) {
+	withMultiTenant(t)
+	srv := newTestServer(t)
+
+	createTestItem(t, srv, `{"name":"a","tags":["sale"]}`)
+	tenantRequest(t, srv, "acme", http.MethodPost, "/api/items", `{"name":"b","tags":["new"]}`)
+
+	root, _, _ := rootKeyspace.aggregates()
+	acme, _, _ := keyspaceFor("acme").aggregates()
+	if root.Items != 1 || root.Tags["new"] != 0 || acme.Items != 1 || acme.Tags["new"] != 1 {
+		t.Errorf("root = %+v, acme = %+v", root, acme)
+	}
+}
@@ -0,0 +1,425 @@
+package main
+
+import (
+	"bytes"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/smtp"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// =============================================================================
+// Alerts
+// =============================================================================
+//
+// App-level alerting, next to the Prometheus metrics: the app itself sends a
+// Slack message or an email when something worth a human's attention
+// happens. Prometheus alerts need Prometheus and Alertmanager running; these
+// need only a webhook URL or an SMTP server, which makes them easy to show.
+//
+//	ALERT_SLACK_WEBHOOK_URL=https://hooks.slack.com/services/... \
+//	ALERT_ITEM_COUNT_THRESHOLD=100 ALERT_ERROR_RATE_PERCENT=10 ./demo-app
+//	curl -X POST http://localhost:8080/api/admin/alerts/test
+//
+// Three things alert:
+//
+//	item_count   the number of items reaches ALERT_ITEM_COUNT_THRESHOLD
+//	             (and "resolved" when it drops below again)
+//	error_rate   5xx responses reach ALERT_ERROR_RATE_PERCENT of requests
+//	             in one ALERT_CHECK_INTERVAL (try GET /api/status/500)
+//	maintenance  maintenance mode is turned on or off (maintenance.go)
+//
+// Thresholds are checked every ALERT_CHECK_INTERVAL. A condition alerts once
+// when it starts ("firing") and once when it ends ("resolved"), not on every
+// check, so a stuck condition doesn't flood the channel. Like events.go,
+// alerts are sent from a queue by one goroutine and never slow a request.
+
+// Alert kinds
+const (
+	alertItemCount   = "item_count"
+	alertErrorRate   = "error_rate"
+	alertMaintenance = "maintenance"
+	alertTest        = "test"
+)
+
+// Alert states
+const (
+	alertFiring   = "firing"
+	alertResolved = "resolved"
+	alertInfo     = "info" // one-off notices: maintenance toggles, tests
+)
+
+const (
+	alertQueueSize   = 100 // alerts waiting to be sent before new ones are dropped
+	alertHistorySize = 50  // recent alerts kept for GET /api/admin/alerts
+	alertSendTimeout = 10 * time.Second
+)
+
+// Alert is one notification
+type Alert struct {
+	Kind     string    `json:"kind"`
+	State    string    `json:"state"`
+	Summary  string    `json:"summary"`
+	Instance string    `json:"instance"`
+	Time     time.Time `json:"time"`
+}
+
+// text is the one-line form sent to Slack and used as the email subject
+func (a Alert) text() string {
+	return fmt.Sprintf("[%s] %s on %s: %s", strings.ToUpper(a.State), a.Kind, a.Instance, a.Summary)
+}
+
+// alertNotifier delivers alerts to one channel
+type alertNotifier interface {
+	name() string
+	send(alert Alert) error
+}
+
+// AlertRules are the configured thresholds (0 = off)
+type AlertRules struct {
+	ItemCountThreshold    int    `json:"item_count_threshold"`
+	ErrorRatePercent      int    `json:"error_rate_percent"`
+	ErrorRateMinRequests  int    `json:"error_rate_min_requests"`
+	CheckInterval         string `json:"check_interval"`
+	checkIntervalDuration time.Duration
+}
+
+// alertManager checks thresholds and sends alerts to every notifier
+type alertManager struct {
+	notifiers []alertNotifier
+	rules     AlertRules
+	instance  string
+	queue     chan Alert
+
+	// Requests and 5xx responses since the last check (loggingMiddleware)
+	requests atomic.Int64
+	errors   atomic.Int64
+
+	mu         sync.Mutex
+	itemsHigh  bool // item_count is firing
+	errorsHigh bool // error_rate is firing
+	history    []Alert
+}
+
+// Active manager, nil unless a notifier is configured
+var alerts *alertManager
+
+// newAlertManager starts the goroutine that sends queued alerts
+func newAlertManager(notifiers []alertNotifier, rules AlertRules) *alertManager {
+	hostname, err := os.Hostname()
+	if err != nil {
+		hostname = "unknown"
+	}
+	rules.CheckInterval = rules.checkIntervalDuration.String()
+	m := &alertManager{notifiers: notifiers, rules: rules, instance: hostname, queue: make(chan Alert, alertQueueSize)}
+	go m.run()
+	return m
+}
+
+// start checks the thresholds every check interval, for as long as the app runs
+func (m *alertManager) start() {
+	if m.rules.ItemCountThreshold <= 0 && m.rules.ErrorRatePercent <= 0 {
+		return
+	}
+	go func() {
+		ticker := time.NewTicker(m.rules.checkIntervalDuration)
+		defer ticker.Stop()
+		for range ticker.C {
+			m.check()
+		}
+	}()
+}
+
+// recordRequest counts a finished request toward the error rate
+func (m *alertManager) recordRequest(status int) {
+	m.requests.Add(1)
+	if status >= 500 {
+		m.errors.Add(1)
+	}
+}
+
+// check compares the current numbers with the thresholds and fires an alert
+// for each condition that started or ended
+func (m *alertManager) check() {
+	if m.rules.ItemCountThreshold > 0 {
+		count, err := countItems()
+		if err != nil {
+			slog.Warn("alert check failed to count items", "error", err)
+		} else {
+			high := count >= m.rules.ItemCountThreshold
+			if m.transition(&m.itemsHigh, high) {
+				m.fire(alertItemCount, firingOrResolved(high),
+					fmt.Sprintf("%d items (threshold %d)", count, m.rules.ItemCountThreshold))
+			}
+		}
+	}
+
+	// Swap reads and resets together, so each check sees one interval
+	requests, errors := m.requests.Swap(0), m.errors.Swap(0)
+	// Too little traffic says nothing either way; keep the current state
+	if m.rules.ErrorRatePercent > 0 && requests >= int64(m.rules.ErrorRateMinRequests) && requests > 0 {
+		percent := int(errors * 100 / requests)
+		high := percent >= m.rules.ErrorRatePercent
+		if m.transition(&m.errorsHigh, high) {
+			m.fire(alertErrorRate, firingOrResolved(high),
+				fmt.Sprintf("%d%% of requests failed (%d of %d in %s, threshold %d%%)",
+					percent, errors, requests, m.rules.CheckInterval, m.rules.ErrorRatePercent))
+		}
+	}
+}
+
+// transition updates a condition's state and reports whether it changed
+func (m *alertManager) transition(state *bool, now bool) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	changed := *state != now
+	*state = now
+	return changed
+}
+
+func firingOrResolved(high bool) string {
+	if high {
+		return alertFiring
+	}
+	return alertResolved
+}
+
+// fire records an alert and queues it without waiting; a full queue drops it
+func (m *alertManager) fire(kind, state, summary string) {
+	alert := Alert{Kind: kind, State: state, Summary: summary, Instance: m.instance, Time: time.Now().UTC()}
+	slog.Warn("alert", "kind", kind, "state", state, "summary", summary)
+
+	m.mu.Lock()
+	m.history = append(m.history, alert)
+	if len(m.history) > alertHistorySize {
+		m.history = m.history[len(m.history)-alertHistorySize:]
+	}
+	m.mu.Unlock()
+
+	select {
+	case m.queue <- alert:
+	default:
+		for _, n := range m.notifiers {
+			alertsSentTotal.WithLabelValues(n.name(), "dropped").Inc()
+		}
+	}
+}
+
+// run sends each alert to every notifier
+func (m *alertManager) run() {
+	for alert := range m.queue {
+		for _, n := range m.notifiers {
+			if err := n.send(alert); err != nil {
+				alertsSentTotal.WithLabelValues(n.name(), "failed").Inc()
+				slog.Warn("failed to send alert", "notifier", n.name(), "kind", alert.Kind, "error", err)
+				continue
+			}
+			alertsSentTotal.WithLabelValues(n.name(), "sent").Inc()
+		}
+	}
+}
+
+// notifyMaintenance alerts that maintenance mode was toggled (no-op without
+// alerting)
+func notifyMaintenance(enabled bool, message string) {
+	if alerts == nil {
+		return
+	}
+	summary := "maintenance mode off"
+	if enabled {
+		summary = "maintenance mode on"
+		if message != "" {
+			summary += ": " + message
+		}
+	}
+	alerts.fire(alertMaintenance, alertInfo, summary)
+}
+
+// alertsAdminHandler handles GET /api/admin/alerts (rules, notifiers, and
+// recent alerts) and POST /api/admin/alerts/test (send a test alert)
+func alertsAdminHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	if alerts == nil {
+		http.Error(w, `{"error":"no alert notifier configured"}`, http.StatusNotFound)
+		return
+	}
+
+	if r.URL.Path == "/api/admin/alerts/test" {
+		if r.Method != http.MethodPost {
+			http.Error(w, `{"error":"method not allowed"}`, http.StatusMethodNotAllowed)
+			return
+		}
+		alerts.fire(alertTest, alertInfo, "test alert from POST /api/admin/alerts/test")
+		w.WriteHeader(http.StatusAccepted)
+		json.NewEncoder(w).Encode(map[string]string{"status": "queued"})
+		return
+	}
+	if r.Method != http.MethodGet {
+		http.Error(w, `{"error":"method not allowed"}`, http.StatusMethodNotAllowed)
+		return
+	}
+
+	names := make([]string, len(alerts.notifiers))
+	for i, n := range alerts.notifiers {
+		names[i] = n.name()
+	}
+	alerts.mu.Lock()
+	recent := append([]Alert{}, alerts.history...)
+	alerts.mu.Unlock()
+	json.NewEncoder(w).Encode(map[string]any{
+		"notifiers": names,
+		"rules":     alerts.rules,
+		"recent":    recent,
+	})
+}
+
+// =============================================================================
+// Notifiers
+// =============================================================================
+
+// slackNotifier posts to a Slack incoming webhook
+type slackNotifier struct {
+	webhookURL string
+	client     *http.Client
+}
+
+func newSlackNotifier(webhookURL string) *slackNotifier {
+	return &slackNotifier{webhookURL: webhookURL, client: newOutboundClient(alertSendTimeout)}
+}
+
+func (s *slackNotifier) name() string { return "slack" }
+
+// send posts {"text": "..."}, the simplest message an incoming webhook takes
+func (s *slackNotifier) send(alert Alert) error {
+	body, _ := json.Marshal(map[string]string{"text": alert.text()})
+	resp, err := s.client.Post(s.webhookURL, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("slack webhook returned %s", resp.Status)
+	}
+	return nil
+}
+
+// smtpNotifier emails alerts through an SMTP server
+type smtpNotifier struct {
+	host     string
+	port     int
+	username string
+	password string
+	from     string
+	to       []string
+}
+
+func (s *smtpNotifier) name() string { return "email" }
+
+// send delivers one email. STARTTLS is used when the server offers it; login
+// (PLAIN) only when a username is set, and net/smtp refuses to send the
+// password unencrypted to anything but localhost.
+func (s *smtpNotifier) send(alert Alert) error {
+	addr := net.JoinHostPort(s.host, strconv.Itoa(s.port))
+	conn, err := net.DialTimeout("tcp", addr, alertSendTimeout)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(alertSendTimeout))
+
+	client, err := smtp.NewClient(conn, s.host)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	if ok, _ := client.Extension("STARTTLS"); ok {
+		if err := client.StartTLS(&tls.Config{ServerName: s.host}); err != nil {
+			return err
+		}
+	}
+	if s.username != "" {
+		if err := client.Auth(smtp.PlainAuth("", s.username, s.password, s.host)); err != nil {
+			return err
+		}
+	}
+	if err := client.Mail(s.from); err != nil {
+		return err
+	}
+	for _, to := range s.to {
+		if err := client.Rcpt(to); err != nil {
+			return err
+		}
+	}
+	data, err := client.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := data.Write(s.message(alert)); err != nil {
+		return err
+	}
+	if err := data.Close(); err != nil {
+		return err
+	}
+	return client.Quit()
+}
+
+// message builds the email: headers, a blank line, then the body
+func (s *smtpNotifier) message(alert Alert) []byte {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "From: %s\r\n", s.from)
+	fmt.Fprintf(&b, "To: %s\r\n", strings.Join(s.to, ", "))
+	fmt.Fprintf(&b, "Subject: [demo-app] %s\r\n", alert.text())
+	fmt.Fprintf(&b, "Date: %s\r\n", alert.Time.Format(time.RFC1123Z))
+	b.WriteString("MIME-Version: 1.0\r\nContent-Type: text/plain; charset=utf-8\r\n\r\n")
+	fmt.Fprintf(&b, "Kind:     %s\r\nState:    %s\r\nInstance: %s\r\nTime:     %s\r\n\r\n%s\r\n",
+		alert.Kind, alert.State, alert.Instance, alert.Time.Format(time.RFC3339), alert.Summary)
+	return b.Bytes()
+}
+
+// configureAlerts builds the notifiers and rules from ALERT_* settings.
+// Returns nil, nil when no notifier is configured.
+func configureAlerts() (*alertManager, error) {
+	var notifiers []alertNotifier
+	if webhookURL := os.Getenv("ALERT_SLACK_WEBHOOK_URL"); webhookURL != "" {
+		notifiers = append(notifiers, newSlackNotifier(webhookURL))
+	}
+	if host := os.Getenv("ALERT_SMTP_HOST"); host != "" {
+		to := envList("ALERT_EMAIL_TO")
+		if len(to) == 0 {
+			return nil, fmt.Errorf("ALERT_EMAIL_TO is required with ALERT_SMTP_HOST")
+		}
+		hostname, _ := os.Hostname()
+		notifiers = append(notifiers, &smtpNotifier{
+			host:     host,
+			port:     envInt("ALERT_SMTP_PORT", 587),
+			username: os.Getenv("ALERT_SMTP_USERNAME"),
+			password: secret("ALERT_SMTP_PASSWORD"),
+			from:     envString("ALERT_EMAIL_FROM", "demo-app@"+hostname),
+			to:       to,
+		})
+	}
+	if len(notifiers) == 0 {
+		return nil, nil
+	}
+
+	rules := AlertRules{
+		ItemCountThreshold:    envInt("ALERT_ITEM_COUNT_THRESHOLD", 0),
+		ErrorRatePercent:      envInt("ALERT_ERROR_RATE_PERCENT", 0),
+		ErrorRateMinRequests:  envInt("ALERT_ERROR_RATE_MIN_REQUESTS", 20),
+		checkIntervalDuration: envDuration("ALERT_CHECK_INTERVAL", 30*time.Second),
+	}
+	if rules.checkIntervalDuration <= 0 {
+		return nil, fmt.Errorf("ALERT_CHECK_INTERVAL must be positive")
+	}
+	return newAlertManager(notifiers, rules), nil
+}
@@ -0,0 +1,227 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeNotifier collects sent alerts on a channel
+type fakeNotifier struct {
+	alerts chan Alert
+}
+
+func (f *fakeNotifier) name() string { return "fake" }
+
+func (f *fakeNotifier) send(alert Alert) error {
+	f.alerts <- alert
+	return nil
+}
+
+// withAlerts sends alerts to a fakeNotifier for one test
+func withAlerts(t *testing.T, rules AlertRules) *fakeNotifier {
+	t.Helper()
+	notifier := &fakeNotifier{alerts: make(chan Alert, 10)}
+	rules.checkIntervalDuration = time.Minute
+	prev := alerts
+	alerts = newAlertManager([]alertNotifier{notifier}, rules)
+	t.Cleanup(func() { alerts = prev })
+	return notifier
+}
+
+// nextAlert waits for the next sent alert
+func nextAlert(t *testing.T, notifier *fakeNotifier) Alert {
+	t.Helper()
+	select {
+	case alert := <-notifier.alerts:
+		return alert
+	case <-time.After(2 * time.Second):
+		t.Fatal("no alert sent")
+		return Alert{}
+	}
+}
+
+// noAlert checks nothing more was sent
+func noAlert(t *testing.T, notifier *fakeNotifier) {
+	t.Helper()
+	select {
+	case alert := <-notifier.alerts:
+		t.Errorf("unexpected alert %+v", alert)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestAlerts_ItemCountThreshold(t *testing.T) {
+	srv := newTestServer(t)
+	notifier := withAlerts(t, AlertRules{ItemCountThreshold: 2})
+
+	createTestItem(t, srv, `{"name":"one"}`)
+	alerts.check()
+	noAlert(t, notifier)
+
+	item := createTestItem(t, srv, `{"name":"two"}`)
+	alerts.check()
+	if alert := nextAlert(t, notifier); alert.Kind != alertItemCount || alert.State != alertFiring {
+		t.Errorf("alert = %+v, want item_count firing", alert)
+	}
+	// Still above: no repeat
+	alerts.check()
+	noAlert(t, notifier)
+
+	doRequest(t, srv, "DELETE", "/api/items/"+strconv.FormatInt(item.ID, 10), "")
+	alerts.check()
+	if alert := nextAlert(t, notifier); alert.State != alertResolved {
+		t.Errorf("alert = %+v, want item_count resolved", alert)
+	}
+}
+
+func TestAlerts_ErrorRate(t *testing.T) {
+	notifier := withAlerts(t, AlertRules{ErrorRatePercent: 20, ErrorRateMinRequests: 10})
+
+	for i := range 10 {
+		if i < 3 {
+			alerts.recordRequest(http.StatusInternalServerError)
+		} else {
+			alerts.recordRequest(http.StatusOK)
+		}
+	}
+	alerts.check()
+	alert := nextAlert(t, notifier)
+	if alert.Kind != alertErrorRate || alert.State != alertFiring || !strings.Contains(alert.Summary, "30%") {
+		t.Errorf("alert = %+v, want error_rate firing at 30%%", alert)
+	}
+
+	// Too few requests to judge: stays firing
+	alerts.recordRequest(http.StatusOK)
+	alerts.check()
+	noAlert(t, notifier)
+
+	for range 10 {
+		alerts.recordRequest(http.StatusOK)
+	}
+	alerts.check()
+	if alert := nextAlert(t, notifier); alert.State != alertResolved {
+		t.Errorf("alert = %+v, want error_rate resolved", alert)
+	}
+}
+
+func TestAlerts_AdminEndpoints(t *testing.T) {
+	srv := newTestServer(t)
+	if code, _ := doRequest(t, srv, "GET", "/api/admin/alerts", ""); code != http.StatusNotFound {
+		t.Errorf("without alerting: status = %d, want 404", code)
+	}
+
+	notifier := withAlerts(t, AlertRules{ItemCountThreshold: 5})
+	if code, _ := doRequest(t, srv, "POST", "/api/admin/alerts/test", ""); code != http.StatusAccepted {
+		t.Fatalf("test alert: status = %d, want 202", code)
+	}
+	if alert := nextAlert(t, notifier); alert.Kind != alertTest {
+		t.Errorf("alert = %+v, want a test alert", alert)
+	}
+
+	code, data := doRequest(t, srv, "GET", "/api/admin/alerts", "")
+	var got struct {
+		Notifiers []string   `json:"notifiers"`
+		Rules     AlertRules `json:"rules"`
+		Recent    []Alert    `json:"recent"`
+	}
+	json.Unmarshal(data, &got)
+	if code != http.StatusOK || len(got.Notifiers) != 1 || got.Rules.ItemCountThreshold != 5 || len(got.Recent) != 1 {
+		t.Errorf("GET /api/admin/alerts = %d %s", code, data)
+	}
+}
+
+func TestSlackNotifier(t *testing.T) {
+	withCircuits(t, 0, time.Minute)
+	var got map[string]string
+	slack := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&got)
+		w.Write([]byte("ok"))
+	}))
+	defer slack.Close()
+
+	alert := Alert{Kind: alertErrorRate, State: alertFiring, Summary: "25% of requests failed", Instance: "pod-1"}
+	if err := newSlackNotifier(slack.URL).send(alert); err != nil {
+		t.Fatal(err)
+	}
+	if got["text"] != "[FIRING] error_rate on pod-1: 25% of requests failed" {
+		t.Errorf("text = %q", got["text"])
+	}
+}
+
+func TestSMTPNotifier(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+
+	// A minimal SMTP server: no STARTTLS, no AUTH, accepts one message
+	received := make(chan []string, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		reader := bufio.NewReader(conn)
+		conn.Write([]byte("220 test ESMTP\r\n"))
+		var lines []string
+		inData := false
+		for {
+			line, err := reader.ReadString('\n')
+			if err != nil {
+				return
+			}
+			line = strings.TrimRight(line, "\r\n")
+			if inData {
+				if line == "." {
+					inData = false
+					conn.Write([]byte("250 queued\r\n"))
+					continue
+				}
+				lines = append(lines, line)
+				continue
+			}
+			switch cmd := strings.ToUpper(strings.Fields(line + " x")[0]); cmd {
+			case "EHLO", "HELO":
+				conn.Write([]byte("250 test\r\n"))
+			case "DATA":
+				inData = true
+				conn.Write([]byte("354 go ahead\r\n"))
+			case "QUIT":
+				conn.Write([]byte("221 bye\r\n"))
+				received <- lines
+				return
+			default: // MAIL, RCPT
+				lines = append(lines, line)
+				conn.Write([]byte("250 ok\r\n"))
+			}
+		}
+	}()
+
+	host, port, _ := net.SplitHostPort(ln.Addr().String())
+	portNum, _ := strconv.Atoi(port)
+	notifier := &smtpNotifier{host: host, port: portNum, from: "demo-app@test", to: []string{"oncall@example.com"}}
+	alert := Alert{Kind: alertMaintenance, State: alertInfo, Summary: "maintenance mode on", Instance: "pod-1", Time: time.Now()}
+	if err := notifier.send(alert); err != nil {
+		t.Fatal(err)
+	}
+
+	message := strings.Join(<-received, "\n")
+	for _, want := range []string{
+		"MAIL FROM:<demo-app@test>",
+		"RCPT TO:<oncall@example.com>",
+		"Subject: [demo-app] [INFO] maintenance on pod-1: maintenance mode on",
+	} {
+		if !strings.Contains(message, want) {
+			t.Errorf("message missing %q:\n%s", want, message)
+		}
+	}
+}
@@ -0,0 +1,180 @@
+package main
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+// fakeAMQPBroker accepts one connection, runs the server side of the
+// handshake, delivers bodies (message i gets message-id "msg-i"), then closes
+// the connection. Each Ack/Reject the client sends sets one entry of replies:
+// "ack", "reject", or "requeue".
+func fakeAMQPBroker(t *testing.T, queueName string, bodies ...string) (addr string, replies chan string) {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	replies = make(chan string, len(bodies))
+
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		ac := &amqpConn{conn: conn, reader: bufio.NewReader(conn)}
+
+		header := make([]byte, 8)
+		io.ReadFull(ac.reader, header)
+		if string(header) != "AMQP\x00\x00\x09\x01" {
+			t.Errorf("protocol header = %q", header)
+			return
+		}
+
+		// Start: version 0-9, no server properties, PLAIN, en_US
+		ac.sendMethod(0, amqpConnectionStart, new(amqpBuilder).u8(0).u8(9).table(nil).longstr("PLAIN").longstr("en_US").bytes())
+		args, _ := ac.expect(amqpConnectionStartOk)
+		r := amqpReader{data: args}
+		r.skip(int(r.u32())) // client properties
+		if mechanism, response := r.shortstr(), string(r.take(int(r.u32()))); mechanism != "PLAIN" || response != "\x00demo\x00s3cret" {
+			t.Errorf("login = %s %q", mechanism, response)
+		}
+
+		ac.sendMethod(0, amqpConnectionTune, new(amqpBuilder).u16(2047).u32(1<<20).u16(0).bytes())
+		args, _ = ac.expect(amqpConnectionTuneOk)
+		r = amqpReader{data: args}
+		if r.u16(); r.u32() != amqpFrameMax {
+			t.Error("client should lower frame-max to its own limit")
+		}
+
+		args, _ = ac.expect(amqpConnectionOpen)
+		if vhost := (&amqpReader{data: args}).shortstr(); vhost != "staging" {
+			t.Errorf("vhost = %q", vhost)
+		}
+		ac.sendMethod(0, amqpConnectionOpenOk, new(amqpBuilder).shortstr("").bytes())
+
+		ac.expect(amqpChannelOpen)
+		ac.sendMethod(amqpChannel, amqpChannelOpenOk, new(amqpBuilder).longstr("").bytes())
+		ac.expect(amqpBasicQos)
+		ac.sendMethod(amqpChannel, amqpBasicQosOk, nil)
+		args, _ = ac.expect(amqpBasicConsume)
+		r = amqpReader{data: args}
+		r.u16()
+		if got := r.shortstr(); got != queueName {
+			t.Errorf("consumed queue %q, want %q", got, queueName)
+		}
+		ac.sendMethod(amqpChannel, amqpBasicConsumeOk, new(amqpBuilder).shortstr("ctag").bytes())
+
+		for i, body := range bodies {
+			tag := uint64(i + 1)
+			ac.sendMethod(amqpChannel, amqpBasicDeliver,
+				new(amqpBuilder).shortstr("ctag").u64(tag).u8(0).shortstr("").shortstr(queueName).bytes())
+			// Properties: content-type and message-id, so the client has to
+			// skip one to find the other
+			props := new(amqpBuilder).u16(60).u16(0).u64(uint64(len(body))).u16(0x8000 | 0x0080).
+				shortstr("application/json").shortstr("msg-" + string(rune('0'+i)))
+			ac.writeFrame(amqpFrameHeader, amqpChannel, props.bytes())
+			// Split the body across two frames
+			half := len(body) / 2
+			ac.writeFrame(amqpFrameBody, amqpChannel, []byte(body[:half]))
+			ac.writeFrame(amqpFrameBody, amqpChannel, []byte(body[half:]))
+
+			method, args, err := ac.readMethod()
+			if err != nil {
+				return
+			}
+			r := amqpReader{data: args}
+			if r.u64() != tag {
+				t.Errorf("reply for the wrong delivery tag")
+			}
+			switch requeue := r.u8(); {
+			case method == amqpBasicAck:
+				replies <- "ack"
+			case method == amqpBasicReject && requeue == 0:
+				replies <- "reject"
+			case method == amqpBasicReject:
+				replies <- "requeue"
+			}
+		}
+
+		ac.sendMethod(0, amqpConnectionClose, new(amqpBuilder).u16(320).shortstr("CONNECTION_FORCED - shutdown").u16(0).u16(0).bytes())
+		ac.expect(amqpConnectionCloseOk)
+	}()
+	return ln.Addr().String(), replies
+}
+
+func TestAMQPSource_Consume(t *testing.T) {
+	srv := newTestServer(t)
+	c := withQueueConsumer(t, srv)
+
+	addr, replies := fakeAMQPBroker(t, "new-items",
+		`{"name":"from rabbit"}`,
+		`{"name":"from rabbit"}`, // different message-id, so a second item
+		`not json`,
+	)
+	u, _ := url.Parse("amqp://demo:s3cret@" + addr + "/staging")
+	source, err := newAMQPSource(u, "new-items")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = source.consume(c)
+	if err == nil || !strings.Contains(err.Error(), "CONNECTION_FORCED") {
+		t.Errorf("consume error = %v, want the server's close reason", err)
+	}
+	for _, want := range []string{"ack", "ack", "reject"} {
+		if got := <-replies; got != want {
+			t.Errorf("reply = %s, want %s", got, want)
+		}
+	}
+	if c.status.Created != 2 || c.status.Rejected != 1 {
+		t.Errorf("status = %+v", c.status)
+	}
+}
+
+func TestAMQPMessageID(t *testing.T) {
+	// content-type, headers {"x":"y"}, delivery-mode, then message-id
+	headers := new(amqpBuilder).shortstr("x").u8('S').longstr("y").bytes()
+	props := new(amqpBuilder).u16(0x8000 | 0x2000 | 0x1000 | 0x0080).
+		shortstr("application/json").u32(uint32(len(headers)))
+	props.buf = append(props.buf, headers...)
+	props.u8(2).shortstr("order-42")
+
+	r := amqpReader{data: props.bytes()}
+	if got := amqpMessageID(&r); got != "order-42" || r.err != nil {
+		t.Errorf("message id = %q (%v)", got, r.err)
+	}
+
+	r = amqpReader{data: new(amqpBuilder).u16(0x8000).shortstr("text/plain").bytes()}
+	if got := amqpMessageID(&r); got != "" {
+		t.Errorf("message id = %q, want none", got)
+	}
+}
+
+func TestNewAMQPSource(t *testing.T) {
+	tests := []struct {
+		url               string
+		addr, user, vhost string
+	}{
+		{"amqp://rabbitmq", "rabbitmq:5672", "guest", "/"},
+		{"amqp://rabbitmq/%2f", "rabbitmq:5672", "guest", "/"},
+		{"amqp://app:pw@rabbitmq:5673/orders", "rabbitmq:5673", "app", "orders"},
+	}
+	for _, tt := range tests {
+		u, _ := url.Parse(tt.url)
+		source, err := newAMQPSource(u, "items")
+		if err != nil {
+			t.Errorf("%s: %v", tt.url, err)
+			continue
+		}
+		if source.addr != tt.addr || source.user != tt.user || source.vhost != tt.vhost {
+			t.Errorf("%s: got %s %s %s", tt.url, source.addr, source.user, source.vhost)
+		}
+	}
+}
@@ -0,0 +1,448 @@
+package main
+
+import (
+	"cmp"
+	"database/sql"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"html/template"
+	"io"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	badger "github.com/dgraph-io/badger/v4"
+)
+
+// =============================================================================
+// Analyze (post-demo report)
+// =============================================================================
+//
+// After a workshop the data directory holds what happened: the items people
+// created, which clients called how often, the hits each replica served, and
+// any recorded writes. analyze reads all of it with the server stopped and
+// writes it somewhere easier to look at:
+//
+//	./demo-app analyze --db /data --out report.html
+//	./demo-app analyze --db /data --sqlite analytics.db
+//	sqlite3 analytics.db "SELECT tenant, count(*) FROM items GROUP BY tenant"
+//
+// The HTML report is a single file with no scripts or external assets, so
+// it can be attached to a ticket or opened on a laptop with no network. The
+// SQLite file has one table per kind of data (items, clients, instance_hits,
+// recorded_requests) and is replaced on every run.
+//
+// Everything comes from this replica's directory: client stats are as of
+// their last save (CLIENT_STATS_PERSIST_INTERVAL), and each replica only
+// has its own hits and recordings unless the directories were copied
+// together.
+
+// Most item rows in the HTML report; the SQLite file has all of them
+const maxReportItems = 500
+
+// AnalyzedItem is an item and the tenant it belongs to
+type AnalyzedItem struct {
+	Tenant string `json:"tenant"`
+	Item
+}
+
+// CountRow is one row of a count table, with its share of the largest
+// count for the report's bars
+type CountRow struct {
+	Name    string
+	Count   int64
+	Percent float64
+}
+
+// InstanceHits is one replica's merged hit counter (hitcounter.go)
+type InstanceHits struct {
+	Instance string
+	Hits     uint64
+}
+
+// Analysis is everything analyze found in a data directory
+type Analysis struct {
+	GeneratedAt time.Time
+	DBPath      string
+	Version     string
+
+	Items         []AnalyzedItem
+	Tenants       []CountRow // items per tenant
+	Categories    []CountRow // items per top-level category
+	CreatedPerDay []CountRow // items per UTC creation date, oldest first
+
+	Clients    []ClientStats
+	Hits       []InstanceHits
+	TotalHits  uint64
+	Recordings []RecordedRequest
+	Recorded   []CountRow // recorded writes per method and path
+}
+
+// runAnalyzeCommand is "demo-app analyze"
+func runAnalyzeCommand(args []string, out io.Writer) int {
+	flags := flag.NewFlagSet("analyze", flag.ContinueOnError)
+	dbPath := dbFlag(flags)
+	htmlOut := flags.String("out", "", "write an HTML report to this file")
+	sqliteOut := flags.String("sqlite", "", "write the data to this SQLite file")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if *htmlOut == "" && *sqliteOut == "" {
+		fmt.Fprintln(os.Stderr, "analyze: set --out, --sqlite, or both")
+		return 2
+	}
+
+	closeDB, err := openDataDir(*dbPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "analyze: %v\n", err)
+		return 1
+	}
+	defer closeDB()
+
+	analysis, err := analyzeStore(*dbPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "analyze: %v\n", err)
+		return 1
+	}
+	if *sqliteOut != "" {
+		if err := writeAnalysisSQLite(analysis, *sqliteOut); err != nil {
+			fmt.Fprintf(os.Stderr, "analyze: %s: %v\n", *sqliteOut, err)
+			return 1
+		}
+	}
+	if *htmlOut != "" {
+		if err := writeAnalysisHTML(analysis, *htmlOut); err != nil {
+			fmt.Fprintf(os.Stderr, "analyze: %s: %v\n", *htmlOut, err)
+			return 1
+		}
+	}
+
+	json.NewEncoder(out).Encode(map[string]any{
+		"items":      len(analysis.Items),
+		"tenants":    len(analysis.Tenants),
+		"clients":    len(analysis.Clients),
+		"hits":       analysis.TotalHits,
+		"recordings": len(analysis.Recordings),
+		"report":     *htmlOut,
+		"sqlite":     *sqliteOut,
+	})
+	return 0
+}
+
+// analyzeStore reads items and request stats from the open database
+func analyzeStore(dbPath string) (*Analysis, error) {
+	a := &Analysis{GeneratedAt: time.Now().UTC(), DBPath: dbPath, Version: version}
+
+	tenantCounts := map[string]int64{}
+	for _, k := range tenants.keyspaces() {
+		prefix := k.key(itemKeyPrefix)
+		err := dbView("analyze_items", string(prefix), func(txn *badger.Txn) error {
+			it := txn.NewIterator(badger.DefaultIteratorOptions)
+			defer it.Close()
+			for it.Seek(prefix); it.ValidForPrefix(prefix); it.Next() {
+				var item Item
+				if err := it.Item().Value(func(val []byte) error { return decodeItem(val, &item) }); err != nil {
+					continue // malformed; fsck's business
+				}
+				a.Items = append(a.Items, AnalyzedItem{Tenant: tenantName(k), Item: item})
+				tenantCounts[tenantName(k)]++
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	categories, days := map[string]int64{}, map[string]int64{}
+	for _, item := range a.Items {
+		category, _, _ := strings.Cut(item.Category, "/")
+		categories[cmp.Or(category, "(none)")]++
+		days[item.CreatedAt.UTC().Format(time.DateOnly)]++
+	}
+	a.Tenants = countRows(tenantCounts, false)
+	a.Categories = countRows(categories, false)
+	a.CreatedPerDay = countRows(days, true)
+
+	// A fresh store, so the saved counts aren't mixed with this process's
+	saved := &clientStatsStore{clients: map[string]*ClientStats{}}
+	if err := saved.load(); err != nil {
+		return nil, fmt.Errorf("client stats: %w", err)
+	}
+	a.Clients = saved.list()
+
+	hits, err := readInstanceHits()
+	if err != nil {
+		return nil, fmt.Errorf("hit counters: %w", err)
+	}
+	a.Hits = hits
+	for _, h := range hits {
+		a.TotalHits += h.Hits
+	}
+
+	if a.Recordings, err = loadRecordings(); err != nil {
+		return nil, fmt.Errorf("recordings: %w", err)
+	}
+	recorded := map[string]int64{}
+	for _, rec := range a.Recordings {
+		path, _, _ := strings.Cut(rec.Path, "?")
+		recorded[rec.Method+" "+path]++
+	}
+	a.Recorded = countRows(recorded, false)
+	return a, nil
+}
+
+// countRows turns counts into rows, largest first (or by name, for dates)
+func countRows(counts map[string]int64, byName bool) []CountRow {
+	var largest int64
+	rows := make([]CountRow, 0, len(counts))
+	for name, count := range counts {
+		rows = append(rows, CountRow{Name: name, Count: count})
+		largest = max(largest, count)
+	}
+	for i := range rows {
+		rows[i].Percent = float64(rows[i].Count) * 100 / float64(largest)
+	}
+	slices.SortFunc(rows, func(a, b CountRow) int {
+		if byName {
+			return strings.Compare(a.Name, b.Name)
+		}
+		return cmp.Or(cmp.Compare(b.Count, a.Count), strings.Compare(a.Name, b.Name))
+	})
+	return rows
+}
+
+// readInstanceHits sums each replica's hit counter the way its merge
+// operator does: every entry back to the last fold, which marks itself as
+// the oldest one worth reading
+func readInstanceHits() ([]InstanceHits, error) {
+	var hits []InstanceHits
+	err := dbView("analyze_hits", hitCounterKeyPrefix, func(txn *badger.Txn) error {
+		opts := badger.DefaultIteratorOptions
+		opts.AllVersions = true
+		opts.Prefix = []byte(hitCounterKeyPrefix)
+		it := txn.NewIterator(opts)
+		defer it.Close()
+
+		current, done := "", false
+		for it.Rewind(); it.Valid(); it.Next() {
+			item := it.Item()
+			if key := string(item.Key()); key != current {
+				current, done = key, false
+				hits = append(hits, InstanceHits{Instance: strings.TrimPrefix(key, hitCounterKeyPrefix)})
+			}
+			if done {
+				continue
+			}
+			if item.IsDeletedOrExpired() {
+				done = true
+				continue
+			}
+			err := item.Value(func(val []byte) error {
+				hits[len(hits)-1].Hits += decodeUint64(val)
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+			done = item.DiscardEarlierVersions()
+		}
+		return nil
+	})
+	return hits, err
+}
+
+// =============================================================================
+// Output
+// =============================================================================
+
+// writeAnalysisSQLite replaces the tables in a SQLite file with the analysis
+func writeAnalysisSQLite(a *Analysis, path string) error {
+	conn, err := sql.Open("sqlite", path) // driver registered in shadow.go
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	tx, err := conn.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	statements := []string{
+		`DROP TABLE IF EXISTS items`,
+		`DROP TABLE IF EXISTS clients`,
+		`DROP TABLE IF EXISTS instance_hits`,
+		`DROP TABLE IF EXISTS recorded_requests`,
+		`CREATE TABLE items (
+			tenant      TEXT    NOT NULL,
+			id          INTEGER NOT NULL,
+			name        TEXT    NOT NULL,
+			description TEXT    NOT NULL,
+			category    TEXT    NOT NULL,
+			tags        TEXT    NOT NULL, -- JSON array
+			metadata    TEXT    NOT NULL, -- JSON object
+			version     INTEGER NOT NULL,
+			created_at  TEXT    NOT NULL,
+			PRIMARY KEY (tenant, id)
+		)`,
+		`CREATE TABLE clients (
+			client     TEXT    NOT NULL PRIMARY KEY,
+			kind       TEXT    NOT NULL,
+			requests   INTEGER NOT NULL,
+			errors     INTEGER NOT NULL,
+			error_rate REAL    NOT NULL,
+			first_seen TEXT    NOT NULL,
+			last_seen  TEXT    NOT NULL
+		)`,
+		`CREATE TABLE instance_hits (
+			instance TEXT    NOT NULL PRIMARY KEY,
+			hits     INTEGER NOT NULL
+		)`,
+		`CREATE TABLE recorded_requests (
+			id         INTEGER NOT NULL PRIMARY KEY,
+			time       TEXT    NOT NULL,
+			method     TEXT    NOT NULL,
+			path       TEXT    NOT NULL,
+			body_bytes INTEGER NOT NULL
+		)`,
+	}
+	for _, stmt := range statements {
+		if _, err := tx.Exec(stmt); err != nil {
+			return err
+		}
+	}
+
+	for _, item := range a.Items {
+		tags, metadata := []byte("[]"), []byte("{}")
+		if len(item.Tags) > 0 {
+			tags, _ = json.Marshal(item.Tags)
+		}
+		if len(item.Metadata) > 0 {
+			metadata, _ = json.Marshal(item.Metadata)
+		}
+		_, err := tx.Exec(`INSERT INTO items VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+			item.Tenant, item.ID, item.Name, item.Description, item.Category,
+			string(tags), string(metadata), item.Version, item.CreatedAt.UTC().Format(time.RFC3339Nano))
+		if err != nil {
+			return err
+		}
+	}
+	for _, c := range a.Clients {
+		_, err := tx.Exec(`INSERT INTO clients VALUES (?, ?, ?, ?, ?, ?, ?)`,
+			c.Client, c.Kind, c.Requests, c.Errors, c.ErrorRate,
+			c.FirstSeen.UTC().Format(time.RFC3339Nano), c.LastSeen.UTC().Format(time.RFC3339Nano))
+		if err != nil {
+			return err
+		}
+	}
+	for _, h := range a.Hits {
+		if _, err := tx.Exec(`INSERT INTO instance_hits VALUES (?, ?)`, h.Instance, int64(h.Hits)); err != nil {
+			return err
+		}
+	}
+	for _, rec := range a.Recordings {
+		_, err := tx.Exec(`INSERT INTO recorded_requests VALUES (?, ?, ?, ?, ?)`,
+			rec.ID, rec.Time.UTC().Format(time.RFC3339Nano), rec.Method, rec.Path, len(rec.Body))
+		if err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// writeAnalysisHTML writes the report page
+func writeAnalysisHTML(a *Analysis, path string) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := analysisTemplate.Execute(file, a); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// The report: one self-contained page, styled inline
+var analysisTemplate = template.Must(template.New("analysis").Funcs(template.FuncMap{
+	"reportItems": func(items []AnalyzedItem) []AnalyzedItem { return items[:min(len(items), maxReportItems)] },
+	"maxItems":    func() int { return maxReportItems },
+	"date":        func(t time.Time) string { return t.UTC().Format("2006-01-02 15:04:05") },
+	"percent":     func(f float64) string { return fmt.Sprintf("%.1f%%", f*100) },
+}).Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>demo-app analysis - {{.DBPath}}</title>
+<style>
+body { font-family: system-ui, sans-serif; margin: 2rem auto; max-width: 64rem; color: #1f2933; padding: 0 1rem; }
+h1 { margin-bottom: 0.25rem; }
+h2 { margin-top: 2.5rem; border-bottom: 1px solid #d9e2ec; padding-bottom: 0.25rem; }
+.meta { color: #616e7c; margin-top: 0; }
+.cards { display: flex; flex-wrap: wrap; gap: 1rem; }
+.card { background: #f0f4f8; border-radius: 6px; padding: 0.75rem 1.25rem; min-width: 8rem; }
+.card .value { font-size: 1.75rem; font-weight: 600; }
+.card .label { color: #616e7c; font-size: 0.875rem; }
+table { border-collapse: collapse; width: 100%; font-size: 0.875rem; }
+th, td { text-align: left; padding: 0.3rem 0.5rem; border-bottom: 1px solid #e4e7eb; vertical-align: top; }
+th { background: #f5f7fa; }
+td.num { text-align: right; font-variant-numeric: tabular-nums; }
+.bar { background: #3e7bfa; height: 0.75rem; border-radius: 2px; min-width: 1px; }
+.empty, .note { color: #616e7c; font-style: italic; }
+</style>
+</head>
+<body>
+<h1>demo-app analysis</h1>
+<p class="meta">{{.DBPath}} &middot; generated {{date .GeneratedAt}} UTC by demo-app {{.Version}}</p>
+
+<div class="cards">
+  <div class="card"><div class="value">{{len .Items}}</div><div class="label">items</div></div>
+  <div class="card"><div class="value">{{len .Tenants}}</div><div class="label">tenants</div></div>
+  <div class="card"><div class="value">{{len .Clients}}</div><div class="label">clients</div></div>
+  <div class="card"><div class="value">{{.TotalHits}}</div><div class="label">API requests</div></div>
+  <div class="card"><div class="value">{{len .Recordings}}</div><div class="label">recorded writes</div></div>
+</div>
+
+{{define "counts"}}{{if .}}<table>
+  {{range .}}<tr><td>{{.Name}}</td><td class="num">{{.Count}}</td><td style="width: 50%"><div class="bar" style="width: {{.Percent}}%"></div></td></tr>
+  {{end}}</table>{{else}}<p class="empty">None.</p>{{end}}{{end}}
+
+<h2>Items created per day</h2>
+{{template "counts" .CreatedPerDay}}
+
+<h2>Items per tenant</h2>
+{{template "counts" .Tenants}}
+
+<h2>Items per category</h2>
+{{template "counts" .Categories}}
+
+<h2>Clients</h2>
+<p class="note">As of the last save of the per-client counts.</p>
+{{if .Clients}}<table>
+  <tr><th>Client</th><th>Kind</th><th>Requests</th><th>Errors</th><th>Error rate</th><th>First seen</th><th>Last seen</th></tr>
+  {{range .Clients}}<tr><td>{{.Client}}</td><td>{{.Kind}}</td><td class="num">{{.Requests}}</td><td class="num">{{.Errors}}</td>
+    <td class="num">{{percent .ErrorRate}}</td><td>{{date .FirstSeen}}</td><td>{{date .LastSeen}}</td></tr>
+  {{end}}</table>{{else}}<p class="empty">None saved.</p>{{end}}
+
+<h2>API requests per replica</h2>
+{{if .Hits}}<table>
+  <tr><th>Instance</th><th>Requests</th></tr>
+  {{range .Hits}}<tr><td>{{.Instance}}</td><td class="num">{{.Hits}}</td></tr>
+  {{end}}</table>{{else}}<p class="empty">None counted (MERGED_HIT_COUNTERS off, or no traffic).</p>{{end}}
+
+<h2>Recorded writes</h2>
+{{template "counts" .Recorded}}
+
+<h2>Items</h2>
+{{if gt (len .Items) maxItems}}<p class="note">The first {{maxItems}} of {{len .Items}}; use --sqlite for all of them.</p>{{end}}
+{{if .Items}}<table>
+  <tr><th>Tenant</th><th>ID</th><th>Name</th><th>Category</th><th>Tags</th><th>Version</th><th>Created</th></tr>
+  {{range reportItems .Items}}<tr><td>{{.Tenant}}</td><td class="num">{{.ID}}</td><td>{{.Name}}</td><td>{{.Category}}</td>
+    <td>{{range $i, $tag := .Tags}}{{if $i}}, {{end}}{{$tag}}{{end}}</td><td class="num">{{.Version}}</td><td>{{date .CreatedAt}}</td></tr>
+  {{end}}</table>{{else}}<p class="empty">None.</p>{{end}}
+</body>
+</html>
+`))
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	badger "github.com/dgraph-io/badger/v4"
+)
+
+func TestAnalyzeCommand(t *testing.T) {
+	withOfflineDB(t)
+	dir := t.TempDir()
+	data := filepath.Join(dir, "data")
+
+	seed := filepath.Join(dir, "seed.ndjson")
+	os.WriteFile(seed, []byte(`{"name":"<b>laptop</b>","category":"hardware/laptops","tags":["demo"]}
+{"name":"mouse","category":"hardware/mice"}
+{"name":"notes"}
+`), 0o644)
+	runJSON(t, runSeedCommand, "--db", data, "--file", seed)
+
+	// Request stats as the server leaves them: saved client counts, and a
+	// hit counter with two unfolded merge entries
+	closeDB, err := openDataDir(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	clients, _ := json.Marshal([]ClientStats{{Client: apiKeyFingerprint("team-a"), Kind: "api_key", Requests: 40, Errors: 4,
+		FirstSeen: time.Now(), LastSeen: time.Now()}})
+	for _, write := range []func(txn *badger.Txn) error{
+		func(txn *badger.Txn) error { return txn.Set([]byte(clientStatsKey), clients) },
+		func(txn *badger.Txn) error { return txn.Set([]byte(hitCounterKeyPrefix+"replica-a"), encodeUint64(5)) },
+		func(txn *badger.Txn) error { return txn.Set([]byte(hitCounterKeyPrefix+"replica-a"), encodeUint64(3)) },
+	} {
+		if err := db.Update(write); err != nil {
+			t.Fatal(err)
+		}
+	}
+	closeDB()
+
+	report, analytics := filepath.Join(dir, "report.html"), filepath.Join(dir, "analytics.db")
+	got := runJSON(t, runAnalyzeCommand, "--db", data, "--out", report, "--sqlite", analytics)
+	if got["items"] != 3.0 || got["clients"] != 1.0 || got["hits"] != 8.0 {
+		t.Errorf("analyze = %v", got)
+	}
+
+	page, err := os.ReadFile(report)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"&lt;b&gt;laptop&lt;/b&gt;", apiKeyFingerprint("team-a"), "replica-a", "hardware", "(none)"} {
+		if !strings.Contains(string(page), want) {
+			t.Errorf("report is missing %q", want)
+		}
+	}
+
+	conn, err := sql.Open("sqlite", analytics)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	var items int
+	var tags string
+	conn.QueryRow(`SELECT count(*) FROM items`).Scan(&items)
+	conn.QueryRow(`SELECT tags FROM items WHERE name = 'mouse'`).Scan(&tags)
+	if items != 3 || tags != "[]" {
+		t.Errorf("sqlite items = %d, mouse tags = %q", items, tags)
+	}
+
+	// A second run replaces the tables instead of adding to them
+	runJSON(t, runAnalyzeCommand, "--db", data, "--sqlite", analytics)
+	conn.QueryRow(`SELECT count(*) FROM items`).Scan(&items)
+	if items != 3 {
+		t.Errorf("sqlite items after a second run = %d, want 3", items)
+	}
+}
+
+func TestAnalyzeCommand_Usage(t *testing.T) {
+	withOfflineDB(t)
+	var out strings.Builder
+	if code := runAnalyzeCommand([]string{"--db", t.TempDir()}, &out); code != 2 {
+		t.Errorf("analyze without --out or --sqlite: exit code = %d, want 2", code)
+	}
+	if code := runAnalyzeCommand([]string{"--db", ":memory:", "--out", "report.html"}, &out); code != 1 {
+		t.Errorf("analyze of :memory:: exit code = %d, want 1", code)
+	}
+}
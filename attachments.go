@@ -0,0 +1,304 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	badger "github.com/dgraph-io/badger/v4"
+)
+
+// =============================================================================
+// Item Attachments
+// =============================================================================
+//
+// Each item can carry one small file — a logo, a config snippet, a PDF:
+//
+//	# raw body; Content-Type is kept (and guessed from the bytes if missing)
+//	curl -X POST --data-binary @logo.png -H "Content-Type: image/png" \
+//	     "http://localhost:8080/api/items/1/attachment?filename=logo.png"
+//	# or a browser-style form upload
+//	curl -X POST -F file=@logo.png http://localhost:8080/api/items/1/attachment
+//
+//	curl http://localhost:8080/api/items/1/attachment -o logo.png
+//	curl -X DELETE http://localhost:8080/api/items/1/attachment
+//
+// That exercises paths JSON endpoints don't: binary bodies, multipart
+// parsing, request size limits (413), and real storage growth.
+//
+// The bytes live under their own key ("attachment:<padded id>") so listing
+// items never loads them; the item only records the file's name, type, and
+// size. Both are written in one transaction.
+//
+// Downloads keep the uploaded Content-Type, but only images, plain text, and
+// PDFs open in the browser. Anything else (an HTML page, an SVG) is sent as
+// a download, so an upload can't run script on the app's origin.
+
+// Prefix for attachment data keys
+const attachmentKeyPrefix = "attachment:"
+
+// Largest attachment accepted, from ATTACHMENT_MAX_BYTES (set in main)
+var attachmentMaxBytes int64 = 1 << 20 // 1 MiB
+
+// AttachmentInfo describes an item's attachment (the bytes are stored separately)
+type AttachmentInfo struct {
+	Filename    string    `json:"filename,omitempty"`
+	ContentType string    `json:"content_type"`
+	Size        int64     `json:"size"`
+	UploadedAt  time.Time `json:"uploaded_at"`
+}
+
+// Uploaded types a browser may show inline; they can't run script.
+// image/svg+xml is missing on purpose: SVG can.
+var inlineContentTypes = map[string]bool{
+	"image/png":       true,
+	"image/jpeg":      true,
+	"image/gif":       true,
+	"image/webp":      true,
+	"text/plain":      true,
+	"application/pdf": true,
+}
+
+// setUploadedContentHeaders sets Content-Type and Content-Disposition for
+// bytes a client uploaded: inline for the types above, a download for the
+// rest. nosniff stops the browser from second-guessing the type.
+func setUploadedContentHeaders(w http.ResponseWriter, contentType, filename string) {
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	disposition := "attachment"
+	if mediaType, _, err := mime.ParseMediaType(contentType); err == nil && inlineContentTypes[mediaType] {
+		disposition = "inline"
+	}
+	params := map[string]string{}
+	if filename != "" {
+		params["filename"] = filename
+	}
+	w.Header().Set("Content-Disposition", mime.FormatMediaType(disposition, params))
+}
+
+// attachmentKey is the data key for an item's attachment
+func attachmentKey(id int64) []byte {
+	return []byte(fmt.Sprintf("%s%0*d", attachmentKeyPrefix, itemKeyDigits, id))
+}
+
+// attachmentHandler handles /api/items/:id/attachment
+func attachmentHandler(w http.ResponseWriter, r *http.Request, id int64) {
+	switch r.Method {
+	case http.MethodGet:
+		getAttachment(w, r, id)
+	case http.MethodPost, http.MethodPut:
+		uploadAttachment(w, r, id)
+	case http.MethodDelete:
+		deleteAttachment(w, r, id)
+	default:
+		http.Error(w, `{"error":"method not allowed"}`, http.StatusMethodNotAllowed)
+	}
+}
+
+// uploadAttachment stores the request body (or the "file" form field) as
+// the item's attachment, replacing any previous one
+func uploadAttachment(w http.ResponseWriter, r *http.Request, id int64) {
+	// MaxBytesReader stops reading past the limit — the body is never
+	// buffered beyond it, however big the client claims it is
+	r.Body = http.MaxBytesReader(w, r.Body, attachmentMaxBytes+64<<10) // room for multipart headers
+
+	data, info, err := readAttachment(r)
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) || (err == nil && int64(len(data)) > attachmentMaxBytes) {
+		writeJSONError(w, http.StatusRequestEntityTooLarge,
+			fmt.Sprintf("attachment larger than %d bytes", attachmentMaxBytes))
+		return
+	}
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if !checkStorageQuota(w, r, int64(len(data))) { // quota.go
+		return
+	}
+
+	var item Item
+	key := itemKey(id)
+	err = dbUpdateContext(r.Context(), "attachment_put", string(attachmentKey(id)), func(txn *badger.Txn) error {
+		entry, err := txn.Get(key)
+		if err != nil {
+			return err
+		}
+		if err := entry.Value(func(val []byte) error { return decodeItem(val, &item) }); err != nil {
+			return err
+		}
+
+		item.Attachment = &info
+		item.Version++ // itemversion.go
+		value, err := encodeItem(item)
+		if err != nil {
+			return err
+		}
+		if err := touchItemsVersion(txn); err != nil { // the list shows attachments
+			return err
+		}
+		if err := txn.Set(key, value); err != nil {
+			return err
+		}
+		return txn.Set(attachmentKey(id), data)
+	})
+	if errors.Is(err, badger.ErrKeyNotFound) {
+		http.Error(w, `{"error":"not found"}`, http.StatusNotFound)
+		return
+	}
+	if errors.Is(err, badger.ErrTxnTooBig) {
+		writeJSONError(w, http.StatusRequestEntityTooLarge, "attachment too large for the database")
+		return
+	}
+	if err != nil {
+		logHandlerError(r.Context(), "attachments", "database", "failed to store attachment", "error", err)
+		http.Error(w, `{"error":"database error"}`, http.StatusInternalServerError)
+		return
+	}
+
+	slog.InfoContext(r.Context(), "attachment stored", "item_id", id, "size", info.Size, "content_type", info.ContentType)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(item)
+}
+
+// readAttachment reads the upload: a multipart form's "file" field, or the
+// raw body with its Content-Type
+func readAttachment(r *http.Request) ([]byte, AttachmentInfo, error) {
+	info := AttachmentInfo{Filename: r.URL.Query().Get("filename"), UploadedAt: time.Now().UTC()}
+	contentType := r.Header.Get("Content-Type")
+
+	var data []byte
+	var err error
+	if mediaType, _, _ := mime.ParseMediaType(contentType); mediaType == "multipart/form-data" {
+		// Keep the whole form in memory: the body is already size-capped
+		if err := r.ParseMultipartForm(attachmentMaxBytes + 64<<10); err != nil {
+			return nil, info, err
+		}
+		file, header, ferr := r.FormFile("file")
+		if ferr != nil {
+			return nil, info, errors.New(`multipart upload needs a "file" field`)
+		}
+		defer file.Close()
+		data, err = io.ReadAll(file)
+		if info.Filename == "" {
+			info.Filename = header.Filename
+		}
+		contentType = header.Header.Get("Content-Type")
+	} else {
+		data, err = io.ReadAll(r.Body)
+	}
+	if err != nil {
+		return nil, info, err
+	}
+	if len(data) == 0 {
+		return nil, info, errors.New("attachment is empty")
+	}
+
+	// curl --data-binary sends form-urlencoded unless told otherwise;
+	// guess from the first bytes instead (like browsers do)
+	if contentType == "" || strings.HasPrefix(contentType, "application/x-www-form-urlencoded") {
+		contentType = http.DetectContentType(data)
+	}
+	info.ContentType = contentType
+	info.Size = int64(len(data))
+	return data, info, nil
+}
+
+// getAttachment streams an item's attachment back with its Content-Type
+func getAttachment(w http.ResponseWriter, r *http.Request, id int64) {
+	var info *AttachmentInfo
+	err := dbViewContext(r.Context(), "attachment_get", string(attachmentKey(id)), func(txn *badger.Txn) error {
+		entry, err := txn.Get(itemKey(id))
+		if err != nil {
+			return err
+		}
+		var item Item
+		if err := entry.Value(func(val []byte) error { return decodeItem(val, &item) }); err != nil {
+			return err
+		}
+		if item.Attachment == nil {
+			return nil
+		}
+		info = item.Attachment
+
+		data, err := txn.Get(attachmentKey(id))
+		if err != nil {
+			return err
+		}
+		// Headers must be set before the first Write
+		setUploadedContentHeaders(w, info.ContentType, info.Filename)
+		w.Header().Set("Content-Length", strconv.FormatInt(data.ValueSize(), 10))
+		// Value hands us Badger's own buffer, valid only inside the
+		// transaction — so write it out from in here
+		return data.Value(func(val []byte) error {
+			_, err := w.Write(val)
+			return err
+		})
+	})
+	if errors.Is(err, badger.ErrKeyNotFound) || (err == nil && info == nil) {
+		http.Error(w, `{"error":"not found"}`, http.StatusNotFound)
+		return
+	}
+	if err != nil && info == nil {
+		logHandlerError(r.Context(), "attachments", "database", "failed to read attachment", "error", err)
+		http.Error(w, `{"error":"database error"}`, http.StatusInternalServerError)
+		return
+	}
+	if err != nil {
+		// Headers are already sent; the client sees a short body
+		logHandlerError(r.Context(), "attachments", "database", "failed to send attachment", "error", err)
+	}
+}
+
+// deleteAttachment removes an item's attachment (204, or 404 if there is none)
+func deleteAttachment(w http.ResponseWriter, r *http.Request, id int64) {
+	found := false
+	key := itemKey(id)
+	err := dbUpdateContext(r.Context(), "attachment_delete", string(attachmentKey(id)), func(txn *badger.Txn) error {
+		entry, err := txn.Get(key)
+		if err != nil {
+			return err
+		}
+		var item Item
+		if err := entry.Value(func(val []byte) error { return decodeItem(val, &item) }); err != nil {
+			return err
+		}
+		if item.Attachment == nil {
+			return nil
+		}
+		found = true
+
+		item.Attachment = nil
+		item.Version++
+		value, err := encodeItem(item)
+		if err != nil {
+			return err
+		}
+		if err := touchItemsVersion(txn); err != nil {
+			return err
+		}
+		if err := txn.Set(key, value); err != nil {
+			return err
+		}
+		return txn.Delete(attachmentKey(id))
+	})
+	if errors.Is(err, badger.ErrKeyNotFound) || (err == nil && !found) {
+		http.Error(w, `{"error":"not found"}`, http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		logHandlerError(r.Context(), "attachments", "database", "failed to delete attachment", "error", err)
+		http.Error(w, `{"error":"database error"}`, http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,195 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	badger "github.com/dgraph-io/badger/v4"
+)
+
+// uploadAttachmentRaw POSTs body as an item's attachment with the given Content-Type
+func uploadAttachmentRaw(t *testing.T, srv *httptest.Server, path string, body []byte, contentType string) (int, []byte) {
+	t.Helper()
+	req, _ := http.NewRequest(http.MethodPost, srv.URL+path, bytes.NewReader(body))
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	resp, err := srv.Client().Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	data, _ := io.ReadAll(resp.Body)
+	return resp.StatusCode, data
+}
+
+func TestAttachments_RoundTrip(t *testing.T) {
+	srv := newTestServer(t)
+	item := createTestItem(t, srv, `{"name":"with-logo"}`)
+	path := fmt.Sprintf("/api/items/%d/attachment", item.ID)
+
+	// Bytes that aren't valid UTF-8, to be sure nothing is mangled
+	payload := []byte{0x89, 'P', 'N', 'G', 0x00, 0xff, 0xfe, 0x01}
+	code, body := uploadAttachmentRaw(t, srv, path+"?filename=logo.png", payload, "image/png")
+	if code != http.StatusCreated {
+		t.Fatalf("upload = %d %s", code, body)
+	}
+	var updated Item
+	json.Unmarshal(body, &updated)
+	if a := updated.Attachment; a == nil || a.Filename != "logo.png" || a.ContentType != "image/png" || a.Size != int64(len(payload)) {
+		t.Fatalf("attachment info = %+v", updated.Attachment)
+	}
+
+	resp, err := srv.Client().Get(srv.URL + path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || !bytes.Equal(got, payload) {
+		t.Fatalf("download = %d %v, want %v", resp.StatusCode, got, payload)
+	}
+	if ct := resp.Header.Get("Content-Type"); ct != "image/png" {
+		t.Errorf("Content-Type = %q, want image/png", ct)
+	}
+	if cd := resp.Header.Get("Content-Disposition"); cd != `inline; filename=logo.png` {
+		t.Errorf("Content-Disposition = %q", cd)
+	}
+
+	// The item listing shows the metadata but not the bytes
+	_, body = doRequest(t, srv, http.MethodGet, fmt.Sprintf("/api/items/%d", item.ID), "")
+	if !strings.Contains(string(body), `"content_type":"image/png"`) {
+		t.Errorf("item = %s, want attachment metadata", body)
+	}
+
+	// Updating the item keeps its attachment
+	doRequest(t, srv, http.MethodPut, fmt.Sprintf("/api/items/%d", item.ID), `{"name":"renamed"}`)
+	if code, _ := doRequest(t, srv, http.MethodGet, path, ""); code != http.StatusOK {
+		t.Errorf("download after update = %d, want 200", code)
+	}
+}
+
+func TestAttachments_MultipartAndSniffing(t *testing.T) {
+	srv := newTestServer(t)
+	item := createTestItem(t, srv, `{"name":"with-notes"}`)
+	path := fmt.Sprintf("/api/items/%d/attachment", item.ID)
+
+	var form bytes.Buffer
+	mw := multipart.NewWriter(&form)
+	fw, _ := mw.CreateFormFile("file", "notes.txt")
+	fw.Write([]byte("hello from a form"))
+	mw.Close()
+
+	code, body := uploadAttachmentRaw(t, srv, path, form.Bytes(), mw.FormDataContentType())
+	if code != http.StatusCreated {
+		t.Fatalf("multipart upload = %d %s", code, body)
+	}
+	var updated Item
+	json.Unmarshal(body, &updated)
+	// CreateFormFile sends application/octet-stream
+	if a := updated.Attachment; a == nil || a.Filename != "notes.txt" || a.ContentType != "application/octet-stream" || a.Size != int64(len("hello from a form")) {
+		t.Fatalf("attachment info = %+v", updated.Attachment)
+	}
+	if _, got := doRequest(t, srv, http.MethodGet, path, ""); string(got) != "hello from a form" {
+		t.Errorf("download = %q", got)
+	}
+
+	// No Content-Type: guessed from the bytes
+	_, body = uploadAttachmentRaw(t, srv, path, []byte("plain words"), "")
+	if !strings.Contains(string(body), `"content_type":"text/plain; charset=utf-8"`) {
+		t.Errorf("sniffed upload = %s", body)
+	}
+}
+
+func TestAttachments_UnsafeTypesDownload(t *testing.T) {
+	srv := newTestServer(t)
+	item := createTestItem(t, srv, `{"name":"with-page"}`)
+	path := fmt.Sprintf("/api/items/%d/attachment", item.ID)
+
+	for contentType, want := range map[string]string{
+		"text/html":                 `attachment; filename=x`,
+		"image/svg+xml":             `attachment; filename=x`,
+		"text/plain; charset=utf-8": `inline; filename=x`,
+		"application/pdf":           `inline; filename=x`,
+	} {
+		uploadAttachmentRaw(t, srv, path+"?filename=x", []byte("<script>alert(1)</script>"), contentType)
+		resp, err := srv.Client().Get(srv.URL + path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if got := resp.Header.Get("Content-Disposition"); got != want {
+			t.Errorf("%s: Content-Disposition = %q, want %q", contentType, got, want)
+		}
+		if got := resp.Header.Get("X-Content-Type-Options"); got != "nosniff" {
+			t.Errorf("%s: X-Content-Type-Options = %q, want nosniff", contentType, got)
+		}
+	}
+}
+
+func TestAttachments_Errors(t *testing.T) {
+	srv := newTestServer(t)
+	item := createTestItem(t, srv, `{"name":"plain"}`)
+	path := fmt.Sprintf("/api/items/%d/attachment", item.ID)
+
+	old := attachmentMaxBytes
+	attachmentMaxBytes = 16
+	t.Cleanup(func() { attachmentMaxBytes = old })
+
+	tests := []struct {
+		name       string
+		method     string
+		path       string
+		body       string
+		wantStatus int
+	}{
+		{"no attachment yet", http.MethodGet, path, "", http.StatusNotFound},
+		{"delete missing attachment", http.MethodDelete, path, "", http.StatusNotFound},
+		{"missing item", http.MethodPost, "/api/items/999/attachment", "data", http.StatusNotFound},
+		{"empty body", http.MethodPost, path, "", http.StatusBadRequest},
+		{"too large", http.MethodPost, path, strings.Repeat("x", 17), http.StatusRequestEntityTooLarge},
+		{"unknown sub-resource", http.MethodGet, fmt.Sprintf("/api/items/%d/nothing", item.ID), "", http.StatusNotFound},
+		{"wrong method", http.MethodPatch, path, "", http.StatusMethodNotAllowed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, body := doRequest(t, srv, tt.method, tt.path, tt.body)
+			if code != tt.wantStatus {
+				t.Errorf("status = %d, want %d (body %s)", code, tt.wantStatus, body)
+			}
+		})
+	}
+}
+
+func TestAttachments_DeletedWithItem(t *testing.T) {
+	srv := newTestServer(t)
+	item := createTestItem(t, srv, `{"name":"short-lived"}`)
+	path := fmt.Sprintf("/api/items/%d/attachment", item.ID)
+
+	doRequest(t, srv, http.MethodPost, path, "first")
+	if code, _ := doRequest(t, srv, http.MethodDelete, path, ""); code != http.StatusNoContent {
+		t.Fatalf("delete attachment = %d, want 204", code)
+	}
+	if code, _ := doRequest(t, srv, http.MethodGet, path, ""); code != http.StatusNotFound {
+		t.Errorf("download after delete = %d, want 404", code)
+	}
+
+	// Deleting the item removes its attachment data too
+	doRequest(t, srv, http.MethodPost, path, "second")
+	doRequest(t, srv, http.MethodDelete, fmt.Sprintf("/api/items/%d", item.ID), "")
+	err := dbView("test", "", func(txn *badger.Txn) error {
+		_, err := txn.Get(attachmentKey(item.ID))
+		return err
+	})
+	if !errors.Is(err, badger.ErrKeyNotFound) {
+		t.Error("attachment data still stored after the item was deleted")
+	}
+}
@@ -0,0 +1,393 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// =============================================================================
+// Backup and Restore
+// =============================================================================
+//
+// Two formats, picked with ?format=:
+//
+//	badger  (default) every key in the database, in Badger's backup format —
+//	        items, jobs, schema version; a full restore replaces the items
+//	ndjson  just the items, one JSON object per line (like ?stream=true);
+//	        readable by jq or any other tool, restored by re-creating items
+//
+// and two destinations, picked with ?dest=:
+//
+//	local   (default) a file in BACKUP_DIR, same place backup jobs write
+//	s3      the bucket configured with S3_* (s3.go)
+//
+// Examples:
+//
+//	POST /api/admin/backup?dest=s3                       -> {"key": "demo-app-backup-....bak", ...}
+//	POST /api/admin/backup?dest=s3&format=ndjson
+//	GET  /api/admin/backup?dest=s3                       -> list what's in the bucket
+//	POST /api/admin/restore?source=s3&key=demo-app-backup-20250101T120000Z.bak
+//
+// Backups are plain (unencrypted) data even with DB_ENCRYPTION_KEY set —
+// protect the bucket accordingly.
+
+// Object names are "<base>-<UTC timestamp>.<ext>"
+const (
+	backupBaseName = "demo-app-backup"
+	exportBaseName = "demo-app-items"
+)
+
+// backupAdminHandler handles /api/admin/backup:
+// POST creates a backup, GET lists existing ones
+func backupAdminHandler(w http.ResponseWriter, r *http.Request) {
+	dest := r.URL.Query().Get("dest")
+	if dest == "" {
+		dest = "local"
+	}
+	if dest != "local" && dest != "s3" {
+		http.Error(w, `{"error":"dest must be local or s3"}`, http.StatusBadRequest)
+		return
+	}
+	if dest == "s3" && objectStore == nil {
+		http.Error(w, `{"error":"object storage is not configured (set S3_BUCKET)"}`, http.StatusServiceUnavailable)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		listBackups(w, r, dest)
+	case http.MethodPost:
+		createBackup(w, r, dest)
+	default:
+		http.Error(w, `{"error":"method not allowed"}`, http.StatusMethodNotAllowed)
+	}
+}
+
+// createBackup writes a backup in the requested format to dest
+func createBackup(w http.ResponseWriter, r *http.Request, dest string) {
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = "badger"
+	}
+
+	// Build the whole backup in memory first: S3 wants the payload hash
+	// before the upload starts, and demo databases are small
+	var buf bytes.Buffer
+	var name string
+	timestamp := time.Now().UTC().Format("20060102T150405Z")
+	switch format {
+	case "badger":
+		name = fmt.Sprintf("%s-%s.bak", backupBaseName, timestamp)
+	case "ndjson":
+		name = fmt.Sprintf("%s-%s.ndjson", exportBaseName, timestamp)
+	default:
+		http.Error(w, `{"error":"format must be badger or ndjson"}`, http.StatusBadRequest)
+		return
+	}
+	if err := writeBackup(&buf, format); err != nil {
+		logHandlerError(r.Context(), "admin_backup", "database", "failed to back up database", "format", format, "error", err)
+		http.Error(w, `{"error":"database error"}`, http.StatusInternalServerError)
+		return
+	}
+
+	result := map[string]any{"dest": dest, "format": format, "bytes": buf.Len()}
+	if dest == "s3" {
+		contentType := "application/octet-stream"
+		if format == "ndjson" {
+			contentType = "application/x-ndjson"
+		}
+		if err := objectStore.putObject(r.Context(), name, buf.Bytes(), contentType); err != nil {
+			logHandlerError(r.Context(), "admin_backup", "s3", "failed to upload backup", "key", name, "error", err)
+			writeJSONError(w, http.StatusBadGateway, "upload failed: "+err.Error())
+			return
+		}
+		result["bucket"] = objectStore.bucket
+		result["key"] = name
+	} else {
+		path := filepath.Join(jobBackupDir, name)
+		err := os.MkdirAll(jobBackupDir, 0o755)
+		if err == nil {
+			err = os.WriteFile(path, buf.Bytes(), 0o644)
+		}
+		if err != nil {
+			logHandlerError(r.Context(), "admin_backup", "file", "failed to write backup", "path", path, "error", err)
+			http.Error(w, `{"error":"failed to write backup file"}`, http.StatusInternalServerError)
+			return
+		}
+		result["key"] = name
+		result["path"] = path
+	}
+
+	slog.InfoContext(r.Context(), "backup created", "dest", dest, "format", format, "key", name, "bytes", buf.Len())
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(result)
+}
+
+// writeBackup writes the database to w as "badger" or "ndjson". The admin
+// endpoint and "demo-app backup" (cli.go) share it.
+func writeBackup(w io.Writer, format string) error {
+	switch format {
+	case "badger":
+		// Since version 0 = everything (see runBackupJob)
+		_, err := db.Backup(w, 0)
+		return err
+	case "ndjson":
+		items, err := loadAllItems()
+		if err != nil {
+			return err
+		}
+		encoder := json.NewEncoder(w)
+		for _, item := range items {
+			if err := encoder.Encode(item); err != nil {
+				return err
+			}
+		}
+		return nil
+	default:
+		return fmt.Errorf("format must be badger or ndjson, got %q", format)
+	}
+}
+
+// listBackups lists the backups and exports available at dest
+func listBackups(w http.ResponseWriter, r *http.Request, dest string) {
+	var objects []s3Object
+	if dest == "s3" {
+		var err error
+		objects, err = objectStore.listObjects(r.Context())
+		if err != nil {
+			logHandlerError(r.Context(), "admin_backup", "s3", "failed to list bucket", "error", err)
+			writeJSONError(w, http.StatusBadGateway, "list failed: "+err.Error())
+			return
+		}
+	} else {
+		entries, err := os.ReadDir(jobBackupDir)
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			logHandlerError(r.Context(), "admin_backup", "file", "failed to read backup dir", "error", err)
+			http.Error(w, `{"error":"failed to read backup directory"}`, http.StatusInternalServerError)
+			return
+		}
+		for _, entry := range entries {
+			info, err := entry.Info()
+			if err != nil || entry.IsDir() {
+				continue
+			}
+			objects = append(objects, s3Object{Key: entry.Name(), Size: info.Size(), LastModified: info.ModTime().UTC()})
+		}
+	}
+
+	// Only show our own files; the bucket or directory may hold other things
+	backups := []s3Object{}
+	for _, obj := range objects {
+		if strings.HasPrefix(obj.Key, backupBaseName) || strings.HasPrefix(obj.Key, exportBaseName) {
+			backups = append(backups, obj)
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]any{"dest": dest, "backups": backups})
+}
+
+// restoreAdminHandler handles POST /api/admin/restore?source=s3|local&key=<name>.
+// The format comes from the file extension: .bak is a full badger backup,
+// .ndjson is an item export.
+func restoreAdminHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, `{"error":"method not allowed"}`, http.StatusMethodNotAllowed)
+		return
+	}
+
+	source := r.URL.Query().Get("source")
+	if source == "" {
+		source = "local"
+	}
+	key := r.URL.Query().Get("key")
+	if key == "" {
+		http.Error(w, `{"error":"key is required"}`, http.StatusBadRequest)
+		return
+	}
+
+	var data []byte
+	switch source {
+	case "s3":
+		if objectStore == nil {
+			http.Error(w, `{"error":"object storage is not configured (set S3_BUCKET)"}`, http.StatusServiceUnavailable)
+			return
+		}
+		var err error
+		data, err = objectStore.getObject(r.Context(), key)
+		var s3Err *s3Error
+		if errors.As(err, &s3Err) && s3Err.Status == http.StatusNotFound {
+			http.Error(w, `{"error":"backup not found"}`, http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			logHandlerError(r.Context(), "admin_restore", "s3", "failed to download backup", "key", key, "error", err)
+			writeJSONError(w, http.StatusBadGateway, "download failed: "+err.Error())
+			return
+		}
+	case "local":
+		// Base name only: the key can't point outside BACKUP_DIR
+		var err error
+		data, err = os.ReadFile(filepath.Join(jobBackupDir, filepath.Base(key)))
+		if errors.Is(err, os.ErrNotExist) {
+			http.Error(w, `{"error":"backup not found"}`, http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			logHandlerError(r.Context(), "admin_restore", "file", "failed to read backup", "key", key, "error", err)
+			http.Error(w, `{"error":"failed to read backup file"}`, http.StatusInternalServerError)
+			return
+		}
+	default:
+		http.Error(w, `{"error":"source must be local or s3"}`, http.StatusBadRequest)
+		return
+	}
+
+	var result map[string]any
+	switch {
+	case strings.HasSuffix(key, ".bak"):
+		if err := restoreBadgerBackup(data); err != nil {
+			logHandlerError(r.Context(), "admin_restore", "database", "failed to restore backup", "key", key, "error", err)
+			writeJSONError(w, http.StatusInternalServerError, "restore failed: "+err.Error())
+			return
+		}
+		count, _ := countItems()
+		result = map[string]any{"format": "badger", "items": count}
+	case strings.HasSuffix(key, ".ndjson"):
+		created, failures := restoreNDJSON(data)
+		result = map[string]any{"format": "ndjson", "created": created, "failed": len(failures), "errors": failures}
+	default:
+		http.Error(w, `{"error":"key must end in .bak or .ndjson"}`, http.StatusBadRequest)
+		return
+	}
+
+	slog.WarnContext(r.Context(), "backup restored", "source", source, "key", key, "client_ip", r.RemoteAddr)
+	result["source"] = source
+	result["key"] = key
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
+
+// restoreBadgerBackup replaces the items with the ones in a db.Backup stream.
+//
+// Like resetStore, but the ID sequence comes from the backup: release ours
+// first (Release writes its position back to the key), load, then lease
+// again from the restored value. Migrations run afterwards because the backup
+// may come from an older version of the app.
+func restoreBadgerBackup(data []byte) error {
+	// Counters come back from the backup too; stop their merge operators
+	// first, like resetStore does
+	counters.stop()
+	hitCounter.stop()
+	// Item creates wait until the backup is loaded and the sequence
+	// re-leased (store.go)
+	err := replaceItemSequence(func() error {
+		// Tenants come from the backup too: release their sequences and
+		// forget the cached registry, which reloads from the restored keys
+		tenants.reset()
+		return timeDBOp("restore", itemKeyPrefix, func() error {
+			err := db.DropPrefix(
+				[]byte(itemKeyPrefix),
+				[]byte(nameIndexPrefix),
+				[]byte(categoryIndexPrefix),
+				[]byte(attachmentKeyPrefix),
+				[]byte(linkKeyPrefix),
+				[]byte(quarantineKeyPrefix),
+				[]byte(idempotencyKeyPrefix),
+				[]byte(counterKeyPrefix),
+				[]byte(hitCounterKeyPrefix),
+				[]byte(kvKeyPrefix),
+				[]byte(itemAggregatesKey),
+				[]byte(itemTimeseriesPrefix),
+				[]byte(displaySchemaKey),
+				[]byte(displayTemplateKey),
+				[]byte(brandingKey),
+				[]byte(i18nKeyPrefix),
+				[]byte(tenantKeyPrefix),
+				[]byte(tenantRegistryPrefix),
+				[]byte("seq:items"),
+			)
+			if err != nil {
+				return err
+			}
+			// 256 = max pending writes; Badger's own "badger restore" uses the same
+			return db.Load(bytes.NewReader(data), 256)
+		})
+	})
+	if err != nil {
+		return err
+	}
+	tenantItemsTotal.Reset() // per-tenant counts from before the restore
+
+	// Followers in cluster mode start over on their next pull (cluster.go)
+	dataGeneration.Add(1)
+	// The restored list is new to every client (itemsetag.go)
+	if err := bumpItemsVersion(); err != nil {
+		return err
+	}
+
+	if err := runMigrations(); err != nil {
+		return err
+	}
+	if uniqueItemNames {
+		if err := rebuildNameIndex(); err != nil {
+			return err
+		}
+	}
+	// A backup from before aggregates existed has none (aggregates.go)
+	if _, err := rootKeyspace.reconcileAggregates(); err != nil {
+		return err
+	}
+	return syncItemsGauge()
+}
+
+// restoreNDJSON re-creates the items from an export, keeping their creation
+// times. They get new IDs, so an export can also be merged into a database
+// that already has items. Bad lines are reported, like the bulk import job.
+func restoreNDJSON(data []byte) (created int, failures []string) {
+	failures = []string{}
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 64<<10), 10<<20) // allow long lines
+	line := 0
+	for scanner.Scan() {
+		line++
+		if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+			continue
+		}
+
+		var item Item
+		if err := json.Unmarshal(scanner.Bytes(), &item); err != nil {
+			failures = append(failures, fmt.Sprintf("line %d: %v", line, err))
+			continue
+		}
+		if item.Name == "" {
+			failures = append(failures, fmt.Sprintf("line %d: name is required", line))
+			continue
+		}
+		if item.CreatedAt.IsZero() {
+			item.CreatedAt = time.Now().UTC()
+		}
+
+		input := itemInput{Name: item.Name, Description: item.Description, Tags: item.Tags, Category: item.Category, Metadata: item.Metadata}
+		if _, _, err := insertItemAt(input, "", item.CreatedAt); err != nil {
+			failures = append(failures, fmt.Sprintf("line %d: %v", line, err))
+			continue
+		}
+		created++
+	}
+	if err := scanner.Err(); err != nil {
+		failures = append(failures, err.Error())
+	}
+	return created, failures
+}
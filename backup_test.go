@@ -0,0 +1,172 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+// withObjectStore points objectStore at a fake bucket for one test
+func withObjectStore(t *testing.T) *fakeS3 {
+	t.Helper()
+	fake, srv := newFakeS3(t, "demo")
+	c, err := newS3Client(srv.URL, "demo", "us-east-1", "key", "secret", "demo-app/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	prev := objectStore
+	objectStore = c
+	t.Cleanup(func() { objectStore = prev })
+	return fake
+}
+
+func TestBackup_S3BadgerRoundTrip(t *testing.T) {
+	srv := newTestServer(t)
+	fake := withObjectStore(t)
+
+	createTestItem(t, srv, `{"name":"keep-1"}`)
+	createTestItem(t, srv, `{"name":"keep-2"}`)
+
+	code, body := doRequest(t, srv, http.MethodPost, "/api/admin/backup?dest=s3", "")
+	if code != http.StatusCreated {
+		t.Fatalf("backup status = %d, body %s", code, body)
+	}
+	var backup struct {
+		Key   string `json:"key"`
+		Bytes int    `json:"bytes"`
+	}
+	json.Unmarshal(body, &backup)
+	if !strings.HasSuffix(backup.Key, ".bak") || backup.Bytes == 0 {
+		t.Fatalf("unexpected backup response: %s", body)
+	}
+	if _, ok := fake.objects["demo-app/"+backup.Key]; !ok {
+		t.Fatalf("backup not uploaded, bucket has %d objects", len(fake.objects))
+	}
+
+	// Change the data after the backup: the restore should undo this
+	createTestItem(t, srv, `{"name":"after-backup"}`)
+	doRequest(t, srv, http.MethodDelete, "/api/items/1", "")
+
+	code, body = doRequest(t, srv, http.MethodGet, "/api/admin/backup?dest=s3", "")
+	if code != http.StatusOK || !strings.Contains(string(body), backup.Key) {
+		t.Fatalf("list = %d %s, want it to include %s", code, body, backup.Key)
+	}
+
+	code, body = doRequest(t, srv, http.MethodPost, "/api/admin/restore?source=s3&key="+backup.Key, "")
+	if code != http.StatusOK {
+		t.Fatalf("restore status = %d, body %s", code, body)
+	}
+
+	_, body = doRequest(t, srv, http.MethodGet, "/api/items", "")
+	var items []Item
+	json.Unmarshal(body, &items)
+	if len(items) != 2 || items[0].Name != "keep-1" || items[1].Name != "keep-2" {
+		t.Fatalf("items after restore = %+v, want keep-1 and keep-2", items)
+	}
+
+	// New items must not reuse restored IDs
+	item := createTestItem(t, srv, `{"name":"new"}`)
+	if item.ID <= items[1].ID {
+		t.Errorf("new item ID %d collides with restored IDs", item.ID)
+	}
+}
+
+func TestBackup_NDJSONExportAndRestore(t *testing.T) {
+	srv := newTestServer(t)
+	fake := withObjectStore(t)
+
+	createTestItem(t, srv, `{"name":"exported","tags":["a"]}`)
+
+	code, body := doRequest(t, srv, http.MethodPost, "/api/admin/backup?dest=s3&format=ndjson", "")
+	if code != http.StatusCreated {
+		t.Fatalf("export status = %d, body %s", code, body)
+	}
+	var export struct {
+		Key string `json:"key"`
+	}
+	json.Unmarshal(body, &export)
+	if !strings.HasSuffix(export.Key, ".ndjson") {
+		t.Fatalf("export key = %q", export.Key)
+	}
+	if data := string(fake.objects["demo-app/"+export.Key]); !strings.Contains(data, `"name":"exported"`) {
+		t.Fatalf("export content = %q", data)
+	}
+
+	// Restoring an export adds the items again with new IDs
+	code, body = doRequest(t, srv, http.MethodPost, "/api/admin/restore?source=s3&key="+export.Key, "")
+	if code != http.StatusOK || !strings.Contains(string(body), `"created":1`) {
+		t.Fatalf("restore = %d %s", code, body)
+	}
+	_, body = doRequest(t, srv, http.MethodGet, "/api/items", "")
+	if n := strings.Count(string(body), `"name":"exported"`); n != 2 {
+		t.Errorf("found %d exported items, want 2: %s", n, body)
+	}
+}
+
+func TestBackup_RestoreDuringCreates(t *testing.T) {
+	newTestServer(t)
+	insertItem(itemInput{Name: "backed up"}, "")
+	var backup bytes.Buffer
+	if _, err := db.Backup(&backup, 0); err != nil {
+		t.Fatal(err)
+	}
+	checkCreatesDuring(t, func() error { return restoreBadgerBackup(backup.Bytes()) })
+}
+
+func TestBackup_Local(t *testing.T) {
+	srv := newTestServer(t)
+	prevDir := jobBackupDir
+	jobBackupDir = t.TempDir()
+	t.Cleanup(func() { jobBackupDir = prevDir })
+
+	createTestItem(t, srv, `{"name":"local"}`)
+
+	code, body := doRequest(t, srv, http.MethodPost, "/api/admin/backup", "")
+	if code != http.StatusCreated || !strings.Contains(string(body), `"dest":"local"`) {
+		t.Fatalf("backup = %d %s", code, body)
+	}
+	var backup struct {
+		Key string `json:"key"`
+	}
+	json.Unmarshal(body, &backup)
+
+	doRequest(t, srv, http.MethodDelete, "/api/items/1", "")
+
+	code, body = doRequest(t, srv, http.MethodPost, "/api/admin/restore?key="+backup.Key, "")
+	if code != http.StatusOK || !strings.Contains(string(body), `"items":1`) {
+		t.Fatalf("restore = %d %s", code, body)
+	}
+}
+
+func TestBackup_Errors(t *testing.T) {
+	srv := newTestServer(t)
+	prev := objectStore
+	objectStore = nil
+	t.Cleanup(func() { objectStore = prev })
+
+	tests := []struct {
+		name       string
+		method     string
+		path       string
+		wantStatus int
+	}{
+		{"s3 not configured", http.MethodPost, "/api/admin/backup?dest=s3", http.StatusServiceUnavailable},
+		{"unknown dest", http.MethodPost, "/api/admin/backup?dest=ftp", http.StatusBadRequest},
+		{"unknown format", http.MethodPost, "/api/admin/backup?format=csv", http.StatusBadRequest},
+		{"wrong method", http.MethodDelete, "/api/admin/backup", http.StatusMethodNotAllowed},
+		{"restore without key", http.MethodPost, "/api/admin/restore", http.StatusBadRequest},
+		{"restore missing file", http.MethodPost, "/api/admin/restore?key=nope.bak", http.StatusNotFound},
+		{"restore from s3 not configured", http.MethodPost, "/api/admin/restore?source=s3&key=a.bak", http.StatusServiceUnavailable},
+		{"restore GET", http.MethodGet, "/api/admin/restore?key=a.bak", http.StatusMethodNotAllowed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, body := doRequest(t, srv, tt.method, tt.path, "")
+			if code != tt.wantStatus {
+				t.Errorf("status = %d, want %d (body %s)", code, tt.wantStatus, body)
+			}
+		})
+	}
+}
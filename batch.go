@@ -0,0 +1,285 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+
+	badger "github.com/dgraph-io/badger/v4"
+)
+
+// =============================================================================
+// Batch Item Operations
+// =============================================================================
+//
+// POST /api/items/batch runs several creates, updates, and deletes in ONE
+// BadgerDB transaction: either all of them happen, or none do.
+//
+//	curl -X POST http://localhost:8080/api/items/batch -H 'Content-Type: application/json' -d '{
+//	  "operations": [
+//	    {"op":"create","item":{"name":"New widget","tags":["new"]}},
+//	    {"op":"update","id":3,"version":2,"item":{"name":"Renamed"}},
+//	    {"op":"delete","id":4}
+//	  ]}'
+//	{"results":[{"index":0,"op":"create","item":{"id":12,...}},
+//	            {"index":1,"op":"update","item":{"id":3,"version":3,...}},
+//	            {"index":2,"op":"delete","id":4}]}
+//
+// If any operation fails (item 4 doesn't exist, version 2 is stale, a name
+// is taken...), the transaction is discarded and the response says which
+// one broke it. Everything before it is undone too:
+//
+//	404 {"error":"not found","index":2,"op":"delete","rolled_back":true}
+//
+// Every operation is validated before the transaction starts, so a bad
+// item fails the batch with 422 without touching the database. Creates get
+// their IDs up front: a batch that rolls back leaves a gap in the IDs, as a
+// failed INSERT does in most SQL databases.
+
+// Most operations in one batch; BadgerDB also caps a transaction's size
+const maxBatchOperations = 100
+
+// batchOperation is one entry in "operations"
+type batchOperation struct {
+	Op      string    `json:"op"`      // create, update, or delete
+	ID      int64     `json:"id"`      // update and delete
+	Version *int64    `json:"version"` // update: optional expected version (itemversion.go)
+	Item    itemInput `json:"item"`    // create and update
+}
+
+// BatchResult is one entry in the response's "results"
+type BatchResult struct {
+	Index int    `json:"index"`
+	Op    string `json:"op"`
+	ID    *int64 `json:"id,omitempty"`   // delete (IDs start at 0, hence the pointer)
+	Item  *Item  `json:"item,omitempty"` // create and update
+}
+
+// batchError is the operation that failed a batch and why
+type batchError struct {
+	index int
+	op    string
+	err   error
+}
+
+func (e *batchError) Error() string {
+	return fmt.Sprintf("operation %d (%s): %v", e.index, e.op, e.err)
+}
+
+func (e *batchError) Unwrap() error { return e.err }
+
+// itemsBatchHandler handles POST /api/items/batch
+func itemsBatchHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	if r.Method != http.MethodPost {
+		http.Error(w, `{"error":"method not allowed"}`, http.StatusMethodNotAllowed)
+		return
+	}
+
+	var body struct {
+		Operations []batchOperation `json:"operations"`
+	}
+	if !decodeJSONBody(w, r, &body) { // strictjson.go
+		return
+	}
+	ops := body.Operations
+	if len(ops) == 0 {
+		http.Error(w, `{"error":"operations is required"}`, http.StatusBadRequest)
+		return
+	}
+	if len(ops) > maxBatchOperations {
+		writeJSONError(w, http.StatusBadRequest, fmt.Sprintf("at most %d operations per batch", maxBatchOperations))
+		return
+	}
+
+	// Validate everything first: nothing is written for a bad batch
+	creates, size := 0, int64(0)
+	for i := range ops {
+		op := &ops[i]
+		switch op.Op {
+		case "create", "update":
+			if op.Item.Name == "" {
+				writeBatchError(w, http.StatusUnprocessableEntity, i, op.Op, "name is required", nil)
+				return
+			}
+			input, errs := checkItemInput(op.Item) // validation.go
+			if len(errs) > 0 {
+				writeBatchError(w, http.StatusUnprocessableEntity, i, op.Op, "validation failed", map[string]any{"fields": errs})
+				return
+			}
+			op.Item = input
+			if op.Op == "create" {
+				creates++
+				value, _ := json.Marshal(input)
+				size += int64(len(value))
+			}
+		case "delete":
+		default:
+			writeBatchError(w, http.StatusBadRequest, i, op.Op, `op must be "create", "update", or "delete"`, nil)
+			return
+		}
+	}
+	if !checkStorageQuota(w, r, size) { // quota.go
+		return
+	}
+
+	ks := keyspaceFrom(r.Context())
+	if creates > 0 {
+		if err := tenants.register(ks); errors.Is(err, errTenantLimit) {
+			writeJSONError(w, http.StatusForbidden, err.Error())
+			return
+		} else if err != nil {
+			logHandlerError(r.Context(), "items", "database", "failed to register tenant", "error", err)
+			http.Error(w, `{"error":"database error"}`, http.StatusInternalServerError)
+			return
+		}
+	}
+	// Held until the batch is written, like insertItem (store.go)
+	defer lockItemCreates()()
+	ids, err := ks.nextItemIDs(creates)
+	if err != nil {
+		logHandlerError(r.Context(), "items", "database", "failed to allocate item IDs", "error", err)
+		http.Error(w, `{"error":"database error"}`, http.StatusInternalServerError)
+		return
+	}
+
+	// One transaction for all of them (the helpers are in store.go)
+	var results []BatchResult
+	now := time.Now().UTC()
+	err = dbUpdateContext(r.Context(), "item_batch", string(ks.key(itemKeyPrefix)), func(txn *badger.Txn) error {
+		results = make([]BatchResult, 0, len(ops))
+		if err := ks.checkItemQuota(txn, creates); err != nil { // quota.go
+			return err
+		}
+		for i, op := range ops {
+			result := BatchResult{Index: i, Op: op.Op}
+			switch op.Op {
+			case "create":
+				item := Item{
+					ID:          ids[0],
+					Name:        op.Item.Name,
+					Description: op.Item.Description,
+					Tags:        op.Item.Tags,
+					Category:    op.Item.Category,
+					Metadata:    op.Item.Metadata,
+					Version:     1,
+					CreatedAt:   now,
+				}
+				ids = ids[1:]
+				if err := ks.putNewItem(txn, item); err != nil {
+					return &batchError{i, op.Op, err}
+				}
+				result.Item = &item
+			case "update":
+				item, err := ks.applyItemUpdate(txn, op.ID, op.Item, op.Version)
+				if err != nil {
+					return &batchError{i, op.Op, err}
+				}
+				result.Item = &item
+			case "delete":
+				if _, err := ks.deleteItemTxn(txn, op.ID); err != nil {
+					return &batchError{i, op.Op, err}
+				}
+				result.ID = &op.ID
+			}
+			results = append(results, result)
+		}
+		return nil
+	})
+
+	var failed *batchError
+	switch {
+	case writeQuotaError(w, r, err):
+		return
+	case errors.As(err, &failed):
+		writeBatchFailure(w, r, failed)
+		return
+	case errors.Is(err, badger.ErrTxnTooBig):
+		writeJSONError(w, http.StatusRequestEntityTooLarge, "batch too large for one transaction")
+		return
+	case errors.Is(err, badger.ErrConflict):
+		http.Error(w, `{"error":"write conflict, retry","rolled_back":true}`, http.StatusConflict)
+		return
+	case err != nil:
+		logHandlerError(r.Context(), "items", "database", "failed to apply batch", "error", err)
+		http.Error(w, `{"error":"database error"}`, http.StatusInternalServerError)
+		return
+	}
+
+	// Committed: now the side effects, once per operation
+	for _, result := range results {
+		switch result.Op {
+		case "create":
+			ks.itemsGauge().Inc()
+			publishItemEvent(eventItemCreated, result.Item.ID, *result.Item) // events.go
+			mirrorItem(ks, *result.Item)                                     // shadow.go
+		case "update":
+			publishItemEvent(eventItemUpdated, result.Item.ID, *result.Item)
+			mirrorItem(ks, *result.Item)
+		case "delete":
+			ks.itemsGauge().Dec()
+			publishItemEvent(eventItemDeleted, *result.ID, map[string]int64{"id": *result.ID})
+			mirrorItemDelete(ks, *result.ID)
+		}
+	}
+	slog.InfoContext(r.Context(), "item batch applied", "operations", len(results))
+	json.NewEncoder(w).Encode(map[string]any{"results": results})
+}
+
+// nextItemIDs leases n item IDs from the tenant's sequence
+func (k keyspace) nextItemIDs(n int) ([]int64, error) {
+	if n == 0 {
+		return nil, nil
+	}
+	seq, err := k.sequence()
+	if err != nil {
+		return nil, fmt.Errorf("item sequence: %w", err)
+	}
+	ids := make([]int64, n)
+	for i := range ids {
+		id, err := seq.Next()
+		if err != nil {
+			return nil, fmt.Errorf("next item ID: %w", err)
+		}
+		ids[i] = int64(id)
+	}
+	return ids, nil
+}
+
+// writeBatchFailure explains which operation rolled the batch back
+func writeBatchFailure(w http.ResponseWriter, r *http.Request, failed *batchError) {
+	var conflict *versionConflictError
+	var nameTaken *nameConflictError
+	switch {
+	case errors.Is(failed.err, badger.ErrKeyNotFound):
+		writeBatchError(w, http.StatusNotFound, failed.index, failed.op, "not found", nil)
+	case errors.As(failed.err, &conflict):
+		writeBatchError(w, http.StatusConflict, failed.index, failed.op, "version conflict", map[string]any{
+			"expected_version": conflict.Expected,
+			"current":          conflict.Current,
+		})
+	case errors.As(failed.err, &nameTaken):
+		writeBatchError(w, http.StatusConflict, failed.index, failed.op, "name already exists", map[string]any{
+			"existing_id": nameTaken.ExistingID,
+		})
+	case errors.Is(failed.err, badger.ErrConflict):
+		writeBatchError(w, http.StatusConflict, failed.index, failed.op, "write conflict, retry", nil)
+	default:
+		logHandlerError(r.Context(), "items", "database", "failed to apply batch", "error", failed)
+		writeBatchError(w, http.StatusInternalServerError, failed.index, failed.op, "database error", nil)
+	}
+}
+
+// writeBatchError writes an error naming the operation, plus any extra fields
+func writeBatchError(w http.ResponseWriter, status, index int, op, msg string, extra map[string]any) {
+	body := map[string]any{"error": msg, "index": index, "op": op, "rolled_back": true}
+	for k, v := range extra {
+		body[k] = v
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"testing"
+)
+
+func TestItemsBatch_AppliesAll(t *testing.T) {
+	srv := newTestServer(t)
+	keep := createTestItem(t, srv, `{"name":"keep"}`)
+	gone := createTestItem(t, srv, `{"name":"gone"}`)
+
+	code, body := doRequest(t, srv, http.MethodPost, "/api/items/batch", fmt.Sprintf(`{"operations":[
+		{"op":"create","item":{"name":"new one","tags":["new"]}},
+		{"op":"update","id":%d,"version":1,"item":{"name":"kept and renamed"}},
+		{"op":"delete","id":%d}
+	]}`, keep.ID, gone.ID))
+	if code != http.StatusOK {
+		t.Fatalf("batch = %d %s", code, body)
+	}
+	var resp struct {
+		Results []BatchResult `json:"results"`
+	}
+	if err := json.Unmarshal(body, &resp); err != nil || len(resp.Results) != 3 {
+		t.Fatalf("results = %s", body)
+	}
+	if resp.Results[0].Item == nil || resp.Results[0].Item.Name != "new one" || resp.Results[1].Item.Version != 2 {
+		t.Errorf("results = %s", body)
+	}
+
+	if code, _ := doRequest(t, srv, http.MethodGet, "/api/items/"+strconv.FormatInt(gone.ID, 10), ""); code != http.StatusNotFound {
+		t.Errorf("deleted item = %d, want 404", code)
+	}
+	_, body = doRequest(t, srv, http.MethodGet, "/api/items/count", "")
+	if string(body) != "{\"count\":2}\n" {
+		t.Errorf("count = %s, want 2", body)
+	}
+}
+
+func TestItemsBatch_AllOrNothing(t *testing.T) {
+	srv := newTestServer(t)
+	item := createTestItem(t, srv, `{"name":"original"}`)
+	id := strconv.FormatInt(item.ID, 10)
+
+	tests := []struct {
+		name     string
+		ops      string
+		wantCode int
+		wantAt   int
+	}{
+		{"missing item", `{"op":"create","item":{"name":"x"}},{"op":"update","id":` + id + `,"item":{"name":"changed"}},{"op":"delete","id":999}`, http.StatusNotFound, 2},
+		{"stale version", `{"op":"update","id":` + id + `,"version":7,"item":{"name":"changed"}}`, http.StatusConflict, 0},
+		{"invalid item", `{"op":"create","item":{"name":"x"}},{"op":"create","item":{}}`, http.StatusUnprocessableEntity, 1},
+		{"unknown op", `{"op":"upsert","id":1}`, http.StatusBadRequest, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, body := doRequest(t, srv, http.MethodPost, "/api/items/batch", `{"operations":[`+tt.ops+`]}`)
+			if code != tt.wantCode {
+				t.Fatalf("batch = %d %s, want %d", code, body, tt.wantCode)
+			}
+			var failed struct {
+				Index      int  `json:"index"`
+				RolledBack bool `json:"rolled_back"`
+			}
+			json.Unmarshal(body, &failed)
+			if failed.Index != tt.wantAt || !failed.RolledBack {
+				t.Errorf("failure = %s, want index %d", body, tt.wantAt)
+			}
+		})
+	}
+
+	// Nothing from the failed batches stuck
+	_, body := doRequest(t, srv, http.MethodGet, "/api/items/"+id, "")
+	var got Item
+	json.Unmarshal(body, &got)
+	if got.Name != "original" || got.Version != 1 {
+		t.Errorf("item after failed batches = %+v", got)
+	}
+	_, body = doRequest(t, srv, http.MethodGet, "/api/items/count", "")
+	if string(body) != "{\"count\":1}\n" {
+		t.Errorf("count = %s, want 1", body)
+	}
+	totals, _, _ := rootKeyspace.aggregates()
+	if totals.Items != 1 {
+		t.Errorf("aggregate item count = %d, want 1", totals.Items)
+	}
+}
@@ -0,0 +1,199 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// =============================================================================
+// Bench Subcommand
+// =============================================================================
+//
+// The Go benchmarks (bench_test.go, "go test -bench .") measure the store and
+// handlers in isolation. "demo-app bench" measures a *running* server over
+// HTTP — the number a demo audience cares about:
+//
+//	./demo-app bench                                  # against localhost:$PORT
+//	./demo-app bench -url http://demo.example.com -n 5000 -c 50
+//
+// It runs three scenarios one after another — create, get, list — and prints
+// throughput and latency percentiles for each. Items it creates are deleted
+// at the end (pass -keep to leave them, e.g. to fill the dashboard).
+
+// benchResult summarizes one scenario
+type benchResult struct {
+	Name      string
+	Requests  int
+	Errors    int64
+	Duration  time.Duration
+	Latencies []time.Duration
+}
+
+// runBench is the entry point for "demo-app bench"; returns the exit code
+func runBench(args []string) int {
+	// A FlagSet is a parser for one subcommand's flags — like an argparse
+	// subparser in Python
+	flags := flag.NewFlagSet("bench", flag.ContinueOnError)
+	baseURL := flags.String("url", "http://localhost:"+envString("PORT", "8080"), "server to benchmark")
+	requests := flags.Int("n", 1000, "requests per scenario")
+	concurrency := flags.Int("c", 10, "concurrent clients")
+	keep := flags.Bool("keep", false, "keep the items created by the benchmark")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if *requests < 1 || *concurrency < 1 {
+		fmt.Fprintln(os.Stderr, "bench: -n and -c must be at least 1")
+		return 2
+	}
+
+	client := &http.Client{Timeout: 30 * time.Second}
+	resp, err := client.Get(*baseURL + "/health")
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "bench: server not reachable at %s: %v\n", *baseURL, err)
+		return 1
+	}
+	resp.Body.Close()
+
+	fmt.Printf("Benchmarking %s: %d requests per scenario, %d concurrent clients\n\n", *baseURL, *requests, *concurrency)
+
+	// create: POST new items, remembering their IDs for the other scenarios
+	var (
+		idsMu sync.Mutex
+		ids   []int64
+	)
+	create := benchScenario("create", *requests, *concurrency, func(i int) error {
+		body := fmt.Sprintf(`{"name":"bench-%d","description":"created by demo-app bench"}`, i)
+		resp, err := client.Post(*baseURL+"/api/items", "application/json", bytes.NewBufferString(body))
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusCreated {
+			return fmt.Errorf("status %d", resp.StatusCode)
+		}
+		var item Item
+		if err := json.NewDecoder(resp.Body).Decode(&item); err != nil {
+			return err
+		}
+		idsMu.Lock()
+		ids = append(ids, item.ID)
+		idsMu.Unlock()
+		return nil
+	})
+	printBenchResult(create)
+
+	if len(ids) == 0 {
+		fmt.Fprintln(os.Stderr, "bench: no items were created, skipping get and list")
+		return 1
+	}
+
+	// get: fetch the created items round-robin
+	get := benchScenario("get", *requests, *concurrency, func(i int) error {
+		return benchGet(client, fmt.Sprintf("%s/api/items/%d", *baseURL, ids[i%len(ids)]))
+	})
+	printBenchResult(get)
+
+	// list: the whole collection, which grows with every create
+	list := benchScenario("list", *requests, *concurrency, func(i int) error {
+		return benchGet(client, *baseURL+"/api/items")
+	})
+	printBenchResult(list)
+
+	if !*keep {
+		for _, id := range ids {
+			req, _ := http.NewRequest(http.MethodDelete, fmt.Sprintf("%s/api/items/%d", *baseURL, id), nil)
+			if resp, err := client.Do(req); err == nil {
+				resp.Body.Close()
+			}
+		}
+		fmt.Printf("Deleted %d benchmark items\n", len(ids))
+	}
+
+	if create.Errors+get.Errors+list.Errors > 0 {
+		return 1
+	}
+	return 0
+}
+
+// benchGet does a GET and expects 200
+func benchGet(client *http.Client, url string) error {
+	resp, err := client.Get(url)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	// Read the body so the connection can be reused (keep-alive)
+	io.Copy(io.Discard, resp.Body)
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// benchScenario runs fn n times across c workers and times every call
+func benchScenario(name string, n, c int, fn func(i int) error) benchResult {
+	result := benchResult{Name: name, Requests: n, Latencies: make([]time.Duration, n)}
+
+	// Workers pull request numbers from a shared counter until n are done
+	var next int64 = -1
+	var wg sync.WaitGroup
+	start := time.Now()
+	for w := 0; w < c; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				i := int(atomic.AddInt64(&next, 1))
+				if i >= n {
+					return
+				}
+				began := time.Now()
+				if err := fn(i); err != nil {
+					atomic.AddInt64(&result.Errors, 1)
+				}
+				// Each worker writes different indexes, so no lock needed
+				result.Latencies[i] = time.Since(began)
+			}
+		}()
+	}
+	wg.Wait()
+	result.Duration = time.Since(start)
+
+	return result
+}
+
+// printBenchResult prints one line per scenario, e.g.
+//
+//	create   1000 req in 1.2s  833.3 req/s  p50 10.1ms  p99 25.3ms  errors 0
+func printBenchResult(r benchResult) {
+	sorted := append([]time.Duration(nil), r.Latencies...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+
+	fmt.Printf("%-6s %6d req in %-8s %8.1f req/s  p50 %-8s  p99 %-8s  errors %d\n",
+		r.Name,
+		r.Requests,
+		r.Duration.Round(time.Millisecond),
+		float64(r.Requests)/r.Duration.Seconds(),
+		benchPercentile(sorted, 0.50).Round(10*time.Microsecond),
+		benchPercentile(sorted, 0.99).Round(10*time.Microsecond),
+		r.Errors,
+	)
+}
+
+// benchPercentile returns the p-th percentile of already sorted latencies
+func benchPercentile(sorted []time.Duration, p float64) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+	i := int(float64(len(sorted)-1) * p)
+	return sorted[i]
+}
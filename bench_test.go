@@ -0,0 +1,105 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// =============================================================================
+// Benchmarks
+// =============================================================================
+//
+// Run with:
+//
+//	go test -run '^$' -bench . -benchmem
+//
+// -run '^$' skips the regular tests; -benchmem adds allocations per op.
+// Compare before/after a change with benchstat
+// (golang.org/x/perf/cmd/benchstat). For a live server, see "demo-app bench".
+
+func BenchmarkCreateItem(b *testing.B) {
+	newTestServer(b)
+
+	// Only the loop is timed; setup above isn't
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, _, err := insertItem(itemInput{Name: fmt.Sprintf("bench-%d", i)}, ""); err != nil {
+			b.Fatalf("insert failed: %v", err)
+		}
+	}
+}
+
+func BenchmarkListItems1k(b *testing.B)   { benchmarkListItems(b, 1_000, "/api/items") }
+func BenchmarkListItems10k(b *testing.B)  { benchmarkListItems(b, 10_000, "/api/items") }
+func BenchmarkListItems100k(b *testing.B) { benchmarkListItems(b, 100_000, "/api/items") }
+
+// Compare B/op with BenchmarkListItems100k: streaming never holds the whole list
+func BenchmarkStreamItems100k(b *testing.B) {
+	benchmarkListItems(b, 100_000, "/api/items?stream=true")
+}
+
+// benchmarkListItems times GET path (load + JSON encode) with n items
+func benchmarkListItems(b *testing.B, n int, path string) {
+	newTestServer(b)
+
+	// Large listings are slow by design here; don't log each one as slow
+	prevThreshold := dbSlowThreshold
+	dbSlowThreshold = 0
+	b.Cleanup(func() { dbSlowThreshold = prevThreshold })
+
+	for i := 0; i < n; i++ {
+		if _, _, err := insertItem(itemInput{Name: fmt.Sprintf("item-%d", i), Description: "benchmark item"}, ""); err != nil {
+			b.Fatalf("insert failed: %v", err)
+		}
+	}
+
+	req := httptest.NewRequest("GET", path, nil)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		w := &discardResponseWriter{header: http.Header{}}
+		itemsHandler(w, req)
+		if w.status != 0 && w.status != http.StatusOK {
+			b.Fatalf("expected status 200, got %d", w.status)
+		}
+	}
+}
+
+// discardResponseWriter throws the body away. httptest.ResponseRecorder
+// keeps the whole body in memory, which would hide what streaming saves.
+type discardResponseWriter struct {
+	header http.Header
+	status int
+}
+
+func (w *discardResponseWriter) Header() http.Header         { return w.header }
+func (w *discardResponseWriter) Write(p []byte) (int, error) { return len(p), nil }
+func (w *discardResponseWriter) WriteHeader(status int)      { w.status = status }
+
+func BenchmarkWebhookHandler(b *testing.B) {
+	// A webhook endpoint that accepts and discards everything
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.Copy(io.Discard, r.Body)
+	}))
+	defer receiver.Close()
+
+	handler := newWebhookHandler(slog.NewJSONHandler(io.Discard, nil), receiver.URL, "")
+	record := slog.NewRecord(time.Now(), slog.LevelInfo, "request", 0)
+	record.AddAttrs(slog.String("method", "GET"), slog.String("path", "/api/items"), slog.Int("status", 200))
+	ctx := context.Background()
+
+	// The same work Handle does, but with the POST run inline instead of in
+	// a goroutine, so every POST is timed and none outlive the benchmark
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := handler.underlying.Handle(ctx, record); err != nil {
+			b.Fatalf("handle failed: %v", err)
+		}
+		handler.postToWebhook(buildLogEntry(record))
+	}
+}
@@ -0,0 +1,226 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	badger "github.com/dgraph-io/badger/v4"
+)
+
+// =============================================================================
+// Branding
+// =============================================================================
+//
+// Re-skin the dashboard and the status page for a customer demo without
+// rebuilding the embedded assets: a title, a logo, a primary color, and a
+// footer line.
+//
+//	curl -X PUT http://localhost:8080/api/admin/branding -d '{
+//	  "title": "Acme Orders",
+//	  "logo_url": "https://acme.example/logo.svg",
+//	  "primary_color": "#0077cc",
+//	  "footer_text": "Acme Corp internal demo"
+//	}'
+//	curl http://localhost:8080/api/branding                  # what the dashboard uses
+//	curl -X DELETE http://localhost:8080/api/admin/branding  # back to the defaults
+//
+// The dashboard polls GET /api/branding, so open browsers pick up a change
+// within ten seconds. Every field is optional; an empty one keeps its
+// default ("Demo App", no logo, the VARIANT accent color, no footer).
+//
+// Like the display template, branding is stored in BadgerDB, replicates to
+// followers in cluster mode, and comes back from a backup.
+
+// Where the branding is stored
+const brandingKey = "branding:dashboard"
+
+// Default dashboard title
+const defaultBrandingTitle = "Demo App"
+
+// Longest title and footer accepted, in characters
+const (
+	maxBrandingTitle  = 80
+	maxBrandingFooter = 200
+)
+
+// "#rgb" or "#rrggbb", so the color is safe to drop into CSS
+var brandingColorPattern = regexp.MustCompile(`^#([0-9a-fA-F]{3}|[0-9a-fA-F]{6})$`)
+
+// Branding is how the dashboard and status page are dressed
+type Branding struct {
+	Title        string `json:"title"`
+	LogoURL      string `json:"logo_url"`
+	PrimaryColor string `json:"primary_color"`
+	FooterText   string `json:"footer_text"`
+}
+
+// validate checks every field, returning one FieldError per problem
+func (b Branding) validate() []FieldError {
+	var errs []FieldError
+	if utf8.RuneCountInString(b.Title) > maxBrandingTitle {
+		errs = append(errs, FieldError{Field: "title", Message: "must be at most 80 characters"})
+	}
+	if b.LogoURL != "" && !validLogoURL(b.LogoURL) {
+		errs = append(errs, FieldError{Field: "logo_url", Message: "must be an http(s) URL or a path starting with /"})
+	}
+	if b.PrimaryColor != "" && !brandingColorPattern.MatchString(b.PrimaryColor) {
+		errs = append(errs, FieldError{Field: "primary_color", Message: "must be a hex color like #0077cc"})
+	}
+	if utf8.RuneCountInString(b.FooterText) > maxBrandingFooter {
+		errs = append(errs, FieldError{Field: "footer_text", Message: "must be at most 200 characters"})
+	}
+	return errs
+}
+
+// validLogoURL accepts absolute http(s) URLs and paths on this server
+// (say, an uploaded attachment). javascript: and data: URLs are refused.
+func validLogoURL(raw string) bool {
+	if strings.HasPrefix(raw, "/") && !strings.HasPrefix(raw, "//") {
+		return true
+	}
+	u, err := url.Parse(raw)
+	return err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != ""
+}
+
+// withDefaults fills in the fields left empty
+func (b Branding) withDefaults() Branding {
+	if b.Title == "" {
+		b.Title = defaultBrandingTitle
+	}
+	if b.PrimaryColor == "" {
+		b.PrimaryColor = variantColor
+	}
+	return b
+}
+
+// readBranding returns the stored branding
+// (badger.ErrKeyNotFound if none was set)
+func readBranding() (Branding, error) {
+	var b Branding
+	err := dbView("branding_get", brandingKey, func(txn *badger.Txn) error {
+		item, err := txn.Get([]byte(brandingKey))
+		if err != nil {
+			return err
+		}
+		return item.Value(func(val []byte) error { return json.Unmarshal(val, &b) })
+	})
+	return b, err
+}
+
+// currentBranding is the branding to render, with defaults filled in.
+// A database error falls back to the defaults: a page that looks generic
+// beats a page that doesn't load.
+func currentBranding() Branding {
+	b, err := readBranding()
+	if err != nil && !errors.Is(err, badger.ErrKeyNotFound) {
+		slog.Warn("failed to read branding, using defaults", "error", err)
+		b = Branding{}
+	}
+	return b.withDefaults()
+}
+
+// brandingHandler handles GET /api/branding
+func brandingHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	if r.Method != http.MethodGet {
+		http.Error(w, `{"error":"method not allowed"}`, http.StatusMethodNotAllowed)
+		return
+	}
+	// Polled by the dashboard: always ask, so a change shows up at once
+	w.Header().Set("Cache-Control", "no-cache")
+	json.NewEncoder(w).Encode(currentBranding())
+}
+
+// brandingAdminHandler handles /api/admin/branding (GET, PUT, DELETE)
+func brandingAdminHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	switch r.Method {
+	case http.MethodGet:
+		getBranding(w, r)
+	case http.MethodPut:
+		putBranding(w, r)
+	case http.MethodDelete:
+		deleteBranding(w, r)
+	default:
+		http.Error(w, `{"error":"method not allowed"}`, http.StatusMethodNotAllowed)
+	}
+}
+
+// getBranding returns the stored branding as set, without defaults
+func getBranding(w http.ResponseWriter, r *http.Request) {
+	b, err := readBranding()
+	if errors.Is(err, badger.ErrKeyNotFound) {
+		http.Error(w, `{"error":"no branding configured"}`, http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		logHandlerError(r.Context(), "branding", "database", "failed to read branding", "error", err)
+		http.Error(w, `{"error":"database error"}`, http.StatusInternalServerError)
+		return
+	}
+	json.NewEncoder(w).Encode(b)
+}
+
+// putBranding replaces the branding; fields left out go back to their
+// defaults
+func putBranding(w http.ResponseWriter, r *http.Request) {
+	var b Branding
+	decoder := json.NewDecoder(http.MaxBytesReader(w, r.Body, 4<<10))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&b); err != nil {
+		writeJSONError(w, http.StatusBadRequest, "invalid JSON: "+err.Error())
+		return
+	}
+	b.Title = strings.TrimSpace(b.Title)
+	b.LogoURL = strings.TrimSpace(b.LogoURL)
+	b.PrimaryColor = strings.TrimSpace(b.PrimaryColor)
+	b.FooterText = strings.TrimSpace(b.FooterText)
+	if errs := b.validate(); len(errs) > 0 {
+		writeValidationErrors(w, errs)
+		return
+	}
+
+	value, err := json.Marshal(b)
+	if err != nil {
+		logHandlerError(r.Context(), "branding", "encode", "failed to encode branding", "error", err)
+		http.Error(w, `{"error":"internal error"}`, http.StatusInternalServerError)
+		return
+	}
+	err = dbUpdate("branding_put", brandingKey, func(txn *badger.Txn) error {
+		return txn.Set([]byte(brandingKey), value)
+	})
+	if err != nil {
+		logHandlerError(r.Context(), "branding", "database", "failed to store branding", "error", err)
+		http.Error(w, `{"error":"database error"}`, http.StatusInternalServerError)
+		return
+	}
+
+	slog.InfoContext(r.Context(), "branding updated", "title", b.Title)
+	json.NewEncoder(w).Encode(b.withDefaults())
+}
+
+// deleteBranding removes the branding; the defaults are used again
+func deleteBranding(w http.ResponseWriter, r *http.Request) {
+	err := dbUpdate("branding_delete", brandingKey, func(txn *badger.Txn) error {
+		if _, err := txn.Get([]byte(brandingKey)); err != nil {
+			return err
+		}
+		return txn.Delete([]byte(brandingKey))
+	})
+	if errors.Is(err, badger.ErrKeyNotFound) {
+		http.Error(w, `{"error":"no branding configured"}`, http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		logHandlerError(r.Context(), "branding", "database", "failed to delete branding", "error", err)
+		http.Error(w, `{"error":"database error"}`, http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
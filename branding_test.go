@@ -0,0 +1,100 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestBranding_Defaults(t *testing.T) {
+	srv := newTestServer(t)
+
+	code, body := doRequest(t, srv, http.MethodGet, "/api/branding", "")
+	var got Branding
+	json.Unmarshal(body, &got)
+	if code != http.StatusOK || got.Title != defaultBrandingTitle || got.PrimaryColor != variantColor {
+		t.Errorf("GET /api/branding = %d %s", code, body)
+	}
+	if code, _ := doRequest(t, srv, http.MethodGet, "/api/admin/branding", ""); code != http.StatusNotFound {
+		t.Errorf("admin GET with nothing set = %d, want 404", code)
+	}
+	if code, _ := doRequest(t, srv, http.MethodDelete, "/api/admin/branding", ""); code != http.StatusNotFound {
+		t.Errorf("DELETE with nothing set = %d, want 404", code)
+	}
+}
+
+func TestBranding_PutAndRender(t *testing.T) {
+	srv := newTestServer(t)
+
+	code, body := doRequest(t, srv, http.MethodPut, "/api/admin/branding",
+		`{"title":"Acme <Orders>","logo_url":"/api/items/1/attachments/logo","primary_color":"#0077cc","footer_text":"Acme internal"}`)
+	if code != http.StatusOK {
+		t.Fatalf("PUT = %d %s", code, body)
+	}
+
+	_, body = doRequest(t, srv, http.MethodGet, "/api/branding", "")
+	var got Branding
+	json.Unmarshal(body, &got)
+	if got.Title != "Acme <Orders>" || got.PrimaryColor != "#0077cc" || got.FooterText != "Acme internal" {
+		t.Errorf("GET /api/branding = %s", body)
+	}
+
+	_, body = doRequest(t, srv, http.MethodGet, "/status", "")
+	page := string(body)
+	for _, want := range []string{
+		"<title>Acme &lt;Orders&gt; Status:",
+		`<img src="/api/items/1/attachments/logo" alt="">`,
+		"solid #0077cc",
+		"Acme internal &middot; Generated",
+	} {
+		if !strings.Contains(page, want) {
+			t.Errorf("status page missing %q", want)
+		}
+	}
+
+	// Fields left out of a PUT go back to their defaults
+	doRequest(t, srv, http.MethodPut, "/api/admin/branding", `{"footer_text":"only a footer"}`)
+	_, body = doRequest(t, srv, http.MethodGet, "/api/branding", "")
+	got = Branding{}
+	json.Unmarshal(body, &got)
+	if got.Title != defaultBrandingTitle || got.LogoURL != "" || got.FooterText != "only a footer" {
+		t.Errorf("after partial PUT = %s", body)
+	}
+
+	if code, _ := doRequest(t, srv, http.MethodDelete, "/api/admin/branding", ""); code != http.StatusNoContent {
+		t.Errorf("DELETE = %d, want 204", code)
+	}
+	_, body = doRequest(t, srv, http.MethodGet, "/status", "")
+	if !strings.Contains(string(body), "<title>Demo App Status:") {
+		t.Error("status page still branded after DELETE")
+	}
+}
+
+func TestBranding_Validation(t *testing.T) {
+	srv := newTestServer(t)
+
+	tests := map[string]struct {
+		body  string
+		code  int
+		field string
+	}{
+		"javascript logo":   {`{"logo_url":"javascript:alert(1)"}`, http.StatusUnprocessableEntity, "logo_url"},
+		"protocol-relative": {`{"logo_url":"//evil.example/x.png"}`, http.StatusUnprocessableEntity, "logo_url"},
+		"css injection":     {`{"primary_color":"red; background: url(x)"}`, http.StatusUnprocessableEntity, "primary_color"},
+		"long title":        {`{"title":"` + strings.Repeat("x", 81) + `"}`, http.StatusUnprocessableEntity, "title"},
+		"unknown field":     {`{"colour":"#fff"}`, http.StatusBadRequest, ""},
+		"short color":       {`{"primary_color":"#fff"}`, http.StatusOK, ""},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			code, body := doRequest(t, srv, http.MethodPut, "/api/admin/branding", tt.body)
+			if code != tt.code {
+				t.Fatalf("PUT %s = %d %s, want %d", tt.body, code, body, tt.code)
+			}
+			if tt.field != "" && !strings.Contains(string(body), `"field":"`+tt.field+`"`) {
+				t.Errorf("error doesn't name %s: %s", tt.field, body)
+			}
+		})
+	}
+}
@@ -0,0 +1,242 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+
+	badger "github.com/dgraph-io/badger/v4"
+)
+
+// =============================================================================
+// Item Categories
+// =============================================================================
+//
+// Items can have a category, a path like "hardware/laptops". Slashes make a
+// hierarchy, so listing "hardware" includes everything under
+// "hardware/laptops" and "hardware/monitors":
+//
+//	GET /api/categories                      -> every category with item counts
+//	GET /api/categories/hardware/items       -> items in hardware and below
+//	GET /api/categories/hardware/items?recursive=false   -> only hardware itself
+//
+// Finding items by category without reading every item needs a secondary
+// index — the same idea as CREATE INDEX in SQL. For each categorized item we
+// store an extra, empty key that sorts next to its siblings:
+//
+//	cat:hardware/#00000000000000000007
+//	cat:hardware/laptops/#00000000000000000003
+//	cat:hardware/laptops/#00000000000000000009
+//
+// A prefix scan over "cat:hardware/" finds the whole subtree, and
+// "cat:hardware/#" just the direct members. '#' can't appear in a category
+// name, so the two never mix up. The index key is written and deleted in the
+// same transaction as the item, so they can't drift apart.
+
+// Prefix for category index keys
+const categoryIndexPrefix = "cat:"
+
+// Limits that keep category keys reasonable
+const (
+	maxCategoryLength = 200
+	maxCategoryDepth  = 10
+)
+
+// normalizeCategory cleans up a category path: lowercase, no surrounding
+// spaces or slashes, e.g. " Hardware/Laptops/ " -> "hardware/laptops".
+// Segments may contain letters, digits, spaces, '-', '_', and '.'.
+func normalizeCategory(category string) (string, error) {
+	category = strings.Trim(strings.ToLower(strings.TrimSpace(category)), "/")
+	if category == "" {
+		return "", nil
+	}
+	if len(category) > maxCategoryLength {
+		return "", fmt.Errorf("must be at most %d characters", maxCategoryLength)
+	}
+
+	segments := strings.Split(category, "/")
+	if len(segments) > maxCategoryDepth {
+		return "", fmt.Errorf("must be at most %d levels deep", maxCategoryDepth)
+	}
+	for i, segment := range segments {
+		segment = strings.TrimSpace(segment)
+		if segment == "" {
+			return "", fmt.Errorf("must not contain empty segments")
+		}
+		for _, c := range segment {
+			if !unicode.IsLetter(c) && !unicode.IsDigit(c) && !strings.ContainsRune(" -_.", c) {
+				return "", fmt.Errorf("must only contain letters, digits, spaces, '-', '_', '.' and '/'")
+			}
+		}
+		segments[i] = segment
+	}
+	return strings.Join(segments, "/"), nil
+}
+
+// categoryIndexKey is the index entry for one item in one category
+func categoryIndexKey(category string, id int64) []byte {
+	return []byte(fmt.Sprintf("%s%s/#%0*d", categoryIndexPrefix, category, itemKeyDigits, id))
+}
+
+// parseCategoryIndexKey splits an index key back into category and item ID
+func parseCategoryIndexKey(key []byte) (string, int64, bool) {
+	rest := strings.TrimPrefix(string(key), categoryIndexPrefix)
+	i := strings.LastIndex(rest, "/#")
+	if i < 0 {
+		return "", 0, false
+	}
+	id, err := strconv.ParseInt(rest[i+2:], 10, 64)
+	if err != nil {
+		return "", 0, false
+	}
+	return rest[:i], id, true
+}
+
+// CategoryInfo is one entry in GET /api/categories
+type CategoryInfo struct {
+	Name       string `json:"name"`        // full path, e.g. "hardware/laptops"
+	Parent     string `json:"parent"`      // "" for top-level categories
+	Items      int    `json:"items"`       // items directly in this category
+	TotalItems int    `json:"total_items"` // including all subcategories
+}
+
+// listCategories counts items per category from the index (keys only, no
+// item values are read). Parents without direct items are included, so
+// the result is a complete tree.
+func listCategories() ([]CategoryInfo, error) {
+	byName := map[string]*CategoryInfo{}
+	err := dbView("category_list", categoryIndexPrefix, func(txn *badger.Txn) error {
+		opts := badger.DefaultIteratorOptions
+		opts.PrefetchValues = false
+		it := txn.NewIterator(opts)
+		defer it.Close()
+
+		prefix := []byte(categoryIndexPrefix)
+		for it.Seek(prefix); it.ValidForPrefix(prefix); it.Next() {
+			category, _, ok := parseCategoryIndexKey(it.Item().Key())
+			if !ok {
+				continue
+			}
+
+			// Count the item for this category and every ancestor
+			segments := strings.Split(category, "/")
+			for depth := len(segments); depth > 0; depth-- {
+				name := strings.Join(segments[:depth], "/")
+				info := byName[name]
+				if info == nil {
+					info = &CategoryInfo{Name: name, Parent: strings.Join(segments[:depth-1], "/")}
+					byName[name] = info
+				}
+				info.TotalItems++
+				if depth == len(segments) {
+					info.Items++
+				}
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Sorted by path, so children follow their parent
+	categories := make([]CategoryInfo, 0, len(byName))
+	for _, info := range byName {
+		categories = append(categories, *info)
+	}
+	sort.Slice(categories, func(i, j int) bool { return categories[i].Name < categories[j].Name })
+	return categories, nil
+}
+
+// itemsInCategory returns the items in category (and its subcategories when
+// recursive), in ID order. found is false if the category has no items.
+func itemsInCategory(category string, recursive bool) (items []Item, found bool, err error) {
+	prefix := categoryIndexPrefix + category + "/"
+	if !recursive {
+		prefix += "#"
+	}
+
+	items = []Item{}
+	err = dbView("category_items", prefix, func(txn *badger.Txn) error {
+		opts := badger.DefaultIteratorOptions
+		opts.PrefetchValues = false
+		it := txn.NewIterator(opts)
+		defer it.Close()
+
+		// Collect IDs from the index, then look up each item —
+		// what a SQL database does for an index scan
+		var ids []int64
+		for it.Seek([]byte(prefix)); it.ValidForPrefix([]byte(prefix)); it.Next() {
+			if _, id, ok := parseCategoryIndexKey(it.Item().Key()); ok {
+				ids = append(ids, id)
+			}
+		}
+		sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+
+		for _, id := range ids {
+			entry, err := txn.Get(itemKey(id))
+			if err != nil {
+				return err
+			}
+			var item Item
+			if err := entry.Value(func(val []byte) error { return decodeItem(val, &item) }); err != nil {
+				return err
+			}
+			items = append(items, item)
+		}
+		found = len(ids) > 0
+		return nil
+	})
+	return items, found, err
+}
+
+// categoriesHandler handles GET /api/categories and
+// GET /api/categories/<path>/items
+func categoriesHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, `{"error":"method not allowed"}`, http.StatusMethodNotAllowed)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+
+	path := strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/categories"), "/")
+	if path == "" {
+		categories, err := listCategories()
+		if err != nil {
+			logHandlerError(r.Context(), "categories", "database", "failed to list categories", "error", err)
+			http.Error(w, `{"error":"database error"}`, http.StatusInternalServerError)
+			return
+		}
+		json.NewEncoder(w).Encode(categories)
+		return
+	}
+
+	// The category itself may contain slashes, so /items is matched at the end
+	name, ok := strings.CutSuffix(path, "/items")
+	if !ok {
+		http.Error(w, `{"error":"not found"}`, http.StatusNotFound)
+		return
+	}
+	category, err := normalizeCategory(name)
+	if err != nil || category == "" {
+		http.Error(w, `{"error":"invalid category"}`, http.StatusBadRequest)
+		return
+	}
+
+	recursive := r.URL.Query().Get("recursive") != "false"
+	items, found, err := itemsInCategory(category, recursive)
+	if err != nil {
+		logHandlerError(r.Context(), "categories", "database", "failed to list category items", "error", err)
+		http.Error(w, `{"error":"database error"}`, http.StatusInternalServerError)
+		return
+	}
+	if !found {
+		http.Error(w, `{"error":"category not found"}`, http.StatusNotFound)
+		return
+	}
+	json.NewEncoder(w).Encode(items)
+}
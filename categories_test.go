@@ -0,0 +1,135 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestNormalizeCategory(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    string
+		wantErr bool
+	}{
+		{"", "", false},
+		{"  ", "", false},
+		{"Hardware", "hardware", false},
+		{" /Hardware/Laptops/ ", "hardware/laptops", false},
+		{"home / bath", "home/bath", false},
+		{"café/crème-brûlée", "café/crème-brûlée", false},
+		{"a//b", "", true},
+		{"tools#1", "", true},
+		{"a/b/c/d/e/f/g/h/i/j/k", "", true},
+		{strings.Repeat("x", 201), "", true},
+	}
+	for _, tt := range tests {
+		got, err := normalizeCategory(tt.in)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("normalizeCategory(%q) = %q, %v; want %q, error %v", tt.in, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestCategories_ListAndItems(t *testing.T) {
+	srv := newTestServer(t)
+
+	laptop := createTestItem(t, srv, `{"name":"laptop","category":"Hardware/Laptops"}`)
+	monitor := createTestItem(t, srv, `{"name":"monitor","category":"hardware/monitors"}`)
+	cable := createTestItem(t, srv, `{"name":"cable","category":"hardware"}`)
+	createTestItem(t, srv, `{"name":"novel","category":"books"}`)
+	createTestItem(t, srv, `{"name":"uncategorized"}`)
+
+	if laptop.Category != "hardware/laptops" {
+		t.Errorf("category = %q, want normalized hardware/laptops", laptop.Category)
+	}
+
+	_, body := doRequest(t, srv, http.MethodGet, "/api/categories", "")
+	var categories []CategoryInfo
+	if err := json.Unmarshal(body, &categories); err != nil {
+		t.Fatalf("invalid JSON: %s", body)
+	}
+	want := []CategoryInfo{
+		{Name: "books", Items: 1, TotalItems: 1},
+		{Name: "hardware", Items: 1, TotalItems: 3},
+		{Name: "hardware/laptops", Parent: "hardware", Items: 1, TotalItems: 1},
+		{Name: "hardware/monitors", Parent: "hardware", Items: 1, TotalItems: 1},
+	}
+	if fmt.Sprint(categories) != fmt.Sprint(want) {
+		t.Errorf("categories = %+v\nwant %+v", categories, want)
+	}
+
+	// The whole subtree, in ID order
+	code, body := doRequest(t, srv, http.MethodGet, "/api/categories/hardware/items", "")
+	var items []Item
+	json.Unmarshal(body, &items)
+	if code != http.StatusOK || len(items) != 3 || items[0].ID != laptop.ID || items[1].ID != monitor.ID || items[2].ID != cable.ID {
+		t.Errorf("hardware items = %d %s", code, body)
+	}
+
+	// Only direct members
+	_, body = doRequest(t, srv, http.MethodGet, "/api/categories/hardware/items?recursive=false", "")
+	items = nil
+	json.Unmarshal(body, &items)
+	if len(items) != 1 || items[0].ID != cable.ID {
+		t.Errorf("direct hardware items = %s", body)
+	}
+
+	// A category whose name is a prefix of another isn't mixed in
+	_, body = doRequest(t, srv, http.MethodGet, "/api/categories/hardware/laptops/items", "")
+	if strings.Contains(string(body), "monitor") || !strings.Contains(string(body), "laptop") {
+		t.Errorf("laptops items = %s", body)
+	}
+}
+
+func TestCategories_IndexFollowsUpdatesAndDeletes(t *testing.T) {
+	srv := newTestServer(t)
+
+	item := createTestItem(t, srv, `{"name":"widget","category":"tools"}`)
+
+	// Move it to another category
+	code, body := doRequest(t, srv, http.MethodPut, fmt.Sprintf("/api/items/%d", item.ID), `{"name":"widget","category":"garden/tools"}`)
+	if code != http.StatusOK || !strings.Contains(string(body), `"category":"garden/tools"`) {
+		t.Fatalf("update = %d %s", code, body)
+	}
+	if code, _ := doRequest(t, srv, http.MethodGet, "/api/categories/tools/items", ""); code != http.StatusNotFound {
+		t.Errorf("old category status = %d, want 404", code)
+	}
+	if code, _ := doRequest(t, srv, http.MethodGet, "/api/categories/garden/tools/items", ""); code != http.StatusOK {
+		t.Errorf("new category status = %d, want 200", code)
+	}
+
+	doRequest(t, srv, http.MethodDelete, fmt.Sprintf("/api/items/%d", item.ID), "")
+	_, body = doRequest(t, srv, http.MethodGet, "/api/categories", "")
+	if strings.TrimSpace(string(body)) != "[]" {
+		t.Errorf("categories after delete = %s, want []", body)
+	}
+}
+
+func TestCategories_Errors(t *testing.T) {
+	srv := newTestServer(t)
+
+	tests := []struct {
+		name       string
+		method     string
+		path       string
+		body       string
+		wantStatus int
+	}{
+		{"invalid category on create", http.MethodPost, "/api/items", `{"name":"x","category":"a//b"}`, http.StatusUnprocessableEntity},
+		{"unknown category", http.MethodGet, "/api/categories/nothing/items", "", http.StatusNotFound},
+		{"missing /items", http.MethodGet, "/api/categories/hardware", "", http.StatusNotFound},
+		{"invalid category path", http.MethodGet, "/api/categories/a%23b/items", "", http.StatusBadRequest},
+		{"wrong method", http.MethodPost, "/api/categories", "", http.StatusMethodNotAllowed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, body := doRequest(t, srv, tt.method, tt.path, tt.body)
+			if code != tt.wantStatus {
+				t.Errorf("status = %d, want %d (body %s)", code, tt.wantStatus, body)
+			}
+		})
+	}
+}
@@ -0,0 +1,195 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"math/rand/v2"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// =============================================================================
+// Chaos Endpoints
+// =============================================================================
+//
+// Break this replica on purpose, to show the platform healing around it.
+// Off unless CHAOS_ENABLED=true, and behind ADMIN_TOKEN when one is set:
+//
+//	curl -X POST http://localhost:8080/api/chaos/panic
+//	{"error":"internal server error"}      # recovered: 500, logged, counted
+//
+//	curl -X POST 'http://localhost:8080/api/chaos/panic?crash=true'
+//	{"status":"crashing"}                  # then the process dies
+//
+// A plain panic shows recoveryMiddleware (middleware.go) at work: the
+// replica answers 500, logs the stack, bumps demoapp_panics_total, and keeps
+// serving. With ?crash=true the panic happens in a goroutine, which nothing
+// recovers, so the process exits with Go's panic output and status 2 —
+// Kubernetes restarts the pod and the load balancer routes around it.
+//
+// For a tidier version of the same demo, exit on a timer:
+//
+//	curl -X POST 'http://localhost:8080/api/chaos/exit?code=1&delay=5s'
+//	{"status":"exiting","code":1,"delay":"5s","exit_at":"2025-01-01T12:00:05Z"}
+//
+// Until then /ready answers 503, so the load balancer takes the replica out
+// of rotation before it goes; then the database is closed and the process
+// exits with the given code (1 by default, which Kubernetes counts as a
+// crash and restarts).
+
+// chaosEnabled turns the /api/chaos/ endpoints on (CHAOS_ENABLED, set in main)
+var chaosEnabled = false
+
+// How long a crash waits, so the 202 reaches the client first
+const chaosCrashDelay = 100 * time.Millisecond
+
+// Longest ?delay= for /api/chaos/exit
+const maxChaosExitDelay = 10 * time.Minute
+
+// chaosExit is the exit /api/chaos/exit scheduled, if any
+var chaosExit struct {
+	sync.Mutex
+	at time.Time // zero when none is scheduled
+}
+
+// exitProcess ends the process; tests replace it
+var exitProcess = func(code int) {
+	if db != nil {
+		db.Close() // flush a file-based database; os.Exit skips defers
+	}
+	os.Exit(code)
+}
+
+// chaosExitPending reports whether the process is about to exit, for /ready
+func chaosExitPending() bool {
+	chaosExit.Lock()
+	defer chaosExit.Unlock()
+	return !chaosExit.at.IsZero()
+}
+
+// chaosAllowed answers 403 unless CHAOS_ENABLED is on. It returns false
+// when the response is done.
+func chaosAllowed(w http.ResponseWriter) bool {
+	if !chaosEnabled {
+		http.Error(w, `{"error":"chaos endpoints are disabled (set CHAOS_ENABLED=true)"}`, http.StatusForbidden)
+		return false
+	}
+	return true
+}
+
+// chaosPanicHandler handles POST /api/chaos/panic[?crash=true]
+func chaosPanicHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	if r.Method != http.MethodPost {
+		http.Error(w, `{"error":"method not allowed"}`, http.StatusMethodNotAllowed)
+		return
+	}
+	if !chaosAllowed(w) {
+		return
+	}
+	crash, _ := strconv.ParseBool(r.URL.Query().Get("crash"))
+	slog.WarnContext(r.Context(), "chaos panic requested", "crash", crash, "client_ip", r.RemoteAddr)
+
+	if !crash {
+		panic("chaos: panic requested via POST /api/chaos/panic")
+	}
+	w.WriteHeader(http.StatusAccepted)
+	w.Write([]byte(`{"status":"crashing"}` + "\n"))
+	http.NewResponseController(w).Flush()
+	go func() {
+		time.Sleep(chaosCrashDelay)
+		panic("chaos: crash requested via POST /api/chaos/panic?crash=true")
+	}()
+}
+
+// chaosExitHandler handles POST /api/chaos/exit[?code=1&delay=5s]
+func chaosExitHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	if r.Method != http.MethodPost {
+		http.Error(w, `{"error":"method not allowed"}`, http.StatusMethodNotAllowed)
+		return
+	}
+	if !chaosAllowed(w) {
+		return
+	}
+
+	code := 1
+	if value := r.URL.Query().Get("code"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed < 0 || parsed > 125 {
+			writeJSONError(w, http.StatusBadRequest, "code must be an exit status between 0 and 125")
+			return
+		}
+		code = parsed
+	}
+	var delay time.Duration
+	if value := r.URL.Query().Get("delay"); value != "" {
+		parsed, err := time.ParseDuration(value)
+		if err != nil || parsed < 0 || parsed > maxChaosExitDelay {
+			writeJSONError(w, http.StatusBadRequest, fmt.Sprintf("delay must be a duration between 0s and %s", maxChaosExitDelay))
+			return
+		}
+		delay = parsed
+	}
+	// Always long enough for this response to get out
+	delay = max(delay, chaosCrashDelay)
+
+	chaosExit.Lock()
+	if !chaosExit.at.IsZero() {
+		at := chaosExit.at
+		chaosExit.Unlock()
+		writeJSONError(w, http.StatusConflict, "exit already scheduled for "+at.Format(time.RFC3339))
+		return
+	}
+	at := time.Now().Add(delay).UTC()
+	chaosExit.at = at
+	chaosExit.Unlock()
+
+	slog.WarnContext(r.Context(), "chaos exit scheduled", "code", code, "delay", delay.String(), "client_ip", r.RemoteAddr)
+	time.AfterFunc(delay, func() {
+		slog.Warn("chaos exit", "code", code)
+		exitProcess(code)
+	})
+
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(map[string]any{
+		"status":  "exiting",
+		"code":    code,
+		"delay":   delay.String(),
+		"exit_at": at.Format(time.RFC3339),
+	})
+}
+
+// =============================================================================
+// Startup Simulation
+// =============================================================================
+//
+// Slow-booting and crash-looping services, for readiness gates, progressive
+// rollouts, and rollbacks:
+//
+//	STARTUP_DELAY=45s ./demo-app               # port opens after 45 seconds
+//	FAIL_STARTUP_PROBABILITY=0.5 ./demo-app    # half the starts exit with 1
+//
+// The delay comes first and the port isn't open during it, so a pod sits
+// Running but not Ready, the way a slow service does. A failed start logs
+// "simulated startup failure" and exits 1 after the delay — set
+// FAIL_STARTUP_PROBABILITY=1 on a new version and watch the rollout stall
+// on CrashLoopBackOff and roll back.
+
+// simulateStartup waits for STARTUP_DELAY and rolls the dice for
+// FAIL_STARTUP_PROBABILITY. It returns false when this start should fail.
+func simulateStartup(delay time.Duration, failProbability float64) bool {
+	if delay > 0 {
+		slog.Warn("simulating slow startup", "delay", delay.String())
+		time.Sleep(delay)
+	}
+	if failProbability > 0 && rand.Float64() < failProbability {
+		slog.Error("simulated startup failure", "probability", failProbability)
+		return false
+	}
+	return true
+}
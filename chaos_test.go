@@ -0,0 +1,114 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestRecoveryMiddleware(t *testing.T) {
+	before := testutil.ToFloat64(panicsTotal.WithLabelValues("/api/boom"))
+	handler := recoveryMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic("boom")
+	}))
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/boom", nil))
+	var got map[string]string
+	json.Unmarshal(rec.Body.Bytes(), &got)
+	if rec.Code != http.StatusInternalServerError || got["error"] != "internal server error" {
+		t.Errorf("panicking handler = %d %s, want a JSON 500", rec.Code, rec.Body)
+	}
+	if after := testutil.ToFloat64(panicsTotal.WithLabelValues("/api/boom")); after != before+1 {
+		t.Errorf("demoapp_panics_total = %v, want %v", after, before+1)
+	}
+
+	// http.ErrAbortHandler is left to net/http
+	abort := recoveryMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic(http.ErrAbortHandler)
+	}))
+	defer func() {
+		if p := recover(); p != http.ErrAbortHandler {
+			t.Errorf("recovered %v, want http.ErrAbortHandler re-panicked", p)
+		}
+	}()
+	abort.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+}
+
+func TestChaosPanic(t *testing.T) {
+	handler := recoveryMiddleware(http.HandlerFunc(chaosPanicHandler))
+	post := func() int {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/api/chaos/panic", nil))
+		return rec.Code
+	}
+
+	if code := post(); code != http.StatusForbidden {
+		t.Errorf("with CHAOS_ENABLED off = %d, want 403", code)
+	}
+
+	prev := chaosEnabled
+	chaosEnabled = true
+	t.Cleanup(func() { chaosEnabled = prev })
+	if code := post(); code != http.StatusInternalServerError {
+		t.Errorf("with CHAOS_ENABLED on = %d, want 500", code)
+	}
+}
+
+func TestChaosExit(t *testing.T) {
+	srv := newTestServer(t)
+	prev, prevExit := chaosEnabled, exitProcess
+	chaosEnabled = true
+	exited := make(chan int, 1)
+	exitProcess = func(code int) { exited <- code }
+	t.Cleanup(func() {
+		chaosEnabled, exitProcess = prev, prevExit
+		chaosExit.Lock()
+		chaosExit.at = time.Time{}
+		chaosExit.Unlock()
+	})
+
+	for _, query := range []string{"code=300", "code=x", "delay=soon", "delay=1h"} {
+		if code, body := doRequest(t, srv, http.MethodPost, "/api/chaos/exit?"+query, ""); code != http.StatusBadRequest {
+			t.Errorf("?%s = %d %s, want 400", query, code, body)
+		}
+	}
+
+	code, body := doRequest(t, srv, http.MethodPost, "/api/chaos/exit?code=3&delay=300ms", "")
+	if code != http.StatusAccepted {
+		t.Fatalf("POST /api/chaos/exit = %d %s", code, body)
+	}
+	// Out of rotation while it counts down, and only one exit at a time
+	if code, _ := doRequest(t, srv, http.MethodGet, "/ready", ""); code != http.StatusServiceUnavailable {
+		t.Errorf("/ready while exiting = %d, want 503", code)
+	}
+	if code, _ := doRequest(t, srv, http.MethodPost, "/api/chaos/exit", ""); code != http.StatusConflict {
+		t.Errorf("second exit = %d, want 409", code)
+	}
+
+	select {
+	case got := <-exited:
+		if got != 3 {
+			t.Errorf("exit code = %d, want 3", got)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("process didn't exit")
+	}
+}
+
+func TestSimulateStartup(t *testing.T) {
+	start := time.Now()
+	if !simulateStartup(50*time.Millisecond, 0) {
+		t.Error("probability 0 failed")
+	}
+	if took := time.Since(start); took < 50*time.Millisecond {
+		t.Errorf("STARTUP_DELAY=50ms took %v", took)
+	}
+	if simulateStartup(0, 1) {
+		t.Error("probability 1 succeeded")
+	}
+}
@@ -0,0 +1,229 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+// =============================================================================
+// Circuit Breakers for Outbound HTTP
+// =============================================================================
+//
+// When a service the app calls goes down, retrying it on every request only
+// piles on. A circuit breaker stops calling it for a while instead:
+//
+//	closed     normal; CIRCUIT_FAILURE_THRESHOLD failures in a row open it
+//	open       calls fail at once with "circuit open", no request is sent
+//	half-open  after CIRCUIT_OPEN_DURATION, one probe call goes through:
+//	           success closes the circuit, failure opens it again
+//
+// There is one breaker per host, shared by everything that calls it through
+// newOutboundClient (outbound.go): log webhook shipping, the downstream
+// fan-out, the display source poller, and refresh_display schedules. A
+// failure is a connection error, a timeout, or a 5xx response. Watch them
+// trip:
+//
+//	curl http://localhost:8080/api/admin/circuits
+//	[{"name":"orders:8080","state":"open","consecutive_failures":5,"last_error":"503 Service Unavailable",...}]
+//
+// demoapp_circuit_state (0 closed, 1 half-open, 2 open) graphs the same thing.
+
+// Circuit states
+const (
+	circuitClosed   = "closed"
+	circuitHalfOpen = "half-open"
+	circuitOpen     = "open"
+)
+
+// Gauge values for demoapp_circuit_state
+var circuitStateValues = map[string]float64{circuitClosed: 0, circuitHalfOpen: 1, circuitOpen: 2}
+
+// Returned instead of calling a host whose circuit is open
+var errCircuitOpen = errors.New("circuit open")
+
+// Breaker settings, from CIRCUIT_FAILURE_THRESHOLD and CIRCUIT_OPEN_DURATION
+// (set in main). A threshold of 0 turns the breakers off.
+var (
+	circuitFailureThreshold = 5
+	circuitOpenDuration     = 30 * time.Second
+)
+
+// CircuitStatus is one breaker in GET /api/admin/circuits
+type CircuitStatus struct {
+	Name                string     `json:"name"` // host:port
+	State               string     `json:"state"`
+	ConsecutiveFailures int        `json:"consecutive_failures"`
+	Requests            int64      `json:"requests"` // calls let through
+	Failures            int64      `json:"failures"`
+	Rejected            int64      `json:"rejected"` // calls refused while open
+	OpenedAt            *time.Time `json:"opened_at,omitempty"`
+	LastError           string     `json:"last_error,omitempty"`
+}
+
+// circuitBreaker is one host's breaker; probing is true while the half-open
+// probe is in flight
+type circuitBreaker struct {
+	mu      sync.Mutex
+	status  CircuitStatus
+	probing bool
+}
+
+// circuitRegistry holds every host's breaker
+type circuitRegistry struct {
+	mu       sync.Mutex
+	breakers map[string]*circuitBreaker
+}
+
+var circuits = &circuitRegistry{breakers: map[string]*circuitBreaker{}}
+
+// get returns the breaker for host, creating it on first use
+func (c *circuitRegistry) get(host string) *circuitBreaker {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	b, ok := c.breakers[host]
+	if !ok {
+		b = &circuitBreaker{status: CircuitStatus{Name: host, State: circuitClosed}}
+		c.breakers[host] = b
+		circuitState.WithLabelValues(host).Set(0)
+	}
+	return b
+}
+
+// list returns every breaker's status, sorted by name
+func (c *circuitRegistry) list() []CircuitStatus {
+	c.mu.Lock()
+	breakers := make([]*circuitBreaker, 0, len(c.breakers))
+	for _, b := range c.breakers {
+		breakers = append(breakers, b)
+	}
+	c.mu.Unlock()
+
+	list := make([]CircuitStatus, 0, len(breakers))
+	for _, b := range breakers {
+		b.mu.Lock()
+		list = append(list, b.status)
+		b.mu.Unlock()
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	return list
+}
+
+// allow reports whether a call may go out now. An open circuit whose wait
+// is over lets exactly one probe through.
+func (b *circuitBreaker) allow() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.status.State {
+	case circuitOpen:
+		if time.Since(*b.status.OpenedAt) < circuitOpenDuration {
+			b.status.Rejected++
+			return false
+		}
+		b.setState(circuitHalfOpen)
+		b.probing = true
+	case circuitHalfOpen:
+		if b.probing {
+			b.status.Rejected++
+			return false
+		}
+		b.probing = true
+	}
+	b.status.Requests++
+	return true
+}
+
+// record updates the breaker with a call's outcome (errMsg "" is success)
+func (b *circuitBreaker) record(errMsg string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.probing = false
+	if errMsg == "" {
+		b.status.ConsecutiveFailures = 0
+		if b.status.State != circuitClosed {
+			b.status.OpenedAt = nil
+			b.setState(circuitClosed)
+		}
+		return
+	}
+
+	b.status.Failures++
+	b.status.ConsecutiveFailures++
+	b.status.LastError = errMsg
+	if b.status.State == circuitHalfOpen || b.status.ConsecutiveFailures >= circuitFailureThreshold {
+		now := time.Now().UTC()
+		b.status.OpenedAt = &now
+		b.setState(circuitOpen)
+	}
+}
+
+// release ends a call without counting it either way
+func (b *circuitBreaker) release() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.probing = false
+}
+
+// setState changes state and the gauge; callers hold b.mu
+func (b *circuitBreaker) setState(state string) {
+	b.status.State = state
+	circuitState.WithLabelValues(b.status.Name).Set(circuitStateValues[state])
+}
+
+// circuitTransport is an http.RoundTripper that sends each request through
+// its host's breaker. Wrapping the transport (instead of each call site)
+// means every client from newOutboundClient (outbound.go) gets one.
+type circuitTransport struct {
+	next http.RoundTripper
+}
+
+// newCircuitTransport wraps next (nil means http.DefaultTransport)
+func newCircuitTransport(next http.RoundTripper) *circuitTransport {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	return &circuitTransport{next: next}
+}
+
+// RoundTrip implements http.RoundTripper
+func (t *circuitTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if circuitFailureThreshold <= 0 {
+		return t.next.RoundTrip(req)
+	}
+
+	b := circuits.get(req.URL.Host)
+	if !b.allow() {
+		circuitRejectionsTotal.WithLabelValues(req.URL.Host).Inc()
+		return nil, errCircuitOpen
+	}
+
+	resp, err := t.next.RoundTrip(req)
+	switch {
+	case errors.Is(req.Context().Err(), context.Canceled):
+		// The caller gave up; that says nothing about the host
+		b.release()
+	case err != nil:
+		b.record(err.Error())
+	case resp.StatusCode >= 500:
+		b.record(resp.Status)
+	default:
+		b.record("")
+	}
+	return resp, err
+}
+
+// circuitsHandler handles GET /api/admin/circuits
+func circuitsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, `{"error":"method not allowed"}`, http.StatusMethodNotAllowed)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(circuits.list())
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// withCircuits gives a test fresh breakers with its own settings
+func withCircuits(t *testing.T, threshold int, openFor time.Duration) {
+	t.Helper()
+	prev, prevThreshold, prevOpen := circuits, circuitFailureThreshold, circuitOpenDuration
+	circuits = &circuitRegistry{breakers: map[string]*circuitBreaker{}}
+	circuitFailureThreshold, circuitOpenDuration = threshold, openFor
+	t.Cleanup(func() {
+		circuits, circuitFailureThreshold, circuitOpenDuration = prev, prevThreshold, prevOpen
+	})
+}
+
+func TestCircuitTransport_OpensAndRecovers(t *testing.T) {
+	withCircuits(t, 2, 50*time.Millisecond)
+
+	var failing atomic.Bool
+	var calls atomic.Int32
+	failing.Store(true)
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		if failing.Load() {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer upstream.Close()
+
+	client := &http.Client{Transport: newCircuitTransport(nil)}
+	get := func() error {
+		resp, err := client.Get(upstream.URL)
+		if err == nil {
+			resp.Body.Close()
+		}
+		return err
+	}
+
+	// Two 503s open the circuit; the next call never reaches the server
+	get()
+	get()
+	if err := get(); !errors.Is(err, errCircuitOpen) {
+		t.Fatalf("third call error = %v, want circuit open", err)
+	}
+	if calls.Load() != 2 {
+		t.Errorf("server saw %d calls, want 2", calls.Load())
+	}
+
+	// After the wait, a failed probe opens it again
+	time.Sleep(60 * time.Millisecond)
+	if err := get(); err != nil {
+		t.Fatalf("probe error = %v", err)
+	}
+	if err := get(); !errors.Is(err, errCircuitOpen) {
+		t.Fatalf("after failed probe error = %v, want circuit open", err)
+	}
+
+	// A successful probe closes it
+	failing.Store(false)
+	time.Sleep(60 * time.Millisecond)
+	if err := get(); err != nil {
+		t.Fatalf("probe error = %v", err)
+	}
+	if err := get(); err != nil {
+		t.Fatalf("after recovery error = %v", err)
+	}
+
+	list := circuits.list()
+	if len(list) != 1 || list[0].State != circuitClosed || list[0].Rejected != 2 || list[0].Failures != 3 {
+		t.Errorf("circuits = %+v", list)
+	}
+}
+
+func TestCircuitBreaker_HalfOpenAllowsOneProbe(t *testing.T) {
+	withCircuits(t, 1, time.Millisecond)
+
+	b := circuits.get("example:80")
+	b.allow()
+	b.record("boom")
+	time.Sleep(2 * time.Millisecond)
+
+	if !b.allow() {
+		t.Fatal("expected the probe to be allowed")
+	}
+	if b.allow() {
+		t.Error("expected a second call to wait for the probe")
+	}
+	b.release() // the probe's caller went away
+	if !b.allow() {
+		t.Error("expected a new probe after release")
+	}
+}
+
+func TestCircuitTransport_Disabled(t *testing.T) {
+	withCircuits(t, 0, time.Minute)
+
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer upstream.Close()
+
+	client := &http.Client{Transport: newCircuitTransport(nil)}
+	for range 3 {
+		resp, err := client.Get(upstream.URL)
+		if err != nil {
+			t.Fatalf("error = %v, want none with breakers off", err)
+		}
+		resp.Body.Close()
+	}
+	if len(circuits.list()) != 0 {
+		t.Errorf("circuits = %+v, want none", circuits.list())
+	}
+}
+
+func TestCircuitsHandler_ShowsDownstreamBreaker(t *testing.T) {
+	srv := newTestServer(t)
+	withCircuits(t, 1, time.Minute)
+
+	down := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer down.Close()
+	withDownstreams(t, down.URL)
+
+	doRequest(t, srv, http.MethodGet, "/api/downstream", "")
+	_, body := doRequest(t, srv, http.MethodGet, "/api/downstream", "")
+	if !strings.Contains(string(body), "circuit open") {
+		t.Errorf("second fan-out = %s, want circuit open", body)
+	}
+
+	code, body := doRequest(t, srv, http.MethodGet, "/api/admin/circuits", "")
+	var list []CircuitStatus
+	json.Unmarshal(body, &list)
+	if code != http.StatusOK || len(list) != 1 || list[0].State != circuitOpen || list[0].Name != strings.TrimPrefix(down.URL, "http://") {
+		t.Errorf("circuits = %d %s", code, body)
+	}
+	if code, _ := doRequest(t, srv, http.MethodPost, "/api/admin/circuits", ""); code != http.StatusMethodNotAllowed {
+		t.Errorf("POST = %d, want 405", code)
+	}
+}
@@ -0,0 +1,436 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// =============================================================================
+// Subcommands
+// =============================================================================
+//
+// The first argument picks what the binary does, like "git commit" or
+// "docker run". With no command (or only flags) it runs the server, so
+// existing "./demo-app" and Docker ENTRYPOINTs keep working:
+//
+//	./demo-app                                   # same as "serve"
+//	./demo-app serve --port 9090 --db /data
+//	./demo-app backup --db /data --out demo.bak
+//	./demo-app restore --db /data --in demo.bak
+//	./demo-app seed --db /data --count 50
+//	./demo-app migrate --db /data --status
+//	./demo-app items get --db /data 42              (cliitems.go)
+//	./demo-app fsck --db /data --fix                (fsck.go)
+//	./demo-app analyze --db /data --out report.html (analyze.go)
+//	./demo-app version --json
+//	./demo-app loadgen --path /api/items -n 500 -c 10
+//
+// backup, restore, seed, migrate, items, fsck, and analyze work on a data directory
+// directly, with no server running — Badger locks the directory, so stop the
+// server first. They read DB_ENCRYPTION_KEY like the server does. healthcheck, bench, and
+// loadgen talk to a running server over HTTP.
+//
+// Each command parses its own flags with a FlagSet and returns an exit code:
+// 0 success, 1 failure, 2 bad usage.
+
+// command is one "demo-app <name>" subcommand
+type command struct {
+	name    string
+	summary string
+	run     func(args []string) int
+}
+
+// commandList returns the subcommands in the order "demo-app help" shows
+// them. A function rather than a var: help refers back to the list, and a
+// var that mentions itself is an initialization cycle in Go.
+func commandList() []command {
+	return []command{
+		{"serve", "run the web server (the default)", runServe},
+		{"backup", "write a data directory to a backup file", func(args []string) int { return runBackupCommand(args, os.Stdout) }},
+		{"restore", "load a backup file into a data directory", func(args []string) int { return runRestoreCommand(args, os.Stdout) }},
+		{"seed", "add items to a data directory from a file or fake data", func(args []string) int { return runSeedCommand(args, os.Stdout) }},
+		{"migrate", "bring a data directory's schema up to date", func(args []string) int { return runMigrateCommand(args, os.Stdout) }},
+		{"items", "list, get, put, or delete items in a data directory", func(args []string) int { return runItemsCommand(args, os.Stdout) }},
+		{"fsck", "check a data directory for damaged records and indexes", func(args []string) int { return runFsckCommand(args, os.Stdout) }},
+		{"analyze", "write a data directory's items and request stats to an HTML report or SQLite file", func(args []string) int { return runAnalyzeCommand(args, os.Stdout) }},
+		{"version", "print the version", func(args []string) int { return runVersion(args, os.Stdout) }},
+		{"healthcheck", "probe a running server (exit 0 healthy, 1 not)", func(args []string) int { return runHealthcheck(args, os.Stdout) }},
+		{"loadgen", "send steady requests to one path of a running server", runLoadgen},
+		{"bench", "load-test a running server's items API", runBench},
+	}
+}
+
+// runCommand dispatches os.Args[1:] to a subcommand; returns the exit code
+func runCommand(args []string) int {
+	if len(args) == 0 {
+		return runServe(nil)
+	}
+	switch args[0] {
+	case "help", "-h", "-help", "--help":
+		printUsage(os.Stdout)
+		return 0
+	}
+	// "./demo-app --port 9090" is serve with flags
+	if strings.HasPrefix(args[0], "-") {
+		return runServe(args)
+	}
+	for _, cmd := range commandList() {
+		if cmd.name == args[0] {
+			return cmd.run(args[1:])
+		}
+	}
+	fmt.Fprintf(os.Stderr, "demo-app: unknown command %q\n\n", args[0])
+	printUsage(os.Stderr)
+	return 2
+}
+
+// printUsage lists the subcommands
+func printUsage(w io.Writer) {
+	fmt.Fprintln(w, "Usage: demo-app [command] [flags]")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Commands:")
+	for _, cmd := range commandList() {
+		fmt.Fprintf(w, "  %-12s %s\n", cmd.name, cmd.summary)
+	}
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, `Run "demo-app <command> -h" for a command's flags.`)
+}
+
+// runServe is "demo-app serve". The flags default to PORT and DB_PATH, so
+// env-only deployments don't change; every other setting stays an env var.
+func runServe(args []string) int {
+	flags := flag.NewFlagSet("serve", flag.ContinueOnError)
+	port := flags.String("port", envString("PORT", "8080"), "HTTP listen port")
+	dbPath := flags.String("db", envString("DB_PATH", ":memory:"), "data directory, or :memory:")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if flags.NArg() > 0 {
+		fmt.Fprintf(os.Stderr, "serve: unexpected argument %q\n", flags.Arg(0))
+		return 2
+	}
+	serve(*port, *dbPath)
+	return 0
+}
+
+// runVersion is "demo-app version"
+func runVersion(args []string, out io.Writer) int {
+	flags := flag.NewFlagSet("version", flag.ContinueOnError)
+	asJSON := flags.Bool("json", false, "print JSON instead of one line")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if *asJSON {
+		json.NewEncoder(out).Encode(map[string]string{
+			"version":    version,
+			"go_version": runtime.Version(),
+			"os":         runtime.GOOS,
+			"arch":       runtime.GOARCH,
+		})
+		return 0
+	}
+	fmt.Fprintf(out, "demo-app %s (%s %s/%s)\n", version, runtime.Version(), runtime.GOOS, runtime.GOARCH)
+	return 0
+}
+
+// dbFlag adds the --db flag shared by the offline commands
+func dbFlag(flags *flag.FlagSet) *string {
+	return flags.String("db", os.Getenv("DB_PATH"), "data directory (default $DB_PATH)")
+}
+
+// openDataDir opens the database at dbPath for an offline command and
+// returns the function that closes it again. It sets the same package-level
+// db and itemSeq the server uses, so the store functions work unchanged.
+func openDataDir(dbPath string) (func(), error) {
+	if dbPath == "" || dbPath == ":memory:" {
+		return nil, errors.New("--db must be a data directory (an in-memory database only exists inside a running server)")
+	}
+	if err := loadEncryptionKeys(dbPath); err != nil {
+		return nil, err
+	}
+	if err := loadDBOptions(); err != nil {
+		return nil, err
+	}
+	store, err := initStore(dbPath)
+	if err != nil {
+		return nil, fmt.Errorf("open %s (is the server still running?): %w", dbPath, err)
+	}
+	seq, err := store.GetSequence([]byte("seq:items"), 100)
+	if err != nil {
+		store.Close()
+		return nil, err
+	}
+	db, itemSeq = store, seq
+	return func() {
+		// Same order as serve's deferred calls; itemSeq is read at close time
+		// because a badger restore replaces it
+		counters.stop()
+		itemSeq.Release()
+		db.Close()
+	}, nil
+}
+
+// runBackupCommand is "demo-app backup"
+func runBackupCommand(args []string, out io.Writer) int {
+	flags := flag.NewFlagSet("backup", flag.ContinueOnError)
+	dbPath := dbFlag(flags)
+	format := flags.String("format", "badger", "badger (everything) or ndjson (items only)")
+	outPath := flags.String("out", "", `file to write, "-" for stdout (default demo-app-backup-<time>.bak or demo-app-items-<time>.ndjson)`)
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if *format != "badger" && *format != "ndjson" {
+		fmt.Fprintln(os.Stderr, "backup: --format must be badger or ndjson")
+		return 2
+	}
+	// Opening a missing directory would create it and back up nothing
+	if *dbPath != "" && *dbPath != ":memory:" {
+		if _, err := os.Stat(*dbPath); err != nil {
+			fmt.Fprintf(os.Stderr, "backup: %v\n", err)
+			return 1
+		}
+	}
+
+	closeDB, err := openDataDir(*dbPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "backup: %v\n", err)
+		return 1
+	}
+	defer closeDB()
+
+	if *outPath == "-" {
+		if err := writeBackup(out, *format); err != nil {
+			fmt.Fprintf(os.Stderr, "backup: %v\n", err)
+			return 1
+		}
+		return 0
+	}
+
+	path := *outPath
+	if path == "" {
+		timestamp := time.Now().UTC().Format("20060102T150405Z")
+		path = fmt.Sprintf("%s-%s.bak", backupBaseName, timestamp)
+		if *format == "ndjson" {
+			path = fmt.Sprintf("%s-%s.ndjson", exportBaseName, timestamp)
+		}
+	}
+	file, err := os.Create(path)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "backup: %v\n", err)
+		return 1
+	}
+	err = writeBackup(file, *format)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(path) // don't leave half a backup behind
+		fmt.Fprintf(os.Stderr, "backup: %v\n", err)
+		return 1
+	}
+
+	info, _ := os.Stat(path)
+	json.NewEncoder(out).Encode(map[string]any{"path": path, "format": *format, "bytes": info.Size()})
+	return 0
+}
+
+// runRestoreCommand is "demo-app restore". Like POST /api/admin/restore, the
+// format comes from the file extension: .bak replaces everything, .ndjson
+// adds the items alongside the existing ones.
+func runRestoreCommand(args []string, out io.Writer) int {
+	flags := flag.NewFlagSet("restore", flag.ContinueOnError)
+	dbPath := dbFlag(flags)
+	inPath := flags.String("in", "", "backup file to load (.bak or .ndjson)")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	ext := filepath.Ext(*inPath)
+	if ext != ".bak" && ext != ".ndjson" {
+		fmt.Fprintln(os.Stderr, "restore: --in must be a .bak or .ndjson file")
+		return 2
+	}
+	data, err := os.ReadFile(*inPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "restore: %v\n", err)
+		return 1
+	}
+
+	closeDB, err := openDataDir(*dbPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "restore: %v\n", err)
+		return 1
+	}
+	defer closeDB()
+
+	if ext == ".bak" {
+		if err := restoreBadgerBackup(data); err != nil {
+			fmt.Fprintf(os.Stderr, "restore: %v\n", err)
+			return 1
+		}
+		count, _ := countItems()
+		json.NewEncoder(out).Encode(map[string]any{"format": "badger", "items": count})
+		return 0
+	}
+
+	// Items are re-created, so the schema has to be current first
+	if err := runMigrations(); err != nil {
+		fmt.Fprintf(os.Stderr, "restore: %v\n", err)
+		return 1
+	}
+	created, failures := restoreNDJSON(data)
+	json.NewEncoder(out).Encode(map[string]any{"format": "ndjson", "created": created, "failed": len(failures), "errors": failures})
+	if len(failures) > 0 {
+		return 1
+	}
+	return 0
+}
+
+// runSeedCommand is "demo-app seed". Unlike SEED_FILE/SEED_COUNT at startup
+// it adds items even when the store already has some; --if-empty restores
+// the startup behavior.
+func runSeedCommand(args []string, out io.Writer) int {
+	flags := flag.NewFlagSet("seed", flag.ContinueOnError)
+	dbPath := dbFlag(flags)
+	file := flags.String("file", "", "JSON array or NDJSON file of items")
+	count := flags.Int("count", 0, "number of fake items to generate")
+	ifEmpty := flags.Bool("if-empty", false, "only seed a store with no items")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if *file == "" && *count <= 0 {
+		fmt.Fprintln(os.Stderr, "seed: set --file, --count, or both")
+		return 2
+	}
+
+	// Read the file before opening the database, so a typo fails fast
+	var inputs []itemInput
+	if *file != "" {
+		var err error
+		inputs, err = readSeedFile(*file)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "seed: %s: %v\n", *file, err)
+			return 1
+		}
+	}
+	for range *count {
+		inputs = append(inputs, fakeItemInput())
+	}
+
+	// Seed items follow the same ITEM_* validation rules as the server
+	if err := loadItemValidation(); err != nil {
+		fmt.Fprintf(os.Stderr, "seed: %v\n", err)
+		return 1
+	}
+	closeDB, err := openDataDir(*dbPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "seed: %v\n", err)
+		return 1
+	}
+	defer closeDB()
+
+	if err := runMigrations(); err != nil {
+		fmt.Fprintf(os.Stderr, "seed: %v\n", err)
+		return 1
+	}
+	if *ifEmpty {
+		empty, err := storeIsEmpty()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "seed: %v\n", err)
+			return 1
+		}
+		if !empty {
+			json.NewEncoder(out).Encode(map[string]any{"created": 0, "skipped": len(inputs), "reason": "store not empty"})
+			return 0
+		}
+	}
+
+	created, skipped := seedItems(inputs)
+	json.NewEncoder(out).Encode(map[string]any{"created": created, "skipped": skipped})
+	return 0
+}
+
+// runMigrateCommand is "demo-app migrate": runs pending migrations (or with
+// --status only reports them) and prints the same JSON as
+// GET /api/admin/migrations
+func runMigrateCommand(args []string, out io.Writer) int {
+	flags := flag.NewFlagSet("migrate", flag.ContinueOnError)
+	dbPath := dbFlag(flags)
+	statusOnly := flags.Bool("status", false, "report the schema version without migrating")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	closeDB, err := openDataDir(*dbPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "migrate: %v\n", err)
+		return 1
+	}
+	defer closeDB()
+
+	if !*statusOnly {
+		if err := runMigrations(); err != nil {
+			fmt.Fprintf(os.Stderr, "migrate: %v\n", err)
+			return 1
+		}
+	}
+	current, err := getSchemaVersion()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "migrate: %v\n", err)
+		return 1
+	}
+	statuses, err := migrationStatuses()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "migrate: %v\n", err)
+		return 1
+	}
+	json.NewEncoder(out).Encode(map[string]any{
+		"schema_version": current,
+		"latest_version": latestSchemaVersion(),
+		"migrations":     statuses,
+	})
+	return 0
+}
+
+// runLoadgen is "demo-app loadgen": n GETs of one path, c at a time, to put
+// traffic on the dashboards. POST /api/jobs {"type":"load_generation"} does
+// the same from inside the server; bench measures the items API instead.
+func runLoadgen(args []string) int {
+	flags := flag.NewFlagSet("loadgen", flag.ContinueOnError)
+	baseURL := flags.String("url", "http://localhost:"+envString("PORT", "8080"), "server to send requests to")
+	path := flags.String("path", "/health", "path to request")
+	requests := flags.Int("n", 100, "number of requests")
+	concurrency := flags.Int("c", 5, "concurrent clients")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if *requests < 1 || *concurrency < 1 {
+		fmt.Fprintln(os.Stderr, "loadgen: -n and -c must be at least 1")
+		return 2
+	}
+	if !strings.HasPrefix(*path, "/") {
+		fmt.Fprintln(os.Stderr, "loadgen: --path must start with /")
+		return 2
+	}
+
+	client := &http.Client{Timeout: 10 * time.Second}
+	target := strings.TrimSuffix(*baseURL, "/") + *path
+	fmt.Printf("Sending %d requests to %s, %d concurrent clients\n\n", *requests, target, *concurrency)
+	result := benchScenario("GET", *requests, *concurrency, func(int) error {
+		return benchGet(client, target)
+	})
+	printBenchResult(result)
+
+	if result.Errors > 0 {
+		return 1
+	}
+	return 0
+}
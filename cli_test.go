@@ -0,0 +1,145 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// withOfflineDB restores the package-level database after a test that runs
+// offline commands, which point db and itemSeq at their own directory
+func withOfflineDB(t *testing.T) {
+	t.Helper()
+	prevDB, prevSeq, prevPath := db, itemSeq, storePath
+	t.Cleanup(func() {
+		db, itemSeq, storePath = prevDB, prevSeq, prevPath
+		syncItemsGauge()
+	})
+}
+
+// runJSON runs an offline command and decodes the JSON it prints
+func runJSON(t *testing.T, run func([]string, io.Writer) int, args ...string) map[string]any {
+	t.Helper()
+	var out bytes.Buffer
+	if code := run(args, &out); code != 0 {
+		t.Fatalf("%v: exit code = %d, output %s", args, code, out.String())
+	}
+	var got map[string]any
+	if err := json.Unmarshal(out.Bytes(), &got); err != nil {
+		t.Fatalf("%v: output isn't JSON: %v: %s", args, err, out.String())
+	}
+	return got
+}
+
+func TestRunCommand_Usage(t *testing.T) {
+	if code := runCommand([]string{"help"}); code != 0 {
+		t.Errorf("help: exit code = %d, want 0", code)
+	}
+	if code := runCommand([]string{"frobnicate"}); code != 2 {
+		t.Errorf("unknown command: exit code = %d, want 2", code)
+	}
+	if code := runCommand([]string{"serve", "extra"}); code != 2 {
+		t.Errorf("serve with an argument: exit code = %d, want 2", code)
+	}
+}
+
+func TestVersionCommand(t *testing.T) {
+	var out bytes.Buffer
+	runVersion(nil, &out)
+	if !strings.HasPrefix(out.String(), "demo-app "+version+" (go") {
+		t.Errorf("version = %q", out.String())
+	}
+
+	out.Reset()
+	runVersion([]string{"--json"}, &out)
+	var got map[string]string
+	json.Unmarshal(out.Bytes(), &got)
+	if got["version"] != version || got["go_version"] == "" {
+		t.Errorf("version --json = %s", out.String())
+	}
+}
+
+func TestOfflineCommands_SeedBackupRestore(t *testing.T) {
+	withOfflineDB(t)
+	dir := t.TempDir()
+	source := filepath.Join(dir, "source")
+
+	got := runJSON(t, runSeedCommand, "--db", source, "--count", "3")
+	if got["created"] != 3.0 {
+		t.Fatalf("seed = %v", got)
+	}
+	// --if-empty leaves a store with items alone
+	got = runJSON(t, runSeedCommand, "--db", source, "--count", "3", "--if-empty")
+	if got["created"] != 0.0 {
+		t.Errorf("seed --if-empty = %v", got)
+	}
+
+	for _, format := range []string{"badger", "ndjson"} {
+		t.Run(format, func(t *testing.T) {
+			file := filepath.Join(dir, "backup."+map[string]string{"badger": "bak", "ndjson": "ndjson"}[format])
+			got := runJSON(t, runBackupCommand, "--db", source, "--format", format, "--out", file)
+			if got["path"] != file {
+				t.Fatalf("backup = %v", got)
+			}
+
+			target := filepath.Join(dir, "restored-"+format)
+			runJSON(t, runRestoreCommand, "--db", target, "--in", file)
+
+			closeDB, err := openDataDir(target)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer closeDB()
+			if count, _ := countItems(); count != 3 {
+				t.Errorf("restored %d items, want 3", count)
+			}
+		})
+	}
+}
+
+func TestOfflineCommands_Errors(t *testing.T) {
+	withOfflineDB(t)
+	var out bytes.Buffer
+	if code := runBackupCommand([]string{"--db", filepath.Join(t.TempDir(), "missing")}, &out); code != 1 {
+		t.Errorf("backup of a missing directory: exit code = %d, want 1", code)
+	}
+	if code := runSeedCommand([]string{"--db", ":memory:", "--count", "1"}, &out); code != 1 {
+		t.Errorf("seed into :memory:: exit code = %d, want 1", code)
+	}
+	if code := runSeedCommand([]string{"--db", t.TempDir()}, &out); code != 2 {
+		t.Errorf("seed without --file or --count: exit code = %d, want 2", code)
+	}
+	if code := runRestoreCommand([]string{"--db", t.TempDir(), "--in", "backup.zip"}, &out); code != 2 {
+		t.Errorf("restore of a .zip: exit code = %d, want 2", code)
+	}
+}
+
+func TestMigrateCommand(t *testing.T) {
+	withOfflineDB(t)
+	path := t.TempDir()
+
+	got := runJSON(t, runMigrateCommand, "--db", path, "--status")
+	if got["schema_version"] != 0.0 {
+		t.Errorf("--status on a new directory = %v, want schema_version 0", got)
+	}
+	got = runJSON(t, runMigrateCommand, "--db", path)
+	if got["schema_version"] != got["latest_version"] {
+		t.Errorf("migrate = %v, want schema_version = latest_version", got)
+	}
+}
+
+func TestLoadgenCommand(t *testing.T) {
+	srv := newTestServer(t)
+	if code := runLoadgen([]string{"--url", srv.URL, "--path", "/health", "-n", "20", "-c", "4"}); code != 0 {
+		t.Errorf("exit code = %d, want 0", code)
+	}
+	if code := runLoadgen([]string{"--url", srv.URL, "--path", "/api/status/500", "-n", "2"}); code != 1 {
+		t.Errorf("failing path: exit code = %d, want 1", code)
+	}
+	if code := runLoadgen([]string{"--path", "health"}); code != 2 {
+		t.Errorf("path without /: exit code = %d, want 2", code)
+	}
+}
@@ -0,0 +1,252 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"math"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	badger "github.com/dgraph-io/badger/v4"
+)
+
+// =============================================================================
+// Per-Client Statistics
+// =============================================================================
+//
+// Request and error counts per client, for noisy-neighbor and rate-limiting
+// demos: point a load generator at the app with one API key, normal traffic
+// with another, and compare:
+//
+//	hey -n 5000 -H "X-API-Key: team-noisy" http://localhost:8080/api/items
+//	curl http://localhost:8080/api/stats/clients
+//	[{"client":"key:86ca20d5","kind":"api_key","requests":5000,"errors":12,"error_rate":0.0024,...},
+//	 {"client":"10.0.0.7","kind":"ip","requests":40,...}]
+//
+// A client is its X-API-Key header when one is sent, otherwise its IP
+// address. Keys can be real credentials (accesspolicy.go) and the stats
+// need no token, so a key is shown as a fingerprint: the first 8 hex
+// characters of its SHA-256. Find yours with
+//
+//	printf %s team-noisy | sha256sum | cut -c1-8
+//
+// Counts live in memory and are saved to BadgerDB every
+// CLIENT_STATS_PERSIST_INTERVAL, so they survive a restart with DB_PATH set.
+// Each replica counts its own traffic and is not replicated.
+
+// Where the counts are saved
+const clientStatsKey = "stats:clients"
+
+// Header that names the client
+const apiKeyHeader = "X-API-Key"
+
+// Clients tracked; later ones are counted together under "other"
+const maxTrackedClients = 1000
+
+// How API keys are shown: "key:" and this many hex characters of the hash
+const (
+	apiKeyFingerprintPrefix = "key:"
+	apiKeyFingerprintLength = 8
+)
+
+// ClientStats is one client's numbers
+type ClientStats struct {
+	Client    string    `json:"client"`
+	Kind      string    `json:"kind"` // api_key, ip, or other
+	Requests  int64     `json:"requests"`
+	Errors    int64     `json:"errors"` // responses with status >= 400
+	ErrorRate float64   `json:"error_rate"`
+	FirstSeen time.Time `json:"first_seen"`
+	LastSeen  time.Time `json:"last_seen"`
+}
+
+// clientStatsStore holds the counts; dirty means there are changes to save
+type clientStatsStore struct {
+	mu      sync.Mutex
+	clients map[string]*ClientStats
+	dirty   bool
+}
+
+var clientStats = &clientStatsStore{clients: map[string]*ClientStats{}}
+
+// clientIdentity names the client behind a request
+func clientIdentity(r *http.Request) (client, kind string) {
+	if key := r.Header.Get(apiKeyHeader); key != "" {
+		return apiKeyFingerprint(key), "api_key"
+	}
+	return clientHost(r.RemoteAddr), "ip"
+}
+
+// apiKeyFingerprint names a key without showing any of it
+func apiKeyFingerprint(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return apiKeyFingerprintPrefix + hex.EncodeToString(sum[:])[:apiKeyFingerprintLength]
+}
+
+// isAPIKeyFingerprint reports whether a saved client name is a fingerprint;
+// older versions saved keys as they were sent
+func isAPIKeyFingerprint(client string) bool {
+	hash, ok := strings.CutPrefix(client, apiKeyFingerprintPrefix)
+	if !ok || len(hash) != apiKeyFingerprintLength {
+		return false
+	}
+	_, err := hex.DecodeString(hash)
+	return err == nil
+}
+
+// record counts one request
+func (s *clientStatsStore) record(client, kind string, status int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stats, ok := s.clients[client]
+	if !ok {
+		if len(s.clients) >= maxTrackedClients {
+			client, kind = "other", "other"
+			stats = s.clients[client]
+		}
+		if stats == nil {
+			now := time.Now().UTC()
+			stats = &ClientStats{Client: client, Kind: kind, FirstSeen: now}
+			s.clients[client] = stats
+		}
+	}
+	stats.Requests++
+	if status >= 400 {
+		stats.Errors++
+	}
+	stats.LastSeen = time.Now().UTC()
+	s.dirty = true
+}
+
+// list returns every client, busiest first
+func (s *clientStatsStore) list() []ClientStats {
+	s.mu.Lock()
+	list := make([]ClientStats, 0, len(s.clients))
+	for _, stats := range s.clients {
+		c := *stats
+		c.ErrorRate = math.Round(float64(c.Errors)/float64(c.Requests)*10000) / 10000
+		list = append(list, c)
+	}
+	s.mu.Unlock()
+
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].Requests != list[j].Requests {
+			return list[i].Requests > list[j].Requests
+		}
+		return list[i].Client < list[j].Client
+	})
+	return list
+}
+
+// load reads saved counts from BadgerDB (nothing saved is fine)
+func (s *clientStatsStore) load() error {
+	var saved []*ClientStats
+	err := dbView("client_stats_load", clientStatsKey, func(txn *badger.Txn) error {
+		item, err := txn.Get([]byte(clientStatsKey))
+		if err != nil {
+			return err
+		}
+		return item.Value(func(val []byte) error { return json.Unmarshal(val, &saved) })
+	})
+	if errors.Is(err, badger.ErrKeyNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, stats := range saved {
+		if stats.Kind == "api_key" && !isAPIKeyFingerprint(stats.Client) {
+			continue // a raw key from an older version; don't show it
+		}
+		s.clients[stats.Client] = stats
+	}
+	return nil
+}
+
+// save writes the counts to BadgerDB if they changed since the last save
+func (s *clientStatsStore) save() error {
+	s.mu.Lock()
+	if !s.dirty {
+		s.mu.Unlock()
+		return nil
+	}
+	list := make([]*ClientStats, 0, len(s.clients))
+	for _, stats := range s.clients {
+		c := *stats
+		list = append(list, &c)
+	}
+	s.dirty = false
+	s.mu.Unlock()
+
+	data, err := json.Marshal(list)
+	if err != nil {
+		return err
+	}
+	return dbUpdate("client_stats_save", clientStatsKey, func(txn *badger.Txn) error {
+		return txn.Set([]byte(clientStatsKey), data)
+	})
+}
+
+// reset forgets every client, in memory and in BadgerDB
+func (s *clientStatsStore) reset() error {
+	s.mu.Lock()
+	s.clients = map[string]*ClientStats{}
+	s.dirty = false
+	s.mu.Unlock()
+
+	return dbUpdate("client_stats_reset", clientStatsKey, func(txn *badger.Txn) error {
+		return txn.Delete([]byte(clientStatsKey))
+	})
+}
+
+// startClientStatsPersister saves the counts every interval (0 disables)
+func startClientStatsPersister(interval time.Duration) {
+	if interval <= 0 {
+		return
+	}
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for range ticker.C {
+			if err := clientStats.save(); err != nil {
+				slog.Warn("failed to save client stats", "error", err)
+			}
+		}
+	}()
+}
+
+// clientStatsHandler handles GET /api/stats/clients and DELETE (admin) to
+// start over
+func clientStatsHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	switch r.Method {
+	case http.MethodGet:
+		json.NewEncoder(w).Encode(clientStats.list())
+	case http.MethodDelete:
+		// Anyone may look; wiping the counts needs ADMIN_TOKEN
+		adminMiddleware(resetClientStats)(w, r)
+	default:
+		http.Error(w, `{"error":"method not allowed"}`, http.StatusMethodNotAllowed)
+	}
+}
+
+// resetClientStats handles DELETE /api/stats/clients
+func resetClientStats(w http.ResponseWriter, r *http.Request) {
+	if err := clientStats.reset(); err != nil {
+		logHandlerError(r.Context(), "client_stats", "database", "failed to reset client stats", "error", err)
+		http.Error(w, `{"error":"database error"}`, http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,169 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestClientIdentity(t *testing.T) {
+	tests := []struct {
+		apiKey     string
+		wantClient string
+		wantKind   string
+	}{
+		{"", "192.0.2.1", "ip"},
+		{"team-a", "key:96c2886c", "api_key"},
+		{"sk_live_1234567890abcdef", "key:014c0728", "api_key"},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		if tt.apiKey != "" {
+			r.Header.Set(apiKeyHeader, tt.apiKey)
+		}
+		client, kind := clientIdentity(r)
+		if client != tt.wantClient || kind != tt.wantKind {
+			t.Errorf("key %q: got (%q, %q), want (%q, %q)", tt.apiKey, client, kind, tt.wantClient, tt.wantKind)
+		}
+	}
+}
+
+func TestClientStats_RecordAndPersist(t *testing.T) {
+	newTestServer(t)
+	s := &clientStatsStore{clients: map[string]*ClientStats{}}
+	teamA := apiKeyFingerprint("team-a")
+
+	for _, status := range []int{200, 200, 404, 500} {
+		s.record(teamA, "api_key", status)
+	}
+	s.record("10.0.0.1", "ip", 200)
+
+	list := s.list()
+	if len(list) != 2 || list[0].Client != teamA {
+		t.Fatalf("list = %+v", list)
+	}
+	if got := list[0]; got.Requests != 4 || got.Errors != 2 || got.ErrorRate != 0.5 {
+		t.Errorf("team-a = %+v", got)
+	}
+
+	// Saved counts come back in a fresh store
+	if err := s.save(); err != nil {
+		t.Fatal(err)
+	}
+	loaded := &clientStatsStore{clients: map[string]*ClientStats{}}
+	if err := loaded.load(); err != nil {
+		t.Fatal(err)
+	}
+	if got := loaded.list(); len(got) != 2 || got[0].Requests != 4 {
+		t.Errorf("loaded = %+v", got)
+	}
+
+	if err := s.reset(); err != nil {
+		t.Fatal(err)
+	}
+	loaded = &clientStatsStore{clients: map[string]*ClientStats{}}
+	loaded.load()
+	if len(s.list()) != 0 || len(loaded.list()) != 0 {
+		t.Error("reset left counts behind")
+	}
+}
+
+func TestClientStats_LoadDropsRawKeys(t *testing.T) {
+	newTestServer(t)
+	s := &clientStatsStore{clients: map[string]*ClientStats{}}
+	s.record("ops-secret", "api_key", 200) // as older versions saved it
+	s.record(apiKeyFingerprint("ops-secret"), "api_key", 200)
+	s.record("10.0.0.1", "ip", 200)
+	if err := s.save(); err != nil {
+		t.Fatal(err)
+	}
+
+	loaded := &clientStatsStore{clients: map[string]*ClientStats{}}
+	if err := loaded.load(); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := loaded.clients["ops-secret"]; ok || len(loaded.clients) != 2 {
+		t.Errorf("loaded = %+v", loaded.list())
+	}
+}
+
+func TestClientStats_NoPolicyKeysShown(t *testing.T) {
+	withAccessPolicy(t, "enforce")
+	srv := newTestServer(t)
+	clientStats.reset()
+	t.Cleanup(func() { clientStats.reset() })
+	guarded := httptest.NewServer(accessPolicyMiddleware(srv.Config.Handler))
+	defer guarded.Close()
+
+	for _, key := range []string{"ops-secret", "ci-secret", "wrong"} {
+		req, _ := http.NewRequest(http.MethodGet, guarded.URL+"/api/items", nil)
+		req.Header.Set(apiKeyHeader, key)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+	}
+
+	_, body := doRequest(t, srv, http.MethodGet, "/api/stats/clients", "")
+	var list []ClientStats
+	json.Unmarshal(body, &list)
+	if len(list) != 3 {
+		t.Fatalf("stats = %s", body)
+	}
+	for _, secret := range []string{"ops-secret", "ci-secret", "ops-", "ci-s", "cret"} {
+		if strings.Contains(string(body), secret) {
+			t.Errorf("stats show %q: %s", secret, body)
+		}
+	}
+}
+
+func TestClientStats_Cap(t *testing.T) {
+	s := &clientStatsStore{clients: map[string]*ClientStats{}}
+	for i := 0; i < maxTrackedClients+5; i++ {
+		s.record(fmt.Sprintf("10.0.%d.%d", i/256, i%256), "ip", 200)
+	}
+	if len(s.clients) != maxTrackedClients+1 || s.clients["other"].Requests != 5 {
+		t.Errorf("clients = %d, other = %+v", len(s.clients), s.clients["other"])
+	}
+}
+
+func TestClientStatsHandler(t *testing.T) {
+	srv := newTestServer(t)
+	clientStats.reset()
+	t.Cleanup(func() { clientStats.reset() })
+
+	for _, path := range []string{"/api/items", "/api/items/999"} {
+		req, _ := http.NewRequest(http.MethodGet, srv.URL+path, nil)
+		req.Header.Set(apiKeyHeader, "team-noisy")
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+	}
+
+	code, body := doRequest(t, srv, http.MethodGet, "/api/stats/clients", "")
+	if code != http.StatusOK {
+		t.Fatalf("status = %d", code)
+	}
+	var list []ClientStats
+	json.Unmarshal(body, &list)
+	if len(list) != 1 || list[0].Client != apiKeyFingerprint("team-noisy") || list[0].Requests != 2 || list[0].Errors != 1 {
+		t.Errorf("stats = %s", body)
+	}
+
+	if code, _ := doRequest(t, srv, http.MethodDelete, "/api/stats/clients", ""); code != http.StatusNoContent {
+		t.Errorf("DELETE = %d, want 204", code)
+	}
+	t.Setenv("ADMIN_TOKEN", "secret")
+	if code, _ := doRequest(t, srv, http.MethodDelete, "/api/stats/clients", ""); code != http.StatusUnauthorized {
+		t.Errorf("DELETE without the admin token = %d, want 401", code)
+	}
+	if code, _ := doRequest(t, srv, http.MethodPost, "/api/stats/clients", ""); code != http.StatusMethodNotAllowed {
+		t.Errorf("POST = %d, want 405", code)
+	}
+}
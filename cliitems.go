@@ -0,0 +1,291 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"time"
+
+	badger "github.com/dgraph-io/badger/v4"
+)
+
+// =============================================================================
+// Offline Item Commands
+// =============================================================================
+//
+// Break-glass access to the items in a data directory, for when the server
+// won't start (a bad record, a failed migration) and the HTTP API isn't
+// there to help:
+//
+//	./demo-app items list --db /data                 # NDJSON, one item per line
+//	./demo-app items get --db /data 42
+//	./demo-app items put --db /data --id 42 --file fixed.json
+//	./demo-app items put --db /data < new.json      # no --id: create
+//	./demo-app items delete --db /data 42
+//
+// Like the other offline commands (cli.go) the server must be stopped first.
+// Nothing here runs migrations, so a database the server can't migrate can
+// still be looked at. Records that aren't valid JSON are reported with their
+// key instead of skipped, and get prints their raw bytes, so they can be
+// fixed with put or removed with delete.
+//
+// The name index for UNIQUE_ITEM_NAMES isn't touched: the server rebuilds it
+// from the items on its next start.
+
+// runItemsCommand is "demo-app items <action>"
+func runItemsCommand(args []string, out io.Writer) int {
+	if len(args) == 0 {
+		fmt.Fprintln(os.Stderr, "usage: demo-app items list|get|put|delete --db <dir> [id]")
+		return 2
+	}
+	action, args := args[0], args[1:]
+
+	flags := flag.NewFlagSet("items "+action, flag.ContinueOnError)
+	dbPath := dbFlag(flags)
+	var (
+		limit *int
+		id    *int64
+		file  *string
+	)
+	switch action {
+	case "list":
+		limit = flags.Int("limit", 0, "stop after this many items (0 = all)")
+	case "put":
+		id = flags.Int64("id", -1, "existing item to replace (default: create a new item)")
+		file = flags.String("file", "-", `JSON item to store, "-" for stdin`)
+	case "get", "delete":
+	default:
+		fmt.Fprintf(os.Stderr, "items: unknown action %q (want list, get, put, or delete)\n", action)
+		return 2
+	}
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	// get and delete take the ID as their one argument
+	var itemID int64
+	if action == "get" || action == "delete" {
+		if flags.NArg() != 1 {
+			fmt.Fprintf(os.Stderr, "usage: demo-app items %s --db <dir> <id>\n", action)
+			return 2
+		}
+		var err error
+		itemID, err = strconv.ParseInt(flags.Arg(0), 10, 64)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "items %s: invalid id %q\n", action, flags.Arg(0))
+			return 2
+		}
+	} else if flags.NArg() > 0 {
+		fmt.Fprintf(os.Stderr, "items %s: unexpected argument %q\n", action, flags.Arg(0))
+		return 2
+	}
+
+	// Read the input before opening the database, so a bad file fails fast
+	var input itemInput
+	if action == "put" {
+		var err error
+		input, err = readItemInput(*file)
+		if err == nil {
+			// Same ITEM_* validation rules as the server
+			err = loadItemValidation()
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "items put: %v\n", err)
+			return 1
+		}
+	}
+
+	closeDB, err := openDataDir(*dbPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "items %s: %v\n", action, err)
+		return 1
+	}
+	defer closeDB()
+
+	switch action {
+	case "list":
+		err = listItemsOffline(out, *limit)
+	case "get":
+		err = getItemOffline(out, itemID)
+	case "put":
+		err = putItemOffline(out, *id, input)
+	case "delete":
+		err = removeItem(itemID)
+		if err == nil {
+			json.NewEncoder(out).Encode(map[string]int64{"deleted": itemID})
+		}
+	}
+	if errors.Is(err, badger.ErrKeyNotFound) {
+		err = fmt.Errorf("no item with id %d", itemID)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "items %s: %v\n", action, err)
+		return 1
+	}
+	return 0
+}
+
+// readItemInput reads and checks the JSON for "items put"
+func readItemInput(file string) (itemInput, error) {
+	var data []byte
+	var err error
+	if file == "-" {
+		data, err = io.ReadAll(os.Stdin)
+	} else {
+		data, err = os.ReadFile(file)
+	}
+	if err != nil {
+		return itemInput{}, err
+	}
+
+	var input itemInput
+	if err := json.Unmarshal(data, &input); err != nil {
+		return itemInput{}, fmt.Errorf("invalid JSON: %w", err)
+	}
+	if input.Name == "" {
+		return itemInput{}, errors.New("name is required")
+	}
+	return input, nil
+}
+
+// listItemsOffline prints every item as NDJSON. Malformed records go to
+// stderr with their key, and make the command fail once the rest are printed.
+func listItemsOffline(out io.Writer, limit int) error {
+	encoder := json.NewEncoder(out)
+	listed, malformed := 0, 0
+	err := dbView("item_list", itemKeyPrefix, func(txn *badger.Txn) error {
+		it := txn.NewIterator(badger.DefaultIteratorOptions)
+		defer it.Close()
+
+		prefix := []byte(itemKeyPrefix)
+		for it.Seek(prefix); it.ValidForPrefix(prefix); it.Next() {
+			if limit > 0 && listed >= limit {
+				return nil
+			}
+			key := string(it.Item().Key())
+			err := it.Item().Value(func(val []byte) error {
+				var item Item
+				if err := decodeItem(val, &item); err != nil {
+					malformed++
+					fmt.Fprintf(os.Stderr, "%s: malformed record (%d bytes): %v\n", key, len(val), err)
+					return nil
+				}
+				listed++
+				return encoder.Encode(item)
+			})
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err == nil && malformed > 0 {
+		err = fmt.Errorf("%d malformed record(s)", malformed)
+	}
+	return err
+}
+
+// getItemOffline prints one item. A record that isn't valid JSON is printed
+// as stored, so it can be copied out, fixed, and put back.
+func getItemOffline(out io.Writer, id int64) error {
+	key := itemKey(id)
+	var raw []byte
+	err := dbView("item_get", string(key), func(txn *badger.Txn) error {
+		dbItem, err := txn.Get(key)
+		if err != nil {
+			return err
+		}
+		raw, err = dbItem.ValueCopy(nil)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	var item Item
+	if err := decodeItem(raw, &item); err != nil {
+		out.Write(raw)
+		fmt.Fprintln(out)
+		return fmt.Errorf("%s: malformed record: %w", key, err)
+	}
+	return json.NewEncoder(out).Encode(item)
+}
+
+// putItemOffline creates an item (id -1; 0 is a real ID) or replaces an existing one's
+// fields. Replacing works on a malformed record too: that's the repair.
+func putItemOffline(out io.Writer, id int64, input itemInput) error {
+	input, errs := checkItemInput(input)
+	if len(errs) > 0 {
+		return fmt.Errorf("%s: %s", errs[0].Field, errs[0].Message)
+	}
+
+	if id < 0 {
+		item, _, err := insertItem(input, "")
+		if err != nil {
+			return err
+		}
+		return json.NewEncoder(out).Encode(item)
+	}
+
+	// Only existing IDs: a new one could be handed out again by the sequence
+	key := itemKey(id)
+	var item Item
+	err := dbUpdate("item_update", string(key), func(txn *badger.Txn) error {
+		if err := touchItemsVersion(txn); err != nil {
+			return err
+		}
+		dbItem, err := txn.Get(key)
+		if err != nil {
+			return err
+		}
+		// A malformed record leaves item empty: keep nothing from it, and
+		// count it as new in the totals (aggregates.go)
+		var before *Item
+		if err := dbItem.Value(func(val []byte) error { return decodeItem(val, &item) }); err != nil {
+			item = Item{}
+		} else {
+			prev := item
+			before = &prev
+		}
+		if item.Category != "" && item.Category != input.Category {
+			if err := txn.Delete(categoryIndexKey(item.Category, id)); err != nil {
+				return err
+			}
+		}
+		if input.Category != "" {
+			if err := txn.Set(categoryIndexKey(input.Category, id), nil); err != nil {
+				return err
+			}
+		}
+
+		item.ID = id
+		item.Name = input.Name
+		item.Description = input.Description
+		item.Tags = input.Tags
+		item.Category = input.Category
+		item.Metadata = input.Metadata
+		item.Version++ // itemversion.go
+		if item.CreatedAt.IsZero() {
+			item.CreatedAt = time.Now().UTC()
+		}
+		if err := rootKeyspace.recordItemChange(txn, before, &item); err != nil {
+			return err
+		}
+		value, err := encodeItem(item)
+		if err != nil {
+			return err
+		}
+		return txn.Set(key, value)
+	})
+	if errors.Is(err, badger.ErrKeyNotFound) {
+		return fmt.Errorf("no item with id %d (omit --id to create one)", id)
+	}
+	if err != nil {
+		return err
+	}
+	return json.NewEncoder(out).Encode(item)
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	badger "github.com/dgraph-io/badger/v4"
+)
+
+func TestItemsCommand_PutGetListDelete(t *testing.T) {
+	withOfflineDB(t)
+	dir := t.TempDir()
+	input := filepath.Join(t.TempDir(), "item.json")
+	os.WriteFile(input, []byte(`{"name":"widget","category":"Tools"}`), 0o644)
+
+	created := runJSON(t, runItemsCommand, "put", "--db", dir, "--file", input)
+	if created["name"] != "widget" || created["category"] != "tools" {
+		t.Fatalf("put = %v", created)
+	}
+	id := strconv.FormatFloat(created["id"].(float64), 'f', -1, 64)
+
+	os.WriteFile(input, []byte(`{"name":"gadget"}`), 0o644)
+	replaced := runJSON(t, runItemsCommand, "put", "--db", dir, "--id", id, "--file", input)
+	if replaced["name"] != "gadget" || replaced["created_at"] != created["created_at"] {
+		t.Errorf("put --id = %v, want name gadget and the original created_at", replaced)
+	}
+	if got := runJSON(t, runItemsCommand, "get", "--db", dir, id); got["name"] != "gadget" {
+		t.Errorf("get = %v", got)
+	}
+
+	var out bytes.Buffer
+	if code := runItemsCommand([]string{"list", "--db", dir}, &out); code != 0 || strings.Count(out.String(), "\n") != 1 {
+		t.Errorf("list: exit code %d, output %q", code, out.String())
+	}
+
+	runJSON(t, runItemsCommand, "delete", "--db", dir, id)
+	if code := runItemsCommand([]string{"get", "--db", dir, id}, &out); code != 1 {
+		t.Errorf("get after delete: exit code = %d, want 1", code)
+	}
+	if code := runItemsCommand([]string{"put", "--db", dir, "--id", "99", "--file", input}, &out); code != 1 {
+		t.Errorf("put --id of a missing item: exit code = %d, want 1", code)
+	}
+}
+
+func TestItemsCommand_MalformedRecord(t *testing.T) {
+	withOfflineDB(t)
+	dir := t.TempDir()
+
+	// Write a record the server would choke on
+	closeDB, err := openDataDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	db.Update(func(txn *badger.Txn) error { return txn.Set(itemKey(7), []byte(`{"name":`)) })
+	closeDB()
+
+	var out bytes.Buffer
+	if code := runItemsCommand([]string{"list", "--db", dir}, &out); code != 1 {
+		t.Errorf("list with a malformed record: exit code = %d, want 1", code)
+	}
+	out.Reset()
+	if code := runItemsCommand([]string{"get", "--db", dir, "7"}, &out); code != 1 || out.String() != "{\"name\":\n" {
+		t.Errorf("get: exit code %d, output %q; want 1 and the raw record", code, out.String())
+	}
+
+	// put --id repairs it
+	input := filepath.Join(t.TempDir(), "fixed.json")
+	os.WriteFile(input, []byte(`{"name":"fixed"}`), 0o644)
+	if got := runJSON(t, runItemsCommand, "put", "--db", dir, "--id", "7", "--file", input); got["name"] != "fixed" {
+		t.Errorf("put = %v", got)
+	}
+	if code := runItemsCommand([]string{"list", "--db", dir}, &out); code != 0 {
+		t.Errorf("list after repair: exit code = %d, want 0", code)
+	}
+}
+
+func TestItemsCommand_Usage(t *testing.T) {
+	var out bytes.Buffer
+	for _, args := range [][]string{
+		nil,
+		{"frob"},
+		{"get", "--db", "/tmp/x"},
+		{"delete", "--db", "/tmp/x", "abc"},
+	} {
+		if code := runItemsCommand(args, &out); code != 2 {
+			t.Errorf("%v: exit code = %d, want 2", args, code)
+		}
+	}
+}
@@ -0,0 +1,246 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"path"
+	"strings"
+	"sync"
+	"time"
+)
+
+// =============================================================================
+// Cloud Metadata Detection
+// =============================================================================
+//
+// Every major cloud runs an instance metadata service (IMDS) at a link-local
+// address that only the VM itself can reach. Asking it "what am I?" lets a
+// multi-cloud demo prove which cloud served the request:
+//
+//	GET /api/system/cloud
+//	{"provider": "aws", "region": "us-east-1", "zone": "us-east-1a",
+//	 "instance_type": "t3.small", "spot": false, ...}
+//
+// Off by default (CLOUD_METADATA=true to enable): outside a cloud, the probe
+// requests just time out, and there's no reason to make them on a laptop.
+//
+// All three providers are asked in parallel with a short timeout; the first
+// valid answer wins. Metadata doesn't change while the VM runs, so the result
+// is cached after the first successful lookup.
+
+// CloudInfo is the response for /api/system/cloud
+type CloudInfo struct {
+	Enabled      bool   `json:"enabled"`
+	Detected     bool   `json:"detected"`
+	Provider     string `json:"provider,omitempty"` // aws, gcp, azure
+	Region       string `json:"region,omitempty"`
+	Zone         string `json:"zone,omitempty"`
+	InstanceType string `json:"instance_type,omitempty"`
+	InstanceID   string `json:"instance_id,omitempty"`
+	Spot         bool   `json:"spot"` // spot (AWS/Azure) or preemptible/Spot VM (GCP)
+}
+
+// Metadata service base URLs (variables so tests can point them at httptest servers)
+var (
+	awsMetadataURL   = "http://169.254.169.254"
+	gcpMetadataURL   = "http://metadata.google.internal"
+	azureMetadataURL = "http://169.254.169.254"
+)
+
+// Cached detection result; nil until a provider has answered
+var (
+	cloudMu     sync.Mutex
+	cloudCached *CloudInfo
+)
+
+// cloudDetector asks one provider's metadata service for instance details
+type cloudDetector func(ctx context.Context, client *http.Client) (*CloudInfo, error)
+
+// detectCloud queries all providers in parallel and returns the first answer
+func detectCloud(timeout time.Duration) CloudInfo {
+	cloudMu.Lock()
+	defer cloudMu.Unlock()
+	if cloudCached != nil {
+		return *cloudCached
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	client := newOutboundClient(timeout) // outbound.go; ctx ends it sooner
+
+	detectors := map[string]cloudDetector{
+		"aws":   detectAWS,
+		"gcp":   detectGCP,
+		"azure": detectAzure,
+	}
+
+	// Buffered so goroutines that finish after we've returned don't block forever
+	results := make(chan *CloudInfo, len(detectors))
+	for name, detect := range detectors {
+		go func(name string, detect cloudDetector) {
+			info, err := detect(ctx, client)
+			if err != nil {
+				results <- nil
+				return
+			}
+			info.Provider = name
+			results <- info
+		}(name, detect)
+	}
+
+	for range detectors {
+		if info := <-results; info != nil {
+			info.Enabled = true
+			info.Detected = true
+			cloudCached = info
+			return *info
+		}
+	}
+
+	// Nothing answered. Not cached, so a slow IMDS gets another chance.
+	return CloudInfo{Enabled: true}
+}
+
+// fetchMetadata makes a GET (or other method) to a metadata service and
+// returns the body, treating any non-200 status as an error
+func fetchMetadata(ctx context.Context, client *http.Client, method, url string, headers map[string]string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, method, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	for name, value := range headers {
+		req.Header.Set(name, value)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("metadata %s: status %d", url, resp.StatusCode)
+	}
+	return io.ReadAll(io.LimitReader(resp.Body, 64*1024))
+}
+
+// detectAWS uses IMDSv2: first PUT for a session token, then GET with it.
+// (IMDSv1's plain GETs are disabled on many hardened instances.)
+func detectAWS(ctx context.Context, client *http.Client) (*CloudInfo, error) {
+	token, err := fetchMetadata(ctx, client, http.MethodPut, awsMetadataURL+"/latest/api/token",
+		map[string]string{"X-aws-ec2-metadata-token-ttl-seconds": "60"})
+	if err != nil {
+		return nil, err
+	}
+	auth := map[string]string{"X-aws-ec2-metadata-token": string(token)}
+
+	body, err := fetchMetadata(ctx, client, http.MethodGet,
+		awsMetadataURL+"/latest/dynamic/instance-identity/document", auth)
+	if err != nil {
+		return nil, err
+	}
+	var doc struct {
+		Region           string `json:"region"`
+		AvailabilityZone string `json:"availabilityZone"`
+		InstanceType     string `json:"instanceType"`
+		InstanceID       string `json:"instanceId"`
+	}
+	if err := json.Unmarshal(body, &doc); err != nil || doc.InstanceID == "" {
+		return nil, errors.New("invalid aws identity document")
+	}
+
+	info := &CloudInfo{
+		Region:       doc.Region,
+		Zone:         doc.AvailabilityZone,
+		InstanceType: doc.InstanceType,
+		InstanceID:   doc.InstanceID,
+	}
+	// "spot" or "on-demand"; missing on some instance types, so ignore errors
+	if lifecycle, err := fetchMetadata(ctx, client, http.MethodGet,
+		awsMetadataURL+"/latest/meta-data/instance-life-cycle", auth); err == nil {
+		info.Spot = strings.TrimSpace(string(lifecycle)) == "spot"
+	}
+	return info, nil
+}
+
+// detectGCP reads the instance tree in one recursive call
+func detectGCP(ctx context.Context, client *http.Client) (*CloudInfo, error) {
+	body, err := fetchMetadata(ctx, client, http.MethodGet,
+		gcpMetadataURL+"/computeMetadata/v1/instance/?recursive=true",
+		map[string]string{"Metadata-Flavor": "Google"})
+	if err != nil {
+		return nil, err
+	}
+	var doc struct {
+		ID          json.Number `json:"id"`
+		Zone        string      `json:"zone"`        // projects/123/zones/us-central1-a
+		MachineType string      `json:"machineType"` // projects/123/machineTypes/e2-medium
+		Scheduling  struct {
+			Preemptible       string `json:"preemptible"`       // "TRUE" / "FALSE"
+			ProvisioningModel string `json:"provisioningModel"` // "SPOT" / "STANDARD"
+		} `json:"scheduling"`
+	}
+	if err := json.Unmarshal(body, &doc); err != nil || doc.Zone == "" {
+		return nil, errors.New("invalid gcp instance metadata")
+	}
+
+	zone := path.Base(doc.Zone)
+	info := &CloudInfo{
+		Zone:         zone,
+		InstanceType: path.Base(doc.MachineType),
+		InstanceID:   doc.ID.String(),
+		Spot:         doc.Scheduling.Preemptible == "TRUE" || doc.Scheduling.ProvisioningModel == "SPOT",
+	}
+	// Region is the zone minus its last "-x" suffix: us-central1-a -> us-central1
+	if i := strings.LastIndex(zone, "-"); i > 0 {
+		info.Region = zone[:i]
+	}
+	return info, nil
+}
+
+// detectAzure reads the compute section of the instance metadata
+func detectAzure(ctx context.Context, client *http.Client) (*CloudInfo, error) {
+	body, err := fetchMetadata(ctx, client, http.MethodGet,
+		azureMetadataURL+"/metadata/instance/compute?api-version=2021-02-01&format=json",
+		map[string]string{"Metadata": "true"})
+	if err != nil {
+		return nil, err
+	}
+	var doc struct {
+		Location string `json:"location"`
+		Zone     string `json:"zone"`
+		VMSize   string `json:"vmSize"`
+		VMID     string `json:"vmId"`
+		Priority string `json:"priority"` // "Regular", "Spot", "Low"
+	}
+	if err := json.Unmarshal(body, &doc); err != nil || doc.VMID == "" {
+		return nil, errors.New("invalid azure instance metadata")
+	}
+
+	return &CloudInfo{
+		Region:       doc.Location,
+		Zone:         doc.Zone,
+		InstanceType: doc.VMSize,
+		InstanceID:   doc.VMID,
+		Spot:         doc.Priority == "Spot" || doc.Priority == "Low",
+	}, nil
+}
+
+// cloudHandler handles GET /api/system/cloud
+func cloudHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, `{"error":"method not allowed"}`, http.StatusMethodNotAllowed)
+		return
+	}
+
+	info := CloudInfo{}
+	if envBool("CLOUD_METADATA", false) {
+		info = detectCloud(envDuration("CLOUD_METADATA_TIMEOUT", time.Second))
+	}
+
+	writeResponse(w, r, http.StatusOK, info)
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// useCloudMetadataServers points all providers at test servers and clears the cache
+func useCloudMetadataServers(t *testing.T, aws, gcp, azure string) {
+	t.Helper()
+	oldAWS, oldGCP, oldAzure := awsMetadataURL, gcpMetadataURL, azureMetadataURL
+	awsMetadataURL, gcpMetadataURL, azureMetadataURL = aws, gcp, azure
+	cloudCached = nil
+	t.Cleanup(func() {
+		awsMetadataURL, gcpMetadataURL, azureMetadataURL = oldAWS, oldGCP, oldAzure
+		cloudCached = nil
+	})
+}
+
+func TestCloud_DetectsAWS(t *testing.T) {
+	aws := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == "PUT" && r.URL.Path == "/latest/api/token":
+			w.Write([]byte("tok"))
+		case r.Header.Get("X-aws-ec2-metadata-token") != "tok":
+			w.WriteHeader(http.StatusUnauthorized)
+		case r.URL.Path == "/latest/dynamic/instance-identity/document":
+			w.Write([]byte(`{"region":"us-east-1","availabilityZone":"us-east-1a","instanceType":"t3.small","instanceId":"i-123"}`))
+		case r.URL.Path == "/latest/meta-data/instance-life-cycle":
+			w.Write([]byte("spot"))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer aws.Close()
+	none := httptest.NewServer(http.NotFoundHandler())
+	defer none.Close()
+	useCloudMetadataServers(t, aws.URL, none.URL, none.URL)
+
+	info := detectCloud(time.Second)
+
+	want := CloudInfo{Enabled: true, Detected: true, Provider: "aws", Region: "us-east-1",
+		Zone: "us-east-1a", InstanceType: "t3.small", InstanceID: "i-123", Spot: true}
+	if info != want {
+		t.Errorf("got %+v, want %+v", info, want)
+	}
+}
+
+func TestCloud_DetectsGCP(t *testing.T) {
+	gcp := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Metadata-Flavor") != "Google" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		w.Write([]byte(`{"id":4520031799277581759,"zone":"projects/1/zones/us-central1-a",
+			"machineType":"projects/1/machineTypes/e2-medium","scheduling":{"preemptible":"FALSE","provisioningModel":"SPOT"}}`))
+	}))
+	defer gcp.Close()
+	none := httptest.NewServer(http.NotFoundHandler())
+	defer none.Close()
+	useCloudMetadataServers(t, none.URL, gcp.URL, none.URL)
+
+	info := detectCloud(time.Second)
+
+	want := CloudInfo{Enabled: true, Detected: true, Provider: "gcp", Region: "us-central1",
+		Zone: "us-central1-a", InstanceType: "e2-medium", InstanceID: "4520031799277581759", Spot: true}
+	if info != want {
+		t.Errorf("got %+v, want %+v", info, want)
+	}
+}
+
+func TestCloud_DetectsAzure(t *testing.T) {
+	azure := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Metadata") != "true" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		w.Write([]byte(`{"location":"westeurope","zone":"2","vmSize":"Standard_B2s","vmId":"abc-def","priority":"Regular"}`))
+	}))
+	defer azure.Close()
+	none := httptest.NewServer(http.NotFoundHandler())
+	defer none.Close()
+	useCloudMetadataServers(t, none.URL, none.URL, azure.URL)
+
+	info := detectCloud(time.Second)
+
+	want := CloudInfo{Enabled: true, Detected: true, Provider: "azure", Region: "westeurope",
+		Zone: "2", InstanceType: "Standard_B2s", InstanceID: "abc-def"}
+	if info != want {
+		t.Errorf("got %+v, want %+v", info, want)
+	}
+}
+
+func TestCloud_NotDetected(t *testing.T) {
+	none := httptest.NewServer(http.NotFoundHandler())
+	defer none.Close()
+	useCloudMetadataServers(t, none.URL, none.URL, none.URL)
+
+	info := detectCloud(time.Second)
+
+	if !info.Enabled || info.Detected || info.Provider != "" {
+		t.Errorf("expected enabled but not detected, got %+v", info)
+	}
+	if cloudCached != nil {
+		t.Error("expected a failed detection not to be cached")
+	}
+}
+
+func TestCloudHandler_DisabledByDefault(t *testing.T) {
+	t.Setenv("CLOUD_METADATA", "")
+	rr := httptest.NewRecorder()
+	cloudHandler(rr, httptest.NewRequest("GET", "/api/system/cloud", nil))
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", rr.Code)
+	}
+	if body := rr.Body.String(); body != "{\"enabled\":false,\"detected\":false,\"spot\":false}\n" {
+		t.Errorf("unexpected body: %s", body)
+	}
+}
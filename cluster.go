@@ -0,0 +1,619 @@
+package main
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	badger "github.com/dgraph-io/badger/v4"
+)
+
+// =============================================================================
+// Cluster Mode: Leader Election and Write Forwarding
+// =============================================================================
+//
+// Scale demo-app to three replicas and each one gets its own BadgerDB: an item
+// created through pod A doesn't exist on pod B, and the dashboard flickers
+// depending on which pod the load balancer picks. Cluster mode makes the
+// replicas behave like one app without switching to a shared database:
+//
+//	           writes                  reads
+//	client ──> follower ──proxy──> leader        client ──> any replica (local DB)
+//	                    <──pull changes──┘
+//
+//  1. Election. Every CLUSTER_HEARTBEAT each replica asks every peer for
+//     GET /api/cluster. The reachable peer with the newest data is the
+//     leader (everyone sees the same answers, so everyone agrees). If the
+//     leader stops answering, the follower that replicated the most takes
+//     over. See electLeader for what "newest" means.
+//  2. Forwarding. Followers proxy item writes (and everything touching jobs
+//     or admin data operations) to the leader.
+//  3. Replication. Followers pull item changes from the leader with Badger's
+//     incremental backup (db.Backup "since" a version, which includes
+//     deletes) and load them into their own database. Reads are local, so a
+//     follower can lag by up to one heartbeat — it pulls right away after
+//     forwarding a write, so clients usually read their own writes.
+//  4. Display panel. Not in the database, so it's synced separately
+//     (displaysync.go).
+//
+// Peers come from CLUSTER_PEERS (a list of URLs, including this replica) or
+// CLUSTER_PEERS_DNS (a Kubernetes headless service, resolved every heartbeat).
+// A peer protocol instead of a Kubernetes Lease means no RBAC and it works in
+// Docker Compose too. The price: during a network partition both sides elect
+// a leader. That's fine for demos; use a real database for anything else.
+
+// Headers used between replicas
+const (
+	// Set on proxied requests so the leader never forwards them again
+	clusterForwardedHeader = "X-Demo-Forwarded-By"
+	// Replication response headers
+	replicationVersionHeader    = "X-Replication-Version"
+	replicationGenerationHeader = "X-Replication-Generation"
+	replicationFullHeader       = "X-Replication-Full"
+	replicationTermHeader       = "X-Replication-Term"
+)
+
+// Key prefixes copied from the leader. Jobs, counters, sequences, idempotency
+// records, and migration metadata stay local: jobs run on the leader
+// (requests are forwarded there), counters ask the leader (counters.go), and
+// the rest is per-database bookkeeping.
+var replicatedPrefixes = []string{
+	itemKeyPrefix, nameIndexPrefix, categoryIndexPrefix, attachmentKeyPrefix, linkKeyPrefix,
+	kvKeyPrefix, displaySchemaKey, displayTemplateKey, brandingKey, i18nKeyPrefix, itemsVersionKey,
+	tenantKeyPrefix, tenantRegistryPrefix, aggregatesKeyPrefix, itemTimeseriesPrefix,
+}
+
+// dataGeneration is bumped whenever the items are replaced wholesale (reset,
+// restore). DropPrefix leaves no delete markers for an incremental pull to
+// find, so followers compare generations and start over when it changes.
+var dataGeneration atomic.Int64
+
+// clusterNode is this replica's view of the cluster
+type clusterNode struct {
+	id        string   // random per process, identifies us in peer responses
+	peers     []string // static CLUSTER_PEERS URLs
+	peersDNS  string   // CLUSTER_PEERS_DNS name
+	peersPort string
+	client    *http.Client
+
+	mu        sync.RWMutex
+	leaderURL string // "" until an election succeeds
+	isLeader  bool
+	selfURL   string // our own entry in the peer list, once seen
+	healthy   []string
+	proxy     *httputil.ReverseProxy
+
+	// term numbers leaderships: a new leader takes one past the highest it
+	// has seen. A follower has the term of the leader it last pulled from.
+	term uint64
+
+	// Replication position on a follower
+	replicatedVersion    uint64
+	replicatedGeneration string
+
+	pullNow chan struct{} // nudges the loop to pull before the next heartbeat
+}
+
+// Active cluster, nil unless CLUSTER_PEERS or CLUSTER_PEERS_DNS is set
+var cluster *clusterNode
+
+// newClusterNode creates the cluster state. It starts as a follower in term
+// 0, so a replica (re)joining with old data can't win an election against
+// peers that kept going without it; it has to catch up first. A single
+// replica without peers becomes leader at its first election.
+func newClusterNode(peers []string, peersDNS, port string) *clusterNode {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return &clusterNode{
+		id:        hex.EncodeToString(b),
+		peers:     peers,
+		peersDNS:  peersDNS,
+		peersPort: port,
+		client:    newOutboundClient(2 * time.Second), // outbound.go
+		pullNow:   make(chan struct{}, 1),
+	}
+}
+
+// start runs elections (and replication, as a follower) every heartbeat
+func (c *clusterNode) start(heartbeat time.Duration) {
+	go func() {
+		ticker := time.NewTicker(heartbeat)
+		defer ticker.Stop()
+		for {
+			c.elect()
+			if leader, isLeader := c.leader(); !isLeader && leader != "" {
+				if err := c.pull(leader); err != nil {
+					slog.Warn("cluster replication failed", "leader", leader, "error", err)
+				}
+				if err := c.syncDisplay(leader); err != nil {
+					slog.Warn("cluster display sync failed", "leader", leader, "error", err)
+				}
+			}
+
+			select {
+			case <-ticker.C:
+			case <-c.pullNow:
+			}
+		}
+	}()
+}
+
+// leader returns the current leader URL and whether that's us
+func (c *clusterNode) leader() (string, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.leaderURL, c.isLeader
+}
+
+// discoverPeers returns the peer URLs: the static list plus one URL per
+// address behind CLUSTER_PEERS_DNS
+func (c *clusterNode) discoverPeers() []string {
+	urls := append([]string(nil), c.peers...)
+	if c.peersDNS != "" {
+		addrs, err := net.LookupHost(c.peersDNS)
+		if err != nil {
+			slog.Warn("cluster peer lookup failed", "name", c.peersDNS, "error", err)
+		}
+		for _, addr := range addrs {
+			urls = append(urls, "http://"+net.JoinHostPort(addr, c.peersPort))
+		}
+	}
+	return urls
+}
+
+// elect probes every peer and picks the one with the newest data as leader
+func (c *clusterNode) elect() {
+	urls := c.discoverPeers()
+
+	// Probe in parallel so one slow peer doesn't delay the election
+	statuses := make([]*clusterStatus, len(urls))
+	var wg sync.WaitGroup
+	for i, u := range urls {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			statuses[i] = c.probe(u)
+		}()
+	}
+	wg.Wait()
+
+	var (
+		healthy    []string
+		candidates []clusterCandidate
+		maxTerm    uint64
+	)
+	selfURL := ""
+	for i, u := range urls {
+		status := statuses[i]
+		if status == nil {
+			continue
+		}
+		healthy = append(healthy, u)
+		candidates = append(candidates, clusterCandidate{
+			URL: u, Term: status.Term, Version: status.DataVersion, Leader: status.Role == "leader",
+		})
+		maxTerm = max(maxTerm, status.Term)
+		if status.NodeID == c.id {
+			selfURL = u
+		}
+	}
+	leaderURL, isLeader := electLeader(candidates, selfURL)
+
+	c.mu.Lock()
+	changed := leaderURL != c.leaderURL || isLeader != c.isLeader
+	wasLeader := c.isLeader
+	c.healthy = healthy
+	c.selfURL = selfURL
+	if changed {
+		c.leaderURL, c.isLeader = leaderURL, isLeader
+		c.proxy = nil
+		if !isLeader {
+			c.proxy = newLeaderProxy(leaderURL)
+		}
+	}
+	if isLeader && !wasLeader {
+		// Our data is now the newest there is, ahead of every other
+		// leader's seen so far
+		c.term = max(c.term, maxTerm) + 1
+	}
+	c.mu.Unlock()
+
+	if !changed {
+		return
+	}
+	role := "follower"
+	if isLeader {
+		role = "leader"
+	}
+	slog.Info("cluster leader changed", "role", role, "leader", leaderURL, "healthy_peers", len(healthy), "term", c.currentTerm())
+
+	// A promoted follower continues the ID sequence after the items it
+	// replicated, otherwise its next item would reuse an ID
+	if isLeader && !wasLeader {
+		if err := advanceItemSequence(); err != nil {
+			slog.Error("failed to advance item sequence after promotion", "error", err)
+		}
+	}
+}
+
+// currentTerm returns the term of the data we have
+func (c *clusterNode) currentTerm() uint64 {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.term
+}
+
+// dataVersion is how far into its term our data goes: the database version
+// on a leader, and the leader's version we replicated up to on a follower
+func (c *clusterNode) dataVersion() uint64 {
+	if c.isLeader {
+		return db.MaxVersion()
+	}
+	return c.replicatedVersion
+}
+
+// clusterStatus is the part of a peer's GET /api/cluster an election uses
+type clusterStatus struct {
+	NodeID      string `json:"node_id"`
+	Role        string `json:"role"`
+	Term        uint64 `json:"term"`
+	DataVersion uint64 `json:"data_version"`
+}
+
+// probe asks a peer for its status (nil if unreachable)
+func (c *clusterNode) probe(peerURL string) *clusterStatus {
+	resp, err := c.client.Get(strings.TrimRight(peerURL, "/") + "/api/cluster")
+	if err != nil {
+		return nil
+	}
+	defer resp.Body.Close()
+	var status clusterStatus
+	if resp.StatusCode != http.StatusOK || json.NewDecoder(resp.Body).Decode(&status) != nil || status.NodeID == "" {
+		return nil
+	}
+	return &status
+}
+
+// clusterCandidate is a reachable peer in an election
+type clusterCandidate struct {
+	URL     string
+	Term    uint64 // term of the data it has
+	Version uint64 // how far into that term
+	Leader  bool   // leading now
+}
+
+// newerThan orders candidates for electLeader
+func (a clusterCandidate) newerThan(b clusterCandidate) bool {
+	switch {
+	case a.Term != b.Term:
+		return a.Term > b.Term
+	case a.Version != b.Version:
+		return a.Version > b.Version
+	case a.Leader != b.Leader:
+		return a.Leader // a caught-up follower doesn't unseat the leader
+	}
+	return a.URL < b.URL
+}
+
+// electLeader picks the candidate with the newest data: the highest term,
+// then the highest version in it. Terms only grow, so a replica that
+// restarted with old data (term 0) never wins against one that kept
+// accepting writes; it follows, and its stale copy is replaced. Ties go to
+// the current leader, then the lowest URL. With no candidates we can't see
+// anyone (not even ourselves, e.g. while starting up), so we act alone.
+func electLeader(candidates []clusterCandidate, selfURL string) (leaderURL string, isLeader bool) {
+	if len(candidates) == 0 {
+		return "", true
+	}
+	best := candidates[0]
+	for _, c := range candidates[1:] {
+		if c.newerThan(best) {
+			best = c
+		}
+	}
+	return best.URL, best.URL == selfURL
+}
+
+// newLeaderProxy builds a reverse proxy to the leader. Unreachable leaders
+// answer 502 in the app's JSON error format.
+func newLeaderProxy(leaderURL string) *httputil.ReverseProxy {
+	target, err := url.Parse(leaderURL)
+	if err != nil {
+		return nil
+	}
+	proxy := httputil.NewSingleHostReverseProxy(target)
+	proxy.ErrorHandler = func(w http.ResponseWriter, r *http.Request, err error) {
+		logHandlerError(r.Context(), "cluster", "forward", "failed to forward request to leader", "leader", leaderURL, "error", err)
+		http.Error(w, `{"error":"leader unavailable"}`, http.StatusBadGateway)
+	}
+	return proxy
+}
+
+// =============================================================================
+// Forwarding Middleware
+// =============================================================================
+
+// leaderMiddleware sends writes (anything but GET/HEAD/OPTIONS) to the
+// leader when this replica is a follower. Reads are served locally.
+func leaderMiddleware(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions:
+			next(w, r)
+		default:
+			leaderOnlyMiddleware(next)(w, r)
+		}
+	}
+}
+
+// leaderOnlyMiddleware sends every request to the leader when this replica
+// is a follower — for data that isn't replicated, like jobs
+func leaderOnlyMiddleware(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if cluster == nil || r.Header.Get(clusterForwardedHeader) != "" {
+			next(w, r)
+			return
+		}
+
+		cluster.mu.RLock()
+		proxy, isLeader := cluster.proxy, cluster.isLeader
+		cluster.mu.RUnlock()
+		if isLeader || proxy == nil {
+			next(w, r)
+			return
+		}
+
+		r.Header.Set(clusterForwardedHeader, cluster.id)
+		proxy.ServeHTTP(w, r)
+
+		// Pull the change now instead of at the next heartbeat
+		select {
+		case cluster.pullNow <- struct{}{}:
+		default: // a pull is already pending
+		}
+	}
+}
+
+// =============================================================================
+// Replication
+// =============================================================================
+
+// pull fetches changes from the leader and applies them
+func (c *clusterNode) pull(leaderURL string) error {
+	c.mu.RLock()
+	since, generation := c.replicatedVersion, c.replicatedGeneration
+	c.mu.RUnlock()
+
+	query := url.Values{}
+	query.Set("since", strconv.FormatUint(since, 10))
+	query.Set("generation", generation)
+	req, err := http.NewRequest(http.MethodGet, strings.TrimRight(leaderURL, "/")+"/api/cluster/replicate?"+query.Encode(), nil)
+	if err != nil {
+		return err
+	}
+	// The replicate endpoint is an admin endpoint; replicas share ADMIN_TOKEN
+	if token := secret("ADMIN_TOKEN"); token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	setInternalKey(req)
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("leader answered %d", resp.StatusCode)
+	}
+
+	full := resp.Header.Get(replicationFullHeader) == "true"
+	if err := loadReplicationStream(db, resp.Body, full); err != nil {
+		return err
+	}
+
+	version, _ := strconv.ParseUint(resp.Header.Get(replicationVersionHeader), 10, 64)
+	c.mu.Lock()
+	if version > c.replicatedVersion || full {
+		c.replicatedVersion = version
+	}
+	c.replicatedGeneration = resp.Header.Get(replicationGenerationHeader)
+	if term, err := strconv.ParseUint(resp.Header.Get(replicationTermHeader), 10, 64); err == nil && !c.isLeader {
+		c.term = term
+	}
+	c.mu.Unlock()
+
+	if full || version > since {
+		return syncItemsGauge()
+	}
+	return nil
+}
+
+// loadReplicationStream applies a replication stream to target. A full
+// stream replaces the replicated keys instead of adding to them.
+func loadReplicationStream(target *badger.DB, r io.Reader, full bool) error {
+	if full {
+		prefixes := make([][]byte, len(replicatedPrefixes))
+		for i, p := range replicatedPrefixes {
+			prefixes[i] = []byte(p)
+		}
+		if err := target.DropPrefix(prefixes...); err != nil {
+			return err
+		}
+	}
+	return timeDBOp("replicate_load", itemKeyPrefix, func() error {
+		return target.Load(r, 256)
+	})
+}
+
+// replicationGeneration identifies this leader's current data. A new process
+// (restart, or another replica becoming leader) or a reset changes it.
+func (c *clusterNode) replicationGeneration() string {
+	return c.id + "-" + strconv.FormatInt(dataGeneration.Load(), 10)
+}
+
+// writeReplicationStream writes the replicated keys changed after version
+// since to w and returns the highest version written
+func writeReplicationStream(w io.Writer, since uint64) (uint64, error) {
+	stream := db.NewStream()
+	stream.LogPrefix = "cluster.replicate"
+	stream.SinceTs = since
+	stream.ChooseKey = func(item *badger.Item) bool {
+		for _, p := range replicatedPrefixes {
+			if bytes.HasPrefix(item.Key(), []byte(p)) {
+				return true
+			}
+		}
+		return false
+	}
+	return stream.Backup(w, since)
+}
+
+// advanceItemSequence moves the item ID sequence past the highest stored
+// item ID. Sequences store their next value as a big-endian uint64 under
+// their key; release ours, rewrite the key, and lease again. Also used when
+// a standby is promoted (standby.go).
+func advanceItemSequence() error {
+	var maxID int64
+	err := dbView("item_max_id", itemKeyPrefix, func(txn *badger.Txn) error {
+		opts := badger.DefaultIteratorOptions
+		opts.PrefetchValues = false
+		opts.Reverse = true
+		it := txn.NewIterator(opts)
+		defer it.Close()
+
+		// Reverse iteration starts at the largest key <= the seek key;
+		// "item:" + 0xFF sorts after every zero-padded item key
+		prefix := []byte(itemKeyPrefix)
+		it.Seek(append(append([]byte(nil), prefix...), 0xFF))
+		if it.ValidForPrefix(prefix) {
+			id, err := strconv.ParseInt(strings.TrimPrefix(string(it.Item().Key()), itemKeyPrefix), 10, 64)
+			if err != nil {
+				return err
+			}
+			maxID = id
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	// Item creates wait while the sequence is replaced (store.go)
+	return replaceItemSequence(func() error {
+		return advanceStoredSequence(uint64(maxID) + 1)
+	})
+}
+
+// advanceStoredSequence moves the stored "seq:items" value up to next,
+// keeping it if it's already higher; call it with the sequence released
+func advanceStoredSequence(next uint64) error {
+	return dbUpdate("item_sequence", "seq:items", func(txn *badger.Txn) error {
+		entry, err := txn.Get([]byte("seq:items"))
+		if err == nil {
+			err = entry.Value(func(val []byte) error {
+				if len(val) == 8 && binary.BigEndian.Uint64(val) > next {
+					next = binary.BigEndian.Uint64(val)
+				}
+				return nil
+			})
+		}
+		if err != nil && !errors.Is(err, badger.ErrKeyNotFound) {
+			return err
+		}
+		var buf [8]byte
+		binary.BigEndian.PutUint64(buf[:], next)
+		return txn.Set([]byte("seq:items"), buf[:])
+	})
+}
+
+// =============================================================================
+// Handlers
+// =============================================================================
+
+// clusterHandler handles GET /api/cluster: this replica's view of the
+// cluster. Peers also use it to find each other during elections.
+func clusterHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, `{"error":"method not allowed"}`, http.StatusMethodNotAllowed)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if cluster == nil {
+		json.NewEncoder(w).Encode(map[string]any{"enabled": false})
+		return
+	}
+
+	cluster.mu.RLock()
+	defer cluster.mu.RUnlock()
+	role := "follower"
+	if cluster.isLeader {
+		role = "leader"
+	}
+	json.NewEncoder(w).Encode(map[string]any{
+		"enabled":            true,
+		"node_id":            cluster.id,
+		"role":               role,
+		"leader":             cluster.leaderURL,
+		"self":               cluster.selfURL,
+		"healthy_peers":      cluster.healthy,
+		"term":               cluster.term,
+		"data_version":       cluster.dataVersion(),
+		"replicated_version": cluster.replicatedVersion,
+	})
+}
+
+// replicateHandler handles GET /api/cluster/replicate?since=N&generation=G.
+// It answers with the item changes after version N in Badger's backup format.
+// When G isn't the current generation the follower's copy is from an older
+// leader or before a reset, so it gets everything (X-Replication-Full: true).
+func replicateHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, `{"error":"method not allowed"}`, http.StatusMethodNotAllowed)
+		return
+	}
+	if cluster == nil {
+		http.Error(w, `{"error":"cluster mode is not enabled"}`, http.StatusNotFound)
+		return
+	}
+
+	since, _ := strconv.ParseUint(r.URL.Query().Get("since"), 10, 64)
+	generation := cluster.replicationGeneration()
+	full := r.URL.Query().Get("generation") != generation
+	if full {
+		since = 0
+	}
+
+	// Buffered so the version can go in a header before the body
+	var buf bytes.Buffer
+	version, err := writeReplicationStream(&buf, since)
+	if err != nil {
+		logHandlerError(r.Context(), "cluster", "database", "failed to build replication stream", "error", err)
+		http.Error(w, `{"error":"database error"}`, http.StatusInternalServerError)
+		return
+	}
+	if version < since {
+		version = since // nothing new
+	}
+
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Header().Set(replicationVersionHeader, strconv.FormatUint(version, 10))
+	w.Header().Set(replicationGenerationHeader, generation)
+	w.Header().Set(replicationFullHeader, strconv.FormatBool(full))
+	w.Header().Set(replicationTermHeader, strconv.FormatUint(cluster.currentTerm(), 10))
+	w.Write(buf.Bytes())
+}
@@ -0,0 +1,321 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	badger "github.com/dgraph-io/badger/v4"
+)
+
+// withCluster installs a cluster node for one test
+func withCluster(t *testing.T, node *clusterNode) {
+	t.Helper()
+	prev := cluster
+	cluster = node
+	t.Cleanup(func() { cluster = prev })
+}
+
+func TestElectLeader(t *testing.T) {
+	a := clusterCandidate{URL: "http://a:8080"}
+	b := clusterCandidate{URL: "http://b:8080"}
+	c := clusterCandidate{URL: "http://c:8080"}
+	at := func(p clusterCandidate, term, version uint64, leader bool) clusterCandidate {
+		p.Term, p.Version, p.Leader = term, version, leader
+		return p
+	}
+	tests := []struct {
+		name       string
+		candidates []clusterCandidate
+		self       string
+		wantLeader string
+		wantIsUs   bool
+	}{
+		{"nobody reachable", nil, "", "", true},
+		{"fresh cluster, we are lowest", []clusterCandidate{b, a}, a.URL, a.URL, true},
+		{"fresh cluster, someone else is lowest", []clusterCandidate{b, a}, b.URL, a.URL, false},
+		{"we are not reachable", []clusterCandidate{c}, "", c.URL, false},
+		{"newer term wins over lower URL", []clusterCandidate{at(a, 1, 900, false), at(b, 2, 10, true)}, a.URL, b.URL, false},
+		{"restarted old leader follows", []clusterCandidate{a, at(b, 2, 10, true)}, a.URL, b.URL, false},
+		{"follower that replicated most takes over", []clusterCandidate{at(a, 2, 40, false), at(c, 2, 50, false)}, c.URL, c.URL, true},
+		{"caught-up follower doesn't unseat the leader", []clusterCandidate{at(a, 2, 50, false), at(b, 2, 50, true)}, a.URL, b.URL, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			leader, isUs := electLeader(tt.candidates, tt.self)
+			if leader != tt.wantLeader || isUs != tt.wantIsUs {
+				t.Errorf("electLeader = (%q, %v), want (%q, %v)", leader, isUs, tt.wantLeader, tt.wantIsUs)
+			}
+		})
+	}
+}
+
+// pullInto fetches a replication stream from srv and loads it into target,
+// returning the new version and generation
+func pullInto(t *testing.T, srv *httptest.Server, target *badger.DB, since, generation string) (string, string, bool) {
+	t.Helper()
+	resp, err := http.Get(srv.URL + "/api/cluster/replicate?since=" + since + "&generation=" + generation)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("replicate status = %d", resp.StatusCode)
+	}
+	full := resp.Header.Get(replicationFullHeader) == "true"
+	if err := loadReplicationStream(target, resp.Body, full); err != nil {
+		t.Fatalf("loadReplicationStream: %v", err)
+	}
+	return resp.Header.Get(replicationVersionHeader), resp.Header.Get(replicationGenerationHeader), full
+}
+
+// itemKeysIn lists the item keys stored in target
+func itemKeysIn(t *testing.T, target *badger.DB) []string {
+	t.Helper()
+	var keys []string
+	target.View(func(txn *badger.Txn) error {
+		it := txn.NewIterator(badger.DefaultIteratorOptions)
+		defer it.Close()
+		prefix := []byte(itemKeyPrefix)
+		for it.Seek(prefix); it.ValidForPrefix(prefix); it.Next() {
+			keys = append(keys, string(it.Item().KeyCopy(nil)))
+		}
+		return nil
+	})
+	return keys
+}
+
+func TestCluster_Replication(t *testing.T) {
+	srv := newTestServer(t)
+	withCluster(t, newClusterNode(nil, "", "0"))
+
+	follower, err := initStore(":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer follower.Close()
+
+	one := createTestItem(t, srv, `{"name":"one"}`)
+	two := createTestItem(t, srv, `{"name":"two"}`)
+
+	// First pull: unknown generation, so everything
+	version, generation, full := pullInto(t, srv, follower, "0", "")
+	if !full {
+		t.Error("first pull should be full")
+	}
+	if keys := itemKeysIn(t, follower); len(keys) != 2 {
+		t.Fatalf("follower has %v after first pull, want 2 items", keys)
+	}
+
+	// Incremental pull picks up a delete and a create
+	doRequest(t, srv, http.MethodDelete, fmt.Sprintf("/api/items/%d", one.ID), "")
+	three := createTestItem(t, srv, `{"name":"three"}`)
+	version, generation, full = pullInto(t, srv, follower, version, generation)
+	if full {
+		t.Error("second pull should be incremental")
+	}
+	want := []string{string(itemKey(two.ID)), string(itemKey(three.ID))}
+	if keys := itemKeysIn(t, follower); strings.Join(keys, ",") != strings.Join(want, ",") {
+		t.Fatalf("follower has %v after incremental pull, want %v", keys, want)
+	}
+
+	// Nothing changed: an empty pull keeps the version
+	if v, _, _ := pullInto(t, srv, follower, version, generation); v != version {
+		t.Errorf("empty pull version = %s, want %s", v, version)
+	}
+
+	// A reset leaves no delete markers, so the generation changes and the
+	// follower gets a full copy (of nothing)
+	if err := resetStore(); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, full = pullInto(t, srv, follower, version, generation); !full {
+		t.Error("pull after reset should be full")
+	}
+	if keys := itemKeysIn(t, follower); len(keys) != 0 {
+		t.Errorf("follower has %v after reset, want none", keys)
+	}
+}
+
+func TestCluster_ForwardsWritesToLeader(t *testing.T) {
+	srv := newTestServer(t)
+
+	var (
+		mu       sync.Mutex
+		received []string
+	)
+	leader := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		received = append(received, r.Method+" "+r.URL.Path+" "+r.Header.Get(clusterForwardedHeader))
+		mu.Unlock()
+		w.WriteHeader(http.StatusCreated)
+		io.WriteString(w, `{"id":99,"name":"from-leader"}`)
+	}))
+	defer leader.Close()
+
+	node := newClusterNode(nil, "", "0")
+	node.isLeader = false
+	node.leaderURL = leader.URL
+	node.proxy = newLeaderProxy(leader.URL)
+	withCluster(t, node)
+
+	code, body := doRequest(t, srv, http.MethodPost, "/api/items", `{"name":"x"}`)
+	if code != http.StatusCreated || !strings.Contains(string(body), "from-leader") {
+		t.Errorf("POST /api/items = %d %s, want the leader's answer", code, body)
+	}
+
+	// Reads are local
+	code, body = doRequest(t, srv, http.MethodGet, "/api/items", "")
+	if code != http.StatusOK || string(bytes.TrimSpace(body)) != "[]" {
+		t.Errorf("GET /api/items = %d %s, want local empty list", code, body)
+	}
+
+	// Jobs aren't replicated, so even reads go to the leader
+	doRequest(t, srv, http.MethodGet, "/api/jobs", "")
+
+	mu.Lock()
+	defer mu.Unlock()
+	want := []string{"POST /api/items " + node.id, "GET /api/jobs " + node.id}
+	if strings.Join(received, "|") != strings.Join(want, "|") {
+		t.Errorf("leader received %q, want %q", received, want)
+	}
+}
+
+func TestCluster_LeaderUnavailable(t *testing.T) {
+	srv := newTestServer(t)
+
+	node := newClusterNode(nil, "", "0")
+	node.isLeader = false
+	node.leaderURL = "http://127.0.0.1:1"
+	node.proxy = newLeaderProxy(node.leaderURL)
+	withCluster(t, node)
+
+	code, body := doRequest(t, srv, http.MethodPost, "/api/items", `{"name":"x"}`)
+	if code != http.StatusBadGateway || !strings.Contains(string(body), "leader unavailable") {
+		t.Errorf("status = %d %s, want 502 leader unavailable", code, body)
+	}
+}
+
+func TestAdvanceItemSequence(t *testing.T) {
+	srv := newTestServer(t)
+
+	// Pretend item 500 arrived through replication
+	value, _ := json.Marshal(Item{ID: 500, Name: "replicated"})
+	err := db.Update(func(txn *badger.Txn) error {
+		return txn.Set(itemKey(500), value)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := advanceItemSequence(); err != nil {
+		t.Fatalf("advanceItemSequence: %v", err)
+	}
+	if item := createTestItem(t, srv, `{"name":"next"}`); item.ID != 501 {
+		t.Errorf("next item ID = %d, want 501", item.ID)
+	}
+}
+
+func TestAdvanceItemSequence_DuringCreates(t *testing.T) {
+	newTestServer(t)
+	checkCreatesDuring(t, advanceItemSequence) // reset_test.go
+}
+
+func TestClusterHandler(t *testing.T) {
+	srv := newTestServer(t)
+
+	withCluster(t, nil)
+	_, body := doRequest(t, srv, http.MethodGet, "/api/cluster", "")
+	if !strings.Contains(string(body), `"enabled":false`) {
+		t.Errorf("disabled cluster body = %s", body)
+	}
+
+	// A new node follows until an election says otherwise
+	node := newClusterNode(nil, "", "0")
+	withCluster(t, node)
+	_, body = doRequest(t, srv, http.MethodGet, "/api/cluster", "")
+	var status clusterStatus
+	json.Unmarshal(body, &status)
+	if status.NodeID != node.id || status.Role != "follower" || status.Term != 0 {
+		t.Errorf("new node status = %s", body)
+	}
+
+	// Alone, it leads, in a new term
+	node.elect()
+	if _, isLeader := node.leader(); !isLeader || node.currentTerm() != 1 {
+		t.Errorf("after electing alone: leader %v, term %d", isLeader, node.currentTerm())
+	}
+
+	// Probing ourselves finds our own ID, which is how elections find "self"
+	if got := node.probe(srv.URL); got == nil || got.NodeID != node.id || got.Role != "leader" || got.Term != 1 {
+		t.Errorf("probe = %+v, want our own status", got)
+	}
+}
+
+func TestCluster_RestartedLeaderRejoins(t *testing.T) {
+	srv := newTestServer(t)
+
+	// We were the leader, with the lowest URL, and restarted with the data
+	// we had then
+	createTestItem(t, srv, `{"name":"stale"}`)
+
+	// Meanwhile the other replica took over and accepted a write
+	peerStore, err := initStore(":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer peerStore.Close()
+	value, _ := json.Marshal(Item{ID: 42, Name: "written while we were down"})
+	if err := peerStore.Update(func(txn *badger.Txn) error { return txn.Set(itemKey(42), value) }); err != nil {
+		t.Fatal(err)
+	}
+	peerVersion := strconv.FormatUint(peerStore.MaxVersion(), 10)
+	peer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/cluster":
+			io.WriteString(w, `{"node_id":"peer","role":"leader","term":2,"data_version":`+peerVersion+`}`)
+		case "/api/cluster/replicate":
+			w.Header().Set(replicationVersionHeader, peerVersion)
+			w.Header().Set(replicationGenerationHeader, "peer-0")
+			w.Header().Set(replicationFullHeader, "true")
+			w.Header().Set(replicationTermHeader, "2")
+			peerStore.Backup(w, 0)
+		}
+	}))
+	defer peer.Close()
+	// "localhost" sorts after our "127.0.0.1", so we'd win on URL alone
+	peerURL := strings.Replace(peer.URL, "127.0.0.1", "localhost", 1)
+
+	node := newClusterNode([]string{srv.URL, peerURL}, "", "0")
+	withCluster(t, node)
+
+	node.elect()
+	if leader, isLeader := node.leader(); isLeader || leader != peerURL {
+		t.Fatalf("after restart: leader %q, us %v; want to follow %s", leader, isLeader, peerURL)
+	}
+
+	// Catching up replaces our stale copy with the leader's
+	if err := node.pull(peerURL); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{string(itemKey(42))}
+	if keys := itemKeysIn(t, db); strings.Join(keys, ",") != strings.Join(want, ",") {
+		t.Errorf("items after catching up = %v, want %v", keys, want)
+	}
+
+	// Caught up, we still don't take over
+	node.elect()
+	if leader, isLeader := node.leader(); isLeader || leader != peerURL {
+		t.Errorf("after catching up: leader %q, us %v", leader, isLeader)
+	}
+	if node.currentTerm() != 2 {
+		t.Errorf("term = %d, want the leader's 2", node.currentTerm())
+	}
+}
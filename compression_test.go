@@ -0,0 +1,99 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+
+	badger "github.com/dgraph-io/badger/v4"
+)
+
+// withItemCompression sets ITEM_COMPRESSION for one test
+func withItemCompression(t *testing.T, codec string) {
+	t.Helper()
+	prev := itemCompression
+	itemCompression = codec
+	t.Cleanup(func() { itemCompression = prev })
+}
+
+// storedItemValue reads an item's value as it sits in BadgerDB
+func storedItemValue(t *testing.T, id int64) []byte {
+	t.Helper()
+	var val []byte
+	err := db.View(func(txn *badger.Txn) error {
+		entry, err := txn.Get(itemKey(id))
+		if err != nil {
+			return err
+		}
+		val, err = entry.ValueCopy(nil)
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return val
+}
+
+func TestItemCompression_RoundTrip(t *testing.T) {
+	long := strings.Repeat("a very repetitive description ", 100)
+	for _, tc := range []struct {
+		codec  string
+		format byte
+	}{
+		{"snappy", valueFormatSnappy},
+		{"zstd", valueFormatZstd},
+	} {
+		t.Run(tc.codec, func(t *testing.T) {
+			srv := newTestServer(t)
+			withItemCompression(t, tc.codec)
+
+			big := createTestItem(t, srv, `{"name":"big","description":"`+long+`","tags":["x"]}`)
+			small := createTestItem(t, srv, `{"name":"small"}`)
+
+			val := storedItemValue(t, big.ID)
+			if val[0] != tc.format || len(val) >= len(long) {
+				t.Errorf("big item stored as %d bytes starting %#x, want compressed with %#x", len(val), val[0], tc.format)
+			}
+			if val := storedItemValue(t, small.ID); val[0] != '{' {
+				t.Errorf("small item stored as %q, want plain JSON", val)
+			}
+
+			// Reads are the same whatever the setting is now
+			itemCompression = "none"
+			code, body := doRequest(t, srv, http.MethodGet, "/api/items/"+strconv.FormatInt(big.ID, 10), "")
+			var got Item
+			json.Unmarshal(body, &got)
+			if code != http.StatusOK || got.Description != long || got.Name != "big" {
+				t.Errorf("GET = %d, name %q, %d description bytes", code, got.Name, len(got.Description))
+			}
+			_, body = doRequest(t, srv, http.MethodGet, "/api/items", "")
+			var list []Item
+			json.Unmarshal(body, &list)
+			if len(list) != 2 || list[0].Description != long {
+				t.Errorf("list = %d items", len(list))
+			}
+
+			// An update rewrites it plain; fsck is happy either way
+			doRequest(t, srv, http.MethodPut, "/api/items/"+strconv.FormatInt(big.ID, 10), `{"name":"big","description":"`+long+`"}`)
+			if val := storedItemValue(t, big.ID); val[0] != '{' {
+				t.Errorf("after update with compression off, stored as %#x...", val[0])
+			}
+			if report, err := runFsck(false); err != nil || !report.Clean || report.Items != 2 {
+				t.Errorf("fsck = %+v, %v", report, err)
+			}
+		})
+	}
+}
+
+func TestParseItemCompression(t *testing.T) {
+	for value, want := range map[string]string{"": "none", "off": "none", "ZSTD": "zstd", " snappy ": "snappy"} {
+		if got, err := parseItemCompression(value); err != nil || got != want {
+			t.Errorf("parseItemCompression(%q) = %q, %v, want %q", value, got, err, want)
+		}
+	}
+	if _, err := parseItemCompression("gzip"); err == nil {
+		t.Error("gzip should be rejected")
+	}
+}
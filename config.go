@@ -0,0 +1,95 @@
+package main
+
+import (
+	"log/slog"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// =============================================================================
+// Environment Variable Helpers
+// =============================================================================
+//
+// demo-app is configured entirely through environment variables.
+// These helpers read a variable with a default, so each feature doesn't
+// repeat the same "if val == "" { val = default }" dance.
+//
+// Invalid values log a warning and fall back to the default rather than
+// crashing — a typo in a demo environment shouldn't take the app down.
+
+// envString returns the variable's value, or def if unset/empty
+func envString(key, def string) string {
+	if val := os.Getenv(key); val != "" {
+		return val
+	}
+	return def
+}
+
+// envInt parses the variable as an integer
+func envInt(key string, def int) int {
+	val := os.Getenv(key)
+	if val == "" {
+		return def
+	}
+	n, err := strconv.Atoi(val)
+	if err != nil {
+		slog.Warn("invalid integer in environment, using default", "key", key, "value", val, "default", def)
+		return def
+	}
+	return n
+}
+
+// envBool parses the variable as a boolean ("true", "1", "false", "0", ...)
+func envBool(key string, def bool) bool {
+	val := os.Getenv(key)
+	if val == "" {
+		return def
+	}
+	b, err := strconv.ParseBool(val)
+	if err != nil {
+		slog.Warn("invalid boolean in environment, using default", "key", key, "value", val, "default", def)
+		return def
+	}
+	return b
+}
+
+// envFloat parses the variable as a floating-point number
+func envFloat(key string, def float64) float64 {
+	val := os.Getenv(key)
+	if val == "" {
+		return def
+	}
+	f, err := strconv.ParseFloat(val, 64)
+	if err != nil {
+		slog.Warn("invalid number in environment, using default", "key", key, "value", val, "default", def)
+		return def
+	}
+	return f
+}
+
+// envDuration parses the variable as a Go duration ("500ms", "30s", "5m")
+func envDuration(key string, def time.Duration) time.Duration {
+	val := os.Getenv(key)
+	if val == "" {
+		return def
+	}
+	d, err := time.ParseDuration(val)
+	if err != nil {
+		slog.Warn("invalid duration in environment, using default", "key", key, "value", val, "default", def.String())
+		return def
+	}
+	return d
+}
+
+// envList splits a comma-separated variable into trimmed, non-empty values
+func envList(key string) []string {
+	var out []string
+	for _, part := range strings.Split(os.Getenv(key), ",") {
+		if part = strings.TrimSpace(part); part != "" {
+			out = append(out, part)
+		}
+	}
+	return out
+}
@@ -0,0 +1,322 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// =============================================================================
+// Config Watching
+// =============================================================================
+//
+// Kubernetes updates a mounted ConfigMap or Secret in place (a few seconds
+// to a minute after "kubectl edit"), but an app that only reads its config
+// at startup never notices. The app watches its config files and reloads
+// just the part a change affects, so "edit the ConfigMap and watch the
+// behavior change" works without restarting the pod:
+//
+//	CONFIG_FILE     LOG_LEVEL and LOG_WEBHOOK_URL, overriding the env vars
+//	TLS_CERT_FILE   server certificate and key, used from the next handshake
+//	TLS_KEY_FILE
+//	ACCESS_POLICY_FILE
+//	RULES_FILE      replaces rules added through the admin API
+//	SECRETS_FILE    re-read now instead of at the next refresh (secrets.go)
+//
+// CONFIG_FILE is, like SECRETS_FILE, a directory with one file per setting
+// (a mounted ConfigMap) or a file of NAME=value lines:
+//
+//	kubectl create configmap demo-app-config --from-literal=LOG_LEVEL=info
+//	# mount it at /etc/demo-app/config and set CONFIG_FILE=/etc/demo-app/config
+//	kubectl edit configmap demo-app-config   # LOG_LEVEL: debug
+//	# a few seconds later: "config settings changed" names=[LOG_LEVEL]
+//
+// Files are checked every CONFIG_WATCH_INTERVAL by content, not with inotify:
+// Kubernetes swaps a symlink rather than writing the files, which inotify
+// watchers often miss, and a checksum also catches edits that keep the
+// modification time. A change that doesn't parse is logged and the running
+// config is kept. Mounts with subPath are never updated by Kubernetes, so
+// mount the whole volume.
+//
+// GET /api/admin/config-watch lists the watched files and when each was last
+// reloaded.
+
+// watchedFile is a component and the files it's reloaded from
+type watchedFile struct {
+	component  string
+	paths      []string
+	reload     func() error
+	sum        [sha256.Size]byte // contents last seen
+	reloads    int
+	lastReload time.Time
+	lastError  string
+}
+
+// configWatcher polls the watched files for changes
+type configWatcher struct {
+	mu       sync.Mutex
+	files    []*watchedFile
+	interval time.Duration
+}
+
+// Files watched for changes; empty until configureConfigWatch runs
+var configWatch = &configWatcher{}
+
+// Settings from CONFIG_FILE, nil unless it's set
+var configFile struct {
+	mu     sync.RWMutex
+	path   string
+	values map[string]string
+}
+
+// The log webhook (webhook.go), so a CONFIG_FILE change can point it
+// somewhere else; nil when it can't be turned on at runtime
+var logWebhook *webhookHandler
+
+// configSetting returns a reloadable setting: from CONFIG_FILE when it has
+// one, otherwise the environment variable of the same name
+func configSetting(name string) string {
+	configFile.mu.RLock()
+	value, ok := configFile.values[name]
+	configFile.mu.RUnlock()
+	if ok {
+		return value
+	}
+	return os.Getenv(name)
+}
+
+// loadConfigFile reads CONFIG_FILE; called at startup before the logger is
+// built, and again by the watcher
+func loadConfigFile(path string) error {
+	// Same layout as SECRETS_FILE, so the same reader
+	values, err := (&fileSecretSource{path: path}).fetch()
+	if err != nil {
+		return fmt.Errorf("CONFIG_FILE: %w", err)
+	}
+	configFile.mu.Lock()
+	defer configFile.mu.Unlock()
+	configFile.path, configFile.values = path, values
+	return nil
+}
+
+// applyConfig sets the log level and webhook URL from the current settings
+func applyConfig() error {
+	level, err := parseLogLevel(configSetting("LOG_LEVEL"))
+	if err != nil {
+		return err
+	}
+	logLevel.Set(level)
+	if logWebhook != nil {
+		logWebhook.setURL(configSetting("LOG_WEBHOOK_URL"))
+	}
+	return nil
+}
+
+// reloadConfigFile re-reads CONFIG_FILE and applies it, leaving everything
+// as it was when the new file is invalid
+func reloadConfigFile() error {
+	configFile.mu.RLock()
+	path, previous := configFile.path, configFile.values
+	configFile.mu.RUnlock()
+
+	if err := loadConfigFile(path); err != nil {
+		return err
+	}
+	if err := applyConfig(); err != nil {
+		configFile.mu.Lock()
+		configFile.values = previous
+		configFile.mu.Unlock()
+		return err
+	}
+
+	configFile.mu.RLock()
+	changed := changedSettings(previous, configFile.values)
+	configFile.mu.RUnlock()
+	if len(changed) > 0 {
+		slog.Info("config settings changed", "names", changed,
+			"log_level", logLevel.Level().String())
+	}
+	return nil
+}
+
+// configureConfigWatch watches the config files that are set and starts
+// polling them. Called once everything they configure has been loaded.
+func configureConfigWatch() {
+	configWatch.interval = envDuration("CONFIG_WATCH_INTERVAL", 5*time.Second)
+	if path := os.Getenv("CONFIG_FILE"); path != "" {
+		configWatch.watch("config", reloadConfigFile, path)
+	}
+	if certFile, keyFile := os.Getenv("TLS_CERT_FILE"), os.Getenv("TLS_KEY_FILE"); certFile != "" && spiffeIdentity == nil {
+		configWatch.watch("tls", func() error { return loadCertificateFiles(certFile, keyFile) }, certFile, keyFile)
+	}
+	if path := os.Getenv("ACCESS_POLICY_FILE"); path != "" {
+		configWatch.watch("access_policy", func() error {
+			_, err := loadAccessPolicyFile(path)
+			return err
+		}, path)
+	}
+	if path := os.Getenv("RULES_FILE"); path != "" {
+		configWatch.watch("rules", func() error { return loadRulesFile(path) }, path)
+	}
+	if path := os.Getenv("SECRETS_FILE"); path != "" {
+		configWatch.watch("secrets", func() error {
+			secrets.refresh() // logs its own errors and keeps the last values
+			return nil
+		}, path)
+	}
+	configWatch.start()
+}
+
+// watch adds a component, remembering what its files hold now
+func (c *configWatcher) watch(component string, reload func() error, paths ...string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.files = append(c.files, &watchedFile{
+		component: component,
+		paths:     paths,
+		reload:    reload,
+		sum:       fingerprint(paths),
+	})
+}
+
+// check reloads every component whose files changed since the last check
+func (c *configWatcher) check() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, f := range c.files {
+		sum := fingerprint(f.paths)
+		if sum == f.sum {
+			continue
+		}
+		// Remember the new contents even if they don't load, so a broken
+		// file is reported once rather than on every check
+		f.sum = sum
+
+		err := f.reload()
+		configReloadsTotal.WithLabelValues(f.component, refreshResult(err)).Inc()
+		if err != nil {
+			f.lastError = err.Error()
+			slog.Error("config reload failed, keeping the running config",
+				"component", f.component, "paths", f.paths, "error", err)
+			continue
+		}
+		f.reloads++
+		f.lastReload, f.lastError = time.Now().UTC(), ""
+		slog.Info("config reloaded", "component", f.component, "paths", f.paths)
+	}
+}
+
+// start checks the files every interval, for as long as the app runs
+func (c *configWatcher) start() {
+	if len(c.files) == 0 || c.interval <= 0 {
+		return
+	}
+	go func() {
+		ticker := time.NewTicker(c.interval)
+		defer ticker.Stop()
+		for range ticker.C {
+			c.check()
+		}
+	}()
+}
+
+// fingerprint hashes the contents of files and of the visible files in
+// directories (the layout SECRETS_FILE and CONFIG_FILE read). A file that
+// can't be read hashes its error, so one appearing or going away counts as
+// a change.
+func fingerprint(paths []string) [sha256.Size]byte {
+	h := sha256.New()
+	var add func(path string)
+	add = func(path string) {
+		fmt.Fprintf(h, "%s\x00", path)
+		info, err := os.Stat(path)
+		if err == nil && info.IsDir() {
+			entries, err := os.ReadDir(path)
+			if err != nil {
+				fmt.Fprintf(h, "error: %v\x00", err)
+				return
+			}
+			for _, entry := range entries {
+				if !strings.HasPrefix(entry.Name(), ".") {
+					add(filepath.Join(path, entry.Name()))
+				}
+			}
+			return
+		}
+		file, err := os.Open(path)
+		if err != nil {
+			fmt.Fprintf(h, "error: %v\x00", err)
+			return
+		}
+		defer file.Close()
+		io.Copy(h, file)
+		h.Write([]byte{0})
+	}
+	for _, path := range paths {
+		add(path)
+	}
+	return [sha256.Size]byte(h.Sum(nil))
+}
+
+// changedSettings lists the names whose values differ between two
+// CONFIG_FILE reads
+func changedSettings(before, after map[string]string) []string {
+	changed := []string{}
+	for name, value := range after {
+		if old, ok := before[name]; !ok || old != value {
+			changed = append(changed, name)
+		}
+	}
+	for name := range before {
+		if _, ok := after[name]; !ok {
+			changed = append(changed, name)
+		}
+	}
+	slices.Sort(changed)
+	return changed
+}
+
+// WatchedFileStatus is one component in GET /api/admin/config-watch
+type WatchedFileStatus struct {
+	Component  string    `json:"component"`
+	Paths      []string  `json:"paths"`
+	Reloads    int       `json:"reloads"`
+	LastReload time.Time `json:"last_reload,omitzero"`
+	LastError  string    `json:"last_error,omitempty"`
+}
+
+// configWatchAdminHandler handles GET /api/admin/config-watch
+func configWatchAdminHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	if r.Method != http.MethodGet {
+		http.Error(w, `{"error":"method not allowed"}`, http.StatusMethodNotAllowed)
+		return
+	}
+
+	configWatch.mu.Lock()
+	defer configWatch.mu.Unlock()
+	files := make([]WatchedFileStatus, 0, len(configWatch.files))
+	for _, f := range configWatch.files {
+		files = append(files, WatchedFileStatus{
+			Component:  f.component,
+			Paths:      f.paths,
+			Reloads:    f.reloads,
+			LastReload: f.lastReload,
+			LastError:  f.lastError,
+		})
+	}
+	json.NewEncoder(w).Encode(map[string]any{
+		"files":     files,
+		"interval":  configWatch.interval.String(),
+		"log_level": logLevel.Level().String(),
+	})
+}
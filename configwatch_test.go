@@ -0,0 +1,226 @@
+package main
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// withConfigWatch gives one test its own watcher and CONFIG_FILE settings,
+// and puts the log level and webhook back afterwards
+func withConfigWatch(t *testing.T) *configWatcher {
+	t.Helper()
+	previousWatch, previousWebhook, previousLevel := configWatch, logWebhook, logLevel.Level()
+	configWatch = &configWatcher{interval: time.Second}
+	t.Cleanup(func() {
+		configWatch, logWebhook = previousWatch, previousWebhook
+		logLevel.Set(previousLevel)
+		configFile.mu.Lock()
+		configFile.path, configFile.values = "", nil
+		configFile.mu.Unlock()
+	})
+	return configWatch
+}
+
+// writeFile replaces a file's contents, failing the test on error
+func writeFile(t *testing.T, path, data string) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestConfigWatch_LogLevel(t *testing.T) {
+	watch := withConfigWatch(t)
+	t.Setenv("LOG_LEVEL", "warn") // overridden by the file
+
+	// A ConfigMap volume: one file per key
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "LOG_LEVEL"), "info\n")
+	if err := loadConfigFile(dir); err != nil {
+		t.Fatal(err)
+	}
+	if err := applyConfig(); err != nil {
+		t.Fatal(err)
+	}
+	if got := logLevel.Level(); got != slog.LevelInfo {
+		t.Fatalf("expected INFO from the file, got %v", got)
+	}
+	watch.watch("config", reloadConfigFile, dir)
+
+	// No change, no reload
+	watch.check()
+	if watch.files[0].reloads != 0 {
+		t.Errorf("expected no reload without a change, got %d", watch.files[0].reloads)
+	}
+
+	writeFile(t, filepath.Join(dir, "LOG_LEVEL"), "debug\n")
+	watch.check()
+	if got := logLevel.Level(); got != slog.LevelDebug {
+		t.Errorf("expected DEBUG after the change, got %v", got)
+	}
+
+	// A bad value is reported and the running level kept
+	writeFile(t, filepath.Join(dir, "LOG_LEVEL"), "loud\n")
+	watch.check()
+	if got := logLevel.Level(); got != slog.LevelDebug {
+		t.Errorf("expected DEBUG to be kept, got %v", got)
+	}
+	if watch.files[0].lastError == "" {
+		t.Error("expected the bad value to be reported")
+	}
+	if got := configSetting("LOG_LEVEL"); got != "debug" {
+		t.Errorf("expected the last good settings, got LOG_LEVEL=%q", got)
+	}
+
+	// Removing the key falls back to the environment
+	os.Remove(filepath.Join(dir, "LOG_LEVEL"))
+	watch.check()
+	if got := logLevel.Level(); got != slog.LevelWarn {
+		t.Errorf("expected WARN from the env, got %v", got)
+	}
+}
+
+func TestConfigWatch_WebhookURL(t *testing.T) {
+	watch := withConfigWatch(t)
+
+	received := make(chan []byte, 10)
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		received <- body
+	}))
+	defer receiver.Close()
+
+	// Started without a URL, like the app with a CONFIG_FILE and no webhook
+	file := filepath.Join(t.TempDir(), "config.env")
+	writeFile(t, file, "LOG_LEVEL=info\n")
+	if err := loadConfigFile(file); err != nil {
+		t.Fatal(err)
+	}
+	logWebhook = newWebhookHandler(slog.NewJSONHandler(io.Discard, nil), configSetting("LOG_WEBHOOK_URL"), "")
+	logger := slog.New(logWebhook).With("component", "test") // a copy, like request loggers
+	watch.watch("config", reloadConfigFile, file)
+
+	logger.Info("before")
+	writeFile(t, file, fmt.Sprintf("LOG_LEVEL=info\nLOG_WEBHOOK_URL=%s\n", receiver.URL))
+	watch.check()
+	logger.Info("after")
+
+	select {
+	case body := <-received:
+		if !bytes.Contains(body, []byte(`"msg":"after"`)) {
+			t.Errorf("expected only the log after the change, got %s", body)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected a log line at the new webhook URL")
+	}
+}
+
+func TestConfigWatch_TLSCertificate(t *testing.T) {
+	watch := withConfigWatch(t)
+
+	dir := t.TempDir()
+	template := func() *x509.Certificate {
+		return &x509.Certificate{IPAddresses: []net.IP{net.IPv4(127, 0, 0, 1)},
+			ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth}}
+	}
+	first := issueTestCert(t, dir, "server", nil, template())
+	config, err := loadServerTLS(first.certFile, first.keyFile, "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	watch.watch("tls", func() error { return loadCertificateFiles(first.certFile, first.keyFile) },
+		first.certFile, first.keyFile)
+
+	serial := func() string {
+		cert, err := config.GetCertificate(&tls.ClientHelloInfo{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		leaf, _ := x509.ParseCertificate(cert.Certificate[0])
+		return leaf.SerialNumber.String()
+	}
+	if got := serial(); got != first.cert.SerialNumber.String() {
+		t.Fatalf("expected the first certificate, got serial %s", got)
+	}
+
+	// Renewed in place, like cert-manager updating the Secret
+	renewed := issueTestCert(t, dir, "server", nil, template())
+	watch.check()
+	if got := serial(); got != renewed.cert.SerialNumber.String() {
+		t.Errorf("expected the renewed certificate, got serial %s", got)
+	}
+
+	// A half-written pair keeps the renewed one
+	writeFile(t, renewed.keyFile, "not a key")
+	watch.check()
+	if got := serial(); got != renewed.cert.SerialNumber.String() {
+		t.Errorf("expected the renewed certificate to be kept, got serial %s", got)
+	}
+}
+
+func TestConfigWatch_AccessPolicy(t *testing.T) {
+	watch := withConfigWatch(t)
+	withAccessPolicy(t, accessEnforce)
+
+	file := filepath.Join(t.TempDir(), "policy.yaml")
+	writeFile(t, file, fmt.Sprintf(testAccessPolicy, accessEnforce))
+	if _, err := loadAccessPolicyFile(file); err != nil {
+		t.Fatal(err)
+	}
+	watch.watch("access_policy", func() error {
+		_, err := loadAccessPolicyFile(file)
+		return err
+	}, file)
+
+	if got := accessStatus(http.MethodPost, "/api/items", ""); got != http.StatusUnauthorized {
+		t.Fatalf("expected 401 when enforcing, got %d", got)
+	}
+	writeFile(t, file, fmt.Sprintf(testAccessPolicy, accessAudit))
+	watch.check()
+	if got := accessStatus(http.MethodPost, "/api/items", ""); got != http.StatusOK {
+		t.Errorf("expected audit mode to let the request through, got %d", got)
+	}
+}
+
+func TestConfigWatch_Admin(t *testing.T) {
+	srv := newTestServer(t)
+	watch := withConfigWatch(t)
+	file := filepath.Join(t.TempDir(), "config.env")
+	writeFile(t, file, "LOG_LEVEL=info\n")
+	if err := loadConfigFile(file); err != nil {
+		t.Fatal(err)
+	}
+	watch.watch("config", reloadConfigFile, file)
+	writeFile(t, file, "LOG_LEVEL=error\n")
+	watch.check()
+
+	code, body := doRequest(t, srv, http.MethodGet, "/api/admin/config-watch", "")
+	if code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", code, body)
+	}
+	var status struct {
+		Files    []WatchedFileStatus `json:"files"`
+		LogLevel string              `json:"log_level"`
+	}
+	if err := json.Unmarshal(body, &status); err != nil {
+		t.Fatal(err)
+	}
+	if len(status.Files) != 1 || status.Files[0].Component != "config" || status.Files[0].Reloads != 1 {
+		t.Errorf("expected one config reload, got %+v", status.Files)
+	}
+	if status.LogLevel != "ERROR" {
+		t.Errorf("expected log_level ERROR, got %q", status.LogLevel)
+	}
+}
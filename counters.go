@@ -0,0 +1,364 @@
+package main
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	badger "github.com/dgraph-io/badger/v4"
+)
+
+// =============================================================================
+// Counters
+// =============================================================================
+//
+// Named counters for click/visit demos behind a load balancer:
+//
+//	curl -X POST http://localhost:8080/api/counters/clicks/increment
+//	{"name":"clicks","value":128,"instance_value":40,"instance":"demo-app-7d9f-abcde"}
+//
+// Each counter has two numbers:
+//
+//   - value: the shared count, stored in BadgerDB. In cluster mode it lives
+//     on the leader and every replica reports the same number.
+//   - instance_value: increments handled by THIS replica since it started,
+//     kept in memory. Hit the service a few times and the instance values
+//     spread across pods while the shared value keeps climbing.
+//
+// The shared count uses a Badger merge operator: each increment appends a
+// small "+1" entry instead of read-modify-writing the total, so concurrent
+// increments never conflict. A background goroutine per counter folds the
+// entries into one value now and then (like Redis INCR without the server).
+//
+// Folding rewrites the total at an existing version, which incremental pulls
+// (cluster.go) never see, so counters aren't replicated: followers ask the
+// leader directly, like displaysync.go does.
+
+// Prefix for counter keys
+const counterKeyPrefix = "counter:"
+
+// Limits: each counter runs a goroutine, so the number of names is capped
+const (
+	maxCounters          = 100
+	maxCounterNameLength = 64
+	maxCounterIncrement  = 1_000_000
+)
+
+// How often merge entries are folded together
+const counterCompactInterval = 10 * time.Second
+
+// Set on requests a follower sends to the leader, so the leader doesn't
+// count them as its own instance increments
+const counterForwardedHeader = "X-Demo-Counter-From"
+
+// Counter is one entry in the counters API
+type Counter struct {
+	Name          string `json:"name"`
+	Value         uint64 `json:"value"`          // shared count (BadgerDB)
+	InstanceValue uint64 `json:"instance_value"` // increments this replica handled
+	Instance      string `json:"instance"`       // this replica's hostname
+}
+
+// counterStore holds the merge operators (one per counter) and the
+// in-memory per-instance counts
+type counterStore struct {
+	mu        sync.Mutex
+	db        *badger.DB // the DB the operators were created for
+	ops       map[string]*badger.MergeOperator
+	instances map[string]uint64
+}
+
+var counters = &counterStore{
+	ops:       map[string]*badger.MergeOperator{},
+	instances: map[string]uint64{},
+}
+
+// errTooManyCounters is returned when a new counter would exceed maxCounters
+var errTooManyCounters = fmt.Errorf("too many counters (max %d)", maxCounters)
+
+// addUint64 is the merge function: both values are 8-byte big-endian numbers
+func addUint64(existing, value []byte) []byte {
+	return encodeUint64(decodeUint64(existing) + decodeUint64(value))
+}
+
+func encodeUint64(n uint64) []byte {
+	buf := make([]byte, 8)
+	binary.BigEndian.PutUint64(buf, n)
+	return buf
+}
+
+func decodeUint64(b []byte) uint64 {
+	if len(b) != 8 {
+		return 0
+	}
+	return binary.BigEndian.Uint64(b)
+}
+
+// normalizeCounterName lowercases a counter name and checks its characters
+func normalizeCounterName(name string) (string, error) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	if name == "" {
+		return "", errors.New("counter name is required")
+	}
+	if len(name) > maxCounterNameLength {
+		return "", fmt.Errorf("counter name must be at most %d characters", maxCounterNameLength)
+	}
+	for _, c := range name {
+		if !(c >= 'a' && c <= 'z' || c >= '0' && c <= '9' || strings.ContainsRune("-_.", c)) {
+			return "", errors.New("counter name must only contain letters, digits, '-', '_' and '.'")
+		}
+	}
+	return name, nil
+}
+
+// operator returns the merge operator for a counter, starting one if needed.
+// Must be called with s.mu held.
+func (s *counterStore) operator(name string) (*badger.MergeOperator, error) {
+	// The store was swapped (tests) — operators for the old one are useless
+	if s.db != db {
+		s.stopLocked()
+		s.db = db
+	}
+	if op, ok := s.ops[name]; ok {
+		return op, nil
+	}
+	if len(s.ops) >= maxCounters {
+		return nil, errTooManyCounters
+	}
+	op := db.GetMergeOperator([]byte(counterKeyPrefix+name), addUint64, counterCompactInterval)
+	s.ops[name] = op
+	return op, nil
+}
+
+// increment adds n to a counter's shared value and returns the new value
+func (s *counterStore) increment(name string, n uint64) (uint64, error) {
+	s.mu.Lock()
+	op, err := s.operator(name)
+	s.mu.Unlock()
+	if err != nil {
+		return 0, err
+	}
+
+	err = timeDBOp("counter_increment", counterKeyPrefix+name, func() error {
+		return op.Add(encodeUint64(n))
+	})
+	if err != nil {
+		return 0, err
+	}
+	value, err := op.Get()
+	return decodeUint64(value), err
+}
+
+// countInstance adds n to this replica's in-memory count
+func (s *counterStore) countInstance(name string, n uint64) uint64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.instances[name] += n
+	return s.instances[name]
+}
+
+// list returns the shared value of every counter stored in BadgerDB
+func (s *counterStore) list() ([]Counter, error) {
+	var names []string
+	err := dbView("counter_list", counterKeyPrefix, func(txn *badger.Txn) error {
+		opts := badger.DefaultIteratorOptions
+		opts.PrefetchValues = false
+		it := txn.NewIterator(opts)
+		defer it.Close()
+
+		prefix := []byte(counterKeyPrefix)
+		for it.Seek(prefix); it.ValidForPrefix(prefix); it.Next() {
+			names = append(names, strings.TrimPrefix(string(it.Item().Key()), counterKeyPrefix))
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	list := []Counter{}
+	for _, name := range names {
+		op, err := s.operator(name)
+		if errors.Is(err, errTooManyCounters) {
+			continue // stored by an older run; not worth another goroutine
+		}
+		if err != nil {
+			return nil, err
+		}
+		value, err := op.Get()
+		if err != nil && !errors.Is(err, badger.ErrKeyNotFound) {
+			return nil, err
+		}
+		list = append(list, Counter{Name: name, Value: decodeUint64(value)})
+	}
+	return list, nil
+}
+
+// stop shuts down every merge operator (each folds its entries one last
+// time) and forgets the instance counts. Called on shutdown and by reset,
+// before the keys are dropped, so a late fold can't bring a counter back.
+func (s *counterStore) stop() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.stopLocked()
+	s.instances = map[string]uint64{}
+}
+
+func (s *counterStore) stopLocked() {
+	for name, op := range s.ops {
+		op.Stop()
+		delete(s.ops, name)
+	}
+}
+
+// counterLeader returns the leader's URL when this replica is a follower
+// that should ask the leader for shared values
+func counterLeader(r *http.Request) (string, bool) {
+	if cluster == nil || r.Header.Get(counterForwardedHeader) != "" {
+		return "", false
+	}
+	leaderURL, isLeader := cluster.leader()
+	if isLeader || leaderURL == "" {
+		return "", false
+	}
+	return leaderURL, true
+}
+
+// askLeader sends a counters request to the leader and decodes the answer.
+// ctx is the request's, so the call is cancelled with it.
+func askLeader(ctx context.Context, method, url string, into any) error {
+	req, err := http.NewRequestWithContext(ctx, method, url, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set(counterForwardedHeader, cluster.id)
+	resp, err := cluster.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("leader answered %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+	return json.NewDecoder(resp.Body).Decode(into)
+}
+
+// countersHandler handles GET /api/counters and
+// POST /api/counters/:name/increment
+func countersHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	hostname, _ := os.Hostname()
+
+	path := strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/counters"), "/")
+	if path == "" {
+		if r.Method != http.MethodGet {
+			http.Error(w, `{"error":"method not allowed"}`, http.StatusMethodNotAllowed)
+			return
+		}
+		listCounters(w, r, hostname)
+		return
+	}
+
+	rawName, ok := strings.CutSuffix(path, "/increment")
+	if !ok || strings.Contains(rawName, "/") {
+		http.Error(w, `{"error":"not found"}`, http.StatusNotFound)
+		return
+	}
+	if r.Method != http.MethodPost {
+		http.Error(w, `{"error":"method not allowed"}`, http.StatusMethodNotAllowed)
+		return
+	}
+	name, err := normalizeCounterName(rawName)
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	by := uint64(1)
+	if raw := r.URL.Query().Get("by"); raw != "" {
+		n, err := strconv.ParseUint(raw, 10, 64)
+		if err != nil || n == 0 || n > maxCounterIncrement {
+			writeJSONError(w, http.StatusBadRequest, fmt.Sprintf("by must be between 1 and %d", maxCounterIncrement))
+			return
+		}
+		by = n
+	}
+
+	counter := Counter{Name: name, Instance: hostname}
+	// A request a follower passed on was already counted there
+	if r.Header.Get(counterForwardedHeader) == "" {
+		counter.InstanceValue = counters.countInstance(name, by)
+	}
+
+	if leaderURL, ok := counterLeader(r); ok {
+		var shared Counter
+		url := strings.TrimRight(leaderURL, "/") + "/api/counters/" + name + "/increment?by=" + strconv.FormatUint(by, 10)
+		if err := askLeader(r.Context(), http.MethodPost, url, &shared); err != nil {
+			logHandlerError(r.Context(), "counters", "leader", "failed to increment counter on leader", "error", err)
+			http.Error(w, `{"error":"leader unavailable"}`, http.StatusBadGateway)
+			return
+		}
+		counter.Value = shared.Value
+	} else {
+		counter.Value, err = counters.increment(name, by)
+		if errors.Is(err, errTooManyCounters) {
+			writeJSONError(w, http.StatusConflict, err.Error())
+			return
+		}
+		if err != nil {
+			logHandlerError(r.Context(), "counters", "database", "failed to increment counter", "error", err)
+			http.Error(w, `{"error":"database error"}`, http.StatusInternalServerError)
+			return
+		}
+	}
+	json.NewEncoder(w).Encode(counter)
+}
+
+// listCounters writes every counter with this replica's instance values
+func listCounters(w http.ResponseWriter, r *http.Request, hostname string) {
+	var list []Counter
+	if leaderURL, ok := counterLeader(r); ok {
+		if err := askLeader(r.Context(), http.MethodGet, strings.TrimRight(leaderURL, "/")+"/api/counters", &list); err != nil {
+			logHandlerError(r.Context(), "counters", "leader", "failed to list counters on leader", "error", err)
+			http.Error(w, `{"error":"leader unavailable"}`, http.StatusBadGateway)
+			return
+		}
+	} else {
+		var err error
+		if list, err = counters.list(); err != nil {
+			logHandlerError(r.Context(), "counters", "database", "failed to list counters", "error", err)
+			http.Error(w, `{"error":"database error"}`, http.StatusInternalServerError)
+			return
+		}
+	}
+
+	// The shared values may come from the leader; the instance values are ours
+	counters.mu.Lock()
+	seen := map[string]bool{}
+	for i := range list {
+		list[i].InstanceValue = counters.instances[list[i].Name]
+		list[i].Instance = hostname
+		seen[list[i].Name] = true
+	}
+	for name, n := range counters.instances {
+		if !seen[name] {
+			list = append(list, Counter{Name: name, InstanceValue: n, Instance: hostname})
+		}
+	}
+	counters.mu.Unlock()
+
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	json.NewEncoder(w).Encode(list)
+}
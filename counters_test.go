@@ -0,0 +1,142 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+)
+
+// incrementCounter POSTs an increment and decodes the answer
+func incrementCounter(t *testing.T, srv *httptest.Server, path string) Counter {
+	t.Helper()
+	code, body := doRequest(t, srv, http.MethodPost, path, "")
+	if code != http.StatusOK {
+		t.Fatalf("POST %s = %d %s", path, code, body)
+	}
+	var c Counter
+	if err := json.Unmarshal(body, &c); err != nil {
+		t.Fatalf("invalid JSON: %s", body)
+	}
+	return c
+}
+
+func TestCounters_IncrementAndList(t *testing.T) {
+	srv := newTestServer(t)
+
+	incrementCounter(t, srv, "/api/counters/clicks/increment")
+	c := incrementCounter(t, srv, "/api/counters/Clicks/increment?by=5")
+	if c.Name != "clicks" || c.Value != 6 || c.InstanceValue != 6 || c.Instance == "" {
+		t.Errorf("counter = %+v, want clicks 6/6", c)
+	}
+	incrementCounter(t, srv, "/api/counters/visits/increment")
+
+	_, body := doRequest(t, srv, http.MethodGet, "/api/counters", "")
+	var list []Counter
+	json.Unmarshal(body, &list)
+	if len(list) != 2 || list[0].Name != "clicks" || list[0].Value != 6 || list[1].Name != "visits" || list[1].Value != 1 {
+		t.Errorf("counters = %s", body)
+	}
+
+	// A restart forgets instance counts; the shared value is in the DB
+	counters.stop()
+	c = incrementCounter(t, srv, "/api/counters/clicks/increment")
+	if c.Value != 7 || c.InstanceValue != 1 {
+		t.Errorf("after restart = %+v, want value 7, instance 1", c)
+	}
+}
+
+func TestCounters_ConcurrentIncrements(t *testing.T) {
+	srv := newTestServer(t)
+
+	// Merge operator entries never conflict, so none of these fail
+	const n = 50
+	var wg sync.WaitGroup
+	for range n {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if code, body := doRequestNoFatal(srv, http.MethodPost, "/api/counters/hits/increment", ""); code != http.StatusOK {
+				t.Errorf("increment = %d %s", code, body)
+			}
+		}()
+	}
+	wg.Wait()
+
+	_, body := doRequest(t, srv, http.MethodGet, "/api/counters", "")
+	var list []Counter
+	json.Unmarshal(body, &list)
+	if len(list) != 1 || list[0].Value != n || list[0].InstanceValue != n {
+		t.Errorf("counters = %s, want hits %d", body, n)
+	}
+}
+
+func TestCounters_FollowerAsksLeader(t *testing.T) {
+	srv := newTestServer(t)
+
+	var forwardedBy []string
+	leader := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		forwardedBy = append(forwardedBy, r.Header.Get(counterForwardedHeader))
+		if r.Method == http.MethodPost {
+			io.WriteString(w, `{"name":"clicks","value":1000,"instance_value":0}`)
+			return
+		}
+		io.WriteString(w, `[{"name":"clicks","value":1000,"instance_value":0}]`)
+	}))
+	defer leader.Close()
+
+	node := newClusterNode(nil, "", "0")
+	node.isLeader = false
+	node.leaderURL = leader.URL
+	withCluster(t, node)
+
+	// Shared value from the leader, instance value counted here
+	c := incrementCounter(t, srv, "/api/counters/clicks/increment")
+	if c.Value != 1000 || c.InstanceValue != 1 {
+		t.Errorf("counter = %+v, want leader's value and our instance count", c)
+	}
+	_, body := doRequest(t, srv, http.MethodGet, "/api/counters", "")
+	var list []Counter
+	json.Unmarshal(body, &list)
+	if len(list) != 1 || list[0].Value != 1000 || list[0].InstanceValue != 1 {
+		t.Errorf("counters = %s", body)
+	}
+	if len(forwardedBy) != 2 || forwardedBy[0] != node.id {
+		t.Errorf("leader saw %q, want requests marked with our node ID", forwardedBy)
+	}
+
+	// Leader gone: 502
+	leader.Close()
+	if code, _ := doRequest(t, srv, http.MethodPost, "/api/counters/clicks/increment", ""); code != http.StatusBadGateway {
+		t.Errorf("status = %d, want 502", code)
+	}
+}
+
+func TestCounters_Errors(t *testing.T) {
+	srv := newTestServer(t)
+
+	tests := []struct {
+		name       string
+		method     string
+		path       string
+		wantStatus int
+	}{
+		{"invalid name", http.MethodPost, "/api/counters/a%20b/increment", http.StatusBadRequest},
+		{"zero increment", http.MethodPost, "/api/counters/x/increment?by=0", http.StatusBadRequest},
+		{"huge increment", http.MethodPost, "/api/counters/x/increment?by=1000001", http.StatusBadRequest},
+		{"GET increment", http.MethodGet, "/api/counters/x/increment", http.StatusMethodNotAllowed},
+		{"POST list", http.MethodPost, "/api/counters", http.StatusMethodNotAllowed},
+		{"unknown path", http.MethodGet, "/api/counters/x", http.StatusNotFound},
+		{"nested name", http.MethodPost, "/api/counters/a/b/increment", http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, body := doRequest(t, srv, tt.method, tt.path, "")
+			if code != tt.wantStatus {
+				t.Errorf("status = %d, want %d (body %s)", code, tt.wantStatus, body)
+			}
+		})
+	}
+}
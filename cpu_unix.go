@@ -0,0 +1,21 @@
+//go:build !windows
+
+package main
+
+import (
+	"syscall"
+	"time"
+)
+
+// processCPUTime reports the CPU time (user + system) this process has used.
+//
+// getrusage(2) is what `time` and `ps` read. Like statfs in disk_unix.go,
+// it's not available on Windows (see cpu_windows.go).
+func processCPUTime() (time.Duration, error) {
+	var usage syscall.Rusage
+	if err := syscall.Getrusage(syscall.RUSAGE_SELF, &usage); err != nil {
+		return 0, err
+	}
+	total := time.Duration(usage.Utime.Nano() + usage.Stime.Nano())
+	return total, nil
+}
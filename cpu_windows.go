@@ -0,0 +1,14 @@
+//go:build windows
+
+package main
+
+import (
+	"errors"
+	"time"
+)
+
+// processCPUTime is not implemented on Windows (see cpu_unix.go).
+// Callers leave CPU usage out of the response.
+func processCPUTime() (time.Duration, error) {
+	return 0, errors.New("process cpu time not supported on windows")
+}
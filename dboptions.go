@@ -0,0 +1,232 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+
+	badger "github.com/dgraph-io/badger/v4"
+	"github.com/dgraph-io/badger/v4/options"
+)
+
+// =============================================================================
+// BadgerDB Tuning
+// =============================================================================
+//
+// Storage-performance demos compare configurations: "what if every write
+// waits for fsync?", "what does block compression cost?". These env vars set
+// the BadgerDB options that matter most, so that needs a restart, not a
+// rebuild:
+//
+//	DB_SYNC_WRITES=true DB_COMPRESSION=zstd DB_PATH=/data ./demo-app
+//
+//   - DB_VALUE_LOG_FILE_SIZE: bytes per value log file (1 MiB to 2 GiB)
+//   - DB_NUM_COMPACTORS: background compaction goroutines (0 = none, else 2+)
+//   - DB_MEMTABLE_SIZE: bytes buffered in memory before a flush to disk
+//   - DB_COMPRESSION: none, snappy, or zstd, for Badger's on-disk blocks
+//   - DB_SYNC_WRITES: fsync every commit (durable, much slower)
+//
+// Unset ones keep Badger's defaults. A value Badger would refuse, or that
+// isn't a number, stops the app at startup with the reason, instead of
+// falling back quietly: a benchmark run on the wrong settings is worse than
+// none. GET /api/admin/db/options reads back what the open database uses:
+//
+//	curl http://localhost:8080/api/admin/db/options
+//	{"in_memory":false,"value_log_file_size":268435456,"num_compactors":4,
+//	 "mem_table_size":67108864,"compression":"zstd","sync_writes":true,"durability":"sync",
+//	 "block_cache_size":268435456,"index_cache_size":0,"encrypted":false,
+//	 "from_env":["DB_VALUE_LOG_FILE_SIZE","DB_COMPRESSION","DB_SYNC_WRITES"]}
+//
+// Block compression is separate from ITEM_COMPRESSION (compression.go),
+// which compresses item values before Badger sees them.
+
+// Bounds Badger (or common sense) puts on the tunable options
+const (
+	minValueLogFileSize = 1 << 20
+	maxValueLogFileSize = 2<<30 - 1
+	minMemTableSize     = 8 << 20 // Badger needs 15% of it to hold a 1 MiB value
+	maxMemTableSize     = 1 << 30
+	maxNumCompactors    = 64
+)
+
+// dbTuning is the tunable part of Badger's options
+type dbTuning struct {
+	ValueLogFileSize int64
+	NumCompactors    int
+	MemTableSize     int64
+	Compression      options.CompressionType
+	SyncWrites       bool
+	FromEnv          []string // the env vars that were set
+}
+
+// dbOptions is what initStore applies; Badger's defaults until
+// loadDBOptions reads the environment
+var dbOptions = defaultDBTuning()
+
+// defaultDBTuning is Badger's own defaults
+func defaultDBTuning() dbTuning {
+	defaults := badger.DefaultOptions("")
+	return dbTuning{
+		ValueLogFileSize: defaults.ValueLogFileSize,
+		NumCompactors:    defaults.NumCompactors,
+		MemTableSize:     defaults.MemTableSize,
+		Compression:      defaults.Compression,
+		SyncWrites:       defaults.SyncWrites,
+	}
+}
+
+// loadDBOptions reads the DB_* tuning variables into dbOptions
+func loadDBOptions() error {
+	tuning, err := parseDBTuning(os.Getenv)
+	if err != nil {
+		return err
+	}
+	dbOptions = tuning
+	return nil
+}
+
+// parseDBTuning reads and checks the tuning variables through getenv,
+// reporting every bad one at once
+func parseDBTuning(getenv func(string) string) (dbTuning, error) {
+	t := defaultDBTuning()
+	var errs []error
+
+	size := func(key string, into *int64, lo, hi int64) {
+		val := strings.TrimSpace(getenv(key))
+		if val == "" {
+			return
+		}
+		t.FromEnv = append(t.FromEnv, key)
+		n, err := strconv.ParseInt(val, 10, 64)
+		if err != nil || n < lo || n > hi {
+			errs = append(errs, fmt.Errorf("%s must be a number of bytes from %d to %d, got %q", key, lo, hi, val))
+			return
+		}
+		*into = n
+	}
+	size("DB_MEMTABLE_SIZE", &t.MemTableSize, minMemTableSize, maxMemTableSize)
+	size("DB_VALUE_LOG_FILE_SIZE", &t.ValueLogFileSize, minValueLogFileSize, maxValueLogFileSize)
+
+	if val := strings.TrimSpace(getenv("DB_NUM_COMPACTORS")); val != "" {
+		t.FromEnv = append(t.FromEnv, "DB_NUM_COMPACTORS")
+		n, err := strconv.Atoi(val)
+		switch {
+		case err != nil || n < 0 || n > maxNumCompactors:
+			errs = append(errs, fmt.Errorf("DB_NUM_COMPACTORS must be a number from 0 to %d, got %q", maxNumCompactors, val))
+		case n == 1:
+			errs = append(errs, errors.New("DB_NUM_COMPACTORS can't be 1: Badger needs 0 (no compaction) or at least 2"))
+		default:
+			t.NumCompactors = n
+		}
+	}
+
+	if val := strings.ToLower(strings.TrimSpace(getenv("DB_COMPRESSION"))); val != "" {
+		t.FromEnv = append(t.FromEnv, "DB_COMPRESSION")
+		switch val {
+		case "none":
+			t.Compression = options.None
+		case "snappy":
+			t.Compression = options.Snappy
+		case "zstd":
+			t.Compression = options.ZSTD
+		default:
+			errs = append(errs, fmt.Errorf(`DB_COMPRESSION must be "none", "snappy", or "zstd", got %q`, val))
+		}
+	}
+
+	if val := strings.TrimSpace(getenv("DB_SYNC_WRITES")); val != "" {
+		t.FromEnv = append(t.FromEnv, "DB_SYNC_WRITES")
+		b, err := strconv.ParseBool(val)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("DB_SYNC_WRITES must be true or false, got %q", val))
+		}
+		t.SyncWrites = b
+	}
+
+	// The same switch by another name (durability.go)
+	if val := strings.TrimSpace(getenv("DURABILITY_MODE")); val != "" {
+		syncWrites, err := parseDurabilityMode(val)
+		switch {
+		case err != nil:
+			errs = append(errs, err)
+		case slices.Contains(t.FromEnv, "DB_SYNC_WRITES") && syncWrites != t.SyncWrites:
+			errs = append(errs, errors.New("DURABILITY_MODE and DB_SYNC_WRITES disagree; set only one of them"))
+		default:
+			t.SyncWrites = syncWrites
+		}
+		t.FromEnv = append(t.FromEnv, "DURABILITY_MODE")
+	}
+
+	return t, errors.Join(errs...)
+}
+
+// apply sets the tuning on Badger options
+func (t dbTuning) apply(opts badger.Options) badger.Options {
+	return opts.
+		WithValueLogFileSize(t.ValueLogFileSize).
+		WithNumCompactors(t.NumCompactors).
+		WithMemTableSize(t.MemTableSize).
+		WithCompression(t.Compression).
+		WithSyncWrites(t.SyncWrites)
+}
+
+// compressionName is the env var spelling of a Badger compression type
+func compressionName(c options.CompressionType) string {
+	switch c {
+	case options.Snappy:
+		return "snappy"
+	case options.ZSTD:
+		return "zstd"
+	default:
+		return "none"
+	}
+}
+
+// DBOptions is GET /api/admin/db/options
+type DBOptions struct {
+	InMemory         bool     `json:"in_memory"`
+	ValueLogFileSize int64    `json:"value_log_file_size"`
+	NumCompactors    int      `json:"num_compactors"`
+	MemTableSize     int64    `json:"mem_table_size"`
+	Compression      string   `json:"compression"`
+	SyncWrites       bool     `json:"sync_writes"`
+	Durability       string   `json:"durability"` // fast, sync, or memory (durability.go)
+	BlockCacheSize   int64    `json:"block_cache_size"`
+	IndexCacheSize   int64    `json:"index_cache_size"`
+	Encrypted        bool     `json:"encrypted"`
+	FromEnv          []string `json:"from_env"` // tuning variables that were set
+}
+
+// dbOptionsHandler handles GET /api/admin/db/options
+func dbOptionsHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	if r.Method != http.MethodGet {
+		http.Error(w, `{"error":"method not allowed"}`, http.StatusMethodNotAllowed)
+		return
+	}
+
+	// Read from the open database, not the env: that's what's in effect
+	opts := db.Opts()
+	fromEnv := dbOptions.FromEnv
+	if fromEnv == nil {
+		fromEnv = []string{}
+	}
+	json.NewEncoder(w).Encode(DBOptions{
+		InMemory:         opts.InMemory,
+		ValueLogFileSize: opts.ValueLogFileSize,
+		NumCompactors:    opts.NumCompactors,
+		MemTableSize:     opts.MemTableSize,
+		Compression:      compressionName(opts.Compression),
+		SyncWrites:       opts.SyncWrites,
+		Durability:       durabilityOf(opts),
+		BlockCacheSize:   opts.BlockCacheSize,
+		IndexCacheSize:   opts.IndexCacheSize,
+		Encrypted:        len(opts.EncryptionKey) > 0,
+		FromEnv:          fromEnv,
+	})
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/dgraph-io/badger/v4/options"
+)
+
+// envMap is a getenv for parseDBTuning
+func envMap(vars map[string]string) func(string) string {
+	return func(key string) string { return vars[key] }
+}
+
+func TestParseDBTuning(t *testing.T) {
+	// Unset: Badger's defaults
+	got, err := parseDBTuning(envMap(nil))
+	if err != nil || got.NumCompactors != 4 || got.Compression != options.Snappy || got.SyncWrites || len(got.FromEnv) != 0 {
+		t.Errorf("defaults = %+v, %v", got, err)
+	}
+
+	got, err = parseDBTuning(envMap(map[string]string{
+		"DB_VALUE_LOG_FILE_SIZE": "268435456",
+		"DB_NUM_COMPACTORS":      "0",
+		"DB_MEMTABLE_SIZE":       "16777216",
+		"DB_COMPRESSION":         "ZSTD",
+		"DB_SYNC_WRITES":         "true",
+	}))
+	if err != nil || got.ValueLogFileSize != 256<<20 || got.NumCompactors != 0 || got.MemTableSize != 16<<20 ||
+		got.Compression != options.ZSTD || !got.SyncWrites || len(got.FromEnv) != 5 {
+		t.Errorf("tuned = %+v, %v", got, err)
+	}
+
+	// Every bad value is reported, not just the first
+	_, err = parseDBTuning(envMap(map[string]string{
+		"DB_VALUE_LOG_FILE_SIZE": "1024",
+		"DB_NUM_COMPACTORS":      "1",
+		"DB_MEMTABLE_SIZE":       "64MB",
+		"DB_COMPRESSION":         "lz4",
+		"DB_SYNC_WRITES":         "sometimes",
+	}))
+	for _, key := range []string{"DB_VALUE_LOG_FILE_SIZE", "DB_NUM_COMPACTORS", "DB_MEMTABLE_SIZE", "DB_COMPRESSION", "DB_SYNC_WRITES"} {
+		if err == nil || !strings.Contains(err.Error(), key) {
+			t.Errorf("error %v doesn't mention %s", err, key)
+		}
+	}
+}
+
+func TestDBOptionsHandler(t *testing.T) {
+	// newTestServer's initStore picks up dbOptions
+	prev := dbOptions
+	dbOptions, _ = parseDBTuning(envMap(map[string]string{"DB_NUM_COMPACTORS": "2", "DB_COMPRESSION": "none", "DB_SYNC_WRITES": "1"}))
+	t.Cleanup(func() { dbOptions = prev })
+	srv := newTestServer(t)
+
+	code, body := doRequest(t, srv, http.MethodGet, "/api/admin/db/options", "")
+	var got DBOptions
+	if err := json.Unmarshal(body, &got); err != nil || code != http.StatusOK {
+		t.Fatalf("GET = %d %s", code, body)
+	}
+	// Badger turns sync writes off in memory: there's no file to sync
+	if !got.InMemory || got.NumCompactors != 2 || got.Compression != "none" || got.SyncWrites || len(got.FromEnv) != 3 {
+		t.Errorf("options = %s", body)
+	}
+
+	if code, _ := doRequest(t, srv, http.MethodPut, "/api/admin/db/options", "{}"); code != http.StatusMethodNotAllowed {
+		t.Errorf("PUT = %d, want 405", code)
+	}
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// =============================================================================
+// Database Trace Headers
+// =============================================================================
+//
+// "What does the storage layer actually do for this request?" With
+// DEBUG_ENDPOINTS=true, a request sent with X-Debug: 1 gets response headers
+// listing its BadgerDB transactions and the keys they were about:
+//
+//	DEBUG_ENDPOINTS=true ./demo-app
+//	curl -i -X PUT -H 'X-Debug: 1' -d '{"name":"renamed"}' http://localhost:8080/api/items/1
+//	X-Debug-Db-Txns: 1 (0 read, 1 write)
+//	X-Debug-Db-Reads:
+//	X-Debug-Db-Writes: item_update item:00000000000000000001
+//
+// Each entry is a transaction's op (the same name as the op label on
+// demoapp_db_operation_duration_seconds) and its key: the item key, or the
+// prefix for a scan. A list is one read transaction over "item:", not one
+// entry per item. The same list is logged with the request, which also
+// covers transactions made after the response started (streamed lists).
+//
+// Without DEBUG_ENDPOINTS the header is ignored: the trace shows the app's
+// key layout, which isn't something to hand every client.
+//
+// The trace travels in the request's context, and the context-aware
+// transaction wrappers in store.go (dbViewContext, dbUpdateContext) add to
+// it, whichever goroutine they run on. Transactions made without the
+// request's context (dbView, dbUpdate) aren't listed.
+
+// debugEndpoints is DEBUG_ENDPOINTS (set in main)
+var debugEndpoints bool
+
+// Entries listed in a header; the count covers all of them
+const maxDBTraceEntries = 50
+
+// dbTrace collects one request's transactions
+type dbTrace struct {
+	mu     sync.Mutex
+	reads  []string // "op key"
+	writes []string
+}
+
+// Context key for a traced request's dbTrace
+type dbTraceKey struct{}
+
+// traceDBTxn records a transaction for the request ctx belongs to, if it's
+// being traced
+func traceDBTxn(ctx context.Context, op, key string, write bool) {
+	t, ok := ctx.Value(dbTraceKey{}).(*dbTrace)
+	if !ok {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	entry := op + " " + key
+	if write {
+		t.writes = append(t.writes, entry)
+	} else {
+		t.reads = append(t.reads, entry)
+	}
+}
+
+// setHeaders writes the trace so far into h
+func (t *dbTrace) setHeaders(h http.Header) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	h.Set("X-Debug-DB-Txns", fmt.Sprintf("%d (%d read, %d write)", len(t.reads)+len(t.writes), len(t.reads), len(t.writes)))
+	h.Set("X-Debug-DB-Reads", joinTraceEntries(t.reads))
+	h.Set("X-Debug-DB-Writes", joinTraceEntries(t.writes))
+}
+
+// joinTraceEntries lists the first maxDBTraceEntries entries
+func joinTraceEntries(entries []string) string {
+	if len(entries) <= maxDBTraceEntries {
+		return strings.Join(entries, ", ")
+	}
+	return strings.Join(entries[:maxDBTraceEntries], ", ") + fmt.Sprintf(", ... %d more", len(entries)-maxDBTraceEntries)
+}
+
+// dbTraceWriter adds the trace headers when the response starts
+type dbTraceWriter struct {
+	http.ResponseWriter
+	trace       *dbTrace
+	wroteHeader bool
+}
+
+func (tw *dbTraceWriter) WriteHeader(code int) {
+	if !tw.wroteHeader {
+		tw.wroteHeader = true
+		tw.trace.setHeaders(tw.Header())
+	}
+	tw.ResponseWriter.WriteHeader(code)
+}
+
+func (tw *dbTraceWriter) Write(b []byte) (int, error) {
+	if !tw.wroteHeader {
+		tw.WriteHeader(http.StatusOK)
+	}
+	return tw.ResponseWriter.Write(b)
+}
+
+// Unwrap lets http.NewResponseController reach Flush (see responseRecorder)
+func (tw *dbTraceWriter) Unwrap() http.ResponseWriter {
+	return tw.ResponseWriter
+}
+
+// dbTraceMiddleware traces requests that ask for it with X-Debug: 1.
+// loggingMiddleware calls it, so the trace is in the context of everything
+// the request runs.
+func dbTraceMiddleware(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !debugEndpoints || r.Header.Get("X-Debug") != "1" {
+			next(w, r)
+			return
+		}
+
+		trace := &dbTrace{}
+		tw := &dbTraceWriter{ResponseWriter: w, trace: trace}
+		next(tw, r.WithContext(context.WithValue(r.Context(), dbTraceKey{}, trace)))
+		if !tw.wroteHeader {
+			trace.setHeaders(w.Header()) // nothing written (an implicit 200)
+		}
+
+		trace.mu.Lock()
+		defer trace.mu.Unlock()
+		slog.InfoContext(r.Context(), "db trace",
+			"method", r.Method,
+			"path", r.URL.Path,
+			"reads", trace.reads,
+			"writes", trace.writes,
+		)
+	}
+}
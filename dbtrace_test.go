@@ -0,0 +1,92 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	badger "github.com/dgraph-io/badger/v4"
+)
+
+// withDebugEndpoints turns DEBUG_ENDPOINTS on for one test
+func withDebugEndpoints(t *testing.T) {
+	t.Helper()
+	debugEndpoints = true
+	t.Cleanup(func() { debugEndpoints = false })
+}
+
+// debugRequest sends a request with X-Debug: 1 and returns its headers
+func debugRequest(t *testing.T, url, method, body string) http.Header {
+	t.Helper()
+	req, err := http.NewRequest(method, url, strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("X-Debug", "1")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	return resp.Header
+}
+
+func TestDBTrace_Headers(t *testing.T) {
+	srv := newTestServer(t)
+	withDebugEndpoints(t)
+	item := createTestItem(t, srv, `{"name":"traced"}`)
+	key := string(itemKey(item.ID))
+
+	h := debugRequest(t, srv.URL+fmt.Sprintf("/api/items/%d", item.ID), http.MethodGet, "")
+	if got := h.Get("X-Debug-DB-Reads"); !strings.Contains(got, "item_get "+key) {
+		t.Errorf("GET reads = %q, want item_get %s", got, key)
+	}
+	if h.Get("X-Debug-DB-Writes") != "" || !strings.HasPrefix(h.Get("X-Debug-DB-Txns"), "1 (1 read, 0 write)") {
+		t.Errorf("GET txns = %q, writes = %q", h.Get("X-Debug-DB-Txns"), h.Get("X-Debug-DB-Writes"))
+	}
+
+	h = debugRequest(t, srv.URL+fmt.Sprintf("/api/items/%d", item.ID), http.MethodPut, `{"name":"renamed"}`)
+	if got := h.Get("X-Debug-DB-Writes"); !strings.Contains(got, "item_update "+key) {
+		t.Errorf("PUT writes = %q, want item_update %s", got, key)
+	}
+}
+
+func TestDBTrace_CreateAndOtherGoroutines(t *testing.T) {
+	srv := newTestServer(t)
+	withDebugEndpoints(t)
+
+	h := debugRequest(t, srv.URL+"/api/items", http.MethodPost, `{"name":"traced"}`)
+	if got := h.Get("X-Debug-DB-Writes"); !strings.Contains(got, "item_insert ") {
+		t.Errorf("POST writes = %q, want item_insert", got)
+	}
+
+	// A transaction the handler hands to another goroutine is still the request's
+	handler := dbTraceMiddleware(func(w http.ResponseWriter, r *http.Request) {
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			dbViewContext(r.Context(), "elsewhere", "k", func(txn *badger.Txn) error { return nil })
+		}()
+		<-done
+	})
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("X-Debug", "1")
+	rec := httptest.NewRecorder()
+	handler(rec, req)
+	if got := rec.Header().Get("X-Debug-DB-Reads"); got != "elsewhere k" {
+		t.Errorf("reads = %q, want the other goroutine's transaction", got)
+	}
+}
+
+func TestDBTrace_OffWithoutDebugEndpoints(t *testing.T) {
+	srv := newTestServer(t)
+	if h := debugRequest(t, srv.URL+"/api/items", http.MethodGet, ""); h.Get("X-Debug-DB-Txns") != "" {
+		t.Errorf("trace headers without DEBUG_ENDPOINTS: %v", h)
+	}
+	withDebugEndpoints(t)
+	if h := debugRequest(t, srv.URL+"/api/items", http.MethodGet, ""); h.Get("X-Debug-DB-Txns") == "" {
+		t.Error("no trace headers with DEBUG_ENDPOINTS")
+	}
+}
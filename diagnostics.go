@@ -0,0 +1,238 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	badger "github.com/dgraph-io/badger/v4"
+)
+
+// =============================================================================
+// Startup Diagnostics
+// =============================================================================
+//
+// When a demo fails, the first question is always "what's wrong with the
+// environment?" Rather than scraping logs, we run a quick self-check at
+// startup, log each result, and keep the report for GET /api/system/diagnostics.
+
+// Diagnostic check statuses
+const (
+	diagOK      = "ok"
+	diagWarn    = "warn"
+	diagFail    = "fail"
+	diagSkipped = "skipped"
+)
+
+// Free disk below this fraction of the total produces a warning
+const diskWarnFraction = 0.10
+
+// DiagnosticCheck is the result of one self-check
+type DiagnosticCheck struct {
+	Name       string `json:"name"`
+	Status     string `json:"status"`
+	Detail     string `json:"detail"`
+	DurationMs int64  `json:"duration_ms"`
+}
+
+// DiagnosticsReport is the full result of a diagnostics pass
+type DiagnosticsReport struct {
+	Status    string            `json:"status"` // worst status across all checks
+	Version   string            `json:"version"`
+	CheckedAt time.Time         `json:"checked_at"`
+	Checks    []DiagnosticCheck `json:"checks"`
+}
+
+// diagnosticsConfig holds what the checks need to know about the environment
+type diagnosticsConfig struct {
+	dbPath     string
+	webhookURL string
+	port       string
+}
+
+// The most recent report, guarded by a mutex since a refresh can
+// run while another request is reading it
+var (
+	diagMu     sync.RWMutex
+	diagReport DiagnosticsReport
+	diagConfig diagnosticsConfig
+)
+
+// runDiagnostics executes every check and returns a report.
+// portErr is the error from main() binding the listen port (nil means it worked).
+func runDiagnostics(cfg diagnosticsConfig, portErr error) DiagnosticsReport {
+	checks := []DiagnosticCheck{
+		timeCheck("db_writable", checkDBWritable),
+		timeCheck("disk_space", func() (string, string) { return checkDiskSpace(cfg.dbPath) }),
+		timeCheck("webhook_reachable", func() (string, string) { return checkWebhook(cfg.webhookURL) }),
+		timeCheck("port_bindable", func() (string, string) { return checkPort(cfg.port, portErr) }),
+	}
+
+	return DiagnosticsReport{
+		Status:    worstStatus(checks),
+		Version:   version,
+		CheckedAt: time.Now().UTC(),
+		Checks:    checks,
+	}
+}
+
+// timeCheck runs a check function and records how long it took
+func timeCheck(name string, fn func() (status, detail string)) DiagnosticCheck {
+	start := time.Now()
+	status, detail := fn()
+	return DiagnosticCheck{
+		Name:       name,
+		Status:     status,
+		Detail:     detail,
+		DurationMs: time.Since(start).Milliseconds(),
+	}
+}
+
+// worstStatus collapses all checks to a single status: fail > warn > ok
+func worstStatus(checks []DiagnosticCheck) string {
+	result := diagOK
+	for _, c := range checks {
+		switch c.Status {
+		case diagFail:
+			return diagFail
+		case diagWarn:
+			result = diagWarn
+		}
+	}
+	return result
+}
+
+// checkDBWritable writes and then deletes a probe key
+func checkDBWritable() (string, string) {
+	key := []byte("diag:probe")
+	value := []byte(time.Now().UTC().Format(time.RFC3339Nano))
+
+	err := db.Update(func(txn *badger.Txn) error {
+		return txn.Set(key, value)
+	})
+	if err != nil {
+		return diagFail, "write failed: " + err.Error()
+	}
+
+	err = db.Update(func(txn *badger.Txn) error {
+		return txn.Delete(key)
+	})
+	if err != nil {
+		return diagFail, "cleanup failed: " + err.Error()
+	}
+	return diagOK, "write/delete succeeded"
+}
+
+// checkDiskSpace reports free space where the database lives
+func checkDiskSpace(dbPath string) (string, string) {
+	if dbPath == "" || dbPath == ":memory:" {
+		return diagSkipped, "in-memory database"
+	}
+
+	total, free, err := diskUsage(dbPath)
+	if err != nil {
+		return diagWarn, "unable to read disk usage: " + err.Error()
+	}
+
+	detail := fmt.Sprintf("%d MB free of %d MB", free/1024/1024, total/1024/1024)
+	if total > 0 && float64(free)/float64(total) < diskWarnFraction {
+		return diagWarn, detail
+	}
+	return diagOK, detail
+}
+
+// checkWebhook opens a TCP connection to the webhook host.
+// We deliberately don't POST — a probe shouldn't show up as a log entry.
+func checkWebhook(webhookURL string) (string, string) {
+	if webhookURL == "" {
+		return diagSkipped, "LOG_WEBHOOK_URL not set"
+	}
+
+	u, err := url.Parse(webhookURL)
+	if err != nil || u.Host == "" {
+		return diagFail, "invalid webhook URL"
+	}
+
+	// Add the default port if the URL doesn't specify one
+	host := u.Host
+	if u.Port() == "" {
+		if u.Scheme == "https" {
+			host = net.JoinHostPort(u.Hostname(), "443")
+		} else {
+			host = net.JoinHostPort(u.Hostname(), "80")
+		}
+	}
+
+	conn, err := net.DialTimeout("tcp", host, 2*time.Second)
+	if err != nil {
+		return diagFail, err.Error()
+	}
+	conn.Close()
+	return diagOK, "connected to " + host
+}
+
+// checkPort reports whether main() managed to bind the listen port
+func checkPort(port string, portErr error) (string, string) {
+	if portErr != nil {
+		return diagFail, portErr.Error()
+	}
+	return diagOK, "listening on :" + port
+}
+
+// logDiagnostics writes one log line per check plus a summary
+func logDiagnostics(report DiagnosticsReport) {
+	for _, c := range report.Checks {
+		level := slog.LevelInfo
+		switch c.Status {
+		case diagWarn:
+			level = slog.LevelWarn
+		case diagFail:
+			level = slog.LevelError
+		}
+		slog.Log(context.Background(), level, "diagnostic check",
+			"check", c.Name,
+			"status", c.Status,
+			"detail", c.Detail,
+			"duration_ms", c.DurationMs,
+		)
+	}
+	slog.Info("diagnostics complete", "status", report.Status)
+}
+
+// setDiagnostics stores the latest report for the API
+func setDiagnostics(report DiagnosticsReport) {
+	diagMu.Lock()
+	defer diagMu.Unlock()
+	diagReport = report
+}
+
+// =============================================================================
+// Diagnostics Endpoint
+// =============================================================================
+
+// diagnosticsHandler returns the startup diagnostics report (GET only)
+// Add ?refresh=true to re-run the checks now instead of returning the cached result.
+func diagnosticsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, `{"error":"method not allowed"}`, http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+
+	if r.URL.Query().Get("refresh") == "true" {
+		// The server is answering this request, so the port is clearly bound
+		setDiagnostics(runDiagnostics(diagConfig, nil))
+	}
+
+	diagMu.RLock()
+	report := diagReport
+	diagMu.RUnlock()
+
+	writeResponse(w, r, http.StatusOK, report)
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestDiagnostics_PortFailureFailsReport(t *testing.T) {
+	report := runDiagnostics(diagnosticsConfig{dbPath: ":memory:", port: "8080"}, errors.New("address in use"))
+
+	if report.Status != diagFail {
+		t.Errorf("expected overall status 'fail', got '%s'", report.Status)
+	}
+
+	statuses := make(map[string]string)
+	for _, c := range report.Checks {
+		statuses[c.Name] = c.Status
+	}
+	if statuses["db_writable"] != diagOK {
+		t.Errorf("expected db_writable 'ok', got '%s'", statuses["db_writable"])
+	}
+	if statuses["disk_space"] != diagSkipped {
+		t.Errorf("expected disk_space 'skipped' for in-memory DB, got '%s'", statuses["disk_space"])
+	}
+	if statuses["port_bindable"] != diagFail {
+		t.Errorf("expected port_bindable 'fail', got '%s'", statuses["port_bindable"])
+	}
+}
+
+func TestDiagnosticsHandler_Refresh(t *testing.T) {
+	diagConfig = diagnosticsConfig{dbPath: ":memory:", port: "8080"}
+
+	req := httptest.NewRequest("GET", "/api/system/diagnostics?refresh=true", nil)
+	rr := httptest.NewRecorder()
+	diagnosticsHandler(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", rr.Code)
+	}
+
+	var report DiagnosticsReport
+	if err := json.Unmarshal(rr.Body.Bytes(), &report); err != nil {
+		t.Fatalf("failed to parse report: %v", err)
+	}
+	if report.Status != diagOK {
+		t.Errorf("expected status 'ok', got '%s': %+v", report.Status, report.Checks)
+	}
+	if len(report.Checks) != 4 {
+		t.Errorf("expected 4 checks, got %d", len(report.Checks))
+	}
+}
@@ -0,0 +1,21 @@
+//go:build !windows
+
+package main
+
+import "syscall"
+
+// diskUsage reports total and free bytes for the filesystem containing path.
+//
+// syscall.Statfs is the Go wrapper around the statfs(2) system call — the
+// same data `df` shows. It doesn't exist on Windows, which is why this file
+// has a build tag (see disk_windows.go for the fallback).
+func diskUsage(path string) (total, free uint64, err error) {
+	var stat syscall.Statfs_t
+	if err := syscall.Statfs(path, &stat); err != nil {
+		return 0, 0, err
+	}
+	// Blocks * block size = bytes. Bavail is what an unprivileged user can use.
+	total = stat.Blocks * uint64(stat.Bsize)
+	free = stat.Bavail * uint64(stat.Bsize)
+	return total, free, nil
+}
@@ -0,0 +1,11 @@
+//go:build windows
+
+package main
+
+import "errors"
+
+// diskUsage is not implemented on Windows (see disk_unix.go).
+// Callers treat the error as "unknown" rather than a failure.
+func diskUsage(path string) (total, free uint64, err error) {
+	return 0, 0, errors.New("disk usage not supported on windows")
+}
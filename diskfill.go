@@ -0,0 +1,247 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math/rand/v2"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+)
+
+// =============================================================================
+// Disk Fill Simulation
+// =============================================================================
+//
+// A driver for PVC usage alerts and volume-expansion demos: write junk files
+// onto the volume that holds the database, watch usage climb, then delete
+// them.
+//
+//	curl -X POST 'http://localhost:8080/api/chaos/disk?mb=500'
+//	curl http://localhost:8080/api/chaos/disk          # progress and usage
+//	curl -X DELETE http://localhost:8080/api/chaos/disk
+//
+// The files go in a chaos-fill directory inside DB_PATH, so they land on the
+// same volume (BadgerDB ignores files it didn't create), and they stay there
+// across restarts until deleted. Usage of the volume shows in /api/system
+// under resources.disk. Writing happens in the background, a 64 MB file at
+// a time, and stops early with less than 64 MB free, so the app keeps
+// working. The fill is capped at 20 GB in total. Needs a file-based DB_PATH,
+// and CHAOS_ENABLED (chaos.go).
+
+// Directory for the junk files, inside DB_PATH
+const diskFillDirName = "chaos-fill"
+
+// Limits, in MB
+const (
+	maxDiskFillMB     = 20480 // everything in chaos-fill together
+	diskFillReserveMB = 64    // free space left for the database
+	diskFillFileMB    = 64    // size of each junk file
+)
+
+// DiskFillStatus is GET /api/chaos/disk
+type DiskFillStatus struct {
+	Path    string    `json:"path"`
+	Files   int       `json:"files"`
+	Bytes   int64     `json:"bytes"`
+	Writing bool      `json:"writing"`
+	Error   string    `json:"error,omitempty"` // why the last fill stopped early
+	Disk    *DiskInfo `json:"disk,omitempty"`
+}
+
+// diskFill tracks the background writer; the files themselves are the
+// state, so a restart picks up where the last process left off
+type diskFill struct {
+	mu      sync.Mutex
+	cancel  context.CancelFunc // nil when not writing
+	lastErr string
+}
+
+var fill = &diskFill{}
+
+// diskFillDir is where the junk goes, or "" for an in-memory database
+func diskFillDir() string {
+	if storePath == "" || storePath == ":memory:" {
+		return ""
+	}
+	return filepath.Join(storePath, diskFillDirName)
+}
+
+// usage counts the junk files and their bytes
+func (f *diskFill) usage(dir string) (files int, bytes int64) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return 0, 0
+	}
+	for _, entry := range entries {
+		if info, err := entry.Info(); err == nil && !entry.IsDir() {
+			files++
+			bytes += info.Size()
+		}
+	}
+	return files, bytes
+}
+
+// status describes the fill and the volume it's on
+func (f *diskFill) status(dir string) DiskFillStatus {
+	f.mu.Lock()
+	status := DiskFillStatus{Path: dir, Writing: f.cancel != nil, Error: f.lastErr}
+	f.mu.Unlock()
+	status.Files, status.Bytes = f.usage(dir)
+	status.Disk = getDiskInfo()
+	return status
+}
+
+// start writes mb more MB of junk into dir in the background. It fails if
+// a fill is already running or the total would pass the cap.
+func (f *diskFill) start(dir string, mb int) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.cancel != nil {
+		return errDiskFillRunning
+	}
+	_, existing := f.usage(dir)
+	if existing+int64(mb)<<20 > maxDiskFillMB<<20 {
+		return fmt.Errorf("%w (%d MB written already, the cap is %d MB)", errDiskFillCap, existing>>20, maxDiskFillMB)
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	f.cancel, f.lastErr = cancel, ""
+	slog.Warn("disk fill started", "dir", dir, "mb", mb)
+	go func() {
+		err := writeJunk(ctx, dir, int64(mb)<<20)
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		f.cancel = nil
+		if err != nil && !errors.Is(err, context.Canceled) {
+			f.lastErr = err.Error()
+			slog.Warn("disk fill stopped early", "dir", dir, "error", err)
+			return
+		}
+		slog.Info("disk fill finished", "dir", dir, "mb", mb)
+	}()
+	return nil
+}
+
+var (
+	errDiskFillRunning = errors.New("a disk fill is already running")
+	errDiskFillCap     = errors.New("the fill would pass its cap")
+)
+
+// clear stops a running fill and deletes the junk
+func (f *diskFill) clear(dir string) error {
+	f.mu.Lock()
+	if f.cancel != nil {
+		f.cancel()
+	}
+	f.lastErr = ""
+	f.mu.Unlock()
+
+	// A running writer notices the cancel before its next megabyte
+	files, bytes := f.usage(dir)
+	if err := os.RemoveAll(dir); err != nil {
+		return err
+	}
+	slog.Info("disk fill cleared", "dir", dir, "files", files, "bytes", bytes)
+	return nil
+}
+
+// writeJunk writes size bytes of random data into new files in dir,
+// stopping when ctx ends or the disk is nearly full
+func writeJunk(ctx context.Context, dir string, size int64) error {
+	// Random, so compressing or deduplicating filesystems store every byte
+	buf := make([]byte, 1<<20)
+	for i := range buf {
+		buf[i] = byte(rand.Uint32())
+	}
+
+	for size > 0 {
+		fileSize := min(size, diskFillFileMB<<20)
+		if err := writeJunkFile(ctx, dir, fileSize, buf); err != nil {
+			return err
+		}
+		size -= fileSize
+	}
+	return nil
+}
+
+// writeJunkFile writes one file of size bytes (whole megabytes from buf)
+func writeJunkFile(ctx context.Context, dir string, size int64, buf []byte) error {
+	file, err := os.CreateTemp(dir, "fill-*.bin")
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	for written := int64(0); written < size; written += int64(len(buf)) {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if _, free, err := diskUsage(dir); err == nil && free < diskFillReserveMB<<20 {
+			return fmt.Errorf("stopped with less than %d MB free", diskFillReserveMB)
+		}
+		if _, err := file.Write(buf[:min(int64(len(buf)), size-written)]); err != nil {
+			return err
+		}
+	}
+	// Make it real: usage alerts watch the volume, not the page cache
+	return file.Sync()
+}
+
+// chaosDiskHandler handles /api/chaos/disk:
+//
+//	GET    -> files written, and usage of the volume
+//	POST   -> write ?mb= more (in the background)
+//	DELETE -> stop and delete everything written
+func chaosDiskHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	if !chaosAllowed(w) {
+		return
+	}
+	dir := diskFillDir()
+	if dir == "" {
+		http.Error(w, `{"error":"disk fill needs a file-based DB_PATH"}`, http.StatusConflict)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		json.NewEncoder(w).Encode(fill.status(dir))
+	case http.MethodPost:
+		mb, err := strconv.Atoi(r.URL.Query().Get("mb"))
+		if err != nil || mb < 1 || mb > maxDiskFillMB {
+			writeJSONError(w, http.StatusBadRequest, fmt.Sprintf("mb must be a whole number between 1 and %d", maxDiskFillMB))
+			return
+		}
+		if err := fill.start(dir, mb); errors.Is(err, errDiskFillRunning) {
+			writeJSONError(w, http.StatusConflict, err.Error())
+			return
+		} else if errors.Is(err, errDiskFillCap) {
+			writeJSONError(w, http.StatusBadRequest, err.Error())
+			return
+		} else if err != nil {
+			logHandlerError(r.Context(), "chaos_disk", "filesystem", "failed to start disk fill", "dir", dir, "error", err)
+			http.Error(w, `{"error":"failed to start disk fill"}`, http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusAccepted)
+		json.NewEncoder(w).Encode(fill.status(dir))
+	case http.MethodDelete:
+		if err := fill.clear(dir); err != nil {
+			logHandlerError(r.Context(), "chaos_disk", "filesystem", "failed to delete disk fill", "dir", dir, "error", err)
+			http.Error(w, `{"error":"failed to delete disk fill"}`, http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		http.Error(w, `{"error":"method not allowed"}`, http.StatusMethodNotAllowed)
+	}
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestChaosDiskHandler(t *testing.T) {
+	srv := newTestServer(t)
+	prev := chaosEnabled
+	chaosEnabled = true
+	t.Cleanup(func() { chaosEnabled = prev })
+
+	// storePath is ":memory:" in tests: nowhere to write
+	if code, _ := doRequest(t, srv, http.MethodPost, "/api/chaos/disk?mb=1", ""); code != http.StatusConflict {
+		t.Errorf("with an in-memory database = %d, want 409", code)
+	}
+
+	prevPath := storePath
+	storePath = t.TempDir()
+	t.Cleanup(func() { storePath = prevPath })
+
+	for _, query := range []string{"", "mb=0", "mb=x", "mb=999999"} {
+		if code, _ := doRequest(t, srv, http.MethodPost, "/api/chaos/disk?"+query, ""); code != http.StatusBadRequest {
+			t.Errorf("?%s = %d, want 400", query, code)
+		}
+	}
+
+	if code, body := doRequest(t, srv, http.MethodPost, "/api/chaos/disk?mb=2", ""); code != http.StatusAccepted {
+		t.Fatalf("POST ?mb=2 = %d %s", code, body)
+	}
+	var status DiskFillStatus
+	deadline := time.Now().Add(10 * time.Second)
+	for {
+		_, body := doRequest(t, srv, http.MethodGet, "/api/chaos/disk", "")
+		json.Unmarshal(body, &status)
+		if !status.Writing || time.Now().After(deadline) {
+			break
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	if status.Writing || status.Bytes != 2<<20 || status.Files != 1 || status.Error != "" {
+		t.Fatalf("after the fill: %+v", status)
+	}
+	if info := getDiskInfo(); info == nil || info.FillBytes != 2<<20 {
+		t.Errorf("/api/system disk = %+v, want chaos_fill_bytes 2 MB", info)
+	}
+
+	if code, _ := doRequest(t, srv, http.MethodDelete, "/api/chaos/disk", ""); code != http.StatusNoContent {
+		t.Errorf("DELETE = %d", code)
+	}
+	if _, err := os.Stat(filepath.Join(storePath, diskFillDirName)); !os.IsNotExist(err) {
+		t.Errorf("fill directory after DELETE: %v", err)
+	}
+}
+
+func TestDiskFill_DatabaseStillOpens(t *testing.T) {
+	dir := t.TempDir()
+	os.MkdirAll(filepath.Join(dir, diskFillDirName), 0o755)
+	os.WriteFile(filepath.Join(dir, diskFillDirName, "fill-1.bin"), []byte("junk"), 0o644)
+
+	prevPath := storePath
+	t.Cleanup(func() { storePath = prevPath })
+	store, err := initStore(dir)
+	if err != nil {
+		t.Fatalf("BadgerDB with a chaos-fill directory: %v", err)
+	}
+	store.Close()
+}
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+
+	badger "github.com/dgraph-io/badger/v4"
+)
+
+// =============================================================================
+// Display Schema
+// =============================================================================
+//
+// An optional contract for the display panel. Once a schema is registered,
+// POST /api/display bodies that don't match it get 422 with every problem
+// listed, instead of silently replacing the panel:
+//
+//	curl -X PUT http://localhost:8080/api/display/schema -d @tf-outputs.schema.json
+//	curl -X POST http://localhost:8080/api/display -d '{"vpc_id": 42}'
+//	{"error":"validation failed","fields":[{"field":"$.vpc_id","message":"must be of type string (got number)"},
+//	                                       {"field":"$.subnets","message":"is required"}]}
+//
+// That's the "contract between pipeline stages" demo: a Terraform stage
+// posts its outputs, and a changed output shape is caught right there.
+// See jsonschema.go for the supported keywords.
+//
+// The schema is stored in BadgerDB, so it survives restarts and replicates
+// to followers in cluster mode (cluster.go); every replica checks the
+// POSTs it receives.
+
+// Where the schema is stored
+const displaySchemaKey = "schema:display"
+
+// Largest schema accepted
+const maxDisplaySchemaBytes = 64 << 10 // 64 KiB
+
+// readDisplaySchema returns the registered schema document
+// (badger.ErrKeyNotFound if there is none)
+func readDisplaySchema() ([]byte, error) {
+	var data []byte
+	err := dbView("display_schema_get", displaySchemaKey, func(txn *badger.Txn) error {
+		item, err := txn.Get([]byte(displaySchemaKey))
+		if err != nil {
+			return err
+		}
+		data, err = item.ValueCopy(nil)
+		return err
+	})
+	return data, err
+}
+
+// loadDisplaySchema returns the compiled schema, or nil if there is none.
+// Compiling on every POST keeps followers current without any cache to
+// invalidate; schemas are small.
+func loadDisplaySchema() (*jsonSchema, error) {
+	data, err := readDisplaySchema()
+	if errors.Is(err, badger.ErrKeyNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return compileJSONSchema(data)
+}
+
+// displaySchemaHandler handles /api/display/schema (GET, PUT, DELETE)
+func displaySchemaHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	switch r.Method {
+	case http.MethodGet:
+		getDisplaySchema(w, r)
+	case http.MethodPut:
+		putDisplaySchema(w, r)
+	case http.MethodDelete:
+		deleteDisplaySchema(w, r)
+	default:
+		http.Error(w, `{"error":"method not allowed"}`, http.StatusMethodNotAllowed)
+	}
+}
+
+// getDisplaySchema returns the schema as it was registered
+func getDisplaySchema(w http.ResponseWriter, r *http.Request) {
+	data, err := readDisplaySchema()
+	if errors.Is(err, badger.ErrKeyNotFound) {
+		http.Error(w, `{"error":"no display schema registered"}`, http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		logHandlerError(r.Context(), "display_schema", "database", "failed to read display schema", "error", err)
+		http.Error(w, `{"error":"database error"}`, http.StatusInternalServerError)
+		return
+	}
+	w.Write(data)
+}
+
+// putDisplaySchema registers (or replaces) the schema. It must compile;
+// the current display data isn't checked against it.
+func putDisplaySchema(w http.ResponseWriter, r *http.Request) {
+	data, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxDisplaySchemaBytes))
+	if err != nil {
+		http.Error(w, `{"error":"schema too large or unreadable"}`, http.StatusRequestEntityTooLarge)
+		return
+	}
+	if _, err := compileJSONSchema(data); err != nil {
+		writeJSONError(w, http.StatusBadRequest, "invalid schema: "+err.Error())
+		return
+	}
+
+	// Store it compacted, so GET returns clean JSON
+	var compact bytes.Buffer
+	json.Compact(&compact, data)
+	err = dbUpdate("display_schema_put", displaySchemaKey, func(txn *badger.Txn) error {
+		return txn.Set([]byte(displaySchemaKey), compact.Bytes())
+	})
+	if err != nil {
+		logHandlerError(r.Context(), "display_schema", "database", "failed to store display schema", "error", err)
+		http.Error(w, `{"error":"database error"}`, http.StatusInternalServerError)
+		return
+	}
+
+	slog.InfoContext(r.Context(), "display schema registered", "bytes", compact.Len())
+	w.Write(compact.Bytes())
+}
+
+// deleteDisplaySchema removes the schema; display data is accepted as-is again
+func deleteDisplaySchema(w http.ResponseWriter, r *http.Request) {
+	err := dbUpdate("display_schema_delete", displaySchemaKey, func(txn *badger.Txn) error {
+		if _, err := txn.Get([]byte(displaySchemaKey)); err != nil {
+			return err
+		}
+		return txn.Delete([]byte(displaySchemaKey))
+	})
+	if errors.Is(err, badger.ErrKeyNotFound) {
+		http.Error(w, `{"error":"no display schema registered"}`, http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		logHandlerError(r.Context(), "display_schema", "database", "failed to delete display schema", "error", err)
+		http.Error(w, `{"error":"database error"}`, http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestDisplaySchema_EnforcedOnPost(t *testing.T) {
+	srv := newTestServer(t)
+
+	// No schema yet: anything goes
+	if code, _ := doRequest(t, srv, http.MethodGet, "/api/display/schema", ""); code != http.StatusNotFound {
+		t.Errorf("GET without schema = %d, want 404", code)
+	}
+	if code, _ := doRequest(t, srv, http.MethodPost, "/api/display", `{"anything":1}`); code != http.StatusCreated {
+		t.Fatalf("POST without schema = %d, want 201", code)
+	}
+
+	schema := `{"type":"object","required":["vpc_id"],"properties":{"vpc_id":{"type":"string"}}}`
+	if code, body := doRequest(t, srv, http.MethodPut, "/api/display/schema", schema); code != http.StatusOK {
+		t.Fatalf("PUT schema = %d %s", code, body)
+	}
+	if _, body := doRequest(t, srv, http.MethodGet, "/api/display/schema", ""); string(body) != schema {
+		t.Errorf("GET schema = %s", body)
+	}
+
+	// A non-matching body is rejected and the panel keeps its data
+	code, body := doRequest(t, srv, http.MethodPost, "/api/display", `{"vpc_id":42}`)
+	if code != http.StatusUnprocessableEntity {
+		t.Fatalf("invalid POST = %d %s, want 422", code, body)
+	}
+	var result struct {
+		Fields []FieldError `json:"fields"`
+	}
+	json.Unmarshal(body, &result)
+	if len(result.Fields) != 1 || result.Fields[0].Field != "$.vpc_id" {
+		t.Errorf("errors = %s", body)
+	}
+	if got := string(getDisplayData()); got != `{"anything":1}` {
+		t.Errorf("display = %s, want unchanged", got)
+	}
+
+	if code, _ := doRequest(t, srv, http.MethodPost, "/api/display", `{"vpc_id":"vpc-1"}`); code != http.StatusCreated {
+		t.Errorf("valid POST = %d, want 201", code)
+	}
+
+	// Removing the schema lifts the check
+	if code, _ := doRequest(t, srv, http.MethodDelete, "/api/display/schema", ""); code != http.StatusNoContent {
+		t.Fatalf("DELETE schema = %d, want 204", code)
+	}
+	if code, _ := doRequest(t, srv, http.MethodPost, "/api/display", `{"vpc_id":42}`); code != http.StatusCreated {
+		t.Errorf("POST after delete = %d, want 201", code)
+	}
+}
+
+func TestDisplaySchema_PeerCopiesNotRechecked(t *testing.T) {
+	srv := newTestServer(t)
+	doRequest(t, srv, http.MethodPut, "/api/display/schema", `{"type":"object","required":["vpc_id"]}`)
+
+	// The replica that took the POST already checked it
+	_, current := getDisplayDataVersion()
+	if code := postDisplayFromPeer(t, srv, `{"other":true}`, current+100); code != http.StatusCreated {
+		t.Errorf("peer copy status = %d, want 201", code)
+	}
+}
+
+func TestDisplaySchema_Errors(t *testing.T) {
+	srv := newTestServer(t)
+
+	tests := []struct {
+		name       string
+		method     string
+		body       string
+		wantStatus int
+		wantBody   string
+	}{
+		{"invalid JSON", http.MethodPut, `{`, http.StatusBadRequest, "invalid schema"},
+		{"unsupported keyword", http.MethodPut, `{"oneOf":[]}`, http.StatusBadRequest, "not supported"},
+		{"delete without schema", http.MethodDelete, "", http.StatusNotFound, "no display schema"},
+		{"wrong method", http.MethodPost, "{}", http.StatusMethodNotAllowed, "method not allowed"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, body := doRequest(t, srv, tt.method, "/api/display/schema", tt.body)
+			if code != tt.wantStatus || !strings.Contains(string(body), tt.wantBody) {
+				t.Errorf("status = %d %s, want %d containing %q", code, body, tt.wantStatus, tt.wantBody)
+			}
+		})
+	}
+}
@@ -0,0 +1,250 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+)
+
+// =============================================================================
+// Display Source
+// =============================================================================
+//
+// Instead of a pipeline POSTing to /api/display, the app can pull the panel
+// from a URL on its own — a Terraform Cloud state outputs endpoint, an S3
+// presigned URL, any JSON an HTTP GET returns:
+//
+//	DISPLAY_SOURCE_URL=https://app.terraform.io/api/v2/workspaces/ws-abc/current-state-version-outputs
+//	DISPLAY_SOURCE_TOKEN=...        # sent as "Authorization: Bearer ..."
+//	DISPLAY_SOURCE_INTERVAL=30s
+//
+// Polling is cheap when nothing changed: the ETag (and Last-Modified) from
+// the last response are sent back as If-None-Match / If-Modified-Since, and
+// the server answers 304 Not Modified without a body — like a browser cache.
+//
+// When a fetch fails the wait doubles each time (30s, 1m, 2m, ...) up to
+// DISPLAY_SOURCE_MAX_BACKOFF, so a broken endpoint isn't hammered. The first
+// success goes back to the normal interval.
+//
+// In cluster mode only the leader polls; the update reaches the other
+// replicas the same way a POST does (displaysync.go).
+//
+//	curl http://localhost:8080/api/display/source    # what the poller is doing
+
+// Largest response accepted (same cap as the refresh_display scheduled task)
+const maxDisplaySourceBytes = 1 << 20 // 1 MiB
+
+// Shortest allowed DISPLAY_SOURCE_INTERVAL
+const minDisplaySourceInterval = time.Second
+
+// displaySourceStatus is what GET /api/display/source reports
+type displaySourceStatus struct {
+	URL                 string     `json:"url"` // query string hidden: presigned URLs carry credentials
+	Interval            string     `json:"interval"`
+	ETag                string     `json:"etag,omitempty"`
+	LastFetch           *time.Time `json:"last_fetch,omitempty"`
+	LastChange          *time.Time `json:"last_change,omitempty"`
+	LastResult          string     `json:"last_result,omitempty"` // updated, unchanged, skipped, or failed
+	LastError           string     `json:"last_error,omitempty"`
+	ConsecutiveFailures int        `json:"consecutive_failures"`
+	NextFetch           time.Time  `json:"next_fetch"`
+}
+
+// displaySource polls one URL into the display panel
+type displaySource struct {
+	url        string
+	token      string
+	interval   time.Duration
+	maxBackoff time.Duration
+	client     *http.Client
+
+	mu           sync.Mutex
+	status       displaySourceStatus
+	lastModified string
+	lastBody     []byte // for servers that send neither ETag nor Last-Modified
+}
+
+// The configured source; nil unless DISPLAY_SOURCE_URL is set (main.go)
+var displaySrc *displaySource
+
+// newDisplaySource checks the settings and builds a poller
+func newDisplaySource(rawURL, token string, interval, maxBackoff time.Duration) (*displaySource, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("invalid display source URL %q", rawURL)
+	}
+	if interval < minDisplaySourceInterval {
+		return nil, fmt.Errorf("display source interval must be at least %s", minDisplaySourceInterval)
+	}
+	maxBackoff = max(maxBackoff, interval)
+
+	shown := *u
+	shown.User = nil
+	if shown.RawQuery != "" {
+		shown.RawQuery = "..."
+	}
+	return &displaySource{
+		url:        rawURL,
+		token:      token,
+		interval:   interval,
+		maxBackoff: maxBackoff,
+		client:     newOutboundClient(10 * time.Second),
+		status:     displaySourceStatus{URL: shown.String(), Interval: interval.String()},
+	}, nil
+}
+
+// start polls right away, then forever in the background
+func (s *displaySource) start() {
+	go func() {
+		for {
+			time.Sleep(s.poll())
+		}
+	}()
+}
+
+// poll runs one fetch, records the outcome, and returns how long to wait
+// before the next one
+func (s *displaySource) poll() time.Duration {
+	result, err := s.fetch()
+	displaySourceFetchesTotal.WithLabelValues(result).Inc()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now().UTC()
+	s.status.LastFetch = &now
+	s.status.LastResult = result
+	s.status.LastError = ""
+	if err != nil {
+		s.status.LastError = err.Error()
+		s.status.ConsecutiveFailures++
+		slog.Warn("display source fetch failed", "url", s.status.URL,
+			"failures", s.status.ConsecutiveFailures, "error", err)
+	} else {
+		s.status.ConsecutiveFailures = 0
+		if result == "updated" {
+			s.status.LastChange = &now
+			slog.Info("display updated from source", "url", s.status.URL)
+		}
+	}
+
+	wait := s.backoff(s.status.ConsecutiveFailures)
+	s.status.NextFetch = now.Add(wait)
+	return wait
+}
+
+// backoff is the wait after n failures in a row: the interval, doubled per
+// failure, capped at maxBackoff
+func (s *displaySource) backoff(failures int) time.Duration {
+	wait := s.interval
+	for i := 0; i < failures && wait < s.maxBackoff; i++ {
+		wait *= 2
+	}
+	return min(wait, s.maxBackoff)
+}
+
+// fetch GETs the URL and, if it changed, replaces the display panel.
+// The result is "updated", "unchanged", "skipped" (a follower), or "failed".
+func (s *displaySource) fetch() (string, error) {
+	// Followers get the panel from the leader (displaysync.go)
+	if cluster != nil {
+		if _, isLeader := cluster.leader(); !isLeader {
+			return "skipped", nil
+		}
+	}
+
+	req, err := http.NewRequest(http.MethodGet, s.url, nil)
+	if err != nil {
+		return "failed", err
+	}
+	req.Header.Set("Accept", "application/json")
+	if s.token != "" {
+		req.Header.Set("Authorization", "Bearer "+s.token)
+	}
+	s.mu.Lock()
+	if s.status.ETag != "" {
+		req.Header.Set("If-None-Match", s.status.ETag)
+	}
+	if s.lastModified != "" {
+		req.Header.Set("If-Modified-Since", s.lastModified)
+	}
+	s.mu.Unlock()
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return "failed", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotModified {
+		return "unchanged", nil
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return "failed", fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+
+	// Read one byte past the cap to tell "exactly 1 MiB" from "too big"
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxDisplaySourceBytes+1))
+	if err != nil {
+		return "failed", err
+	}
+	if len(body) > maxDisplaySourceBytes {
+		return "failed", fmt.Errorf("response larger than %d bytes", maxDisplaySourceBytes)
+	}
+	var value any
+	if err := json.Unmarshal(body, &value); err != nil {
+		return "failed", errors.New("response is not valid JSON")
+	}
+
+	// The same check a POST gets (displayschema.go)
+	schema, err := loadDisplaySchema()
+	if err != nil {
+		return "failed", err
+	}
+	if schema != nil {
+		if errs := schema.validate(value); len(errs) > 0 {
+			return "failed", fmt.Errorf("response doesn't match the display schema: %s %s", errs[0].Field, errs[0].Message)
+		}
+	}
+
+	s.mu.Lock()
+	s.status.ETag = resp.Header.Get("ETag")
+	s.lastModified = resp.Header.Get("Last-Modified")
+	same := bytes.Equal(body, s.lastBody)
+	s.lastBody = body
+	s.mu.Unlock()
+	if same {
+		return "unchanged", nil
+	}
+
+	data := json.RawMessage(body)
+	version := setDisplayData(data)
+	displayUpdatesTotal.Inc()
+	replicateDisplay(data, version)
+	publishDisplayEvent(data)
+	return "updated", nil
+}
+
+// displaySourceHandler reports the poller's state (GET /api/display/source)
+func displaySourceHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, `{"error":"method not allowed"}`, http.StatusMethodNotAllowed)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if displaySrc == nil {
+		http.Error(w, `{"error":"no display source configured"}`, http.StatusNotFound)
+		return
+	}
+
+	displaySrc.mu.Lock()
+	defer displaySrc.mu.Unlock()
+	json.NewEncoder(w).Encode(displaySrc.status)
+}
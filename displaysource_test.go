@@ -0,0 +1,178 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestDisplaySource_ETagAndChanges(t *testing.T) {
+	newTestServer(t)
+
+	body := `{"vpc_id":"vpc-1"}`
+	var fetches atomic.Int32
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fetches.Add(1)
+		if r.Header.Get("Authorization") != "Bearer secret" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		etag := `"` + body + `"`
+		if r.Header.Get("If-None-Match") == etag {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", etag)
+		w.Write([]byte(body))
+	}))
+	defer upstream.Close()
+
+	src, err := newDisplaySource(upstream.URL+"/outputs?sig=abc", "secret", time.Minute, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if wait := src.poll(); wait != time.Minute || src.status.LastResult != "updated" {
+		t.Fatalf("first poll: wait %v, result %q (%s)", wait, src.status.LastResult, src.status.LastError)
+	}
+	if got := string(getDisplayData()); got != body {
+		t.Errorf("display = %s, want %s", got, body)
+	}
+
+	// Same ETag: the server answers 304 and the panel keeps its version
+	_, version := getDisplayDataVersion()
+	src.poll()
+	if src.status.LastResult != "unchanged" {
+		t.Errorf("second poll result = %q, want unchanged", src.status.LastResult)
+	}
+	if _, v := getDisplayDataVersion(); v != version {
+		t.Error("unchanged poll bumped the display version")
+	}
+
+	body = `{"vpc_id":"vpc-2"}`
+	src.poll()
+	if src.status.LastResult != "updated" || string(getDisplayData()) != body {
+		t.Errorf("after change: result %q, display %s", src.status.LastResult, getDisplayData())
+	}
+	if fetches.Load() != 3 {
+		t.Errorf("fetches = %d, want 3", fetches.Load())
+	}
+
+	// The query string (a presigned signature) isn't shown
+	if strings.Contains(src.status.URL, "sig=abc") {
+		t.Errorf("status URL = %s, leaks the query string", src.status.URL)
+	}
+}
+
+func TestDisplaySource_Backoff(t *testing.T) {
+	newTestServer(t)
+	// One request per poll: no retries, and no circuit breaker cutting in
+	withRetries(t, 0, 0)
+	withCircuits(t, 0, time.Minute)
+
+	failing := true
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if failing {
+			http.Error(w, "down", http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte(`{"ok":true}`))
+	}))
+	defer upstream.Close()
+
+	src, err := newDisplaySource(upstream.URL, "", 10*time.Second, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, want := range []time.Duration{20 * time.Second, 40 * time.Second, time.Minute, time.Minute} {
+		if wait := src.poll(); wait != want {
+			t.Errorf("wait after %d failures = %v, want %v", src.status.ConsecutiveFailures, wait, want)
+		}
+	}
+	if src.status.LastResult != "failed" || !strings.Contains(src.status.LastError, "503") {
+		t.Errorf("status = %+v", src.status)
+	}
+
+	failing = false
+	if wait := src.poll(); wait != 10*time.Second || src.status.ConsecutiveFailures != 0 {
+		t.Errorf("after recovery: wait %v, failures %d", wait, src.status.ConsecutiveFailures)
+	}
+}
+
+func TestDisplaySource_RejectsBadResponses(t *testing.T) {
+	srv := newTestServer(t)
+	doRequest(t, srv, http.MethodPut, "/api/display/schema", `{"type":"object","required":["vpc_id"]}`)
+
+	body := `not json`
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(body))
+	}))
+	defer upstream.Close()
+
+	src, err := newDisplaySource(upstream.URL, "", time.Minute, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range []struct{ body, wantErr string }{
+		{`not json`, "not valid JSON"},
+		{`{"other":1}`, "display schema"},
+	} {
+		body = tt.body
+		src.poll()
+		if src.status.LastResult != "failed" || !strings.Contains(src.status.LastError, tt.wantErr) {
+			t.Errorf("body %s: status %q %q, want error containing %q", tt.body, src.status.LastResult, src.status.LastError, tt.wantErr)
+		}
+	}
+	if getDisplayData() != nil {
+		t.Errorf("display = %s, want untouched", getDisplayData())
+	}
+}
+
+func TestDisplaySource_FollowerSkips(t *testing.T) {
+	newTestServer(t)
+	node := newClusterNode(nil, "", "0")
+	node.isLeader = false
+	node.leaderURL = "http://127.0.0.1:1"
+	withCluster(t, node)
+
+	src, err := newDisplaySource("http://127.0.0.1:1/never-called", "", time.Minute, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	src.poll()
+	if src.status.LastResult != "skipped" || src.status.ConsecutiveFailures != 0 {
+		t.Errorf("follower status = %+v", src.status)
+	}
+}
+
+func TestDisplaySource_Config(t *testing.T) {
+	for _, raw := range []string{"", "ftp://example.com/x", "http://"} {
+		if _, err := newDisplaySource(raw, "", time.Minute, time.Hour); err == nil {
+			t.Errorf("URL %q accepted", raw)
+		}
+	}
+	if _, err := newDisplaySource("https://example.com", "", time.Millisecond, time.Hour); err == nil {
+		t.Error("1ms interval accepted")
+	}
+}
+
+func TestDisplaySourceHandler(t *testing.T) {
+	srv := newTestServer(t)
+	prev := displaySrc
+	t.Cleanup(func() { displaySrc = prev })
+
+	displaySrc = nil
+	if code, _ := doRequest(t, srv, http.MethodGet, "/api/display/source", ""); code != http.StatusNotFound {
+		t.Errorf("unconfigured status = %d, want 404", code)
+	}
+
+	displaySrc, _ = newDisplaySource("https://example.com/state.json", "", 30*time.Second, time.Hour)
+	code, body := doRequest(t, srv, http.MethodGet, "/api/display/source", "")
+	if code != http.StatusOK || !strings.Contains(string(body), `"interval":"30s"`) {
+		t.Errorf("status = %d %s", code, body)
+	}
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// =============================================================================
+// Display Replication
+// =============================================================================
+//
+// The display panel lives in memory, one copy per replica. Behind a load
+// balancer that means "POST /api/display" lands on one pod and the dashboard
+// only shows it when you happen to hit that pod again. In cluster mode
+// (cluster.go) replicas keep their panels in sync two ways:
+//
+//  1. Fan-out: a POST from a client is forwarded right away to every peer,
+//     marked with X-Demo-Display-From so peers don't forward it again.
+//  2. Catch-up: followers compare their version with the leader's on every
+//     heartbeat and copy the leader's panel if it's newer. This fills in new
+//     pods and anything a failed fan-out missed.
+//
+// Every panel carries a version (the time it was set, X-Display-Version).
+// A replica only accepts a copy newer than its own, so when two pods are
+// updated at once they all settle on the later update: last writer wins.
+
+// Headers used between replicas
+const (
+	displayFromHeader    = "X-Demo-Display-From"
+	displayVersionHeader = "X-Display-Version"
+)
+
+// replicateDisplay sends a display update to every peer except this replica.
+// Runs in the background: the client's POST doesn't wait for the peers.
+func replicateDisplay(data json.RawMessage, version int64) {
+	if cluster == nil {
+		return
+	}
+	cluster.mu.RLock()
+	selfURL := cluster.selfURL
+	cluster.mu.RUnlock()
+
+	for _, peer := range cluster.discoverPeers() {
+		if peer == selfURL {
+			continue
+		}
+		go func() {
+			if err := cluster.sendDisplay(peer, data, version); err != nil {
+				displayReplicationsTotal.WithLabelValues("error").Inc()
+				slog.Warn("failed to replicate display data", "peer", peer, "error", err)
+				return
+			}
+			displayReplicationsTotal.WithLabelValues("success").Inc()
+		}()
+	}
+}
+
+// sendDisplay POSTs one display update to a peer
+func (c *clusterNode) sendDisplay(peerURL string, data json.RawMessage, version int64) error {
+	body := data
+	if body == nil {
+		body = json.RawMessage("null") // a reset clears the panel
+	}
+	req, err := http.NewRequest(http.MethodPost, strings.TrimRight(peerURL, "/")+"/api/display", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(displayFromHeader, c.id)
+	req.Header.Set(displayVersionHeader, strconv.FormatInt(version, 10))
+	setInternalKey(req)
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("peer answered %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// syncDisplay copies the leader's display panel if it's newer than ours
+func (c *clusterNode) syncDisplay(leaderURL string) error {
+	resp, err := c.client.Get(strings.TrimRight(leaderURL, "/") + "/api/display")
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("leader answered %d", resp.StatusCode)
+	}
+
+	version, err := strconv.ParseInt(resp.Header.Get(displayVersionHeader), 10, 64)
+	if err != nil {
+		return nil // leader has never been set (or is an older version)
+	}
+	if _, current := getDisplayDataVersion(); version <= current {
+		return nil
+	}
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	setDisplayDataIfNewer(displayFromWire(data), version)
+	return nil
+}
+
+// displayFromWire turns a replicated body back into display data:
+// "null" (a cleared panel) becomes nil, like after a reset
+func displayFromWire(data []byte) json.RawMessage {
+	if string(bytes.TrimSpace(data)) == "null" {
+		return nil
+	}
+	return json.RawMessage(data)
+}
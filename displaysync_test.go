@@ -0,0 +1,120 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// postDisplayFromPeer sends a display update the way another replica would
+func postDisplayFromPeer(t *testing.T, srv *httptest.Server, body string, version int64) int {
+	t.Helper()
+	req, _ := http.NewRequest(http.MethodPost, srv.URL+"/api/display", strings.NewReader(body))
+	req.Header.Set(displayFromHeader, "peer")
+	req.Header.Set(displayVersionHeader, strconv.FormatInt(version, 10))
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	return resp.StatusCode
+}
+
+func TestDisplay_FromPeerLastWriterWins(t *testing.T) {
+	srv := newTestServer(t)
+	_, current := getDisplayDataVersion()
+
+	if code := postDisplayFromPeer(t, srv, `{"from":"newer"}`, current+200); code != http.StatusCreated {
+		t.Fatalf("newer update status = %d, want 201", code)
+	}
+	if code := postDisplayFromPeer(t, srv, `{"from":"older"}`, current+100); code != http.StatusOK {
+		t.Fatalf("older update status = %d, want 200", code)
+	}
+	if got := string(getDisplayData()); got != `{"from":"newer"}` {
+		t.Errorf("display = %s, want the newer update", got)
+	}
+
+	// A cleared panel replicates as null
+	postDisplayFromPeer(t, srv, `null`, current+300)
+	if got := getDisplayData(); got != nil {
+		t.Errorf("display = %s, want cleared", got)
+	}
+
+	req, _ := http.NewRequest(http.MethodPost, srv.URL+"/api/display", strings.NewReader(`{}`))
+	req.Header.Set(displayFromHeader, "peer")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("missing version status = %d, want 400", resp.StatusCode)
+	}
+}
+
+func TestDisplay_FanOutToPeers(t *testing.T) {
+	srv := newTestServer(t)
+
+	type received struct {
+		from, version, body string
+	}
+	got := make(chan received, 1)
+	peer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		got <- received{r.Header.Get(displayFromHeader), r.Header.Get(displayVersionHeader), string(body)}
+		w.WriteHeader(http.StatusCreated)
+	}))
+	defer peer.Close()
+
+	// Ourselves and one peer; we must not send to ourselves
+	node := newClusterNode([]string{srv.URL, peer.URL}, "", "0")
+	node.selfURL = srv.URL
+	withCluster(t, node)
+
+	code, _ := doRequest(t, srv, http.MethodPost, "/api/display", `{"status":"deployed"}`)
+	if code != http.StatusCreated {
+		t.Fatalf("status = %d, want 201", code)
+	}
+
+	select {
+	case r := <-got:
+		_, version := getDisplayDataVersion()
+		if r.from != node.id || r.version != strconv.FormatInt(version, 10) || r.body != `{"status":"deployed"}` {
+			t.Errorf("peer received %+v", r)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("peer never received the display update")
+	}
+}
+
+func TestDisplay_SyncFromLeader(t *testing.T) {
+	newTestServer(t)
+	_, current := getDisplayDataVersion()
+
+	leaderVersion := current + 1000
+	leader := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set(displayVersionHeader, strconv.FormatInt(leaderVersion, 10))
+		io.WriteString(w, `{"from":"leader"}`)
+	}))
+	defer leader.Close()
+
+	node := newClusterNode(nil, "", "0")
+	if err := node.syncDisplay(leader.URL); err != nil {
+		t.Fatalf("syncDisplay: %v", err)
+	}
+	if got := string(getDisplayData()); got != `{"from":"leader"}` {
+		t.Fatalf("display = %s, want the leader's", got)
+	}
+
+	// Ours is newer now: a stale leader copy doesn't overwrite it
+	setDisplayData([]byte(`{"from":"local"}`))
+	leaderVersion = current + 1
+	node.syncDisplay(leader.URL)
+	if got := string(getDisplayData()); got != `{"from":"local"}` {
+		t.Errorf("display = %s, want to keep the local update", got)
+	}
+}
@@ -0,0 +1,260 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"html/template"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"time"
+
+	badger "github.com/dgraph-io/badger/v4"
+)
+
+// =============================================================================
+// Rendered Display
+// =============================================================================
+//
+// GET /api/display/rendered turns the display panel into an HTML page you can
+// put on a projector or send to an audience — no JSON, no dashboard chrome:
+//
+//	open http://localhost:8080/api/display/rendered
+//
+// The page comes from a Go html/template. A built-in template lays out any
+// JSON as nested tables; upload your own to show exactly what matters:
+//
+//	curl -X PUT http://localhost:8080/api/admin/display-template --data-binary @- <<'EOF'
+//	<h1>{{.Data.app}} is live in {{.Data.region}}</h1>
+//	<p>Served by {{.Hostname}}, updated {{.UpdatedAt.Format "15:04:05"}}</p>
+//	EOF
+//	curl -X DELETE http://localhost:8080/api/admin/display-template   # back to built-in
+//
+// html/template (unlike text/template, which rules.go uses) escapes every
+// value for the spot it lands in, so display data containing <script> shows
+// up as text instead of running.
+//
+// Like the display schema, an uploaded template is stored in BadgerDB and
+// replicates to followers in cluster mode.
+
+// Where an uploaded template is stored
+const displayTemplateKey = "template:display"
+
+// Largest template accepted
+const maxDisplayTemplateBytes = 64 << 10 // 64 KiB
+
+// displayTemplateData is what a template can reference
+type displayTemplateData struct {
+	Data      any       // the display JSON, decoded (nil if empty)
+	JSON      string    // the display JSON, indented
+	UpdatedAt time.Time // when the panel was last set (zero if empty)
+	Hostname  string
+	Variant   string
+	Accent    string // the dashboard accent color
+}
+
+// Functions available in display templates
+var displayTemplateFuncs = template.FuncMap{
+	"json":   jsonText,   // {{json .Data.tags}} -> ["a","b"]
+	"typeOf": jsonTypeOf, // object, array, string, number, boolean, null
+}
+
+// defaultDisplayTemplate renders any JSON as nested tables
+const defaultDisplayTemplate = `<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<meta http-equiv="refresh" content="10">
+<title>Demo App Display</title>
+<style>
+body { font-family: -apple-system, BlinkMacSystemFont, "Segoe UI", Roboto, sans-serif;
+       background: #1a1a2e; color: #eee; margin: 0; padding: 2rem;
+       border-top: 6px solid {{.Accent}}; }
+h1 { font-weight: 400; margin-top: 0; }
+table { border-collapse: collapse; margin: 0.25rem 0; }
+th, td { text-align: left; vertical-align: top; padding: 0.35rem 0.75rem; border-bottom: 1px solid #0f3460; }
+th { color: #aaa; font-weight: 500; }
+ol { margin: 0; padding-left: 1.25rem; }
+footer { margin-top: 2rem; color: #777; font-size: 0.85rem; }
+</style>
+</head>
+<body>
+<h1>Display</h1>
+{{if .Data}}{{template "value" .Data}}{{else}}<p>No display data yet.</p>{{end}}
+<footer>{{.Hostname}}{{with .Variant}} &middot; {{.}}{{end}}{{if not .UpdatedAt.IsZero}} &middot; updated {{.UpdatedAt.Format "2006-01-02 15:04:05 MST"}}{{end}}</footer>
+</body>
+</html>
+{{define "value"}}{{$type := typeOf .}}
+{{- if eq $type "object"}}<table>{{range $key, $value := .}}<tr><th>{{$key}}</th><td>{{template "value" $value}}</td></tr>{{end}}</table>
+{{- else if eq $type "array"}}<ol>{{range .}}<li>{{template "value" .}}</li>{{end}}</ol>
+{{- else if eq $type "null"}}&mdash;
+{{- else}}{{.}}{{end}}{{end}}`
+
+// parseDisplayTemplate compiles a template with the display functions
+func parseDisplayTemplate(text string) (*template.Template, error) {
+	return template.New("display").Funcs(displayTemplateFuncs).Parse(text)
+}
+
+// readDisplayTemplate returns the uploaded template
+// (badger.ErrKeyNotFound if there is none)
+func readDisplayTemplate() ([]byte, error) {
+	var data []byte
+	err := dbView("display_template_get", displayTemplateKey, func(txn *badger.Txn) error {
+		item, err := txn.Get([]byte(displayTemplateKey))
+		if err != nil {
+			return err
+		}
+		data, err = item.ValueCopy(nil)
+		return err
+	})
+	return data, err
+}
+
+// activeDisplayTemplate returns the uploaded template's text, or the
+// built-in one
+func activeDisplayTemplate() (string, error) {
+	data, err := readDisplayTemplate()
+	if errors.Is(err, badger.ErrKeyNotFound) {
+		return defaultDisplayTemplate, nil
+	}
+	return string(data), err
+}
+
+// renderedDisplayHandler handles GET /api/display/rendered
+func renderedDisplayHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, `{"error":"method not allowed"}`, http.StatusMethodNotAllowed)
+		return
+	}
+
+	text, err := activeDisplayTemplate()
+	if err != nil {
+		logHandlerError(r.Context(), "display_rendered", "database", "failed to read display template", "error", err)
+		http.Error(w, `{"error":"database error"}`, http.StatusInternalServerError)
+		return
+	}
+	// Parsed on every request, like the schema: nothing to invalidate when
+	// a new template replicates in
+	tmpl, err := parseDisplayTemplate(text)
+	if err != nil {
+		logHandlerError(r.Context(), "display_rendered", "template", "stored display template doesn't parse", "error", err)
+		http.Error(w, `{"error":"invalid display template"}`, http.StatusInternalServerError)
+		return
+	}
+
+	raw, version := getDisplayDataVersion()
+	hostname, _ := os.Hostname()
+	data := displayTemplateData{Hostname: hostname, Variant: variant, Accent: variantColor, JSON: "{}"}
+	if raw != nil {
+		json.Unmarshal(raw, &data.Data) // validated when it was set
+		var indented bytes.Buffer
+		json.Indent(&indented, raw, "", "  ")
+		data.JSON = indented.String()
+		data.UpdatedAt = time.Unix(0, version).UTC()
+	}
+
+	// Render into a buffer so a template error can still become a clean 500
+	// instead of half a page
+	var page bytes.Buffer
+	if err := tmpl.Execute(&page, data); err != nil {
+		logHandlerError(r.Context(), "display_rendered", "template", "failed to render display template", "error", err)
+		writeJSONError(w, http.StatusInternalServerError, "template error: "+err.Error())
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Write(page.Bytes())
+}
+
+// displayTemplateAdminHandler handles /api/admin/display-template
+// (GET, PUT, DELETE)
+func displayTemplateAdminHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		getDisplayTemplate(w, r)
+	case http.MethodPut:
+		putDisplayTemplate(w, r)
+	case http.MethodDelete:
+		deleteDisplayTemplate(w, r)
+	default:
+		w.Header().Set("Content-Type", "application/json")
+		http.Error(w, `{"error":"method not allowed"}`, http.StatusMethodNotAllowed)
+	}
+}
+
+// getDisplayTemplate returns the active template's source; X-Display-Template
+// says whether it's "custom" or "default"
+func getDisplayTemplate(w http.ResponseWriter, r *http.Request) {
+	data, err := readDisplayTemplate()
+	source := "custom"
+	if errors.Is(err, badger.ErrKeyNotFound) {
+		data, err, source = []byte(defaultDisplayTemplate), nil, "default"
+	}
+	if err != nil {
+		logHandlerError(r.Context(), "display_template", "database", "failed to read display template", "error", err)
+		w.Header().Set("Content-Type", "application/json")
+		http.Error(w, `{"error":"database error"}`, http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Header().Set("X-Display-Template", source)
+	w.Write(data)
+}
+
+// putDisplayTemplate uploads a template. It must parse; errors that only
+// show up when rendering (a missing field, say) are reported by GET
+// /api/display/rendered.
+func putDisplayTemplate(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	data, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxDisplayTemplateBytes))
+	if err != nil {
+		http.Error(w, `{"error":"template too large or unreadable"}`, http.StatusRequestEntityTooLarge)
+		return
+	}
+	if len(bytes.TrimSpace(data)) == 0 {
+		http.Error(w, `{"error":"template is empty"}`, http.StatusBadRequest)
+		return
+	}
+	if _, err := parseDisplayTemplate(string(data)); err != nil {
+		writeJSONError(w, http.StatusBadRequest, "invalid template: "+err.Error())
+		return
+	}
+
+	err = dbUpdate("display_template_put", displayTemplateKey, func(txn *badger.Txn) error {
+		return txn.Set([]byte(displayTemplateKey), data)
+	})
+	if err != nil {
+		logHandlerError(r.Context(), "display_template", "database", "failed to store display template", "error", err)
+		http.Error(w, `{"error":"database error"}`, http.StatusInternalServerError)
+		return
+	}
+
+	slog.InfoContext(r.Context(), "display template uploaded", "bytes", len(data))
+	json.NewEncoder(w).Encode(map[string]any{"status": "template updated", "bytes": len(data)})
+}
+
+// deleteDisplayTemplate removes the uploaded template; the built-in one
+// is used again
+func deleteDisplayTemplate(w http.ResponseWriter, r *http.Request) {
+	err := dbUpdate("display_template_delete", displayTemplateKey, func(txn *badger.Txn) error {
+		if _, err := txn.Get([]byte(displayTemplateKey)); err != nil {
+			return err
+		}
+		return txn.Delete([]byte(displayTemplateKey))
+	})
+	if errors.Is(err, badger.ErrKeyNotFound) {
+		w.Header().Set("Content-Type", "application/json")
+		http.Error(w, `{"error":"no custom display template"}`, http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		logHandlerError(r.Context(), "display_template", "database", "failed to delete display template", "error", err)
+		w.Header().Set("Content-Type", "application/json")
+		http.Error(w, `{"error":"database error"}`, http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestRenderedDisplay_DefaultTemplate(t *testing.T) {
+	srv := newTestServer(t)
+
+	code, body := doRequest(t, srv, http.MethodGet, "/api/display/rendered", "")
+	if code != http.StatusOK || !strings.Contains(string(body), "No display data yet") {
+		t.Fatalf("empty panel = %d %s", code, body)
+	}
+
+	doRequest(t, srv, http.MethodPost, "/api/display",
+		`{"region":"us-east-1","subnets":["a","b"],"owner":null,"note":"<script>alert(1)</script>"}`)
+	_, body = doRequest(t, srv, http.MethodGet, "/api/display/rendered", "")
+	page := string(body)
+	for _, want := range []string{
+		"<th>region</th><td>us-east-1</td>",
+		"<ol><li>a</li><li>b</li></ol>",
+		"<th>owner</th><td>&mdash;</td>",
+		"&lt;script&gt;alert(1)&lt;/script&gt;", // escaped, not run
+		"updated ",
+	} {
+		if !strings.Contains(page, want) {
+			t.Errorf("page missing %q:\n%s", want, page)
+		}
+	}
+	if strings.Contains(page, "<script>") {
+		t.Error("display data was not escaped")
+	}
+}
+
+func TestRenderedDisplay_CustomTemplate(t *testing.T) {
+	srv := newTestServer(t)
+	doRequest(t, srv, http.MethodPost, "/api/display", `{"app":"shop","tags":["x","y"]}`)
+
+	tmpl := `<h1>{{.Data.app}}</h1><pre>{{json .Data.tags}}</pre>`
+	if code, body := doRequest(t, srv, http.MethodPut, "/api/admin/display-template", tmpl); code != http.StatusOK {
+		t.Fatalf("PUT template = %d %s", code, body)
+	}
+	resp, err := http.Get(srv.URL + "/api/admin/display-template")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.Header.Get("X-Display-Template") != "custom" {
+		t.Errorf("X-Display-Template = %q, want custom", resp.Header.Get("X-Display-Template"))
+	}
+
+	_, body := doRequest(t, srv, http.MethodGet, "/api/display/rendered", "")
+	if want := `<h1>shop</h1><pre>[&#34;x&#34;,&#34;y&#34;]</pre>`; string(body) != want {
+		t.Errorf("rendered = %s, want %s", body, want)
+	}
+
+	// Errors that only show when rendering come back as a 500 with the reason
+	doRequest(t, srv, http.MethodPut, "/api/admin/display-template", `{{.Data.app.name}}`)
+	code, body := doRequest(t, srv, http.MethodGet, "/api/display/rendered", "")
+	if code != http.StatusInternalServerError || !strings.Contains(string(body), "template error") {
+		t.Errorf("bad template = %d %s, want 500", code, body)
+	}
+
+	// DELETE goes back to the built-in template
+	if code, _ := doRequest(t, srv, http.MethodDelete, "/api/admin/display-template", ""); code != http.StatusNoContent {
+		t.Errorf("DELETE = %d, want 204", code)
+	}
+	if _, body := doRequest(t, srv, http.MethodGet, "/api/display/rendered", ""); !strings.Contains(string(body), "<th>app</th>") {
+		t.Errorf("after delete = %s", body)
+	}
+}
+
+func TestDisplayTemplateAdmin_Errors(t *testing.T) {
+	srv := newTestServer(t)
+
+	tests := []struct {
+		name       string
+		method     string
+		path       string
+		body       string
+		wantStatus int
+	}{
+		{"unparseable template", http.MethodPut, "/api/admin/display-template", `{{if}}`, http.StatusBadRequest},
+		{"unknown function", http.MethodPut, "/api/admin/display-template", `{{shout .Data}}`, http.StatusBadRequest},
+		{"empty template", http.MethodPut, "/api/admin/display-template", "  ", http.StatusBadRequest},
+		{"delete without custom", http.MethodDelete, "/api/admin/display-template", "", http.StatusNotFound},
+		{"wrong method", http.MethodPost, "/api/admin/display-template", "x", http.StatusMethodNotAllowed},
+		{"rendered is read-only", http.MethodPost, "/api/display/rendered", "", http.StatusMethodNotAllowed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if code, body := doRequest(t, srv, tt.method, tt.path, tt.body); code != tt.wantStatus {
+				t.Errorf("status = %d %s, want %d", code, body, tt.wantStatus)
+			}
+		})
+	}
+}
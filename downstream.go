@@ -0,0 +1,207 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// =============================================================================
+// Downstream Fan-Out
+// =============================================================================
+//
+// Turns one demo-app into a multi-service topology. List other services in
+// DOWNSTREAM_URLS and GET /api/downstream calls them all at once and reports
+// who answered and how fast:
+//
+//	DOWNSTREAM_URLS=http://orders:8080,http://payments:8080 ./demo-app
+//	curl http://localhost:8080/api/downstream
+//	{"status":"ok","request_id":"5f1c...","trace_id":"4bf9...","downstreams":[
+//	  {"url":"http://orders:8080/health","healthy":true,"status_code":200,"latency_ms":1.8,...}]}
+//
+// A URL without a path gets /health appended; a URL with a path is called
+// as-is. Point one demo-app at another's /api/downstream and the calls chain
+// — a -> b -> c — with each report nested in the one above it. A failure
+// deep in the chain turns every level above it 502, which is what cascading
+// failure looks like.
+//
+// Every call carries X-Request-ID and a W3C traceparent (trace.go), taken
+// from the incoming request or started here, so the downstream services'
+// logs line up with ours by request_id or trace_id.
+
+// Header that carries the request ID
+const requestIDHeader = "X-Request-ID"
+
+// Largest downstream response body included in the report
+const maxDownstreamBody = 64 << 10 // 64 KiB
+
+// Services to call and how long to wait for each, from DOWNSTREAM_URLS and
+// DOWNSTREAM_TIMEOUT (set in main)
+var (
+	downstreamURLs    []string
+	downstreamTimeout = 2 * time.Second
+)
+
+// DownstreamResult is one service's answer
+type DownstreamResult struct {
+	URL        string          `json:"url"`
+	Healthy    bool            `json:"healthy"` // answered 2xx
+	StatusCode int             `json:"status_code,omitempty"`
+	LatencyMs  float64         `json:"latency_ms"`
+	Error      string          `json:"error,omitempty"`
+	Response   json.RawMessage `json:"response,omitempty"` // the body, when it's JSON
+}
+
+// DownstreamReport is the GET /api/downstream response
+type DownstreamReport struct {
+	Status      string             `json:"status"` // ok, degraded (some failed), or down (all failed)
+	RequestID   string             `json:"request_id"`
+	TraceID     string             `json:"trace_id"`
+	Downstreams []DownstreamResult `json:"downstreams"`
+}
+
+// downstreamCallURL adds /health to a URL that has no path
+func downstreamCallURL(raw string) (string, error) {
+	u, err := url.Parse(raw)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return "", fmt.Errorf("invalid downstream URL %q: must be http(s)://host[:port][/path]", raw)
+	}
+	if u.Path == "" || u.Path == "/" {
+		u.Path = "/health"
+	}
+	return u.String(), nil
+}
+
+// newRequestID returns 16 random bytes as hex
+func newRequestID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// callDownstream GETs one service, passing the request ID and trace along
+func callDownstream(ctx context.Context, client *http.Client, target, requestID string, tc traceContext) DownstreamResult {
+	result := DownstreamResult{URL: target}
+	start := time.Now()
+	defer func() { result.LatencyMs = float64(time.Since(start).Microseconds()) / 1000 }()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, target, nil)
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	req.Header.Set(requestIDHeader, requestID)
+	req.Header.Set("traceparent", tc.traceparent())
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	defer resp.Body.Close()
+
+	result.StatusCode = resp.StatusCode
+	result.Healthy = resp.StatusCode >= 200 && resp.StatusCode < 300
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxDownstreamBody+1))
+	if err != nil {
+		result.Healthy = false
+		result.Error = "reading response: " + err.Error()
+		return result
+	}
+	if len(body) <= maxDownstreamBody && json.Valid(body) {
+		result.Response = body
+	}
+	if !result.Healthy {
+		result.Error = resp.Status
+	}
+	return result
+}
+
+// downstreamHandler handles GET /api/downstream
+//
+// 200 when every downstream is healthy (or none are configured), 502 when
+// any of them isn't — the report is in the body either way.
+func downstreamHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, `{"error":"method not allowed"}`, http.StatusMethodNotAllowed)
+		return
+	}
+
+	requestID := r.Header.Get(requestIDHeader)
+	if requestID == "" {
+		requestID = newRequestID()
+	}
+	tc, ok := traceContextFrom(r.Context())
+	if !ok {
+		tc = newTraceContext()
+		r = r.WithContext(withTraceContext(r.Context(), tc))
+	}
+
+	client := newOutboundClient(downstreamTimeout)
+	// A redirect is an answer worth reporting, not following
+	client.CheckRedirect = func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }
+
+	// Call them all at once; each goroutine fills in its own slot
+	results := make([]DownstreamResult, len(downstreamURLs))
+	var wg sync.WaitGroup
+	for i, raw := range downstreamURLs {
+		target, err := downstreamCallURL(raw)
+		if err != nil {
+			results[i] = DownstreamResult{URL: raw, Error: err.Error()}
+			continue
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i] = callDownstream(r.Context(), client, target, requestID, tc)
+		}()
+	}
+	wg.Wait()
+
+	report := DownstreamReport{Status: "ok", RequestID: requestID, TraceID: tc.TraceID, Downstreams: results}
+	healthy := 0
+	for _, result := range results {
+		if result.Healthy {
+			healthy++
+		}
+	}
+	status := http.StatusOK
+	if healthy < len(results) {
+		status = http.StatusBadGateway
+		report.Status = "degraded"
+		if healthy == 0 {
+			report.Status = "down"
+		}
+		slog.WarnContext(r.Context(), "downstream calls failed",
+			"request_id", requestID, "failed", len(results)-healthy, "total", len(results))
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set(requestIDHeader, requestID)
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(report)
+}
+
+// validateDownstreamURLs checks DOWNSTREAM_URLS at startup
+func validateDownstreamURLs(urls []string) error {
+	var bad []string
+	for _, raw := range urls {
+		if _, err := downstreamCallURL(raw); err != nil {
+			bad = append(bad, raw)
+		}
+	}
+	if len(bad) > 0 {
+		return fmt.Errorf("invalid DOWNSTREAM_URLS (need http(s)://host[:port][/path]): %s", strings.Join(bad, ", "))
+	}
+	return nil
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// withDownstreams points DOWNSTREAM_URLS at urls for one test
+func withDownstreams(t *testing.T, urls ...string) {
+	t.Helper()
+	prev := downstreamURLs
+	downstreamURLs = urls
+	t.Cleanup(func() { downstreamURLs = prev })
+}
+
+func TestDownstream_PropagatesIDsAndReports(t *testing.T) {
+	srv := newTestServer(t)
+
+	var gotPath, gotRequestID, gotTraceparent string
+	healthy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotPath = r.URL.Path
+		gotRequestID = r.Header.Get(requestIDHeader)
+		gotTraceparent = r.Header.Get("traceparent")
+		w.Write([]byte(`{"status":"ok"}`))
+	}))
+	defer healthy.Close()
+	withDownstreams(t, healthy.URL)
+
+	req, _ := http.NewRequest(http.MethodGet, srv.URL+"/api/downstream", nil)
+	req.Header.Set(requestIDHeader, "req-123")
+	req.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	var report DownstreamReport
+	json.NewDecoder(resp.Body).Decode(&report)
+	if resp.StatusCode != http.StatusOK || report.Status != "ok" {
+		t.Fatalf("status = %d %+v", resp.StatusCode, report)
+	}
+	if report.RequestID != "req-123" || resp.Header.Get(requestIDHeader) != "req-123" || gotRequestID != "req-123" {
+		t.Errorf("request ID: report %q, header %q, downstream %q", report.RequestID, resp.Header.Get(requestIDHeader), gotRequestID)
+	}
+	if gotPath != "/health" {
+		t.Errorf("downstream path = %q, want /health", gotPath)
+	}
+	// Same trace, our span as the parent — not the caller's span
+	if !strings.HasPrefix(gotTraceparent, "00-4bf92f3577b34da6a3ce929d0e0e4736-") ||
+		strings.Contains(gotTraceparent, "00f067aa0ba902b7") || !strings.HasSuffix(gotTraceparent, "-01") {
+		t.Errorf("traceparent = %q", gotTraceparent)
+	}
+	if len(report.Downstreams) != 1 || !report.Downstreams[0].Healthy || string(report.Downstreams[0].Response) != `{"status":"ok"}` {
+		t.Errorf("downstreams = %+v", report.Downstreams)
+	}
+}
+
+func TestDownstream_StartsTraceAndRequestID(t *testing.T) {
+	srv := newTestServer(t)
+
+	var gotTraceparent string
+	ds := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotTraceparent = r.Header.Get("traceparent")
+	}))
+	defer ds.Close()
+	withDownstreams(t, ds.URL)
+
+	_, body := doRequest(t, srv, http.MethodGet, "/api/downstream", "")
+	var report DownstreamReport
+	json.Unmarshal(body, &report)
+	if !isLowerHex(report.RequestID, 32) || !isLowerHex(report.TraceID, 32) {
+		t.Errorf("report = %s", body)
+	}
+	if tc, ok := parseTraceparent(gotTraceparent); !ok || !strings.Contains(gotTraceparent, report.TraceID) || tc.sampled() {
+		t.Errorf("traceparent = %q, trace %q; want an unsampled trace", gotTraceparent, report.TraceID)
+	}
+}
+
+func TestDownstream_Failures(t *testing.T) {
+	srv := newTestServer(t)
+
+	ok := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer ok.Close()
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "nope", http.StatusServiceUnavailable)
+	}))
+	defer failing.Close()
+	closed := httptest.NewServer(nil)
+	closed.Close()
+
+	tests := []struct {
+		name       string
+		urls       []string
+		wantStatus int
+		want       string
+	}{
+		{"none configured", nil, http.StatusOK, "ok"},
+		{"some failing", []string{ok.URL, failing.URL + "/ready"}, http.StatusBadGateway, "degraded"},
+		{"all failing", []string{failing.URL, closed.URL}, http.StatusBadGateway, "down"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			withDownstreams(t, tt.urls...)
+			code, body := doRequest(t, srv, http.MethodGet, "/api/downstream", "")
+			var report DownstreamReport
+			json.Unmarshal(body, &report)
+			if code != tt.wantStatus || report.Status != tt.want || len(report.Downstreams) != len(tt.urls) {
+				t.Errorf("got %d %s, want %d %q", code, body, tt.wantStatus, tt.want)
+			}
+		})
+	}
+
+	if code, _ := doRequest(t, srv, http.MethodPost, "/api/downstream", ""); code != http.StatusMethodNotAllowed {
+		t.Errorf("POST = %d, want 405", code)
+	}
+}
+
+func TestValidateDownstreamURLs(t *testing.T) {
+	if err := validateDownstreamURLs([]string{"http://orders:8080", "https://b/api/downstream"}); err != nil {
+		t.Errorf("valid URLs: %v", err)
+	}
+	if err := validateDownstreamURLs([]string{"orders:8080"}); err == nil {
+		t.Error("expected an error for a URL without a scheme")
+	}
+	if got, _ := downstreamCallURL("http://b:8080/api/downstream?x=1"); got != "http://b:8080/api/downstream?x=1" {
+		t.Errorf("path kept = %q", got)
+	}
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	badger "github.com/dgraph-io/badger/v4"
+)
+
+// =============================================================================
+// Durability Mode
+// =============================================================================
+//
+// By default BadgerDB acknowledges a commit once it's in the operating
+// system's page cache: fast, but a power cut (not a crash of the app) can
+// lose the last few writes. DURABILITY_MODE=sync makes every commit wait
+// for fsync, so an acknowledged write is on disk:
+//
+//	DURABILITY_MODE=fast DB_PATH=/data ./demo-app   # the default
+//	DURABILITY_MODE=sync DB_PATH=/data ./demo-app   # every write fsyncs
+//
+// For the "durability vs performance" segment, run the same load against
+// each and compare write latency in /metrics, labelled by mode:
+//
+//	histogram_quantile(0.99, sum by (durability, le)
+//	  (rate(demoapp_db_write_duration_seconds_bucket[1m])))
+//
+// An in-memory database (DB_PATH=:memory:) has nothing to fsync; its
+// writes are labelled "memory". DURABILITY_MODE is a friendlier name for
+// DB_SYNC_WRITES (dboptions.go): set one or the other, or both the same.
+
+// Durability modes, also the durability label on the write histogram
+const (
+	durabilityFast   = "fast"
+	durabilitySync   = "sync"
+	durabilityMemory = "memory"
+)
+
+// writeDurability is the open database's mode (set by initStore)
+var writeDurability = durabilityMemory
+
+// parseDurabilityMode reads DURABILITY_MODE into whether commits fsync
+func parseDurabilityMode(value string) (syncWrites bool, err error) {
+	switch strings.ToLower(strings.TrimSpace(value)) {
+	case durabilityFast:
+		return false, nil
+	case durabilitySync, "fsync":
+		return true, nil
+	default:
+		return false, fmt.Errorf(`DURABILITY_MODE must be "fast" or "sync", got %q`, value)
+	}
+}
+
+// durabilityOf is the mode Badger options give
+func durabilityOf(opts badger.Options) string {
+	switch {
+	case opts.InMemory:
+		return durabilityMemory
+	case opts.SyncWrites:
+		return durabilitySync
+	default:
+		return durabilityFast
+	}
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"testing"
+
+	badger "github.com/dgraph-io/badger/v4"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+func TestParseDBTuning_DurabilityMode(t *testing.T) {
+	got, err := parseDBTuning(envMap(map[string]string{"DURABILITY_MODE": "sync"}))
+	if err != nil || !got.SyncWrites {
+		t.Errorf("sync = %+v, %v", got, err)
+	}
+	// Both set the same way is fine; disagreeing isn't
+	if _, err := parseDBTuning(envMap(map[string]string{"DURABILITY_MODE": "fast", "DB_SYNC_WRITES": "false"})); err != nil {
+		t.Errorf("agreeing = %v", err)
+	}
+	if _, err := parseDBTuning(envMap(map[string]string{"DURABILITY_MODE": "fast", "DB_SYNC_WRITES": "true"})); err == nil {
+		t.Error("disagreeing DURABILITY_MODE and DB_SYNC_WRITES should fail")
+	}
+	if _, err := parseDBTuning(envMap(map[string]string{"DURABILITY_MODE": "paranoid"})); err == nil {
+		t.Error("unknown mode should fail")
+	}
+}
+
+func TestDurability_WritesLabelledByMode(t *testing.T) {
+	prevOptions, prevDB, prevPath, prevMode := dbOptions, db, storePath, writeDurability
+	t.Cleanup(func() { dbOptions, db, storePath, writeDurability = prevOptions, prevDB, prevPath, prevMode })
+
+	dbOptions, _ = parseDBTuning(envMap(map[string]string{"DURABILITY_MODE": "sync"}))
+	store, err := initStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+	db = store
+	if writeDurability != durabilitySync {
+		t.Fatalf("durability = %q, want sync", writeDurability)
+	}
+
+	err = dbUpdate("durability_test", "k", func(txn *badger.Txn) error { return txn.Set([]byte("k"), []byte("v")) })
+	if err != nil {
+		t.Fatal(err)
+	}
+	var m dto.Metric
+	dbWriteDuration.WithLabelValues(durabilitySync, "durability_test").(prometheus.Histogram).Write(&m)
+	if m.GetHistogram().GetSampleCount() != 1 {
+		t.Errorf("sync writes observed = %d, want 1", m.GetHistogram().GetSampleCount())
+	}
+}
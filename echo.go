@@ -0,0 +1,93 @@
+package main
+
+import (
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"net/http"
+	"unicode/utf8"
+)
+
+// =============================================================================
+// Echo Endpoint
+// =============================================================================
+//
+// ANY /api/echo (and /api/echo/anything/below) reflects the request back as
+// JSON, like httpbin's /anything. When the app sits behind an ingress,
+// gateway, or service mesh, this shows exactly what arrived after every hop:
+// rewritten paths, injected headers, stripped query params, TLS termination.
+
+// Echoed request bodies are capped at 1MB
+const maxEchoBodySize = 1 << 20
+
+// EchoResponse is the reflected request
+type EchoResponse struct {
+	Method        string              `json:"method"`
+	URL           string              `json:"url"`
+	Path          string              `json:"path"`
+	Host          string              `json:"host"`
+	Proto         string              `json:"proto"`
+	RemoteAddr    string              `json:"remote_addr"`
+	Query         map[string][]string `json:"query"`
+	Headers       map[string][]string `json:"headers"`
+	ContentLength int64               `json:"content_length"`
+	Body          string              `json:"body"`
+	BodyBase64    bool                `json:"body_base64,omitempty"` // true when the body wasn't UTF-8 text
+	JSON          json.RawMessage     `json:"json,omitempty"`        // body again, parsed, if it was JSON
+	TLS           *EchoTLS            `json:"tls"`                   // null for plain HTTP
+}
+
+// EchoTLS describes the TLS connection, when the app terminates TLS itself
+type EchoTLS struct {
+	Version            string `json:"version"`
+	CipherSuite        string `json:"cipher_suite"`
+	ServerName         string `json:"server_name,omitempty"` // SNI
+	NegotiatedProtocol string `json:"negotiated_protocol,omitempty"`
+	PeerCertificates   int    `json:"peer_certificates"`
+}
+
+// echoHandler reflects any request back as JSON
+func echoHandler(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxEchoBodySize))
+	if err != nil {
+		http.Error(w, `{"error":"failed to read body"}`, http.StatusBadRequest)
+		return
+	}
+
+	resp := EchoResponse{
+		Method:        r.Method,
+		URL:           r.URL.String(),
+		Path:          r.URL.Path,
+		Host:          r.Host,
+		Proto:         r.Proto,
+		RemoteAddr:    r.RemoteAddr,
+		Query:         r.URL.Query(),
+		Headers:       r.Header,
+		ContentLength: r.ContentLength,
+	}
+
+	// Binary bodies would be mangled in a JSON string, so base64 them
+	if utf8.Valid(body) {
+		resp.Body = string(body)
+	} else {
+		resp.Body = base64.StdEncoding.EncodeToString(body)
+		resp.BodyBase64 = true
+	}
+	if len(body) > 0 && json.Valid(body) {
+		resp.JSON = body
+	}
+
+	if r.TLS != nil {
+		resp.TLS = &EchoTLS{
+			Version:            tls.VersionName(r.TLS.Version),
+			CipherSuite:        tls.CipherSuiteName(r.TLS.CipherSuite),
+			ServerName:         r.TLS.ServerName,
+			NegotiatedProtocol: r.TLS.NegotiatedProtocol,
+			PeerCertificates:   len(r.TLS.PeerCertificates),
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
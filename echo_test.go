@@ -0,0 +1,55 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestEcho_ReflectsRequest(t *testing.T) {
+	req := httptest.NewRequest("PATCH", "/api/echo/some/path?x=1&x=2", strings.NewReader(`{"hello":"world"}`))
+	req.Header.Set("X-Trace", "abc")
+	rr := httptest.NewRecorder()
+
+	echoHandler(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", rr.Code)
+	}
+
+	var resp EchoResponse
+	if err := json.NewDecoder(rr.Body).Decode(&resp); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+
+	if resp.Method != "PATCH" || resp.Path != "/api/echo/some/path" {
+		t.Errorf("unexpected method/path: %s %s", resp.Method, resp.Path)
+	}
+	if got := resp.Query["x"]; len(got) != 2 || got[1] != "2" {
+		t.Errorf("expected repeated query values, got %v", got)
+	}
+	if got := resp.Headers["X-Trace"]; len(got) != 1 || got[0] != "abc" {
+		t.Errorf("expected X-Trace header, got %v", got)
+	}
+	if resp.Body != `{"hello":"world"}` || string(resp.JSON) != `{"hello":"world"}` {
+		t.Errorf("unexpected body: %q / %s", resp.Body, resp.JSON)
+	}
+	if resp.TLS != nil {
+		t.Error("expected no TLS info for plain HTTP")
+	}
+}
+
+func TestEcho_BinaryBodyIsBase64(t *testing.T) {
+	req := httptest.NewRequest("POST", "/api/echo", strings.NewReader("\xff\xfe"))
+	rr := httptest.NewRecorder()
+
+	echoHandler(rr, req)
+
+	var resp EchoResponse
+	json.NewDecoder(rr.Body).Decode(&resp)
+	if !resp.BodyBase64 || resp.Body != "//4=" {
+		t.Errorf("expected base64 body, got %q (base64=%v)", resp.Body, resp.BodyBase64)
+	}
+}
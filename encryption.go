@@ -0,0 +1,125 @@
+package main
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	badger "github.com/dgraph-io/badger/v4"
+)
+
+// =============================================================================
+// Encryption at Rest
+// =============================================================================
+//
+// BadgerDB can encrypt everything it writes to disk with AES. Set
+// DB_ENCRYPTION_KEY to a hex-encoded 16, 24, or 32 byte key (AES-128/192/256):
+//
+//	DB_ENCRYPTION_KEY=$(openssl rand -hex 32) DB_PATH=/data ./demo-app
+//
+// How Badger does it: the key you provide is the *master key*. It only
+// encrypts a small "key registry" file holding randomly generated *data keys*,
+// which encrypt the actual data (a new one every 10 days). That's why the
+// master key can be rotated cheaply — only the registry is re-encrypted:
+//
+//	DB_ENCRYPTION_KEY=<new> DB_ENCRYPTION_KEY_PREVIOUS=<old> ./demo-app
+//
+// Leaving DB_ENCRYPTION_KEY_PREVIOUS set after the rotation is harmless;
+// it's skipped once the registry no longer matches it.
+//
+// Only applies to file-based databases (DB_PATH); in-memory data never
+// touches the disk.
+
+// Set from DB_ENCRYPTION_KEY / DB_ENCRYPTION_KEY_PREVIOUS by
+// loadEncryptionKeys before initStore runs. nil means no encryption.
+var (
+	dbEncryptionKey         []byte
+	dbEncryptionKeyPrevious []byte
+)
+
+// Memory for Badger's index cache. Badger recommends one with encryption,
+// otherwise every table index is decrypted on each lookup.
+const encryptedIndexCacheSize = 100 << 20 // 100 MB
+
+// parseEncryptionKey decodes a hex key and checks it's a valid AES length.
+// The messages name the env var since that's what the user has to fix.
+func parseEncryptionKey(envName, value string) ([]byte, error) {
+	if value == "" {
+		return nil, nil
+	}
+	key, err := hex.DecodeString(strings.TrimSpace(value))
+	if err != nil {
+		return nil, fmt.Errorf("%s must be hex-encoded (e.g. openssl rand -hex 32): %w", envName, err)
+	}
+	switch len(key) {
+	case 16, 24, 32:
+		return key, nil
+	default:
+		return nil, fmt.Errorf("%s must be 16, 24, or 32 bytes (32, 48, or 64 hex characters), got %d bytes", envName, len(key))
+	}
+}
+
+// loadEncryptionKeys reads DB_ENCRYPTION_KEY and DB_ENCRYPTION_KEY_PREVIOUS
+// for the database at dbPath. The server and the offline subcommands (cli.go)
+// both call it, so a data directory opens the same way from either.
+func loadEncryptionKeys(dbPath string) error {
+	var err error
+	dbEncryptionKey, err = parseEncryptionKey("DB_ENCRYPTION_KEY", secret("DB_ENCRYPTION_KEY"))
+	if err == nil {
+		dbEncryptionKeyPrevious, err = parseEncryptionKey("DB_ENCRYPTION_KEY_PREVIOUS", secret("DB_ENCRYPTION_KEY_PREVIOUS"))
+	}
+	if err != nil {
+		return err
+	}
+	if dbEncryptionKeyPrevious != nil && dbEncryptionKey == nil {
+		return errors.New("DB_ENCRYPTION_KEY_PREVIOUS is set but DB_ENCRYPTION_KEY is not")
+	}
+	if dbEncryptionKey != nil && (dbPath == "" || dbPath == ":memory:") {
+		slog.Warn("DB_ENCRYPTION_KEY ignored: in-memory databases are never written to disk")
+		dbEncryptionKey, dbEncryptionKeyPrevious = nil, nil
+	}
+	return nil
+}
+
+// rotateEncryptionKey re-encrypts the key registry in dir from oldKey to newKey.
+// Returns rotated=false (and no error) if the registry isn't encrypted with
+// oldKey — usually because the rotation already happened on an earlier start.
+func rotateEncryptionKey(dir string, oldKey, newKey []byte) (rotated bool, err error) {
+	opts := badger.KeyRegistryOptions{
+		Dir:                           dir,
+		ReadOnly:                      true,
+		EncryptionKey:                 oldKey,
+		EncryptionKeyRotationDuration: 10 * 24 * time.Hour, // Badger's default
+	}
+	registry, err := badger.OpenKeyRegistry(opts)
+	if errors.Is(err, badger.ErrEncryptionKeyMismatch) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	defer registry.Close()
+
+	opts.EncryptionKey = newKey
+	if err := badger.WriteKeyRegistry(registry, opts); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// explainOpenError turns Badger's terse "Encryption key mismatch" into
+// something that says which setting to check
+func explainOpenError(err error) error {
+	if !errors.Is(err, badger.ErrEncryptionKeyMismatch) {
+		return err
+	}
+	if dbEncryptionKey == nil {
+		return fmt.Errorf("database is encrypted: set DB_ENCRYPTION_KEY to the key it was created with: %w", err)
+	}
+	return fmt.Errorf("DB_ENCRYPTION_KEY does not match this database "+
+		"(it was created with a different key, or without encryption; "+
+		"to rotate keys, set DB_ENCRYPTION_KEY_PREVIOUS to the old key): %w", err)
+}
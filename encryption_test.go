@@ -0,0 +1,105 @@
+package main
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	badger "github.com/dgraph-io/badger/v4"
+)
+
+func TestParseEncryptionKey(t *testing.T) {
+	tests := []struct {
+		value   string
+		wantLen int
+		wantErr bool
+	}{
+		{"", 0, false},
+		{strings.Repeat("ab", 16), 16, false},
+		{strings.Repeat("ab", 24), 24, false},
+		{strings.Repeat("ab", 32), 32, false},
+		{strings.Repeat("ab", 8), 0, true},   // 8 bytes: too short
+		{"not-hex-at-all!", 0, true},         // not hex
+		{strings.Repeat("a", 32), 16, false}, // 32 hex chars = 16 bytes
+	}
+
+	for _, tt := range tests {
+		key, err := parseEncryptionKey("DB_ENCRYPTION_KEY", tt.value)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%q: expected error=%v, got %v", tt.value, tt.wantErr, err)
+			continue
+		}
+		if len(key) != tt.wantLen {
+			t.Errorf("%q: expected %d-byte key, got %d", tt.value, tt.wantLen, len(key))
+		}
+	}
+}
+
+func TestEncryptedStore_WrongKeyAndRotation(t *testing.T) {
+	dir := t.TempDir()
+	keyA := []byte(strings.Repeat("a", 32))
+	keyB := []byte(strings.Repeat("b", 32))
+
+	// initStore reads these package-level settings; restore them afterwards
+	prevKey, prevPrevious, prevPath := dbEncryptionKey, dbEncryptionKeyPrevious, storePath
+	defer func() { dbEncryptionKey, dbEncryptionKeyPrevious, storePath = prevKey, prevPrevious, prevPath }()
+
+	// open sets the keys and opens dir, returning the database or error
+	open := func(key, previous []byte) (*badger.DB, error) {
+		dbEncryptionKey, dbEncryptionKeyPrevious = key, previous
+		return initStore(dir)
+	}
+
+	// Create an encrypted database with one value
+	store, err := open(keyA, nil)
+	if err != nil {
+		t.Fatalf("failed to create encrypted store: %v", err)
+	}
+	store.Update(func(txn *badger.Txn) error {
+		return txn.Set([]byte("secret"), []byte("demo data"))
+	})
+	store.Close()
+
+	// Wrong key and no key both fail with a message naming the setting
+	if _, err := open(keyB, nil); err == nil || !errors.Is(err, badger.ErrEncryptionKeyMismatch) ||
+		!strings.Contains(err.Error(), "DB_ENCRYPTION_KEY does not match") {
+		t.Errorf("expected key mismatch error, got %v", err)
+	}
+	if _, err := open(nil, nil); err == nil || !strings.Contains(err.Error(), "database is encrypted") {
+		t.Errorf("expected 'database is encrypted' error, got %v", err)
+	}
+
+	// Rotate A -> B; the data is still readable with B
+	store, err = open(keyB, keyA)
+	if err != nil {
+		t.Fatalf("failed to rotate key: %v", err)
+	}
+	err = store.View(func(txn *badger.Txn) error {
+		entry, err := txn.Get([]byte("secret"))
+		if err != nil {
+			return err
+		}
+		return entry.Value(func(val []byte) error {
+			if string(val) != "demo data" {
+				t.Errorf("expected 'demo data', got %q", val)
+			}
+			return nil
+		})
+	})
+	if err != nil {
+		t.Fatalf("failed to read after rotation: %v", err)
+	}
+	store.Close()
+
+	// Restarting with the previous key still set is fine (nothing to rotate)
+	store, err = open(keyB, keyA)
+	if err != nil {
+		t.Fatalf("expected reopen with DB_ENCRYPTION_KEY_PREVIOUS still set to work: %v", err)
+	}
+	store.Close()
+
+	// The old key no longer opens it
+	if _, err := open(keyA, nil); !errors.Is(err, badger.ErrEncryptionKeyMismatch) {
+		t.Errorf("expected old key to be rejected after rotation, got %v", err)
+	}
+}
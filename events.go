@@ -0,0 +1,154 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"strconv"
+	"time"
+)
+
+// =============================================================================
+// Event Publishing
+// =============================================================================
+//
+// With EVENT_SINK set, the app is also an event producer: every change made
+// through the API is published to a streaming platform, for demos where a
+// consumer (a Flink job, a Lambda, a dashboard) reacts to what happens here.
+//
+//	EVENT_SINK=nats NATS_URL=nats://localhost:4222 ./demo-app
+//	nats sub 'demoapp.>'
+//	[#1] Received on "demoapp.item.created"
+//	{"id":"9f3c...","type":"item.created","time":"...","instance":"demo-app-7d9f","data":{"id":1,"name":"widget",...}}
+//
+// Events:
+//
+//	item.created, item.updated   data is the item
+//	item.deleted                 data is {"id": N}
+//	display.updated              data is the new panel JSON (POST /api/display,
+//	                             DISPLAY_SOURCE_URL, or a refresh_display schedule)
+//
+// Each platform is an eventSink. nats.go talks the NATS text protocol
+// directly; kafka.go goes through a Kafka REST proxy, which keeps the app
+// free of a Kafka client library. Adding another platform means one new
+// type with name and publish.
+//
+// Publishing never slows a request down: events go into a queue that one
+// goroutine drains. If the sink can't keep up the queue fills and new
+// events are dropped (and counted) rather than blocking the API.
+
+// Event types
+const (
+	eventItemCreated    = "item.created"
+	eventItemUpdated    = "item.updated"
+	eventItemDeleted    = "item.deleted"
+	eventDisplayUpdated = "display.updated"
+)
+
+// Events waiting to be sent before new ones are dropped
+const eventQueueSize = 1000
+
+// Event is one change, as published
+type Event struct {
+	ID       string          `json:"id"`
+	Type     string          `json:"type"`
+	Time     time.Time       `json:"time"`
+	Instance string          `json:"instance"` // hostname of the replica that made the change
+	Key      string          `json:"-"`        // partition key: "item-1", "display"
+	Data     json.RawMessage `json:"data"`
+}
+
+// eventSink sends events to one platform
+type eventSink interface {
+	name() string
+	publish(event Event) error
+}
+
+// eventPublisher queues events for a sink
+type eventPublisher struct {
+	sink     eventSink
+	queue    chan Event
+	instance string
+}
+
+// Active publisher, nil unless EVENT_SINK is set
+var events *eventPublisher
+
+// newEventSink builds the sink named by EVENT_SINK
+func newEventSink(kind string) (eventSink, error) {
+	// Each case checks err itself: returning a nil *natsSink as an
+	// eventSink would make a non-nil interface
+	switch kind {
+	case "nats":
+		sink, err := newNATSSink(envString("NATS_URL", "nats://localhost:4222"), envString("NATS_SUBJECT_PREFIX", "demoapp"))
+		if err != nil {
+			return nil, err
+		}
+		return sink, nil
+	case "kafka":
+		restURL := os.Getenv("KAFKA_REST_URL")
+		if restURL == "" {
+			return nil, fmt.Errorf("KAFKA_REST_URL is required for EVENT_SINK=kafka")
+		}
+		sink, err := newKafkaSink(restURL, envString("KAFKA_TOPIC", "demoapp-events"))
+		if err != nil {
+			return nil, err
+		}
+		return sink, nil
+	default:
+		return nil, fmt.Errorf("unknown EVENT_SINK %q (want nats or kafka)", kind)
+	}
+}
+
+// newEventPublisher starts the goroutine that sends queued events to sink
+func newEventPublisher(sink eventSink) *eventPublisher {
+	hostname, err := os.Hostname()
+	if err != nil {
+		hostname = "unknown"
+	}
+	p := &eventPublisher{sink: sink, queue: make(chan Event, eventQueueSize), instance: hostname}
+	go p.run()
+	return p
+}
+
+// run sends events in order, for as long as the app runs
+func (p *eventPublisher) run() {
+	for event := range p.queue {
+		if err := p.sink.publish(event); err != nil {
+			eventsPublishedTotal.WithLabelValues(p.sink.name(), "failed").Inc()
+			slog.Warn("failed to publish event", "sink", p.sink.name(), "type", event.Type, "error", err)
+			continue
+		}
+		eventsPublishedTotal.WithLabelValues(p.sink.name(), "published").Inc()
+	}
+}
+
+// emit queues an event without waiting; a full queue drops it
+func (p *eventPublisher) emit(eventType, key string, data any) {
+	body, err := json.Marshal(data)
+	if err != nil {
+		slog.Warn("failed to encode event", "type", eventType, "error", err)
+		return
+	}
+	event := Event{ID: newRequestID(), Type: eventType, Time: time.Now().UTC(), Instance: p.instance, Key: key, Data: body}
+	select {
+	case p.queue <- event:
+	default:
+		eventsPublishedTotal.WithLabelValues(p.sink.name(), "dropped").Inc()
+	}
+}
+
+// publishItemEvent publishes an item change (no-op without EVENT_SINK)
+func publishItemEvent(eventType string, id int64, data any) {
+	if events != nil {
+		events.emit(eventType, "item-"+strconv.FormatInt(id, 10), data)
+	}
+}
+
+// publishDisplayEvent publishes a new display panel (no-op without EVENT_SINK)
+func publishDisplayEvent(data json.RawMessage) {
+	if events != nil {
+		events.emit(eventDisplayUpdated, "display", data)
+	}
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// fakeSink collects published events on a channel
+type fakeSink struct {
+	events chan Event
+	err    error
+}
+
+func (f *fakeSink) name() string { return "fake" }
+
+func (f *fakeSink) publish(event Event) error {
+	f.events <- event
+	return f.err
+}
+
+// withEventSink publishes to a fakeSink for one test
+func withEventSink(t *testing.T) *fakeSink {
+	t.Helper()
+	sink := &fakeSink{events: make(chan Event, 100)}
+	prev := events
+	events = newEventPublisher(sink)
+	t.Cleanup(func() { events = prev })
+	return sink
+}
+
+// nextEvent waits for the next published event
+func nextEvent(t *testing.T, sink *fakeSink) Event {
+	t.Helper()
+	select {
+	case event := <-sink.events:
+		return event
+	case <-time.After(2 * time.Second):
+		t.Fatal("no event published")
+		return Event{}
+	}
+}
+
+func TestEvents_ItemAndDisplayChanges(t *testing.T) {
+	srv := newTestServer(t)
+	sink := withEventSink(t)
+
+	item := createTestItem(t, srv, `{"name":"widget"}`)
+	id := strconv.FormatInt(item.ID, 10)
+	doRequest(t, srv, http.MethodPut, "/api/items/"+id, `{"name":"gadget"}`)
+	doRequest(t, srv, http.MethodDelete, "/api/items/"+id, "")
+	doRequest(t, srv, http.MethodPost, "/api/display", `{"build":42}`)
+
+	tests := []struct {
+		wantType string
+		wantKey  string
+		wantData string
+	}{
+		{eventItemCreated, "item-" + id, "widget"},
+		{eventItemUpdated, "item-" + id, "gadget"},
+		{eventItemDeleted, "item-" + id, `{"id":` + id + `}`},
+		{eventDisplayUpdated, "display", `{"build":42}`},
+	}
+	for _, tt := range tests {
+		event := nextEvent(t, sink)
+		if event.Type != tt.wantType || event.Key != tt.wantKey || event.ID == "" || event.Instance == "" {
+			t.Errorf("event = %+v, want type %s key %s", event, tt.wantType, tt.wantKey)
+		}
+		if tt.wantType == eventItemCreated || tt.wantType == eventItemUpdated {
+			var got Item
+			json.Unmarshal(event.Data, &got)
+			if got.Name != tt.wantData {
+				t.Errorf("%s data = %s", tt.wantType, event.Data)
+			}
+		} else if string(event.Data) != tt.wantData {
+			t.Errorf("%s data = %s, want %s", tt.wantType, event.Data, tt.wantData)
+		}
+	}
+
+	// Failed writes publish nothing
+	doRequest(t, srv, http.MethodDelete, "/api/items/"+id, "")
+	doRequest(t, srv, http.MethodPost, "/api/items", `{}`)
+	select {
+	case event := <-sink.events:
+		t.Errorf("unexpected event %+v", event)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestEventPublisher_DropsWhenFull(t *testing.T) {
+	// A sink that never returns keeps the one event it's handed; the queue
+	// then fills and the rest are dropped without blocking emit
+	blocked := &fakeSink{events: make(chan Event)}
+	p := &eventPublisher{sink: blocked, queue: make(chan Event, 2), instance: "test"}
+	go p.run()
+
+	done := make(chan struct{})
+	go func() {
+		for i := range 10 {
+			p.emit(eventItemCreated, "item-"+strconv.Itoa(i), map[string]int{"id": i})
+		}
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("emit blocked on a full queue")
+	}
+}
+
+func TestEventPublisher_FailureDoesNotStop(t *testing.T) {
+	sink := &fakeSink{events: make(chan Event, 10), err: errors.New("down")}
+	p := newEventPublisher(sink)
+
+	p.emit(eventDisplayUpdated, "display", json.RawMessage(`1`))
+	p.emit(eventDisplayUpdated, "display", json.RawMessage(`2`))
+	if first, second := nextEvent(t, sink), nextEvent(t, sink); string(first.Data) != "1" || string(second.Data) != "2" {
+		t.Errorf("events = %s, %s", first.Data, second.Data)
+	}
+}
+
+func TestNewEventSink(t *testing.T) {
+	if _, err := newEventSink("rabbitmq"); err == nil {
+		t.Error("expected an error for an unknown sink")
+	}
+	t.Setenv("KAFKA_REST_URL", "")
+	if _, err := newEventSink("kafka"); err == nil {
+		t.Error("expected an error without KAFKA_REST_URL")
+	}
+	t.Setenv("NATS_URL", "nats://localhost:4222")
+	if sink, err := newEventSink("nats"); err != nil || sink.name() != "nats" {
+		t.Errorf("nats sink = %v, %v", sink, err)
+	}
+}
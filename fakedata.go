@@ -0,0 +1,178 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// =============================================================================
+// Fake Demo Data
+// =============================================================================
+//
+// A tiny "faker": realistic-looking product names built from word lists.
+// No external dependency — a demo app only needs plausible data, not a
+// full Faker library with addresses and phone numbers in 40 locales.
+
+var (
+	fakeAdjectives = []string{
+		"Ergonomic", "Rustic", "Sleek", "Refined", "Handcrafted", "Intelligent",
+		"Practical", "Gorgeous", "Incredible", "Licensed", "Modern", "Recycled",
+		"Small", "Tasty", "Unbranded", "Awesome", "Generic", "Fantastic",
+	}
+	fakeMaterials = []string{
+		"Steel", "Wooden", "Concrete", "Plastic", "Cotton", "Granite", "Rubber",
+		"Metal", "Soft", "Fresh", "Frozen", "Bronze", "Titanium", "Carbon",
+	}
+	fakeProducts = []string{
+		"Chair", "Car", "Computer", "Keyboard", "Mouse", "Bike", "Ball", "Gloves",
+		"Pants", "Shirt", "Table", "Shoes", "Hat", "Towels", "Soap", "Tuna",
+		"Chicken", "Fish", "Cheese", "Bacon", "Pizza", "Salad", "Sausages", "Chips",
+	}
+	fakeFeatures = []string{
+		"built for demanding workloads",
+		"designed for everyday use",
+		"with a five-year warranty",
+		"now 20% lighter",
+		"certified for edge deployments",
+		"ships in recyclable packaging",
+		"approved by the platform team",
+		"tested across three regions",
+	}
+	// Category for each product, so generated data fills the category tree
+	fakeCategories = map[string]string{
+		"Chair": "home/furniture", "Table": "home/furniture",
+		"Towels": "home/bath", "Soap": "home/bath",
+		"Computer": "electronics/computers", "Keyboard": "electronics/accessories", "Mouse": "electronics/accessories",
+		"Car": "outdoors/vehicles", "Bike": "outdoors/vehicles",
+		"Ball": "sports", "Gloves": "sports",
+		"Pants": "clothing", "Shirt": "clothing", "Shoes": "clothing/footwear", "Hat": "clothing",
+		"Tuna": "grocery/seafood", "Fish": "grocery/seafood", "Chicken": "grocery/meat", "Bacon": "grocery/meat",
+		"Sausages": "grocery/meat", "Cheese": "grocery/dairy", "Pizza": "grocery/frozen",
+		"Salad": "grocery/produce", "Chips": "grocery/snacks",
+	}
+	// Warehouse regions, so ?meta.region=... has something to filter on
+	fakeRegions = []string{"us-east-1", "us-west-2", "eu-west-1", "ap-southeast-2"}
+	fakeTags    = []string{
+		"new", "sale", "featured", "clearance", "limited", "bestseller",
+		"eco", "premium", "backorder", "bundle",
+	}
+)
+
+// pick returns a random element of a slice
+func pick(list []string) string {
+	return list[rand.IntN(len(list))]
+}
+
+// fakeItemInput generates one realistic-looking item
+func fakeItemInput() itemInput {
+	material := pick(fakeMaterials)
+	product := pick(fakeProducts)
+
+	// 0-2 distinct tags
+	var tags []string
+	for _, i := range rand.Perm(len(fakeTags))[:rand.IntN(3)] {
+		tags = append(tags, fakeTags[i])
+	}
+
+	return itemInput{
+		Name:        fmt.Sprintf("%s %s %s", pick(fakeAdjectives), material, product),
+		Description: fmt.Sprintf("A %s %s %s.", strings.ToLower(material), strings.ToLower(product), pick(fakeFeatures)),
+		Tags:        tags,
+		Category:    fakeCategories[product],
+		Metadata: map[string]any{
+			"region": pick(fakeRegions),
+			"stock":  rand.IntN(500),
+		},
+	}
+}
+
+// =============================================================================
+// Generator Endpoint
+// =============================================================================
+
+// Upper bound for one generate request — enough for pagination/search demos
+// without letting a typo fill the disk
+const maxGenerateCount = 10000
+
+// generateAdminHandler creates a batch of fake items (POST only)
+//
+//	POST /api/admin/generate?count=500&spread=720h
+//
+// count:  how many items (default 100, max 10000)
+// spread: items get random created_at times within this window before now
+//
+//	(default 720h = 30 days), so time-based views have something to show
+func generateAdminHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, `{"error":"method not allowed"}`, http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+
+	count := 100
+	if raw := r.URL.Query().Get("count"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n < 1 || n > maxGenerateCount {
+			writeJSONError(w, http.StatusBadRequest, fmt.Sprintf("count must be between 1 and %d", maxGenerateCount))
+			return
+		}
+		count = n
+	}
+
+	spread := 30 * 24 * time.Hour
+	if raw := r.URL.Query().Get("spread"); raw != "" {
+		d, err := time.ParseDuration(raw)
+		if err != nil || d < 0 {
+			http.Error(w, `{"error":"invalid spread duration"}`, http.StatusBadRequest)
+			return
+		}
+		spread = d
+	}
+
+	start := time.Now()
+	created := 0
+	var firstID, lastID int64
+	for i := 0; i < count; i++ {
+		createdAt := time.Now().UTC()
+		if spread > 0 {
+			// rand.Int64N returns [0, n) — subtract to go back in time
+			createdAt = createdAt.Add(-time.Duration(rand.Int64N(int64(spread))))
+		}
+
+		item, _, err := insertItemAt(fakeItemInput(), "", createdAt)
+		var conflict *nameConflictError
+		if errors.As(err, &conflict) {
+			continue // unique-names mode: skip the occasional duplicate name
+		}
+		if err != nil {
+			logHandlerError(r.Context(), "admin_generate", "database", "failed to insert generated item", "error", err)
+			http.Error(w, `{"error":"database error"}`, http.StatusInternalServerError)
+			return
+		}
+
+		if created == 0 {
+			firstID = item.ID
+		}
+		lastID = item.ID
+		created++
+	}
+
+	slog.InfoContext(r.Context(), "generated demo items", "requested", count, "created", created)
+
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]any{
+		"requested":   count,
+		"created":     created,
+		"first_id":    firstID,
+		"last_id":     lastID,
+		"duration_ms": time.Since(start).Milliseconds(),
+	})
+}
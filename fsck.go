@@ -0,0 +1,387 @@
+package main
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+
+	badger "github.com/dgraph-io/badger/v4"
+)
+
+// =============================================================================
+// Integrity Check (fsck)
+// =============================================================================
+//
+// Like fsck for a filesystem: read every item and index key and report what
+// doesn't add up.
+//
+//	./demo-app fsck --db /data            # offline, server stopped (cli.go)
+//	./demo-app fsck --db /data --fix
+//	curl -X POST http://localhost:8080/api/admin/fsck
+//	curl -X POST "http://localhost:8080/api/admin/fsck?fix=true"
+//
+// What counts as a problem:
+//
+//	malformed_item    an item: value that isn't valid JSON, has no name, or
+//	                  whose ID doesn't match its key
+//	orphaned_index    a category, name, attachment, or link key pointing at
+//	                  an item that doesn't exist (or no longer matches)
+//	sequence_behind   the ID sequence would hand out an ID that's already
+//	                  used, so the next create would overwrite an item
+//
+// With fix, malformed items are quarantined: moved to "quarantine:<key>",
+// out of every listing but kept for a closer look (a badger backup includes
+// them). Orphaned index keys are deleted and the sequence is moved past the
+// highest ID.
+//
+// Gaps in the IDs are reported but aren't problems: deleted items and
+// unused sequence leases after a crash both leave them.
+//
+// The name index is only checked with UNIQUE_ITEM_NAMES on; otherwise stale
+// entries are expected and the next start with the mode on rebuilds it.
+
+// Quarantined records live under this prefix (see above)
+const quarantineKeyPrefix = "quarantine:"
+
+// FsckProblem is one inconsistency fsck found
+type FsckProblem struct {
+	Kind   string `json:"kind"`
+	Key    string `json:"key,omitempty"`
+	Detail string `json:"detail"`
+	Fix    string `json:"fix,omitempty"` // "quarantined", "deleted", or "advanced" once fixed
+}
+
+// FsckSequence describes the item ID sequence
+type FsckSequence struct {
+	Next       uint64   `json:"next"`   // stored value: the end of the open lease of up to 100 IDs
+	MaxID      int64    `json:"max_id"` // highest item ID in use, -1 if there are no items
+	MissingIDs int64    `json:"missing_ids"`
+	Gaps       []string `json:"gaps,omitempty"` // first ranges of unused IDs, e.g. "3-5"
+}
+
+// FsckReport is what fsck returns
+type FsckReport struct {
+	Clean    bool          `json:"clean"` // no problems left (none found, or all fixed)
+	Items    int           `json:"items"` // valid items
+	Problems []FsckProblem `json:"problems"`
+	Fixed    int           `json:"fixed"`
+	Sequence FsckSequence  `json:"sequence"`
+}
+
+// At most this many gap ranges are listed
+const fsckMaxGaps = 20
+
+// fsckFinding is a problem plus what --fix does about it
+type fsckFinding struct {
+	problem    FsckProblem
+	key        []byte
+	quarantine []byte // the value to keep under quarantineKeyPrefix; nil = delete
+}
+
+// runFsck checks the database and, with fix, repairs what it found
+func runFsck(fix bool) (FsckReport, error) {
+	var findings []fsckFinding
+	items := map[int64]Item{}
+	var ids []int64 // every ID in an item key, valid or not
+	var seqNext uint64
+
+	err := dbView("fsck", itemKeyPrefix, func(txn *badger.Txn) error {
+		// Items first: the index checks need to know which items are valid
+		err := fsckScan(txn, itemKeyPrefix, func(key, val []byte) {
+			id, err := strconv.ParseInt(strings.TrimPrefix(string(key), itemKeyPrefix), 10, 64)
+			if err == nil {
+				ids = append(ids, id)
+			}
+			var item Item
+			detail := ""
+			if err != nil {
+				detail = "key has no item ID"
+			} else if err := decodeItem(val, &item); err != nil {
+				detail = "invalid JSON: " + err.Error()
+			} else if item.ID != id {
+				detail = fmt.Sprintf("value has id %d", item.ID)
+			} else if item.Name == "" {
+				detail = "name is empty"
+			}
+			if detail != "" {
+				findings = append(findings, fsckFinding{
+					problem:    FsckProblem{Kind: "malformed_item", Key: string(key), Detail: detail},
+					key:        key,
+					quarantine: val,
+				})
+				return
+			}
+			items[id] = item
+		})
+		if err != nil {
+			return err
+		}
+
+		// orphan records an index key that no longer points at a valid item
+		orphan := func(key []byte, detail string) {
+			findings = append(findings, fsckFinding{
+				problem: FsckProblem{Kind: "orphaned_index", Key: string(key), Detail: detail},
+				key:     key,
+			})
+		}
+		missing := func(id int64) string { return fmt.Sprintf("item %d doesn't exist", id) }
+
+		err = fsckScan(txn, categoryIndexPrefix, func(key, _ []byte) {
+			category, id, ok := parseCategoryIndexKey(key)
+			item, exists := items[id]
+			switch {
+			case !ok:
+				orphan(key, "unreadable category index key")
+			case !exists:
+				orphan(key, missing(id))
+			case item.Category != category:
+				orphan(key, fmt.Sprintf("item %d is in category %q", id, item.Category))
+			}
+		})
+		if err != nil {
+			return err
+		}
+
+		if uniqueItemNames {
+			err = fsckScan(txn, nameIndexPrefix, func(key, val []byte) {
+				id, err := strconv.ParseInt(string(val), 10, 64)
+				item, exists := items[id]
+				switch {
+				case err != nil:
+					orphan(key, "unreadable owner ID")
+				case !exists:
+					orphan(key, missing(id))
+				case !strings.EqualFold(item.Name, strings.TrimPrefix(string(key), nameIndexPrefix)):
+					orphan(key, fmt.Sprintf("item %d is named %q", id, item.Name))
+				}
+			})
+			if err != nil {
+				return err
+			}
+		}
+
+		err = fsckScan(txn, attachmentKeyPrefix, func(key, _ []byte) {
+			id, err := strconv.ParseInt(strings.TrimPrefix(string(key), attachmentKeyPrefix), 10, 64)
+			if _, exists := items[id]; err != nil || !exists {
+				orphan(key, missing(id))
+			}
+		})
+		if err != nil {
+			return err
+		}
+
+		// link:out:<source>:<type>:<target> and link:in:<target>:<type>:<source>
+		for _, prefix := range []string{linkOutKeyPrefix, linkInKeyPrefix} {
+			err = fsckScan(txn, prefix, func(key, _ []byte) {
+				parts := strings.Split(strings.TrimPrefix(string(key), prefix), ":")
+				if len(parts) != 3 {
+					orphan(key, "unreadable link key")
+					return
+				}
+				for _, part := range []string{parts[0], parts[2]} {
+					id, err := strconv.ParseInt(part, 10, 64)
+					if _, exists := items[id]; err != nil || !exists {
+						orphan(key, missing(id))
+						return
+					}
+				}
+			})
+			if err != nil {
+				return err
+			}
+		}
+
+		// Badger stores a sequence as its next value, 8 bytes big-endian
+		seqItem, err := txn.Get([]byte("seq:items"))
+		if errors.Is(err, badger.ErrKeyNotFound) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		return seqItem.Value(func(val []byte) error {
+			if len(val) == 8 {
+				seqNext = binary.BigEndian.Uint64(val)
+			}
+			return nil
+		})
+	})
+	if err != nil {
+		return FsckReport{}, err
+	}
+
+	report := FsckReport{Items: len(items), Problems: []FsckProblem{}}
+	report.Sequence = fsckSequence(ids, seqNext)
+	if report.Sequence.MaxID >= 0 && seqNext <= uint64(report.Sequence.MaxID) {
+		findings = append(findings, fsckFinding{problem: FsckProblem{
+			Kind:   "sequence_behind",
+			Key:    "seq:items",
+			Detail: fmt.Sprintf("next ID %d is not above the highest ID %d", seqNext, report.Sequence.MaxID),
+		}})
+	}
+
+	if fix && len(findings) > 0 {
+		if err := applyFsckFixes(findings, report.Sequence.MaxID); err != nil {
+			return FsckReport{}, err
+		}
+		report.Fixed = len(findings)
+		if err := syncItemsGauge(); err != nil {
+			return FsckReport{}, err
+		}
+	}
+	for _, f := range findings {
+		report.Problems = append(report.Problems, f.problem)
+	}
+	report.Clean = len(findings) == 0 || fix
+	return report, nil
+}
+
+// fsckScan calls fn for every key with prefix. The slices are copies, so fn
+// may keep them.
+func fsckScan(txn *badger.Txn, prefix string, fn func(key, val []byte)) error {
+	it := txn.NewIterator(badger.IteratorOptions{Prefix: []byte(prefix), PrefetchValues: true, PrefetchSize: 100})
+	defer it.Close()
+	for it.Rewind(); it.Valid(); it.Next() {
+		val, err := it.Item().ValueCopy(nil)
+		if err != nil {
+			return err
+		}
+		fn(it.Item().KeyCopy(nil), val)
+	}
+	return nil
+}
+
+// fsckSequence summarizes the IDs in use and the gaps between them
+func fsckSequence(ids []int64, next uint64) FsckSequence {
+	seq := FsckSequence{Next: next, MaxID: -1}
+	slices.Sort(ids)
+	expected := int64(0)
+	for _, id := range ids {
+		if id < expected {
+			continue // negative, or a duplicate from a legacy unpadded key
+		}
+		if id > expected {
+			seq.MissingIDs += id - expected
+			if len(seq.Gaps) < fsckMaxGaps {
+				gap := strconv.FormatInt(expected, 10)
+				if id-1 > expected {
+					gap += "-" + strconv.FormatInt(id-1, 10)
+				}
+				seq.Gaps = append(seq.Gaps, gap)
+			}
+		}
+		expected = id + 1
+		seq.MaxID = id
+	}
+	return seq
+}
+
+// applyFsckFixes quarantines, deletes, and advances what runFsck found, and
+// marks each problem as fixed
+func applyFsckFixes(findings []fsckFinding, maxID int64) error {
+	err := dbUpdate("fsck_fix", itemKeyPrefix, func(txn *badger.Txn) error {
+		if err := touchItemsVersion(txn); err != nil {
+			return err
+		}
+		for i := range findings {
+			f := &findings[i]
+			if f.key == nil {
+				continue // the sequence, below
+			}
+			if f.quarantine != nil {
+				if err := txn.Set(append([]byte(quarantineKeyPrefix), f.key...), f.quarantine); err != nil {
+					return err
+				}
+				f.problem.Fix = "quarantined"
+			} else {
+				f.problem.Fix = "deleted"
+			}
+			if err := txn.Delete(f.key); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	for i := range findings {
+		if findings[i].problem.Kind != "sequence_behind" {
+			continue
+		}
+		// The sequence holds a lease: hand it back, move the stored value
+		// past maxID, and lease again from there, with item creates paused
+		// (store.go). Creates since the check may already have moved it
+		// further, so it only ever goes forward (cluster.go).
+		err := replaceItemSequence(func() error {
+			return advanceStoredSequence(uint64(maxID) + 1)
+		})
+		if err != nil {
+			return err
+		}
+		findings[i].problem.Fix = "advanced"
+	}
+	return nil
+}
+
+// fsckAdminHandler handles POST /api/admin/fsck[?fix=true]. It checks this
+// replica's database; in cluster mode each replica has its own.
+func fsckAdminHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, `{"error":"method not allowed"}`, http.StatusMethodNotAllowed)
+		return
+	}
+	fix := r.URL.Query().Get("fix") == "true"
+
+	report, err := runFsck(fix)
+	if err != nil {
+		logHandlerError(r.Context(), "admin_fsck", "database", "integrity check failed", "error", err)
+		http.Error(w, `{"error":"database error"}`, http.StatusInternalServerError)
+		return
+	}
+	if len(report.Problems) > 0 {
+		slog.WarnContext(r.Context(), "integrity check found problems", "problems", len(report.Problems), "fixed", report.Fixed)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(report)
+}
+
+// runFsckCommand is "demo-app fsck": exit 0 when clean, 1 when problems
+// remain (run again with --fix)
+func runFsckCommand(args []string, out io.Writer) int {
+	flags := flag.NewFlagSet("fsck", flag.ContinueOnError)
+	dbPath := dbFlag(flags)
+	fix := flags.Bool("fix", false, "quarantine malformed items, delete orphaned index keys, advance the sequence")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	closeDB, err := openDataDir(*dbPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "fsck: %v\n", err)
+		return 1
+	}
+	defer closeDB()
+
+	report, err := runFsck(*fix)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "fsck: %v\n", err)
+		return 1
+	}
+	json.NewEncoder(out).Encode(report)
+	if !report.Clean {
+		return 1
+	}
+	return 0
+}
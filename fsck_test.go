@@ -0,0 +1,134 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	badger "github.com/dgraph-io/badger/v4"
+)
+
+// corruptStore writes one of each problem fsck looks for
+func corruptStore(t *testing.T) {
+	t.Helper()
+	err := db.Update(func(txn *badger.Txn) error {
+		zero := make([]byte, 8) // sequence back at 0: the next create would overwrite item 0
+		for key, val := range map[string][]byte{
+			string(itemKey(50)):                  []byte(`{"name":`),
+			string(categoryIndexKey("gone", 7)):  nil,
+			string(linkOutKey(0, "related", 99)): nil,
+			string(attachmentKey(42)):            []byte("data"),
+			"seq:items":                          zero,
+		} {
+			if err := txn.Set([]byte(key), val); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestFsck_FindsAndFixesProblems(t *testing.T) {
+	srv := newTestServer(t)
+	createTestItem(t, srv, `{"name":"ok","category":"tools"}`)
+	createTestItem(t, srv, `{"name":"also ok"}`)
+
+	code, data := doRequest(t, srv, "POST", "/api/admin/fsck", "")
+	var report FsckReport
+	json.Unmarshal(data, &report)
+	if code != http.StatusOK || !report.Clean || report.Items != 2 {
+		t.Fatalf("healthy store: %d %s", code, data)
+	}
+
+	corruptStore(t)
+	_, data = doRequest(t, srv, "POST", "/api/admin/fsck", "")
+	report = FsckReport{}
+	json.Unmarshal(data, &report)
+	kinds := map[string]int{}
+	for _, p := range report.Problems {
+		kinds[p.Kind]++
+	}
+	if report.Clean || kinds["malformed_item"] != 1 || kinds["orphaned_index"] != 3 || kinds["sequence_behind"] != 1 {
+		t.Fatalf("report = %s", data)
+	}
+	// IDs 2-49 were never used
+	if report.Sequence.MaxID != 50 || report.Sequence.MissingIDs != 48 || report.Sequence.Gaps[0] != "2-49" {
+		t.Errorf("sequence = %+v", report.Sequence)
+	}
+
+	_, data = doRequest(t, srv, "POST", "/api/admin/fsck?fix=true", "")
+	report = FsckReport{}
+	json.Unmarshal(data, &report)
+	if !report.Clean || report.Fixed != 5 {
+		t.Fatalf("fix report = %s", data)
+	}
+
+	// The malformed record is quarantined, not lost
+	err := db.View(func(txn *badger.Txn) error {
+		_, err := txn.Get(append([]byte(quarantineKeyPrefix), itemKey(50)...))
+		return err
+	})
+	if err != nil {
+		t.Errorf("quarantined record: %v", err)
+	}
+	_, data = doRequest(t, srv, "POST", "/api/admin/fsck", "")
+	report = FsckReport{}
+	json.Unmarshal(data, &report)
+	if !report.Clean || len(report.Problems) != 0 {
+		t.Errorf("after fix: %s", data)
+	}
+
+	// The sequence moved past the quarantined ID
+	if item := createTestItem(t, srv, `{"name":"new"}`); item.ID <= 50 {
+		t.Errorf("new item got id %d, want > 50", item.ID)
+	}
+}
+
+func TestFsckCommand(t *testing.T) {
+	withOfflineDB(t)
+	dir := t.TempDir()
+	runJSON(t, runSeedCommand, "--db", dir, "--count", "2")
+
+	closeDB, err := openDataDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	db.Update(func(txn *badger.Txn) error { return txn.Set(itemKey(1), []byte("not json")) })
+	closeDB()
+
+	var out bytes.Buffer
+	if code := runFsckCommand([]string{"--db", dir}, &out); code != 1 {
+		t.Errorf("with a malformed item: exit code = %d, want 1", code)
+	}
+	// Fake items have categories, so the index entry goes too
+	if report := runJSON(t, runFsckCommand, "--db", dir, "--fix"); report["clean"] != true || report["fixed"] != 2.0 {
+		t.Errorf("--fix = %v", report)
+	}
+	if report := runJSON(t, runFsckCommand, "--db", dir); report["items"] != 1.0 {
+		t.Errorf("after --fix = %v", report)
+	}
+}
+
+func TestFsck_FixDuringCreates(t *testing.T) {
+	newTestServer(t)
+	far := int64(0)
+	checkCreatesDuring(t, func() error { // reset_test.go
+		// An item far past the sequence, so every fix run advances it.
+		// (Zeroing the sequence instead would hand out IDs twice before
+		// fsck ever ran.)
+		far += 1_000_000
+		value, _ := json.Marshal(Item{ID: far, Name: "replicated"})
+		err := db.Update(func(txn *badger.Txn) error {
+			return txn.Set(itemKey(far), value)
+		})
+		if err != nil {
+			return err
+		}
+		_, err = runFsck(true)
+		return err
+	})
+}
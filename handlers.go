@@ -1,8 +1,9 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
-	"fmt"
+	"errors"
 	"log/slog"
 	"net"
 	"net/http"
@@ -31,6 +32,37 @@ func healthHandler(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(response)
 }
 
+// readyHandler reports whether the app can serve traffic: /health says the
+// process is up, /ready also checks the database answers a read.
+// Kubernetes readinessProbe (and "demo-app healthcheck") use it.
+func readyHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	err := errors.New("database closed")
+	if chaosExitPending() {
+		// POST /api/chaos/exit is counting down (chaos.go)
+		err = errors.New("shutting down")
+	} else if db != nil && !db.IsClosed() {
+		err = dbView("ready", schemaVersionKey, func(txn *badger.Txn) error {
+			_, err := txn.Get([]byte(schemaVersionKey))
+			if errors.Is(err, badger.ErrKeyNotFound) {
+				return nil
+			}
+			return err
+		})
+	}
+	if err != nil {
+		slog.WarnContext(r.Context(), "not ready", "error", err)
+		w.WriteHeader(http.StatusServiceUnavailable)
+		json.NewEncoder(w).Encode(map[string]string{"status": "not ready", "error": err.Error()})
+		return
+	}
+	json.NewEncoder(w).Encode(map[string]string{
+		"status":    "ready",
+		"timestamp": time.Now().UTC().Format(time.RFC3339),
+	})
+}
+
 // =============================================================================
 // Items Endpoints (CRUD)
 // =============================================================================
@@ -45,6 +77,11 @@ func itemsHandler(w http.ResponseWriter, r *http.Request) {
 
 	w.Header().Set("Content-Type", "application/json")
 
+	// An unknown ?format= is refused before anything is written (responseformat.go)
+	if !checkResponseFormat(w, r) {
+		return
+	}
+
 	// Route based on method and whether we have an ID
 	if path == "" {
 		// /api/items (no ID)
@@ -57,13 +94,27 @@ func itemsHandler(w http.ResponseWriter, r *http.Request) {
 			http.Error(w, `{"error":"method not allowed"}`, http.StatusMethodNotAllowed)
 		}
 	} else {
-		// /api/items/:id
-		id, err := strconv.ParseInt(path, 10, 64)
+		// /api/items/:id or /api/items/:id/<sub-resource>
+		idPart, sub, _ := strings.Cut(path, "/")
+		id, err := strconv.ParseInt(idPart, 10, 64)
 		if err != nil {
 			http.Error(w, `{"error":"invalid id"}`, http.StatusBadRequest)
 			return
 		}
 
+		switch sub {
+		case "":
+		case "attachment":
+			attachmentHandler(w, r, id)
+			return
+		case "links":
+			linksHandler(w, r, id)
+			return
+		default:
+			http.Error(w, `{"error":"not found"}`, http.StatusNotFound)
+			return
+		}
+
 		switch r.Method {
 		case http.MethodGet:
 			getItem(w, r, id)
@@ -77,242 +128,330 @@ func itemsHandler(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
-// listItems returns all items from the database
-func listItems(w http.ResponseWriter, r *http.Request) {
-	items := []Item{}
+// itemsCountHandler returns the number of items (GET only)
+// Cheaper than listing everything just to count it: only keys are read.
+func itemsCountHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, `{"error":"method not allowed"}`, http.StatusMethodNotAllowed)
+		return
+	}
 
-	// db.View() starts a read-only transaction
-	// This is safe for concurrent access — multiple readers can run simultaneously
-	err := db.View(func(txn *badger.Txn) error {
-		// Create an iterator with default options
-		opts := badger.DefaultIteratorOptions
-		// PrefetchValues = true means we want the values, not just keys
-		opts.PrefetchValues = true
-
-		it := txn.NewIterator(opts)
-		defer it.Close()
-
-		// Seek to the first key with our prefix, then iterate while prefix matches
-		prefix := []byte(itemKeyPrefix)
-		for it.Seek(prefix); it.ValidForPrefix(prefix); it.Next() {
-			item := it.Item()
-
-			// Get the value (the JSON blob)
-			err := item.Value(func(val []byte) error {
-				var i Item
-				if err := json.Unmarshal(val, &i); err != nil {
-					slog.Error("failed to unmarshal item", "error", err)
-					return nil // Skip malformed items, don't fail the whole list
-				}
-				items = append(items, i)
-				return nil
-			})
-			if err != nil {
-				return err
-			}
-		}
-		return nil
-	})
+	w.Header().Set("Content-Type", "application/json")
 
+	count, err := itemStoreFrom(r.Context()).countItems()
 	if err != nil {
-		slog.Error("failed to list items", "error", err)
+		logHandlerError(r.Context(), "items", "database", "failed to count items", "error", err)
 		http.Error(w, `{"error":"database error"}`, http.StatusInternalServerError)
 		return
 	}
 
-	json.NewEncoder(w).Encode(items)
+	json.NewEncoder(w).Encode(map[string]int{"count": count})
 }
 
-// createItem creates a new item in the database
-func createItem(w http.ResponseWriter, r *http.Request) {
-	var input struct {
-		Name        string `json:"name"`
-		Description string `json:"description"`
-	}
+// itemInput is the request body for creating or updating an item
+type itemInput struct {
+	Name        string         `json:"name"`
+	Description string         `json:"description"`
+	Tags        []string       `json:"tags"`
+	Category    string         `json:"category"`
+	Metadata    map[string]any `json:"metadata"`
+	Version     *int64         `json:"version,omitempty"` // updates only: the version edited (itemversion.go)
+}
 
-	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
-		http.Error(w, `{"error":"invalid json"}`, http.StatusBadRequest)
-		return
+// decodeItemInput parses and validates an item request body.
+// On failure it writes the error response itself and returns ok=false,
+// so callers just need: if !ok { return }
+func decodeItemInput(w http.ResponseWriter, r *http.Request) (itemInput, bool) {
+	var input itemInput
+
+	// 415 and unknown-field errors with STRICT_JSON (strictjson.go)
+	if !decodeJSONBody(w, r, &input) {
+		return input, false
 	}
 
 	if input.Name == "" {
 		http.Error(w, `{"error":"name is required"}`, http.StatusBadRequest)
-		return
+		return input, false
+	}
+
+	// Configurable constraints (validation.go) — 422 with per-field details
+	input, errs := checkItemInput(input)
+	if len(errs) > 0 {
+		writeValidationErrors(w, errs)
+		return input, false
+	}
+
+	return input, true
+}
+
+// checkItemInput applies the validation rules, category, and metadata checks
+// to an item with a name, returning it with the category normalized.
+// "demo-app items put" (cliitems.go) uses it too.
+func checkItemInput(input itemInput) (itemInput, []FieldError) {
+	errs := itemValidation.validate(input.Name, input.Description, input.Tags)
+	category, err := normalizeCategory(input.Category)
+	if err != nil {
+		errs = append(errs, FieldError{"category", err.Error()})
 	}
+	if err := validateMetadata(input.Metadata); err != nil {
+		errs = append(errs, FieldError{"metadata", err.Error()})
+	}
+	input.Category = category
+	return input, errs
+}
 
-	// Get next ID from the sequence
-	// This is atomic and safe for concurrent access
-	id, err := itemSeq.Next()
+// listItems returns all items from the database, optionally filtered by
+// metadata (?meta.region=us-east-1, see metadata.go)
+func listItems(w http.ResponseWriter, r *http.Request) {
+	filter, err := parseMetadataFilter(r.URL.Query())
 	if err != nil {
-		slog.Error("failed to get next item ID", "error", err)
-		http.Error(w, `{"error":"database error"}`, http.StatusInternalServerError)
+		writeJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	// ?wait=30s&since_version=N holds the request until the items change
+	// (longpoll.go)
+	store := itemStoreFrom(r.Context()) // the request's tenant (tenant.go)
+	if !longPoll(w, r, itemsVersionHeader, store.itemsVersion) {
+		return
+	}
+
+	// ETag and 304 Not Modified for pollers (itemsetag.go)
+	format := "ndjson"
+	if !wantsNDJSON(r) {
+		negotiated, _ := negotiateFormat(r) // checked in itemsHandler
+		format = negotiated.name
+	}
+	if checkItemsNotModified(w, r, format) {
 		return
 	}
 
-	// Create the item
-	item := Item{
-		ID:          int64(id),
-		Name:        input.Name,
-		Description: input.Description,
-		CreatedAt:   time.Now().UTC(),
+	if wantsNDJSON(r) {
+		streamItems(w, r, filter)
+		return
 	}
 
-	// Serialize to JSON
-	value, err := json.Marshal(item)
+	// scanItems (itemstore.go) reads in one read-only transaction, which is
+	// safe for concurrent access — multiple readers can run simultaneously
+	items := []Item{}
+	err = store.scanItems(r.Context(), func(i Item) error {
+		if filter.matches(i) {
+			items = append(items, i)
+		}
+		return nil
+	})
 	if err != nil {
-		slog.Error("failed to marshal item", "error", err)
+		logHandlerError(r.Context(), "items", "database", "failed to list items", "error", err)
 		http.Error(w, `{"error":"database error"}`, http.StatusInternalServerError)
 		return
 	}
 
-	// Build the key: "item:1", "item:2", etc.
-	key := []byte(fmt.Sprintf("%s%d", itemKeyPrefix, id))
+	writeResponse(w, r, http.StatusOK, items)
+}
+
+// Items written between flushes when streaming
+const streamFlushEvery = 100
+
+// wantsNDJSON reports whether the client asked for a streamed list,
+// with ?stream=true or an "Accept: application/x-ndjson" header
+func wantsNDJSON(r *http.Request) bool {
+	if r.URL.Query().Get("stream") == "true" {
+		return true
+	}
+	return strings.Contains(r.Header.Get("Accept"), "application/x-ndjson")
+}
 
-	// db.Update() starts a read-write transaction
-	// Multiple Update transactions are serialized, but this is fast for K/V operations
-	err = db.Update(func(txn *badger.Txn) error {
-		return txn.Set(key, value)
+// streamItems writes items as NDJSON (newline-delimited JSON): one item per
+// line, written as it's read from the database.
+//
+// listItems collects every item into a slice before encoding, so memory grows
+// with the dataset. Here only one item is in memory at a time, which keeps a
+// 100k-item demo flat. The client also sees the first items right away:
+//
+//	{"id":1,"name":"Widget",...}
+//	{"id":2,"name":"Gadget",...}
+//
+// Python analogy: a generator instead of building a list.
+func streamItems(w http.ResponseWriter, r *http.Request, filter metadataFilter) {
+	w.Header().Set("Content-Type", "application/x-ndjson")
+
+	// ResponseController finds Flush on the real writer through our
+	// middleware wrappers; flushing pushes buffered lines to the client
+	rc := http.NewResponseController(w)
+	encoder := json.NewEncoder(w) // Encode adds the newline after each item
+	written := 0
+
+	// Client went away (or the deadline passed): scanItems stops reading
+	// instead of streaming into the void
+	err := itemStoreFrom(r.Context()).scanItems(r.Context(), func(i Item) error {
+		if !filter.matches(i) {
+			return nil
+		}
+		if err := encoder.Encode(i); err != nil {
+			return err
+		}
+		written++
+		if written%streamFlushEvery == 0 {
+			rc.Flush()
+		}
+		return nil
 	})
+
+	// The 200 status and earlier lines are already sent, so an error can
+	// only be logged — the client sees a truncated stream
+	if err != nil && r.Context().Err() == nil {
+		logHandlerError(r.Context(), "items", "database", "failed to stream items", "error", err)
+	}
+}
+
+// createItem creates a new item in the database
+func createItem(w http.ResponseWriter, r *http.Request) {
+	input, ok := decodeItemInput(w, r)
+	if !ok {
+		return
+	}
+
+	// Optional Idempotency-Key header (idempotency.go)
+	idemKey := r.Header.Get("Idempotency-Key")
+	if len(idemKey) > maxIdempotencyKeyLength {
+		http.Error(w, `{"error":"idempotency key too long"}`, http.StatusBadRequest)
+		return
+	}
+
+	// QUOTA_MAX_ITEMS and QUOTA_MAX_STORAGE_BYTES (quota.go)
+	value, _ := json.Marshal(input)
+	if !checkStorageQuota(w, r, int64(len(value))) {
+		return
+	}
+
+	// insertItem (store.go) does the actual write, in the request's tenant
+	item, replayed, err := itemStoreFrom(r.Context()).insertItem(r.Context(), input, idemKey, time.Now().UTC(), true)
+	if writeQuotaError(w, r, err) {
+		return
+	}
+	if errors.Is(err, errIdempotencyMismatch) {
+		writeJSONError(w, http.StatusUnprocessableEntity, err.Error())
+		return
+	}
+	if errors.Is(err, errTenantLimit) {
+		writeJSONError(w, http.StatusForbidden, err.Error())
+		return
+	}
+	if writeNameConflict(w, err) {
+		return
+	}
 	if err != nil {
-		slog.Error("failed to insert item", "error", err)
+		logHandlerError(r.Context(), "items", "database", "failed to insert item", "error", err)
 		http.Error(w, `{"error":"database error"}`, http.StatusInternalServerError)
 		return
 	}
 
-	// Update Prometheus metrics (defined in metrics.go)
-	itemsTotal.Inc()
+	// Retry: answer exactly like the first time, plus a header saying so
+	if replayed {
+		w.Header().Set("Idempotent-Replayed", "true")
+	} else {
+		publishItemEvent(eventItemCreated, item.ID, item) // events.go
+	}
 
-	w.WriteHeader(http.StatusCreated)
-	json.NewEncoder(w).Encode(item)
+	writeResponse(w, r, http.StatusCreated, item)
 }
 
 // getItem returns a single item by ID
 func getItem(w http.ResponseWriter, r *http.Request, id int64) {
-	key := []byte(fmt.Sprintf("%s%d", itemKeyPrefix, id))
-	var item Item
-
-	err := db.View(func(txn *badger.Txn) error {
-		dbItem, err := txn.Get(key)
-		if err != nil {
-			return err // Will be badger.ErrKeyNotFound if not exists
-		}
-
-		return dbItem.Value(func(val []byte) error {
-			return json.Unmarshal(val, &item)
-		})
-	})
-
+	item, err := itemStoreFrom(r.Context()).fetchItem(r.Context(), id)
 	if err == badger.ErrKeyNotFound {
 		http.Error(w, `{"error":"not found"}`, http.StatusNotFound)
 		return
 	}
 	if err != nil {
-		slog.Error("failed to fetch item", "error", err)
+		logHandlerError(r.Context(), "items", "database", "failed to fetch item", "error", err)
 		http.Error(w, `{"error":"database error"}`, http.StatusInternalServerError)
 		return
 	}
 
-	json.NewEncoder(w).Encode(item)
+	// Send it back in If-Match to update this version only (itemversion.go)
+	w.Header().Set("ETag", itemETag(item))
+	writeResponse(w, r, http.StatusOK, item)
 }
 
 // updateItem updates an existing item
 func updateItem(w http.ResponseWriter, r *http.Request, id int64) {
-	var input struct {
-		Name        string `json:"name"`
-		Description string `json:"description"`
+	input, ok := decodeItemInput(w, r)
+	if !ok {
+		return
 	}
-
-	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
-		http.Error(w, `{"error":"invalid json"}`, http.StatusBadRequest)
+	// If-Match or "version": the version this edit was made against
+	expected, ok := expectedItemVersion(w, r, input) // itemversion.go
+	if !ok {
 		return
 	}
 
-	if input.Name == "" {
-		http.Error(w, `{"error":"name is required"}`, http.StatusBadRequest)
+	// Only refused once the tenant is already over QUOTA_MAX_STORAGE_BYTES,
+	// so items can still be trimmed (quota.go)
+	if !checkStorageQuota(w, r, 0) {
 		return
 	}
 
-	key := []byte(fmt.Sprintf("%s%d", itemKeyPrefix, id))
-	var item Item
-
 	// Update is a read-modify-write operation, all in one transaction
-	err := db.Update(func(txn *badger.Txn) error {
-		// First, read the existing item
-		dbItem, err := txn.Get(key)
-		if err != nil {
-			return err // badger.ErrKeyNotFound if doesn't exist
-		}
-
-		// Get current value and unmarshal
-		err = dbItem.Value(func(val []byte) error {
-			return json.Unmarshal(val, &item)
-		})
-		if err != nil {
-			return err
-		}
-
-		// Update fields (preserve CreatedAt and ID)
-		item.Name = input.Name
-		item.Description = input.Description
-
-		// Marshal and save
-		value, err := json.Marshal(item)
-		if err != nil {
-			return err
-		}
-
-		return txn.Set(key, value)
-	})
-
+	// (updateItem in itemstore.go)
+	item, err := itemStoreFrom(r.Context()).updateItem(r.Context(), id, input, expected)
 	if err == badger.ErrKeyNotFound {
 		http.Error(w, `{"error":"not found"}`, http.StatusNotFound)
 		return
 	}
+	if writeVersionConflict(w, r, err) || writeNameConflict(w, err) {
+		return
+	}
 	if err != nil {
-		slog.Error("failed to update item", "error", err)
+		logHandlerError(r.Context(), "items", "database", "failed to update item", "error", err)
 		http.Error(w, `{"error":"database error"}`, http.StatusInternalServerError)
 		return
 	}
+	publishItemEvent(eventItemUpdated, item.ID, item)
 
-	json.NewEncoder(w).Encode(item)
+	w.Header().Set("ETag", itemETag(item))
+	writeResponse(w, r, http.StatusOK, item)
 }
 
 // deleteItem removes an item by ID
 func deleteItem(w http.ResponseWriter, r *http.Request, id int64) {
-	key := []byte(fmt.Sprintf("%s%d", itemKeyPrefix, id))
-
-	// First check if the item exists (for proper 404 handling)
-	err := db.View(func(txn *badger.Txn) error {
-		_, err := txn.Get(key)
-		return err
-	})
+	// removeItem (store.go) returns badger.ErrKeyNotFound if the item doesn't exist
+	err := itemStoreFrom(r.Context()).removeItem(id)
 
 	if err == badger.ErrKeyNotFound {
 		http.Error(w, `{"error":"not found"}`, http.StatusNotFound)
 		return
 	}
 	if err != nil {
-		slog.Error("failed to check item existence", "error", err)
+		logHandlerError(r.Context(), "items", "database", "failed to delete item", "error", err)
 		http.Error(w, `{"error":"database error"}`, http.StatusInternalServerError)
 		return
 	}
-
-	// Item exists, delete it
-	err = db.Update(func(txn *badger.Txn) error {
-		return txn.Delete(key)
-	})
-	if err != nil {
-		slog.Error("failed to delete item", "error", err)
-		http.Error(w, `{"error":"database error"}`, http.StatusInternalServerError)
-		return
-	}
-
-	// Update Prometheus metrics (defined in metrics.go)
-	itemsTotal.Dec()
+	publishItemEvent(eventItemDeleted, id, map[string]int64{"id": id})
 
 	w.WriteHeader(http.StatusNoContent)
 }
 
+// writeNameConflict writes a 409 response if err is a name collision
+// (or a concurrent write conflict from BadgerDB). Returns true if it handled err.
+func writeNameConflict(w http.ResponseWriter, err error) bool {
+	var conflict *nameConflictError
+	if errors.As(err, &conflict) {
+		w.WriteHeader(http.StatusConflict)
+		json.NewEncoder(w).Encode(map[string]any{
+			"error":       "name already exists",
+			"existing_id": conflict.ExistingID,
+		})
+		return true
+	}
+	// Two transactions touched the same key at once — the client can retry
+	if errors.Is(err, badger.ErrConflict) {
+		http.Error(w, `{"error":"write conflict, retry"}`, http.StatusConflict)
+		return true
+	}
+	return false
+}
+
 // =============================================================================
 // Display Endpoints
 // =============================================================================
@@ -334,34 +473,104 @@ func displayHandler(w http.ResponseWriter, r *http.Request) {
 
 // getDisplay returns the current display data
 func getDisplay(w http.ResponseWriter, r *http.Request) {
-	if displayData == nil {
+	// ?wait=30s&since_version=N holds the request until the display changes
+	// (longpoll.go)
+	ks := keyspaceFrom(r.Context()) // each tenant has its own panel (tenant.go)
+	if !longPoll(w, r, displayVersionHeader, displayVersionOf(ks)) {
+		return
+	}
+
+	data, version := tenants.displayData(ks)
+	if version > 0 {
+		// Lets replicas in cluster mode tell which copy is newer (displaysync.go)
+		w.Header().Set(displayVersionHeader, strconv.FormatInt(version, 10))
+	}
+	if data == nil {
 		// Return empty object if nothing set
 		w.Write([]byte("{}"))
 		return
 	}
-	w.Write(displayData)
+	w.Write(data)
 }
 
 // setDisplay stores arbitrary JSON for display
-// The data is stored in memory (displayData variable from store.go)
+// The data is stored in memory (setDisplayData in store.go)
 // and is lost when the app restarts
 func setDisplay(w http.ResponseWriter, r *http.Request) {
 	// Read the raw JSON body
 	var data json.RawMessage
-	if err := json.NewDecoder(r.Body).Decode(&data); err != nil {
-		http.Error(w, `{"error":"invalid json"}`, http.StatusBadRequest)
+	if !decodeJSONBody(w, r, &data) { // strictjson.go
+		return
+	}
+
+	// A copy from another replica in cluster mode (displaysync.go):
+	// keep it only if it's newer than ours, and don't pass it on
+	if r.Header.Get(displayFromHeader) != "" {
+		version, err := strconv.ParseInt(r.Header.Get(displayVersionHeader), 10, 64)
+		if err != nil {
+			http.Error(w, `{"error":"invalid display version"}`, http.StatusBadRequest)
+			return
+		}
+		if !setDisplayDataIfNewer(displayFromWire(data), version) {
+			// Ours is newer; 200 instead of 201 tells the sender nothing changed
+			w.Write(data)
+			return
+		}
+		displayUpdatesTotal.Inc()
+		w.WriteHeader(http.StatusCreated)
+		w.Write(data)
+		return
+	}
+
+	// Check it against the registered schema, if any (displayschema.go)
+	schema, err := loadDisplaySchema()
+	if err != nil {
+		logHandlerError(r.Context(), "display", "schema", "failed to load display schema", "error", err)
+		http.Error(w, `{"error":"failed to load display schema"}`, http.StatusInternalServerError)
+		return
+	}
+	if schema != nil {
+		var value any
+		json.Unmarshal(data, &value) // already decoded once, can't fail
+		if errs := schema.validate(value); len(errs) > 0 {
+			writeValidationErrors(w, errs)
+			return
+		}
+	}
+
+	// Another tenant's panel is kept apart and stays on this replica (tenant.go)
+	if ks := keyspaceFrom(r.Context()); ks != rootKeyspace {
+		if err := tenants.register(ks); errors.Is(err, errTenantLimit) {
+			writeJSONError(w, http.StatusForbidden, err.Error())
+			return
+		} else if err != nil {
+			logHandlerError(r.Context(), "display", "database", "failed to create tenant", "error", err)
+			http.Error(w, `{"error":"database error"}`, http.StatusInternalServerError)
+			return
+		}
+		version := tenants.setDisplayData(ks, data)
+		displayUpdatesTotal.Inc()
+		w.Header().Set(displayVersionHeader, strconv.FormatInt(version, 10))
+		w.WriteHeader(http.StatusCreated)
+		w.Write(data)
 		return
 	}
 
 	// Store it (package-level variable from store.go)
-	displayData = data
+	version := setDisplayData(data)
 
 	// Update Prometheus metrics (defined in metrics.go)
 	displayUpdatesTotal.Inc()
 
+	// Send it to the other replicas in cluster mode (displaysync.go),
+	// and to EVENT_SINK (events.go)
+	replicateDisplay(data, version)
+	publishDisplayEvent(data)
+
 	// Return what we stored
+	w.Header().Set(displayVersionHeader, strconv.FormatInt(version, 10))
 	w.WriteHeader(http.StatusCreated)
-	w.Write(displayData)
+	w.Write(data)
 }
 
 // =============================================================================
@@ -388,7 +597,7 @@ func systemHandler(w http.ResponseWriter, r *http.Request) {
 	ips := getIPAddresses()
 
 	// Get selected environment variables (safe to expose)
-	envVars := getFilteredEnvVars()
+	envVars := getFilteredEnvVars(r.Context())
 
 	// Get request headers (useful for debugging proxy chains, auth, etc.)
 	// r.Header is map[string][]string — headers can have multiple values
@@ -407,9 +616,15 @@ func systemHandler(w http.ResponseWriter, r *http.Request) {
 		"headers":     headers,
 		"client_ip":   clientIP,
 		"user_agent":  userAgent,
+		"resources":   getResourceInfo(), // limits, usage, uptime (resources.go)
+		"client_cert": clientCert(r),     // mutual TLS only (tls.go)
+	}
+	if spiffeIdentity != nil {
+		response["workload_identity"] = spiffeIdentity.info() // spiffe.go
 	}
 
-	json.NewEncoder(w).Encode(response)
+	// JSON, YAML, or XML (responseformat.go)
+	writeResponse(w, r, http.StatusOK, response)
 }
 
 // getIPAddresses returns all non-loopback IPv4 addresses
@@ -464,7 +679,7 @@ func getIPAddresses() []string {
 //
 // Security note: When ENV_FILTER is set, the user takes responsibility for
 // not exposing sensitive variables (AWS_SECRET_ACCESS_KEY, passwords, etc.)
-func getFilteredEnvVars() map[string]string {
+func getFilteredEnvVars(ctx context.Context) map[string]string {
 	result := make(map[string]string)
 
 	// Check if user provided a custom filter pattern
@@ -477,7 +692,7 @@ func getFilteredEnvVars() map[string]string {
 		if err != nil {
 			// Invalid regex - log the error and return empty map
 			// Better to show nothing than crash or expose unintended vars
-			slog.Error("invalid ENV_FILTER regex", "pattern", filterPattern, "error", err)
+			logHandlerError(ctx, "system", "config", "invalid ENV_FILTER regex", "pattern", filterPattern, "error", err)
 			return result
 		}
 
@@ -541,3 +756,28 @@ func getRequestHeaders(r *http.Request) map[string]string {
 
 	return headers
 }
+
+// =============================================================================
+// Response Helpers
+// =============================================================================
+
+// logHandlerError logs an error and counts it in demoapp_handler_errors_total.
+// Use it instead of plain slog.Error inside handlers.
+//
+// handler and reason become metric labels, so keep them to small fixed sets
+// (e.g. "items"/"database") — never put IDs or error text in them.
+// ctx is the request's context (r.Context()), so the log line carries the
+// request's trace IDs when it has them (trace.go).
+func logHandlerError(ctx context.Context, handler, reason, msg string, args ...any) {
+	handlerErrorsTotal.WithLabelValues(handler, reason).Inc()
+	slog.ErrorContext(ctx, msg, append(args, "handler", handler, "reason", reason)...)
+}
+
+// writeJSONError writes {"error": message} with the given status code.
+// Use this instead of a hand-written JSON string when the message is dynamic —
+// json.Marshal takes care of escaping quotes and special characters.
+func writeJSONError(w http.ResponseWriter, status int, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]string{"error": message})
+}
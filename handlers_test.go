@@ -4,10 +4,15 @@ import (
 	"bytes"
 	"encoding/json"
 	"fmt"
+	"io"
 	"net/http"
 	"net/http/httptest"
 	"os"
+	"strings"
+	"sync"
 	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
 )
 
 // TestMain runs once before all tests in this file.
@@ -29,13 +34,126 @@ func TestMain(m *testing.M) {
 	}
 	defer itemSeq.Release()
 
+	// Start the background job workers (jobs.go)
+	jobBackupDir, err = os.MkdirTemp("", "demo-app-test-backups")
+	if err != nil {
+		panic("failed to create backup dir: " + err.Error())
+	}
+	defer os.RemoveAll(jobBackupDir)
+	if err := startJobWorkers(2); err != nil {
+		panic("failed to start job workers: " + err.Error())
+	}
+
 	// Run all tests
 	os.Exit(m.Run())
 }
 
 // resetDisplayData clears the display panel between tests
 func resetDisplayData() {
-	displayData = nil
+	setDisplayData(nil)
+}
+
+// =============================================================================
+// Test Server
+// =============================================================================
+
+// newTestServer starts the app's routes (registerRoutes in main.go) on an
+// httptest.Server backed by a fresh BadgerDB in in-memory mode, so the test
+// sees no items from other tests and can assert exact counts. Everything is
+// torn down and the shared TestMain store restored when the test finishes.
+//
+// This is the real store, and most of the app reaches it through
+// package-level variables (db, itemSeq). So this swaps them for the test's
+// duration, and also resets other package state (display data, tenants,
+// counters). Tests using it must not call t.Parallel() — two of them would
+// swap the same variables. Concurrency *within* a test is fine (see the
+// Concurrency tests below, and run them with go test -race). Tests of the
+// items API alone can use newFakeItemServer (itemstore_test.go) instead,
+// and run in parallel.
+func newTestServer(t testing.TB) *httptest.Server {
+	t.Helper()
+
+	store, err := initStore(":memory:")
+	if err != nil {
+		t.Fatalf("failed to init test database: %v", err)
+	}
+	seq, err := store.GetSequence([]byte("seq:items"), 100)
+	if err != nil {
+		store.Close()
+		t.Fatalf("failed to init test sequence: %v", err)
+	}
+
+	prevDB, prevSeq := db, itemSeq
+	db, itemSeq = store, seq
+	resetDisplayData()
+	tenants.reset()
+	itemsTotal.Set(0)
+
+	mux := http.NewServeMux()
+	if err := registerRoutes(mux); err != nil {
+		t.Fatalf("failed to register routes: %v", err)
+	}
+	srv := httptest.NewServer(mux)
+
+	// Cleanups run after the test, like pytest fixture teardown
+	t.Cleanup(func() {
+		srv.Close() // waits for in-flight requests
+		counters.stop()
+		hitCounter.stop()
+		tenants.reset()
+		db, itemSeq = prevDB, prevSeq
+		seq.Release()
+		store.Close()
+		syncItemsGauge()
+	})
+	return srv
+}
+
+// doRequest sends a request to the test server and returns the status and body.
+// An empty body sends no body.
+func doRequest(t testing.TB, srv *httptest.Server, method, path, body string) (int, []byte) {
+	t.Helper()
+
+	code, data := doRequestNoFatal(srv, method, path, body)
+	if code == 0 {
+		t.Fatalf("%s %s failed: %s", method, path, data)
+	}
+	return code, data
+}
+
+// doRequestNoFatal is doRequest for use in goroutines, where t.Fatalf
+// isn't allowed. Transport errors come back as status 0.
+func doRequestNoFatal(srv *httptest.Server, method, path, body string) (int, []byte) {
+	var reader io.Reader
+	if body != "" {
+		reader = strings.NewReader(body)
+	}
+	req, err := http.NewRequest(method, srv.URL+path, reader)
+	if err != nil {
+		return 0, []byte(err.Error())
+	}
+	resp, err := srv.Client().Do(req)
+	if err != nil {
+		return 0, []byte(err.Error())
+	}
+	defer resp.Body.Close()
+	data, _ := io.ReadAll(resp.Body)
+	return resp.StatusCode, data
+}
+
+// createTestItem POSTs an item and returns it, failing the test on error
+func createTestItem(t testing.TB, srv *httptest.Server, body string) Item {
+	t.Helper()
+
+	code, data := doRequest(t, srv, "POST", "/api/items", body)
+	if code != http.StatusCreated {
+		t.Fatalf("create: expected status 201, got %d: %s", code, data)
+	}
+	var item Item
+	if err := json.Unmarshal(data, &item); err != nil {
+		t.Fatalf("failed to parse created item: %v", err)
+	}
+	return item
 }
 
 // =============================================================================
@@ -68,25 +186,42 @@ func TestHealthHandler_ReturnsOK(t *testing.T) {
 	}
 }
 
+func TestReadyHandler(t *testing.T) {
+	srv := newTestServer(t)
+
+	code, data := doRequest(t, srv, "GET", "/ready", "")
+	if code != http.StatusOK || !strings.Contains(string(data), `"status":"ready"`) {
+		t.Errorf("ready: %d %s", code, data)
+	}
+
+	// A closed database is not ready
+	prev := db
+	db = nil
+	defer func() { db = prev }()
+	rr := httptest.NewRecorder()
+	readyHandler(rr, httptest.NewRequest("GET", "/ready", nil))
+	if rr.Code != http.StatusServiceUnavailable {
+		t.Errorf("without a database: status = %d, want 503", rr.Code)
+	}
+}
+
 // =============================================================================
 // Items Endpoint Tests
 // =============================================================================
 
 func TestItems_CreateAndList(t *testing.T) {
-	// Create an item
-	body := bytes.NewBufferString(`{"name":"Test Item","description":"A test"}`)
-	req := httptest.NewRequest("POST", "/api/items", body)
-	rr := httptest.NewRecorder()
+	t.Parallel()
+	srv := newFakeItemServer(t)
 
-	itemsHandler(rr, req)
-
-	if rr.Code != http.StatusCreated {
-		t.Fatalf("create: expected status 201, got %d: %s", rr.Code, rr.Body.String())
+	// Create an item
+	code, body := doRequest(t, srv, "POST", "/api/items", `{"name":"Test Item","description":"A test"}`)
+	if code != http.StatusCreated {
+		t.Fatalf("create: expected status 201, got %d: %s", code, body)
 	}
 
 	// Parse the created item to get its ID
 	var created Item
-	if err := json.Unmarshal(rr.Body.Bytes(), &created); err != nil {
+	if err := json.Unmarshal(body, &created); err != nil {
 		t.Fatalf("failed to parse created item: %v", err)
 	}
 
@@ -97,47 +232,55 @@ func TestItems_CreateAndList(t *testing.T) {
 		t.Errorf("expected description 'A test', got '%s'", created.Description)
 	}
 
-	// List items — should include the one we just created
-	req = httptest.NewRequest("GET", "/api/items", nil)
-	rr = httptest.NewRecorder()
-
-	itemsHandler(rr, req)
-
-	if rr.Code != http.StatusOK {
-		t.Fatalf("list: expected status 200, got %d", rr.Code)
+	// List items — the store is fresh, so it's exactly the one we created
+	code, body = doRequest(t, srv, "GET", "/api/items", "")
+	if code != http.StatusOK {
+		t.Fatalf("list: expected status 200, got %d", code)
 	}
 
 	var items []Item
-	if err := json.Unmarshal(rr.Body.Bytes(), &items); err != nil {
+	if err := json.Unmarshal(body, &items); err != nil {
 		t.Fatalf("failed to parse items list: %v", err)
 	}
 
-	if len(items) == 0 {
-		t.Error("expected at least one item in list")
+	if len(items) != 1 || items[0].ID != created.ID {
+		t.Errorf("expected only the created item in list, got %+v", items)
 	}
 }
 
-func TestItems_GetByID(t *testing.T) {
-	// Create an item first
-	body := bytes.NewBufferString(`{"name":"Get Test"}`)
-	req := httptest.NewRequest("POST", "/api/items", body)
-	rr := httptest.NewRecorder()
-	itemsHandler(rr, req)
+func TestItems_ListedInIDOrder(t *testing.T) {
+	t.Parallel()
+	srv := newFakeItemServer(t)
 
-	var created Item
-	json.Unmarshal(rr.Body.Bytes(), &created)
+	// More than 10 items, so IDs cross a digit boundary (9 -> 10)
+	for i := 0; i < 12; i++ {
+		createTestItem(t, srv, fmt.Sprintf(`{"name":"Ordered %d"}`, i))
+	}
 
-	// GET by ID
-	req = httptest.NewRequest("GET", fmt.Sprintf("/api/items/%d", created.ID), nil)
-	rr = httptest.NewRecorder()
-	itemsHandler(rr, req)
+	_, body := doRequest(t, srv, "GET", "/api/items", "")
+	var items []Item
+	json.Unmarshal(body, &items)
 
-	if rr.Code != http.StatusOK {
-		t.Fatalf("expected status 200, got %d: %s", rr.Code, rr.Body.String())
+	for i := 1; i < len(items); i++ {
+		if items[i].ID <= items[i-1].ID {
+			t.Fatalf("expected ascending IDs, got %d after %d", items[i].ID, items[i-1].ID)
+		}
+	}
+}
+
+func TestItems_GetByID(t *testing.T) {
+	t.Parallel()
+	srv := newFakeItemServer(t)
+	created := createTestItem(t, srv, `{"name":"Get Test"}`)
+
+	// GET by ID
+	code, body := doRequest(t, srv, "GET", fmt.Sprintf("/api/items/%d", created.ID), "")
+	if code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", code, body)
 	}
 
 	var fetched Item
-	json.Unmarshal(rr.Body.Bytes(), &fetched)
+	json.Unmarshal(body, &fetched)
 
 	if fetched.Name != "Get Test" {
 		t.Errorf("expected name 'Get Test', got '%s'", fetched.Name)
@@ -145,27 +288,18 @@ func TestItems_GetByID(t *testing.T) {
 }
 
 func TestItems_Update(t *testing.T) {
-	// Create an item
-	body := bytes.NewBufferString(`{"name":"Before Update"}`)
-	req := httptest.NewRequest("POST", "/api/items", body)
-	rr := httptest.NewRecorder()
-	itemsHandler(rr, req)
-
-	var created Item
-	json.Unmarshal(rr.Body.Bytes(), &created)
+	t.Parallel()
+	srv := newFakeItemServer(t)
+	created := createTestItem(t, srv, `{"name":"Before Update"}`)
 
 	// Update it
-	body = bytes.NewBufferString(`{"name":"After Update","description":"Updated"}`)
-	req = httptest.NewRequest("PUT", fmt.Sprintf("/api/items/%d", created.ID), body)
-	rr = httptest.NewRecorder()
-	itemsHandler(rr, req)
-
-	if rr.Code != http.StatusOK {
-		t.Fatalf("expected status 200, got %d: %s", rr.Code, rr.Body.String())
+	code, body := doRequest(t, srv, "PUT", fmt.Sprintf("/api/items/%d", created.ID), `{"name":"After Update","description":"Updated"}`)
+	if code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", code, body)
 	}
 
 	var updated Item
-	json.Unmarshal(rr.Body.Bytes(), &updated)
+	json.Unmarshal(body, &updated)
 
 	if updated.Name != "After Update" {
 		t.Errorf("expected name 'After Update', got '%s'", updated.Name)
@@ -176,73 +310,132 @@ func TestItems_Update(t *testing.T) {
 }
 
 func TestItems_Delete(t *testing.T) {
-	// Create an item
-	body := bytes.NewBufferString(`{"name":"To Delete"}`)
-	req := httptest.NewRequest("POST", "/api/items", body)
-	rr := httptest.NewRecorder()
-	itemsHandler(rr, req)
-
-	var created Item
-	json.Unmarshal(rr.Body.Bytes(), &created)
+	t.Parallel()
+	srv := newFakeItemServer(t)
+	created := createTestItem(t, srv, `{"name":"To Delete"}`)
+	path := fmt.Sprintf("/api/items/%d", created.ID)
 
 	// Delete it
-	req = httptest.NewRequest("DELETE", fmt.Sprintf("/api/items/%d", created.ID), nil)
-	rr = httptest.NewRecorder()
-	itemsHandler(rr, req)
-
-	if rr.Code != http.StatusNoContent {
-		t.Fatalf("expected status 204, got %d: %s", rr.Code, rr.Body.String())
+	code, body := doRequest(t, srv, "DELETE", path, "")
+	if code != http.StatusNoContent {
+		t.Fatalf("expected status 204, got %d: %s", code, body)
 	}
 
 	// Verify it's gone
-	req = httptest.NewRequest("GET", fmt.Sprintf("/api/items/%d", created.ID), nil)
-	rr = httptest.NewRecorder()
-	itemsHandler(rr, req)
-
-	if rr.Code != http.StatusNotFound {
-		t.Errorf("expected status 404 after delete, got %d", rr.Code)
+	if code, _ := doRequest(t, srv, "GET", path, ""); code != http.StatusNotFound {
+		t.Errorf("expected status 404 after delete, got %d", code)
 	}
 }
 
-func TestItems_NotFound(t *testing.T) {
-	req := httptest.NewRequest("GET", "/api/items/999999", nil)
-	rr := httptest.NewRecorder()
-	itemsHandler(rr, req)
+func TestItems_Count(t *testing.T) {
+	t.Parallel()
+	srv := newFakeItemServer(t)
+	createTestItem(t, srv, `{"name":"Counted 1"}`)
+	createTestItem(t, srv, `{"name":"Counted 2"}`)
 
-	if rr.Code != http.StatusNotFound {
-		t.Errorf("expected status 404, got %d", rr.Code)
+	code, body := doRequest(t, srv, "GET", "/api/items/count", "")
+	if code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", code)
+	}
+	var result map[string]int
+	json.Unmarshal(body, &result)
+	if result["count"] != 2 {
+		t.Errorf("expected count 2, got %d", result["count"])
 	}
 }
 
-func TestItems_InvalidID(t *testing.T) {
-	req := httptest.NewRequest("GET", "/api/items/abc", nil)
-	rr := httptest.NewRecorder()
-	itemsHandler(rr, req)
+// TestItems_GaugeSync needs the real store: syncItemsGauge counts BadgerDB
+func TestItems_GaugeSync(t *testing.T) {
+	srv := newTestServer(t)
+	createTestItem(t, srv, `{"name":"Counted 1"}`)
+	createTestItem(t, srv, `{"name":"Counted 2"}`)
 
-	if rr.Code != http.StatusBadRequest {
-		t.Errorf("expected status 400, got %d", rr.Code)
+	// The gauge is corrected to the real count, whatever it drifted to
+	itemsTotal.Set(-5)
+	if err := syncItemsGauge(); err != nil {
+		t.Fatalf("failed to sync gauge: %v", err)
+	}
+	if got := testutil.ToFloat64(itemsTotal); got != 2 {
+		t.Errorf("expected items gauge 2, got %v", got)
 	}
 }
 
-func TestItems_InvalidJSON(t *testing.T) {
-	body := bytes.NewBufferString(`not json`)
-	req := httptest.NewRequest("POST", "/api/items", body)
-	rr := httptest.NewRecorder()
-	itemsHandler(rr, req)
-
-	if rr.Code != http.StatusBadRequest {
-		t.Errorf("expected status 400, got %d", rr.Code)
+func TestItems_StreamNDJSON(t *testing.T) {
+	t.Parallel()
+	srv := newFakeItemServer(t)
+	for i := 0; i < streamFlushEvery+5; i++ {
+		createTestItem(t, srv, fmt.Sprintf(`{"name":"Streamed %d"}`, i))
+	}
+
+	// Both ways of asking for a stream
+	requests := map[string]func(*http.Request){
+		"query":  func(r *http.Request) { r.URL.RawQuery = "stream=true" },
+		"accept": func(r *http.Request) { r.Header.Set("Accept", "application/x-ndjson") },
+	}
+	for name, setup := range requests {
+		t.Run(name, func(t *testing.T) {
+			req, _ := http.NewRequest("GET", srv.URL+"/api/items", nil)
+			setup(req)
+			resp, err := srv.Client().Do(req)
+			if err != nil {
+				t.Fatalf("request failed: %v", err)
+			}
+			defer resp.Body.Close()
+
+			if ct := resp.Header.Get("Content-Type"); ct != "application/x-ndjson" {
+				t.Errorf("expected NDJSON content type, got %q", ct)
+			}
+
+			// Each line is one complete item
+			lines := 0
+			decoder := json.NewDecoder(resp.Body)
+			for decoder.More() {
+				var item Item
+				if err := decoder.Decode(&item); err != nil {
+					t.Fatalf("line %d: invalid item: %v", lines+1, err)
+				}
+				if !strings.HasPrefix(item.Name, "Streamed ") {
+					t.Errorf("unexpected item %+v", item)
+				}
+				lines++
+			}
+			if lines != streamFlushEvery+5 {
+				t.Errorf("expected %d items, got %d", streamFlushEvery+5, lines)
+			}
+		})
 	}
 }
 
-func TestItems_MissingName(t *testing.T) {
-	body := bytes.NewBufferString(`{"description":"no name"}`)
-	req := httptest.NewRequest("POST", "/api/items", body)
-	rr := httptest.NewRecorder()
-	itemsHandler(rr, req)
-
-	if rr.Code != http.StatusBadRequest {
-		t.Errorf("expected status 400, got %d", rr.Code)
+// TestItems_Errors covers requests the items API must reject
+func TestItems_Errors(t *testing.T) {
+	t.Parallel()
+	srv := newFakeItemServer(t)
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		body   string
+		want   int
+	}{
+		{"not found", "GET", "/api/items/999999", "", http.StatusNotFound},
+		{"invalid ID", "GET", "/api/items/abc", "", http.StatusBadRequest},
+		{"invalid JSON", "POST", "/api/items", `not json`, http.StatusBadRequest},
+		{"missing name", "POST", "/api/items", `{"description":"no name"}`, http.StatusBadRequest},
+		{"update missing item", "PUT", "/api/items/999999", `{"name":"x"}`, http.StatusNotFound},
+		{"delete missing item", "DELETE", "/api/items/999999", "", http.StatusNotFound},
+		{"method not allowed", "PATCH", "/api/items", "", http.StatusMethodNotAllowed},
+	}
+
+	for _, tt := range tests {
+		// t.Run gives each case its own name in test output
+		// (like pytest's parametrize ids)
+		t.Run(tt.name, func(t *testing.T) {
+			code, body := doRequest(t, srv, tt.method, tt.path, tt.body)
+			if code != tt.want {
+				t.Errorf("expected status %d, got %d: %s", tt.want, code, body)
+			}
+		})
 	}
 }
 
@@ -332,7 +525,7 @@ func TestSystem_ReturnsExpectedFields(t *testing.T) {
 	}
 
 	// Check required fields exist
-	for _, field := range []string{"hostname", "ips", "environment", "headers", "client_ip", "user_agent"} {
+	for _, field := range []string{"hostname", "ips", "environment", "headers", "client_ip", "user_agent", "resources"} {
 		if _, ok := result[field]; !ok {
 			t.Errorf("expected field '%s' in system response", field)
 		}
@@ -348,3 +541,228 @@ func TestSystem_MethodNotAllowed(t *testing.T) {
 		t.Errorf("expected status 405, got %d", rr.Code)
 	}
 }
+
+func TestItems_UniqueNames(t *testing.T) {
+	newTestServer(t) // isolated store; handlers are called directly below
+	uniqueItemNames = true
+	defer func() { uniqueItemNames = false }()
+	if err := rebuildNameIndex(); err != nil {
+		t.Fatalf("failed to build name index: %v", err)
+	}
+
+	// First create succeeds
+	body := bytes.NewBufferString(`{"name":"Unique Widget"}`)
+	req := httptest.NewRequest("POST", "/api/items", body)
+	rr := httptest.NewRecorder()
+	itemsHandler(rr, req)
+
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("expected status 201, got %d: %s", rr.Code, rr.Body.String())
+	}
+	var created Item
+	json.Unmarshal(rr.Body.Bytes(), &created)
+
+	// Same name (different case) conflicts
+	body = bytes.NewBufferString(`{"name":"unique widget"}`)
+	req = httptest.NewRequest("POST", "/api/items", body)
+	rr = httptest.NewRecorder()
+	itemsHandler(rr, req)
+
+	if rr.Code != http.StatusConflict {
+		t.Fatalf("expected status 409, got %d: %s", rr.Code, rr.Body.String())
+	}
+
+	// After deleting the original, the name is free again
+	req = httptest.NewRequest("DELETE", fmt.Sprintf("/api/items/%d", created.ID), nil)
+	rr = httptest.NewRecorder()
+	itemsHandler(rr, req)
+
+	body = bytes.NewBufferString(`{"name":"unique widget"}`)
+	req = httptest.NewRequest("POST", "/api/items", body)
+	rr = httptest.NewRecorder()
+	itemsHandler(rr, req)
+
+	if rr.Code != http.StatusCreated {
+		t.Errorf("expected status 201 after delete freed the name, got %d: %s", rr.Code, rr.Body.String())
+	}
+}
+
+func TestItems_IdempotencyKey(t *testing.T) {
+	newTestServer(t) // isolated store; handlers are called directly below
+
+	post := func(body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", "/api/items", bytes.NewBufferString(body))
+		req.Header.Set("Idempotency-Key", "test-retry-1")
+		rr := httptest.NewRecorder()
+		itemsHandler(rr, req)
+		return rr
+	}
+
+	first := post(`{"name":"Idempotent"}`)
+	if first.Code != http.StatusCreated {
+		t.Fatalf("expected status 201, got %d: %s", first.Code, first.Body.String())
+	}
+
+	// Retry with the same key and body returns the same item
+	retry := post(`{"name":"Idempotent"}`)
+	if retry.Code != http.StatusCreated {
+		t.Fatalf("retry: expected status 201, got %d", retry.Code)
+	}
+	if retry.Header().Get("Idempotent-Replayed") != "true" {
+		t.Error("expected Idempotent-Replayed header on retry")
+	}
+
+	var a, b Item
+	json.Unmarshal(first.Body.Bytes(), &a)
+	json.Unmarshal(retry.Body.Bytes(), &b)
+	if a.ID != b.ID {
+		t.Errorf("expected same item ID on retry, got %d and %d", a.ID, b.ID)
+	}
+
+	// Same key with a different body is rejected
+	mismatch := post(`{"name":"Something Else"}`)
+	if mismatch.Code != http.StatusUnprocessableEntity {
+		t.Errorf("expected status 422 for reused key, got %d", mismatch.Code)
+	}
+}
+
+// =============================================================================
+// Concurrency Tests
+// =============================================================================
+//
+// Many clients hitting the API at once, as in a load demo. These catch data
+// races (run with go test -race) and lost updates in the store.
+
+func TestItems_ConcurrentCreate(t *testing.T) {
+	srv := newTestServer(t)
+	const clients = 20
+
+	var wg sync.WaitGroup
+	ids := make(chan int64, clients)
+	for i := 0; i < clients; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			// t.Fatalf can't be used outside the test goroutine, so use Errorf
+			code, body := doRequestNoFatal(srv, "POST", "/api/items", fmt.Sprintf(`{"name":"Concurrent %d"}`, i))
+			if code != http.StatusCreated {
+				t.Errorf("expected status 201, got %d: %s", code, body)
+				return
+			}
+			var item Item
+			json.Unmarshal(body, &item)
+			ids <- item.ID
+		}(i)
+	}
+	wg.Wait()
+	close(ids)
+
+	// Every create got its own ID
+	seen := make(map[int64]bool)
+	for id := range ids {
+		if seen[id] {
+			t.Errorf("duplicate item ID %d", id)
+		}
+		seen[id] = true
+	}
+
+	count, err := countItems()
+	if err != nil {
+		t.Fatalf("failed to count items: %v", err)
+	}
+	if count != clients {
+		t.Errorf("expected %d items, got %d", clients, count)
+	}
+	if got := testutil.ToFloat64(itemsTotal); got != clients {
+		t.Errorf("expected items gauge %d, got %v", clients, got)
+	}
+}
+
+func TestItems_ConcurrentUpdateAndDelete(t *testing.T) {
+	srv := newTestServer(t)
+
+	// Half the items get updated, the other half deleted — all at once,
+	// plus readers listing the collection in between
+	const items = 10
+	var created []Item
+	for i := 0; i < items; i++ {
+		created = append(created, createTestItem(t, srv, fmt.Sprintf(`{"name":"Item %d"}`, i)))
+	}
+
+	var wg sync.WaitGroup
+	for i, item := range created {
+		wg.Add(2)
+		go func(i int, item Item) {
+			defer wg.Done()
+			path := fmt.Sprintf("/api/items/%d", item.ID)
+			if i%2 == 0 {
+				if code, body := doRequestNoFatal(srv, "PUT", path, `{"name":"Updated"}`); code != http.StatusOK {
+					t.Errorf("update: expected status 200, got %d: %s", code, body)
+				}
+			} else {
+				if code, body := doRequestNoFatal(srv, "DELETE", path, ""); code != http.StatusNoContent {
+					t.Errorf("delete: expected status 204, got %d: %s", code, body)
+				}
+			}
+		}(i, item)
+		go func() {
+			defer wg.Done()
+			if code, body := doRequestNoFatal(srv, "GET", "/api/items", ""); code != http.StatusOK {
+				t.Errorf("list: expected status 200, got %d: %s", code, body)
+			}
+		}()
+	}
+	wg.Wait()
+
+	remaining, err := loadAllItems()
+	if err != nil {
+		t.Fatalf("failed to load items: %v", err)
+	}
+	if len(remaining) != items/2 {
+		t.Fatalf("expected %d items left, got %d", items/2, len(remaining))
+	}
+	for _, item := range remaining {
+		if item.Name != "Updated" {
+			t.Errorf("expected surviving item %d to be updated, got name %q", item.ID, item.Name)
+		}
+	}
+	if got := testutil.ToFloat64(itemsTotal); got != items/2 {
+		t.Errorf("expected items gauge %d, got %v", items/2, got)
+	}
+}
+
+func TestItems_ConcurrentUniqueNames(t *testing.T) {
+	srv := newTestServer(t)
+	uniqueItemNames = true
+	defer func() { uniqueItemNames = false }()
+
+	// Everyone races for the same name; exactly one may win
+	const clients = 10
+	var wg sync.WaitGroup
+	codes := make(chan int, clients)
+	for i := 0; i < clients; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			code, _ := doRequestNoFatal(srv, "POST", "/api/items", `{"name":"Contested"}`)
+			codes <- code
+		}()
+	}
+	wg.Wait()
+	close(codes)
+
+	created, conflicts := 0, 0
+	for code := range codes {
+		switch code {
+		case http.StatusCreated:
+			created++
+		case http.StatusConflict:
+			conflicts++
+		default:
+			t.Errorf("unexpected status %d", code)
+		}
+	}
+	if created != 1 || conflicts != clients-1 {
+		t.Errorf("expected 1 created and %d conflicts, got %d and %d", clients-1, created, conflicts)
+	}
+}
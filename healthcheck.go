@@ -0,0 +1,176 @@
+package main
+
+import (
+	"crypto/tls"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// =============================================================================
+// Healthcheck Subcommand
+// =============================================================================
+//
+// "demo-app healthcheck" probes a running server and exits 0 (healthy) or 1
+// (unhealthy), which is what Docker HEALTHCHECK and exec probes want. With
+// no flags it checks this container's own /health and /ready:
+//
+//	./demo-app healthcheck
+//	{"healthy":true,"checks":[{"url":"http://localhost:8080/health","status":200,...},...]}
+//
+// Flags make the same binary a probe for anything that speaks HTTP, so a
+// distroless image with no curl can still check a sidecar or a dependency:
+//
+//	./demo-app healthcheck --url https://demo.example.com --insecure
+//	./demo-app healthcheck --url http://keycloak:8080/realms/master --expect-status 200,302
+//	./demo-app healthcheck --cert client.pem --key client-key.pem --ca ca.pem  # mutual TLS (tls.go)
+//
+// A --url without a path is a demo-app: /health and /ready are both checked.
+// A --url with a path is checked as is. Redirects aren't followed: a 302 is
+// the status compared with --expect-status. The result is always one JSON
+// line on stdout; exit code 2 means the flags were wrong.
+
+// HealthcheckResult is one URL's outcome
+type HealthcheckResult struct {
+	URL       string  `json:"url"`
+	Status    int     `json:"status,omitempty"`
+	Expected  []int   `json:"expected"`
+	OK        bool    `json:"ok"`
+	LatencyMs float64 `json:"latency_ms"`
+	Error     string  `json:"error,omitempty"`
+}
+
+// HealthcheckReport is what "demo-app healthcheck" prints
+type HealthcheckReport struct {
+	Healthy bool                `json:"healthy"`
+	Checks  []HealthcheckResult `json:"checks"`
+}
+
+// runHealthcheck is the entry point for "demo-app healthcheck"; returns the
+// exit code
+func runHealthcheck(args []string, out io.Writer) int {
+	flags := flag.NewFlagSet("healthcheck", flag.ContinueOnError)
+	scheme := "http"
+	if os.Getenv("TLS_CERT_FILE") != "" {
+		scheme = "https" // this container serves TLS (tls.go)
+	}
+	baseURL := flags.String("url", scheme+"://localhost:"+envString("PORT", "8080"), "server (checks /health and /ready) or full URL to check")
+	timeout := flags.Duration("timeout", 3*time.Second, "time allowed for each request")
+	insecure := flags.Bool("insecure", false, "skip TLS certificate verification")
+	certFile := flags.String("cert", "", "client certificate for servers that require mutual TLS")
+	keyFile := flags.String("key", "", "private key for --cert")
+	caFile := flags.String("ca", "", "CA certificates to verify the server with")
+	expect := flags.String("expect-status", "200", "comma-separated acceptable status codes")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	expected, err := parseStatusList(*expect)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "healthcheck: --expect-status: %v\n", err)
+		return 2
+	}
+	targets, err := healthcheckTargets(*baseURL)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "healthcheck: --url: %v\n", err)
+		return 2
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = &tls.Config{}
+	if *insecure {
+		// Self-signed certificates are normal inside a cluster; --insecure
+		// checks the server answers without checking who it is
+		transport.TLSClientConfig.InsecureSkipVerify = true
+	}
+	if *certFile != "" {
+		cert, err := tls.LoadX509KeyPair(*certFile, *keyFile)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "healthcheck: --cert: %v\n", err)
+			return 2
+		}
+		transport.TLSClientConfig.Certificates = []tls.Certificate{cert}
+	}
+	if *caFile != "" {
+		pool, err := loadCertPool(*caFile)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "healthcheck: --ca: %v\n", err)
+			return 2
+		}
+		transport.TLSClientConfig.RootCAs = pool
+	}
+	client := &http.Client{
+		Timeout:   *timeout,
+		Transport: transport,
+		// Report a redirect as is, so --expect-status can name it
+		CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse },
+	}
+
+	report := HealthcheckReport{Healthy: true}
+	for _, target := range targets {
+		result := probeURL(client, target, expected)
+		report.Healthy = report.Healthy && result.OK
+		report.Checks = append(report.Checks, result)
+	}
+	json.NewEncoder(out).Encode(report)
+
+	if !report.Healthy {
+		return 1
+	}
+	return 0
+}
+
+// healthcheckTargets expands --url: a bare server gets /health and /ready
+func healthcheckTargets(rawURL string) ([]string, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("want http(s)://host[:port][/path], got %q", rawURL)
+	}
+	if u.Path != "" && u.Path != "/" {
+		return []string{u.String()}, nil
+	}
+	base := strings.TrimSuffix(u.String(), "/")
+	return []string{base + "/health", base + "/ready"}, nil
+}
+
+// parseStatusList reads "200" or "200,204,302"
+func parseStatusList(raw string) ([]int, error) {
+	var codes []int
+	for _, part := range strings.Split(raw, ",") {
+		code, err := strconv.Atoi(strings.TrimSpace(part))
+		if err != nil || code < 100 || code > 599 {
+			return nil, fmt.Errorf("invalid status code %q", part)
+		}
+		codes = append(codes, code)
+	}
+	return codes, nil
+}
+
+// probeURL makes one GET and compares the status with expected
+func probeURL(client *http.Client, target string, expected []int) HealthcheckResult {
+	result := HealthcheckResult{URL: target, Expected: expected}
+	start := time.Now()
+	resp, err := client.Get(target)
+	result.LatencyMs = float64(time.Since(start).Microseconds()) / 1000
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+	resp.Body.Close()
+
+	result.Status = resp.StatusCode
+	result.OK = slices.Contains(expected, resp.StatusCode)
+	if !result.OK {
+		result.Error = "unexpected status " + resp.Status
+	}
+	return result
+}
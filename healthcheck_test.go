@@ -0,0 +1,92 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestHealthcheck_ChecksHealthAndReady(t *testing.T) {
+	srv := newTestServer(t)
+
+	var out bytes.Buffer
+	if code := runHealthcheck([]string{"--url", srv.URL}, &out); code != 0 {
+		t.Fatalf("exit code = %d, output %s", code, out.String())
+	}
+	var report HealthcheckReport
+	if err := json.Unmarshal(out.Bytes(), &report); err != nil {
+		t.Fatalf("output isn't JSON: %v: %s", err, out.String())
+	}
+	if !report.Healthy || len(report.Checks) != 2 ||
+		!strings.HasSuffix(report.Checks[0].URL, "/health") || !strings.HasSuffix(report.Checks[1].URL, "/ready") {
+		t.Errorf("report = %+v", report)
+	}
+}
+
+func TestHealthcheck_ExpectStatus(t *testing.T) {
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer target.Close()
+
+	var out bytes.Buffer
+	if code := runHealthcheck([]string{"--url", target.URL + "/ping"}, &out); code != 1 {
+		t.Errorf("204 with the default 200 expected: exit code = %d, want 1", code)
+	}
+	if !strings.Contains(out.String(), "unexpected status 204") {
+		t.Errorf("output = %s", out.String())
+	}
+
+	out.Reset()
+	if code := runHealthcheck([]string{"--url", target.URL + "/ping", "--expect-status", "200,204"}, &out); code != 0 {
+		t.Errorf("exit code = %d, want 0: %s", code, out.String())
+	}
+}
+
+func TestHealthcheck_RedirectNotFollowed(t *testing.T) {
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/login" {
+			return
+		}
+		http.Redirect(w, r, "/login", http.StatusFound)
+	}))
+	defer target.Close()
+
+	var out bytes.Buffer
+	if code := runHealthcheck([]string{"--url", target.URL + "/realms/master", "--expect-status", "302"}, &out); code != 0 {
+		t.Errorf("exit code = %d, want 0: %s", code, out.String())
+	}
+	out.Reset()
+	if code := runHealthcheck([]string{"--url", target.URL + "/realms/master"}, &out); code != 1 {
+		t.Errorf("302 with the default 200 expected: exit code = %d, want 1", code)
+	}
+}
+
+func TestHealthcheck_Insecure(t *testing.T) {
+	target := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer target.Close()
+
+	var out bytes.Buffer
+	if code := runHealthcheck([]string{"--url", target.URL + "/health"}, &out); code != 1 {
+		t.Errorf("self-signed certificate: exit code = %d, want 1", code)
+	}
+	out.Reset()
+	if code := runHealthcheck([]string{"--url", target.URL + "/health", "--insecure"}, &out); code != 0 {
+		t.Errorf("--insecure: exit code = %d, want 0: %s", code, out.String())
+	}
+}
+
+func TestHealthcheck_BadFlags(t *testing.T) {
+	for _, args := range [][]string{
+		{"--expect-status", "ok"},
+		{"--url", "localhost:8080"},
+		{"--timeout", "soon"},
+	} {
+		if code := runHealthcheck(args, &bytes.Buffer{}); code != 2 {
+			t.Errorf("%v: exit code = %d, want 2", args, code)
+		}
+	}
+}
@@ -0,0 +1,173 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	badger "github.com/dgraph-io/badger/v4"
+)
+
+// =============================================================================
+// Merged Hit Counters
+// =============================================================================
+//
+// Every /api/ request this replica serves adds 1 to its hit counter, a key
+// that's written far more often than any item. A plain read-modify-write
+// counter would make those requests fight over one key (BadgerDB aborts all
+// but one of them with ErrConflict). A merge operator doesn't read at all:
+// each hit appends a small "+1" entry, and a background goroutine folds the
+// entries into one value every 10s. GET /api/counters/merged shows it:
+//
+//	curl http://localhost:8080/api/counters/merged
+//	{"instance":"demo-app-7d9f-abcde","hits":1873,"pending_entries":41,
+//	 "adds":1873,"failed_adds":0,"fold_interval":"10s","enabled":true}
+//
+//   - hits: the merged value, the folded total plus every pending entry
+//   - pending_entries: the entries a read has to merge right now. Run a load
+//     test and it climbs; wait 10s and it drops back to 1.
+//   - adds: hits this process recorded since it started
+//
+// The key is per replica ("hits:<hostname>") and isn't replicated, so each
+// pod counts its own traffic. The named counters in counters.go use the same
+// mechanism. MERGED_HIT_COUNTERS=false turns the per-request write off, e.g.
+// for read benchmarks.
+
+// Prefix for hit counter keys
+const hitCounterKeyPrefix = "hits:"
+
+// mergedHitCounters is MERGED_HIT_COUNTERS (set in main)
+var mergedHitCounters = true
+
+// MergedHits is GET /api/counters/merged
+type MergedHits struct {
+	Instance       string `json:"instance"`
+	Hits           uint64 `json:"hits"`
+	PendingEntries int    `json:"pending_entries"`
+	Adds           uint64 `json:"adds"`
+	FailedAdds     uint64 `json:"failed_adds"`
+	FoldInterval   string `json:"fold_interval"`
+	Enabled        bool   `json:"enabled"`
+}
+
+// hitCounterStore holds this replica's merge operator
+type hitCounterStore struct {
+	mu     sync.Mutex
+	db     *badger.DB // the DB the operator was created for
+	op     *badger.MergeOperator
+	adds   atomic.Uint64
+	failed atomic.Uint64
+}
+
+var hitCounter = &hitCounterStore{}
+
+// hitCounterKey is this replica's key
+func hitCounterKey() []byte {
+	hostname, _ := os.Hostname()
+	return []byte(hitCounterKeyPrefix + hostname)
+}
+
+// operator returns the merge operator, starting it if needed
+func (s *hitCounterStore) operator() *badger.MergeOperator {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	// The store was swapped (tests) — the old operator is useless
+	if s.op != nil && s.db != db {
+		s.op.Stop()
+		s.op = nil
+	}
+	if s.op == nil {
+		s.db = db
+		s.op = db.GetMergeOperator(hitCounterKey(), addUint64, counterCompactInterval)
+	}
+	return s.op
+}
+
+// add counts one request to path. Only /api/ requests count, and a failed
+// write is logged rather than failing the request it counts.
+func (s *hitCounterStore) add(path string) {
+	if !mergedHitCounters || db == nil || !strings.HasPrefix(path, "/api/") {
+		return
+	}
+	if err := s.operator().Add(encodeUint64(1)); err != nil {
+		s.failed.Add(1)
+		slog.Debug("failed to record hit", "error", err)
+		return
+	}
+	s.adds.Add(1)
+}
+
+// read returns the merged value and how many entries it was merged from
+func (s *hitCounterStore) read() (MergedHits, error) {
+	hostname, _ := os.Hostname()
+	hits := MergedHits{
+		Instance:     hostname,
+		Adds:         s.adds.Load(),
+		FailedAdds:   s.failed.Load(),
+		FoldInterval: counterCompactInterval.String(),
+		Enabled:      mergedHitCounters,
+	}
+	value, err := s.operator().Get()
+	if errors.Is(err, badger.ErrKeyNotFound) {
+		return hits, nil // no hits yet
+	}
+	if err != nil {
+		return hits, err
+	}
+	hits.Hits = decodeUint64(value)
+
+	// Count the versions Get just merged: every entry back to the last fold
+	// (which marks itself as the oldest one worth reading)
+	err = dbView("hit_counter_read", hitCounterKeyPrefix, func(txn *badger.Txn) error {
+		opts := badger.DefaultIteratorOptions
+		opts.AllVersions = true
+		opts.PrefetchValues = false
+		it := txn.NewKeyIterator(hitCounterKey(), opts)
+		defer it.Close()
+		for it.Rewind(); it.Valid(); it.Next() {
+			item := it.Item()
+			if item.IsDeletedOrExpired() {
+				break
+			}
+			hits.PendingEntries++
+			if item.DiscardEarlierVersions() {
+				break
+			}
+		}
+		return nil
+	})
+	return hits, err
+}
+
+// stop folds the entries one last time and stops the operator. Called on
+// shutdown, and by reset and restore before the keys are dropped.
+func (s *hitCounterStore) stop() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.op != nil {
+		s.op.Stop()
+		s.op = nil
+	}
+}
+
+// mergedCountersHandler handles GET /api/counters/merged
+func mergedCountersHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	if r.Method != http.MethodGet {
+		http.Error(w, `{"error":"method not allowed"}`, http.StatusMethodNotAllowed)
+		return
+	}
+
+	hits, err := hitCounter.read()
+	if err != nil {
+		logHandlerError(r.Context(), "counters", "database", "failed to read hit counter", "error", err)
+		http.Error(w, `{"error":"database error"}`, http.StatusInternalServerError)
+		return
+	}
+	json.NewEncoder(w).Encode(hits)
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"sync"
+	"testing"
+)
+
+func TestMergedHits_CountsAPIRequests(t *testing.T) {
+	srv := newTestServer(t)
+
+	// Concurrent requests each append an entry; none of them conflict
+	const n = 30
+	var wg sync.WaitGroup
+	for range n {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if code, body := doRequestNoFatal(srv, http.MethodGet, "/api/items", ""); code != http.StatusOK {
+				t.Errorf("GET /api/items = %d %s", code, body)
+			}
+		}()
+	}
+	wg.Wait()
+	doRequest(t, srv, http.MethodGet, "/health", "") // not an API request
+
+	code, body := doRequest(t, srv, http.MethodGet, "/api/counters/merged", "")
+	if code != http.StatusOK {
+		t.Fatalf("GET /api/counters/merged = %d %s", code, body)
+	}
+	var hits MergedHits
+	if err := json.Unmarshal(body, &hits); err != nil {
+		t.Fatalf("invalid JSON: %s", body)
+	}
+	// The read happens before its own request is counted
+	if hits.Hits != n || hits.PendingEntries != n || !hits.Enabled || hits.Instance == "" {
+		t.Errorf("merged = %s, want %d hits in %d entries", body, n, n)
+	}
+
+	// Stopping the operator folds the entries into one
+	hitCounter.stop()
+	_, body = doRequest(t, srv, http.MethodGet, "/api/counters/merged", "")
+	json.Unmarshal(body, &hits)
+	if hits.Hits != n+1 || hits.PendingEntries != 1 {
+		t.Errorf("after fold = %s, want %d hits in 1 entry", body, n+1)
+	}
+}
+
+func TestMergedHits_Disabled(t *testing.T) {
+	srv := newTestServer(t)
+	mergedHitCounters = false
+	t.Cleanup(func() { mergedHitCounters = true })
+
+	doRequest(t, srv, http.MethodGet, "/api/items", "")
+	_, body := doRequest(t, srv, http.MethodGet, "/api/counters/merged", "")
+	var hits MergedHits
+	json.Unmarshal(body, &hits)
+	if hits.Hits != 0 || hits.Enabled {
+		t.Errorf("merged = %s, want nothing counted", body)
+	}
+
+	if code, _ := doRequest(t, srv, http.MethodPost, "/api/counters/merged", ""); code != http.StatusMethodNotAllowed {
+		t.Errorf("POST = %d, want 405", code)
+	}
+}
@@ -0,0 +1,370 @@
+package main
+
+import (
+	"embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"net/http"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	badger "github.com/dgraph-io/badger/v4"
+)
+
+// =============================================================================
+// Dashboard Translations (i18n)
+// =============================================================================
+//
+// The dashboard's words come from the server, so a workshop in Madrid or
+// Tokyo can run the demo in the local language:
+//
+//	curl http://localhost:8080/api/i18n/es                      # Spanish strings
+//	curl -H 'Accept-Language: de-AT,de;q=0.9' http://localhost:8080/api/i18n
+//	open 'http://localhost:8080/?lang=ja'                       # force a language
+//
+// Bundles for en, es, fr, de, and ja are embedded from i18n/*.json. English
+// is the base: a key missing from a translation shows in English rather
+// than not at all. A regional tag falls back to its language, so "es-MX"
+// gets the Spanish bundle.
+//
+// Fix a translation or add a language live through the admin API. Overrides
+// are merged over the embedded bundle, only keys the English bundle has are
+// accepted, and they're stored in BadgerDB (replicated like the display
+// template):
+//
+//	curl -X PUT http://localhost:8080/api/admin/i18n/es -d '{"panel.items":"Productos"}'
+//	curl -X PUT http://localhost:8080/api/admin/i18n/nl -d '{"panel.items":"Artikelen"}'
+//	curl -X DELETE http://localhost:8080/api/admin/i18n/es       # drop the overrides
+
+//go:embed i18n/*.json
+var i18nFiles embed.FS
+
+// Language used when nothing else matches, and the base every bundle
+// falls back to
+const defaultLanguage = "en"
+
+// Overrides are stored under i18n:<lang>
+const i18nKeyPrefix = "i18n:"
+
+// Largest override upload accepted
+const maxI18nOverrideBytes = 64 << 10 // 64 KiB
+
+// Language tags like "en", "pt-br", "zh-hant-tw" (lowercased before checking)
+var languageTagPattern = regexp.MustCompile(`^[a-z]{2,3}(-[a-z0-9]{2,8})*$`)
+
+// i18nBundles are the embedded translations, by language tag
+var i18nBundles = mustLoadI18nBundles(i18nFiles)
+
+// mustLoadI18nBundles reads i18n/*.json. A broken bundle is a build
+// mistake, so it stops the program (i18n_test.go catches it first).
+func mustLoadI18nBundles(fsys fs.FS) map[string]map[string]string {
+	names, err := fs.Glob(fsys, "i18n/*.json")
+	if err != nil {
+		panic(err)
+	}
+	bundles := map[string]map[string]string{}
+	for _, name := range names {
+		data, err := fs.ReadFile(fsys, name)
+		if err != nil {
+			panic(err)
+		}
+		var bundle map[string]string
+		if err := json.Unmarshal(data, &bundle); err != nil {
+			panic(fmt.Sprintf("%s: %v", name, err))
+		}
+		bundles[strings.TrimSuffix(path.Base(name), ".json")] = bundle
+	}
+	if bundles[defaultLanguage] == nil {
+		panic("i18n: no " + defaultLanguage + ".json bundle")
+	}
+	return bundles
+}
+
+// i18nResponse is what GET /api/i18n returns
+type i18nResponse struct {
+	Lang      string            `json:"lang"`
+	Languages []string          `json:"languages"` // everything available
+	Strings   map[string]string `json:"strings"`
+}
+
+// baseLanguage is "es" for "es-mx"
+func baseLanguage(tag string) string {
+	base, _, _ := strings.Cut(tag, "-")
+	return base
+}
+
+// readI18nOverrides returns the uploaded overrides for a language
+// (badger.ErrKeyNotFound if there are none)
+func readI18nOverrides(lang string) (map[string]string, error) {
+	key := i18nKeyPrefix + lang
+	var overrides map[string]string
+	err := dbView("i18n_get", key, func(txn *badger.Txn) error {
+		item, err := txn.Get([]byte(key))
+		if err != nil {
+			return err
+		}
+		return item.Value(func(val []byte) error { return json.Unmarshal(val, &overrides) })
+	})
+	return overrides, err
+}
+
+// availableLanguages lists the embedded languages plus any added through
+// overrides, sorted
+func availableLanguages() ([]string, error) {
+	seen := map[string]bool{}
+	for lang := range i18nBundles {
+		seen[lang] = true
+	}
+	err := dbView("i18n_list", i18nKeyPrefix, func(txn *badger.Txn) error {
+		opts := badger.DefaultIteratorOptions
+		opts.PrefetchValues = false
+		it := txn.NewIterator(opts)
+		defer it.Close()
+
+		prefix := []byte(i18nKeyPrefix)
+		for it.Seek(prefix); it.ValidForPrefix(prefix); it.Next() {
+			seen[strings.TrimPrefix(string(it.Item().Key()), i18nKeyPrefix)] = true
+		}
+		return nil
+	})
+	languages := make([]string, 0, len(seen))
+	for lang := range seen {
+		languages = append(languages, lang)
+	}
+	sort.Strings(languages)
+	return languages, err
+}
+
+// matchLanguage picks the available language for a tag: itself, or its
+// base language ("es-mx" -> "es"). ok is false when neither is there.
+func matchLanguage(tag string, available []string) (lang string, ok bool) {
+	for _, candidate := range []string{tag, baseLanguage(tag)} {
+		for _, lang := range available {
+			if lang == candidate {
+				return lang, true
+			}
+		}
+	}
+	return "", false
+}
+
+// negotiateLanguage picks from an Accept-Language header such as
+// "de-AT,de;q=0.9,en;q=0.5", highest q first. "*" or no match means the
+// default language.
+func negotiateLanguage(header string, available []string) string {
+	type choice struct {
+		tag string
+		q   float64
+	}
+	var choices []choice
+	for _, part := range strings.Split(header, ",") {
+		tag, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		tag = strings.ToLower(strings.TrimSpace(tag))
+		if tag == "" {
+			continue
+		}
+		q := 1.0
+		if value, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			parsed, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				continue
+			}
+			q = parsed
+		}
+		if q > 0 {
+			choices = append(choices, choice{tag, q})
+		}
+	}
+	// Stable, so equal weights keep the order the browser sent
+	sort.SliceStable(choices, func(i, j int) bool { return choices[i].q > choices[j].q })
+
+	for _, c := range choices {
+		if c.tag == "*" {
+			break
+		}
+		if lang, ok := matchLanguage(c.tag, available); ok {
+			return lang
+		}
+	}
+	return defaultLanguage
+}
+
+// translations merges the strings for a language: English first, then the
+// base language, then the language itself, each as embedded and then with
+// its overrides on top
+func translations(lang string) (map[string]string, error) {
+	layers := []string{defaultLanguage}
+	if base := baseLanguage(lang); base != defaultLanguage {
+		layers = append(layers, base)
+	}
+	if lang != layers[len(layers)-1] {
+		layers = append(layers, lang)
+	}
+
+	merged := map[string]string{}
+	for _, layer := range layers {
+		for key, value := range i18nBundles[layer] {
+			merged[key] = value
+		}
+		overrides, err := readI18nOverrides(layer)
+		if errors.Is(err, badger.ErrKeyNotFound) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		for key, value := range overrides {
+			merged[key] = value
+		}
+	}
+	return merged, nil
+}
+
+// i18nHandler handles GET /api/i18n (language from Accept-Language) and
+// GET /api/i18n/{lang}
+func i18nHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	if r.Method != http.MethodGet {
+		http.Error(w, `{"error":"method not allowed"}`, http.StatusMethodNotAllowed)
+		return
+	}
+
+	available, err := availableLanguages()
+	if err != nil {
+		logHandlerError(r.Context(), "i18n", "database", "failed to list languages", "error", err)
+		http.Error(w, `{"error":"database error"}`, http.StatusInternalServerError)
+		return
+	}
+
+	var lang string
+	requested := strings.ToLower(strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/i18n"), "/"))
+	if requested == "" {
+		lang = negotiateLanguage(r.Header.Get("Accept-Language"), available)
+		// The answer depends on the header, so caches must key on it
+		w.Header().Set("Vary", "Accept-Language")
+	} else {
+		var ok bool
+		if lang, ok = matchLanguage(requested, available); !ok {
+			writeJSONError(w, http.StatusNotFound, fmt.Sprintf("no translations for %q (available: %s)", requested, strings.Join(available, ", ")))
+			return
+		}
+	}
+
+	merged, err := translations(lang)
+	if err != nil {
+		logHandlerError(r.Context(), "i18n", "database", "failed to read translation overrides", "error", err, "lang", lang)
+		http.Error(w, `{"error":"database error"}`, http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Language", lang)
+	json.NewEncoder(w).Encode(i18nResponse{Lang: lang, Languages: available, Strings: merged})
+}
+
+// i18nAdminHandler handles /api/admin/i18n/{lang} (GET, PUT, DELETE)
+func i18nAdminHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	lang := strings.ToLower(strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/admin/i18n"), "/"))
+	if !languageTagPattern.MatchString(lang) {
+		http.Error(w, `{"error":"expected /api/admin/i18n/{lang}, e.g. /api/admin/i18n/es"}`, http.StatusBadRequest)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		getI18nOverrides(w, r, lang)
+	case http.MethodPut:
+		putI18nOverrides(w, r, lang)
+	case http.MethodDelete:
+		deleteI18nOverrides(w, r, lang)
+	default:
+		http.Error(w, `{"error":"method not allowed"}`, http.StatusMethodNotAllowed)
+	}
+}
+
+// getI18nOverrides returns a language's overrides as uploaded
+func getI18nOverrides(w http.ResponseWriter, r *http.Request, lang string) {
+	overrides, err := readI18nOverrides(lang)
+	if errors.Is(err, badger.ErrKeyNotFound) {
+		writeJSONError(w, http.StatusNotFound, fmt.Sprintf("no overrides for %q", lang))
+		return
+	}
+	if err != nil {
+		logHandlerError(r.Context(), "i18n_admin", "database", "failed to read translation overrides", "error", err, "lang", lang)
+		http.Error(w, `{"error":"database error"}`, http.StatusInternalServerError)
+		return
+	}
+	json.NewEncoder(w).Encode(overrides)
+}
+
+// putI18nOverrides replaces a language's overrides. Keys must be ones the
+// English bundle has, so a typo is an error instead of a string nobody sees.
+func putI18nOverrides(w http.ResponseWriter, r *http.Request, lang string) {
+	var overrides map[string]string
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxI18nOverrideBytes)).Decode(&overrides); err != nil {
+		writeJSONError(w, http.StatusBadRequest, "expected a JSON object of strings: "+err.Error())
+		return
+	}
+	if len(overrides) == 0 {
+		http.Error(w, `{"error":"no strings given (DELETE removes the overrides)"}`, http.StatusBadRequest)
+		return
+	}
+	var errs []FieldError
+	for key := range overrides {
+		if _, ok := i18nBundles[defaultLanguage][key]; !ok {
+			errs = append(errs, FieldError{Field: key, Message: "not a dashboard string"})
+		}
+	}
+	if len(errs) > 0 {
+		sort.Slice(errs, func(i, j int) bool { return errs[i].Field < errs[j].Field })
+		writeValidationErrors(w, errs)
+		return
+	}
+
+	value, err := json.Marshal(overrides)
+	if err != nil {
+		logHandlerError(r.Context(), "i18n_admin", "encode", "failed to encode translation overrides", "error", err)
+		http.Error(w, `{"error":"internal error"}`, http.StatusInternalServerError)
+		return
+	}
+	key := i18nKeyPrefix + lang
+	err = dbUpdate("i18n_put", key, func(txn *badger.Txn) error {
+		return txn.Set([]byte(key), value)
+	})
+	if err != nil {
+		logHandlerError(r.Context(), "i18n_admin", "database", "failed to store translation overrides", "error", err, "lang", lang)
+		http.Error(w, `{"error":"database error"}`, http.StatusInternalServerError)
+		return
+	}
+
+	slog.InfoContext(r.Context(), "translation overrides updated", "lang", lang, "strings", len(overrides))
+	json.NewEncoder(w).Encode(map[string]any{"lang": lang, "strings": len(overrides)})
+}
+
+// deleteI18nOverrides removes a language's overrides; an embedded language
+// goes back to its bundle, an added one disappears
+func deleteI18nOverrides(w http.ResponseWriter, r *http.Request, lang string) {
+	key := i18nKeyPrefix + lang
+	err := dbUpdate("i18n_delete", key, func(txn *badger.Txn) error {
+		if _, err := txn.Get([]byte(key)); err != nil {
+			return err
+		}
+		return txn.Delete([]byte(key))
+	})
+	if errors.Is(err, badger.ErrKeyNotFound) {
+		writeJSONError(w, http.StatusNotFound, fmt.Sprintf("no overrides for %q", lang))
+		return
+	}
+	if err != nil {
+		logHandlerError(r.Context(), "i18n_admin", "database", "failed to delete translation overrides", "error", err, "lang", lang)
+		http.Error(w, `{"error":"database error"}`, http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
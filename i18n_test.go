@@ -0,0 +1,142 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// Every embedded bundle has exactly the English keys, and every key the
+// dashboard asks for exists
+func TestI18n_BundlesComplete(t *testing.T) {
+	english := i18nBundles[defaultLanguage]
+	for lang, bundle := range i18nBundles {
+		for key := range english {
+			if bundle[key] == "" {
+				t.Errorf("%s.json is missing %q", lang, key)
+			}
+		}
+		for key := range bundle {
+			if _, ok := english[key]; !ok {
+				t.Errorf("%s.json has %q, which en.json doesn't", lang, key)
+			}
+		}
+	}
+
+	var used []string
+	for _, file := range []string{"static/app.js", "static/index.html"} {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, m := range regexp.MustCompile(`(?:t\('|data-i18n=")([a-z_]+\.[a-z_]+)`).FindAllStringSubmatch(string(data), -1) {
+			used = append(used, m[1])
+		}
+	}
+	if len(used) == 0 {
+		t.Fatal("found no translated strings in the dashboard")
+	}
+	for _, key := range used {
+		if _, ok := english[key]; !ok {
+			t.Errorf("dashboard uses %q, which en.json doesn't have", key)
+		}
+	}
+}
+
+func TestNegotiateLanguage(t *testing.T) {
+	available := []string{"de", "en", "es", "fr", "ja"}
+	tests := map[string]string{
+		"":                           "en",
+		"es":                         "es",
+		"es-MX,es;q=0.9":             "es",
+		"nl,fr;q=0.8,de;q=0.9":       "de",
+		"nl":                         "en",
+		"*":                          "en",
+		"ja;q=0, fr":                 "fr",
+		"en-US,en;q=0.9,ja;q=0.8":    "en",
+		"de;q=0.5, es;q=0.5, fr;q=x": "de",
+	}
+	for header, want := range tests {
+		if got := negotiateLanguage(header, available); got != want {
+			t.Errorf("negotiateLanguage(%q) = %q, want %q", header, got, want)
+		}
+	}
+}
+
+func TestI18nHandler(t *testing.T) {
+	srv := newTestServer(t)
+
+	req, _ := http.NewRequest(http.MethodGet, srv.URL+"/api/i18n", nil)
+	req.Header.Set("Accept-Language", "fr-CA,fr;q=0.9")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got i18nResponse
+	json.NewDecoder(resp.Body).Decode(&got)
+	resp.Body.Close()
+	if got.Lang != "fr" || got.Strings["panel.items"] != "Éléments" {
+		t.Errorf("Accept-Language fr-CA = %+v", got)
+	}
+	if resp.Header.Get("Content-Language") != "fr" || resp.Header.Get("Vary") != "Accept-Language" {
+		t.Errorf("headers = %v", resp.Header)
+	}
+
+	code, body := doRequest(t, srv, http.MethodGet, "/api/i18n/es-MX", "")
+	got = i18nResponse{}
+	json.Unmarshal(body, &got)
+	if code != http.StatusOK || got.Lang != "es" || got.Strings["button.save"] != "Guardar" {
+		t.Errorf("GET /api/i18n/es-MX = %d %s", code, body)
+	}
+
+	if code, body := doRequest(t, srv, http.MethodGet, "/api/i18n/nl", ""); code != http.StatusNotFound {
+		t.Errorf("GET /api/i18n/nl = %d %s, want 404", code, body)
+	}
+}
+
+func TestI18nOverrides(t *testing.T) {
+	srv := newTestServer(t)
+
+	// Fix one Spanish string
+	if code, body := doRequest(t, srv, http.MethodPut, "/api/admin/i18n/es", `{"panel.items":"Productos"}`); code != http.StatusOK {
+		t.Fatalf("PUT es = %d %s", code, body)
+	}
+	_, body := doRequest(t, srv, http.MethodGet, "/api/i18n/es", "")
+	var got i18nResponse
+	json.Unmarshal(body, &got)
+	if got.Strings["panel.items"] != "Productos" || got.Strings["button.save"] != "Guardar" {
+		t.Errorf("es with an override = %v", got.Strings)
+	}
+
+	// Add a language: what isn't translated shows in English
+	doRequest(t, srv, http.MethodPut, "/api/admin/i18n/nl", `{"panel.items":"Artikelen"}`)
+	_, body = doRequest(t, srv, http.MethodGet, "/api/i18n/nl", "")
+	got = i18nResponse{}
+	json.Unmarshal(body, &got)
+	if got.Lang != "nl" || got.Strings["panel.items"] != "Artikelen" || got.Strings["button.save"] != "Save" {
+		t.Errorf("added nl = %+v", got)
+	}
+	if !sort.StringsAreSorted(got.Languages) || !strings.Contains(strings.Join(got.Languages, ","), "nl") {
+		t.Errorf("languages = %v, want nl listed", got.Languages)
+	}
+
+	// Unknown keys are refused, one error each
+	code, body := doRequest(t, srv, http.MethodPut, "/api/admin/i18n/es", `{"panel.itemz":"x","nope":"y"}`)
+	if code != http.StatusUnprocessableEntity || !strings.Contains(string(body), `"field":"nope"`) {
+		t.Errorf("unknown keys = %d %s, want 422", code, body)
+	}
+	if code, _ := doRequest(t, srv, http.MethodPut, "/api/admin/i18n/not%20a%20tag", `{}`); code != http.StatusBadRequest {
+		t.Errorf("bad language tag = %d, want 400", code)
+	}
+
+	if code, _ := doRequest(t, srv, http.MethodDelete, "/api/admin/i18n/nl", ""); code != http.StatusNoContent {
+		t.Errorf("DELETE nl = %d, want 204", code)
+	}
+	if code, _ := doRequest(t, srv, http.MethodGet, "/api/i18n/nl", ""); code != http.StatusNotFound {
+		t.Errorf("nl after DELETE = %d, want 404", code)
+	}
+}
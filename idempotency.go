@@ -0,0 +1,85 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"time"
+
+	badger "github.com/dgraph-io/badger/v4"
+)
+
+// =============================================================================
+// Idempotency Keys
+// =============================================================================
+//
+// A client that POSTs an item and never sees the response (timeout, dropped
+// connection) can't know whether the item was created. Retrying blindly
+// creates duplicates.
+//
+// With an Idempotency-Key header, the first request stores
+// "idem:<key>" -> {hash of the request, created item}. A retry with the same
+// key gets the original item back instead of creating a new one.
+//
+// The records expire after IDEMPOTENCY_TTL using BadgerDB's built-in TTL —
+// expired keys simply stop being returned by Get, no cleanup job needed.
+
+// Key prefix for idempotency records
+const idempotencyKeyPrefix = "idem:"
+
+// Longest Idempotency-Key header value we accept
+const maxIdempotencyKeyLength = 255
+
+// idempotencyTTL is how long a key is remembered (IDEMPOTENCY_TTL, set in main)
+var idempotencyTTL = 24 * time.Hour
+
+// errIdempotencyMismatch means the key was reused with a different request body
+var errIdempotencyMismatch = errors.New("idempotency key reused with a different request")
+
+// idempotencyRecord is what we store under each key
+type idempotencyRecord struct {
+	RequestHash string `json:"request_hash"`
+	Item        Item   `json:"item"`
+}
+
+// hashItemInput fingerprints a request so we can tell a genuine retry
+// (same body) from a client bug (same key, different body)
+func hashItemInput(input itemInput) string {
+	data, _ := json.Marshal(input) // marshaling a plain struct can't fail
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// getIdempotencyRecord looks up a tenant's key inside a transaction.
+// Returns (nil, nil) when the key hasn't been seen (or has expired).
+func getIdempotencyRecord(txn *badger.Txn, k keyspace, key string) (*idempotencyRecord, error) {
+	entry, err := txn.Get(k.key(idempotencyKeyPrefix + key))
+	if errors.Is(err, badger.ErrKeyNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var record idempotencyRecord
+	err = entry.Value(func(val []byte) error {
+		return json.Unmarshal(val, &record)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &record, nil
+}
+
+// putIdempotencyRecord stores a key with the configured TTL
+func putIdempotencyRecord(txn *badger.Txn, k keyspace, key string, record idempotencyRecord) error {
+	value, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	// badger.NewEntry lets us attach options like a TTL to a write;
+	// txn.Set is shorthand for SetEntry(NewEntry(k, v)) with no options
+	entry := badger.NewEntry(k.key(idempotencyKeyPrefix+key), value).WithTTL(idempotencyTTL)
+	return txn.SetEntry(entry)
+}
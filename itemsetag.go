@@ -0,0 +1,124 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	badger "github.com/dgraph-io/badger/v4"
+)
+
+// =============================================================================
+// Conditional GET for the Item List
+// =============================================================================
+//
+// A dashboard polling GET /api/items downloads the whole list every time,
+// even when nothing changed. Now the list carries an ETag; send it back in
+// If-None-Match and an unchanged list costs a 304 with no body:
+//
+//	curl -si http://localhost:8080/api/items | grep -i etag
+//	ETag: "items-1287-5c1a9e3f"
+//	curl -si -H 'If-None-Match: "items-1287-5c1a9e3f"' http://localhost:8080/api/items
+//	HTTP/1.1 304 Not Modified
+//
+// The version in the ETag is the collection version: every transaction
+// that changes an item also writes the key below, and BadgerDB stamps each
+// write with its commit timestamp, which only goes up. So the key's version
+// moves on with every change and never repeats. The write is "blind" (the
+// key is never read inside the transaction), and BadgerDB only checks
+// conflicts on reads, so concurrent item writes don't conflict over it.
+//
+// The key replicates like the items, so followers in cluster mode serve the
+// leader's ETag. Enveloped responses (?envelope=true) carry per-request
+// metadata and get no ETag.
+
+// Written by every item change; only its BadgerDB version matters
+const itemsVersionKey = "version:items"
+
+// touchItemsVersion marks the items as changed by txn
+func touchItemsVersion(txn *badger.Txn) error {
+	return rootKeyspace.touchItemsVersion(txn)
+}
+
+// touchItemsVersion marks a tenant's items as changed (tenant.go)
+func (k keyspace) touchItemsVersion(txn *badger.Txn) error {
+	return txn.Set(k.key(itemsVersionKey), nil)
+}
+
+// bumpItemsVersion marks the items as changed, for changes made outside a
+// transaction (reset, restore)
+func bumpItemsVersion() error {
+	return dbUpdate("items_version", itemsVersionKey, touchItemsVersion)
+}
+
+// itemsVersion is the collection version: 0 until the items first change
+func itemsVersion() (uint64, error) {
+	return rootKeyspace.itemsVersion()
+}
+
+// itemsVersion is a tenant's collection version (tenant.go)
+func (k keyspace) itemsVersion() (uint64, error) {
+	var version uint64
+	key := k.key(itemsVersionKey)
+	err := dbView("items_version", string(key), func(txn *badger.Txn) error {
+		entry, err := txn.Get(key)
+		if errors.Is(err, badger.ErrKeyNotFound) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		version = entry.Version()
+		return nil
+	})
+	return version, err
+}
+
+// itemsETag is the ETag for the list at this version. The same list in
+// YAML, or filtered by ?meta., is a different representation, so the
+// format and query are hashed in.
+func itemsETag(version uint64, format string, rawQuery string) string {
+	sum := sha256.Sum256([]byte(format + "?" + rawQuery))
+	return fmt.Sprintf(`"items-%d-%s"`, version, hex.EncodeToString(sum[:4]))
+}
+
+// etagMatches reports whether an If-None-Match header lists etag (or is "*").
+// Weak validators (W/"...") match too, as RFC 9110 says for GET.
+func etagMatches(ifNoneMatch, etag string) bool {
+	for _, candidate := range strings.Split(ifNoneMatch, ",") {
+		candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+		if candidate == "*" || candidate == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// checkItemsNotModified sets the list's ETag and answers 304 when the client
+// already has it. It returns true when the response is done.
+func checkItemsNotModified(w http.ResponseWriter, r *http.Request, format string) bool {
+	if wantsEnvelope(r) {
+		return false
+	}
+	// Read before the list: if an item changes in between, the ETag is the
+	// older one and the next poll simply downloads again
+	version, err := itemStoreFrom(r.Context()).itemsVersion()
+	if err != nil {
+		logHandlerError(r.Context(), "items", "database", "failed to read items version", "error", err)
+		return false // serve the list without an ETag
+	}
+
+	etag := itemsETag(version, format, r.URL.RawQuery)
+	w.Header().Set("ETag", etag)
+	// Caches may store the list but must ask before reusing it
+	w.Header().Set("Cache-Control", "no-cache")
+	if etagMatches(r.Header.Get("If-None-Match"), etag) {
+		w.Header().Set("Vary", "Accept") // as writeResponse would have
+		w.WriteHeader(http.StatusNotModified)
+		return true
+	}
+	return false
+}
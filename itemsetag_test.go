@@ -0,0 +1,108 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+	"sync"
+	"testing"
+)
+
+// getItemsETag lists the items with If-None-Match and returns the status
+// and ETag
+func getItemsETag(t *testing.T, url, ifNoneMatch, accept string) (int, string) {
+	t.Helper()
+	req, _ := http.NewRequest(http.MethodGet, url, nil)
+	if ifNoneMatch != "" {
+		req.Header.Set("If-None-Match", ifNoneMatch)
+	}
+	if accept != "" {
+		req.Header.Set("Accept", accept)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	return resp.StatusCode, resp.Header.Get("ETag")
+}
+
+func TestItemsETag_NotModified(t *testing.T) {
+	srv := newTestServer(t)
+	item := createTestItem(t, srv, `{"name":"Widget"}`)
+
+	code, etag := getItemsETag(t, srv.URL+"/api/items", "", "")
+	if code != http.StatusOK || etag == "" {
+		t.Fatalf("GET = %d, ETag %q", code, etag)
+	}
+	if code, _ := getItemsETag(t, srv.URL+"/api/items", etag, ""); code != http.StatusNotModified {
+		t.Errorf("unchanged list with If-None-Match = %d, want 304", code)
+	}
+	if code, _ := getItemsETag(t, srv.URL+"/api/items", `"other", W/`+etag, ""); code != http.StatusNotModified {
+		t.Errorf("weak match in a list = %d, want 304", code)
+	}
+
+	// Another representation of the same list has another ETag
+	_, yamlETag := getItemsETag(t, srv.URL+"/api/items", "", "application/yaml")
+	_, filteredETag := getItemsETag(t, srv.URL+"/api/items?meta.env=prod", "", "")
+	if yamlETag == etag || filteredETag == etag {
+		t.Errorf("ETags for JSON %s, YAML %s, filtered %s should all differ", etag, yamlETag, filteredETag)
+	}
+
+	// Every kind of change moves it on
+	id := strconv.FormatInt(item.ID, 10)
+	changes := []struct {
+		name, method, path, body string
+	}{
+		{"create", http.MethodPost, "/api/items", `{"name":"Gadget"}`},
+		{"update", http.MethodPut, "/api/items/" + id, `{"name":"Widget 2"}`},
+		{"attach", http.MethodPut, "/api/items/" + id + "/attachment", "hello"},
+		{"detach", http.MethodDelete, "/api/items/" + id + "/attachment", ""},
+		{"delete", http.MethodDelete, "/api/items/" + id, ""},
+	}
+	for _, c := range changes {
+		if code, body := doRequest(t, srv, c.method, c.path, c.body); code >= 300 {
+			t.Fatalf("%s = %d %s", c.name, code, body)
+		}
+		code, next := getItemsETag(t, srv.URL+"/api/items", etag, "")
+		if code != http.StatusOK || next == etag {
+			t.Errorf("after %s: %d with ETag %s, want 200 and a new ETag", c.name, code, next)
+		}
+		etag = next
+	}
+
+	// Envelopes carry per-request metadata, so no ETag
+	if _, envelopeETag := getItemsETag(t, srv.URL+"/api/items?envelope=true", "", ""); envelopeETag != "" {
+		t.Errorf("enveloped list has ETag %s", envelopeETag)
+	}
+}
+
+func TestItemsETag_ConcurrentWritesDontConflict(t *testing.T) {
+	srv := newTestServer(t)
+
+	var wg sync.WaitGroup
+	codes := make([]int, 20)
+	for i := range codes {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			codes[i], _ = doRequestNoFatal(srv, http.MethodPost, "/api/items", `{"name":"item `+strconv.Itoa(i)+`"}`)
+		}()
+	}
+	wg.Wait()
+	for i, code := range codes {
+		if code != http.StatusCreated {
+			t.Errorf("create %d = %d, want 201", i, code)
+		}
+	}
+}
+
+func TestItemsETag_Reset(t *testing.T) {
+	newTestServer(t)
+	before, _ := itemsVersion()
+	if err := resetStore(); err != nil {
+		t.Fatal(err)
+	}
+	if after, _ := itemsVersion(); after <= before {
+		t.Errorf("items version after reset = %d, was %d", after, before)
+	}
+}
@@ -0,0 +1,183 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+
+	badger "github.com/dgraph-io/badger/v4"
+)
+
+// =============================================================================
+// Item Statistics
+// =============================================================================
+//
+// GET /api/items/stats summarizes the items for dashboard cards:
+//
+//	curl http://localhost:8080/api/items/stats
+//	{"count":1200,"storage_bytes":301544,"tags":{"sale":40,"new":12},"untagged":1148,
+//	 "categories":{"hardware/laptops":30},"uncategorized":1170,
+//	 "oldest_created_at":"2026-01-02T09:00:00Z","newest_created_at":"2026-03-04T17:12:09Z",
+//	 "avg_description_length":23.5,"version":1287,"computed_at":"...","cached":true,
+//	 "aggregates":{"pending_deltas":3,"folded_at":"...","reconciled_at":"...","last_drift":0}}
+//
+// Nothing here reads every item. The count, tags, and description lengths
+// are totals kept up to date by each write (aggregates.go); "aggregates"
+// says how fresh they are. Storage bytes and categories come from keys
+// alone (the category index, see categories.go), which BadgerDB iterates
+// without reading values, and the dates from the first and last item (IDs
+// go up with time). That part is cached against the collection version
+// (itemsetag.go), so a dashboard polling an idle store reads a few keys.
+
+// ItemStats is GET /api/items/stats
+type ItemStats struct {
+	Count                int64            `json:"count"`
+	StorageBytes         int64            `json:"storage_bytes"` // items and attachments, as stored
+	Tags                 map[string]int64 `json:"tags"`
+	Untagged             int64            `json:"untagged"`
+	Categories           map[string]int64 `json:"categories"`
+	Uncategorized        int64            `json:"uncategorized"`
+	OldestCreatedAt      *time.Time       `json:"oldest_created_at"` // null with no items
+	NewestCreatedAt      *time.Time       `json:"newest_created_at"`
+	AvgDescriptionLength float64          `json:"avg_description_length"`
+	Version              uint64           `json:"version"` // collection version the key-only part is for
+	ComputedAt           time.Time        `json:"computed_at"`
+	Cached               bool             `json:"cached"`
+	Aggregates           AggregateStatus  `json:"aggregates"`
+}
+
+// itemStatsEntry is one tenant's cached stats; db tells a cache from an
+// earlier database (a restore, or the next test) apart
+type itemStatsEntry struct {
+	db    *badger.DB
+	stats ItemStats
+}
+
+var itemStatsCache = struct {
+	sync.Mutex
+	entries map[keyspace]itemStatsEntry
+}{entries: map[keyspace]itemStatsEntry{}}
+
+// itemStats returns the tenant's stats: the maintained totals, plus the
+// key-only part, recomputed when the items changed since the cached copy
+func (k keyspace) itemStats() (ItemStats, error) {
+	totals, status, err := k.aggregates()
+	if err != nil {
+		return ItemStats{}, err
+	}
+	version, err := k.itemsVersion()
+	if err != nil {
+		return ItemStats{}, err
+	}
+
+	itemStatsCache.Lock()
+	entry, ok := itemStatsCache.entries[k]
+	itemStatsCache.Unlock()
+	stats := entry.stats
+	if ok && entry.db == db && stats.Version == version {
+		stats.Cached = true
+	} else {
+		if stats, err = k.scanItemKeys(); err != nil {
+			return ItemStats{}, err
+		}
+		stats.Version = version
+		itemStatsCache.Lock()
+		itemStatsCache.entries[k] = itemStatsEntry{db: db, stats: stats}
+		itemStatsCache.Unlock()
+	}
+
+	categorized := int64(0)
+	for _, n := range stats.Categories {
+		categorized += n
+	}
+	stats.Count = totals.Items
+	stats.Uncategorized = max(totals.Items-categorized, 0)
+	stats.Tags = totals.Tags
+	if stats.Tags == nil {
+		stats.Tags = map[string]int64{}
+	}
+	stats.Untagged = totals.Untagged
+	if totals.Items > 0 {
+		stats.AvgDescriptionLength = *roundTo(float64(totals.DescriptionChars)/float64(totals.Items), 1)
+	}
+	stats.Aggregates = status
+	return stats, nil
+}
+
+// scanItemKeys works out the key-only part of the stats in one transaction
+func (k keyspace) scanItemKeys() (ItemStats, error) {
+	stats := ItemStats{Categories: map[string]int64{}, ComputedAt: time.Now().UTC()}
+	itemPrefix := k.key(itemKeyPrefix)
+
+	err := dbView("item_stats", string(itemPrefix), func(txn *badger.Txn) error {
+		opts := badger.DefaultIteratorOptions
+		opts.PrefetchValues = false
+		keys := txn.NewIterator(opts)
+		defer keys.Close()
+
+		for _, prefix := range [][]byte{itemPrefix, k.key(attachmentKeyPrefix)} {
+			for keys.Seek(prefix); keys.ValidForPrefix(prefix); keys.Next() {
+				stats.StorageBytes += keys.Item().EstimatedSize()
+			}
+		}
+		prefix := k.key(categoryIndexPrefix)
+		for keys.Seek(prefix); keys.ValidForPrefix(prefix); keys.Next() {
+			if category, _, ok := parseCategoryIndexKey(keys.Item().KeyCopy(nil)[len(k.prefix()):]); ok {
+				stats.Categories[category]++
+			}
+		}
+
+		// Oldest and newest: the first and last item keys
+		var err error
+		if stats.OldestCreatedAt, err = createdAtOf(txn, itemPrefix, false); err != nil {
+			return err
+		}
+		stats.NewestCreatedAt, err = createdAtOf(txn, itemPrefix, true)
+		return err
+	})
+	return stats, err
+}
+
+// createdAtOf reads the creation time of the first (or, reversed, the last)
+// item under prefix; nil when there are none
+func createdAtOf(txn *badger.Txn, prefix []byte, reverse bool) (*time.Time, error) {
+	opts := badger.DefaultIteratorOptions
+	opts.PrefetchValues = false
+	opts.Reverse = reverse
+	it := txn.NewIterator(opts)
+	defer it.Close()
+
+	seek := prefix
+	if reverse {
+		seek = append(append([]byte{}, prefix...), 0xff) // just past the last item
+	}
+	it.Seek(seek)
+	if !it.ValidForPrefix(prefix) {
+		return nil, nil
+	}
+	var item Item
+	if err := it.Item().Value(func(val []byte) error { return decodeItem(val, &item) }); err != nil {
+		return nil, err
+	}
+	return &item.CreatedAt, nil
+}
+
+// itemsStatsHandler handles GET /api/items/stats
+func itemsStatsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, `{"error":"method not allowed"}`, http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+
+	stats, err := keyspaceFrom(r.Context()).itemStats()
+	if err != nil {
+		logHandlerError(r.Context(), "items", "database", "failed to compute item stats", "error", err)
+		http.Error(w, `{"error":"database error"}`, http.StatusInternalServerError)
+		return
+	}
+
+	json.NewEncoder(w).Encode(stats)
+}
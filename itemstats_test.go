@@ -0,0 +1,63 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"testing"
+)
+
+func TestItemsStats(t *testing.T) {
+	srv := newTestServer(t)
+
+	getStats := func() ItemStats {
+		t.Helper()
+		code, body := doRequest(t, srv, http.MethodGet, "/api/items/stats", "")
+		if code != http.StatusOK {
+			t.Fatalf("GET /api/items/stats = %d %s", code, body)
+		}
+		var stats ItemStats
+		if err := json.Unmarshal(body, &stats); err != nil {
+			t.Fatal(err)
+		}
+		return stats
+	}
+
+	empty := getStats()
+	if empty.Count != 0 || empty.OldestCreatedAt != nil || empty.AvgDescriptionLength != 0 {
+		t.Errorf("empty stats = %+v", empty)
+	}
+
+	first := createTestItem(t, srv, `{"name":"a","description":"abcd","tags":["sale","new"],"category":"hardware/laptops"}`)
+	createTestItem(t, srv, `{"name":"b","description":"ab","tags":["sale"]}`)
+	createTestItem(t, srv, `{"name":"c"}`)
+
+	stats := getStats()
+	if stats.Count != 3 || stats.Cached {
+		t.Fatalf("count = %d, cached = %v; want 3, false", stats.Count, stats.Cached)
+	}
+	if stats.Tags["sale"] != 2 || stats.Tags["new"] != 1 || stats.Untagged != 1 {
+		t.Errorf("tags = %v, untagged = %d", stats.Tags, stats.Untagged)
+	}
+	if stats.Categories["hardware/laptops"] != 1 || stats.Uncategorized != 2 {
+		t.Errorf("categories = %v, uncategorized = %d", stats.Categories, stats.Uncategorized)
+	}
+	if stats.AvgDescriptionLength != 2 {
+		t.Errorf("avg description length = %v, want 2", stats.AvgDescriptionLength)
+	}
+	if stats.OldestCreatedAt == nil || !stats.OldestCreatedAt.Equal(first.CreatedAt) || stats.NewestCreatedAt.Before(*stats.OldestCreatedAt) {
+		t.Errorf("oldest = %v, newest = %v; first item created %v", stats.OldestCreatedAt, stats.NewestCreatedAt, first.CreatedAt)
+	}
+	if stats.StorageBytes <= 0 {
+		t.Errorf("storage bytes = %d", stats.StorageBytes)
+	}
+
+	// Unchanged items come from the cache; a write invalidates it
+	if again := getStats(); !again.Cached || again.Version != stats.Version {
+		t.Errorf("second read cached = %v, version %d -> %d", again.Cached, stats.Version, again.Version)
+	}
+	doRequest(t, srv, http.MethodDelete, "/api/items/"+strconv.FormatInt(first.ID, 10), "")
+	if after := getStats(); after.Cached || after.Count != 2 || after.Tags["new"] != 0 {
+		t.Errorf("after delete = %+v", after)
+	}
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"context"
+	"time"
+
+	badger "github.com/dgraph-io/badger/v4"
+)
+
+// =============================================================================
+// Item Store
+// =============================================================================
+//
+// The items handlers (handlers.go) reach storage through an itemStore taken
+// from the request's context, not through the package-level database:
+//
+//	store := itemStoreFrom(r.Context())
+//	item, err := store.fetchItem(r.Context(), id)
+//
+// In the app that's always the request's tenant keyspace (tenant.go), so
+// BadgerDB. Tests can put another store in the context with withItemStore:
+// an in-memory fake (itemstore_test.go) touches no package-level state, so
+// handler tests using it can run with t.Parallel().
+//
+// Everything behind the interface behaves like the keyspace methods it was
+// taken from: a missing item is badger.ErrKeyNotFound, a stale version a
+// *versionConflictError, a reused Idempotency-Key with another body
+// errIdempotencyMismatch.
+
+// itemStore is what the items handlers need from storage
+type itemStore interface {
+	// scanItems calls fn with every item in ID order, skipping malformed
+	// ones, until fn returns an error or ctx ends
+	scanItems(ctx context.Context, fn func(Item) error) error
+	fetchItem(ctx context.Context, id int64) (Item, error)
+	insertItem(ctx context.Context, input itemInput, idemKey string, createdAt time.Time, limited bool) (item Item, replayed bool, err error)
+	// updateItem replaces an item's fields; expected, when set, must be its
+	// current version (itemversion.go)
+	updateItem(ctx context.Context, id int64, input itemInput, expected *int64) (Item, error)
+	removeItem(id int64) error
+	countItems() (int, error)
+	itemsVersion() (uint64, error) // itemsetag.go
+}
+
+var _ itemStore = keyspace{}
+
+type itemStoreKey struct{}
+
+// withItemStore returns a context whose requests use store for their items
+func withItemStore(ctx context.Context, store itemStore) context.Context {
+	return context.WithValue(ctx, itemStoreKey{}, store)
+}
+
+// itemStoreFrom is the request's item store: the one withItemStore set, or
+// the request's tenant keyspace
+func itemStoreFrom(ctx context.Context) itemStore {
+	if store, ok := ctx.Value(itemStoreKey{}).(itemStore); ok {
+		return store
+	}
+	return keyspaceFrom(ctx)
+}
+
+// scanItems walks the tenant's items in key (ID) order
+func (k keyspace) scanItems(ctx context.Context, fn func(Item) error) error {
+	prefix := k.key(itemKeyPrefix)
+	return dbViewContext(ctx, "item_list", string(prefix), func(txn *badger.Txn) error {
+		// The default options prefetch values: we want the items, not just keys
+		it := txn.NewIterator(badger.DefaultIteratorOptions)
+		defer it.Close()
+
+		// Seek to the first key with our prefix, then iterate while prefix matches
+		for it.Seek(prefix); it.ValidForPrefix(prefix); it.Next() {
+			// Past the deadline or the client left: stop reading (timeout.go)
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			var item Item
+			err := it.Item().Value(func(val []byte) error {
+				return decodeItem(val, &item)
+			})
+			if err != nil {
+				logHandlerError(ctx, "items", "decode", "failed to unmarshal item", "error", err)
+				continue // Skip malformed items, don't fail the whole list
+			}
+			if err := fn(item); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// fetchItem reads one of the tenant's items
+func (k keyspace) fetchItem(ctx context.Context, id int64) (Item, error) {
+	key := k.itemKey(id)
+	var item Item
+	err := dbViewContext(ctx, "item_get", string(key), func(txn *badger.Txn) error {
+		dbItem, err := txn.Get(key)
+		if err != nil {
+			return err // Will be badger.ErrKeyNotFound if not exists
+		}
+		return dbItem.Value(func(val []byte) error {
+			return decodeItem(val, &item)
+		})
+	})
+	return item, err
+}
+
+// updateItem is a read-modify-write, all in one transaction
+// (applyItemUpdate in store.go)
+func (k keyspace) updateItem(ctx context.Context, id int64, input itemInput, expected *int64) (Item, error) {
+	var item Item
+	err := dbUpdateContext(ctx, "item_update", string(k.itemKey(id)), func(txn *badger.Txn) error {
+		var err error
+		item, err = k.applyItemUpdate(txn, id, input, expected)
+		return err
+	})
+	if err != nil {
+		return item, err
+	}
+	mirrorItem(k, item) // shadow.go
+	return item, nil
+}
@@ -0,0 +1,247 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	badger "github.com/dgraph-io/badger/v4"
+)
+
+// memItemStore is an in-memory itemStore. It holds no package-level state,
+// so every test can have its own and run in parallel with the others.
+type memItemStore struct {
+	mu          sync.Mutex
+	items       map[int64]Item
+	nextID      int64
+	version     uint64                       // bumped on every write, like items:version
+	idempotency map[string]idempotencyRecord // Idempotency-Key -> first answer
+}
+
+var _ itemStore = (*memItemStore)(nil)
+
+func newMemItemStore() *memItemStore {
+	return &memItemStore{
+		items:       map[int64]Item{},
+		idempotency: map[string]idempotencyRecord{},
+	}
+}
+
+func (m *memItemStore) scanItems(ctx context.Context, fn func(Item) error) error {
+	m.mu.Lock()
+	items := make([]Item, 0, len(m.items))
+	for _, item := range m.items {
+		items = append(items, item)
+	}
+	m.mu.Unlock()
+
+	sort.Slice(items, func(i, j int) bool { return items[i].ID < items[j].ID })
+	for _, item := range items {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := fn(item); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (m *memItemStore) fetchItem(ctx context.Context, id int64) (Item, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	item, ok := m.items[id]
+	if !ok {
+		return Item{}, badger.ErrKeyNotFound
+	}
+	return item, nil
+}
+
+func (m *memItemStore) insertItem(ctx context.Context, input itemInput, idemKey string, createdAt time.Time, limited bool) (Item, bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if idemKey != "" {
+		if record, ok := m.idempotency[idemKey]; ok {
+			if record.RequestHash != hashItemInput(input) {
+				return Item{}, false, errIdempotencyMismatch
+			}
+			return record.Item, true, nil
+		}
+	}
+
+	m.nextID++
+	item := Item{
+		ID:          m.nextID,
+		Name:        input.Name,
+		Description: input.Description,
+		Tags:        input.Tags,
+		Category:    input.Category,
+		Metadata:    input.Metadata,
+		Version:     1,
+		CreatedAt:   createdAt,
+	}
+	m.items[item.ID] = item
+	m.version++
+	if idemKey != "" {
+		m.idempotency[idemKey] = idempotencyRecord{RequestHash: hashItemInput(input), Item: item}
+	}
+	return item, false, nil
+}
+
+func (m *memItemStore) updateItem(ctx context.Context, id int64, input itemInput, expected *int64) (Item, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	item, ok := m.items[id]
+	if !ok {
+		return Item{}, badger.ErrKeyNotFound
+	}
+	if expected != nil && *expected != item.Version {
+		return item, &versionConflictError{Expected: *expected, Current: item}
+	}
+	item.Name = input.Name
+	item.Description = input.Description
+	item.Tags = input.Tags
+	item.Category = input.Category
+	item.Metadata = input.Metadata
+	item.Version++
+	m.items[id] = item
+	m.version++
+	return item, nil
+}
+
+func (m *memItemStore) removeItem(id int64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.items[id]; !ok {
+		return badger.ErrKeyNotFound
+	}
+	delete(m.items, id)
+	m.version++
+	return nil
+}
+
+func (m *memItemStore) countItems() (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return len(m.items), nil
+}
+
+func (m *memItemStore) itemsVersion() (uint64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.version, nil
+}
+
+// newFakeItemServer serves the items API (just itemsHandler and
+// itemsCountHandler, no middleware) on a fresh memItemStore. Unlike
+// newTestServer it swaps nothing package-level, so tests using it can call
+// t.Parallel().
+func newFakeItemServer(t testing.TB) *httptest.Server {
+	t.Helper()
+
+	store := newMemItemStore()
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/items", itemsHandler)
+	mux.HandleFunc("/api/items/", itemsHandler)
+	mux.HandleFunc("/api/items/count", itemsCountHandler)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mux.ServeHTTP(w, r.WithContext(withItemStore(r.Context(), store)))
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+// TestItemStore_Contract runs the same operations against the BadgerDB
+// keyspace and the fake, so the fake can't drift from what the handler
+// tests are meant to check
+func TestItemStore_Contract(t *testing.T) {
+	stores := map[string]func(t *testing.T) itemStore{
+		"keyspace": func(t *testing.T) itemStore {
+			newTestServer(t) // fresh in-memory BadgerDB
+			return rootKeyspace
+		},
+		"fake": func(t *testing.T) itemStore { return newMemItemStore() },
+	}
+	for name, open := range stores {
+		t.Run(name, func(t *testing.T) {
+			store := open(t)
+			ctx := context.Background()
+			now := time.Now().UTC()
+
+			first, _, err := store.insertItem(ctx, itemInput{Name: "First"}, "", now, true)
+			if err != nil {
+				t.Fatalf("insert: %v", err)
+			}
+			second, _, err := store.insertItem(ctx, itemInput{Name: "Second"}, "retry-1", now, true)
+			if err != nil {
+				t.Fatalf("insert: %v", err)
+			}
+			if first.Version != 1 || second.ID <= first.ID {
+				t.Errorf("inserted %+v then %+v, want version 1 and rising IDs", first, second)
+			}
+
+			// Idempotency-Key: same body replays, another body is refused
+			replay, replayed, err := store.insertItem(ctx, itemInput{Name: "Second"}, "retry-1", now, true)
+			if err != nil || !replayed || replay.ID != second.ID {
+				t.Errorf("replay = %+v, %v, %v; want item %d replayed", replay, replayed, err, second.ID)
+			}
+			if _, _, err := store.insertItem(ctx, itemInput{Name: "Other"}, "retry-1", now, true); !errors.Is(err, errIdempotencyMismatch) {
+				t.Errorf("reused key with another body: err = %v", err)
+			}
+
+			before, err := store.itemsVersion()
+			if err != nil {
+				t.Fatalf("itemsVersion: %v", err)
+			}
+			stale := int64(0)
+			var conflict *versionConflictError
+			if _, err := store.updateItem(ctx, first.ID, itemInput{Name: "Stale"}, &stale); !errors.As(err, &conflict) || conflict.Current.Version != 1 {
+				t.Errorf("stale update: err = %v", err)
+			}
+			current := int64(1)
+			updated, err := store.updateItem(ctx, first.ID, itemInput{Name: "Renamed"}, &current)
+			if err != nil || updated.Name != "Renamed" || updated.Version != 2 {
+				t.Errorf("update = %+v, %v", updated, err)
+			}
+			if after, _ := store.itemsVersion(); after == before {
+				t.Errorf("items version stayed %d after an update", after)
+			}
+
+			if err := store.removeItem(second.ID); err != nil {
+				t.Fatalf("remove: %v", err)
+			}
+			for _, err := range []error{
+				store.removeItem(second.ID),
+				func() error { _, err := store.fetchItem(ctx, second.ID); return err }(),
+				func() error { _, err := store.updateItem(ctx, second.ID, itemInput{Name: "x"}, nil); return err }(),
+			} {
+				if err != badger.ErrKeyNotFound {
+					t.Errorf("missing item: err = %v, want badger.ErrKeyNotFound", err)
+				}
+			}
+
+			fetched, err := store.fetchItem(ctx, first.ID)
+			if err != nil || fetched.Name != "Renamed" {
+				t.Errorf("fetch = %+v, %v", fetched, err)
+			}
+			if count, err := store.countItems(); err != nil || count != 1 {
+				t.Errorf("count = %d, %v; want 1", count, err)
+			}
+			var names []string
+			store.scanItems(ctx, func(i Item) error {
+				names = append(names, i.Name)
+				return nil
+			})
+			if len(names) != 1 || names[0] != "Renamed" {
+				t.Errorf("scan = %v", names)
+			}
+		})
+	}
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// =============================================================================
+// Optimistic Concurrency for Items
+// =============================================================================
+//
+// Two people edit the same item: both load version 3, both save. Without a
+// check the second save silently overwrites the first (a "lost update").
+// Every item now has a version, 1 when created and one higher after every
+// change, and GET /api/items/:id returns it as an ETag too:
+//
+//	curl -si http://localhost:8080/api/items/7 | grep -i etag
+//	ETag: "item-7-v3"
+//
+// A PUT that says which version it edited only succeeds if that's still
+// the current one, either as If-Match or as "version" in the body:
+//
+//	curl -X PUT -H 'If-Match: "item-7-v3"' -d '{"name":"Mine"}' localhost:8080/api/items/7
+//	curl -X PUT -d '{"name":"Mine","version":3}' localhost:8080/api/items/7
+//	# 409 {"error":"version conflict","expected_version":3,"current":{"id":7,"version":4,...}}
+//
+// The 409 carries the current item, so the client can merge and retry
+// without another GET. A PUT without a version overwrites whatever is there,
+// as before, unless REQUIRE_ITEM_VERSION=true (then it gets 428). Items
+// stored before versions existed are version 0 until their next change.
+
+// requireItemVersion is REQUIRE_ITEM_VERSION (set in main)
+var requireItemVersion bool
+
+// versionConflictError is returned by an update whose expected version
+// isn't the item's current one
+type versionConflictError struct {
+	Expected int64
+	Current  Item
+}
+
+func (e *versionConflictError) Error() string {
+	return fmt.Sprintf("version conflict: expected %d, item %d is at %d", e.Expected, e.Current.ID, e.Current.Version)
+}
+
+// itemETag is an item's ETag; it changes with every version
+func itemETag(item Item) string {
+	return fmt.Sprintf(`"item-%d-v%d"`, item.ID, item.Version)
+}
+
+// parseIfMatch reads the version from an If-Match header: an item ETag
+// ("item-7-v3", weak or not) or a bare version ("3"). "*" only asks that
+// the item exists, which every update checks anyway, so it means no version.
+func parseIfMatch(value string) (*int64, error) {
+	value = strings.TrimPrefix(strings.TrimSpace(value), "W/")
+	if value == "" || value == "*" {
+		return nil, nil
+	}
+	tag := strings.Trim(value, `"`)
+	if i := strings.LastIndex(tag, "-v"); i >= 0 && strings.HasPrefix(tag, "item-") {
+		tag = tag[i+2:]
+	}
+	version, err := strconv.ParseInt(tag, 10, 64)
+	if err != nil || version < 0 {
+		return nil, fmt.Errorf("If-Match %s is not an item ETag or version", value)
+	}
+	return &version, nil
+}
+
+// expectedItemVersion works out which version an update was made against,
+// from If-Match or the body's "version". It answers 400 (the two disagree)
+// or 428 (none given with REQUIRE_ITEM_VERSION) and returns false itself.
+func expectedItemVersion(w http.ResponseWriter, r *http.Request, input itemInput) (*int64, bool) {
+	header, err := parseIfMatch(r.Header.Get("If-Match"))
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, err.Error())
+		return nil, false
+	}
+	expected := input.Version
+	switch {
+	case header != nil && expected != nil && *header != *expected:
+		http.Error(w, `{"error":"If-Match and the body's version disagree"}`, http.StatusBadRequest)
+		return nil, false
+	case header != nil:
+		expected = header
+	case expected == nil && requireItemVersion:
+		http.Error(w, `{"error":"send the item's version (If-Match or \"version\")"}`, http.StatusPreconditionRequired)
+		return nil, false
+	}
+	return expected, true
+}
+
+// writeVersionConflict writes a 409 with the current item if err is a
+// version conflict. Returns true if it handled err.
+func writeVersionConflict(w http.ResponseWriter, r *http.Request, err error) bool {
+	var conflict *versionConflictError
+	if !errors.As(err, &conflict) {
+		return false
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("ETag", itemETag(conflict.Current))
+	w.WriteHeader(http.StatusConflict)
+	json.NewEncoder(w).Encode(map[string]any{
+		"error":            "version conflict",
+		"expected_version": conflict.Expected,
+		"current":          conflict.Current,
+	})
+	return true
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestParseIfMatch(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    int64 // -1 for no version
+		wantErr bool
+	}{
+		{"", -1, false},
+		{"*", -1, false},
+		{`"item-7-v3"`, 3, false},
+		{`W/"item-7-v3"`, 3, false},
+		{`"3"`, 3, false},
+		{"3", 3, false},
+		{`"items-1287-5c1a9e3f"`, 0, true},
+		{`"-1"`, 0, true},
+	}
+	for _, tt := range tests {
+		got, err := parseIfMatch(tt.value)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseIfMatch(%q) error = %v", tt.value, err)
+			continue
+		}
+		if tt.wantErr {
+			continue
+		}
+		if (got == nil) != (tt.want < 0) || (got != nil && *got != tt.want) {
+			t.Errorf("parseIfMatch(%q) = %v, want %d", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestItemVersion_OptimisticConcurrency(t *testing.T) {
+	srv := newTestServer(t)
+	item := createTestItem(t, srv, `{"name":"original"}`)
+	if item.Version != 1 {
+		t.Fatalf("new item version = %d, want 1", item.Version)
+	}
+	path := "/api/items/" + strconv.FormatInt(item.ID, 10)
+
+	put := func(ifMatch, body string) (int, http.Header, []byte) {
+		t.Helper()
+		req, _ := http.NewRequest(http.MethodPut, srv.URL+path, strings.NewReader(body))
+		if ifMatch != "" {
+			req.Header.Set("If-Match", ifMatch)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		var data json.RawMessage
+		json.NewDecoder(resp.Body).Decode(&data)
+		return resp.StatusCode, resp.Header, data
+	}
+
+	// The ETag from GET works as If-Match, once
+	resp, err := http.Get(srv.URL + path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	etag := resp.Header.Get("ETag")
+	if etag != `"item-`+strconv.FormatInt(item.ID, 10)+`-v1"` {
+		t.Fatalf("ETag = %q", etag)
+	}
+	code, header, body := put(etag, `{"name":"first edit"}`)
+	if code != http.StatusOK || header.Get("ETag") == etag {
+		t.Fatalf("first edit = %d %s (ETag %q)", code, body, header.Get("ETag"))
+	}
+
+	// A second edit of version 1 lost the race: 409 with the current item
+	code, _, body = put(etag, `{"name":"second edit"}`)
+	if code != http.StatusConflict {
+		t.Fatalf("stale edit = %d %s, want 409", code, body)
+	}
+	var conflict struct {
+		Expected int64 `json:"expected_version"`
+		Current  Item  `json:"current"`
+	}
+	json.Unmarshal(body, &conflict)
+	if conflict.Expected != 1 || conflict.Current.Version != 2 || conflict.Current.Name != "first edit" {
+		t.Errorf("conflict = %s", body)
+	}
+
+	// The body's version works too
+	if code, _, body := put("", `{"name":"third edit","version":2}`); code != http.StatusOK {
+		t.Errorf("edit with body version = %d %s", code, body)
+	}
+	if code, _, _ := put("", `{"name":"stale","version":2}`); code != http.StatusConflict {
+		t.Errorf("stale body version = %d, want 409", code)
+	}
+	if code, _, _ := put(`"3"`, `{"name":"x","version":2}`); code != http.StatusBadRequest {
+		t.Errorf("If-Match and body disagree = %d, want 400", code)
+	}
+
+	// No version at all still overwrites, unless REQUIRE_ITEM_VERSION
+	if code, _, body := put("", `{"name":"blind"}`); code != http.StatusOK {
+		t.Errorf("blind edit = %d %s", code, body)
+	}
+	requireItemVersion = true
+	t.Cleanup(func() { requireItemVersion = false })
+	if code, _, _ := put("", `{"name":"blind"}`); code != http.StatusPreconditionRequired {
+		t.Errorf("blind edit with REQUIRE_ITEM_VERSION = %d, want 428", code)
+	}
+}
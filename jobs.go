@@ -0,0 +1,528 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	badger "github.com/dgraph-io/badger/v4"
+)
+
+// =============================================================================
+// Async Job Subsystem
+// =============================================================================
+//
+// Some work is too slow to do inside an HTTP request (importing thousands of
+// items, taking a backup, generating load). The async task pattern:
+//
+//  1. POST /api/jobs          -> store the job as "queued", return 202 + job ID
+//  2. Worker goroutines       -> pick jobs off a channel and run them
+//  3. GET  /api/jobs/:id      -> poll status and progress
+//
+// Python equivalent: Celery or RQ, minus the separate broker — the "queue"
+// is a Go channel, and job state lives in BadgerDB so it survives restarts.
+//
+// Keys look like "job:1", "job:2", with IDs from the "seq:jobs" sequence.
+
+// Key prefix for jobs in BadgerDB
+const jobKeyPrefix = "job:"
+
+// Job statuses
+const (
+	jobQueued    = "queued"
+	jobRunning   = "running"
+	jobSucceeded = "succeeded"
+	jobFailed    = "failed"
+)
+
+// How many queued jobs can wait for a worker before POST /api/jobs returns 503
+const jobQueueSize = 100
+
+// How often a running job's progress is written to BadgerDB.
+// Writing on every single processed item would be wasteful.
+const jobProgressInterval = 250 * time.Millisecond
+
+// Job is a unit of background work
+type Job struct {
+	ID         int64           `json:"id"`
+	Type       string          `json:"type"`
+	Status     string          `json:"status"`
+	Params     json.RawMessage `json:"params,omitempty"`
+	Processed  int             `json:"processed"`
+	Total      int             `json:"total"`
+	Progress   int             `json:"progress"` // percent, 0-100
+	Result     any             `json:"result,omitempty"`
+	Error      string          `json:"error,omitempty"`
+	CreatedAt  time.Time       `json:"created_at"`
+	StartedAt  *time.Time      `json:"started_at,omitempty"`
+	FinishedAt *time.Time      `json:"finished_at,omitempty"`
+}
+
+// jobRunner does the work for one job type.
+// It calls progress(done, total) as it goes and returns a result to store.
+type jobRunner func(job *Job, progress func(done, total int)) (any, error)
+
+// jobTypes maps the "type" field of a job request to the function that runs it.
+// Adding a new job type is just adding an entry here.
+var jobTypes = map[string]jobRunner{
+	"bulk_import":     runBulkImportJob,
+	"backup":          runBackupJob,
+	"load_generation": runLoadGenerationJob,
+}
+
+// Package-level job state, set up by startJobWorkers
+var (
+	jobSeq   *badger.Sequence
+	jobQueue chan int64
+
+	// jobSelfURL is how load_generation reaches this server, e.g. "http://localhost:8080"
+	jobSelfURL string
+
+	// jobBackupDir is where backup jobs write their files
+	jobBackupDir string
+)
+
+// jobKey builds the BadgerDB key for a job
+func jobKey(id int64) []byte {
+	return []byte(fmt.Sprintf("%s%d", jobKeyPrefix, id))
+}
+
+// saveJob writes a job to BadgerDB
+func saveJob(job *Job) error {
+	value, err := json.Marshal(job)
+	if err != nil {
+		return err
+	}
+	return dbUpdate("job_save", string(jobKey(job.ID)), func(txn *badger.Txn) error {
+		return txn.Set(jobKey(job.ID), value)
+	})
+}
+
+// loadJob reads a job from BadgerDB (badger.ErrKeyNotFound if missing)
+func loadJob(id int64) (*Job, error) {
+	var job Job
+	err := dbView("job_get", string(jobKey(id)), func(txn *badger.Txn) error {
+		entry, err := txn.Get(jobKey(id))
+		if err != nil {
+			return err
+		}
+		return entry.Value(func(val []byte) error {
+			return json.Unmarshal(val, &job)
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
+
+// listJobs returns every stored job (in key order)
+func listJobs() ([]Job, error) {
+	jobs := []Job{}
+	err := dbView("job_list", jobKeyPrefix, func(txn *badger.Txn) error {
+		it := txn.NewIterator(badger.DefaultIteratorOptions)
+		defer it.Close()
+
+		prefix := []byte(jobKeyPrefix)
+		for it.Seek(prefix); it.ValidForPrefix(prefix); it.Next() {
+			err := it.Item().Value(func(val []byte) error {
+				var job Job
+				if err := json.Unmarshal(val, &job); err != nil {
+					return nil // skip malformed jobs
+				}
+				jobs = append(jobs, job)
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	return jobs, err
+}
+
+// startJobWorkers creates the job sequence, re-queues unfinished jobs from a
+// previous run, and launches the worker goroutines.
+func startJobWorkers(workers int) error {
+	var err error
+	jobSeq, err = db.GetSequence([]byte("seq:jobs"), 10)
+	if err != nil {
+		return err
+	}
+
+	jobQueue = make(chan int64, jobQueueSize)
+
+	// Jobs that were queued or running when the app stopped get another go.
+	// A "running" job was interrupted mid-way, so it starts again from scratch.
+	jobs, err := listJobs()
+	if err != nil {
+		return err
+	}
+	for _, job := range jobs {
+		if job.Status != jobQueued && job.Status != jobRunning {
+			continue
+		}
+		if len(jobQueue) == cap(jobQueue) {
+			slog.Warn("job queue full, not re-queuing job", "job_id", job.ID)
+			continue
+		}
+		job.Status = jobQueued
+		job.StartedAt = nil
+		if err := saveJob(&job); err != nil {
+			return err
+		}
+		jobQueue <- job.ID
+		slog.Info("re-queued unfinished job", "job_id", job.ID, "type", job.Type)
+	}
+
+	// Each worker is a goroutine looping over the channel.
+	// "for id := range jobQueue" blocks until a job arrives — no polling needed.
+	for i := 0; i < workers; i++ {
+		go func(worker int) {
+			for id := range jobQueue {
+				runJob(worker, id)
+			}
+		}(i)
+	}
+
+	slog.Info("job workers started", "workers", workers)
+	return nil
+}
+
+// runJob executes one job and records the outcome
+func runJob(worker int, id int64) {
+	job, err := loadJob(id)
+	if err != nil {
+		slog.Error("failed to load job", "job_id", id, "error", err)
+		return
+	}
+
+	runner, ok := jobTypes[job.Type]
+	if !ok {
+		finishJob(job, nil, fmt.Errorf("unknown job type %q", job.Type))
+		return
+	}
+
+	now := time.Now().UTC()
+	job.Status = jobRunning
+	job.StartedAt = &now
+	if err := saveJob(job); err != nil {
+		slog.Error("failed to save job", "job_id", id, "error", err)
+	}
+	slog.Info("job started", "job_id", id, "type", job.Type, "worker", worker)
+
+	// Progress callback: update the job in memory every time, but only
+	// persist it every jobProgressInterval to keep write volume sane.
+	// A mutex is needed because load_generation reports from many goroutines.
+	var mu sync.Mutex
+	lastSave := time.Now()
+	progress := func(done, total int) {
+		mu.Lock()
+		defer mu.Unlock()
+		job.Processed = done
+		job.Total = total
+		if total > 0 {
+			job.Progress = done * 100 / total
+		}
+		if time.Since(lastSave) >= jobProgressInterval {
+			lastSave = time.Now()
+			if err := saveJob(job); err != nil {
+				slog.Error("failed to save job progress", "job_id", id, "error", err)
+			}
+		}
+	}
+
+	result, runErr := runner(job, progress)
+
+	mu.Lock()
+	defer mu.Unlock()
+	finishJob(job, result, runErr)
+}
+
+// finishJob marks a job succeeded or failed and saves it
+func finishJob(job *Job, result any, err error) {
+	now := time.Now().UTC()
+	job.FinishedAt = &now
+	job.Result = result
+	if err != nil {
+		job.Status = jobFailed
+		job.Error = err.Error()
+	} else {
+		job.Status = jobSucceeded
+		job.Progress = 100
+	}
+
+	if saveErr := saveJob(job); saveErr != nil {
+		slog.Error("failed to save finished job", "job_id", job.ID, "error", saveErr)
+	}
+	slog.Info("job finished", "job_id", job.ID, "type", job.Type, "status", job.Status, "error", job.Error)
+}
+
+// =============================================================================
+// Job Types
+// =============================================================================
+
+// runBulkImportJob creates items from params: {"items": [{"name": ...}, ...]}
+// Invalid items are skipped and reported in the result instead of failing the job.
+func runBulkImportJob(job *Job, progress func(done, total int)) (any, error) {
+	var params struct {
+		Items []itemInput `json:"items"`
+	}
+	if err := json.Unmarshal(job.Params, &params); err != nil {
+		return nil, fmt.Errorf("invalid params: %w", err)
+	}
+
+	created := 0
+	var failures []string
+	for i, input := range params.Items {
+		if input.Name == "" {
+			failures = append(failures, fmt.Sprintf("item %d: name is required", i))
+		} else if errs := itemValidation.validate(input.Name, input.Description, input.Tags); len(errs) > 0 {
+			failures = append(failures, fmt.Sprintf("item %d: %s %s", i, errs[0].Field, errs[0].Message))
+		} else if _, _, err := insertItem(input, ""); err != nil {
+			failures = append(failures, fmt.Sprintf("item %d: %v", i, err))
+		} else {
+			created++
+		}
+		progress(i+1, len(params.Items))
+	}
+
+	return map[string]any{"created": created, "failed": len(failures), "errors": failures}, nil
+}
+
+// runBackupJob writes a full BadgerDB backup to a file in jobBackupDir
+func runBackupJob(job *Job, progress func(done, total int)) (any, error) {
+	if err := os.MkdirAll(jobBackupDir, 0o755); err != nil {
+		return nil, err
+	}
+
+	filename := filepath.Join(jobBackupDir,
+		fmt.Sprintf("demo-app-backup-%s-job%d.bak", time.Now().UTC().Format("20060102T150405Z"), job.ID))
+	f, err := os.Create(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	progress(0, 1)
+
+	// db.Backup streams every key/value (since version 0 = everything)
+	// in Badger's protobuf format. "badger restore" or db.Load can read it back.
+	if _, err := db.Backup(f, 0); err != nil {
+		return nil, err
+	}
+
+	info, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	progress(1, 1)
+	return map[string]any{"path": filename, "bytes": info.Size()}, nil
+}
+
+// runLoadGenerationJob sends HTTP requests to this same server, producing
+// real log lines and metrics. Params:
+//
+//	{"requests": 500, "concurrency": 10, "path": "/api/items"}
+func runLoadGenerationJob(job *Job, progress func(done, total int)) (any, error) {
+	params := struct {
+		Requests    int    `json:"requests"`
+		Concurrency int    `json:"concurrency"`
+		Path        string `json:"path"`
+	}{Requests: 100, Concurrency: 5, Path: "/health"}
+
+	if len(job.Params) > 0 {
+		if err := json.Unmarshal(job.Params, &params); err != nil {
+			return nil, fmt.Errorf("invalid params: %w", err)
+		}
+	}
+	if params.Requests < 1 || params.Requests > 100000 {
+		return nil, errors.New("requests must be between 1 and 100000")
+	}
+	if params.Concurrency < 1 || params.Concurrency > 100 {
+		return nil, errors.New("concurrency must be between 1 and 100")
+	}
+	if !strings.HasPrefix(params.Path, "/") {
+		return nil, errors.New("path must start with /")
+	}
+
+	client := newSelfClient(10 * time.Second)
+	req, err := http.NewRequest(http.MethodGet, jobSelfURL+params.Path, nil)
+	if err != nil {
+		return nil, fmt.Errorf("invalid path: %w", err)
+	}
+	setInternalKey(req) // past an access policy: the job's already admin-only
+
+	// Fan out: a channel of "tickets", one per request, consumed by
+	// params.Concurrency goroutines. sync.WaitGroup waits for them all.
+	tickets := make(chan struct{}, params.Requests)
+	for i := 0; i < params.Requests; i++ {
+		tickets <- struct{}{}
+	}
+	close(tickets)
+
+	var (
+		mu       sync.Mutex
+		done     int
+		statuses = map[string]int{}
+		wg       sync.WaitGroup
+	)
+	start := time.Now()
+
+	for i := 0; i < params.Concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range tickets {
+				status := "error"
+				resp, err := client.Do(req.Clone(req.Context()))
+				if err == nil {
+					status = strconv.Itoa(resp.StatusCode)
+					resp.Body.Close()
+				}
+
+				mu.Lock()
+				done++
+				statuses[status]++
+				current := done
+				mu.Unlock()
+
+				progress(current, params.Requests)
+			}
+		}()
+	}
+	wg.Wait()
+
+	elapsed := time.Since(start)
+	return map[string]any{
+		"requests":         params.Requests,
+		"statuses":         statuses,
+		"duration_ms":      elapsed.Milliseconds(),
+		"requests_per_sec": float64(params.Requests) / elapsed.Seconds(),
+	}, nil
+}
+
+// =============================================================================
+// Jobs Endpoints
+// =============================================================================
+
+// jobsHandler routes /api/jobs requests
+//
+//	GET  /api/jobs      -> list jobs
+//	POST /api/jobs      -> enqueue a job: {"type": "bulk_import", "params": {...}} (admin)
+//	GET  /api/jobs/:id  -> job status and progress
+func jobsHandler(w http.ResponseWriter, r *http.Request) {
+	path := strings.TrimPrefix(r.URL.Path, "/api/jobs")
+	path = strings.TrimPrefix(path, "/")
+
+	w.Header().Set("Content-Type", "application/json")
+
+	if path != "" {
+		if r.Method != http.MethodGet {
+			http.Error(w, `{"error":"method not allowed"}`, http.StatusMethodNotAllowed)
+			return
+		}
+		id, err := strconv.ParseInt(path, 10, 64)
+		if err != nil {
+			http.Error(w, `{"error":"invalid id"}`, http.StatusBadRequest)
+			return
+		}
+		getJob(w, r, id)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		jobs, err := listJobs()
+		if err != nil {
+			logHandlerError(r.Context(), "jobs", "database", "failed to list jobs", "error", err)
+			http.Error(w, `{"error":"database error"}`, http.StatusInternalServerError)
+			return
+		}
+		json.NewEncoder(w).Encode(jobs)
+	case http.MethodPost:
+		// Jobs dump the database or send thousands of requests, so starting
+		// one needs ADMIN_TOKEN like the admin API; watching them doesn't
+		adminMiddleware(createJob)(w, r)
+	default:
+		http.Error(w, `{"error":"method not allowed"}`, http.StatusMethodNotAllowed)
+	}
+}
+
+// getJob returns one job by ID
+func getJob(w http.ResponseWriter, r *http.Request, id int64) {
+	job, err := loadJob(id)
+	if errors.Is(err, badger.ErrKeyNotFound) {
+		http.Error(w, `{"error":"not found"}`, http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		logHandlerError(r.Context(), "jobs", "database", "failed to fetch job", "error", err)
+		http.Error(w, `{"error":"database error"}`, http.StatusInternalServerError)
+		return
+	}
+	json.NewEncoder(w).Encode(job)
+}
+
+// createJob stores a new job and hands it to the workers
+func createJob(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		Type   string          `json:"type"`
+		Params json.RawMessage `json:"params"`
+	}
+	if !decodeJSONBody(w, r, &input) { // strictjson.go
+		return
+	}
+	if _, ok := jobTypes[input.Type]; !ok {
+		writeJSONError(w, http.StatusBadRequest, fmt.Sprintf("unknown job type %q", input.Type))
+		return
+	}
+
+	id, err := jobSeq.Next()
+	if err != nil {
+		logHandlerError(r.Context(), "jobs", "database", "failed to get next job ID", "error", err)
+		http.Error(w, `{"error":"database error"}`, http.StatusInternalServerError)
+		return
+	}
+
+	job := &Job{
+		ID:        int64(id),
+		Type:      input.Type,
+		Status:    jobQueued,
+		Params:    input.Params,
+		CreatedAt: time.Now().UTC(),
+	}
+	if err := saveJob(job); err != nil {
+		logHandlerError(r.Context(), "jobs", "database", "failed to save job", "error", err)
+		http.Error(w, `{"error":"database error"}`, http.StatusInternalServerError)
+		return
+	}
+
+	// select with a default case is a non-blocking send: if the channel
+	// buffer is full we fail fast instead of hanging the request
+	select {
+	case jobQueue <- job.ID:
+	default:
+		dbUpdate("job_delete", string(jobKey(job.ID)), func(txn *badger.Txn) error {
+			return txn.Delete(jobKey(job.ID))
+		})
+		w.Header().Set("Retry-After", "5")
+		http.Error(w, `{"error":"job queue full"}`, http.StatusServiceUnavailable)
+		return
+	}
+
+	// 202 Accepted: "got it, working on it" — the standard async response.
+	// Location tells the client where to poll.
+	w.Header().Set("Location", fmt.Sprintf("/api/jobs/%d", job.ID))
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(job)
+}
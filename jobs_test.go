@@ -0,0 +1,110 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// waitForJob polls GET /api/jobs/:id until the job finishes or the timeout hits
+func waitForJob(t *testing.T, id int64) Job {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		req := httptest.NewRequest("GET", fmt.Sprintf("/api/jobs/%d", id), nil)
+		rr := httptest.NewRecorder()
+		jobsHandler(rr, req)
+
+		var job Job
+		json.Unmarshal(rr.Body.Bytes(), &job)
+		if job.Status == jobSucceeded || job.Status == jobFailed {
+			return job
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	t.Fatalf("job %d did not finish in time", id)
+	return Job{}
+}
+
+func TestJobs_BulkImport(t *testing.T) {
+	body := bytes.NewBufferString(`{"type":"bulk_import","params":{"items":[{"name":"Job A"},{"name":"Job B"},{"description":"no name"}]}}`)
+	req := httptest.NewRequest("POST", "/api/jobs", body)
+	rr := httptest.NewRecorder()
+	jobsHandler(rr, req)
+
+	if rr.Code != http.StatusAccepted {
+		t.Fatalf("expected status 202, got %d: %s", rr.Code, rr.Body.String())
+	}
+
+	var queued Job
+	json.Unmarshal(rr.Body.Bytes(), &queued)
+
+	job := waitForJob(t, queued.ID)
+	if job.Status != jobSucceeded {
+		t.Fatalf("expected job to succeed, got %s: %s", job.Status, job.Error)
+	}
+
+	result := job.Result.(map[string]any)
+	if result["created"] != float64(2) || result["failed"] != float64(1) {
+		t.Errorf("expected 2 created and 1 failed, got %v", result)
+	}
+	if job.Progress != 100 {
+		t.Errorf("expected progress 100, got %d", job.Progress)
+	}
+}
+
+func TestJobs_Backup(t *testing.T) {
+	req := httptest.NewRequest("POST", "/api/jobs", bytes.NewBufferString(`{"type":"backup"}`))
+	rr := httptest.NewRecorder()
+	jobsHandler(rr, req)
+
+	var queued Job
+	json.Unmarshal(rr.Body.Bytes(), &queued)
+
+	job := waitForJob(t, queued.ID)
+	if job.Status != jobSucceeded {
+		t.Fatalf("expected backup to succeed, got %s: %s", job.Status, job.Error)
+	}
+}
+
+func TestJobs_UnknownType(t *testing.T) {
+	req := httptest.NewRequest("POST", "/api/jobs", bytes.NewBufferString(`{"type":"nope"}`))
+	rr := httptest.NewRecorder()
+	jobsHandler(rr, req)
+
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("expected status 400, got %d", rr.Code)
+	}
+}
+
+func TestJobs_CreateNeedsAdminToken(t *testing.T) {
+	t.Setenv("ADMIN_TOKEN", "secret")
+	post := func(auth string) int {
+		// An unknown type: past the token check it's a 400, and no job runs
+		req := httptest.NewRequest("POST", "/api/jobs", bytes.NewBufferString(`{"type":"nope"}`))
+		if auth != "" {
+			req.Header.Set("Authorization", auth)
+		}
+		rr := httptest.NewRecorder()
+		jobsHandler(rr, req)
+		return rr.Code
+	}
+
+	if code := post(""); code != http.StatusUnauthorized {
+		t.Errorf("expected 401 without the token, got %d", code)
+	}
+	if code := post("Bearer secret"); code != http.StatusBadRequest {
+		t.Errorf("expected the token to get past the check, got %d", code)
+	}
+
+	// Anyone can still watch jobs
+	rr := httptest.NewRecorder()
+	jobsHandler(rr, httptest.NewRequest("GET", "/api/jobs", nil))
+	if rr.Code != http.StatusOK {
+		t.Errorf("expected GET to stay public, got %d", rr.Code)
+	}
+}
@@ -0,0 +1,366 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// =============================================================================
+// JSON Schema (subset)
+// =============================================================================
+//
+// A small JSON Schema validator — enough to describe the shape of a
+// Terraform output or a pipeline payload:
+//
+//	{
+//	  "type": "object",
+//	  "required": ["vpc_id", "subnets"],
+//	  "properties": {
+//	    "vpc_id":  {"type": "string", "pattern": "^vpc-"},
+//	    "subnets": {"type": "array", "minItems": 1, "items": {"type": "string"}},
+//	    "env":     {"enum": ["dev", "staging", "prod"]}
+//	  },
+//	  "additionalProperties": false
+//	}
+//
+// Supported keywords: type, enum, const, properties, required,
+// additionalProperties, items, minItems, maxItems, minLength, maxLength,
+// pattern, minimum, maximum, exclusiveMinimum, exclusiveMaximum.
+// Annotations ($schema, title, description, ...) are accepted and ignored.
+// Anything else ($ref, anyOf, ...) is rejected when the schema is compiled,
+// so a schema never silently checks less than it says.
+//
+// The full spec is much bigger; libraries exist, but this repo sticks to
+// the standard library and the subset covers what demos need.
+
+// jsonSchema is a compiled schema. nil fields mean "no constraint".
+type jsonSchema struct {
+	reject bool // the schema `false`: nothing matches
+
+	types      []string
+	enum       []any
+	constValue any
+	hasConst   bool
+
+	properties           map[string]*jsonSchema
+	required             []string
+	additionalProperties *jsonSchema // nil: anything goes
+
+	items              *jsonSchema
+	minItems, maxItems *int
+
+	minLength, maxLength *int
+	pattern              *regexp.Regexp
+
+	minimum, maximum                   *float64
+	exclusiveMinimum, exclusiveMaximum *float64
+}
+
+// Keywords that only describe the schema; they never affect validation
+var jsonSchemaAnnotations = map[string]bool{
+	"$schema": true, "$id": true, "$comment": true, "title": true,
+	"description": true, "default": true, "examples": true,
+}
+
+// Valid values for "type"
+var jsonSchemaTypes = map[string]bool{
+	"object": true, "array": true, "string": true, "number": true,
+	"integer": true, "boolean": true, "null": true,
+}
+
+// compileJSONSchema parses a schema document
+func compileJSONSchema(data []byte) (*jsonSchema, error) {
+	var raw any
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("schema is not valid JSON: %w", err)
+	}
+	return compileSchemaNode(raw, "$")
+}
+
+// compileSchemaNode compiles one (sub)schema; path is used in error messages
+func compileSchemaNode(raw any, path string) (*jsonSchema, error) {
+	// true and false are valid schemas: match everything / nothing
+	if b, ok := raw.(bool); ok {
+		return &jsonSchema{reject: !b}, nil
+	}
+	node, ok := raw.(map[string]any)
+	if !ok {
+		return nil, fmt.Errorf("%s: schema must be an object or a boolean", path)
+	}
+
+	s := &jsonSchema{}
+	// Sorted so the first error reported is always the same one
+	keywords := make([]string, 0, len(node))
+	for k := range node {
+		keywords = append(keywords, k)
+	}
+	sort.Strings(keywords)
+
+	for _, keyword := range keywords {
+		value := node[keyword]
+		var err error
+		switch keyword {
+		case "type":
+			s.types, err = schemaTypes(value)
+		case "enum":
+			list, ok := value.([]any)
+			if !ok {
+				err = fmt.Errorf("must be an array")
+				break
+			}
+			s.enum = list
+		case "const":
+			s.constValue, s.hasConst = value, true
+		case "properties":
+			props, ok := value.(map[string]any)
+			if !ok {
+				err = fmt.Errorf("must be an object")
+				break
+			}
+			s.properties = map[string]*jsonSchema{}
+			for name, sub := range props {
+				if s.properties[name], err = compileSchemaNode(sub, path+"."+name); err != nil {
+					return nil, err
+				}
+			}
+		case "required":
+			s.required, err = schemaStrings(value)
+		case "additionalProperties":
+			s.additionalProperties, err = compileSchemaNode(value, path+".additionalProperties")
+			if err != nil {
+				return nil, err
+			}
+		case "items":
+			if s.items, err = compileSchemaNode(value, path+"[]"); err != nil {
+				return nil, err
+			}
+		case "minItems":
+			s.minItems, err = schemaCount(value)
+		case "maxItems":
+			s.maxItems, err = schemaCount(value)
+		case "minLength":
+			s.minLength, err = schemaCount(value)
+		case "maxLength":
+			s.maxLength, err = schemaCount(value)
+		case "pattern":
+			text, ok := value.(string)
+			if !ok {
+				err = fmt.Errorf("must be a string")
+				break
+			}
+			s.pattern, err = regexp.Compile(text)
+		case "minimum":
+			s.minimum, err = schemaNumber(value)
+		case "maximum":
+			s.maximum, err = schemaNumber(value)
+		case "exclusiveMinimum":
+			s.exclusiveMinimum, err = schemaNumber(value)
+		case "exclusiveMaximum":
+			s.exclusiveMaximum, err = schemaNumber(value)
+		default:
+			if !jsonSchemaAnnotations[keyword] {
+				err = fmt.Errorf("keyword is not supported")
+			}
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s: %q %v", path, keyword, err)
+		}
+	}
+	return s, nil
+}
+
+// schemaTypes reads "type": a name or a list of names
+func schemaTypes(value any) ([]string, error) {
+	names := []string{}
+	if text, ok := value.(string); ok {
+		names = append(names, text)
+	} else {
+		var err error
+		if names, err = schemaStrings(value); err != nil {
+			return nil, err
+		}
+	}
+	for _, name := range names {
+		if !jsonSchemaTypes[name] {
+			return nil, fmt.Errorf("has unknown type %q", name)
+		}
+	}
+	return names, nil
+}
+
+func schemaStrings(value any) ([]string, error) {
+	list, ok := value.([]any)
+	if !ok {
+		return nil, fmt.Errorf("must be an array of strings")
+	}
+	out := make([]string, 0, len(list))
+	for _, v := range list {
+		text, ok := v.(string)
+		if !ok {
+			return nil, fmt.Errorf("must be an array of strings")
+		}
+		out = append(out, text)
+	}
+	return out, nil
+}
+
+func schemaCount(value any) (*int, error) {
+	n, ok := value.(float64)
+	if !ok || n < 0 || n != math.Trunc(n) {
+		return nil, fmt.Errorf("must be a non-negative integer")
+	}
+	count := int(n)
+	return &count, nil
+}
+
+func schemaNumber(value any) (*float64, error) {
+	n, ok := value.(float64)
+	if !ok {
+		return nil, fmt.Errorf("must be a number")
+	}
+	return &n, nil
+}
+
+// validate checks a decoded JSON value and returns every problem found,
+// each with the JSONPath-style location ("$.subnets[1]") as the field
+func (s *jsonSchema) validate(value any) []FieldError {
+	var errs []FieldError
+	s.check(value, "$", &errs)
+	return errs
+}
+
+func (s *jsonSchema) check(value any, path string, errs *[]FieldError) {
+	fail := func(format string, args ...any) {
+		*errs = append(*errs, FieldError{path, fmt.Sprintf(format, args...)})
+	}
+
+	if s.reject {
+		fail("is not allowed")
+		return
+	}
+	if len(s.types) > 0 && !jsonTypeMatches(value, s.types) {
+		fail("must be of type %s (got %s)", strings.Join(s.types, " or "), jsonTypeOf(value))
+		return // the other checks assume the right type
+	}
+	if s.hasConst && !reflect.DeepEqual(value, s.constValue) {
+		fail("must equal %s", jsonText(s.constValue))
+	}
+	if s.enum != nil && !containsJSONValue(s.enum, value) {
+		options := make([]string, len(s.enum))
+		for i, v := range s.enum {
+			options[i] = jsonText(v)
+		}
+		fail("must be one of %s", strings.Join(options, ", "))
+	}
+
+	switch v := value.(type) {
+	case map[string]any:
+		for _, name := range s.required {
+			if _, ok := v[name]; !ok {
+				*errs = append(*errs, FieldError{path + "." + name, "is required"})
+			}
+		}
+		names := make([]string, 0, len(v))
+		for name := range v {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			if sub, ok := s.properties[name]; ok {
+				sub.check(v[name], path+"."+name, errs)
+			} else if s.additionalProperties != nil {
+				s.additionalProperties.check(v[name], path+"."+name, errs)
+			}
+		}
+	case []any:
+		if s.minItems != nil && len(v) < *s.minItems {
+			fail("must have at least %d items", *s.minItems)
+		}
+		if s.maxItems != nil && len(v) > *s.maxItems {
+			fail("must have at most %d items", *s.maxItems)
+		}
+		if s.items != nil {
+			for i, item := range v {
+				s.items.check(item, fmt.Sprintf("%s[%d]", path, i), errs)
+			}
+		}
+	case string:
+		length := utf8.RuneCountInString(v)
+		if s.minLength != nil && length < *s.minLength {
+			fail("must be at least %d characters", *s.minLength)
+		}
+		if s.maxLength != nil && length > *s.maxLength {
+			fail("must be at most %d characters", *s.maxLength)
+		}
+		if s.pattern != nil && !s.pattern.MatchString(v) {
+			fail("must match pattern %s", s.pattern)
+		}
+	case float64:
+		if s.minimum != nil && v < *s.minimum {
+			fail("must be >= %v", *s.minimum)
+		}
+		if s.maximum != nil && v > *s.maximum {
+			fail("must be <= %v", *s.maximum)
+		}
+		if s.exclusiveMinimum != nil && v <= *s.exclusiveMinimum {
+			fail("must be > %v", *s.exclusiveMinimum)
+		}
+		if s.exclusiveMaximum != nil && v >= *s.exclusiveMaximum {
+			fail("must be < %v", *s.exclusiveMaximum)
+		}
+	}
+}
+
+// jsonTypeOf names the JSON type of a decoded value
+func jsonTypeOf(value any) string {
+	switch value.(type) {
+	case map[string]any:
+		return "object"
+	case []any:
+		return "array"
+	case string:
+		return "string"
+	case float64:
+		return "number"
+	case bool:
+		return "boolean"
+	default:
+		return "null"
+	}
+}
+
+// jsonTypeMatches reports whether value is one of types
+// ("integer" is a number without a fraction, and also counts as "number")
+func jsonTypeMatches(value any, types []string) bool {
+	actual := jsonTypeOf(value)
+	for _, t := range types {
+		if t == actual {
+			return true
+		}
+		if n, ok := value.(float64); ok && t == "integer" && n == math.Trunc(n) {
+			return true
+		}
+	}
+	return false
+}
+
+func containsJSONValue(list []any, value any) bool {
+	for _, v := range list {
+		if reflect.DeepEqual(v, value) {
+			return true
+		}
+	}
+	return false
+}
+
+// jsonText renders a value for an error message
+func jsonText(value any) string {
+	data, _ := json.Marshal(value)
+	return string(data)
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestCompileJSONSchema_Errors(t *testing.T) {
+	tests := []struct {
+		schema  string
+		wantErr string
+	}{
+		{`{`, "not valid JSON"},
+		{`[]`, "must be an object or a boolean"},
+		{`{"type":"thing"}`, `unknown type "thing"`},
+		{`{"anyOf":[]}`, `"anyOf" keyword is not supported`},
+		{`{"properties":{"a":{"$ref":"#/x"}}}`, `$.a: "$ref"`},
+		{`{"minLength":-1}`, "non-negative integer"},
+		{`{"pattern":"("}`, `"pattern"`},
+		{`{"required":"a"}`, "array of strings"},
+	}
+	for _, tt := range tests {
+		_, err := compileJSONSchema([]byte(tt.schema))
+		if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+			t.Errorf("compile %s: err = %v, want containing %q", tt.schema, err, tt.wantErr)
+		}
+	}
+
+	// Annotations are fine
+	if _, err := compileJSONSchema([]byte(`{"$schema":"https://json-schema.org/draft/2020-12/schema","title":"x","description":"y"}`)); err != nil {
+		t.Errorf("annotations rejected: %v", err)
+	}
+}
+
+func TestJSONSchema_Validate(t *testing.T) {
+	schema, err := compileJSONSchema([]byte(`{
+		"type": "object",
+		"required": ["vpc_id", "subnets"],
+		"properties": {
+			"vpc_id":   {"type": "string", "pattern": "^vpc-"},
+			"subnets":  {"type": "array", "minItems": 1, "maxItems": 3, "items": {"type": "string", "minLength": 3}},
+			"env":      {"enum": ["dev", "prod"]},
+			"replicas": {"type": "integer", "minimum": 1, "exclusiveMaximum": 10},
+			"version":  {"const": 2},
+			"owner":    {"type": ["string", "null"]}
+		},
+		"additionalProperties": false
+	}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		data string
+		want []string // "field: message" pairs
+	}{
+		{"valid", `{"vpc_id":"vpc-1","subnets":["a-1"],"env":"dev","replicas":3,"version":2,"owner":null}`, nil},
+		{"not an object", `[1]`, []string{"$: must be of type object (got array)"}},
+		{"missing required", `{}`, []string{"$.vpc_id: is required", "$.subnets: is required"}},
+		{"wrong types", `{"vpc_id":42,"subnets":"a"}`, []string{
+			"$.subnets: must be of type array (got string)",
+			"$.vpc_id: must be of type string (got number)",
+		}},
+		{"nested problems", `{"vpc_id":"subnet-1","subnets":["ok1","x"],"env":"test"}`, []string{
+			`$.env: must be one of "dev", "prod"`,
+			"$.subnets[1]: must be at least 3 characters",
+			"$.vpc_id: must match pattern ^vpc-",
+		}},
+		{"numbers", `{"vpc_id":"vpc-1","subnets":["abc"],"replicas":10.5,"version":3}`, []string{
+			"$.replicas: must be of type integer (got number)",
+			"$.version: must equal 2",
+		}},
+		{"bounds", `{"vpc_id":"vpc-1","subnets":["abc","def","ghi","jkl"],"replicas":10}`, []string{
+			"$.replicas: must be < 10",
+			"$.subnets: must have at most 3 items",
+		}},
+		{"extra property", `{"vpc_id":"vpc-1","subnets":["abc"],"extra":true}`, []string{"$.extra: is not allowed"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var value any
+			json.Unmarshal([]byte(tt.data), &value)
+			var got []string
+			for _, e := range schema.validate(value) {
+				got = append(got, e.Field+": "+e.Message)
+			}
+			if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+				t.Errorf("errors = %q\nwant %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,244 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// =============================================================================
+// Kafka Event Sink
+// =============================================================================
+//
+// Publishes events (events.go) to a Kafka topic through a REST proxy —
+// Confluent REST Proxy, or the HTTP proxy built into Redpanda. The Kafka wire
+// protocol is binary and large; the proxy's v2 API is one JSON POST:
+//
+//	POST /topics/demoapp-events
+//	Content-Type: application/vnd.kafka.json.v2+json
+//	{"records":[{"key":"item-1","value":{"id":"9f3c...","type":"item.created",...}}]}
+//
+// The record key is the item ("item-1") or "display", so every change to
+// one item lands in the same partition, in order.
+//
+//	EVENT_SINK=kafka KAFKA_REST_URL=http://rest-proxy:8082 ./demo-app
+//	kafka-console-consumer --bootstrap-server kafka:9092 --topic demoapp-events
+
+// Content type of the REST proxy's v2 JSON format
+const kafkaJSONContentType = "application/vnd.kafka.json.v2+json"
+
+// kafkaSink publishes to one topic through a REST proxy
+type kafkaSink struct {
+	endpoint string // <KAFKA_REST_URL>/topics/<topic>
+	client   *http.Client
+}
+
+// newKafkaSink checks the proxy URL; nothing is sent yet
+func newKafkaSink(restURL, topic string) (*kafkaSink, error) {
+	u, err := url.Parse(restURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("invalid KAFKA_REST_URL %q: want http(s)://host[:port]", restURL)
+	}
+	if topic == "" {
+		return nil, fmt.Errorf("KAFKA_TOPIC must not be empty")
+	}
+	return &kafkaSink{
+		endpoint: strings.TrimSuffix(restURL, "/") + "/topics/" + url.PathEscape(topic),
+		client:   newOutboundClient(10 * time.Second), // outbound.go
+	}, nil
+}
+
+func (k *kafkaSink) name() string { return "kafka" }
+
+// publish sends one event as one record
+func (k *kafkaSink) publish(event Event) error {
+	body, err := json.Marshal(map[string]any{
+		"records": []map[string]any{{"key": event.Key, "value": event}},
+	})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, k.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", kafkaJSONContentType)
+	req.Header.Set("Accept", "application/vnd.kafka.v2+json")
+	// The event ID makes a retry safe to dedupe, so the outbound client may retry it
+	req.Header.Set("Idempotency-Key", event.ID)
+
+	resp, err := k.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	// The proxy answers 200 with an offset per record; a record it couldn't
+	// write has an error instead
+	var result struct {
+		Offsets []struct {
+			ErrorCode *int   `json:"error_code"`
+			Error     string `json:"error"`
+		} `json:"offsets"`
+		Message string `json:"message"`
+	}
+	json.NewDecoder(io.LimitReader(resp.Body, 64<<10)).Decode(&result)
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("kafka rest proxy: %s %s", resp.Status, result.Message)
+	}
+	for _, offset := range result.Offsets {
+		if offset.ErrorCode != nil {
+			return fmt.Errorf("kafka rest proxy: record rejected: %s (code %d)", offset.Error, *offset.ErrorCode)
+		}
+	}
+	return nil
+}
+
+// =============================================================================
+// Kafka Queue Source
+// =============================================================================
+//
+// Consumes a topic for the queue consumer (queueconsumer.go) through the same
+// REST proxy. The proxy keeps a consumer instance in a consumer group (so
+// replicas split the partitions between them) and we poll it:
+//
+//	POST   /consumers/demo-app                          create the instance
+//	POST   /consumers/demo-app/instances/<id>/subscription {"topics":["demoapp-items"]}
+//	GET    /consumers/demo-app/instances/<id>/records   a batch of records
+//	POST   /consumers/demo-app/instances/<id>/offsets   commit what was handled
+//	DELETE /consumers/demo-app/instances/<id>           leave the group
+//
+// Offsets are committed only after every record in a batch is stored. If one
+// needs a retry, the instance is deleted before committing, and the group
+// hands the uncommitted records out again.
+
+// Content type of the REST proxy's v2 consumer management calls
+const kafkaV2ContentType = "application/vnd.kafka.v2+json"
+
+// kafkaSource consumes one topic through a REST proxy
+type kafkaSource struct {
+	base   string // KAFKA_REST_URL without a trailing slash
+	topic  string
+	group  string
+	client *http.Client
+}
+
+// newKafkaSource prepares a consumer; nothing is sent yet
+func newKafkaSource(restURL, topic, group string) *kafkaSource {
+	if group == "" {
+		group = "demo-app"
+	}
+	return &kafkaSource{
+		base:   strings.TrimSuffix(restURL, "/"),
+		topic:  topic,
+		group:  group,
+		client: newOutboundClient(30 * time.Second), // outbound.go
+	}
+}
+
+func (k *kafkaSource) name() string { return "kafka" }
+
+// kafkaRecord is one record from GET .../records
+type kafkaRecord struct {
+	Topic     string          `json:"topic"`
+	Value     json.RawMessage `json:"value"`
+	Partition int             `json:"partition"`
+	Offset    int64           `json:"offset"`
+}
+
+// consume joins the group and handles records until a call fails
+func (k *kafkaSource) consume(c *queueConsumer) error {
+	var created struct {
+		InstanceID string `json:"instance_id"`
+	}
+	err := k.call(http.MethodPost, k.base+"/consumers/"+url.PathEscape(k.group), map[string]any{
+		"format":             "json",
+		"auto.offset.reset":  "earliest",
+		"auto.commit.enable": "false",
+	}, &created)
+	if err != nil {
+		return err
+	}
+	if created.InstanceID == "" {
+		return fmt.Errorf("kafka rest proxy: no instance_id when creating consumer")
+	}
+	instance := k.base + "/consumers/" + url.PathEscape(k.group) + "/instances/" + url.PathEscape(created.InstanceID)
+	// Leave the group on the way out, so the partitions move on right away
+	defer k.call(http.MethodDelete, instance, nil, nil)
+
+	if err := k.call(http.MethodPost, instance+"/subscription", map[string]any{"topics": []string{k.topic}}, nil); err != nil {
+		return err
+	}
+	c.connected()
+
+	for {
+		var records []kafkaRecord
+		if err := k.call(http.MethodGet, instance+"/records?timeout=1000", nil, &records); err != nil {
+			return err
+		}
+		if len(records) == 0 {
+			continue
+		}
+		for _, record := range records {
+			msg := queueMessage{
+				id:   fmt.Sprintf("kafka:%s:%d:%d", record.Topic, record.Partition, record.Offset),
+				body: record.Value,
+			}
+			// A rejected record is skipped; committing past it drops it
+			if c.handle(msg) == queueRetry {
+				return fmt.Errorf("kafka: record %s needs a retry; rejoining to get it again", msg.id)
+			}
+		}
+		// An empty body commits everything this instance has fetched
+		if err := k.call(http.MethodPost, instance+"/offsets", nil, nil); err != nil {
+			return err
+		}
+	}
+}
+
+// call sends one request to the proxy and decodes the answer into out
+func (k *kafkaSource) call(method, target string, body any, out any) error {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(data)
+	}
+	req, err := http.NewRequest(method, target, reader)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", kafkaV2ContentType)
+	// Records come back in the embedded format they were written with (JSON)
+	if strings.HasSuffix(req.URL.Path, "/records") {
+		req.Header.Set("Accept", kafkaJSONContentType)
+	} else {
+		req.Header.Set("Accept", kafkaV2ContentType)
+	}
+
+	resp, err := k.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	data, _ := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		var result struct {
+			Message string `json:"message"`
+		}
+		json.Unmarshal(data, &result)
+		return fmt.Errorf("kafka rest proxy: %s %s: %s %s", method, req.URL.Path, resp.Status, result.Message)
+	}
+	if out != nil && len(data) > 0 {
+		return json.Unmarshal(data, out)
+	}
+	return nil
+}
@@ -0,0 +1,168 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestKafkaSink_Publish(t *testing.T) {
+	withCircuits(t, 0, time.Minute)
+
+	var gotPath, gotType string
+	var got struct {
+		Records []struct {
+			Key   string `json:"key"`
+			Value Event  `json:"value"`
+		} `json:"records"`
+	}
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotPath, gotType = r.URL.Path, r.Header.Get("Content-Type")
+		json.NewDecoder(r.Body).Decode(&got)
+		w.Write([]byte(`{"offsets":[{"partition":0,"offset":7}]}`))
+	}))
+	defer proxy.Close()
+
+	sink, err := newKafkaSink(proxy.URL+"/", "demo events")
+	if err != nil {
+		t.Fatal(err)
+	}
+	event := Event{ID: "e1", Type: eventItemDeleted, Key: "item-3", Data: json.RawMessage(`{"id":3}`)}
+	if err := sink.publish(event); err != nil {
+		t.Fatal(err)
+	}
+
+	if gotPath != "/topics/demo events" || gotType != kafkaJSONContentType {
+		t.Errorf("request = %s (%s)", gotPath, gotType)
+	}
+	if len(got.Records) != 1 || got.Records[0].Key != "item-3" || got.Records[0].Value.Type != eventItemDeleted {
+		t.Errorf("records = %+v", got.Records)
+	}
+}
+
+func TestKafkaSink_Errors(t *testing.T) {
+	withCircuits(t, 0, time.Minute)
+	withRetries(t, 0, 0)
+
+	tests := []struct {
+		name    string
+		status  int
+		body    string
+		wantErr string
+	}{
+		{"unknown topic", http.StatusNotFound, `{"error_code":40401,"message":"Topic not found"}`, "Topic not found"},
+		{"record rejected", http.StatusOK, `{"offsets":[{"error_code":1,"error":"too large"}]}`, "too large"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(tt.status)
+				w.Write([]byte(tt.body))
+			}))
+			defer proxy.Close()
+
+			sink, _ := newKafkaSink(proxy.URL, "demoapp-events")
+			err := sink.publish(Event{ID: "e1", Type: eventItemCreated})
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+
+	if _, err := newKafkaSink("kafka:9092", "t"); err == nil {
+		t.Error("expected an error for a non-HTTP URL")
+	}
+}
+
+func TestKafkaSource_Consume(t *testing.T) {
+	withCircuits(t, 0, time.Minute)
+	withRetries(t, 0, 0)
+	srv := newTestServer(t)
+	c := withQueueConsumer(t, srv)
+
+	var calls []string
+	polls := 0
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls = append(calls, r.Method+" "+r.URL.Path)
+		switch {
+		case r.URL.Path == "/consumers/demo-app":
+			w.Write([]byte(`{"instance_id":"c1","base_uri":"http://elsewhere/consumers/demo-app/instances/c1"}`))
+		case strings.HasSuffix(r.URL.Path, "/records"):
+			if r.Header.Get("Accept") != kafkaJSONContentType {
+				t.Errorf("records Accept = %s", r.Header.Get("Accept"))
+			}
+			polls++
+			switch polls {
+			case 1:
+				w.Write([]byte(`[
+					{"topic":"items","partition":0,"offset":4,"value":{"name":"from kafka"}},
+					{"topic":"items","partition":0,"offset":5,"value":{"name":""}}
+				]`))
+			case 2:
+				w.Write([]byte(`[]`))
+			default:
+				http.Error(w, `{"error_code":40403,"message":"Consumer instance not found"}`, http.StatusNotFound)
+			}
+		default:
+			w.WriteHeader(http.StatusNoContent)
+		}
+	}))
+	defer proxy.Close()
+
+	err := newKafkaSource(proxy.URL, "items", "demo-app").consume(c)
+	if err == nil || !strings.Contains(err.Error(), "Consumer instance not found") {
+		t.Errorf("consume error = %v", err)
+	}
+
+	want := []string{
+		"POST /consumers/demo-app",
+		"POST /consumers/demo-app/instances/c1/subscription",
+		"GET /consumers/demo-app/instances/c1/records",
+		"POST /consumers/demo-app/instances/c1/offsets",
+		"GET /consumers/demo-app/instances/c1/records",
+		"GET /consumers/demo-app/instances/c1/records",
+		"DELETE /consumers/demo-app/instances/c1",
+	}
+	if strings.Join(calls, "\n") != strings.Join(want, "\n") {
+		t.Errorf("calls:\n%s\nwant:\n%s", strings.Join(calls, "\n"), strings.Join(want, "\n"))
+	}
+	// The blank name fails validation: skipped, and committed past
+	if c.status.Created != 1 || c.status.Rejected != 1 {
+		t.Errorf("status = %+v", c.status)
+	}
+}
+
+func TestKafkaSource_RetryRejoins(t *testing.T) {
+	withCircuits(t, 0, time.Minute)
+	withRetries(t, 0, 0)
+	down := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, `{"error":"database unavailable"}`, http.StatusServiceUnavailable)
+	}))
+	defer down.Close()
+	c := withQueueConsumer(t, down)
+
+	committed, deleted := false, false
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/consumers/demo-app":
+			w.Write([]byte(`{"instance_id":"c1"}`))
+		case strings.HasSuffix(r.URL.Path, "/records"):
+			w.Write([]byte(`[{"topic":"items","partition":1,"offset":9,"value":{"name":"x"}}]`))
+		case strings.HasSuffix(r.URL.Path, "/offsets"):
+			committed = true
+		case r.Method == http.MethodDelete:
+			deleted = true
+		}
+	}))
+	defer proxy.Close()
+
+	if err := newKafkaSource(proxy.URL, "items", "").consume(c); err == nil {
+		t.Fatal("expected an error so the consumer rejoins")
+	}
+	if committed || !deleted {
+		t.Errorf("committed = %v, deleted = %v; want the instance deleted without a commit", committed, deleted)
+	}
+}
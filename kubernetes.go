@@ -0,0 +1,187 @@
+package main
+
+import (
+	"bufio"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// =============================================================================
+// Kubernetes Awareness
+// =============================================================================
+//
+// Inside a pod, Kubernetes leaves several clues about "where am I running":
+//
+//   - KUBERNETES_SERVICE_HOST/PORT env vars (set in every pod)
+//   - the service account mount: /var/run/secrets/kubernetes.io/serviceaccount
+//     with token, ca.crt, and namespace files
+//   - downward API env vars the manifest opts into (POD_NAME, NODE_NAME, ...)
+//   - a downward API volume (PODINFO_PATH, default /etc/podinfo) with
+//     labels and annotations files
+//
+// GET /api/system/kubernetes gathers them into one structured answer.
+// The token itself is never returned — only whether it's present.
+//
+// Example manifest snippet to expose everything:
+//
+//	env:
+//	  - name: POD_NAME
+//	    valueFrom: {fieldRef: {fieldPath: metadata.name}}
+//	  - name: NODE_NAME
+//	    valueFrom: {fieldRef: {fieldPath: spec.nodeName}}
+//	volumes:
+//	  - name: podinfo
+//	    downwardAPI:
+//	      items:
+//	        - {path: labels, fieldRef: {fieldPath: metadata.labels}}
+//	        - {path: annotations, fieldRef: {fieldPath: metadata.annotations}}
+
+// Where Kubernetes mounts the pod's service account credentials
+const serviceAccountDir = "/var/run/secrets/kubernetes.io/serviceaccount"
+
+// Default mount path for a downward API volume (override with PODINFO_PATH)
+const defaultPodinfoDir = "/etc/podinfo"
+
+// KubernetesInfo is the response for /api/system/kubernetes
+type KubernetesInfo struct {
+	InCluster      bool               `json:"in_cluster"`
+	APIServer      string             `json:"api_server,omitempty"`
+	Namespace      string             `json:"namespace,omitempty"`
+	Pod            PodInfo            `json:"pod"`
+	ServiceAccount ServiceAccountInfo `json:"service_account"`
+	Labels         map[string]string  `json:"labels"`
+	Annotations    map[string]string  `json:"annotations"`
+}
+
+// PodInfo comes from downward API env vars (or podinfo files as a fallback)
+type PodInfo struct {
+	Name           string `json:"name,omitempty"`
+	Namespace      string `json:"namespace,omitempty"`
+	UID            string `json:"uid,omitempty"`
+	IP             string `json:"ip,omitempty"`
+	NodeName       string `json:"node_name,omitempty"`
+	HostIP         string `json:"host_ip,omitempty"`
+	ServiceAccount string `json:"service_account,omitempty"`
+}
+
+// ServiceAccountInfo describes the mounted service account
+type ServiceAccountInfo struct {
+	Mounted      bool   `json:"mounted"`
+	TokenPresent bool   `json:"token_present"`
+	CAPresent    bool   `json:"ca_present"`
+	Namespace    string `json:"namespace,omitempty"`
+}
+
+// getKubernetesInfo inspects the environment and the given mount directories
+func getKubernetesInfo(saDir, podinfoDir string) KubernetesInfo {
+	info := KubernetesInfo{
+		Pod: PodInfo{
+			Name:           os.Getenv("POD_NAME"),
+			Namespace:      os.Getenv("POD_NAMESPACE"),
+			UID:            os.Getenv("POD_UID"),
+			IP:             os.Getenv("POD_IP"),
+			NodeName:       os.Getenv("NODE_NAME"),
+			HostIP:         os.Getenv("HOST_IP"),
+			ServiceAccount: os.Getenv("POD_SERVICE_ACCOUNT"),
+		},
+		Labels:      readDownwardAPIMap(filepath.Join(podinfoDir, "labels")),
+		Annotations: readDownwardAPIMap(filepath.Join(podinfoDir, "annotations")),
+	}
+
+	if host := os.Getenv("KUBERNETES_SERVICE_HOST"); host != "" {
+		info.InCluster = true
+		info.APIServer = net.JoinHostPort(host, envString("KUBERNETES_SERVICE_PORT", "443"))
+	}
+
+	// Service account mount
+	if _, err := os.Stat(saDir); err == nil {
+		info.ServiceAccount.Mounted = true
+		info.ServiceAccount.TokenPresent = fileExists(filepath.Join(saDir, "token"))
+		info.ServiceAccount.CAPresent = fileExists(filepath.Join(saDir, "ca.crt"))
+		info.ServiceAccount.Namespace = readTrimmedFile(filepath.Join(saDir, "namespace"))
+	}
+
+	// Downward API volumes can also carry name/namespace/uid as plain files
+	if info.Pod.Name == "" {
+		info.Pod.Name = readTrimmedFile(filepath.Join(podinfoDir, "name"))
+	}
+	if info.Pod.Namespace == "" {
+		info.Pod.Namespace = readTrimmedFile(filepath.Join(podinfoDir, "namespace"))
+	}
+	if info.Pod.UID == "" {
+		info.Pod.UID = readTrimmedFile(filepath.Join(podinfoDir, "uid"))
+	}
+
+	// Best answer for "which namespace": the service account's is authoritative
+	info.Namespace = info.ServiceAccount.Namespace
+	if info.Namespace == "" {
+		info.Namespace = info.Pod.Namespace
+	}
+
+	return info
+}
+
+// readDownwardAPIMap parses a downward API labels/annotations file.
+// Each line is key="value" with the value Go-quoted, e.g.:
+//
+//	app="demo-app"
+//	team="platform"
+//
+// A missing file gives an empty map (not nil, so JSON shows {}).
+func readDownwardAPIMap(path string) map[string]string {
+	result := make(map[string]string)
+
+	f, err := os.Open(path)
+	if err != nil {
+		return result
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	// Annotations can be long (e.g. last-applied-configuration); allow 1MB lines
+	scanner.Buffer(make([]byte, 64*1024), 1<<20)
+	for scanner.Scan() {
+		key, raw, ok := strings.Cut(scanner.Text(), "=")
+		if !ok {
+			continue
+		}
+		value, err := strconv.Unquote(raw)
+		if err != nil {
+			value = raw // not quoted — keep as-is
+		}
+		result[key] = value
+	}
+	return result
+}
+
+// fileExists reports whether path exists
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
+
+// readTrimmedFile returns a file's contents without surrounding whitespace,
+// or "" if it can't be read
+func readTrimmedFile(path string) string {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(data))
+}
+
+// kubernetesHandler handles GET /api/system/kubernetes
+func kubernetesHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, `{"error":"method not allowed"}`, http.StatusMethodNotAllowed)
+		return
+	}
+
+	info := getKubernetesInfo(serviceAccountDir, envString("PODINFO_PATH", defaultPodinfoDir))
+
+	writeResponse(w, r, http.StatusOK, info)
+}
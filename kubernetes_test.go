@@ -0,0 +1,59 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestKubernetes_InCluster(t *testing.T) {
+	saDir := t.TempDir()
+	os.WriteFile(filepath.Join(saDir, "token"), []byte("secret-token"), 0o600)
+	os.WriteFile(filepath.Join(saDir, "namespace"), []byte("demo\n"), 0o644)
+
+	podinfoDir := t.TempDir()
+	os.WriteFile(filepath.Join(podinfoDir, "labels"),
+		[]byte("app=\"demo-app\"\npod-template-hash=\"abc123\"\n"), 0o644)
+	os.WriteFile(filepath.Join(podinfoDir, "annotations"),
+		[]byte("note=\"line one\\nline two\"\n"), 0o644)
+
+	t.Setenv("KUBERNETES_SERVICE_HOST", "10.96.0.1")
+	t.Setenv("KUBERNETES_SERVICE_PORT", "443")
+	t.Setenv("POD_NAME", "demo-app-7d4f")
+	t.Setenv("NODE_NAME", "node-1")
+
+	info := getKubernetesInfo(saDir, podinfoDir)
+
+	if !info.InCluster || info.APIServer != "10.96.0.1:443" {
+		t.Errorf("expected in-cluster with API server, got %v %q", info.InCluster, info.APIServer)
+	}
+	if info.Namespace != "demo" {
+		t.Errorf("expected namespace 'demo', got %q", info.Namespace)
+	}
+	if !info.ServiceAccount.Mounted || !info.ServiceAccount.TokenPresent || info.ServiceAccount.CAPresent {
+		t.Errorf("unexpected service account info: %+v", info.ServiceAccount)
+	}
+	if info.Pod.Name != "demo-app-7d4f" || info.Pod.NodeName != "node-1" {
+		t.Errorf("unexpected pod info: %+v", info.Pod)
+	}
+	if info.Labels["app"] != "demo-app" || info.Labels["pod-template-hash"] != "abc123" {
+		t.Errorf("unexpected labels: %v", info.Labels)
+	}
+	if info.Annotations["note"] != "line one\nline two" {
+		t.Errorf("expected unquoted annotation, got %q", info.Annotations["note"])
+	}
+}
+
+func TestKubernetes_OutsideCluster(t *testing.T) {
+	t.Setenv("KUBERNETES_SERVICE_HOST", "")
+	missing := filepath.Join(t.TempDir(), "missing")
+
+	info := getKubernetesInfo(missing, missing)
+
+	if info.InCluster || info.ServiceAccount.Mounted {
+		t.Errorf("expected not in cluster, got %+v", info)
+	}
+	if info.Labels == nil || len(info.Labels) != 0 {
+		t.Errorf("expected empty labels map, got %v", info.Labels)
+	}
+}
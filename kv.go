@@ -0,0 +1,237 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	badger "github.com/dgraph-io/badger/v4"
+)
+
+// =============================================================================
+// Key-Value Scratch API
+// =============================================================================
+//
+// A place for ad-hoc workshop state that isn't an item: feature toggles,
+// notes, Terraform outputs. Values are stored as sent and come back with the
+// same Content-Type:
+//
+//	curl -X PUT http://localhost:8080/api/kv/flags/dark-mode -d 'true'
+//	curl -X PUT http://localhost:8080/api/kv/tf/outputs \
+//	     -H "Content-Type: application/json" -d @outputs.json
+//	curl http://localhost:8080/api/kv/tf/outputs
+//	curl "http://localhost:8080/api/kv?prefix=flags/"
+//	curl -X DELETE http://localhost:8080/api/kv/flags/dark-mode
+//
+// Like attachments (attachments.go), only safe types open in a browser: a
+// value PUT as text/html downloads instead of rendering as a page.
+//
+// Keys may contain slashes, which makes namespaces: "flags/", "team-a/notes".
+// Listing with ?prefix= is a Badger prefix scan, so it only reads the
+// matching keys.
+
+// Prefix for KV entries in BadgerDB (the user's key follows)
+const kvKeyPrefix = "kv:"
+
+// Longest key accepted
+const maxKVKeyLength = 256
+
+// Largest value accepted, from KV_MAX_VALUE_BYTES (set in main)
+var kvMaxValueBytes int64 = 64 << 10 // 64 KiB
+
+// kvEntry is what's stored under each key
+type kvEntry struct {
+	ContentType string    `json:"content_type"`
+	UpdatedAt   time.Time `json:"updated_at"`
+	Value       []byte    `json:"value"` // base64 in the stored JSON
+}
+
+// KVInfo describes one key in listings and PUT responses (no value)
+type KVInfo struct {
+	Key         string    `json:"key"`
+	Size        int       `json:"size"`
+	ContentType string    `json:"content_type"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// validateKVKey checks a key: printable ASCII without spaces, no empty
+// namespace segments
+func validateKVKey(key string) error {
+	if key == "" {
+		return errors.New("key is required")
+	}
+	if len(key) > maxKVKeyLength {
+		return fmt.Errorf("key must be at most %d characters", maxKVKeyLength)
+	}
+	for _, c := range key {
+		if c <= ' ' || c > '~' {
+			return errors.New("key must be printable ASCII without spaces")
+		}
+	}
+	if strings.HasPrefix(key, "/") || strings.HasSuffix(key, "/") || strings.Contains(key, "//") {
+		return errors.New("key must not have empty segments")
+	}
+	return nil
+}
+
+// kvHandler handles /api/kv and /api/kv/:key
+func kvHandler(w http.ResponseWriter, r *http.Request) {
+	key := strings.TrimPrefix(strings.TrimPrefix(r.URL.Path, "/api/kv"), "/")
+	if key == "" {
+		if r.Method != http.MethodGet {
+			http.Error(w, `{"error":"method not allowed"}`, http.StatusMethodNotAllowed)
+			return
+		}
+		listKV(w, r)
+		return
+	}
+
+	if err := validateKVKey(key); err != nil {
+		writeJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		getKV(w, r, key)
+	case http.MethodPut:
+		putKV(w, r, key)
+	case http.MethodDelete:
+		deleteKV(w, r, key)
+	default:
+		http.Error(w, `{"error":"method not allowed"}`, http.StatusMethodNotAllowed)
+	}
+}
+
+// listKV lists keys (not values), optionally under ?prefix=
+func listKV(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	prefix := []byte(kvKeyPrefix + r.URL.Query().Get("prefix"))
+
+	list := []KVInfo{}
+	err := dbViewContext(r.Context(), "kv_list", string(prefix), func(txn *badger.Txn) error {
+		it := txn.NewIterator(badger.DefaultIteratorOptions)
+		defer it.Close()
+
+		for it.Seek(prefix); it.ValidForPrefix(prefix); it.Next() {
+			var entry kvEntry
+			if err := it.Item().Value(func(val []byte) error { return json.Unmarshal(val, &entry) }); err != nil {
+				return err
+			}
+			list = append(list, KVInfo{
+				Key:         strings.TrimPrefix(string(it.Item().Key()), kvKeyPrefix),
+				Size:        len(entry.Value),
+				ContentType: entry.ContentType,
+				UpdatedAt:   entry.UpdatedAt,
+			})
+		}
+		return nil
+	})
+	if err != nil {
+		logHandlerError(r.Context(), "kv", "database", "failed to list keys", "error", err)
+		http.Error(w, `{"error":"database error"}`, http.StatusInternalServerError)
+		return
+	}
+	json.NewEncoder(w).Encode(list)
+}
+
+// getKV returns a value as it was stored, with its Content-Type
+func getKV(w http.ResponseWriter, r *http.Request, key string) {
+	var entry kvEntry
+	err := dbViewContext(r.Context(), "kv_get", kvKeyPrefix+key, func(txn *badger.Txn) error {
+		item, err := txn.Get([]byte(kvKeyPrefix + key))
+		if err != nil {
+			return err
+		}
+		return item.Value(func(val []byte) error { return json.Unmarshal(val, &entry) })
+	})
+	if errors.Is(err, badger.ErrKeyNotFound) {
+		http.Error(w, `{"error":"not found"}`, http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		logHandlerError(r.Context(), "kv", "database", "failed to read key", "error", err)
+		http.Error(w, `{"error":"database error"}`, http.StatusInternalServerError)
+		return
+	}
+
+	setUploadedContentHeaders(w, entry.ContentType, "") // attachments.go
+	w.Header().Set("Content-Length", strconv.Itoa(len(entry.Value)))
+	w.Header().Set("Last-Modified", entry.UpdatedAt.Format(http.TimeFormat))
+	w.Write(entry.Value)
+}
+
+// putKV creates or replaces a value: 201 if the key is new, 200 if replaced
+func putKV(w http.ResponseWriter, r *http.Request, key string) {
+	w.Header().Set("Content-Type", "application/json")
+
+	r.Body = http.MaxBytesReader(w, r.Body, kvMaxValueBytes)
+	value, err := io.ReadAll(r.Body)
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		writeJSONError(w, http.StatusRequestEntityTooLarge, fmt.Sprintf("value larger than %d bytes", kvMaxValueBytes))
+		return
+	}
+	if err != nil {
+		http.Error(w, `{"error":"failed to read body"}`, http.StatusBadRequest)
+		return
+	}
+
+	contentType := r.Header.Get("Content-Type")
+	// curl -d sends form-urlencoded unless told otherwise; guess instead
+	if contentType == "" || strings.HasPrefix(contentType, "application/x-www-form-urlencoded") {
+		contentType = http.DetectContentType(value)
+	}
+	entry := kvEntry{ContentType: contentType, UpdatedAt: time.Now().UTC(), Value: value}
+	data, err := json.Marshal(entry)
+	if err != nil {
+		http.Error(w, `{"error":"failed to encode value"}`, http.StatusInternalServerError)
+		return
+	}
+
+	created := false
+	err = dbUpdateContext(r.Context(), "kv_put", kvKeyPrefix+key, func(txn *badger.Txn) error {
+		_, err := txn.Get([]byte(kvKeyPrefix + key))
+		if errors.Is(err, badger.ErrKeyNotFound) {
+			created = true
+		} else if err != nil {
+			return err
+		}
+		return txn.Set([]byte(kvKeyPrefix+key), data)
+	})
+	if err != nil {
+		logHandlerError(r.Context(), "kv", "database", "failed to store key", "error", err)
+		http.Error(w, `{"error":"database error"}`, http.StatusInternalServerError)
+		return
+	}
+
+	if created {
+		w.WriteHeader(http.StatusCreated)
+	}
+	json.NewEncoder(w).Encode(KVInfo{Key: key, Size: len(value), ContentType: contentType, UpdatedAt: entry.UpdatedAt})
+}
+
+// deleteKV removes a key (204, or 404 if it doesn't exist)
+func deleteKV(w http.ResponseWriter, r *http.Request, key string) {
+	err := dbUpdateContext(r.Context(), "kv_delete", kvKeyPrefix+key, func(txn *badger.Txn) error {
+		if _, err := txn.Get([]byte(kvKeyPrefix + key)); err != nil {
+			return err
+		}
+		return txn.Delete([]byte(kvKeyPrefix + key))
+	})
+	if errors.Is(err, badger.ErrKeyNotFound) {
+		http.Error(w, `{"error":"not found"}`, http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		logHandlerError(r.Context(), "kv", "database", "failed to delete key", "error", err)
+		http.Error(w, `{"error":"database error"}`, http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestKV_PutGetListDelete(t *testing.T) {
+	srv := newTestServer(t)
+
+	// New key: 201; replacing it: 200
+	code, body := doRequest(t, srv, http.MethodPut, "/api/kv/flags/dark-mode", "false")
+	if code != http.StatusCreated {
+		t.Fatalf("first PUT = %d %s", code, body)
+	}
+	if code, _ := doRequest(t, srv, http.MethodPut, "/api/kv/flags/dark-mode", "true"); code != http.StatusOK {
+		t.Errorf("second PUT = %d, want 200", code)
+	}
+
+	// The value comes back as sent, with its Content-Type
+	req, _ := http.NewRequest(http.MethodPut, srv.URL+"/api/kv/tf/outputs", strings.NewReader(`{"vpc_id":"vpc-123"}`))
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := srv.Client().Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	resp, err = srv.Client().Get(srv.URL + "/api/kv/tf/outputs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if string(got) != `{"vpc_id":"vpc-123"}` || resp.Header.Get("Content-Type") != "application/json" {
+		t.Errorf("GET = %s (%s)", got, resp.Header.Get("Content-Type"))
+	}
+	if got := resp.Header.Get("X-Content-Type-Options"); got != "nosniff" {
+		t.Errorf("X-Content-Type-Options = %q, want nosniff", got)
+	}
+	if _, body := doRequest(t, srv, http.MethodGet, "/api/kv/flags/dark-mode", ""); string(body) != "true" {
+		t.Errorf("flag = %q, want the replaced value", body)
+	}
+
+	// Listing by namespace
+	_, body = doRequest(t, srv, http.MethodGet, "/api/kv?prefix=flags/", "")
+	var list []KVInfo
+	json.Unmarshal(body, &list)
+	if len(list) != 1 || list[0].Key != "flags/dark-mode" || list[0].Size != 4 {
+		t.Errorf("prefix list = %s", body)
+	}
+	_, body = doRequest(t, srv, http.MethodGet, "/api/kv", "")
+	list = nil
+	json.Unmarshal(body, &list)
+	if len(list) != 2 {
+		t.Errorf("full list = %s", body)
+	}
+
+	if code, _ := doRequest(t, srv, http.MethodDelete, "/api/kv/flags/dark-mode", ""); code != http.StatusNoContent {
+		t.Errorf("DELETE = %d, want 204", code)
+	}
+	if code, _ := doRequest(t, srv, http.MethodGet, "/api/kv/flags/dark-mode", ""); code != http.StatusNotFound {
+		t.Errorf("GET after delete = %d, want 404", code)
+	}
+}
+
+func TestKV_Errors(t *testing.T) {
+	srv := newTestServer(t)
+
+	old := kvMaxValueBytes
+	kvMaxValueBytes = 8
+	t.Cleanup(func() { kvMaxValueBytes = old })
+
+	tests := []struct {
+		name       string
+		method     string
+		path       string
+		body       string
+		wantStatus int
+	}{
+		{"missing key", http.MethodGet, "/api/kv/nothing", "", http.StatusNotFound},
+		{"delete missing key", http.MethodDelete, "/api/kv/nothing", "", http.StatusNotFound},
+		{"value too large", http.MethodPut, "/api/kv/big", "123456789", http.StatusRequestEntityTooLarge},
+		{"value at the limit", http.MethodPut, "/api/kv/ok", "12345678", http.StatusCreated},
+		{"space in key", http.MethodPut, "/api/kv/a%20b", "x", http.StatusBadRequest},
+		{"key too long", http.MethodPut, "/api/kv/" + strings.Repeat("k", maxKVKeyLength+1), "x", http.StatusBadRequest},
+		{"write to list", http.MethodPost, "/api/kv", "", http.StatusMethodNotAllowed},
+		{"wrong method", http.MethodPost, "/api/kv/x", "", http.StatusMethodNotAllowed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, body := doRequest(t, srv, tt.method, tt.path, tt.body)
+			if code != tt.wantStatus {
+				t.Errorf("status = %d, want %d (body %s)", code, tt.wantStatus, body)
+			}
+		})
+	}
+}
+
+func TestValidateKVKey(t *testing.T) {
+	for _, key := range []string{"a", "team-a/notes", "tf:outputs.v2", strings.Repeat("k", maxKVKeyLength)} {
+		if err := validateKVKey(key); err != nil {
+			t.Errorf("validateKVKey(%q) = %v, want ok", key, err)
+		}
+	}
+	for _, key := range []string{"", "/a", "a/", "a//b", "a b", "ключ", "a\n"} {
+		if err := validateKVKey(key); err == nil {
+			t.Errorf("validateKVKey(%q) = nil, want an error", key)
+		}
+	}
+}
+
+func TestKV_HTMLDownloads(t *testing.T) {
+	srv := newTestServer(t)
+	req, _ := http.NewRequest(http.MethodPut, srv.URL+"/api/kv/page", strings.NewReader(`<script>alert(1)</script>`))
+	req.Header.Set("Content-Type", "text/html")
+	resp, err := srv.Client().Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	resp, err = srv.Client().Get(srv.URL + "/api/kv/page")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if got := resp.Header.Get("Content-Disposition"); got != "attachment" {
+		t.Errorf("Content-Disposition = %q, want attachment", got)
+	}
+	if got := resp.Header.Get("X-Content-Type-Options"); got != "nosniff" {
+		t.Errorf("X-Content-Type-Options = %q, want nosniff", got)
+	}
+}
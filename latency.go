@@ -0,0 +1,196 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/rand/v2"
+	"net/http"
+	"path"
+	"strings"
+	"sync"
+	"time"
+)
+
+// =============================================================================
+// Per-Route Latency Injection
+// =============================================================================
+//
+// Response rules (rules.go) can add a fixed delay_ms, but real services don't
+// have fixed latency — most requests are fast and a few are slow. Latency
+// profiles describe a DISTRIBUTION per route instead, so latency-based routing
+// and SLO alerting demos see a realistic p50/p99 spread:
+//
+//	PUT /api/admin/latency
+//	[
+//	  {"path": "/api/items", "p50": "200ms", "p99": "1s"},
+//	  {"method": "POST", "path": "/api/items*", "p50": "50ms"}
+//	]
+//
+// Each request to a matching route sleeps for a random duration drawn from a
+// log-normal distribution whose median is p50 and whose 99th percentile is
+// p99. (Log-normal is the classic shape of service latency: a tight hump with
+// a long right tail.) Omit p99 for a fixed delay.
+//
+// The first matching profile wins. Admin routes are never delayed.
+
+// Injected delays are capped so a typo like "p99": "1h" can't hang clients
+const maxInjectedLatency = 60 * time.Second
+
+// z-score of the 99th percentile of a standard normal distribution
+const z99 = 2.326
+
+// LatencyProfile is the artificial latency for one route
+type LatencyProfile struct {
+	Method string `json:"method,omitempty"`
+	Path   string `json:"path"` // glob pattern, same syntax as rule paths
+	P50    string `json:"p50"`
+	P99    string `json:"p99,omitempty"`
+
+	// Parsed distribution parameters (see compileLatencyProfile)
+	mu    float64 // log of the median, in seconds
+	sigma float64 // spread; 0 means a fixed delay
+}
+
+// Active profiles; latencyMu guards the slice (read by every request)
+var (
+	latencyMu       sync.RWMutex
+	latencyProfiles []*LatencyProfile
+)
+
+// compileLatencyProfile validates a profile and fits its distribution.
+//
+// For a log-normal, median = e^mu and p99 = e^(mu + z99*sigma), so:
+//
+//	mu    = ln(p50)
+//	sigma = ln(p99/p50) / z99
+func compileLatencyProfile(p *LatencyProfile) error {
+	if p.Path == "" {
+		return fmt.Errorf("path is required")
+	}
+	if _, err := path.Match(p.Path, "/"); err != nil {
+		return fmt.Errorf("invalid path pattern %q: %w", p.Path, err)
+	}
+
+	p50, err := time.ParseDuration(p.P50)
+	if err != nil || p50 <= 0 {
+		return fmt.Errorf("p50 must be a positive duration")
+	}
+	p.mu = math.Log(p50.Seconds())
+	p.sigma = 0
+
+	if p.P99 != "" {
+		p99, err := time.ParseDuration(p.P99)
+		if err != nil || p99 < p50 {
+			return fmt.Errorf("p99 must be a duration no smaller than p50")
+		}
+		p.sigma = math.Log(p99.Seconds()/p50.Seconds()) / z99
+	}
+	return nil
+}
+
+// sample draws one delay from the profile's distribution
+func (p *LatencyProfile) sample() time.Duration {
+	seconds := math.Exp(p.mu + p.sigma*rand.NormFloat64())
+	d := time.Duration(seconds * float64(time.Second))
+	return min(d, maxInjectedLatency)
+}
+
+// matches reports whether the profile applies to a request
+func (p *LatencyProfile) matches(r *http.Request) bool {
+	if p.Method != "" && !strings.EqualFold(p.Method, r.Method) {
+		return false
+	}
+	ok, _ := path.Match(p.Path, r.URL.Path)
+	return ok
+}
+
+// setLatencyProfiles validates and swaps the whole profile set (all-or-nothing)
+func setLatencyProfiles(profiles []*LatencyProfile) error {
+	for i, p := range profiles {
+		if p == nil { // a JSON null in the list
+			return fmt.Errorf("profile %d is null", i)
+		}
+		if err := compileLatencyProfile(p); err != nil {
+			return fmt.Errorf("profile %d: %w", i, err)
+		}
+	}
+
+	latencyMu.Lock()
+	defer latencyMu.Unlock()
+	latencyProfiles = profiles
+	return nil
+}
+
+// findLatencyProfile returns the first profile matching the request, or nil
+func findLatencyProfile(r *http.Request) *LatencyProfile {
+	latencyMu.RLock()
+	defer latencyMu.RUnlock()
+	for _, p := range latencyProfiles {
+		if p.matches(r) {
+			return p
+		}
+	}
+	return nil
+}
+
+// latencyMiddleware delays requests that match a latency profile
+func latencyMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasPrefix(r.URL.Path, "/api/admin/") {
+			if p := findLatencyProfile(r); p != nil {
+				// Sleep, giving up early if the client disconnects
+				select {
+				case <-time.After(p.sample()):
+				case <-r.Context().Done():
+					return
+				}
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// =============================================================================
+// Admin API: /api/admin/latency
+// =============================================================================
+
+// latencyAdminHandler manages latency profiles at runtime
+//
+//	GET    /api/admin/latency -> list profiles
+//	PUT    /api/admin/latency -> replace all profiles
+//	DELETE /api/admin/latency -> remove all profiles
+func latencyAdminHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	switch r.Method {
+	case http.MethodGet:
+		// Encode under the read lock; profiles are never mutated in place
+		latencyMu.RLock()
+		defer latencyMu.RUnlock()
+		list := latencyProfiles
+		if list == nil {
+			list = []*LatencyProfile{}
+		}
+		json.NewEncoder(w).Encode(list)
+
+	case http.MethodPut:
+		var profiles []*LatencyProfile
+		if err := json.NewDecoder(r.Body).Decode(&profiles); err != nil {
+			http.Error(w, `{"error":"invalid json"}`, http.StatusBadRequest)
+			return
+		}
+		if err := setLatencyProfiles(profiles); err != nil {
+			writeJSONError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		json.NewEncoder(w).Encode(profiles)
+
+	case http.MethodDelete:
+		setLatencyProfiles(nil)
+		w.WriteHeader(http.StatusNoContent)
+
+	default:
+		http.Error(w, `{"error":"method not allowed"}`, http.StatusMethodNotAllowed)
+	}
+}
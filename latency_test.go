@@ -0,0 +1,102 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLatency_DistributionMatchesPercentiles(t *testing.T) {
+	p := &LatencyProfile{Path: "/api/items", P50: "200ms", P99: "1s"}
+	if err := compileLatencyProfile(p); err != nil {
+		t.Fatalf("failed to compile profile: %v", err)
+	}
+
+	const n = 20000
+	samples := make([]time.Duration, n)
+	for i := range samples {
+		samples[i] = p.sample()
+	}
+	sort.Slice(samples, func(i, j int) bool { return samples[i] < samples[j] })
+
+	// Sampled percentiles should land near the configured ones
+	p50 := samples[n/2]
+	p99 := samples[n*99/100]
+	if p50 < 180*time.Millisecond || p50 > 220*time.Millisecond {
+		t.Errorf("expected p50 near 200ms, got %s", p50)
+	}
+	if p99 < 800*time.Millisecond || p99 > 1250*time.Millisecond {
+		t.Errorf("expected p99 near 1s, got %s", p99)
+	}
+}
+
+func TestLatency_FixedDelayWithoutP99(t *testing.T) {
+	p := &LatencyProfile{Path: "/*", P50: "50ms"}
+	if err := compileLatencyProfile(p); err != nil {
+		t.Fatalf("failed to compile profile: %v", err)
+	}
+	for i := 0; i < 10; i++ {
+		if d := p.sample(); d != 50*time.Millisecond {
+			t.Fatalf("expected fixed 50ms, got %s", d)
+		}
+	}
+}
+
+func TestLatency_InvalidProfiles(t *testing.T) {
+	bad := []*LatencyProfile{
+		{P50: "10ms"},                         // missing path
+		{Path: "/x", P50: "fast"},             // bad duration
+		{Path: "/x", P50: "1s", P99: "100ms"}, // p99 < p50
+		{Path: "[", P50: "10ms"},              // bad glob
+	}
+	for _, p := range bad {
+		if err := setLatencyProfiles([]*LatencyProfile{p}); err == nil {
+			t.Errorf("expected error for %+v", *p)
+		}
+	}
+	if err := setLatencyProfiles([]*LatencyProfile{nil}); err == nil {
+		t.Error("expected error for a null profile")
+	}
+}
+
+func TestLatency_AdminAndMiddleware(t *testing.T) {
+	defer setLatencyProfiles(nil)
+
+	req := httptest.NewRequest("PUT", "/api/admin/latency",
+		strings.NewReader(`[{"method":"GET","path":"/api/items","p50":"30ms"}]`))
+	rr := httptest.NewRecorder()
+	latencyAdminHandler(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	handler := latencyMiddleware(next)
+
+	// Matching route is delayed
+	start := time.Now()
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/api/items", nil))
+	if elapsed := time.Since(start); elapsed < 30*time.Millisecond {
+		t.Errorf("expected at least 30ms delay, got %s", elapsed)
+	}
+
+	// Other methods are not
+	start = time.Now()
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("POST", "/api/items", nil))
+	if elapsed := time.Since(start); elapsed >= 30*time.Millisecond {
+		t.Errorf("expected no delay for POST, got %s", elapsed)
+	}
+
+	// DELETE clears all profiles
+	rr = httptest.NewRecorder()
+	latencyAdminHandler(rr, httptest.NewRequest("DELETE", "/api/admin/latency", nil))
+	if rr.Code != http.StatusNoContent {
+		t.Errorf("expected status 204, got %d", rr.Code)
+	}
+	if findLatencyProfile(httptest.NewRequest("GET", "/api/items", nil)) != nil {
+		t.Error("expected profiles to be cleared")
+	}
+}
@@ -0,0 +1,188 @@
+package main
+
+import (
+	"encoding/json"
+	"math"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+// =============================================================================
+// Latency Percentiles
+// =============================================================================
+//
+// Prometheus computes percentiles from demoapp_http_request_duration_seconds,
+// but not every demo has Prometheus. GET /api/stats/latency gives the same
+// answer straight from the app — p50/p90/p99 per route over the last few
+// minutes:
+//
+//	curl http://localhost:8080/api/stats/latency
+//	{"window":"5m0s","routes":[
+//	  {"method":"GET","path":"/api/items","count":412,"p50_ms":0.6,"p90_ms":1.2,"p99_ms":3.9,"max_ms":8.1}]}
+//
+// Turn on latency injection (latency.go) or a slow rule (rules.go) and the
+// numbers move within one window. ?window=1m looks at a shorter span.
+//
+// Each route keeps its latest samples in a ring buffer (like
+// recentrequests.go), so memory is bounded however busy a route gets. When a
+// route sees more than maxLatencySamples requests in the window, the
+// percentiles cover the newest ones.
+
+// Samples kept per route
+const maxLatencySamples = 1000
+
+// Routes tracked; anything beyond is counted under "other" so random 404
+// paths can't grow the map forever
+const maxLatencyRoutes = 200
+
+// Default span the percentiles cover, from LATENCY_STATS_WINDOW (set in main)
+var latencyStatsWindow = 5 * time.Minute
+
+// latencySample is one request's duration and when it finished
+type latencySample struct {
+	at       time.Time
+	duration time.Duration
+}
+
+// latencyRoute is the sample ring for one method + path
+type latencyRoute struct {
+	samples []latencySample
+	next    int
+}
+
+// latencyRecorder holds every route's samples
+type latencyRecorder struct {
+	mu     sync.Mutex
+	routes map[[2]string]*latencyRoute // key: method, normalized path
+}
+
+var latencyStats = &latencyRecorder{routes: map[[2]string]*latencyRoute{}}
+
+// RouteLatency is one route in GET /api/stats/latency
+type RouteLatency struct {
+	Method string  `json:"method"`
+	Path   string  `json:"path"`
+	Count  int     `json:"count"`
+	P50Ms  float64 `json:"p50_ms"`
+	P90Ms  float64 `json:"p90_ms"`
+	P99Ms  float64 `json:"p99_ms"`
+	MaxMs  float64 `json:"max_ms"`
+}
+
+// record adds one request (path already normalized, see normalizePath)
+func (l *latencyRecorder) record(method, path string, d time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	key := [2]string{method, path}
+	route, ok := l.routes[key]
+	if !ok {
+		if len(l.routes) >= maxLatencyRoutes {
+			key = [2]string{method, "other"}
+			route = l.routes[key]
+		}
+		if route == nil {
+			route = &latencyRoute{}
+			l.routes[key] = route
+		}
+	}
+
+	sample := latencySample{at: time.Now(), duration: d}
+	if len(route.samples) < maxLatencySamples {
+		route.samples = append(route.samples, sample)
+		return
+	}
+	route.samples[route.next] = sample
+	route.next = (route.next + 1) % maxLatencySamples
+}
+
+// summary computes percentiles for every route with samples in the window
+func (l *latencyRecorder) summary(window time.Duration) []RouteLatency {
+	cutoff := time.Now().Add(-window)
+	routes := []RouteLatency{}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for key, route := range l.routes {
+		var durations []time.Duration
+		for _, s := range route.samples {
+			if s.at.After(cutoff) {
+				durations = append(durations, s.duration)
+			}
+		}
+		if len(durations) == 0 {
+			continue
+		}
+		sort.Slice(durations, func(i, j int) bool { return durations[i] < durations[j] })
+		routes = append(routes, RouteLatency{
+			Method: key[0],
+			Path:   key[1],
+			Count:  len(durations),
+			P50Ms:  durationMs(percentile(durations, 50)),
+			P90Ms:  durationMs(percentile(durations, 90)),
+			P99Ms:  durationMs(percentile(durations, 99)),
+			MaxMs:  durationMs(durations[len(durations)-1]),
+		})
+	}
+
+	sort.Slice(routes, func(i, j int) bool {
+		if routes[i].Path != routes[j].Path {
+			return routes[i].Path < routes[j].Path
+		}
+		return routes[i].Method < routes[j].Method
+	})
+	return routes
+}
+
+// reset forgets every sample
+func (l *latencyRecorder) reset() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.routes = map[[2]string]*latencyRoute{}
+}
+
+// percentile picks the nearest-rank p-th percentile of sorted durations:
+// the smallest value that at least p% of the samples are at or below
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	rank := int(math.Ceil(p / 100 * float64(len(sorted))))
+	return sorted[max(rank, 1)-1]
+}
+
+// durationMs converts to milliseconds, rounded to 0.01 for readable JSON
+func durationMs(d time.Duration) float64 {
+	return math.Round(float64(d.Microseconds())/10) / 100
+}
+
+// latencyStatsHandler handles GET /api/stats/latency (?window=1m) and
+// DELETE to start over, e.g. between two demo runs
+func latencyStatsHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodDelete:
+		latencyStats.reset()
+		w.WriteHeader(http.StatusNoContent)
+		return
+	default:
+		http.Error(w, `{"error":"method not allowed"}`, http.StatusMethodNotAllowed)
+		return
+	}
+
+	window := latencyStatsWindow
+	if raw := r.URL.Query().Get("window"); raw != "" {
+		d, err := time.ParseDuration(raw)
+		if err != nil || d <= 0 || d > latencyStatsWindow {
+			writeJSONError(w, http.StatusBadRequest, "window must be a duration up to "+latencyStatsWindow.String())
+			return
+		}
+		window = d
+	}
+
+	json.NewEncoder(w).Encode(map[string]any{
+		"window": window.String(),
+		"routes": latencyStats.summary(window),
+	})
+}
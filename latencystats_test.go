@@ -0,0 +1,117 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestPercentile(t *testing.T) {
+	var sorted []time.Duration
+	for i := 1; i <= 100; i++ {
+		sorted = append(sorted, time.Duration(i)*time.Millisecond)
+	}
+	tests := []struct {
+		p    float64
+		want time.Duration
+	}{
+		{50, 50 * time.Millisecond},
+		{90, 90 * time.Millisecond},
+		{99, 99 * time.Millisecond},
+		{100, 100 * time.Millisecond},
+		{0, time.Millisecond},
+	}
+	for _, tt := range tests {
+		if got := percentile(sorted, tt.p); got != tt.want {
+			t.Errorf("p%v = %v, want %v", tt.p, got, tt.want)
+		}
+	}
+	if got := percentile([]time.Duration{7}, 99); got != 7 {
+		t.Errorf("single sample p99 = %v", got)
+	}
+}
+
+func TestLatencyRecorder(t *testing.T) {
+	l := &latencyRecorder{routes: map[[2]string]*latencyRoute{}}
+	for i := 1; i <= 10; i++ {
+		l.record("GET", "/api/items", time.Duration(i)*time.Millisecond)
+	}
+	l.record("POST", "/api/items", 5*time.Millisecond)
+
+	got := l.summary(time.Minute)
+	if len(got) != 2 {
+		t.Fatalf("routes = %+v", got)
+	}
+	want := RouteLatency{Method: "GET", Path: "/api/items", Count: 10, P50Ms: 5, P90Ms: 9, P99Ms: 10, MaxMs: 10}
+	if got[0] != want {
+		t.Errorf("GET summary = %+v, want %+v", got[0], want)
+	}
+
+	// Samples outside the window don't count
+	l.routes[[2]string{"GET", "/api/items"}].samples[0].at = time.Now().Add(-time.Hour)
+	if got := l.summary(time.Minute); got[0].Count != 9 {
+		t.Errorf("count after aging one sample = %d, want 9", got[0].Count)
+	}
+
+	// The ring keeps only the newest samples
+	for i := 0; i < maxLatencySamples+5; i++ {
+		l.record("PUT", "/api/items/:id", time.Millisecond)
+	}
+	if n := len(l.routes[[2]string{"PUT", "/api/items/:id"}].samples); n != maxLatencySamples {
+		t.Errorf("samples kept = %d, want %d", n, maxLatencySamples)
+	}
+
+	// Past the route cap, new paths share one "other" entry
+	for i := 0; i < maxLatencyRoutes+10; i++ {
+		l.record("GET", fmt.Sprintf("/nope/%d", i), time.Millisecond)
+	}
+	if len(l.routes) > maxLatencyRoutes+1 {
+		t.Errorf("routes = %d, want capped", len(l.routes))
+	}
+	if _, ok := l.routes[[2]string{"GET", "other"}]; !ok {
+		t.Error("no \"other\" route after the cap")
+	}
+}
+
+func TestLatencyStatsHandler(t *testing.T) {
+	srv := newTestServer(t)
+	latencyStats.reset()
+	t.Cleanup(latencyStats.reset)
+
+	for range 3 {
+		doRequest(t, srv, http.MethodGet, "/api/items", "")
+	}
+	doRequest(t, srv, http.MethodGet, "/api/items/5", "")
+
+	code, body := doRequest(t, srv, http.MethodGet, "/api/stats/latency?window=1m", "")
+	if code != http.StatusOK {
+		t.Fatalf("status = %d %s", code, body)
+	}
+	var result struct {
+		Window string         `json:"window"`
+		Routes []RouteLatency `json:"routes"`
+	}
+	json.Unmarshal(body, &result)
+	counts := map[string]int{}
+	for _, route := range result.Routes {
+		counts[route.Method+" "+route.Path] = route.Count
+	}
+	if result.Window != "1m0s" || counts["GET /api/items"] != 3 || counts["GET /api/items/:id"] != 1 {
+		t.Errorf("result = %s", body)
+	}
+
+	if code, _ := doRequest(t, srv, http.MethodDelete, "/api/stats/latency", ""); code != http.StatusNoContent {
+		t.Errorf("DELETE = %d, want 204", code)
+	}
+	if len(latencyStats.summary(time.Minute)) > 1 { // only the DELETE itself may be recorded
+		t.Errorf("after reset = %+v", latencyStats.summary(time.Minute))
+	}
+
+	for _, window := range []string{"abc", "-1s", "24h"} {
+		if code, _ := doRequest(t, srv, http.MethodGet, "/api/stats/latency?window="+window, ""); code != http.StatusBadRequest {
+			t.Errorf("window=%s status = %d, want 400", window, code)
+		}
+	}
+}
@@ -0,0 +1,337 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	badger "github.com/dgraph-io/badger/v4"
+)
+
+// =============================================================================
+// Item Links
+// =============================================================================
+//
+// Items can point at each other with a typed link, which turns the item list
+// into a small graph — "web-1 depends_on db-1", "v2 replaces v1":
+//
+//	POST   /api/items/1/links   {"target_id": 2, "type": "depends_on"}
+//	GET    /api/items/1/links                 -> links from AND to item 1
+//	GET    /api/items/2/links?direction=in    -> only links pointing at item 2
+//	DELETE /api/items/1/links?target_id=2&type=depends_on
+//
+// A key-value store has no foreign keys or JOINs, so each link is stored
+// twice with composite keys, once per direction:
+//
+//	link:out:00000000000000000001:depends_on:00000000000000000002
+//	link:in:00000000000000000002:depends_on:00000000000000000001
+//
+// A prefix scan over "link:out:<id>:" finds everything an item points at,
+// "link:in:<id>:" everything pointing at it — both without reading other
+// items. Both keys are written in one transaction. Deleting an item removes
+// its links in both directions in the same transaction as the item itself
+// (what ON DELETE CASCADE does in SQL), so no link outlives an endpoint.
+
+// Key prefixes for the two halves of a link
+const (
+	linkKeyPrefix    = "link:"
+	linkOutKeyPrefix = linkKeyPrefix + "out:"
+	linkInKeyPrefix  = linkKeyPrefix + "in:"
+)
+
+// Used when a link is created without a type
+const defaultLinkType = "related"
+
+// Longest link type accepted
+const maxLinkTypeLength = 64
+
+// Link is one directed link between two items
+type Link struct {
+	SourceID  int64     `json:"source_id"`
+	TargetID  int64     `json:"target_id"`
+	Type      string    `json:"type"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// linkView is a Link as seen from one item: outgoing (it's the source) or
+// incoming (it's the target)
+type linkView struct {
+	Link
+	Direction string `json:"direction"`
+}
+
+// linkInput is the request body for POST /api/items/:id/links
+type linkInput struct {
+	TargetID *int64 `json:"target_id"` // pointer: 0 is a valid item ID
+	Type     string `json:"type"`
+}
+
+// Errors from createLink the handler maps to status codes
+var (
+	errLinkTargetNotFound = errors.New("target item not found")
+	errLinkExists         = errors.New("link already exists")
+)
+
+// normalizeLinkType lowercases a link type and checks it's safe to use in a
+// key: letters, digits, '-' and '_' only
+func normalizeLinkType(linkType string) (string, error) {
+	linkType = strings.ToLower(strings.TrimSpace(linkType))
+	if linkType == "" {
+		return defaultLinkType, nil
+	}
+	if len(linkType) > maxLinkTypeLength {
+		return "", fmt.Errorf("must be at most %d characters", maxLinkTypeLength)
+	}
+	for _, c := range linkType {
+		if !(c >= 'a' && c <= 'z' || c >= '0' && c <= '9' || c == '-' || c == '_') {
+			return "", errors.New("must only contain letters, digits, '-' and '_'")
+		}
+	}
+	return linkType, nil
+}
+
+// linkOutKey and linkInKey build the two keys for one link
+func linkOutKey(source int64, linkType string, target int64) []byte {
+	return []byte(fmt.Sprintf("%s%0*d:%s:%0*d", linkOutKeyPrefix, itemKeyDigits, source, linkType, itemKeyDigits, target))
+}
+
+func linkInKey(target int64, linkType string, source int64) []byte {
+	return []byte(fmt.Sprintf("%s%0*d:%s:%0*d", linkInKeyPrefix, itemKeyDigits, target, linkType, itemKeyDigits, source))
+}
+
+// linkScanPrefix is the prefix of every key for links in one direction of an item
+func linkScanPrefix(directionPrefix string, id int64) []byte {
+	return []byte(fmt.Sprintf("%s%0*d:", directionPrefix, itemKeyDigits, id))
+}
+
+// createLink stores a link from source to target. Both items must exist.
+func createLink(source, target int64, linkType string) (Link, error) {
+	link := Link{SourceID: source, TargetID: target, Type: linkType, CreatedAt: time.Now().UTC()}
+	value, err := json.Marshal(link)
+	if err != nil {
+		return Link{}, err
+	}
+
+	outKey := linkOutKey(source, linkType, target)
+	err = dbUpdate("link_create", string(outKey), func(txn *badger.Txn) error {
+		if _, err := txn.Get(itemKey(source)); err != nil {
+			return err // ErrKeyNotFound -> 404 for the item in the URL
+		}
+		if _, err := txn.Get(itemKey(target)); errors.Is(err, badger.ErrKeyNotFound) {
+			return errLinkTargetNotFound
+		} else if err != nil {
+			return err
+		}
+
+		if _, err := txn.Get(outKey); err == nil {
+			return errLinkExists
+		} else if !errors.Is(err, badger.ErrKeyNotFound) {
+			return err
+		}
+
+		if err := txn.Set(outKey, value); err != nil {
+			return err
+		}
+		return txn.Set(linkInKey(target, linkType, source), value)
+	})
+	if err != nil {
+		return Link{}, err
+	}
+	return link, nil
+}
+
+// deleteLink removes one link. found is false if there was no such link.
+func deleteLink(source, target int64, linkType string) (found bool, err error) {
+	outKey := linkOutKey(source, linkType, target)
+	err = dbUpdate("link_delete", string(outKey), func(txn *badger.Txn) error {
+		if _, err := txn.Get(outKey); errors.Is(err, badger.ErrKeyNotFound) {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		found = true
+		if err := txn.Delete(outKey); err != nil {
+			return err
+		}
+		return txn.Delete(linkInKey(target, linkType, source))
+	})
+	return found, err
+}
+
+// listLinks returns an item's links: outgoing first, then incoming, each in
+// key order (by type, then other item's ID). direction is "", "out", or "in";
+// linkType "" means every type.
+func listLinks(txn *badger.Txn, id int64, direction, linkType string) ([]linkView, error) {
+	links := []linkView{}
+	scans := []struct {
+		prefix    string
+		direction string
+	}{
+		{linkOutKeyPrefix, "outgoing"},
+		{linkInKeyPrefix, "incoming"},
+	}
+	for _, scan := range scans {
+		if direction != "" && !strings.HasPrefix(scan.direction, direction) {
+			continue
+		}
+
+		prefix := linkScanPrefix(scan.prefix, id)
+		if linkType != "" {
+			prefix = append(prefix, linkType+":"...)
+		}
+
+		it := txn.NewIterator(badger.DefaultIteratorOptions)
+		for it.Seek(prefix); it.ValidForPrefix(prefix); it.Next() {
+			var link Link
+			err := it.Item().Value(func(val []byte) error { return json.Unmarshal(val, &link) })
+			if err != nil {
+				it.Close()
+				return nil, err
+			}
+			links = append(links, linkView{Link: link, Direction: scan.direction})
+		}
+		it.Close()
+	}
+	return links, nil
+}
+
+// deleteItemLinks removes every link from or to an item. Runs inside the
+// item's delete transaction (removeItem in store.go).
+func deleteItemLinks(txn *badger.Txn, id int64) error {
+	links, err := listLinks(txn, id, "", "")
+	if err != nil {
+		return err
+	}
+	// Collected first: deleting while an iterator is open is asking for trouble
+	for _, link := range links {
+		if err := txn.Delete(linkOutKey(link.SourceID, link.Type, link.TargetID)); err != nil {
+			return err
+		}
+		if err := txn.Delete(linkInKey(link.TargetID, link.Type, link.SourceID)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// linksHandler handles /api/items/:id/links
+func linksHandler(w http.ResponseWriter, r *http.Request, id int64) {
+	switch r.Method {
+	case http.MethodGet:
+		getLinks(w, r, id)
+	case http.MethodPost:
+		postLink(w, r, id)
+	case http.MethodDelete:
+		removeLink(w, r, id)
+	default:
+		http.Error(w, `{"error":"method not allowed"}`, http.StatusMethodNotAllowed)
+	}
+}
+
+// getLinks lists an item's links (?direction=out|in, ?type=depends_on)
+func getLinks(w http.ResponseWriter, r *http.Request, id int64) {
+	query := r.URL.Query()
+	direction := query.Get("direction")
+	if direction != "" && direction != "out" && direction != "in" {
+		http.Error(w, `{"error":"direction must be out or in"}`, http.StatusBadRequest)
+		return
+	}
+	var linkType string
+	if raw := query.Get("type"); raw != "" {
+		var err error
+		if linkType, err = normalizeLinkType(raw); err != nil {
+			writeJSONError(w, http.StatusBadRequest, "type "+err.Error())
+			return
+		}
+	}
+
+	var links []linkView
+	err := dbView("link_list", string(linkScanPrefix(linkOutKeyPrefix, id)), func(txn *badger.Txn) error {
+		if _, err := txn.Get(itemKey(id)); err != nil {
+			return err
+		}
+		var err error
+		links, err = listLinks(txn, id, direction, linkType)
+		return err
+	})
+	if errors.Is(err, badger.ErrKeyNotFound) {
+		http.Error(w, `{"error":"not found"}`, http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		logHandlerError(r.Context(), "links", "database", "failed to list links", "error", err)
+		http.Error(w, `{"error":"database error"}`, http.StatusInternalServerError)
+		return
+	}
+	json.NewEncoder(w).Encode(links)
+}
+
+// postLink creates a link from the item in the URL to target_id
+func postLink(w http.ResponseWriter, r *http.Request, id int64) {
+	var input linkInput
+	if !decodeJSONBody(w, r, &input) { // strictjson.go
+		return
+	}
+
+	var errs []FieldError
+	if input.TargetID == nil {
+		errs = append(errs, FieldError{"target_id", "is required"})
+	} else if *input.TargetID == id {
+		errs = append(errs, FieldError{"target_id", "an item can't link to itself"})
+	}
+	linkType, err := normalizeLinkType(input.Type)
+	if err != nil {
+		errs = append(errs, FieldError{"type", err.Error()})
+	}
+	if len(errs) > 0 {
+		writeValidationErrors(w, errs)
+		return
+	}
+
+	link, err := createLink(id, *input.TargetID, linkType)
+	switch {
+	case errors.Is(err, badger.ErrKeyNotFound):
+		http.Error(w, `{"error":"not found"}`, http.StatusNotFound)
+	case errors.Is(err, errLinkTargetNotFound):
+		writeValidationErrors(w, []FieldError{{"target_id", err.Error()}})
+	case errors.Is(err, errLinkExists):
+		writeJSONError(w, http.StatusConflict, err.Error())
+	case err != nil:
+		logHandlerError(r.Context(), "links", "database", "failed to create link", "error", err)
+		http.Error(w, `{"error":"database error"}`, http.StatusInternalServerError)
+	default:
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(link)
+	}
+}
+
+// removeLink deletes one outgoing link (?target_id=2&type=depends_on)
+func removeLink(w http.ResponseWriter, r *http.Request, id int64) {
+	query := r.URL.Query()
+	target, err := strconv.ParseInt(query.Get("target_id"), 10, 64)
+	if err != nil {
+		http.Error(w, `{"error":"target_id is required"}`, http.StatusBadRequest)
+		return
+	}
+	linkType, err := normalizeLinkType(query.Get("type"))
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, "type "+err.Error())
+		return
+	}
+
+	found, err := deleteLink(id, target, linkType)
+	if err != nil {
+		logHandlerError(r.Context(), "links", "database", "failed to delete link", "error", err)
+		http.Error(w, `{"error":"database error"}`, http.StatusInternalServerError)
+		return
+	}
+	if !found {
+		http.Error(w, `{"error":"not found"}`, http.StatusNotFound)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestLinks_CreateAndQueryBothDirections(t *testing.T) {
+	srv := newTestServer(t)
+	web := createTestItem(t, srv, `{"name":"web"}`)
+	db := createTestItem(t, srv, `{"name":"db"}`)
+	cache := createTestItem(t, srv, `{"name":"cache"}`)
+
+	code, body := doRequest(t, srv, http.MethodPost, fmt.Sprintf("/api/items/%d/links", web.ID),
+		fmt.Sprintf(`{"target_id":%d,"type":"Depends_On"}`, db.ID))
+	if code != http.StatusCreated {
+		t.Fatalf("create link = %d %s", code, body)
+	}
+	var link Link
+	json.Unmarshal(body, &link)
+	if link.SourceID != web.ID || link.TargetID != db.ID || link.Type != "depends_on" {
+		t.Errorf("link = %+v", link)
+	}
+	// No type: "related"
+	doRequest(t, srv, http.MethodPost, fmt.Sprintf("/api/items/%d/links", cache.ID), fmt.Sprintf(`{"target_id":%d}`, web.ID))
+
+	links := func(path string) []linkView {
+		t.Helper()
+		code, body := doRequest(t, srv, http.MethodGet, path, "")
+		if code != http.StatusOK {
+			t.Fatalf("GET %s = %d %s", path, code, body)
+		}
+		var views []linkView
+		json.Unmarshal(body, &views)
+		return views
+	}
+
+	got := links(fmt.Sprintf("/api/items/%d/links", web.ID))
+	if len(got) != 2 ||
+		got[0].Direction != "outgoing" || got[0].TargetID != db.ID ||
+		got[1].Direction != "incoming" || got[1].SourceID != cache.ID || got[1].Type != "related" {
+		t.Errorf("web links = %+v", got)
+	}
+	if got := links(fmt.Sprintf("/api/items/%d/links?direction=in", db.ID)); len(got) != 1 || got[0].SourceID != web.ID {
+		t.Errorf("db incoming = %+v", got)
+	}
+	if got := links(fmt.Sprintf("/api/items/%d/links?type=related", web.ID)); len(got) != 1 || got[0].SourceID != cache.ID {
+		t.Errorf("web related = %+v", got)
+	}
+
+	// Removing the link clears both directions
+	path := fmt.Sprintf("/api/items/%d/links?target_id=%d&type=depends_on", web.ID, db.ID)
+	if code, _ := doRequest(t, srv, http.MethodDelete, path, ""); code != http.StatusNoContent {
+		t.Fatalf("delete link = %d, want 204", code)
+	}
+	if got := links(fmt.Sprintf("/api/items/%d/links", db.ID)); len(got) != 0 {
+		t.Errorf("db links after delete = %+v", got)
+	}
+}
+
+func TestLinks_RemovedWithItem(t *testing.T) {
+	srv := newTestServer(t)
+	a := createTestItem(t, srv, `{"name":"a"}`)
+	b := createTestItem(t, srv, `{"name":"b"}`)
+	c := createTestItem(t, srv, `{"name":"c"}`)
+
+	doRequest(t, srv, http.MethodPost, fmt.Sprintf("/api/items/%d/links", a.ID), fmt.Sprintf(`{"target_id":%d}`, b.ID))
+	doRequest(t, srv, http.MethodPost, fmt.Sprintf("/api/items/%d/links", c.ID), fmt.Sprintf(`{"target_id":%d}`, b.ID))
+	doRequest(t, srv, http.MethodPost, fmt.Sprintf("/api/items/%d/links", b.ID), fmt.Sprintf(`{"target_id":%d,"type":"replaces"}`, a.ID))
+
+	doRequest(t, srv, http.MethodDelete, fmt.Sprintf("/api/items/%d", b.ID), "")
+
+	for _, item := range []Item{a, c} {
+		_, body := doRequest(t, srv, http.MethodGet, fmt.Sprintf("/api/items/%d/links", item.ID), "")
+		if strings.TrimSpace(string(body)) != "[]" {
+			t.Errorf("item %d links after deleting b = %s, want []", item.ID, body)
+		}
+	}
+}
+
+func TestLinks_Errors(t *testing.T) {
+	srv := newTestServer(t)
+	a := createTestItem(t, srv, `{"name":"a"}`)
+	b := createTestItem(t, srv, `{"name":"b"}`)
+	path := fmt.Sprintf("/api/items/%d/links", a.ID)
+	doRequest(t, srv, http.MethodPost, path, fmt.Sprintf(`{"target_id":%d}`, b.ID))
+
+	tests := []struct {
+		name       string
+		method     string
+		path       string
+		body       string
+		wantStatus int
+	}{
+		{"missing target_id", http.MethodPost, path, `{"type":"x"}`, http.StatusUnprocessableEntity},
+		{"link to itself", http.MethodPost, path, fmt.Sprintf(`{"target_id":%d}`, a.ID), http.StatusUnprocessableEntity},
+		{"invalid type", http.MethodPost, path, fmt.Sprintf(`{"target_id":%d,"type":"a:b"}`, b.ID), http.StatusUnprocessableEntity},
+		{"unknown target", http.MethodPost, path, `{"target_id":999}`, http.StatusUnprocessableEntity},
+		{"duplicate", http.MethodPost, path, fmt.Sprintf(`{"target_id":%d}`, b.ID), http.StatusConflict},
+		{"unknown source", http.MethodPost, "/api/items/999/links", fmt.Sprintf(`{"target_id":%d}`, b.ID), http.StatusNotFound},
+		{"list unknown item", http.MethodGet, "/api/items/999/links", "", http.StatusNotFound},
+		{"bad direction", http.MethodGet, path + "?direction=sideways", "", http.StatusBadRequest},
+		{"delete missing link", http.MethodDelete, path + "?target_id=999", "", http.StatusNotFound},
+		{"delete without target", http.MethodDelete, path, "", http.StatusBadRequest},
+		{"invalid json", http.MethodPost, path, `{`, http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, body := doRequest(t, srv, tt.method, tt.path, tt.body)
+			if code != tt.wantStatus {
+				t.Errorf("status = %d, want %d (body %s)", code, tt.wantStatus, body)
+			}
+		})
+	}
+}
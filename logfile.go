@@ -0,0 +1,113 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sync"
+)
+
+// =============================================================================
+// Log File Output with Rotation
+// =============================================================================
+//
+// On a plain VM there may be no log collector scraping stdout, so once the
+// terminal scrolls away the history is gone. With LOG_FILE set, every log
+// line (request logs included) is also appended to that file.
+//
+// To keep the file from filling the disk it's rotated by size, the same
+// scheme logrotate uses:
+//
+//	demo-app.log      <- current file
+//	demo-app.log.1    <- previous
+//	demo-app.log.2    <- older
+//	...
+//	demo-app.log.N    <- oldest kept (N = LOG_MAX_BACKUPS), deleted on next rotation
+
+// rotatingFile is an io.Writer that appends to a file and rotates it
+// once it grows past maxSize bytes.
+type rotatingFile struct {
+	mu         sync.Mutex
+	path       string
+	maxSize    int64 // rotate when a write would exceed this many bytes
+	maxBackups int   // how many old files to keep (0 = just truncate)
+	file       *os.File
+	size       int64 // current size of file
+}
+
+// newRotatingFile opens (or creates) path for appending
+func newRotatingFile(path string, maxSize int64, maxBackups int) (*rotatingFile, error) {
+	r := &rotatingFile{path: path, maxSize: maxSize, maxBackups: maxBackups}
+	if err := r.open(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// open opens the current log file, picking up its existing size so a
+// restart keeps appending rather than rotating immediately
+func (r *rotatingFile) open() error {
+	f, err := os.OpenFile(r.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+	stat, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+	r.file = f
+	r.size = stat.Size()
+	return nil
+}
+
+// Write implements io.Writer. slog handlers call it once per log line,
+// so a single line is never split across two files.
+func (r *rotatingFile) Write(p []byte) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.size > 0 && r.size+int64(len(p)) > r.maxSize {
+		if err := r.rotate(); err != nil {
+			return 0, err
+		}
+	}
+
+	n, err := r.file.Write(p)
+	r.size += int64(n)
+	return n, err
+}
+
+// rotate shifts path.N-1 -> path.N, ..., path -> path.1 and starts a new file.
+// Caller must hold r.mu.
+func (r *rotatingFile) rotate() error {
+	if err := r.file.Close(); err != nil {
+		return err
+	}
+
+	if r.maxBackups > 0 {
+		// Errors are ignored: a backup may not exist yet
+		os.Remove(r.backupName(r.maxBackups))
+		for i := r.maxBackups - 1; i >= 1; i-- {
+			os.Rename(r.backupName(i), r.backupName(i+1))
+		}
+		if err := os.Rename(r.path, r.backupName(1)); err != nil {
+			return err
+		}
+	} else if err := os.Remove(r.path); err != nil {
+		return err
+	}
+
+	return r.open()
+}
+
+// backupName returns the path of the nth backup (demo-app.log.n)
+func (r *rotatingFile) backupName(n int) string {
+	return fmt.Sprintf("%s.%d", r.path, n)
+}
+
+// Close closes the current file
+func (r *rotatingFile) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.file.Close()
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestRotatingFile_RotatesBySize(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "demo-app.log")
+	r, err := newRotatingFile(path, 10, 2)
+	if err != nil {
+		t.Fatalf("failed to open: %v", err)
+	}
+	defer r.Close()
+
+	// Each write is 6 bytes, so every second write rotates
+	for _, line := range []string{"aaaaa\n", "bbbbb\n", "ccccc\n", "ddddd\n"} {
+		if _, err := r.Write([]byte(line)); err != nil {
+			t.Fatalf("write failed: %v", err)
+		}
+	}
+
+	want := map[string]string{
+		path:        "ddddd\n",
+		path + ".1": "ccccc\n",
+		path + ".2": "bbbbb\n",
+	}
+	for file, content := range want {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			t.Fatalf("expected %s to exist: %v", file, err)
+		}
+		if string(data) != content {
+			t.Errorf("%s: expected %q, got %q", file, content, data)
+		}
+	}
+
+	// Only LOG_MAX_BACKUPS backups are kept; the oldest ("aaaaa") is gone
+	if _, err := os.Stat(path + ".3"); !os.IsNotExist(err) {
+		t.Errorf("expected %s.3 not to exist", path)
+	}
+}
+
+func TestRotatingFile_AppendsOnReopen(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "demo-app.log")
+	os.WriteFile(path, []byte("old\n"), 0o644)
+
+	r, err := newRotatingFile(path, 100, 1)
+	if err != nil {
+		t.Fatalf("failed to open: %v", err)
+	}
+	r.Write([]byte("new\n"))
+	r.Close()
+
+	data, _ := os.ReadFile(path)
+	if string(data) != "old\nnew\n" {
+		t.Errorf("expected append to existing file, got %q", data)
+	}
+}
+
+func TestRotatingFile_NoBackups(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "demo-app.log")
+	r, err := newRotatingFile(path, 5, 0)
+	if err != nil {
+		t.Fatalf("failed to open: %v", err)
+	}
+	defer r.Close()
+
+	r.Write([]byte("first\n"))
+	r.Write([]byte("second\n"))
+
+	data, _ := os.ReadFile(path)
+	if string(data) != "second\n" {
+		t.Errorf("expected file to start over, got %q", data)
+	}
+	if _, err := os.Stat(path + ".1"); !os.IsNotExist(err) {
+		t.Error("expected no backup file with LOG_MAX_BACKUPS=0")
+	}
+}
@@ -0,0 +1,203 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// =============================================================================
+// Log Formats
+// =============================================================================
+//
+// LOG_FORMAT picks how log lines look:
+//
+//	json    {"time":"...","level":"INFO","msg":"request","method":"GET",...}   (default)
+//	logfmt  time=... level=INFO msg=request method=GET ...
+//	pretty  10:30:00.123 INFO  request  method=GET path=/api/items status=200
+//
+// json is what log aggregators want. pretty is for humans: local development
+// and workshops, where a wall of JSON loses the audience. It colors the level
+// (set NO_COLOR=1 to turn colors off, see https://no-color.org).
+//
+// logfmt and json come from the standard library. pretty is a small custom
+// slog.Handler below (see webhook.go for a walkthrough of the interface).
+//
+// LOG_LEVEL (debug, info, warn, or error; default info) drops anything
+// quieter. It can be changed while the app runs through CONFIG_FILE
+// (configwatch.go), e.g. to turn on debug logs for a minute.
+
+// Minimum level for every format. A LevelVar, so changing it takes effect
+// on the next log line without rebuilding the handlers.
+var logLevel = new(slog.LevelVar)
+
+// parseLogLevel reads a LOG_LEVEL value: debug, info, warn, or error, in
+// any case ("" is info)
+func parseLogLevel(s string) (slog.Level, error) {
+	if s == "" {
+		return slog.LevelInfo, nil
+	}
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(s)); err != nil {
+		return 0, fmt.Errorf("unknown LOG_LEVEL %q (use debug, info, warn, or error)", s)
+	}
+	return level, nil
+}
+
+// newLogHandler returns the slog.Handler for format, writing to w.
+// Unknown formats return an error along with a JSON handler to fall back on.
+func newLogHandler(format string, w io.Writer) (slog.Handler, error) {
+	opts := &slog.HandlerOptions{Level: logLevel}
+	switch format {
+	case "", "json":
+		return slog.NewJSONHandler(w, opts), nil
+	case "logfmt", "text":
+		// slog's TextHandler writes logfmt-style key=value pairs
+		return slog.NewTextHandler(w, opts), nil
+	case "pretty":
+		return newPrettyHandler(w, os.Getenv("NO_COLOR") == ""), nil
+	default:
+		return slog.NewJSONHandler(w, opts), fmt.Errorf("unknown LOG_FORMAT %q (use json, logfmt, or pretty)", format)
+	}
+}
+
+// ANSI escape codes for terminal colors
+const (
+	ansiReset  = "\033[0m"
+	ansiDim    = "\033[2m"
+	ansiRed    = "\033[31m"
+	ansiGreen  = "\033[32m"
+	ansiYellow = "\033[33m"
+	ansiBlue   = "\033[34m"
+)
+
+// prettyHandler writes one human-friendly line per record
+type prettyHandler struct {
+	w     io.Writer
+	mu    *sync.Mutex // shared by WithAttrs/WithGroup copies so lines don't interleave
+	color bool
+
+	attrs  string // attributes added with logger.With, already formatted
+	prefix string // group prefix for keys, e.g. "request."
+}
+
+// newPrettyHandler creates a pretty console handler
+func newPrettyHandler(w io.Writer, color bool) *prettyHandler {
+	return &prettyHandler{w: w, mu: &sync.Mutex{}, color: color}
+}
+
+// Enabled logs LOG_LEVEL and above, like the standard handlers
+func (h *prettyHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return level >= logLevel.Level()
+}
+
+// Handle formats and writes the record:
+//
+//	10:30:00.123 INFO  request  method=GET path=/api/items
+func (h *prettyHandler) Handle(ctx context.Context, record slog.Record) error {
+	var b strings.Builder
+
+	b.WriteString(h.paint(ansiDim, record.Time.Format("15:04:05.000")))
+	b.WriteByte(' ')
+	b.WriteString(h.paint(levelColor(record.Level), fmt.Sprintf("%-5s", record.Level.String())))
+	b.WriteByte(' ')
+	b.WriteString(record.Message)
+
+	b.WriteString(h.attrs)
+	record.Attrs(func(attr slog.Attr) bool {
+		h.appendAttr(&b, h.prefix, attr)
+		return true
+	})
+	b.WriteByte('\n')
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	_, err := io.WriteString(h.w, b.String())
+	return err
+}
+
+// WithAttrs formats the attributes once, up front
+func (h *prettyHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	var b strings.Builder
+	for _, attr := range attrs {
+		h.appendAttr(&b, h.prefix, attr)
+	}
+	clone := *h
+	clone.attrs += b.String()
+	return &clone
+}
+
+// WithGroup prefixes later keys with "name."
+func (h *prettyHandler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	clone := *h
+	clone.prefix += name + "."
+	return &clone
+}
+
+// appendAttr writes " key=value", flattening groups into dotted keys
+func (h *prettyHandler) appendAttr(b *strings.Builder, prefix string, attr slog.Attr) {
+	attr.Value = attr.Value.Resolve()
+	if attr.Equal(slog.Attr{}) {
+		return // slog convention: empty attrs are ignored
+	}
+
+	if attr.Value.Kind() == slog.KindGroup {
+		if attr.Key != "" {
+			prefix += attr.Key + "."
+		}
+		for _, a := range attr.Value.Group() {
+			h.appendAttr(b, prefix, a)
+		}
+		return
+	}
+
+	b.WriteByte(' ')
+	b.WriteString(h.paint(ansiDim, prefix+attr.Key+"="))
+	b.WriteString(prettyValue(attr.Value))
+}
+
+// prettyValue quotes strings only when needed to keep them readable
+func prettyValue(v slog.Value) string {
+	var s string
+	switch v.Kind() {
+	case slog.KindTime:
+		s = v.Time().Format(time.RFC3339)
+	default:
+		s = v.String()
+	}
+	if s == "" || strings.ContainsAny(s, " \t\n\"=") {
+		return strconv.Quote(s)
+	}
+	return s
+}
+
+// levelColor picks a color per level, like most CLI tools do
+func levelColor(level slog.Level) string {
+	switch {
+	case level >= slog.LevelError:
+		return ansiRed
+	case level >= slog.LevelWarn:
+		return ansiYellow
+	case level >= slog.LevelInfo:
+		return ansiGreen
+	default:
+		return ansiBlue
+	}
+}
+
+// paint wraps s in a color code (when colors are on)
+func (h *prettyHandler) paint(color, s string) string {
+	if !h.color {
+		return s
+	}
+	return color + s + ansiReset
+}
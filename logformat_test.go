@@ -0,0 +1,97 @@
+package main
+
+import (
+	"bytes"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func TestNewLogHandler_Formats(t *testing.T) {
+	t.Setenv("NO_COLOR", "1")
+
+	tests := []struct {
+		format  string
+		want    string
+		wantErr bool
+	}{
+		{"", `"msg":"hello"`, false},
+		{"json", `"msg":"hello"`, false},
+		{"logfmt", "msg=hello", false},
+		{"pretty", "INFO  hello", false},
+		{"yaml", `"msg":"hello"`, true}, // falls back to JSON
+	}
+
+	for _, tt := range tests {
+		var buf bytes.Buffer
+		handler, err := newLogHandler(tt.format, &buf)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%q: expected error=%v, got %v", tt.format, tt.wantErr, err)
+		}
+		slog.New(handler).Info("hello")
+		if !strings.Contains(buf.String(), tt.want) {
+			t.Errorf("%q: expected %q in %q", tt.format, tt.want, buf.String())
+		}
+	}
+}
+
+func TestPrettyHandler_Attrs(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(newPrettyHandler(&buf, false)).
+		With("component", "api").
+		WithGroup("req")
+
+	logger.Warn("slow request", "path", "/api/items", "note", "took a while", slog.Group("db", "op", "item_list"))
+
+	line := buf.String()
+	for _, want := range []string{
+		"WARN  slow request",
+		" component=api",
+		" req.path=/api/items",
+		` req.note="took a while"`,
+		" req.db.op=item_list",
+	} {
+		if !strings.Contains(line, want) {
+			t.Errorf("expected %q in %q", want, line)
+		}
+	}
+	if strings.Contains(line, "\033[") {
+		t.Errorf("expected no color codes with color off, got %q", line)
+	}
+}
+
+func TestPrettyHandler_Color(t *testing.T) {
+	var buf bytes.Buffer
+	slog.New(newPrettyHandler(&buf, true)).Error("boom")
+
+	if !strings.Contains(buf.String(), ansiRed+"ERROR"+ansiReset) {
+		t.Errorf("expected red ERROR level, got %q", buf.String())
+	}
+}
+
+func TestLogLevel(t *testing.T) {
+	previous := logLevel.Level()
+	t.Cleanup(func() { logLevel.Set(previous) })
+
+	for _, s := range []string{"", "info", "INFO"} {
+		if level, err := parseLogLevel(s); err != nil || level != slog.LevelInfo {
+			t.Errorf("%q: expected INFO, got %v, %v", s, level, err)
+		}
+	}
+	if _, err := parseLogLevel("loud"); err == nil {
+		t.Error("expected an error for an unknown level")
+	}
+
+	// Every format follows the shared level
+	logLevel.Set(slog.LevelWarn)
+	for _, format := range []string{"json", "logfmt", "pretty"} {
+		var buf bytes.Buffer
+		handler, _ := newLogHandler(format, &buf)
+		logger := slog.New(handler)
+		logger.Info("quiet")
+		logger.Warn("loud")
+		if strings.Contains(buf.String(), "quiet") || !strings.Contains(buf.String(), "loud") {
+			t.Errorf("%s: expected only the WARN line, got %q", format, buf.String())
+		}
+	}
+}
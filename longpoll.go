@@ -0,0 +1,136 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// =============================================================================
+// Long Polling
+// =============================================================================
+//
+// Live updates without WebSockets, for demo networks where proxies or
+// firewalls break them: ask for the data "once it's newer than what I have"
+// and the server holds the request open until it is.
+//
+//	curl -i http://localhost:8080/api/items            # X-Items-Version: 1287
+//	curl 'http://localhost:8080/api/items?wait=30s&since_version=1287'
+//	# ...answers as soon as an item changes, or after 30s with the same list
+//
+//	curl -i http://localhost:8080/api/display          # X-Display-Version: 1718...
+//	curl 'http://localhost:8080/api/display?wait=30s&since_version=1718...'
+//
+// Every answer carries the current version, so a client loops: send the
+// version it got last, get the next one. Without since_version the request
+// waits for the next change after it arrived. wait is capped at a minute;
+// a timeout is a normal 200, and for the item list an If-None-Match still
+// turns it into a 304. A wait longer than the request's deadline
+// (REQUEST_TIMEOUT, timeout.go) ends a second before it.
+//
+// Waiting requests check the version every 100ms (one small key read)
+// rather than subscribing to changes, so every writer counts: handlers,
+// jobs, and replication from the leader. They do count against
+// MAX_INFLIGHT_REQUESTS while they wait.
+
+// Longest ?wait= accepted
+const maxLongPollWait = time.Minute
+
+// How often a waiting request checks the version
+const longPollInterval = 100 * time.Millisecond
+
+// How long before the request's deadline (timeout.go) a long poll answers
+const longPollDeadlineMargin = time.Second
+
+// Response header with the item list's version (the display panel's is
+// X-Display-Version, displaysync.go)
+const itemsVersionHeader = "X-Items-Version"
+
+// parseLongPoll reads ?wait= and ?since_version=. A zero wait means the
+// request doesn't long-poll; hasSince is false when since_version is absent.
+func parseLongPoll(r *http.Request) (wait time.Duration, since uint64, hasSince bool, err error) {
+	query := r.URL.Query()
+	if value := query.Get("wait"); value != "" {
+		wait, err = time.ParseDuration(value)
+		if err != nil || wait < 0 {
+			return 0, 0, false, fmt.Errorf("invalid wait %q (want a duration like 30s)", value)
+		}
+		wait = min(wait, maxLongPollWait)
+	}
+	if value := query.Get("since_version"); value != "" {
+		since, err = strconv.ParseUint(value, 10, 64)
+		if err != nil {
+			return 0, 0, false, fmt.Errorf("invalid since_version %q", value)
+		}
+		hasSince = true
+	}
+	return wait, since, hasSince, nil
+}
+
+// longPoll holds the request until version() moves past since_version or
+// ?wait= runs out, then sets the version header. It returns false when the
+// response is done: a bad parameter (400), a version it couldn't read
+// (500), or a client that went away.
+func longPoll(w http.ResponseWriter, r *http.Request, header string, version func() (uint64, error)) bool {
+	wait, since, hasSince, err := parseLongPoll(r)
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, err.Error())
+		return false
+	}
+
+	// Answer with the data before the deadline would turn it into a 504
+	if deadline, ok := r.Context().Deadline(); ok {
+		wait = min(wait, time.Until(deadline)-longPollDeadlineMargin)
+	}
+
+	current, err := version()
+	if err == nil && wait > 0 {
+		if !hasSince {
+			since = current // wait for the next change
+		}
+		current, err = waitForVersion(r, wait, since, current, version)
+	}
+	if r.Context().Err() != nil {
+		return false // nobody to answer
+	}
+	if err != nil {
+		logHandlerError(r.Context(), "long_poll", "database", "failed to read version", "header", header, "error", err)
+		http.Error(w, `{"error":"database error"}`, http.StatusInternalServerError)
+		return false
+	}
+	w.Header().Set(header, strconv.FormatUint(current, 10))
+	return true
+}
+
+// waitForVersion checks version() until it isn't since, the wait is over,
+// or the client disconnects, and returns the last version seen
+func waitForVersion(r *http.Request, wait time.Duration, since, current uint64, version func() (uint64, error)) (uint64, error) {
+	deadline := time.NewTimer(wait)
+	defer deadline.Stop()
+	ticker := time.NewTicker(longPollInterval)
+	defer ticker.Stop()
+
+	for current == since {
+		select {
+		case <-ticker.C:
+			var err error
+			if current, err = version(); err != nil {
+				return 0, err
+			}
+		case <-deadline.C:
+			return current, nil
+		case <-r.Context().Done():
+			return current, nil
+		}
+	}
+	return current, nil
+}
+
+// displayVersionOf reads a tenant's display panel version for longPoll
+func displayVersionOf(k keyspace) func() (uint64, error) {
+	return func() (uint64, error) {
+		_, version := tenants.displayData(k)
+		return uint64(version), nil
+	}
+}
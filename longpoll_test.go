@@ -0,0 +1,77 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// longPollGet does a GET and returns the status, the header, and how long
+// it took
+func longPollGet(t *testing.T, url, header string) (int, string, time.Duration) {
+	t.Helper()
+	start := time.Now()
+	resp, err := http.Get(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	return resp.StatusCode, resp.Header.Get(header), time.Since(start)
+}
+
+func TestLongPoll_Items(t *testing.T) {
+	srv := newTestServer(t)
+	createTestItem(t, srv, `{"name":"Widget"}`)
+
+	code, version, _ := longPollGet(t, srv.URL+"/api/items", itemsVersionHeader)
+	if code != http.StatusOK || version == "" || version == "0" {
+		t.Fatalf("GET = %d, %s %q", code, itemsVersionHeader, version)
+	}
+
+	// An old version answers at once
+	_, _, took := longPollGet(t, srv.URL+"/api/items?wait=30s&since_version=1", itemsVersionHeader)
+	if took > 5*time.Second {
+		t.Errorf("stale since_version waited %v", took)
+	}
+
+	// The current one waits for the next change
+	go func() {
+		time.Sleep(300 * time.Millisecond)
+		doRequestNoFatal(srv, http.MethodPost, "/api/items", `{"name":"Gadget"}`)
+	}()
+	code, next, took := longPollGet(t, srv.URL+"/api/items?wait=30s&since_version="+version, itemsVersionHeader)
+	if code != http.StatusOK || next == version {
+		t.Errorf("after a create = %d, version %s (was %s)", code, next, version)
+	}
+	if took < 250*time.Millisecond || took > 10*time.Second {
+		t.Errorf("waited %v, want about 300ms", took)
+	}
+
+	// Nothing changes: the wait runs out and the list comes back anyway
+	code, same, took := longPollGet(t, srv.URL+"/api/items?wait=300ms&since_version="+next, itemsVersionHeader)
+	if code != http.StatusOK || same != next || took < 300*time.Millisecond {
+		t.Errorf("timeout = %d, version %s (was %s) after %v", code, same, next, took)
+	}
+
+	for _, query := range []string{"wait=soon", "wait=-1s", "since_version=x"} {
+		if code, _ := doRequest(t, srv, http.MethodGet, "/api/items?"+query, ""); code != http.StatusBadRequest {
+			t.Errorf("?%s = %d, want 400", query, code)
+		}
+	}
+}
+
+func TestLongPoll_Display(t *testing.T) {
+	srv := newTestServer(t)
+	version := setDisplayData([]byte(`{"step":1}`))
+
+	go func() {
+		time.Sleep(200 * time.Millisecond)
+		setDisplayData([]byte(`{"step":2}`))
+	}()
+	// Without since_version it waits for the next change after it arrived
+	code, next, _ := longPollGet(t, srv.URL+"/api/display?wait=30s", displayVersionHeader)
+	if code != http.StatusOK || next == strconv.FormatInt(version, 10) {
+		t.Errorf("after an update = %d, version %s (was %d)", code, next, version)
+	}
+}
@@ -1,12 +1,22 @@
 package main
 
 import (
+	"cmp"
+	"context"
+	"crypto/tls"
 	"embed"
+	"io"
 	"io/fs"
 	"log/slog"
+	"net"
 	"net/http"
 	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
 
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 )
 
@@ -17,78 +27,182 @@ import (
 //go:embed static/*
 var staticFiles embed.FS
 
-// runHealthcheck checks if the server is responding and exits with appropriate code
-// This is called when the binary is run with "healthcheck" argument
-// Used by Docker HEALTHCHECK to verify the container is healthy
-func runHealthcheck() {
-	port := os.Getenv("PORT")
-	if port == "" {
-		port = "8080"
-	}
-
-	resp, err := http.Get("http://localhost:" + port + "/health")
-	if err != nil {
-		os.Exit(1)
-	}
-	defer resp.Body.Close()
+// version is the app version, overridden at build time:
+//
+//	go build -ldflags="-X main.version=v0.9.0"
+//
+// -X sets a string variable in the binary — Go's equivalent of injecting
+// __version__ during a Python package build.
+var version = "dev"
 
-	if resp.StatusCode == http.StatusOK {
-		os.Exit(0)
-	}
-	os.Exit(1)
+func main() {
+	// The first argument picks a subcommand: serve (the default), backup,
+	// restore, healthcheck, ... (cli.go)
+	// Example: ./demo-app backup --db /data --out demo.bak
+	os.Exit(runCommand(os.Args[1:]))
 }
 
-func main() {
-	// Healthcheck mode: if run with "healthcheck" arg, just check if server responds
-	// Example: ./demo-app healthcheck
-	if len(os.Args) > 1 && os.Args[1] == "healthcheck" {
-		runHealthcheck()
-		return
-	}
+// serve runs the web server until it fails; "demo-app serve" (cli.go) calls
+// it with the port and database path from its flags
+func serve(port, dbPath string) {
+	// Retries and connection pooling for outbound HTTP (outbound.go); first,
+	// because the log webhook below is one of the clients
+	configureOutbound()
 
-	// Configure structured JSON logging
-	// All log output will be JSON for easy parsing by log aggregators
+	// Configure structured logging
+	// By default all log output is JSON for easy parsing by log aggregators.
+	// LOG_FORMAT=logfmt or pretty switches the format (see logformat.go).
 	//
 	// If LOG_WEBHOOK_URL is set, logs are also POSTed to that URL.
 	// This enables shipping logs to Splunk, Loki, or any HTTP endpoint
 	// without requiring a sidecar or external agent.
-	jsonHandler := slog.NewJSONHandler(os.Stdout, nil)
+	//
+	// If LOG_FILE is set, logs are also appended to that file, rotated by
+	// size (see logfile.go) so demos on plain VMs keep their history.
+	var logOutput io.Writer = os.Stdout
+	logFile := os.Getenv("LOG_FILE")
+	if logFile != "" {
+		maxSizeMB := envInt("LOG_MAX_SIZE_MB", 100)
+		rotating, err := newRotatingFile(logFile, int64(maxSizeMB)*1024*1024, envInt("LOG_MAX_BACKUPS", 3))
+		if err != nil {
+			slog.Error("failed to open log file", "path", logFile, "error", err)
+			os.Exit(1)
+		}
+		defer rotating.Close()
+		// MultiWriter is like Unix tee: every write goes to both
+		logOutput = io.MultiWriter(os.Stdout, rotating)
+	}
+	formatHandler, formatErr := newLogHandler(os.Getenv("LOG_FORMAT"), logOutput)
+
+	// CONFIG_FILE holds LOG_LEVEL and LOG_WEBHOOK_URL when they should be
+	// changeable without a restart (configwatch.go)
+	configPath := os.Getenv("CONFIG_FILE")
+	if configPath != "" {
+		if err := loadConfigFile(configPath); err != nil {
+			slog.Error("failed to load config file", "error", err)
+			os.Exit(1)
+		}
+	}
+	level, err := parseLogLevel(configSetting("LOG_LEVEL"))
+	if err != nil {
+		slog.Error("invalid log level", "error", err)
+		os.Exit(1)
+	}
+	logLevel.Set(level)
 
-	webhookURL := os.Getenv("LOG_WEBHOOK_URL")
+	webhookURL := configSetting("LOG_WEBHOOK_URL")
 	webhookToken := os.Getenv("LOG_WEBHOOK_TOKEN")
 
 	var handler slog.Handler
-	if webhookURL != "" {
-		// Wrap the JSON handler with webhook functionality
-		handler = newWebhookHandler(jsonHandler, webhookURL, webhookToken)
+	if webhookURL != "" || configPath != "" {
+		// Wrap the format handler with webhook functionality. With a
+		// CONFIG_FILE it's there even without a URL, so one can be added later.
+		logWebhook = newWebhookHandler(formatHandler, webhookURL, webhookToken)
+		handler = logWebhook
 	} else {
-		// No webhook, just use the JSON handler directly
-		handler = jsonHandler
+		// No webhook, just use the format handler directly
+		handler = formatHandler
+	}
+
+	// If LOG_SYSLOG_ADDR is set, logs are also sent to a syslog server
+	// (syslog.go) for SIEM tools that don't speak HTTP
+	syslogAddr := os.Getenv("LOG_SYSLOG_ADDR")
+	if syslogAddr != "" {
+		syslog, err := newSyslogHandler(handler, syslogAddr)
+		if err != nil {
+			slog.Error("invalid LOG_SYSLOG_ADDR", "error", err)
+			os.Exit(1)
+		}
+		handler = syslog
 	}
 
+	// Outermost, so every output gets trace_id/span_id for traced requests
+	handler = newTraceHandler(handler)
+
 	logger := slog.New(handler)
 	slog.SetDefault(logger)
 
+	// Reported now that there's a logger to report it with
+	if formatErr != nil {
+		slog.Warn("falling back to JSON logs", "error", formatErr)
+	}
+
+	// Startup banner: one structured line with everything you'd want to know
+	// when reading logs from a failed demo
+	hostname, _ := os.Hostname()
+	slog.Info("demo-app starting",
+		"version", version,
+		"go_version", runtime.Version(),
+		"os", runtime.GOOS,
+		"arch", runtime.GOARCH,
+		"hostname", hostname,
+		"pid", os.Getpid(),
+		"port", port,
+		"db_path", dbPath,
+	)
+
+	if logFile != "" {
+		slog.Info("log file enabled", "path", logFile)
+	}
+	if configPath != "" {
+		slog.Info("config file loaded", "path", configPath, "log_level", level.String())
+	}
+
 	// Log webhook status after logger is configured
 	if webhookURL != "" {
 		slog.Info("log webhook enabled", "url", webhookURL)
 	}
+	if syslogAddr != "" {
+		slog.Info("syslog output enabled", "addr", syslogAddr)
+	}
 
-	// Get configuration from environment variables
-	port := os.Getenv("PORT")
-	if port == "" {
-		port = "8080"
+	// Secrets from SECRETS_FILE or Vault, re-read in the background
+	// (secrets.go). Before anything that reads a secret.
+	if err := configureSecrets(); err != nil {
+		slog.Error("failed to load secrets", "error", err)
+		os.Exit(1)
+	}
+	for _, state := range secrets.sources {
+		slog.Info("secrets loaded", "source", state.source.name(), "location", state.source.location(),
+			"count", len(state.values), "refresh_interval", secrets.interval)
 	}
+	secrets.start()
 
-	dbPath := os.Getenv("DB_PATH")
-	if dbPath == "" {
-		dbPath = ":memory:"
+	// Workload identity from a SPIFFE Workload API (spiffe.go). Fetched
+	// this early so the SPIFFE ID is on nearly every log line.
+	if socket := os.Getenv("SPIFFE_ENDPOINT_SOCKET"); socket != "" {
+		if os.Getenv("TLS_CERT_FILE") != "" || os.Getenv("TLS_CLIENT_CA_FILE") != "" {
+			slog.Error("SPIFFE_ENDPOINT_SOCKET replaces TLS_CERT_FILE and TLS_CLIENT_CA_FILE; set one or the other")
+			os.Exit(1)
+		}
+		identity, err := newWorkloadIdentity(socket)
+		if err == nil {
+			err = identity.start(context.Background(), workloadAPITimeout)
+		}
+		if err != nil {
+			slog.Error("failed to get workload identity", "socket", socket, "error", err)
+			os.Exit(1)
+		}
+		spiffeIdentity = identity
+		slog.SetDefault(slog.Default().With("spiffe_id", identity.info().SPIFFEID))
+	}
+
+	// Encryption at rest for file-based databases (encryption.go)
+	err = loadEncryptionKeys(dbPath)
+	if err != nil {
+		slog.Error("invalid encryption key", "error", err)
+		os.Exit(1)
+	}
+
+	// Badger tuning knobs; a bad value stops startup (dboptions.go)
+	if err := loadDBOptions(); err != nil {
+		slog.Error("invalid database options", "error", err)
+		os.Exit(1)
 	}
 
 	// Initialize database
 	// initStore is defined in store.go
 	// db is a package-level variable in store.go
-	var err error
 	db, err = initStore(dbPath)
 	if err != nil {
 		slog.Error("failed to initialize database", "error", err)
@@ -104,15 +218,431 @@ func main() {
 		slog.Error("failed to initialize item sequence", "error", err)
 		os.Exit(1)
 	}
-	defer itemSeq.Release()
+	// Wrapped in a closure so the deferred call uses whatever sequence is
+	// current at shutdown (the reset endpoint replaces it)
+	defer func() { itemSeq.Release() }()
+
+	// Counter merge operators fold their last entries before the DB closes
+	// (deferred calls run in reverse order) (counters.go, hitcounter.go)
+	defer counters.stop()
+	defer hitCounter.stop()
+
+	// Transactions slower than this are logged as warnings (store.go)
+	dbSlowThreshold = envDuration("DB_SLOW_THRESHOLD", dbSlowThreshold)
 
 	// Log database mode
 	mode := "in-memory"
 	if dbPath != "" && dbPath != ":memory:" {
 		mode = "file"
 	}
-	slog.Info("database initialized", "path", dbPath, "mode", mode, "engine", "badger")
+	slog.Info("database initialized", "path", dbPath, "mode", mode, "engine", "badger", "encrypted", dbEncryptionKey != nil, "durability", writeDurability)
+
+	// Optional second store every item write is copied to, for migration
+	// demos; like the DB settings, a bad one stops startup (shadow.go)
+	if spec := os.Getenv("SHADOW_STORE"); spec != "" {
+		shadow, err = openShadowStore(spec)
+		if err != nil {
+			slog.Error("failed to open shadow store", "error", err)
+			os.Exit(1)
+		}
+		defer shadow.close()
+		slog.Info("shadow writes enabled", "store", spec)
+	}
+
+	// Latency histogram buckets, and native histograms (metrics.go)
+	buckets, err := parseBuckets(envList("METRICS_BUCKETS"))
+	if err != nil {
+		slog.Warn("invalid METRICS_BUCKETS, using the defaults", "error", err)
+	}
+	configureRequestDuration(buckets, envBool("METRICS_NATIVE_HISTOGRAMS", false))
+
+	// Optional StatsD push alongside Prometheus /metrics (statsd.go)
+	switch exporter := envString("METRICS_EXPORTER", "prometheus"); exporter {
+	case "prometheus":
+		// /metrics is always served; nothing else to start
+	case "statsd":
+		addr := envString("STATSD_ADDR", "localhost:8125")
+		statsd, err = newStatsdClient(addr, envString("STATSD_PREFIX", "demoapp."), envList("STATSD_TAGS"))
+		if err != nil {
+			slog.Error("failed to set up statsd exporter", "addr", addr, "error", err)
+			os.Exit(1)
+		}
+		statsd.start(envDuration("STATSD_INTERVAL", 10*time.Second))
+		slog.Info("statsd metrics export enabled", "addr", addr)
+	default:
+		slog.Warn("unknown METRICS_EXPORTER, using prometheus only", "value", exporter)
+	}
+
+	// Bring data written by older versions up to date (migrations.go)
+	if err := runMigrations(); err != nil {
+		slog.Error("failed to migrate database", "error", err)
+		os.Exit(1)
+	}
+
+	// Start demoapp_items_total at the real count (a persistent DB may
+	// already have items), and keep it reconciled (store.go)
+	if err := syncItemsGauge(); err != nil {
+		slog.Error("failed to count items", "error", err)
+		os.Exit(1)
+	}
+	startItemsGaugeReconciler(envDuration("ITEMS_RECONCILE_INTERVAL", time.Minute))
+
+	// Item count and tag totals kept alongside the items (aggregates.go)
+	aggregatesReconcileInterval = envDuration("AGGREGATES_RECONCILE_INTERVAL", aggregatesReconcileInterval)
+	startAggregatesMaintainer()
+
+	// Item creations per minute, for GET /api/items/timeseries (timeseries.go)
+	itemTimeseriesRetention = envDuration("ITEM_TIMESERIES_RETENTION", itemTimeseriesRetention)
+	if itemTimeseriesRetention < time.Minute {
+		slog.Error("ITEM_TIMESERIES_RETENTION must be at least 1m", "value", itemTimeseriesRetention.String())
+		os.Exit(1)
+	}
+
+	// Unique item names mode: (re)build the name index from existing items
+	// so items created while the mode was off are covered too (store.go)
+	uniqueItemNames = envBool("UNIQUE_ITEM_NAMES", false)
+	if uniqueItemNames {
+		if err := rebuildNameIndex(); err != nil {
+			slog.Error("failed to build item name index", "error", err)
+			os.Exit(1)
+		}
+	}
+
+	// How long Idempotency-Key values are remembered (idempotency.go)
+	idempotencyTTL = envDuration("IDEMPOTENCY_TTL", idempotencyTTL)
+
+	// Compress item values before they're stored (compression.go)
+	if itemCompression, err = parseItemCompression(os.Getenv("ITEM_COMPRESSION")); err != nil {
+		slog.Error("invalid item compression", "error", err)
+		os.Exit(1)
+	}
+	itemCompressionMinBytes = envInt("ITEM_COMPRESSION_MIN_BYTES", itemCompressionMinBytes)
+
+	// Largest file accepted by POST /api/items/:id/attachment (attachments.go)
+	attachmentMaxBytes = int64(envInt("ATTACHMENT_MAX_BYTES", int(attachmentMaxBytes)))
+
+	// Largest value accepted by PUT /api/kv/:key (kv.go)
+	kvMaxValueBytes = int64(envInt("KV_MAX_VALUE_BYTES", int(kvMaxValueBytes)))
+
+	// How many requests the status page and Traffic panel remember (recentrequests.go)
+	recentRequests = newRequestRing(envInt("RECENT_REQUESTS", recentRequestsSize))
+	latencyStatsWindow = envDuration("LATENCY_STATS_WINDOW", latencyStatsWindow)
+
+	// One instance, many tenants (tenant.go)
+	multiTenant = envBool("MULTI_TENANT", false)
+	tenantDomain = strings.ToLower(strings.TrimPrefix(os.Getenv("TENANT_DOMAIN"), "."))
+	maxTenants = envInt("MAX_TENANTS", maxTenants)
+
+	// Require application/json and reject unknown fields (strictjson.go)
+	strictJSON = envBool("STRICT_JSON", false)
+	// Refuse item updates that don't say which version they edited (itemversion.go)
+	requireItemVersion = envBool("REQUIRE_ITEM_VERSION", false)
+	// Count every API request with a merge operator (hitcounter.go)
+	mergedHitCounters = envBool("MERGED_HIT_COUNTERS", true)
+	// Honor X-Debug: 1 with database trace headers (dbtrace.go)
+	debugEndpoints = envBool("DEBUG_ENDPOINTS", false)
+
+	// Per-tenant (or per-API-key) limits, 0 = unlimited (quota.go)
+	defaultQuota = parseQuotaEnv()
+
+	// What GET /api/stats/slo measures against (slo.go)
+	if target := envFloat("SLO_TARGET", sloTarget); target > 0 && target < 100 {
+		sloTarget = target
+	} else {
+		slog.Warn("SLO_TARGET must be a percentage between 0 and 100, using default", "value", target, "default", sloTarget)
+	}
+	sloLatencyThreshold = envDuration("SLO_LATENCY_THRESHOLD", sloLatencyThreshold)
+	if values := envList("SLO_WINDOWS"); values != nil {
+		if windows, err := parseSLOWindows(values); err != nil {
+			slog.Warn("invalid SLO_WINDOWS, using default", "error", err)
+		} else {
+			sloWindows = windows
+		}
+	}
+
+	// Services GET /api/downstream calls (downstream.go)
+	downstreamURLs = envList("DOWNSTREAM_URLS")
+	downstreamTimeout = envDuration("DOWNSTREAM_TIMEOUT", downstreamTimeout)
+	circuitFailureThreshold = envInt("CIRCUIT_FAILURE_THRESHOLD", circuitFailureThreshold)
+	circuitOpenDuration = envDuration("CIRCUIT_OPEN_DURATION", circuitOpenDuration)
+	if err := validateDownstreamURLs(downstreamURLs); err != nil {
+		slog.Error("failed to configure downstreams", "error", err)
+		os.Exit(1)
+	}
+
+	// Per-client counts survive restarts when DB_PATH is a file (clientstats.go)
+	if err := clientStats.load(); err != nil {
+		slog.Warn("failed to load client stats", "error", err)
+	}
+	startClientStatsPersister(envDuration("CLIENT_STATS_PERSIST_INTERVAL", 30*time.Second))
+
+	// Quota overrides set through the admin API (quota.go)
+	if err := quotas.load(); err != nil {
+		slog.Warn("failed to load quota overrides", "error", err)
+	}
+
+	// Load item validation constraints (validation.go)
+	if err := loadItemValidation(); err != nil {
+		slog.Error("failed to load item validation rules", "error", err)
+		os.Exit(1)
+	}
+
+	// Seed an empty store from SEED_FILE / SEED_COUNT (seed.go)
+	// Runs after validation is loaded so seed items follow the same rules
+	if err := seedStore(os.Getenv("SEED_FILE"), envInt("SEED_COUNT", 0)); err != nil {
+		slog.Error("failed to load seed data", "error", err)
+		os.Exit(1)
+	}
+
+	// Start background job workers (jobs.go)
+	// Load generation jobs call back into this server (selfclient.go)
+	jobSelfURL = "http://localhost:" + port
+	jobBackupDir = envString("BACKUP_DIR", filepath.Join(os.TempDir(), "demo-app-backups"))
+	if err := startJobWorkers(envInt("JOB_WORKERS", 2)); err != nil {
+		slog.Error("failed to start job workers", "error", err)
+		os.Exit(1)
+	}
+	defer jobSeq.Release()
 
+	// Optional multi-replica mode: leader election, write forwarding, and
+	// replication between replicas with their own databases (cluster.go)
+	if peers, peersDNS := envList("CLUSTER_PEERS"), os.Getenv("CLUSTER_PEERS_DNS"); len(peers) > 0 || peersDNS != "" {
+		cluster = newClusterNode(peers, peersDNS, port)
+		cluster.start(envDuration("CLUSTER_HEARTBEAT", 2*time.Second))
+		slog.Info("cluster mode enabled", "node_id", cluster.id, "peers", peers, "peers_dns", peersDNS)
+	}
+
+	// Optional warm standby: this instance either receives shipped backups
+	// (STANDBY_MODE) or ships them to one (STANDBY_URL) (standby.go)
+	standbyMode = envBool("STANDBY_MODE", false)
+	if standbyMode {
+		slog.Info("standby mode enabled; writes refused until POST /api/admin/promote")
+	}
+	if target := os.Getenv("STANDBY_URL"); target != "" {
+		interval := envDuration("STANDBY_INTERVAL", 30*time.Second)
+		standbyShip = newStandbyShipper(target, db)
+		standbyShip.start(interval)
+		slog.Info("shipping backups to standby", "standby", target, "interval", interval)
+	}
+
+	// Optional S3-compatible storage for backups (s3.go, backup.go)
+	if bucket := os.Getenv("S3_BUCKET"); bucket != "" {
+		objectStore, err = newS3Client(
+			os.Getenv("S3_ENDPOINT"),
+			bucket,
+			envString("S3_REGION", "us-east-1"),
+			cmp.Or(secret("S3_ACCESS_KEY_ID"), secret("AWS_ACCESS_KEY_ID")),
+			cmp.Or(secret("S3_SECRET_ACCESS_KEY"), secret("AWS_SECRET_ACCESS_KEY")),
+			envString("S3_PREFIX", "demo-app/"),
+		)
+		if err != nil {
+			slog.Error("failed to configure object storage", "error", err)
+			os.Exit(1)
+		}
+		slog.Info("object storage enabled", "endpoint", objectStore.endpoint, "bucket", bucket, "prefix", objectStore.prefix)
+	}
+
+	// Start scheduled tasks (scheduler.go) if any are configured
+	scheduleDefs, err := loadSchedules()
+	if err != nil {
+		slog.Error("failed to load schedules", "error", err)
+		os.Exit(1)
+	}
+	startScheduler(scheduleDefs)
+
+	// Optional: keep the display panel filled from a URL (displaysource.go)
+	if sourceURL := os.Getenv("DISPLAY_SOURCE_URL"); sourceURL != "" {
+		displaySrc, err = newDisplaySource(sourceURL, secret("DISPLAY_SOURCE_TOKEN"),
+			envDuration("DISPLAY_SOURCE_INTERVAL", time.Minute),
+			envDuration("DISPLAY_SOURCE_MAX_BACKOFF", 10*time.Minute))
+		if err != nil {
+			slog.Error("failed to configure display source", "error", err)
+			os.Exit(1)
+		}
+		displaySrc.start()
+		slog.Info("display source enabled", "url", displaySrc.status.URL, "interval", displaySrc.interval)
+	}
+
+	// Optional: publish item and display changes to NATS or Kafka (events.go)
+	if kind := os.Getenv("EVENT_SINK"); kind != "" {
+		sink, err := newEventSink(kind)
+		if err != nil {
+			slog.Error("failed to configure event sink", "error", err)
+			os.Exit(1)
+		}
+		events = newEventPublisher(sink)
+		slog.Info("event publishing enabled", "sink", sink.name())
+	}
+
+	// Optional: create items from NATS, AMQP, or Kafka messages (queueconsumer.go)
+	// Messages are posted back to this server, like load generation jobs
+	// (in-process once the router is built, see selfclient.go)
+	if queueURL := os.Getenv("QUEUE_URL"); queueURL != "" {
+		queue, err = newQueueConsumer(queueURL, envString("QUEUE_TOPIC", "demoapp-items"), envString("QUEUE_GROUP", "demo-app"), jobSelfURL)
+		if err != nil {
+			slog.Error("failed to configure queue consumer", "error", err)
+			os.Exit(1)
+		}
+		queue.start()
+		slog.Info("queue consumer enabled", "source", queue.status.Source, "url", queue.status.URL, "topic", queue.status.Topic)
+	}
+
+	// Optional: Slack/email alerts on thresholds and maintenance toggles (alerts.go)
+	alerts, err = configureAlerts()
+	if err != nil {
+		slog.Error("failed to configure alerts", "error", err)
+		os.Exit(1)
+	}
+	if alerts != nil {
+		alerts.start()
+		slog.Info("alerting enabled", "item_count_threshold", alerts.rules.ItemCountThreshold,
+			"error_rate_percent", alerts.rules.ErrorRatePercent)
+	}
+	// Start read-only if asked (maintenance.go)
+	if envBool("MAINTENANCE_MODE", false) {
+		maintenance.set(true, os.Getenv("MAINTENANCE_MESSAGE"))
+		slog.Warn("maintenance mode enabled at startup")
+	}
+
+	// Serve the dashboard for deep links to unknown pages (static.go)
+	spaFallback = envBool("SPA_FALLBACK", spaFallback)
+
+	// Wrap API responses in {"data":...,"meta":...} (responseformat.go)
+	responseEnvelope = envBool("RESPONSE_ENVELOPE", responseEnvelope)
+
+	// POST /api/chaos/* endpoints that break this replica on purpose (chaos.go)
+	chaosEnabled = envBool("CHAOS_ENABLED", chaosEnabled)
+	// Leak memory from the start, for OOMKill demos (memoryleak.go)
+	startMemoryLeakFromEnv()
+
+	// Per-request deadlines, 504 when exceeded (timeout.go)
+	requestTimeout = envDuration("REQUEST_TIMEOUT", requestTimeout)
+	parseRouteTimeouts(envList("REQUEST_TIMEOUTS"))
+
+	// Blue/green/canary variant (variant.go)
+	loadVariant()
+	if variant != "" {
+		slog.Info("variant enabled", "variant", variant, "color", variantColor)
+	}
+
+	// Load response rules (rules.go) if a rules file is configured
+	if rulesFile := os.Getenv("RULES_FILE"); rulesFile != "" {
+		if err := loadRulesFile(rulesFile); err != nil {
+			slog.Error("failed to load rules file", "path", rulesFile, "error", err)
+			os.Exit(1)
+		}
+		slog.Info("response rules loaded", "path", rulesFile, "count", len(rules.list()))
+	}
+
+	// Per-path access policy (accesspolicy.go)
+	if policyFile := os.Getenv("ACCESS_POLICY_FILE"); policyFile != "" {
+		policy, err := loadAccessPolicyFile(policyFile)
+		if err != nil {
+			slog.Error("failed to load access policy", "path", policyFile, "error", err)
+			os.Exit(1)
+		}
+		slog.Info("access policy loaded", "path", policyFile, "mode", policy.Mode,
+			"keys", len(policy.Keys), "rules", len(policy.Rules))
+	}
+
+	// Reload the files above, the TLS certificate, and SECRETS_FILE when
+	// they change (configwatch.go)
+	configureConfigWatch()
+	for _, f := range configWatch.files {
+		slog.Info("watching config files", "component", f.component, "paths", f.paths,
+			"interval", configWatch.interval)
+	}
+
+	// Routes are registered in registerRoutes (below) so tests can build
+	// the same routes on their own ServeMux (see newTestServer)
+	if err := registerRoutes(http.DefaultServeMux); err != nil {
+		slog.Error("failed to register routes", "error", err)
+		os.Exit(1)
+	}
+
+	// Slow or failing starts for rollout demos (chaos.go)
+	if !simulateStartup(envDuration("STARTUP_DELAY", 0), envFloat("FAIL_STARTUP_PROBABILITY", 0)) {
+		os.Exit(1)
+	}
+
+	// ==========================================================================
+	// Start Server
+	// ==========================================================================
+
+	// Bind the port ourselves (instead of http.ListenAndServe) so the
+	// diagnostics pass can report whether binding worked
+	listener, listenErr := net.Listen("tcp", ":"+port)
+
+	// HTTPS, and client certificates for mutual TLS, when configured (tls.go)
+	tlsConfig, err := loadServerTLS(os.Getenv("TLS_CERT_FILE"), os.Getenv("TLS_KEY_FILE"),
+		os.Getenv("TLS_CLIENT_CA_FILE"), os.Getenv("TLS_CLIENT_AUTH"))
+	if err != nil {
+		slog.Error("invalid TLS configuration", "error", err)
+		os.Exit(1)
+	}
+	if spiffeIdentity != nil {
+		tlsConfig, err = spiffeIdentity.tlsConfig(os.Getenv("TLS_CLIENT_AUTH"))
+		if err != nil {
+			slog.Error("invalid TLS configuration", "error", err)
+			os.Exit(1)
+		}
+	}
+	if tlsConfig != nil && listenErr == nil {
+		listener = tls.NewListener(listener, tlsConfig)
+	}
+
+	// Run startup diagnostics (diagnostics.go) and keep the report for the API
+	diagConfig = diagnosticsConfig{dbPath: dbPath, webhookURL: webhookURL, port: port}
+	report := runDiagnostics(diagConfig, listenErr)
+	logDiagnostics(report)
+	setDiagnostics(report)
+
+	if listenErr != nil {
+		slog.Error("server failed to start", "error", listenErr)
+		os.Exit(1)
+	}
+
+	// Router-wide middleware, outermost first:
+	//   1. responseHeadersMiddleware stamps RESPONSE_HEADERS (and X-Variant)
+	//      on every response
+	//   2. recoveryMiddleware turns panics into 500s
+	//   3. concurrencyLimitMiddleware sheds load above MAX_INFLIGHT_REQUESTS
+	//   4. accessPolicyMiddleware checks ACCESS_POLICY_FILE
+	//   5. maintenanceMiddleware refuses writes in maintenance mode
+	//   6. standbyMiddleware refuses writes on an unpromoted standby
+	//   7. latencyMiddleware injects per-route latency profiles
+	//   8. rulesMiddleware runs response rules before any handler
+	//      (and can mock paths that have no handler at all)
+	var router http.Handler = http.DefaultServeMux
+	router = rulesMiddleware(router)
+	router = latencyMiddleware(router)
+	router = standbyMiddleware(router)
+	router = maintenanceMiddleware(router)
+	router = accessPolicyMiddleware(router)
+	router = concurrencyLimitMiddleware(envInt("MAX_INFLIGHT_REQUESTS", 0), router)
+	router = recoveryMiddleware(router)
+	responseHeaders := parseResponseHeaders(envList("RESPONSE_HEADERS"))
+	if variant != "" {
+		responseHeaders = append(responseHeaders, responseHeader{Name: "X-Variant", Value: variant})
+	}
+	for _, h := range responseHeaders {
+		slog.Info("response header enabled", "name", h.Name, "value", h.Value)
+	}
+	router = responseHeadersMiddleware(responseHeaders, router)
+	selfHandler.Store(&router) // calls back to this server skip the network (selfclient.go)
+
+	slog.Info("server starting", "port", port, "tls", tlsConfig != nil,
+		"client_auth", tlsConfig != nil && (tlsConfig.ClientCAs != nil || spiffeIdentity != nil))
+	err = http.Serve(listener, router)
+	if err != nil {
+		slog.Error("server stopped", "error", err)
+		os.Exit(1)
+	}
+}
+
+// registerRoutes adds every API route and the dashboard to mux.
+// main uses http.DefaultServeMux; tests pass a fresh ServeMux.
+func registerRoutes(mux *http.ServeMux) error {
 	// ==========================================================================
 	// Route Registration
 	// ==========================================================================
@@ -122,51 +652,185 @@ func main() {
 	// All are accessible because they're in the same package (package main)
 
 	// Health endpoint (for load balancers, Docker healthcheck)
-	http.HandleFunc("/health", loggingMiddleware(healthHandler))
+	mux.HandleFunc("/health", loggingMiddleware(healthHandler))
+	// Readiness: the database answers too (handlers.go)
+	mux.HandleFunc("/ready", loggingMiddleware(readyHandler))
+	// Server-rendered status page, works without JavaScript (status.go)
+	mux.HandleFunc("/status", loggingMiddleware(statusPageHandler))
+	// This replica's latest requests (recentrequests.go)
+	mux.HandleFunc("/api/requests/recent", loggingMiddleware(recentRequestsHandler))
+	// p50/p90/p99 per route without Prometheus (latencystats.go)
+	mux.HandleFunc("/api/stats/latency", loggingMiddleware(latencyStatsHandler))
+	mux.HandleFunc("/api/stats/clients", loggingMiddleware(clientStatsHandler))
+	// Availability and latency SLIs with burn rates (slo.go)
+	mux.HandleFunc("/api/stats/slo", loggingMiddleware(sloHandler))
+	// Fan out to DOWNSTREAM_URLS and report their health (downstream.go)
+	mux.HandleFunc("/api/downstream", loggingMiddleware(downstreamHandler))
+
+	// Queue consumer status (queueconsumer.go)
+	mux.HandleFunc("/api/queue", loggingMiddleware(queueHandler))
 
 	// Items API (CRUD)
-	http.HandleFunc("/api/items", loggingMiddleware(itemsHandler))
-	http.HandleFunc("/api/items/", loggingMiddleware(itemsHandler)) // trailing slash catches /api/items/:id
+	// leaderMiddleware forwards writes to the leader in cluster mode (cluster.go)
+	mux.HandleFunc("/api/items", loggingMiddleware(leaderMiddleware(itemsHandler)))
+	mux.HandleFunc("/api/items/", loggingMiddleware(leaderMiddleware(itemsHandler))) // trailing slash catches /api/items/:id
+	// Longer patterns win in ServeMux, so this beats /api/items/ for "count"
+	mux.HandleFunc("/api/items/count", loggingMiddleware(itemsCountHandler))
+	// Several creates/updates/deletes in one transaction (batch.go)
+	mux.HandleFunc("/api/items/batch", loggingMiddleware(leaderMiddleware(itemsBatchHandler)))
+	// Counts per tag and category, dates, storage (itemstats.go)
+	mux.HandleFunc("/api/items/stats", loggingMiddleware(itemsStatsHandler))
+	// Creations per minute/hour for the dashboard chart (timeseries.go)
+	mux.HandleFunc("/api/items/timeseries", loggingMiddleware(itemsTimeseriesHandler))
+	// The caller's limits and usage (quota.go)
+	mux.HandleFunc("/api/quota", loggingMiddleware(quotaHandler))
+
+	// Item categories, read from a secondary index (categories.go)
+	mux.HandleFunc("/api/categories", loggingMiddleware(categoriesHandler))
+	mux.HandleFunc("/api/categories/", loggingMiddleware(categoriesHandler))
+	// Counters talk to the leader themselves: the instance count stays on
+	// the replica that took the request (counters.go)
+	mux.HandleFunc("/api/counters", loggingMiddleware(countersHandler))
+	// This replica's own request count, kept with a merge operator (hitcounter.go)
+	mux.HandleFunc("/api/counters/merged", loggingMiddleware(mergedCountersHandler))
+	mux.HandleFunc("/api/counters/", loggingMiddleware(countersHandler))
+	mux.HandleFunc("/api/kv", loggingMiddleware(leaderMiddleware(kvHandler)))
+	mux.HandleFunc("/api/kv/", loggingMiddleware(leaderMiddleware(kvHandler)))
 
 	// Display panel API (arbitrary JSON storage)
-	http.HandleFunc("/api/display", loggingMiddleware(displayHandler))
+	mux.HandleFunc("/api/display", loggingMiddleware(displayHandler))
+	mux.HandleFunc("/api/display/schema", loggingMiddleware(leaderMiddleware(displaySchemaHandler)))
+	mux.HandleFunc("/api/display/source", loggingMiddleware(displaySourceHandler))
+	mux.HandleFunc("/api/display/rendered", loggingMiddleware(renderedDisplayHandler))
 
 	// System info API (hostname, IPs, env vars)
-	http.HandleFunc("/api/system", loggingMiddleware(systemHandler))
+	mux.HandleFunc("/api/system", loggingMiddleware(systemHandler))
+	mux.HandleFunc("/api/system/diagnostics", loggingMiddleware(diagnosticsHandler))
+	mux.HandleFunc("/api/system/kubernetes", loggingMiddleware(kubernetesHandler))
+	mux.HandleFunc("/api/system/cloud", loggingMiddleware(cloudHandler))
+
+	// Blue/green/canary variant info (variant.go)
+	mux.HandleFunc("/api/variant", loggingMiddleware(variantHandler))
+
+	// Dashboard title, logo, color, and footer (branding.go)
+	mux.HandleFunc("/api/branding", loggingMiddleware(brandingHandler))
+
+	// Translated dashboard strings (i18n.go)
+	mux.HandleFunc("/api/i18n", loggingMiddleware(i18nHandler))
+	mux.HandleFunc("/api/i18n/", loggingMiddleware(i18nHandler))
+
+	// Request echo for debugging ingress rewrites and header propagation (echo.go)
+	mux.HandleFunc("/api/echo", loggingMiddleware(echoHandler))
+	mux.HandleFunc("/api/echo/", loggingMiddleware(echoHandler))
+
+	// httpbin-style delay and status simulation (simulate.go)
+	mux.HandleFunc("/api/delay/", loggingMiddleware(delayHandler))
+	mux.HandleFunc("/api/status/", loggingMiddleware(statusHandler))
+
+	// DNS/TCP/HTTP connectivity probe from inside the pod (netprobe.go)
+	mux.HandleFunc("/api/net/probe", loggingMiddleware(netProbeHandler))
+
+	// Async jobs API
+	// Jobs live on the leader in cluster mode, so every request goes there
+	mux.HandleFunc("/api/jobs", loggingMiddleware(leaderOnlyMiddleware(jobsHandler)))
+	mux.HandleFunc("/api/jobs/", loggingMiddleware(leaderOnlyMiddleware(jobsHandler)))
+
+	// Admin API (protected by ADMIN_TOKEN when set, see middleware.go)
+	mux.HandleFunc("/api/admin/rules", loggingMiddleware(adminMiddleware(rulesAdminHandler)))
+	mux.HandleFunc("/api/admin/rules/", loggingMiddleware(adminMiddleware(rulesAdminHandler)))
+	mux.HandleFunc("/api/admin/access-policy", loggingMiddleware(adminMiddleware(accessPolicyAdminHandler)))
+	mux.HandleFunc("/api/admin/secrets", loggingMiddleware(adminMiddleware(secretsAdminHandler)))
+	mux.HandleFunc("/api/admin/config-watch", loggingMiddleware(adminMiddleware(configWatchAdminHandler)))
+	mux.HandleFunc("/api/admin/display-template", loggingMiddleware(adminMiddleware(leaderMiddleware(displayTemplateAdminHandler))))
+	mux.HandleFunc("/api/admin/branding", loggingMiddleware(adminMiddleware(leaderMiddleware(brandingAdminHandler))))
+	mux.HandleFunc("/api/admin/i18n/", loggingMiddleware(adminMiddleware(leaderMiddleware(i18nAdminHandler))))
+	mux.HandleFunc("/api/admin/recorder", loggingMiddleware(adminMiddleware(recorderAdminHandler)))
+	mux.HandleFunc("/api/admin/recorder/requests", loggingMiddleware(adminMiddleware(recorderAdminHandler)))
+	// Outbound circuit breakers (circuitbreaker.go)
+	mux.HandleFunc("/api/admin/circuits", loggingMiddleware(adminMiddleware(circuitsHandler)))
+	mux.HandleFunc("/api/admin/maintenance", loggingMiddleware(adminMiddleware(maintenanceAdminHandler)))
+	mux.HandleFunc("/api/admin/alerts", loggingMiddleware(adminMiddleware(alertsAdminHandler)))
+	mux.HandleFunc("/api/admin/alerts/test", loggingMiddleware(adminMiddleware(alertsAdminHandler)))
+	mux.HandleFunc("/api/admin/replay", loggingMiddleware(adminMiddleware(replayAdminHandler)))
+	mux.HandleFunc("/api/admin/schedules", loggingMiddleware(adminMiddleware(schedulesAdminHandler)))
+	mux.HandleFunc("/api/admin/generate", loggingMiddleware(adminMiddleware(leaderOnlyMiddleware(generateAdminHandler))))
+	mux.HandleFunc("/api/admin/reset", loggingMiddleware(adminMiddleware(leaderOnlyMiddleware(resetAdminHandler))))
+	// List tenants and wipe one (tenant.go)
+	mux.HandleFunc("/api/admin/tenants", loggingMiddleware(adminMiddleware(tenantsAdminHandler)))
+	mux.HandleFunc("/api/admin/tenants/", loggingMiddleware(adminMiddleware(leaderOnlyMiddleware(tenantsAdminHandler))))
+	// Per-tenant limits and usage (quota.go)
+	mux.HandleFunc("/api/admin/quotas", loggingMiddleware(adminMiddleware(quotasAdminHandler)))
+	mux.HandleFunc("/api/admin/quotas/", loggingMiddleware(adminMiddleware(quotasAdminHandler)))
+	mux.HandleFunc("/api/admin/latency", loggingMiddleware(adminMiddleware(latencyAdminHandler)))
+	mux.HandleFunc("/api/admin/migrations", loggingMiddleware(adminMiddleware(migrationsAdminHandler)))
+	// Integrity check of this replica's database (fsck.go)
+	mux.HandleFunc("/api/admin/fsck", loggingMiddleware(adminMiddleware(fsckAdminHandler)))
+	// The Badger options the open database uses (dboptions.go)
+	mux.HandleFunc("/api/admin/db/options", loggingMiddleware(adminMiddleware(dbOptionsHandler)))
+	// Shadow-write comparison and backfill (shadow.go)
+	mux.HandleFunc("/api/admin/shadow", loggingMiddleware(adminMiddleware(shadowAdminHandler)))
+	mux.HandleFunc("/api/admin/shadow/backfill", loggingMiddleware(adminMiddleware(leaderOnlyMiddleware(shadowAdminHandler))))
+	mux.HandleFunc("/api/admin/backup", loggingMiddleware(adminMiddleware(leaderOnlyMiddleware(backupAdminHandler))))
+	mux.HandleFunc("/api/admin/restore", loggingMiddleware(adminMiddleware(leaderOnlyMiddleware(restoreAdminHandler))))
+
+	// Deliberate failures for self-healing demos, off unless CHAOS_ENABLED (chaos.go)
+	mux.HandleFunc("/api/chaos/panic", loggingMiddleware(adminMiddleware(chaosPanicHandler)))
+	mux.HandleFunc("/api/chaos/exit", loggingMiddleware(adminMiddleware(chaosExitHandler)))
+	mux.HandleFunc("/api/chaos/memory", loggingMiddleware(adminMiddleware(chaosMemoryHandler)))
+	mux.HandleFunc("/api/chaos/disk", loggingMiddleware(adminMiddleware(chaosDiskHandler)))
+
+	// Cluster status and replication (cluster.go)
+	// No logging middleware — peers call these every heartbeat
+	mux.HandleFunc("/api/cluster", clusterHandler)
+	mux.HandleFunc("/api/cluster/replicate", adminMiddleware(replicateHandler))
+
+	// Warm standby status, shipments, and promotion (standby.go)
+	mux.HandleFunc("/api/standby", loggingMiddleware(standbyStatusHandler))
+	mux.HandleFunc("/api/standby/receive", loggingMiddleware(adminMiddleware(standbyReceiveHandler)))
+	mux.HandleFunc("/api/admin/promote", loggingMiddleware(adminMiddleware(promoteAdminHandler)))
 
 	// Prometheus metrics endpoint
 	// No logging middleware — would be too noisy from Prometheus scraping every 15s
-	http.Handle("/metrics", promhttp.Handler())
+	// Same as promhttp.Handler(), plus OpenMetrics for scrapers that ask for
+	// it — the only format that carries exemplars (observeRequestDuration)
+	mux.Handle("/metrics", promhttp.InstrumentMetricHandler(prometheus.DefaultRegisterer,
+		promhttp.HandlerFor(prometheus.DefaultGatherer, promhttp.HandlerOpts{EnableOpenMetrics: true})))
 
 	// ==========================================================================
 	// Static File Serving
 	// ==========================================================================
 
-	// Serve embedded static files (HTML, CSS, JS)
+	// Serve embedded static files (HTML, CSS, JS) with caching headers,
+	// fingerprinted names, and gzip (static.go)
 	staticFS, err := fs.Sub(staticFiles, "static")
 	if err != nil {
-		slog.Error("failed to create static file system", "error", err)
-		os.Exit(1)
+		return err
+	}
+	assets, err := loadStaticAssets(staticFS)
+	if err != nil {
+		return err
 	}
-	http.Handle("/static/", http.StripPrefix("/static/", http.FileServer(http.FS(staticFS))))
+	mux.Handle("/static/", http.StripPrefix("/static/", assets))
+
+	// OpenAPI description of the API, read by the console at
+	// /static/console.html
+	mux.HandleFunc("/api/openapi.json", func(w http.ResponseWriter, r *http.Request) {
+		assets.serve(w, r, "openapi.json")
+	})
 
-	// Redirect root to dashboard
-	http.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+	// Redirect root to dashboard; other unknown pages are dashboard deep
+	// links when SPA_FALLBACK is on (static.go)
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
 		if r.URL.Path == "/" {
 			http.Redirect(w, r, "/static/index.html", http.StatusFound)
 			return
 		}
+		if spaFallback && wantsDashboard(r) {
+			assets.serve(w, r, "index.html")
+			return
+		}
 		http.NotFound(w, r)
 	})
 
-	// ==========================================================================
-	// Start Server
-	// ==========================================================================
-
-	slog.Info("server starting", "port", port)
-	err = http.ListenAndServe(":"+port, nil)
-	if err != nil {
-		slog.Error("server failed to start", "error", err)
-		os.Exit(1)
-	}
+	return nil
 }
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// =============================================================================
+// Maintenance Mode
+// =============================================================================
+//
+// A switch for planned work: while it's on, the app keeps serving reads but
+// refuses writes with 503 and a Retry-After, the way a real service goes
+// read-only for a migration:
+//
+//	curl -X PUT http://localhost:8080/api/admin/maintenance -d '{"enabled": true, "message": "upgrading storage"}'
+//	curl -X POST http://localhost:8080/api/items -d '{"name":"x"}'
+//	{"error":"maintenance mode","message":"upgrading storage"}
+//	curl -X PUT http://localhost:8080/api/admin/maintenance -d '{"enabled": false}'
+//
+// /health stays 200 so load balancers don't pull the instance, and
+// /api/admin/* keeps working so maintenance can be turned off again.
+// MAINTENANCE_MODE=true starts the app with it on. The switch is per
+// replica; toggling it sends an alert when alerting is set up (alerts.go).
+
+// MaintenanceStatus is reported by GET /api/admin/maintenance
+type MaintenanceStatus struct {
+	Enabled bool       `json:"enabled"`
+	Message string     `json:"message,omitempty"`
+	Since   *time.Time `json:"since,omitempty"`
+}
+
+// maintenanceState holds the switch
+type maintenanceState struct {
+	mu     sync.Mutex
+	status MaintenanceStatus
+}
+
+var maintenance = &maintenanceState{}
+
+// get returns the current status
+func (m *maintenanceState) get() MaintenanceStatus {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.status
+}
+
+// set turns maintenance mode on or off; it reports whether that changed it
+func (m *maintenanceState) set(enabled bool, message string) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	changed := m.status.Enabled != enabled
+	if !enabled {
+		m.status = MaintenanceStatus{}
+		return changed
+	}
+	if changed {
+		now := time.Now().UTC()
+		m.status.Since = &now
+	}
+	m.status.Enabled = true
+	m.status.Message = message
+	return changed
+}
+
+// maintenanceMiddleware refuses writes while maintenance mode is on
+func maintenanceMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		status := maintenance.get()
+		if !status.Enabled || !maintenanceBlocks(r) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Retry-After", "60")
+		w.WriteHeader(http.StatusServiceUnavailable)
+		json.NewEncoder(w).Encode(map[string]string{"error": "maintenance mode", "message": status.Message})
+	})
+}
+
+// maintenanceBlocks reports whether r is a write maintenance mode refuses
+func maintenanceBlocks(r *http.Request) bool {
+	switch r.Method {
+	case http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete:
+	default:
+		return false
+	}
+	return !strings.HasPrefix(r.URL.Path, "/api/admin/") && !strings.HasPrefix(r.URL.Path, "/api/cluster")
+}
+
+// maintenanceAdminHandler handles /api/admin/maintenance (GET status, PUT
+// {"enabled": bool, "message": "..."})
+func maintenanceAdminHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPut:
+		var input struct {
+			Enabled *bool  `json:"enabled"`
+			Message string `json:"message"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&input); err != nil || input.Enabled == nil {
+			http.Error(w, `{"error":"body must be {\"enabled\": true|false, \"message\": \"...\"}"}`, http.StatusBadRequest)
+			return
+		}
+		if maintenance.set(*input.Enabled, input.Message) {
+			slog.InfoContext(r.Context(), "maintenance mode toggled", "enabled", *input.Enabled, "message", input.Message)
+			notifyMaintenance(*input.Enabled, input.Message)
+		}
+	default:
+		http.Error(w, `{"error":"method not allowed"}`, http.StatusMethodNotAllowed)
+		return
+	}
+	json.NewEncoder(w).Encode(maintenance.get())
+}
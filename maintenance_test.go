@@ -0,0 +1,74 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestMaintenance_BlocksWrites(t *testing.T) {
+	t.Cleanup(func() { maintenance.set(false, "") })
+	maintenance.set(true, "upgrading storage")
+
+	handler := maintenanceMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	tests := []struct {
+		method, path string
+		want         int
+	}{
+		{"POST", "/api/items", http.StatusServiceUnavailable},
+		{"DELETE", "/api/items/1", http.StatusServiceUnavailable},
+		{"GET", "/api/items", http.StatusOK},
+		{"GET", "/health", http.StatusOK},
+		{"PUT", "/api/admin/maintenance", http.StatusOK},
+	}
+	for _, tt := range tests {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(tt.method, tt.path, nil))
+		if rec.Code != tt.want {
+			t.Errorf("%s %s = %d, want %d", tt.method, tt.path, rec.Code, tt.want)
+		}
+		if rec.Code == http.StatusServiceUnavailable && rec.Header().Get("Retry-After") == "" {
+			t.Errorf("%s %s: missing Retry-After", tt.method, tt.path)
+		}
+	}
+
+	maintenance.set(false, "")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest("POST", "/api/items", nil))
+	if rec.Code != http.StatusOK {
+		t.Errorf("after turning off: POST = %d, want 200", rec.Code)
+	}
+}
+
+func TestMaintenance_AdminToggleAlerts(t *testing.T) {
+	t.Cleanup(func() { maintenance.set(false, "") })
+	srv := newTestServer(t)
+	notifier := withAlerts(t, AlertRules{})
+
+	code, data := doRequest(t, srv, "PUT", "/api/admin/maintenance", `{"enabled":true,"message":"db upgrade"}`)
+	var status MaintenanceStatus
+	json.Unmarshal(data, &status)
+	if code != http.StatusOK || !status.Enabled || status.Message != "db upgrade" || status.Since == nil {
+		t.Fatalf("PUT = %d %s", code, data)
+	}
+	if alert := nextAlert(t, notifier); alert.Kind != alertMaintenance || alert.Summary != "maintenance mode on: db upgrade" {
+		t.Errorf("alert = %+v", alert)
+	}
+
+	// Setting the same state again doesn't alert
+	doRequest(t, srv, "PUT", "/api/admin/maintenance", `{"enabled":true,"message":"db upgrade"}`)
+	noAlert(t, notifier)
+
+	doRequest(t, srv, "PUT", "/api/admin/maintenance", `{"enabled":false}`)
+	if alert := nextAlert(t, notifier); alert.Summary != "maintenance mode off" {
+		t.Errorf("alert = %+v", alert)
+	}
+
+	if code, _ := doRequest(t, srv, "PUT", "/api/admin/maintenance", `{}`); code != http.StatusBadRequest {
+		t.Errorf("PUT {} = %d, want 400", code)
+	}
+}
@@ -0,0 +1,230 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"runtime/debug"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// =============================================================================
+// Memory Leak Simulation
+// =============================================================================
+//
+// A controlled leak for OOMKill, vertical-pod-autoscaler, and memory-alert
+// demos: the app holds on to a few more megabytes every second until it
+// reaches a cap, and the growth shows in /api/system (resources.memory) and
+// demoapp_memory_leaked_bytes.
+//
+//	curl -X POST 'http://localhost:8080/api/chaos/memory?mb_per_minute=60&cap_mb=512'
+//	curl http://localhost:8080/api/chaos/memory      # progress
+//	curl -X DELETE http://localhost:8080/api/chaos/memory   # stop and give it all back
+//
+// The endpoint is a chaos endpoint (CHAOS_ENABLED, chaos.go). To leak from
+// the moment the process starts — so an OOMKilled pod leaks again after its
+// restart and ends up in CrashLoopBackOff — set MEMORY_LEAK_MB_PER_MINUTE
+// (and MEMORY_LEAK_CAP_MB) instead.
+//
+// The cap is what keeps it safe: the leak never grows past it, so a pod
+// without a memory limit can't take the node down. Every page of the leaked
+// memory is written to, so it counts in the process's RSS and the
+// container's memory usage, not just in Go's heap numbers.
+
+// Limits for the parameters
+const (
+	maxLeakMBPerMinute = 10240
+	maxLeakCapMB       = 65536
+	defaultLeakCapMB   = 512
+)
+
+// How often the leak grows (by mb_per_minute/60 per second)
+const leakInterval = time.Second
+
+// MemoryLeakStatus is GET /api/chaos/memory
+type MemoryLeakStatus struct {
+	Active      bool       `json:"active"`
+	MBPerMinute int        `json:"mb_per_minute"`
+	CapMB       int        `json:"cap_mb"`
+	LeakedBytes int64      `json:"leaked_bytes"`
+	StartedAt   *time.Time `json:"started_at,omitempty"`
+}
+
+// memoryLeak holds the leaked memory and the goroutine growing it
+type memoryLeak struct {
+	mu        sync.Mutex
+	chunks    [][]byte
+	leaked    int64
+	rateMB    int
+	capMB     int
+	startedAt time.Time
+	stop      chan struct{} // nil when not growing
+}
+
+var leak = &memoryLeak{}
+
+// start grows the leak by rateMB per minute until capMB. Calling it again
+// changes the rate and cap and keeps what's already leaked.
+func (l *memoryLeak) start(rateMB, capMB int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.stop != nil {
+		close(l.stop)
+	}
+	l.rateMB, l.capMB, l.startedAt = rateMB, capMB, time.Now().UTC()
+	l.stop = make(chan struct{})
+	go l.run(l.stop)
+	slog.Warn("memory leak started", "mb_per_minute", rateMB, "cap_mb", capMB)
+}
+
+// run adds a chunk every leakInterval until stopped or at the cap
+func (l *memoryLeak) run(stop chan struct{}) {
+	ticker := time.NewTicker(leakInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			if l.grow() {
+				return
+			}
+		}
+	}
+}
+
+// grow leaks one interval's worth and reports whether the cap is reached
+func (l *memoryLeak) grow() bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	limit := int64(l.capMB) << 20
+	size := min(int64(l.rateMB)<<20/int64(time.Minute/leakInterval), limit-l.leaked)
+	if size > 0 {
+		chunk := make([]byte, size)
+		// Touch every page, or the OS never actually hands the memory over
+		for i := 0; i < len(chunk); i += 4096 {
+			chunk[i] = 1
+		}
+		l.chunks = append(l.chunks, chunk)
+		l.leaked += size
+		memoryLeakedBytes.Set(float64(l.leaked))
+	}
+	if l.leaked >= limit {
+		slog.Warn("memory leak reached its cap", "cap_mb", l.capMB)
+		return true
+	}
+	return false
+}
+
+// release stops the leak and returns the memory to the OS
+func (l *memoryLeak) release() {
+	l.mu.Lock()
+	if l.stop != nil {
+		close(l.stop)
+		l.stop = nil
+	}
+	freed := l.leaked
+	l.chunks, l.leaked = nil, 0
+	l.startedAt = time.Time{}
+	memoryLeakedBytes.Set(0)
+	l.mu.Unlock()
+
+	debug.FreeOSMemory() // a GC, then unused memory goes back right away
+	slog.Info("memory leak released", "freed_bytes", freed)
+}
+
+// leakedBytes is how much is leaked right now, for /api/system
+func (l *memoryLeak) leakedBytes() int64 {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.leaked
+}
+
+// status is the leak's state for the API
+func (l *memoryLeak) status() MemoryLeakStatus {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	status := MemoryLeakStatus{
+		Active:      l.stop != nil && l.leaked < int64(l.capMB)<<20,
+		MBPerMinute: l.rateMB,
+		CapMB:       l.capMB,
+		LeakedBytes: l.leaked,
+	}
+	if !l.startedAt.IsZero() {
+		startedAt := l.startedAt
+		status.StartedAt = &startedAt
+	}
+	return status
+}
+
+// parseLeakParams checks a rate and cap in MB
+func parseLeakParams(rate, capMB int) error {
+	if rate < 1 || rate > maxLeakMBPerMinute {
+		return fmt.Errorf("mb_per_minute must be between 1 and %d", maxLeakMBPerMinute)
+	}
+	if capMB < 1 || capMB > maxLeakCapMB {
+		return fmt.Errorf("cap_mb must be between 1 and %d", maxLeakCapMB)
+	}
+	return nil
+}
+
+// startMemoryLeakFromEnv starts leaking at boot when
+// MEMORY_LEAK_MB_PER_MINUTE is set
+func startMemoryLeakFromEnv() {
+	rate := envInt("MEMORY_LEAK_MB_PER_MINUTE", 0)
+	if rate == 0 {
+		return
+	}
+	capMB := envInt("MEMORY_LEAK_CAP_MB", defaultLeakCapMB)
+	if err := parseLeakParams(rate, capMB); err != nil {
+		slog.Warn("memory leak not started", "error", err)
+		return
+	}
+	leak.start(rate, capMB)
+}
+
+// chaosMemoryHandler handles /api/chaos/memory:
+//
+//	GET    -> leak status
+//	POST   -> start (or change) the leak: ?mb_per_minute=&cap_mb=
+//	DELETE -> stop and free everything
+func chaosMemoryHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	if !chaosAllowed(w) {
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		json.NewEncoder(w).Encode(leak.status())
+	case http.MethodPost:
+		query := r.URL.Query()
+		rate, err := strconv.Atoi(query.Get("mb_per_minute"))
+		if err != nil {
+			writeJSONError(w, http.StatusBadRequest, "mb_per_minute is required (a whole number of MB)")
+			return
+		}
+		capMB := defaultLeakCapMB
+		if value := query.Get("cap_mb"); value != "" {
+			if capMB, err = strconv.Atoi(value); err != nil {
+				writeJSONError(w, http.StatusBadRequest, "cap_mb must be a whole number of MB")
+				return
+			}
+		}
+		if err := parseLeakParams(rate, capMB); err != nil {
+			writeJSONError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		leak.start(rate, capMB)
+		w.WriteHeader(http.StatusAccepted)
+		json.NewEncoder(w).Encode(leak.status())
+	case http.MethodDelete:
+		leak.release()
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		http.Error(w, `{"error":"method not allowed"}`, http.StatusMethodNotAllowed)
+	}
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestMemoryLeak_GrowsToCap(t *testing.T) {
+	l := &memoryLeak{rateMB: 120, capMB: 3} // 2 MB a second
+	t.Cleanup(func() { memoryLeakedBytes.Set(0) })
+
+	if l.grow() || l.leaked != 2<<20 {
+		t.Fatalf("after one interval: leaked %d", l.leaked)
+	}
+	if !l.grow() || l.leaked != 3<<20 {
+		t.Errorf("at the cap: leaked %d, want exactly 3 MB", l.leaked)
+	}
+	if got := testutil.ToFloat64(memoryLeakedBytes); got != 3<<20 {
+		t.Errorf("demoapp_memory_leaked_bytes = %v", got)
+	}
+
+	l.release()
+	if l.leaked != 0 || l.chunks != nil || testutil.ToFloat64(memoryLeakedBytes) != 0 {
+		t.Errorf("after release: leaked %d", l.leaked)
+	}
+}
+
+func TestChaosMemoryHandler(t *testing.T) {
+	srv := newTestServer(t)
+	t.Cleanup(leak.release)
+
+	if code, _ := doRequest(t, srv, http.MethodPost, "/api/chaos/memory?mb_per_minute=10", ""); code != http.StatusForbidden {
+		t.Errorf("with CHAOS_ENABLED off = %d, want 403", code)
+	}
+
+	prev := chaosEnabled
+	chaosEnabled = true
+	t.Cleanup(func() { chaosEnabled = prev })
+
+	for _, query := range []string{"", "mb_per_minute=0", "mb_per_minute=10&cap_mb=x", "mb_per_minute=10&cap_mb=999999"} {
+		if code, _ := doRequest(t, srv, http.MethodPost, "/api/chaos/memory?"+query, ""); code != http.StatusBadRequest {
+			t.Errorf("?%s = %d, want 400", query, code)
+		}
+	}
+
+	code, body := doRequest(t, srv, http.MethodPost, "/api/chaos/memory?mb_per_minute=1&cap_mb=1", "")
+	if code != http.StatusAccepted || !strings.Contains(string(body), `"active":true`) || !strings.Contains(string(body), `"cap_mb":1`) {
+		t.Errorf("POST = %d %s", code, body)
+	}
+	if code, _ := doRequest(t, srv, http.MethodDelete, "/api/chaos/memory", ""); code != http.StatusNoContent {
+		t.Errorf("DELETE = %d", code)
+	}
+	if _, body := doRequest(t, srv, http.MethodGet, "/api/chaos/memory", ""); !strings.Contains(string(body), `"active":false`) {
+		t.Errorf("GET after DELETE = %s", body)
+	}
+}
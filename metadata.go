@@ -0,0 +1,145 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// =============================================================================
+// Item Metadata
+// =============================================================================
+//
+// Items can carry a free-form "metadata" object for anything that doesn't fit
+// name/description/tags — instance details, Terraform outputs, build info:
+//
+//	{"name": "web-1", "metadata": {"region": "us-east-1", "instance": {"type": "t3.micro"}}}
+//
+// GET /api/items filters on it with meta.<path>=<value> query parameters.
+// The path walks nested objects with dots, like a tiny JSONPath:
+//
+//	GET /api/items?meta.region=us-east-1
+//	GET /api/items?meta.instance.type=t3.micro&meta.region=us-east-1   (both must match)
+//	GET /api/items?meta.region=us-east-1&meta.region=us-west-2         (either region)
+//
+// Values compare as text: numbers and booleans match their JSON spelling
+// (meta.replicas=3, meta.public=true). When the path ends at an array, any
+// element may match. Filtering happens while scanning the items — there is
+// no index, which is fine at demo scale.
+
+// Largest metadata object accepted, as encoded JSON
+const maxMetadataBytes = 16 << 10 // 16 KiB
+
+// Query parameter prefix for metadata filters
+const metadataFilterPrefix = "meta."
+
+// validateMetadata checks the size of an item's metadata
+func validateMetadata(metadata map[string]any) error {
+	if len(metadata) == 0 {
+		return nil
+	}
+	data, err := json.Marshal(metadata)
+	if err != nil {
+		return err
+	}
+	if len(data) > maxMetadataBytes {
+		return fmt.Errorf("must be at most %d bytes of JSON", maxMetadataBytes)
+	}
+	return nil
+}
+
+// metadataCondition is one meta.<path> parameter: the item matches if the
+// value at path equals any of values
+type metadataCondition struct {
+	path   []string
+	values []string
+}
+
+// metadataFilter holds every condition from the query; all must match
+type metadataFilter []metadataCondition
+
+// parseMetadataFilter collects the meta.* parameters from a query string.
+// Returns nil (matches everything) when there are none.
+func parseMetadataFilter(query url.Values) (metadataFilter, error) {
+	var filter metadataFilter
+	for param, values := range query {
+		rawPath, ok := strings.CutPrefix(param, metadataFilterPrefix)
+		if !ok {
+			continue
+		}
+		path := strings.Split(rawPath, ".")
+		for _, segment := range path {
+			if segment == "" {
+				return nil, fmt.Errorf("invalid metadata filter %q", param)
+			}
+		}
+		filter = append(filter, metadataCondition{path: path, values: values})
+	}
+	// Map order is random; keep it stable so behavior is predictable
+	sort.Slice(filter, func(i, j int) bool {
+		return strings.Join(filter[i].path, ".") < strings.Join(filter[j].path, ".")
+	})
+	return filter, nil
+}
+
+// matches reports whether item satisfies every condition
+func (f metadataFilter) matches(item Item) bool {
+	for _, cond := range f {
+		if !cond.matches(item.Metadata) {
+			return false
+		}
+	}
+	return true
+}
+
+// matches walks the path into metadata and compares what it finds
+func (c metadataCondition) matches(metadata map[string]any) bool {
+	var current any = metadata
+	for _, segment := range c.path {
+		object, ok := current.(map[string]any)
+		if !ok {
+			return false
+		}
+		if current, ok = object[segment]; !ok {
+			return false
+		}
+	}
+
+	// An array matches if any element does, e.g. meta.zones=us-east-1a
+	candidates := []any{current}
+	if list, ok := current.([]any); ok {
+		candidates = list
+	}
+	for _, candidate := range candidates {
+		text, ok := metadataValueString(candidate)
+		if !ok {
+			continue
+		}
+		for _, want := range c.values {
+			if text == want {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// metadataValueString spells a decoded JSON scalar the way it appears in
+// JSON (3, true, null); objects and arrays have no single spelling
+func metadataValueString(v any) (string, bool) {
+	switch v := v.(type) {
+	case string:
+		return v, true
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64), true
+	case bool:
+		return strconv.FormatBool(v), true
+	case nil:
+		return "null", true
+	default:
+		return "", false
+	}
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestMetadataFilter_Matches(t *testing.T) {
+	var metadata map[string]any
+	json.Unmarshal([]byte(`{
+		"region": "us-east-1",
+		"replicas": 3,
+		"public": true,
+		"owner": null,
+		"instance": {"type": "t3.micro"},
+		"zones": ["us-east-1a", "us-east-1b"]
+	}`), &metadata)
+	item := Item{Metadata: metadata}
+
+	tests := []struct {
+		query string
+		want  bool
+	}{
+		{"", true},
+		{"meta.region=us-east-1", true},
+		{"meta.region=us-west-2", false},
+		{"meta.region=us-west-2&meta.region=us-east-1", true}, // either value
+		{"meta.region=us-east-1&meta.public=false", false},    // all conditions
+		{"meta.replicas=3", true},
+		{"meta.public=true", true},
+		{"meta.owner=null", true},
+		{"meta.instance.type=t3.micro", true},
+		{"meta.instance=t3.micro", false}, // an object never matches
+		{"meta.zones=us-east-1b", true},
+		{"meta.missing=x", false},
+		{"meta.region.deeper=x", false},
+		{"name=ignored", true},
+	}
+	for _, tt := range tests {
+		query, _ := url.ParseQuery(tt.query)
+		filter, err := parseMetadataFilter(query)
+		if err != nil {
+			t.Fatalf("parseMetadataFilter(%q): %v", tt.query, err)
+		}
+		if got := filter.matches(item); got != tt.want {
+			t.Errorf("%q matches = %v, want %v", tt.query, got, tt.want)
+		}
+	}
+
+	if _, err := parseMetadataFilter(url.Values{"meta.a..b": {"x"}}); err == nil {
+		t.Error("expected an error for an empty path segment")
+	}
+}
+
+func TestItems_MetadataFilter(t *testing.T) {
+	srv := newTestServer(t)
+
+	east := createTestItem(t, srv, `{"name":"web-1","metadata":{"region":"us-east-1","instance":{"type":"t3.micro"}}}`)
+	createTestItem(t, srv, `{"name":"web-2","metadata":{"region":"us-west-2","instance":{"type":"t3.micro"}}}`)
+	createTestItem(t, srv, `{"name":"plain"}`)
+
+	if east.Metadata["region"] != "us-east-1" {
+		t.Fatalf("metadata = %v", east.Metadata)
+	}
+
+	tests := []struct {
+		query     string
+		wantNames []string
+	}{
+		{"meta.region=us-east-1", []string{"web-1"}},
+		{"meta.instance.type=t3.micro", []string{"web-1", "web-2"}},
+		{"meta.instance.type=t3.micro&meta.region=us-west-2", []string{"web-2"}},
+		{"meta.region=eu-west-1", []string{}},
+	}
+	for _, tt := range tests {
+		_, body := doRequest(t, srv, http.MethodGet, "/api/items?"+tt.query, "")
+		var items []Item
+		if err := json.Unmarshal(body, &items); err != nil {
+			t.Fatalf("%s: invalid JSON %s", tt.query, body)
+		}
+		names := []string{}
+		for _, item := range items {
+			names = append(names, item.Name)
+		}
+		if fmt.Sprint(names) != fmt.Sprint(tt.wantNames) {
+			t.Errorf("%s = %v, want %v", tt.query, names, tt.wantNames)
+		}
+	}
+
+	// Streaming applies the same filter
+	_, body := doRequest(t, srv, http.MethodGet, "/api/items?stream=true&meta.region=us-west-2", "")
+	if lines := strings.Split(strings.TrimSpace(string(body)), "\n"); len(lines) != 1 || !strings.Contains(lines[0], "web-2") {
+		t.Errorf("stream = %s, want only web-2", body)
+	}
+
+	// PUT replaces the metadata
+	_, body = doRequest(t, srv, http.MethodPut, fmt.Sprintf("/api/items/%d", east.ID), `{"name":"web-1","metadata":{"region":"eu-west-1"}}`)
+	if !strings.Contains(string(body), `"metadata":{"region":"eu-west-1"}`) {
+		t.Errorf("update = %s", body)
+	}
+}
+
+func TestItems_MetadataErrors(t *testing.T) {
+	srv := newTestServer(t)
+
+	tests := []struct {
+		name       string
+		method     string
+		path       string
+		body       string
+		wantStatus int
+	}{
+		{"metadata not an object", http.MethodPost, "/api/items", `{"name":"x","metadata":["a"]}`, http.StatusBadRequest},
+		{"metadata too large", http.MethodPost, "/api/items", `{"name":"x","metadata":{"blob":"` + strings.Repeat("x", maxMetadataBytes) + `"}}`, http.StatusUnprocessableEntity},
+		{"empty filter path", http.MethodGet, "/api/items?meta.=x", "", http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, body := doRequest(t, srv, tt.method, tt.path, tt.body)
+			if code != tt.wantStatus {
+				t.Errorf("status = %d, want %d (body %s)", code, tt.wantStatus, body)
+			}
+		})
+	}
+}
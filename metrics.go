@@ -1,6 +1,10 @@
 package main
 
 import (
+	"fmt"
+	"strconv"
+	"time"
+
 	"github.com/prometheus/client_golang/prometheus"
 )
 
@@ -19,15 +23,57 @@ var (
 	)
 
 	// httpRequestDuration tracks response time distribution
-	// Histogram automatically creates buckets (0.005s, 0.01s, 0.025s, ... 10s)
-	// Labels: method and path (not status, since we don't know status until response)
-	httpRequestDuration = prometheus.NewHistogramVec(
+	// Default buckets are 0.005s, 0.01s, 0.025s, ... 10s; METRICS_BUCKETS and
+	// METRICS_NATIVE_HISTOGRAMS replace it at startup (configureRequestDuration)
+	// Labels: method, path, and status_class ("2xx", "5xx"): the class, not
+	// the exact code, so each route has a handful of series, not dozens.
+	// Observations from traced requests carry the trace ID as an exemplar
+	// (observeRequestDuration in middleware.go).
+	httpRequestDuration = newRequestDurationHistogram(prometheus.DefBuckets, false)
+
+	// httpInflightRequests is how many requests are being handled right now.
+	// Rises under load or when handlers slow down — pairs well with
+	// MAX_INFLIGHT_REQUESTS and latency injection demos.
+	httpInflightRequests = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "demoapp_http_inflight_requests",
+			Help: "Number of HTTP requests currently being handled",
+		},
+	)
+
+	// handlerErrorsTotal counts errors handlers hit (and logged), by handler
+	// and error class — so dashboards can alert on "database errors in items"
+	// without scraping logs. Incremented via logHandlerError in handlers.go.
+	handlerErrorsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "demoapp_handler_errors_total",
+			Help: "Total number of errors encountered by HTTP handlers",
+		},
+		[]string{"handler", "reason"},
+	)
+
+	// dbOperationDuration times BadgerDB transactions by operation
+	// (item_get, item_insert, job_save, ...). Buckets start lower than the
+	// HTTP defaults because most K/V operations take well under a millisecond.
+	dbOperationDuration = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "demoapp_db_operation_duration_seconds",
+			Help:    "BadgerDB transaction duration in seconds",
+			Buckets: []float64{.0001, .00025, .0005, .001, .0025, .005, .01, .025, .05, .1, .25, .5, 1},
+		},
+		[]string{"op"},
+	)
+
+	// dbWriteDuration times read-write transactions, commit included, by
+	// durability mode (fast, sync, or memory; see durability.go). Compare
+	// the modes in a durability vs performance demo.
+	dbWriteDuration = prometheus.NewHistogramVec(
 		prometheus.HistogramOpts{
-			Name:    "demoapp_http_request_duration_seconds",
-			Help:    "HTTP request duration in seconds",
-			Buckets: prometheus.DefBuckets, // Default: .005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10
+			Name:    "demoapp_db_write_duration_seconds",
+			Help:    "BadgerDB read-write transaction duration in seconds, by durability mode",
+			Buckets: []float64{.0001, .00025, .0005, .001, .0025, .005, .01, .025, .05, .1, .25, .5, 1},
 		},
-		[]string{"method", "path"},
+		[]string{"durability", "op"},
 	)
 
 	// itemsTotal is a gauge showing current item count
@@ -48,6 +94,250 @@ var (
 		},
 	)
 
+	// displayReplicationsTotal counts display updates sent to peer replicas
+	// in cluster mode (displaysync.go), by result: "success" or "error"
+	displayReplicationsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "demoapp_display_replications_total",
+			Help: "Total number of display panel updates sent to peer replicas",
+		},
+		[]string{"result"},
+	)
+
+	// displaySourceFetchesTotal counts polls of DISPLAY_SOURCE_URL
+	// (displaysource.go), by result: updated, unchanged, skipped, or failed
+	displaySourceFetchesTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "demoapp_display_source_fetches_total",
+			Help: "Total number of display source fetches",
+		},
+		[]string{"result"},
+	)
+
+	// circuitState is each outbound host's breaker (circuitbreaker.go):
+	// 0 closed, 1 half-open, 2 open
+	circuitState = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "demoapp_circuit_state",
+			Help: "Circuit breaker state per host (0 closed, 1 half-open, 2 open)",
+		},
+		[]string{"circuit"},
+	)
+
+	// circuitRejectionsTotal counts calls refused because a circuit was open
+	circuitRejectionsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "demoapp_circuit_rejections_total",
+			Help: "Total number of outbound calls rejected by an open circuit",
+		},
+		[]string{"circuit"},
+	)
+
+	// outboundRetriesTotal counts retried outbound calls per host (outbound.go)
+	outboundRetriesTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "demoapp_outbound_retries_total",
+			Help: "Total number of outbound HTTP calls that were retried",
+		},
+		[]string{"host"},
+	)
+
+	// eventsPublishedTotal counts events sent to EVENT_SINK (events.go),
+	// by result: published, failed, or dropped (queue full)
+	eventsPublishedTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "demoapp_events_published_total",
+			Help: "Total number of events sent to the event sink",
+		},
+		[]string{"sink", "result"},
+	)
+
+	// queueMessagesTotal counts messages from QUEUE_URL (queueconsumer.go),
+	// by result: created, duplicate, rejected, or retried
+	queueMessagesTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "demoapp_queue_messages_total",
+			Help: "Total number of queue messages handled by the queue consumer",
+		},
+		[]string{"result"},
+	)
+
+	// alertsSentTotal counts alerts (alerts.go) by notifier and result:
+	// sent, failed, or dropped (queue full)
+	alertsSentTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "demoapp_alerts_sent_total",
+			Help: "Total number of alerts sent to Slack or email",
+		},
+		[]string{"notifier", "result"},
+	)
+
+	// requestsShedTotal counts requests rejected with 503 by the in-flight limit
+	// (MAX_INFLIGHT_REQUESTS, see concurrencyLimitMiddleware in middleware.go)
+	requestsShedTotal = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Name: "demoapp_requests_shed_total",
+			Help: "Total number of requests rejected because the in-flight limit was reached",
+		},
+	)
+
+	// requestTimeoutsTotal counts requests answered 504 because they ran past
+	// their deadline (REQUEST_TIMEOUT, see timeout.go)
+	requestTimeoutsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "demoapp_request_timeouts_total",
+			Help: "Total number of requests that ran past their deadline",
+		},
+		[]string{"path"},
+	)
+
+	// panicsTotal counts handler panics turned into 500s by
+	// recoveryMiddleware (middleware.go)
+	panicsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "demoapp_panics_total",
+			Help: "Total number of panics recovered while handling requests",
+		},
+		[]string{"path"},
+	)
+
+	// memoryLeakedBytes is how much the memory leak simulation holds
+	// (memoryleak.go); 0 when it isn't running
+	memoryLeakedBytes = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "demoapp_memory_leaked_bytes",
+			Help: "Bytes held by the memory leak simulation",
+		},
+	)
+
+	// tenantRequestsTotal counts requests per tenant (MULTI_TENANT, see
+	// tenant.go); names nobody has written to yet share the "other" tenant
+	tenantRequestsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "demoapp_tenant_requests_total",
+			Help: "Total number of HTTP requests per tenant",
+		},
+		[]string{"tenant", "status_class"},
+	)
+
+	// tenantItemsTotal is each tenant's item count; the default tenant's is
+	// demoapp_items_total
+	tenantItemsTotal = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "demoapp_tenant_items_total",
+			Help: "Current number of items per tenant",
+		},
+		[]string{"tenant"},
+	)
+
+	// quotaRejectionsTotal counts requests refused for going over a quota
+	// (quota.go), by quota: max_items, max_storage_bytes, or max_rps
+	quotaRejectionsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "demoapp_quota_rejections_total",
+			Help: "Total number of requests rejected for exceeding a quota",
+		},
+		[]string{"quota"},
+	)
+
+	// itemValueBytesTotal counts item value bytes written, before ("raw")
+	// and after ("stored") compression (compression.go)
+	itemValueBytesTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "demoapp_item_value_bytes_total",
+			Help: "Total item value bytes written, as JSON (raw) and as stored",
+		},
+		[]string{"form"},
+	)
+
+	// itemCompressionRatio is raw/stored size for each compressed item value
+	itemCompressionRatio = prometheus.NewHistogram(
+		prometheus.HistogramOpts{
+			Name:    "demoapp_item_compression_ratio",
+			Help:    "Uncompressed to compressed size of compressed item values",
+			Buckets: []float64{1.1, 1.25, 1.5, 2, 3, 5, 10},
+		},
+	)
+
+	// standbyShipmentsTotal counts backups shipped to a warm standby, by
+	// result (standby.go)
+	standbyShipmentsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "demoapp_standby_shipments_total",
+			Help: "Total incremental backups shipped to the standby, by result",
+		},
+		[]string{"result"},
+	)
+
+	// shadowWritesTotal counts item writes mirrored to SHADOW_STORE, by op
+	// (put, delete) and result (shadow.go)
+	shadowWritesTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "demoapp_shadow_writes_total",
+			Help: "Total item writes mirrored to the shadow store, by op and result",
+		},
+		[]string{"op", "result"},
+	)
+
+	// accessDecisionsTotal counts ACCESS_POLICY_FILE decisions: allow, deny,
+	// and would_deny in audit mode (accesspolicy.go)
+	accessDecisionsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "demoapp_access_decisions_total",
+			Help: "Total requests checked against the access policy, by decision",
+		},
+		[]string{"decision"},
+	)
+
+	// spiffeInfo is always 1 for this workload's SPIFFE ID, when
+	// SPIFFE_ENDPOINT_SOCKET is set (spiffe.go). Join on it to label other
+	// series by identity.
+	spiffeInfo = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "demoapp_spiffe_info",
+			Help: "Workload SPIFFE ID from the Workload API (always 1)",
+		},
+		[]string{"spiffe_id"},
+	)
+
+	// spiffeSVIDExpiry is when the current X.509 SVID expires
+	spiffeSVIDExpiry = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "demoapp_spiffe_svid_expiry_timestamp_seconds",
+			Help: "Unix time the current X.509 SVID expires",
+		},
+	)
+
+	// secretRefreshesTotal counts reads of SECRETS_FILE and Vault, by source
+	// and result (secrets.go)
+	secretRefreshesTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "demoapp_secret_refreshes_total",
+			Help: "Total secret source reads, by source and result",
+		},
+		[]string{"source", "result"},
+	)
+
+	// configReloadsTotal counts reloads after a watched config file changed,
+	// by component and result (configwatch.go)
+	configReloadsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "demoapp_config_reloads_total",
+			Help: "Total reloads of changed config files, by component and result",
+		},
+		[]string{"component", "result"},
+	)
+
+	// variantInfo is always 1 for the active VARIANT (blue/green/canary demos).
+	// Not set at all when VARIANT is empty. See variant.go.
+	variantInfo = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "demoapp_variant_info",
+			Help: "Deployment variant (always 1)",
+		},
+		[]string{"variant"},
+	)
+
 	// buildInfo is a gauge that's always 1, with labels for version info
 	// This is a common Prometheus pattern for exposing build metadata
 	buildInfo = prometheus.NewGaugeVec(
@@ -64,11 +354,94 @@ var (
 func init() {
 	prometheus.MustRegister(httpRequestsTotal)
 	prometheus.MustRegister(httpRequestDuration)
+	prometheus.MustRegister(httpInflightRequests)
+	prometheus.MustRegister(handlerErrorsTotal)
+	prometheus.MustRegister(dbOperationDuration)
+	prometheus.MustRegister(dbWriteDuration)
 	prometheus.MustRegister(itemsTotal)
 	prometheus.MustRegister(displayUpdatesTotal)
+	prometheus.MustRegister(displayReplicationsTotal)
+	prometheus.MustRegister(displaySourceFetchesTotal)
+	prometheus.MustRegister(circuitState)
+	prometheus.MustRegister(circuitRejectionsTotal)
+	prometheus.MustRegister(outboundRetriesTotal)
+	prometheus.MustRegister(eventsPublishedTotal)
+	prometheus.MustRegister(queueMessagesTotal)
+	prometheus.MustRegister(alertsSentTotal)
+	prometheus.MustRegister(requestsShedTotal)
+	prometheus.MustRegister(requestTimeoutsTotal)
+	prometheus.MustRegister(panicsTotal)
+	prometheus.MustRegister(memoryLeakedBytes)
+	prometheus.MustRegister(tenantRequestsTotal)
+	prometheus.MustRegister(tenantItemsTotal)
+	prometheus.MustRegister(quotaRejectionsTotal)
+	prometheus.MustRegister(itemValueBytesTotal)
+	prometheus.MustRegister(itemCompressionRatio)
+	prometheus.MustRegister(standbyShipmentsTotal)
+	prometheus.MustRegister(shadowWritesTotal)
+	prometheus.MustRegister(accessDecisionsTotal)
+	prometheus.MustRegister(spiffeInfo)
+	prometheus.MustRegister(spiffeSVIDExpiry)
+	prometheus.MustRegister(secretRefreshesTotal)
+	prometheus.MustRegister(configReloadsTotal)
+	prometheus.MustRegister(variantInfo)
 	prometheus.MustRegister(buildInfo)
 
 	// Set build info (always 1, labels carry the metadata)
-	// TODO: Set version from build flags in CI/CD
-	buildInfo.WithLabelValues("dev").Set(1)
+	// version comes from -ldflags at build time (see main.go)
+	buildInfo.WithLabelValues(version).Set(1)
+}
+
+// newRequestDurationHistogram builds demoapp_http_request_duration_seconds.
+//
+// native adds a Prometheus native histogram: instead of fixed buckets, the
+// client keeps exponential buckets (each up to 10% wider than the last)
+// sized to whatever it observes, so 80µs and 8s responses both get useful
+// resolution. Prometheus only scrapes them with
+// --enable-feature=native-histograms; the classic buckets are still
+// exposed alongside for everything else.
+func newRequestDurationHistogram(buckets []float64, native bool) *prometheus.HistogramVec {
+	opts := prometheus.HistogramOpts{
+		Name:    "demoapp_http_request_duration_seconds",
+		Help:    "HTTP request duration in seconds",
+		Buckets: buckets,
+	}
+	if native {
+		opts.NativeHistogramBucketFactor = 1.1
+		opts.NativeHistogramMaxBucketNumber = 160
+		opts.NativeHistogramMinResetDuration = time.Hour
+	}
+	return prometheus.NewHistogramVec(opts, []string{"method", "path", "status_class"})
+}
+
+// configureRequestDuration swaps httpRequestDuration for one with these
+// buckets (nil keeps the defaults) and native histograms on or off. Call
+// it at startup, before requests are observed.
+func configureRequestDuration(buckets []float64, native bool) {
+	if buckets == nil {
+		buckets = prometheus.DefBuckets
+	}
+	prometheus.Unregister(httpRequestDuration)
+	httpRequestDuration = newRequestDurationHistogram(buckets, native)
+	prometheus.MustRegister(httpRequestDuration)
+}
+
+// parseBuckets reads METRICS_BUCKETS: upper bounds in seconds, in
+// increasing order ("0.0001,0.0005,0.001,0.005,0.01,0.1,1")
+func parseBuckets(values []string) ([]float64, error) {
+	if len(values) == 0 {
+		return nil, nil
+	}
+	buckets := make([]float64, 0, len(values))
+	for _, value := range values {
+		bound, err := strconv.ParseFloat(value, 64)
+		if err != nil || bound <= 0 {
+			return nil, fmt.Errorf("bucket %q is not a positive number of seconds", value)
+		}
+		if len(buckets) > 0 && bound <= buckets[len(buckets)-1] {
+			return nil, fmt.Errorf("buckets must increase (%s after %g)", value, buckets[len(buckets)-1])
+		}
+		buckets = append(buckets, bound)
+	}
+	return buckets, nil
 }
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+func TestParseBuckets(t *testing.T) {
+	buckets, err := parseBuckets([]string{"0.0001", "0.0005", "0.001"})
+	if err != nil || len(buckets) != 3 || buckets[0] != 0.0001 {
+		t.Errorf("parseBuckets = %v, %v", buckets, err)
+	}
+	if buckets, err := parseBuckets(nil); buckets != nil || err != nil {
+		t.Errorf("no METRICS_BUCKETS = %v, %v; want the defaults (nil)", buckets, err)
+	}
+	for _, bad := range [][]string{{"0.1", "0.05"}, {"0.1", "0.1"}, {"fast"}, {"-1"}} {
+		if _, err := parseBuckets(bad); err == nil {
+			t.Errorf("parseBuckets(%v): want an error", bad)
+		}
+	}
+}
+
+// observedHistogram observes one 80µs request and returns the histogram
+// as Prometheus would scrape it
+func observedHistogram(t *testing.T) *dto.Histogram {
+	t.Helper()
+	r := httptest.NewRequest(http.MethodGet, "/api/items", nil)
+	observeRequestDuration(r, "/api/items", http.StatusOK, 80*time.Microsecond)
+
+	var m dto.Metric
+	observer := httpRequestDuration.WithLabelValues(http.MethodGet, "/api/items", "2xx")
+	if err := observer.(prometheus.Metric).Write(&m); err != nil {
+		t.Fatal(err)
+	}
+	return m.GetHistogram()
+}
+
+func TestConfigureRequestDuration(t *testing.T) {
+	t.Cleanup(func() { configureRequestDuration(nil, false) })
+
+	configureRequestDuration([]float64{0.0001, 0.001}, false)
+	h := observedHistogram(t)
+	if len(h.GetBucket()) != 2 || h.GetBucket()[0].GetUpperBound() != 0.0001 || h.GetBucket()[0].GetCumulativeCount() != 1 {
+		t.Errorf("custom buckets = %v", h.GetBucket())
+	}
+	if len(h.GetPositiveSpan()) != 0 {
+		t.Error("native histogram without METRICS_NATIVE_HISTOGRAMS")
+	}
+
+	configureRequestDuration(nil, true)
+	h = observedHistogram(t)
+	if len(h.GetBucket()) != len(prometheus.DefBuckets) || len(h.GetPositiveSpan()) == 0 {
+		t.Errorf("native: %d classic buckets, spans %v", len(h.GetBucket()), h.GetPositiveSpan())
+	}
+}
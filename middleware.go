@@ -1,11 +1,18 @@
 package main
 
 import (
+	"context"
+	"crypto/subtle"
+	"fmt"
 	"log/slog"
 	"net/http"
+	"os"
+	"runtime/debug"
 	"strconv"
 	"strings"
 	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
 )
 
 // responseRecorder wraps http.ResponseWriter to capture the status code
@@ -22,6 +29,12 @@ func (r *responseRecorder) WriteHeader(code int) {
 	r.ResponseWriter.WriteHeader(code)
 }
 
+// Unwrap returns the original ResponseWriter, so http.NewResponseController
+// can reach optional features like Flush (used by streaming responses)
+func (r *responseRecorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
+
 // loggingMiddleware wraps a handler to log every request and record Prometheus metrics
 // This is the "middleware pattern" — a function that takes a handler and returns a new handler
 // Python equivalent: a decorator that wraps a Flask route
@@ -29,14 +42,32 @@ func loggingMiddleware(next http.HandlerFunc) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		start := time.Now()
 
+		// Track concurrent requests; defer runs even if the handler panics
+		httpInflightRequests.Inc()
+		defer httpInflightRequests.Dec()
+
 		// Wrap the ResponseWriter to capture status code
 		recorder := &responseRecorder{
 			ResponseWriter: w,
 			statusCode:     200, // default if WriteHeader isn't called
 		}
 
-		// Call the actual handler
-		next(recorder, r)
+		// The response envelope reports time taken from here (responseformat.go)
+		r = r.WithContext(context.WithValue(r.Context(), requestStartKey{}, start))
+
+		// Requests from a traced caller carry a traceparent header; put the
+		// trace in the context so log lines get trace_id/span_id (trace.go)
+		if tc, ok := parseTraceparent(r.Header.Get("traceparent")); ok {
+			r = r.WithContext(withTraceContext(r.Context(), tc))
+		}
+
+		// Save writes while the admin request recorder is on (recorder.go)
+		replayRecorder.capture(r)
+
+		// Call the actual handler, with the route's deadline (timeout.go),
+		// scoped to the request's tenant (tenant.go), listing its
+		// transactions when asked to (dbtrace.go)
+		dbTraceMiddleware(timeoutMiddleware(tenantMiddleware(quotaMiddleware(next))))(recorder, r)
 
 		// Calculate duration
 		duration := time.Since(start)
@@ -46,14 +77,37 @@ func loggingMiddleware(next http.HandlerFunc) http.HandlerFunc {
 		metricPath := normalizePath(r.URL.Path)
 
 		// Log the request (original path for debugging)
-		slog.Info("request",
+		attrs := []any{
 			"method", r.Method,
 			"path", r.URL.Path,
 			"status", recorder.statusCode,
 			"latency_ms", duration.Milliseconds(),
 			"client_ip", r.RemoteAddr,
 			"user_agent", r.UserAgent(),
-		)
+		}
+		// Who called, when mutual TLS says so (tls.go)
+		if cert := clientCert(r); cert != nil {
+			attrs = append(attrs, "client_cert", cert.Subject)
+			if cert.SPIFFEID != "" {
+				attrs = append(attrs, "client_spiffe_id", cert.SPIFFEID)
+			}
+		}
+		slog.InfoContext(r.Context(), "request", attrs...)
+
+		// One merge-operator write per API request (hitcounter.go)
+		hitCounter.add(r.URL.Path)
+
+		// Keep it for the status page and Traffic panel (recentrequests.go)
+		if r.URL.Path != recentRequestsPath {
+			recentRequests.add(RecentRequest{
+				Time:      start.UTC(),
+				Method:    r.Method,
+				Path:      r.URL.Path,
+				Status:    recorder.statusCode,
+				LatencyMs: float64(duration.Microseconds()) / 1000,
+				Client:    clientHost(r.RemoteAddr),
+			})
+		}
 
 		// Record Prometheus metrics
 		// These variables are defined in metrics.go but accessible here (same package)
@@ -63,11 +117,59 @@ func loggingMiddleware(next http.HandlerFunc) http.HandlerFunc {
 			strconv.Itoa(recorder.statusCode),
 		).Inc()
 
-		httpRequestDuration.WithLabelValues(
-			r.Method,
-			metricPath,
-		).Observe(duration.Seconds())
+		observeRequestDuration(r, metricPath, recorder.statusCode, duration)
+
+		// Same numbers for GET /api/stats/latency (latencystats.go)
+		latencyStats.record(r.Method, metricPath, duration)
+		// ...and for GET /api/stats/slo (slo.go)
+		recordSLO(r, recorder.statusCode, duration)
+
+		// Requests per tenant with MULTI_TENANT (tenant.go)
+		recordTenantRequest(r, recorder.statusCode)
+
+		// Requests and errors per API key or IP (clientstats.go)
+		client, kind := clientIdentity(r)
+		clientStats.record(client, kind, recorder.statusCode)
+
+		// Error rate for alerting (alerts.go; nil unless an alert notifier is set)
+		if alerts != nil {
+			alerts.recordRequest(recorder.statusCode)
+		}
+
+		// Per-request timer for StatsD agents, which compute their own
+		// percentiles (statsd.go; nil unless METRICS_EXPORTER=statsd)
+		if statsd != nil {
+			statsd.timing("http.request.duration", duration, []string{
+				"method:" + r.Method,
+				"path:" + metricPath,
+				"status:" + strconv.Itoa(recorder.statusCode),
+			})
+		}
+	}
+}
+
+// statusClass groups a status code for metric labels: 404 -> "4xx"
+func statusClass(code int) string {
+	return strconv.Itoa(code/100) + "xx"
+}
+
+// observeRequestDuration records the request in httpRequestDuration. When
+// the request belongs to a sampled trace (trace.go), the observation
+// carries the trace as an exemplar, so Grafana can link from a slow bucket
+// straight to the trace in Tempo. Exemplars only show up when /metrics is
+// scraped as OpenMetrics (Prometheus with --enable-feature=exemplar-storage).
+func observeRequestDuration(r *http.Request, metricPath string, code int, duration time.Duration) {
+	observer := httpRequestDuration.WithLabelValues(r.Method, metricPath, statusClass(code))
+	tc, ok := traceContextFrom(r.Context())
+	if !ok || !tc.sampled() {
+		observer.Observe(duration.Seconds())
+		return
 	}
+	// Histograms from client_golang always implement ExemplarObserver
+	observer.(prometheus.ExemplarObserver).ObserveWithExemplar(duration.Seconds(), prometheus.Labels{
+		"trace_id": tc.TraceID,
+		"span_id":  tc.SpanID,
+	})
 }
 
 // normalizePath replaces dynamic path segments with placeholders
@@ -78,13 +180,217 @@ func loggingMiddleware(next http.HandlerFunc) http.HandlerFunc {
 // for every unique item ID. With millions of items, that's millions of series,
 // which would overwhelm Prometheus.
 func normalizePath(path string) string {
-	// Handle /api/items/:id pattern
-	if strings.HasPrefix(path, "/api/items/") {
-		parts := strings.Split(path, "/")
-		if len(parts) == 4 && parts[3] != "" {
-			// /api/items/123 -> 4 parts: ["", "api", "items", "123"]
-			return "/api/items/:id"
+	// Fixed sub-routes that would otherwise look like /api/items/:id
+	if path == "/api/items/count" {
+		return path
+	}
+
+	// Handle /api/<resource>/:param patterns
+	params := []struct{ resource, param string }{
+		{"items", ":id"},
+		{"jobs", ":id"},
+		{"delay", ":seconds"},
+		{"status", ":code"},
+	}
+	for _, p := range params {
+		prefix := "/api/" + p.resource + "/"
+		if strings.HasPrefix(path, prefix) {
+			parts := strings.Split(path, "/")
+			if len(parts) == 4 && parts[3] != "" {
+				// /api/items/123 -> 4 parts: ["", "api", "items", "123"]
+				return prefix + p.param
+			}
 		}
 	}
+
+	// /api/echo accepts any sub-path; collapse them all into one series
+	if strings.HasPrefix(path, "/api/echo/") {
+		return "/api/echo/*"
+	}
 	return path
 }
+
+// adminMiddleware protects /api/admin/* endpoints.
+//
+// If ADMIN_TOKEN is set, requests must send "Authorization: Bearer <token>".
+// If it isn't set, admin endpoints are open — convenient for local demos,
+// but set a token anywhere the app is reachable by an audience.
+func adminMiddleware(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		token := secret("ADMIN_TOKEN") // secrets.go: Vault or SECRETS_FILE, else the env var
+		// subtle.ConstantTimeCompare avoids leaking how many characters matched
+		// through response timing (a plain == returns early on the first mismatch)
+		given := r.Header.Get("Authorization")
+		if token != "" && subtle.ConstantTimeCompare([]byte(given), []byte("Bearer "+token)) != 1 {
+			w.Header().Set("Content-Type", "application/json")
+			http.Error(w, `{"error":"unauthorized"}`, http.StatusUnauthorized)
+			return
+		}
+		next(w, r)
+	}
+}
+
+// concurrencyLimitMiddleware sheds load once maxInFlight requests are running.
+//
+// The limiter is a buffered channel used as a semaphore: each request puts a
+// token in; when the buffer is full, there's no room and we reject with 503.
+// (Python equivalent: threading.BoundedSemaphore with blocking=False.)
+//
+// /health, /ready, and /metrics are exempt so probes and Prometheus keep
+// working while the app sheds — otherwise an overloaded pod would also look
+// dead.
+//
+// maxInFlight <= 0 disables the limit.
+func concurrencyLimitMiddleware(maxInFlight int, next http.Handler) http.Handler {
+	if maxInFlight <= 0 {
+		return next
+	}
+
+	slots := make(chan struct{}, maxInFlight)
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/health" || r.URL.Path == "/ready" || r.URL.Path == "/metrics" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		select {
+		case slots <- struct{}{}:
+			// Got a slot — release it when the request finishes
+			defer func() { <-slots }()
+			next.ServeHTTP(w, r)
+		default:
+			// No slot free: fail fast instead of queueing
+			requestsShedTotal.Inc()
+			slog.Warn("request shed",
+				"method", r.Method,
+				"path", r.URL.Path,
+				"limit", maxInFlight,
+				"client_ip", r.RemoteAddr,
+			)
+			w.Header().Set("Content-Type", "application/json")
+			w.Header().Set("Retry-After", "1")
+			http.Error(w, `{"error":"server busy"}`, http.StatusServiceUnavailable)
+		}
+	})
+}
+
+// headerWriter remembers whether the response has started, so
+// recoveryMiddleware knows if it can still send a 500
+type headerWriter struct {
+	http.ResponseWriter
+	wroteHeader bool
+}
+
+func (h *headerWriter) WriteHeader(code int) {
+	h.wroteHeader = true
+	h.ResponseWriter.WriteHeader(code)
+}
+
+func (h *headerWriter) Write(b []byte) (int, error) {
+	h.wroteHeader = true
+	return h.ResponseWriter.Write(b)
+}
+
+// Unwrap lets http.NewResponseController reach Flush (see responseRecorder)
+func (h *headerWriter) Unwrap() http.ResponseWriter {
+	return h.ResponseWriter
+}
+
+// recoveryMiddleware turns a panic anywhere below it into a 500 instead of
+// a dropped connection, logs it with the stack, and counts it in
+// demoapp_panics_total.
+//
+// net/http already recovers panics so one bad request can't kill the
+// server, but it only closes the connection and prints to stderr — no JSON
+// error, no structured log, nothing for Prometheus. Panics in goroutines a
+// handler starts aren't covered by either and still crash the process (which
+// is what POST /api/chaos/panic?crash=true relies on, see chaos.go).
+func recoveryMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hw := &headerWriter{ResponseWriter: w}
+		defer func() {
+			p := recover()
+			if p == nil {
+				return
+			}
+			if p == http.ErrAbortHandler {
+				panic(p) // a deliberate abort (httputil.ReverseProxy uses it); let net/http handle it
+			}
+
+			panicsTotal.WithLabelValues(normalizePath(r.URL.Path)).Inc()
+			slog.ErrorContext(r.Context(), "panic in handler",
+				"method", r.Method,
+				"path", r.URL.Path,
+				"panic", fmt.Sprint(p),
+				"stack", string(debug.Stack()),
+			)
+			// Once the status line is out, all we can do is stop
+			if !hw.wroteHeader {
+				writeJSONError(w, http.StatusInternalServerError, "internal server error")
+			}
+		}()
+		next.ServeHTTP(hw, r)
+	})
+}
+
+// responseHeader is one header stamped onto every response
+type responseHeader struct {
+	Name  string
+	Value string
+}
+
+// parseResponseHeaders reads RESPONSE_HEADERS, a comma-separated list of
+// Name=value pairs:
+//
+//	RESPONSE_HEADERS="X-Served-By=${hostname},X-Region=us-east-1,X-Version=${version}"
+//
+// Values can reference environment variables ($POD_NAME, ${REGION}) plus two
+// built-ins: ${hostname} and ${version}. They're expanded once at startup.
+// Entries without "=" are skipped with a warning.
+func parseResponseHeaders(entries []string) []responseHeader {
+	hostname, _ := os.Hostname()
+	lookup := func(name string) string {
+		switch name {
+		case "hostname":
+			return hostname
+		case "version":
+			return version
+		}
+		return os.Getenv(name)
+	}
+
+	var headers []responseHeader
+	for _, entry := range entries {
+		name, value, ok := strings.Cut(entry, "=")
+		name = strings.TrimSpace(name)
+		if !ok || name == "" {
+			slog.Warn("invalid response header, expected Name=value", "entry", entry)
+			continue
+		}
+		headers = append(headers, responseHeader{
+			Name:  http.CanonicalHeaderKey(name),
+			Value: os.Expand(strings.TrimSpace(value), lookup),
+		})
+	}
+	return headers
+}
+
+// responseHeadersMiddleware adds fixed headers to every response, so
+// traffic-splitting and canary demos can be checked with `curl -I` alone.
+//
+// It's the outermost middleware: even shed (503) and rule-mocked responses
+// say which instance produced them.
+func responseHeadersMiddleware(headers []responseHeader, next http.Handler) http.Handler {
+	if len(headers) == 0 {
+		return next
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Headers must be set before the handler writes the status line
+		for _, h := range headers {
+			w.Header().Set(h.Name, h.Value)
+		}
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,187 @@
+package main
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestConcurrencyLimit_ShedsAboveLimit(t *testing.T) {
+	// The wrapped handler blocks until released, holding its slot
+	started := make(chan struct{})
+	release := make(chan struct{})
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/health" {
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+		close(started)
+		<-release
+		w.WriteHeader(http.StatusOK)
+	})
+	handler := concurrencyLimitMiddleware(1, next)
+
+	// Occupy the only slot
+	done := make(chan int)
+	go func() {
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, httptest.NewRequest("GET", "/api/items", nil))
+		done <- rr.Code
+	}()
+	<-started
+
+	before := testutil.ToFloat64(requestsShedTotal)
+
+	// A second request should be shed immediately
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest("GET", "/api/items", nil))
+	if rr.Code != http.StatusServiceUnavailable {
+		t.Errorf("expected status 503, got %d", rr.Code)
+	}
+	if rr.Header().Get("Retry-After") == "" {
+		t.Error("expected Retry-After header")
+	}
+	if got := testutil.ToFloat64(requestsShedTotal) - before; got != 1 {
+		t.Errorf("expected shed counter to increase by 1, got %v", got)
+	}
+
+	// /health is exempt even while the limit is reached
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest("GET", "/health", nil))
+	if rr.Code != http.StatusOK {
+		t.Errorf("expected /health to bypass the limit, got %d", rr.Code)
+	}
+
+	// Once the first request finishes, its slot is free again
+	close(release)
+	if code := <-done; code != http.StatusOK {
+		t.Errorf("expected first request to succeed, got %d", code)
+	}
+}
+
+func TestNormalizePath(t *testing.T) {
+	cases := map[string]string{
+		"/api/items":      "/api/items",
+		"/api/items/42":   "/api/items/:id",
+		"/api/jobs/7":     "/api/jobs/:id",
+		"/api/delay/2.5":  "/api/delay/:seconds",
+		"/api/status/503": "/api/status/:code",
+		"/api/echo/a/b/c": "/api/echo/*",
+		"/api/items/42/x": "/api/items/42/x",
+		"/api/system":     "/api/system",
+	}
+	for path, want := range cases {
+		if got := normalizePath(path); got != want {
+			t.Errorf("normalizePath(%q) = %q, want %q", path, got, want)
+		}
+	}
+}
+
+func TestResponseHeaders_ParseAndApply(t *testing.T) {
+	t.Setenv("DEMO_REGION", "eu-west-1")
+	hostname, _ := os.Hostname()
+
+	headers := parseResponseHeaders([]string{
+		"x-served-by=${hostname}",
+		"X-Region=$DEMO_REGION",
+		"X-Version=${version}",
+		"not-a-header",
+	})
+	if len(headers) != 3 {
+		t.Fatalf("expected 3 headers (invalid entry skipped), got %d", len(headers))
+	}
+
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTeapot)
+	})
+	rr := httptest.NewRecorder()
+	responseHeadersMiddleware(headers, next).ServeHTTP(rr, httptest.NewRequest("GET", "/health", nil))
+
+	want := map[string]string{
+		"X-Served-By": hostname,
+		"X-Region":    "eu-west-1",
+		"X-Version":   version,
+	}
+	for name, value := range want {
+		if got := rr.Header().Get(name); got != value {
+			t.Errorf("%s: expected %q, got %q", name, value, got)
+		}
+	}
+}
+
+func TestLoggingMiddleware_TracksInflight(t *testing.T) {
+	var during float64
+	handler := loggingMiddleware(func(w http.ResponseWriter, r *http.Request) {
+		during = testutil.ToFloat64(httpInflightRequests)
+	})
+
+	before := testutil.ToFloat64(httpInflightRequests)
+	handler(httptest.NewRecorder(), httptest.NewRequest("GET", "/health", nil))
+
+	if during != before+1 {
+		t.Errorf("expected in-flight gauge %v during the request, got %v", before+1, during)
+	}
+	if after := testutil.ToFloat64(httpInflightRequests); after != before {
+		t.Errorf("expected in-flight gauge back to %v, got %v", before, after)
+	}
+}
+
+func TestLogHandlerError_CountsByLabel(t *testing.T) {
+	counter := handlerErrorsTotal.WithLabelValues("items", "database")
+	before := testutil.ToFloat64(counter)
+
+	logHandlerError(context.Background(), "items", "database", "test error")
+
+	if got := testutil.ToFloat64(counter) - before; got != 1 {
+		t.Errorf("expected counter to increase by 1, got %v", got)
+	}
+}
+
+func TestRequestDuration_StatusClassAndExemplar(t *testing.T) {
+	srv := newTestServer(t)
+
+	traceID := "4bf92f3577b34da6a3ce929d0e0e4736"
+	req, _ := http.NewRequest(http.MethodGet, srv.URL+"/api/status/418", nil)
+	req.Header.Set("traceparent", "00-"+traceID+"-00f067aa0ba902b7-01")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	// Exemplars only come with OpenMetrics, which the scraper has to ask for
+	req, _ = http.NewRequest(http.MethodGet, srv.URL+"/metrics", nil)
+	req.Header.Set("Accept", "application/openmetrics-text; version=1.0.0")
+	resp, err = http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+
+	var bucket string
+	for _, line := range strings.Split(string(body), "\n") {
+		if strings.HasPrefix(line, "demoapp_http_request_duration_seconds_bucket{") &&
+			strings.Contains(line, `path="/api/status/:code"`) && strings.Contains(line, `status_class="4xx"`) &&
+			strings.Contains(line, "trace_id=") {
+			bucket = line
+		}
+	}
+	if !strings.Contains(bucket, `trace_id="`+traceID+`"`) {
+		t.Errorf("no 4xx bucket with an exemplar for the trace in:\n%s", body)
+	}
+}
+
+func TestStatusClass(t *testing.T) {
+	for code, want := range map[int]string{200: "2xx", 304: "3xx", 404: "4xx", 504: "5xx"} {
+		if got := statusClass(code); got != want {
+			t.Errorf("statusClass(%d) = %q, want %q", code, got, want)
+		}
+	}
+}
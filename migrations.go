@@ -0,0 +1,219 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	badger "github.com/dgraph-io/badger/v4"
+)
+
+// =============================================================================
+// Schema Migrations
+// =============================================================================
+//
+// A persistent demo database outlives the binary that created it. When the
+// way records are stored changes (key format, new Item fields, ...), the new
+// version has to convert what the old one wrote — the same problem Django
+// or Alembic migrations solve for SQL databases.
+//
+// Each change is a numbered migration in the registry below. The database
+// remembers the highest version applied under the "meta:schema_version" key,
+// and at startup every migration with a higher number runs, in order:
+//
+//	schema_version 0 (new or pre-migrations DB)
+//	  -> 1 pad_item_keys
+//	  -> 2 ...
+//
+// Adding a migration:
+//  1. write a func() (string, error) that converts the data and returns a
+//     short summary ("converted 42 items")
+//  2. append it to the migrations list with the next version number
+//  3. never renumber or remove a migration that has shipped
+//
+// Migrations should be safe to re-run (check before converting): if the app
+// crashes mid-migration the version isn't bumped, and it runs again.
+//
+// GET /api/admin/migrations shows the current version and what ran when.
+
+// Key holding the current schema version (a decimal number)
+const schemaVersionKey = "meta:schema_version"
+
+// Prefix for per-migration records: "migration:1" -> MigrationRecord JSON
+const migrationKeyPrefix = "migration:"
+
+// migration is one registered schema change
+type migration struct {
+	Version int
+	Name    string
+	Up      func() (string, error)
+}
+
+// migrations is the registry, in version order
+var migrations = []migration{
+	{Version: 1, Name: "pad_item_keys", Up: func() (string, error) {
+		// Zero-pad item keys so they sort in ID order (store.go)
+		converted, err := migrateItemKeys()
+		return fmt.Sprintf("converted %d item keys", converted), err
+	}},
+}
+
+// MigrationRecord is stored when a migration has been applied
+type MigrationRecord struct {
+	AppliedAt  time.Time `json:"applied_at"`
+	DurationMS int64     `json:"duration_ms"`
+	Result     string    `json:"result"`
+}
+
+// MigrationStatus is one entry in GET /api/admin/migrations
+type MigrationStatus struct {
+	Version int              `json:"version"`
+	Name    string           `json:"name"`
+	Applied bool             `json:"applied"`
+	Record  *MigrationRecord `json:"record,omitempty"` // nil if not applied
+}
+
+// latestSchemaVersion is the version this binary migrates up to
+func latestSchemaVersion() int {
+	if len(migrations) == 0 {
+		return 0
+	}
+	return migrations[len(migrations)-1].Version
+}
+
+// getSchemaVersion reads the database's schema version (0 if never set)
+func getSchemaVersion() (int, error) {
+	version := 0
+	err := dbView("schema_version", schemaVersionKey, func(txn *badger.Txn) error {
+		entry, err := txn.Get([]byte(schemaVersionKey))
+		if errors.Is(err, badger.ErrKeyNotFound) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		return entry.Value(func(val []byte) error {
+			version, err = strconv.Atoi(string(val))
+			return err
+		})
+	})
+	return version, err
+}
+
+// runMigrations applies every migration newer than the database's version.
+// Each successful migration bumps the version (with its record) in one
+// transaction, so a failure leaves the database at the last good version.
+func runMigrations() error {
+	current, err := getSchemaVersion()
+	if err != nil {
+		return fmt.Errorf("read schema version: %w", err)
+	}
+
+	// A newer binary migrated this database; this one may not understand it
+	if current > latestSchemaVersion() {
+		slog.Warn("database schema is newer than this version of demo-app",
+			"schema_version", current, "latest_known", latestSchemaVersion())
+		return nil
+	}
+
+	for _, m := range migrations {
+		if m.Version <= current {
+			continue
+		}
+
+		slog.Info("running migration", "version", m.Version, "name", m.Name)
+		start := time.Now()
+		result, err := m.Up()
+		if err != nil {
+			return fmt.Errorf("migration %d (%s): %w", m.Version, m.Name, err)
+		}
+
+		record := MigrationRecord{
+			AppliedAt:  time.Now().UTC(),
+			DurationMS: time.Since(start).Milliseconds(),
+			Result:     result,
+		}
+		value, err := json.Marshal(record)
+		if err != nil {
+			return err
+		}
+		err = dbUpdate("schema_version", schemaVersionKey, func(txn *badger.Txn) error {
+			// A migration may have rewritten items (itemsetag.go)
+			if err := touchItemsVersion(txn); err != nil {
+				return err
+			}
+			if err := txn.Set([]byte(migrationKeyPrefix+strconv.Itoa(m.Version)), value); err != nil {
+				return err
+			}
+			return txn.Set([]byte(schemaVersionKey), []byte(strconv.Itoa(m.Version)))
+		})
+		if err != nil {
+			return fmt.Errorf("record migration %d: %w", m.Version, err)
+		}
+
+		slog.Info("migration applied", "version", m.Version, "name", m.Name,
+			"result", result, "duration_ms", record.DurationMS)
+	}
+	return nil
+}
+
+// migrationStatuses lists every registered migration with its record
+func migrationStatuses() ([]MigrationStatus, error) {
+	statuses := make([]MigrationStatus, 0, len(migrations))
+	err := dbView("schema_version", migrationKeyPrefix, func(txn *badger.Txn) error {
+		for _, m := range migrations {
+			status := MigrationStatus{Version: m.Version, Name: m.Name}
+
+			entry, err := txn.Get([]byte(migrationKeyPrefix + strconv.Itoa(m.Version)))
+			if err == nil {
+				var record MigrationRecord
+				err = entry.Value(func(val []byte) error {
+					return json.Unmarshal(val, &record)
+				})
+				if err != nil {
+					return err
+				}
+				status.Applied = true
+				status.Record = &record
+			} else if !errors.Is(err, badger.ErrKeyNotFound) {
+				return err
+			}
+
+			statuses = append(statuses, status)
+		}
+		return nil
+	})
+	return statuses, err
+}
+
+// migrationsAdminHandler handles GET /api/admin/migrations
+func migrationsAdminHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, `{"error":"method not allowed"}`, http.StatusMethodNotAllowed)
+		return
+	}
+
+	current, err := getSchemaVersion()
+	if err != nil {
+		logHandlerError(r.Context(), "admin_migrations", "database", "failed to read schema version", "error", err)
+		http.Error(w, `{"error":"database error"}`, http.StatusInternalServerError)
+		return
+	}
+	statuses, err := migrationStatuses()
+	if err != nil {
+		logHandlerError(r.Context(), "admin_migrations", "database", "failed to read migrations", "error", err)
+		http.Error(w, `{"error":"database error"}`, http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]any{
+		"schema_version": current,
+		"latest_version": latestSchemaVersion(),
+		"migrations":     statuses,
+	})
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	badger "github.com/dgraph-io/badger/v4"
+)
+
+func TestRunMigrations(t *testing.T) {
+	newTestServer(t)
+
+	// Swap in a test registry: one migration that works, counting its runs
+	runs := 0
+	prev := migrations
+	migrations = []migration{
+		{Version: 1, Name: "first", Up: func() (string, error) { runs++; return "ok", nil }},
+	}
+	defer func() { migrations = prev }()
+
+	if err := runMigrations(); err != nil {
+		t.Fatalf("migrations failed: %v", err)
+	}
+	if version, _ := getSchemaVersion(); version != 1 {
+		t.Errorf("expected schema version 1, got %d", version)
+	}
+
+	// Already applied migrations don't run again
+	if err := runMigrations(); err != nil {
+		t.Fatalf("second run failed: %v", err)
+	}
+	if runs != 1 {
+		t.Errorf("expected migration to run once, ran %d times", runs)
+	}
+
+	// A newly registered migration runs on the next startup
+	migrations = append(migrations, migration{Version: 2, Name: "second", Up: func() (string, error) { return "done", nil }})
+	if err := runMigrations(); err != nil {
+		t.Fatalf("third run failed: %v", err)
+	}
+	if version, _ := getSchemaVersion(); version != 2 {
+		t.Errorf("expected schema version 2, got %d", version)
+	}
+}
+
+func TestRunMigrations_FailureKeepsVersion(t *testing.T) {
+	newTestServer(t)
+
+	prev := migrations
+	migrations = []migration{
+		{Version: 1, Name: "good", Up: func() (string, error) { return "ok", nil }},
+		{Version: 2, Name: "bad", Up: func() (string, error) { return "", badger.ErrConflict }},
+	}
+	defer func() { migrations = prev }()
+
+	if err := runMigrations(); err == nil {
+		t.Fatal("expected an error from the failing migration")
+	}
+	// The good migration stays applied; the bad one will be retried
+	if version, _ := getSchemaVersion(); version != 1 {
+		t.Errorf("expected schema version 1 after failure, got %d", version)
+	}
+}
+
+func TestMigrationsAdminHandler(t *testing.T) {
+	newTestServer(t)
+	if err := runMigrations(); err != nil {
+		t.Fatalf("migrations failed: %v", err)
+	}
+
+	req := httptest.NewRequest("GET", "/api/admin/migrations", nil)
+	rr := httptest.NewRecorder()
+	migrationsAdminHandler(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", rr.Code)
+	}
+	var result struct {
+		SchemaVersion int               `json:"schema_version"`
+		LatestVersion int               `json:"latest_version"`
+		Migrations    []MigrationStatus `json:"migrations"`
+	}
+	json.Unmarshal(rr.Body.Bytes(), &result)
+
+	if result.SchemaVersion != latestSchemaVersion() || result.LatestVersion != latestSchemaVersion() {
+		t.Errorf("expected schema at latest version %d, got %+v", latestSchemaVersion(), result)
+	}
+	if len(result.Migrations) != len(migrations) {
+		t.Fatalf("expected %d migrations, got %d", len(migrations), len(result.Migrations))
+	}
+	for _, m := range result.Migrations {
+		if !m.Applied || m.Record == nil {
+			t.Errorf("expected migration %d to be applied with a record, got %+v", m.Version, m)
+		}
+	}
+}
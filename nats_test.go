@@ -0,0 +1,174 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeNATSServer accepts one connection, does the handshake, and sends
+// each published message (subject and payload) to the returned channel.
+// reject makes it answer CONNECT with -ERR.
+func fakeNATSServer(t *testing.T, reject bool) (addr string, connects chan string, msgs chan [2]string) {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	connects = make(chan string, 1)
+	msgs = make(chan [2]string, 10)
+
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		conn.Write([]byte("INFO {\"server_id\":\"test\"}\r\n"))
+		reader := bufio.NewReader(conn)
+
+		line, _ := reader.ReadString('\n')
+		connects <- strings.TrimSpace(strings.TrimPrefix(line, "CONNECT "))
+		reader.ReadString('\n') // PING
+		if reject {
+			conn.Write([]byte("-ERR 'Authorization Violation'\r\n"))
+			return
+		}
+		conn.Write([]byte("PONG\r\n"))
+		// Keepalive from the server side; the client must answer
+		conn.Write([]byte("PING\r\n"))
+
+		for {
+			line, err := reader.ReadString('\n')
+			if err != nil {
+				return
+			}
+			fields := strings.Fields(line)
+			if len(fields) != 3 || fields[0] != "PUB" {
+				if fields[0] != "PONG" {
+					t.Errorf("unexpected line %q", line)
+				}
+				continue
+			}
+			size, _ := strconv.Atoi(fields[2])
+			payload := make([]byte, size+2) // plus \r\n
+			io.ReadFull(reader, payload)
+			msgs <- [2]string{fields[1], string(payload[:size])}
+		}
+	}()
+	return ln.Addr().String(), connects, msgs
+}
+
+func TestNATSSink_Publish(t *testing.T) {
+	addr, connects, msgs := fakeNATSServer(t, false)
+	sink, err := newNATSSink("nats://s3cret@"+addr, "demoapp.")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	event := Event{ID: "e1", Type: eventItemCreated, Data: json.RawMessage(`{"id":1}`)}
+	if err := sink.publish(event); err != nil {
+		t.Fatal(err)
+	}
+
+	var options map[string]any
+	json.Unmarshal([]byte(<-connects), &options)
+	if options["auth_token"] != "s3cret" || options["name"] != "demo-app" {
+		t.Errorf("CONNECT options = %v", options)
+	}
+
+	select {
+	case msg := <-msgs:
+		var got Event
+		json.Unmarshal([]byte(msg[1]), &got)
+		if msg[0] != "demoapp.item.created" || got.ID != "e1" || string(got.Data) != `{"id":1}` {
+			t.Errorf("published %s %s", msg[0], msg[1])
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("nothing published")
+	}
+}
+
+func TestNATSSink_ConnectRejected(t *testing.T) {
+	addr, _, _ := fakeNATSServer(t, true)
+	sink, _ := newNATSSink("nats://user:wrong@"+addr, "demoapp")
+
+	err := sink.publish(Event{Type: eventItemCreated})
+	if err == nil || !strings.Contains(err.Error(), "Authorization Violation") {
+		t.Errorf("error = %v, want the server's -ERR", err)
+	}
+	if sink.conn != nil {
+		t.Error("expected no connection after a rejected CONNECT")
+	}
+}
+
+func TestNewNATSSink(t *testing.T) {
+	tests := []struct {
+		url      string
+		wantAddr string
+		wantErr  bool
+	}{
+		{"nats://localhost", "localhost:4222", false},
+		{"nats://u:p@nats.example.com:4333", "nats.example.com:4333", false},
+		{"http://localhost:4222", "", true},
+		{"localhost:4222", "", true},
+	}
+	for _, tt := range tests {
+		sink, err := newNATSSink(tt.url, "demoapp")
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: err = %v", tt.url, err)
+			continue
+		}
+		if err == nil && sink.server.addr != tt.wantAddr {
+			t.Errorf("%s: addr = %s, want %s", tt.url, sink.server.addr, tt.wantAddr)
+		}
+	}
+}
+
+func TestNATSSource_Consume(t *testing.T) {
+	srv := newTestServer(t)
+	c := withQueueConsumer(t, srv)
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	subs := make(chan string, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		conn.Write([]byte("INFO {}\r\n"))
+		reader := bufio.NewReader(conn)
+		reader.ReadString('\n') // CONNECT
+		reader.ReadString('\n') // PING
+		conn.Write([]byte("PONG\r\n"))
+		line, _ := reader.ReadString('\n')
+		subs <- strings.TrimSpace(line)
+
+		payload := `{"name":"from nats"}`
+		conn.Write([]byte("MSG demoapp-items 1 " + strconv.Itoa(len(payload)) + "\r\n" + payload + "\r\n"))
+		conn.Write([]byte("-ERR 'Stale Connection'\r\n"))
+	}()
+
+	source := &natsSource{server: natsServer{addr: ln.Addr().String()}, subject: "demoapp-items", group: "demo-app"}
+	err = source.consume(c)
+	if err == nil || !strings.Contains(err.Error(), "Stale Connection") {
+		t.Errorf("consume error = %v, want the server's -ERR", err)
+	}
+	if sub := <-subs; sub != "SUB demoapp-items demo-app 1" {
+		t.Errorf("subscribed with %q", sub)
+	}
+	if c.status.Created != 1 {
+		t.Errorf("status = %+v, want one item created", c.status)
+	}
+}
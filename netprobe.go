@@ -0,0 +1,184 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// =============================================================================
+// Network Probe Endpoint
+// =============================================================================
+//
+// "Can this pod reach X?" is the most common question when debugging network
+// policies and egress rules — and the container usually has no curl, dig, or
+// nc. GET /api/net/probe runs the checks from inside the app instead:
+//
+//	GET /api/net/probe?host=api.github.com&port=443
+//	GET /api/net/probe?host=10.0.0.5&port=8080&http=true&path=/health
+//
+// Each step is timed separately, so the answer distinguishes "DNS is broken"
+// from "DNS works but the connection is blocked" from "connected but the
+// service answered 503".
+
+// Probe timeouts: default per step, and the most a caller may ask for
+const (
+	defaultProbeTimeout = 5 * time.Second
+	maxProbeTimeout     = 30 * time.Second
+)
+
+// ProbeResult is the outcome of all probe steps
+type ProbeResult struct {
+	Host string     `json:"host"`
+	Port int        `json:"port"`
+	DNS  ProbeStep  `json:"dns"`
+	TCP  *ProbeStep `json:"tcp,omitempty"`  // skipped if DNS fails
+	HTTP *ProbeStep `json:"http,omitempty"` // only with ?http=true
+	OK   bool       `json:"ok"`
+}
+
+// ProbeStep is one timed check
+type ProbeStep struct {
+	OK         bool     `json:"ok"`
+	DurationMs float64  `json:"duration_ms"`
+	Error      string   `json:"error,omitempty"`
+	Addresses  []string `json:"addresses,omitempty"` // DNS
+	Address    string   `json:"address,omitempty"`   // TCP: the ip:port that answered
+	URL        string   `json:"url,omitempty"`       // HTTP
+	Status     int      `json:"status,omitempty"`    // HTTP
+}
+
+// finish records a step's duration and error
+func (s *ProbeStep) finish(start time.Time, err error) {
+	// Sub-millisecond precision: in-cluster DNS is often well under 1ms
+	s.DurationMs = float64(time.Since(start).Microseconds()) / 1000
+	if err != nil {
+		s.Error = err.Error()
+		return
+	}
+	s.OK = true
+}
+
+// runNetProbe resolves host, connects to port, and optionally makes an HTTP request
+func runNetProbe(ctx context.Context, host string, port int, checkHTTP bool, path string, timeout time.Duration) ProbeResult {
+	result := ProbeResult{Host: host, Port: port}
+
+	// Step 1: DNS. LookupHost returns IPs unchanged, so IP hosts work too.
+	start := time.Now()
+	dnsCtx, cancel := context.WithTimeout(ctx, timeout)
+	addrs, err := net.DefaultResolver.LookupHost(dnsCtx, host)
+	cancel()
+	result.DNS.Addresses = addrs
+	result.DNS.finish(start, err)
+	if err != nil {
+		return result
+	}
+
+	// Step 2: TCP connect to the host and port (Go tries each resolved
+	// address in turn, like a browser would)
+	result.TCP = &ProbeStep{}
+	start = time.Now()
+	dialer := net.Dialer{Timeout: timeout}
+	conn, err := dialer.DialContext(ctx, "tcp", net.JoinHostPort(host, strconv.Itoa(port)))
+	if err == nil {
+		result.TCP.Address = conn.RemoteAddr().String()
+		conn.Close()
+	}
+	result.TCP.finish(start, err)
+	if err != nil {
+		return result
+	}
+
+	// Step 3 (optional): HTTP GET. Port 443 implies https.
+	if checkHTTP {
+		scheme := "http"
+		if port == 443 {
+			scheme = "https"
+		}
+		url := fmt.Sprintf("%s://%s%s", scheme, net.JoinHostPort(host, strconv.Itoa(port)), path)
+		result.HTTP = &ProbeStep{URL: url}
+
+		start = time.Now()
+		client := &http.Client{
+			Timeout: timeout,
+			// Report redirects instead of following them off to other hosts
+			CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse },
+		}
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+		if err == nil {
+			var resp *http.Response
+			resp, err = client.Do(req)
+			if err == nil {
+				result.HTTP.Status = resp.StatusCode
+				resp.Body.Close()
+			}
+		}
+		result.HTTP.finish(start, err)
+		if err != nil {
+			return result
+		}
+	}
+
+	result.OK = true
+	return result
+}
+
+// netProbeHandler handles GET /api/net/probe
+//
+// Query params: host (required), port (default 80), http (true/false),
+// path (HTTP path, default /), timeout (per step, default 5s, max 30s)
+//
+// Always 200 when the probe ran — a failed connection is a valid answer,
+// reported in the body's "ok" fields.
+func netProbeHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, `{"error":"method not allowed"}`, http.StatusMethodNotAllowed)
+		return
+	}
+
+	q := r.URL.Query()
+
+	host := q.Get("host")
+	if host == "" {
+		http.Error(w, `{"error":"host is required"}`, http.StatusBadRequest)
+		return
+	}
+
+	port := 80
+	if raw := q.Get("port"); raw != "" {
+		p, err := strconv.Atoi(raw)
+		if err != nil || p < 1 || p > 65535 {
+			http.Error(w, `{"error":"port must be between 1 and 65535"}`, http.StatusBadRequest)
+			return
+		}
+		port = p
+	}
+
+	timeout := defaultProbeTimeout
+	if raw := q.Get("timeout"); raw != "" {
+		d, err := time.ParseDuration(raw)
+		if err != nil || d <= 0 || d > maxProbeTimeout {
+			writeJSONError(w, http.StatusBadRequest, fmt.Sprintf("timeout must be a duration up to %s", maxProbeTimeout))
+			return
+		}
+		timeout = d
+	}
+
+	checkHTTP, _ := strconv.ParseBool(q.Get("http"))
+	path := q.Get("path")
+	if path == "" {
+		path = "/"
+	}
+	if path[0] != '/' {
+		path = "/" + path
+	}
+
+	result := runNetProbe(r.Context(), host, port, checkHTTP, path, timeout)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestNetProbe_HTTPSuccess(t *testing.T) {
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTeapot)
+	}))
+	defer target.Close()
+
+	host, portStr, _ := net.SplitHostPort(target.Listener.Addr().String())
+	port, _ := strconv.Atoi(portStr)
+
+	result := runNetProbe(context.Background(), host, port, true, "/ping", time.Second)
+
+	if !result.OK || !result.DNS.OK || result.TCP == nil || !result.TCP.OK {
+		t.Fatalf("expected probe to succeed, got %+v", result)
+	}
+	if result.HTTP == nil || result.HTTP.Status != http.StatusTeapot {
+		t.Errorf("expected HTTP status 418, got %+v", result.HTTP)
+	}
+}
+
+func TestNetProbe_ConnectionRefused(t *testing.T) {
+	// Grab a free port, then close the listener so nothing is there
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	port := l.Addr().(*net.TCPAddr).Port
+	l.Close()
+
+	result := runNetProbe(context.Background(), "127.0.0.1", port, false, "/", time.Second)
+
+	if result.OK || !result.DNS.OK {
+		t.Fatalf("expected DNS ok and overall failure, got %+v", result)
+	}
+	if result.TCP == nil || result.TCP.OK || result.TCP.Error == "" {
+		t.Errorf("expected TCP failure with error, got %+v", result.TCP)
+	}
+}
+
+func TestNetProbeHandler_Validation(t *testing.T) {
+	for _, query := range []string{"", "?host=x&port=0", "?host=x&port=abc", "?host=x&timeout=5m"} {
+		rr := httptest.NewRecorder()
+		netProbeHandler(rr, httptest.NewRequest("GET", "/api/net/probe"+query, nil))
+		if rr.Code != http.StatusBadRequest {
+			t.Errorf("%q: expected status 400, got %d", query, rr.Code)
+		}
+	}
+}
+
+func TestNetProbeHandler_ReportsFailureAs200(t *testing.T) {
+	rr := httptest.NewRecorder()
+	netProbeHandler(rr, httptest.NewRequest("GET", "/api/net/probe?host=127.0.0.1&port=1&timeout=500ms", nil))
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", rr.Code)
+	}
+	var result ProbeResult
+	json.NewDecoder(rr.Body).Decode(&result)
+	if result.OK {
+		t.Error("expected ok=false for a closed port")
+	}
+}
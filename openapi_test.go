@@ -0,0 +1,114 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+// openAPISpec is the part of static/openapi.json the tests look at
+type openAPISpec struct {
+	OpenAPI string                                `json:"openapi"`
+	Paths   map[string]map[string]json.RawMessage `json:"paths"`
+}
+
+func TestOpenAPI_SpecServed(t *testing.T) {
+	srv := newTestServer(t)
+
+	resp, body := staticGet(t, srv.URL+"/api/openapi.json", nil)
+	if resp.StatusCode != http.StatusOK || resp.Header.Get("Content-Type") != "application/json" {
+		t.Fatalf("GET /api/openapi.json = %d %v", resp.StatusCode, resp.Header)
+	}
+	var spec openAPISpec
+	if err := json.Unmarshal(body, &spec); err != nil {
+		t.Fatalf("spec isn't JSON: %v", err)
+	}
+	if !strings.HasPrefix(spec.OpenAPI, "3.") || len(spec.Paths) == 0 {
+		t.Errorf("openapi = %q with %d paths", spec.OpenAPI, len(spec.Paths))
+	}
+
+	resp, page := staticGet(t, srv.URL+"/static/console.html", nil)
+	if resp.StatusCode != http.StatusOK || !regexp.MustCompile(`/static/console\.[0-9a-f]{8}\.js`).Match(page) {
+		t.Errorf("console.html: %d\n%s", resp.StatusCode, page)
+	}
+}
+
+// Every path in the spec must be one the app serves, so the console never
+// offers an endpoint that 404s
+func TestOpenAPI_PathsAreRouted(t *testing.T) {
+	srv := newTestServer(t)
+	// A second mux with the same routes, to ask which pattern matches
+	mux := http.NewServeMux()
+	if err := registerRoutes(mux); err != nil {
+		t.Fatal(err)
+	}
+
+	_, body := staticGet(t, srv.URL+"/api/openapi.json", nil)
+	var spec openAPISpec
+	if err := json.Unmarshal(body, &spec); err != nil {
+		t.Fatal(err)
+	}
+	params := regexp.MustCompile(`\{[^}]+\}`)
+	for path, methods := range spec.Paths {
+		for method := range methods {
+			req := httptest.NewRequest(strings.ToUpper(method), params.ReplaceAllString(path, "1"), nil)
+			if _, pattern := mux.Handler(req); pattern == "" || pattern == "/" {
+				t.Errorf("%s %s isn't routed", strings.ToUpper(method), path)
+			}
+		}
+	}
+}
+
+// Routes the spec leaves out on purpose: replicas and standbys call them,
+// not people
+var openAPIUnlisted = map[string]bool{
+	"/api/cluster/replicate": true,
+	"/api/standby/receive":   true,
+	"/api/openapi.json":      true, // the spec itself
+}
+
+// Every /api route must have a path in the spec, so the console never
+// leaves an endpoint out
+func TestOpenAPI_RoutesAreListed(t *testing.T) {
+	source, err := os.ReadFile("main.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	routes := regexp.MustCompile(`mux\.Handle(?:Func)?\("(/api/[^"]*)"`).FindAllStringSubmatch(string(source), -1)
+	if len(routes) == 0 {
+		t.Fatal("no /api routes found in main.go")
+	}
+
+	mux := http.NewServeMux()
+	if err := registerRoutes(mux); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile("static/openapi.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var spec openAPISpec
+	if err := json.Unmarshal(data, &spec); err != nil {
+		t.Fatal(err)
+	}
+	// Which route pattern each spec path lands on
+	params := regexp.MustCompile(`\{[^}]+\}`)
+	listed := map[string]bool{}
+	for path, methods := range spec.Paths {
+		for method := range methods {
+			req := httptest.NewRequest(strings.ToUpper(method), params.ReplaceAllString(path, "1"), nil)
+			_, pattern := mux.Handler(req)
+			listed[pattern] = true
+		}
+	}
+
+	for _, route := range routes {
+		if pattern := route[1]; !listed[pattern] && !openAPIUnlisted[pattern] {
+			t.Errorf("%s isn't in static/openapi.json", pattern)
+		}
+	}
+}
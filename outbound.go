@@ -0,0 +1,164 @@
+package main
+
+import (
+	"errors"
+	"io"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"time"
+)
+
+// =============================================================================
+// Outbound HTTP Clients
+// =============================================================================
+//
+// Every integration that calls another service gets its client from
+// newOutboundClient, so they all behave the same way:
+//
+//	client := newOutboundClient(5 * time.Second)
+//
+// A request goes through three layers, outermost first:
+//
+//	retries         failed calls are tried again, OUTBOUND_RETRIES times, with
+//	                jittered exponential backoff (retryTransport below)
+//	circuit breaker one per host; an open circuit isn't retried (circuitbreaker.go)
+//	shared pool     one http.Transport for everything, so connections to each
+//	                host are kept alive and reused (outboundTransport)
+//
+// Only calls that are safe to repeat are retried: GET, HEAD, OPTIONS, PUT,
+// and DELETE, or any method with an Idempotency-Key header (idempotency.go
+// explains the idea). Connection errors and 429/502/503/504 answers are
+// retried; other statuses are returned as they are. The client's timeout
+// covers all attempts together.
+//
+// A few clients are deliberately built without it:
+//
+//	newSelfClient   calls back to this server are served in-process and
+//	                never touch the network (selfclient.go)
+//	/api/net/probe  reports raw reachability; a retry or an open circuit
+//	                would hide exactly what it's asked to show (netprobe.go)
+//	SPIFFE          the Workload API is a local socket with its own dialer,
+//	                not a service that goes down (spiffe.go)
+//	CLI commands    bench, loadgen, and healthcheck run outside the server
+//	                and measure or check the target as it answers (bench.go,
+//	                cli.go, healthcheck.go)
+
+// Retry settings, from OUTBOUND_RETRIES and OUTBOUND_RETRY_BACKOFF (see
+// configureOutbound)
+var (
+	outboundRetries      = 2
+	outboundRetryBackoff = 100 * time.Millisecond
+)
+
+// Longest single wait between attempts
+const maxOutboundBackoff = 2 * time.Second
+
+// The connection pool every outbound client shares
+var outboundTransport = newOutboundTransport(10, 0, 5*time.Second)
+
+// newOutboundTransport builds the shared pool. maxIdlePerHost connections are
+// kept open to each host for reuse; maxPerHost caps connections to one host
+// (0 = no limit); dialTimeout bounds connecting and the TLS handshake.
+func newOutboundTransport(maxIdlePerHost, maxPerHost int, dialTimeout time.Duration) *http.Transport {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.DialContext = (&net.Dialer{Timeout: dialTimeout, KeepAlive: 30 * time.Second}).DialContext
+	transport.TLSHandshakeTimeout = dialTimeout
+	transport.MaxIdleConnsPerHost = maxIdlePerHost
+	transport.MaxConnsPerHost = maxPerHost
+	return transport
+}
+
+// configureOutbound applies the OUTBOUND_* settings. main calls it before
+// any client is created.
+func configureOutbound() {
+	outboundRetries = max(envInt("OUTBOUND_RETRIES", outboundRetries), 0)
+	outboundRetryBackoff = envDuration("OUTBOUND_RETRY_BACKOFF", outboundRetryBackoff)
+	outboundTransport = newOutboundTransport(
+		envInt("OUTBOUND_MAX_IDLE_CONNS_PER_HOST", 10),
+		envInt("OUTBOUND_MAX_CONNS_PER_HOST", 0),
+		envDuration("OUTBOUND_DIAL_TIMEOUT", 5*time.Second),
+	)
+}
+
+// newOutboundClient returns a client with retries, circuit breakers, and the
+// shared pool; timeout is the most one call may take, retries included
+func newOutboundClient(timeout time.Duration) *http.Client {
+	return &http.Client{
+		Timeout:   timeout,
+		Transport: &retryTransport{next: newCircuitTransport(outboundTransport)},
+	}
+}
+
+// retryTransport is an http.RoundTripper that tries again on failure
+type retryTransport struct {
+	next http.RoundTripper
+}
+
+// RoundTrip implements http.RoundTripper
+func (t *retryTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	for attempt := 0; ; attempt++ {
+		resp, err := t.next.RoundTrip(req)
+		if attempt >= outboundRetries || !shouldRetry(req, resp, err) {
+			return resp, err
+		}
+		if resp != nil {
+			// Drain a little so the connection can go back to the pool
+			io.Copy(io.Discard, io.LimitReader(resp.Body, 4096))
+			resp.Body.Close()
+		}
+		outboundRetriesTotal.WithLabelValues(req.URL.Host).Inc()
+
+		select {
+		case <-time.After(retryDelay(attempt)):
+		case <-req.Context().Done():
+			return nil, req.Context().Err()
+		}
+
+		// A body can only be read once; GetBody gives a fresh copy
+		if req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			req = req.Clone(req.Context())
+			req.Body = body
+		}
+	}
+}
+
+// shouldRetry reports whether a failed call is worth another attempt
+func shouldRetry(req *http.Request, resp *http.Response, err error) bool {
+	if errors.Is(err, errCircuitOpen) || req.Context().Err() != nil {
+		return false
+	}
+	if req.Body != nil && req.Body != http.NoBody && req.GetBody == nil {
+		return false // the body is used up and can't be sent again
+	}
+	switch req.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodPut, http.MethodDelete:
+	default:
+		if req.Header.Get("Idempotency-Key") == "" {
+			return false
+		}
+	}
+	if err != nil {
+		return true
+	}
+	switch resp.StatusCode {
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+// retryDelay is the wait before retry number attempt+1: a random time up to
+// OUTBOUND_RETRY_BACKOFF * 2^attempt ("full jitter"), so many clients that
+// failed together don't all retry at the same moment
+func retryDelay(attempt int) time.Duration {
+	ceiling := min(outboundRetryBackoff<<attempt, maxOutboundBackoff)
+	if ceiling <= 0 {
+		return 0
+	}
+	return rand.N(ceiling) + 1
+}
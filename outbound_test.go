@@ -0,0 +1,133 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// withRetries sets the retry settings for one test
+func withRetries(t *testing.T, retries int, backoff time.Duration) {
+	t.Helper()
+	prevRetries, prevBackoff := outboundRetries, outboundRetryBackoff
+	outboundRetries, outboundRetryBackoff = retries, backoff
+	t.Cleanup(func() { outboundRetries, outboundRetryBackoff = prevRetries, prevBackoff })
+}
+
+func TestOutboundClient_Retries(t *testing.T) {
+	withCircuits(t, 0, time.Minute)
+	withRetries(t, 2, time.Millisecond)
+
+	tests := []struct {
+		name      string
+		method    string
+		header    string
+		status    int // what the server answers until the last attempt
+		wantCalls int32
+	}{
+		{"GET retried on 503", http.MethodGet, "", http.StatusServiceUnavailable, 3},
+		{"PUT retried on 502", http.MethodPut, "", http.StatusBadGateway, 3},
+		{"POST not retried", http.MethodPost, "", http.StatusServiceUnavailable, 1},
+		{"POST with Idempotency-Key retried", http.MethodPost, "Idempotency-Key", http.StatusServiceUnavailable, 3},
+		{"500 not retried", http.MethodGet, "", http.StatusInternalServerError, 1},
+		{"404 not retried", http.MethodGet, "", http.StatusNotFound, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var calls atomic.Int32
+			var bodies []string
+			upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				body, _ := io.ReadAll(r.Body)
+				bodies = append(bodies, string(body))
+				calls.Add(1)
+				w.WriteHeader(tt.status)
+			}))
+			defer upstream.Close()
+
+			req, _ := http.NewRequest(tt.method, upstream.URL, strings.NewReader("payload"))
+			if tt.header != "" {
+				req.Header.Set(tt.header, "k1")
+			}
+			resp, err := newOutboundClient(5 * time.Second).Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp.Body.Close()
+
+			if calls.Load() != tt.wantCalls {
+				t.Errorf("calls = %d, want %d", calls.Load(), tt.wantCalls)
+			}
+			if resp.StatusCode != tt.status {
+				t.Errorf("status = %d, want %d", resp.StatusCode, tt.status)
+			}
+			// Every attempt sends the whole body again
+			for _, body := range bodies {
+				if body != "payload" {
+					t.Errorf("attempt body = %q", body)
+				}
+			}
+		})
+	}
+}
+
+func TestOutboundClient_RecoversAfterRetry(t *testing.T) {
+	withCircuits(t, 0, time.Minute)
+	withRetries(t, 2, time.Millisecond)
+
+	var calls atomic.Int32
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte("ok"))
+	}))
+	defer upstream.Close()
+
+	resp, err := newOutboundClient(5 * time.Second).Get(upstream.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusOK || string(body) != "ok" || calls.Load() != 2 {
+		t.Errorf("got %d %q after %d calls", resp.StatusCode, body, calls.Load())
+	}
+}
+
+func TestOutboundClient_TimeoutCoversRetries(t *testing.T) {
+	withCircuits(t, 0, time.Minute)
+	withRetries(t, 5, time.Second)
+
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer upstream.Close()
+
+	start := time.Now()
+	_, err := newOutboundClient(200 * time.Millisecond).Get(upstream.URL)
+	if err == nil {
+		t.Fatal("expected a timeout")
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("took %v, want the 200ms client timeout to stop the retries", elapsed)
+	}
+}
+
+func TestRetryDelay(t *testing.T) {
+	withRetries(t, 2, 100*time.Millisecond)
+	for attempt, ceiling := range []time.Duration{100 * time.Millisecond, 200 * time.Millisecond, 400 * time.Millisecond} {
+		for range 50 {
+			if d := retryDelay(attempt); d <= 0 || d > ceiling {
+				t.Fatalf("retryDelay(%d) = %v, want (0, %v]", attempt, d, ceiling)
+			}
+		}
+	}
+	if d := retryDelay(20); d > maxOutboundBackoff {
+		t.Errorf("retryDelay(20) = %v, want at most %v", d, maxOutboundBackoff)
+	}
+}
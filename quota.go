@@ -0,0 +1,515 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	badger "github.com/dgraph-io/badger/v4"
+)
+
+// =============================================================================
+// Quotas
+// =============================================================================
+//
+// Fair-use limits for a shared instance: each tenant (tenant.go) gets at
+// most QUOTA_MAX_ITEMS items, QUOTA_MAX_STORAGE_BYTES of item and attachment
+// data, and QUOTA_MAX_RPS requests per second. Without MULTI_TENANT the
+// request rate is limited per API key (X-API-Key) that the access policy
+// (accesspolicy.go) knows, otherwise per IP, and the item and storage
+// limits apply to the whole store.
+//
+//	QUOTA_MAX_ITEMS=100 QUOTA_MAX_RPS=5 MULTI_TENANT=true ./demo-app
+//	curl -H 'X-Tenant: acme' http://localhost:8080/api/quota          # my usage
+//	curl http://localhost:8080/api/admin/quotas                        # everyone's
+//	curl -X PUT -d '{"max_items":1000,"max_rps":50}' \
+//	  http://localhost:8080/api/admin/quotas/acme                      # acme pays more
+//
+// Going over a limit:
+//
+//   - max_rps: 429 with Retry-After, before the handler runs
+//   - max_items, max_storage_bytes: 403 on the write that would go over
+//
+// The rate is a token bucket: a subject can burst up to max_rps requests at
+// once, then gets max_rps a second. max_items is checked inside the create's
+// transaction against the item aggregates (aggregates.go), which also
+// reads the items version, so racing creates conflict instead of going
+// over. max_storage_bytes needs a scan of the tenant's keys, so it's
+// checked before the write and racing writes can go a little over. Both
+// cover the items API and attachments; jobs and seeders aren't limited.
+// 0 means unlimited. Overrides set through the admin API are stored in
+// this replica's database.
+
+// Times a limited create is retried when a racing write conflicts with it
+const quotaConflictRetries = 5
+
+// Key prefix for per-subject overrides ("quotas:acme" -> Quota)
+const quotaKeyPrefix = "quotas:"
+
+// Token buckets kept at most; idle ones are dropped first, then the least
+// recently used
+const maxQuotaBuckets = 10000
+
+// Quota is a set of limits; 0 means unlimited
+type Quota struct {
+	MaxItems        int     `json:"max_items"`
+	MaxStorageBytes int64   `json:"max_storage_bytes"`
+	MaxRPS          float64 `json:"max_rps"`
+}
+
+// defaultQuota applies to every subject without an override, from
+// QUOTA_MAX_ITEMS, QUOTA_MAX_STORAGE_BYTES and QUOTA_MAX_RPS (set in main)
+var defaultQuota Quota
+
+// QuotaUsage is GET /api/quota, and one entry in GET /api/admin/quotas
+type QuotaUsage struct {
+	Subject      string `json:"subject"`
+	Limits       Quota  `json:"limits"`
+	Items        int    `json:"items"`
+	StorageBytes int64  `json:"storage_bytes"`
+	Throttled    int64  `json:"throttled"` // requests refused with 429 since startup
+}
+
+// tokenBucket is one subject's request allowance
+type tokenBucket struct {
+	tokens    float64
+	last      time.Time
+	throttled int64
+}
+
+// quotaStore holds the overrides and the token buckets
+type quotaStore struct {
+	mu        sync.Mutex
+	overrides map[string]Quota
+	buckets   map[string]*tokenBucket
+}
+
+var quotas = &quotaStore{overrides: map[string]Quota{}, buckets: map[string]*tokenBucket{}}
+
+// limits are the subject's quota: its override, or the defaults
+func (q *quotaStore) limits(subject string) Quota {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if quota, ok := q.overrides[subject]; ok {
+		return quota
+	}
+	return defaultQuota
+}
+
+// allow takes a token from the subject's bucket, reporting false when it's
+// empty (the request is over max_rps)
+func (q *quotaStore) allow(subject string, rps float64, now time.Time) bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	b, ok := q.buckets[subject]
+	if !ok {
+		if len(q.buckets) >= maxQuotaBuckets {
+			q.dropIdle(now)
+		}
+		b = &tokenBucket{tokens: rps, last: now}
+		q.buckets[subject] = b
+	}
+	// Refill for the time since the last request, up to one second's worth
+	b.tokens = math.Min(rps, b.tokens+now.Sub(b.last).Seconds()*rps)
+	b.last = now
+	if b.tokens < 1 {
+		b.throttled++
+		return false
+	}
+	b.tokens--
+	return true
+}
+
+// dropIdle forgets buckets that have refilled completely, or the least
+// recently used one if none has; call with q.mu held. Busy subjects keep
+// their buckets, so new subjects arriving can't reset anyone's throttling.
+func (q *quotaStore) dropIdle(now time.Time) {
+	var oldest string
+	for subject, b := range q.buckets {
+		if now.Sub(b.last) > time.Second {
+			delete(q.buckets, subject)
+		} else if oldest == "" || b.last.Before(q.buckets[oldest].last) {
+			oldest = subject
+		}
+	}
+	if len(q.buckets) >= maxQuotaBuckets {
+		delete(q.buckets, oldest)
+	}
+}
+
+// throttled is how many of the subject's requests got 429
+func (q *quotaStore) throttled(subject string) int64 {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if b, ok := q.buckets[subject]; ok {
+		return b.throttled
+	}
+	return 0
+}
+
+// set stores an override for the subject
+func (q *quotaStore) set(subject string, quota Quota) error {
+	value, _ := json.Marshal(quota) // a plain struct always marshals
+	err := dbUpdate("quota_set", quotaKeyPrefix+subject, func(txn *badger.Txn) error {
+		return txn.Set([]byte(quotaKeyPrefix+subject), value)
+	})
+	if err != nil {
+		return err
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.overrides[subject] = quota
+	delete(q.buckets, subject) // start the new rate with a full bucket
+	return nil
+}
+
+// remove deletes the subject's override, reporting whether there was one
+func (q *quotaStore) remove(subject string) (bool, error) {
+	q.mu.Lock()
+	_, ok := q.overrides[subject]
+	q.mu.Unlock()
+	if !ok {
+		return false, nil
+	}
+	err := dbUpdate("quota_delete", quotaKeyPrefix+subject, func(txn *badger.Txn) error {
+		return txn.Delete([]byte(quotaKeyPrefix + subject))
+	})
+	if err != nil {
+		return false, err
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	delete(q.overrides, subject)
+	delete(q.buckets, subject)
+	return true, nil
+}
+
+// load reads the overrides saved in the database, at startup
+func (q *quotaStore) load() error {
+	overrides := map[string]Quota{}
+	err := dbView("quota_list", quotaKeyPrefix, func(txn *badger.Txn) error {
+		it := txn.NewIterator(badger.DefaultIteratorOptions)
+		defer it.Close()
+		prefix := []byte(quotaKeyPrefix)
+		for it.Seek(prefix); it.ValidForPrefix(prefix); it.Next() {
+			var quota Quota
+			if err := it.Item().Value(func(val []byte) error { return json.Unmarshal(val, &quota) }); err != nil {
+				return err
+			}
+			overrides[strings.TrimPrefix(string(it.Item().Key()), quotaKeyPrefix)] = quota
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.overrides = overrides
+	return nil
+}
+
+// reset forgets the overrides (not the saved copies) and every bucket
+func (q *quotaStore) reset() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	clear(q.overrides)
+	clear(q.buckets)
+}
+
+// rateSubject is who a request's rate counts against: its tenant with
+// MULTI_TENANT, otherwise the name of its API key or its IP. A key only
+// counts once the access policy knows it — X-API-Key is whatever the
+// client sends, so a new made-up key per request would get a new bucket.
+func rateSubject(r *http.Request) string {
+	if multiTenant {
+		if ks := keyspaceFrom(r.Context()); ks != rootKeyspace {
+			return ks.tenant
+		}
+		return defaultTenant
+	}
+	if p := accessPolicy.Load(); p != nil {
+		if caller := p.caller(r); caller != nil {
+			return caller.Name
+		}
+	}
+	return clientHost(r.RemoteAddr)
+}
+
+// dataSubject is who a request's items and storage count against
+func dataSubject(k keyspace) string {
+	if k == rootKeyspace {
+		return defaultTenant
+	}
+	return k.tenant
+}
+
+// quotaMiddleware refuses requests over the subject's max_rps with 429.
+// Called from loggingMiddleware after tenantMiddleware, so the tenant is
+// known. Probes, /metrics, and the admin API are never limited.
+func quotaMiddleware(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		path := r.URL.Path
+		if !strings.HasPrefix(path, "/api/") || strings.HasPrefix(path, "/api/admin/") {
+			next(w, r)
+			return
+		}
+		subject := rateSubject(r)
+		limit := quotas.limits(subject).MaxRPS
+		if limit <= 0 || quotas.allow(subject, limit, time.Now()) {
+			next(w, r)
+			return
+		}
+		quotaRejectionsTotal.WithLabelValues("max_rps").Inc()
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Retry-After", "1")
+		w.WriteHeader(http.StatusTooManyRequests)
+		json.NewEncoder(w).Encode(map[string]any{
+			"error": "rate limit exceeded",
+			"quota": "max_rps",
+			"limit": limit,
+		})
+	}
+}
+
+// usage counts a tenant's items and the bytes they and their attachments
+// take, from keys only: EstimatedSize doesn't read the values
+func (k keyspace) usage() (items int, bytes int64, err error) {
+	err = dbView("quota_usage", string(k.key(itemKeyPrefix)), func(txn *badger.Txn) error {
+		opts := badger.DefaultIteratorOptions
+		opts.PrefetchValues = false
+		it := txn.NewIterator(opts)
+		defer it.Close()
+
+		prefix := k.key(itemKeyPrefix)
+		for it.Seek(prefix); it.ValidForPrefix(prefix); it.Next() {
+			items++
+			bytes += it.Item().EstimatedSize()
+		}
+		prefix = k.key(attachmentKeyPrefix)
+		for it.Seek(prefix); it.ValidForPrefix(prefix); it.Next() {
+			bytes += it.Item().EstimatedSize()
+		}
+		return nil
+	})
+	return items, bytes, err
+}
+
+// quotaError is a write refused for going over a limit
+type quotaError struct {
+	subject, quota string
+	limit, used    int64
+}
+
+func (e *quotaError) Error() string {
+	return fmt.Sprintf("%s over its %s quota (%d of %d)", e.subject, e.quota, e.used, e.limit)
+}
+
+// checkItemQuota fails with a *quotaError when newItems more items would
+// put the tenant over max_items. It runs inside the create's transaction:
+// the count comes from the aggregates, and reading the items version
+// (which every item write sets) makes a racing create fail one of them
+// with ErrConflict rather than let both through.
+func (k keyspace) checkItemQuota(txn *badger.Txn, newItems int) error {
+	subject := dataSubject(k)
+	limit := quotas.limits(subject).MaxItems
+	if newItems <= 0 || limit <= 0 {
+		return nil
+	}
+	if _, err := txn.Get(k.key(itemsVersionKey)); err != nil && !errors.Is(err, badger.ErrKeyNotFound) {
+		return err
+	}
+	snap, err := k.readAggregateSnapshot(txn)
+	if err != nil {
+		return err
+	}
+	pending, _, err := k.sumAggregateDeltas(txn, 0)
+	if err != nil {
+		return err
+	}
+	used := snap.Items + pending.Items
+	if used+int64(newItems) > int64(limit) {
+		return &quotaError{subject: subject, quota: "max_items", limit: int64(limit), used: used}
+	}
+	return nil
+}
+
+// writeQuotaError answers 403 if err is a *quotaError.
+// Returns true if it handled err.
+func writeQuotaError(w http.ResponseWriter, r *http.Request, err error) bool {
+	var over *quotaError
+	if !errors.As(err, &over) {
+		return false
+	}
+	quotaRejectionsTotal.WithLabelValues(over.quota).Inc()
+	slog.WarnContext(r.Context(), "quota exceeded", "subject", over.subject, "quota", over.quota, "limit", over.limit, "used", over.used)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusForbidden)
+	json.NewEncoder(w).Encode(map[string]any{
+		"error": "quota exceeded",
+		"quota": over.quota,
+		"limit": over.limit,
+		"used":  over.used,
+	})
+	return true
+}
+
+// checkStorageQuota answers 403 when a write of size more bytes would put
+// the request's tenant over max_storage_bytes. max_items is checked when
+// the items are written (checkItemQuota).
+// It returns false when the response is done.
+func checkStorageQuota(w http.ResponseWriter, r *http.Request, size int64) bool {
+	ks := keyspaceFrom(r.Context())
+	limit := quotas.limits(dataSubject(ks)).MaxStorageBytes
+	if limit <= 0 {
+		return true
+	}
+	_, bytes, err := ks.usage()
+	if err != nil {
+		logHandlerError(r.Context(), "quota", "database", "failed to read quota usage", "error", err)
+		return true // don't fail the write over a usage read
+	}
+	if bytes+size <= limit {
+		return true
+	}
+	return !writeQuotaError(w, r, &quotaError{subject: dataSubject(ks), quota: "max_storage_bytes", limit: limit, used: bytes})
+}
+
+// quotaUsage describes one subject's limits and what it's using
+func quotaUsage(subject string, k keyspace) (QuotaUsage, error) {
+	items, bytes, err := k.usage()
+	return QuotaUsage{
+		Subject:      subject,
+		Limits:       quotas.limits(subject),
+		Items:        items,
+		StorageBytes: bytes,
+		Throttled:    quotas.throttled(subject),
+	}, err
+}
+
+// quotaHandler handles GET /api/quota: the caller's own limits and usage
+func quotaHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	if r.Method != http.MethodGet {
+		http.Error(w, `{"error":"method not allowed"}`, http.StatusMethodNotAllowed)
+		return
+	}
+
+	ks := keyspaceFrom(r.Context())
+	usage, err := quotaUsage(dataSubject(ks), ks)
+	if err != nil {
+		logHandlerError(r.Context(), "quota", "database", "failed to read quota usage", "error", err)
+		http.Error(w, `{"error":"database error"}`, http.StatusInternalServerError)
+		return
+	}
+	// Without tenants the rate is the caller's own, not the store's
+	if subject := rateSubject(r); subject != usage.Subject {
+		usage.Limits.MaxRPS = quotas.limits(subject).MaxRPS
+		usage.Throttled = quotas.throttled(subject)
+	}
+	json.NewEncoder(w).Encode(usage)
+}
+
+// quotasAdminHandler handles /api/admin/quotas:
+//
+//	GET    /api/admin/quotas           -> defaults, overrides, and every tenant's usage
+//	PUT    /api/admin/quotas/:subject  -> override a tenant's (or API key's) limits
+//	DELETE /api/admin/quotas/:subject  -> back to the defaults
+func quotasAdminHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	subject := strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/admin/quotas"), "/")
+
+	switch {
+	case subject == "" && r.Method == http.MethodGet:
+		listQuotas(w, r)
+	case subject != "" && r.Method == http.MethodPut:
+		var quota Quota
+		if err := json.NewDecoder(r.Body).Decode(&quota); err != nil {
+			http.Error(w, `{"error":"invalid json"}`, http.StatusBadRequest)
+			return
+		}
+		if quota.MaxItems < 0 || quota.MaxStorageBytes < 0 || quota.MaxRPS < 0 {
+			http.Error(w, `{"error":"limits can't be negative (0 is unlimited)"}`, http.StatusBadRequest)
+			return
+		}
+		if err := quotas.set(subject, quota); err != nil {
+			logHandlerError(r.Context(), "quota", "database", "failed to save quota", "subject", subject, "error", err)
+			http.Error(w, `{"error":"database error"}`, http.StatusInternalServerError)
+			return
+		}
+		slog.InfoContext(r.Context(), "quota set", "subject", subject,
+			"max_items", quota.MaxItems, "max_storage_bytes", quota.MaxStorageBytes, "max_rps", quota.MaxRPS)
+		json.NewEncoder(w).Encode(quota)
+	case subject != "" && r.Method == http.MethodDelete:
+		found, err := quotas.remove(subject)
+		if err != nil {
+			logHandlerError(r.Context(), "quota", "database", "failed to delete quota", "subject", subject, "error", err)
+			http.Error(w, `{"error":"database error"}`, http.StatusInternalServerError)
+			return
+		}
+		if !found {
+			http.Error(w, `{"error":"not found"}`, http.StatusNotFound)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		http.Error(w, `{"error":"method not allowed"}`, http.StatusMethodNotAllowed)
+	}
+}
+
+// listQuotas writes GET /api/admin/quotas
+func listQuotas(w http.ResponseWriter, r *http.Request) {
+	names := []string{defaultTenant}
+	if multiTenant {
+		list, err := tenants.list()
+		if err != nil {
+			logHandlerError(r.Context(), "quota", "database", "failed to list tenants", "error", err)
+			http.Error(w, `{"error":"database error"}`, http.StatusInternalServerError)
+			return
+		}
+		names = names[:0]
+		for _, tenant := range list {
+			names = append(names, tenant.Name)
+		}
+	}
+
+	usage := make([]QuotaUsage, 0, len(names))
+	for _, name := range names {
+		u, err := quotaUsage(name, keyspaceFor(name))
+		if err != nil {
+			logHandlerError(r.Context(), "quota", "database", "failed to read quota usage", "error", err)
+			http.Error(w, `{"error":"database error"}`, http.StatusInternalServerError)
+			return
+		}
+		usage = append(usage, u)
+	}
+
+	quotas.mu.Lock()
+	overrides := make(map[string]Quota, len(quotas.overrides))
+	for subject, quota := range quotas.overrides {
+		overrides[subject] = quota
+	}
+	quotas.mu.Unlock()
+
+	json.NewEncoder(w).Encode(map[string]any{
+		"defaults":  defaultQuota,
+		"overrides": overrides,
+		"usage":     usage,
+	})
+}
+
+// parseQuotaEnv reads the default limits
+func parseQuotaEnv() Quota {
+	return Quota{
+		MaxItems:        envInt("QUOTA_MAX_ITEMS", 0),
+		MaxStorageBytes: int64(envInt("QUOTA_MAX_STORAGE_BYTES", 0)),
+		MaxRPS:          envFloat("QUOTA_MAX_RPS", 0),
+	}
+}
@@ -0,0 +1,218 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// withQuota sets the default limits for one test
+func withQuota(t *testing.T, quota Quota) {
+	t.Helper()
+	prev := defaultQuota
+	defaultQuota = quota
+	quotas.reset()
+	t.Cleanup(func() {
+		defaultQuota = prev
+		quotas.reset()
+	})
+}
+
+func TestTokenBucket(t *testing.T) {
+	q := &quotaStore{overrides: map[string]Quota{}, buckets: map[string]*tokenBucket{}}
+	now := time.Now()
+
+	for i := 0; i < 2; i++ {
+		if !q.allow("acme", 2, now) {
+			t.Fatalf("request %d refused within the burst", i+1)
+		}
+	}
+	if q.allow("acme", 2, now) {
+		t.Fatal("third request in the same instant allowed at 2 rps")
+	}
+	if !q.allow("globex", 2, now) {
+		t.Fatal("another subject shares acme's bucket")
+	}
+	if !q.allow("acme", 2, now.Add(500*time.Millisecond)) {
+		t.Fatal("no token refilled after half a second at 2 rps")
+	}
+	if got := q.throttled("acme"); got != 1 {
+		t.Errorf("throttled = %d, want 1", got)
+	}
+}
+
+func TestQuota_MaxItems(t *testing.T) {
+	withMultiTenant(t)
+	withQuota(t, Quota{MaxItems: 2})
+	srv := newTestServer(t)
+
+	for i := 0; i < 2; i++ {
+		if code, body := tenantRequest(t, srv, "acme", http.MethodPost, "/api/items", `{"name":"widget"}`); code != http.StatusCreated {
+			t.Fatalf("create %d = %d %s", i+1, code, body)
+		}
+	}
+	code, body := tenantRequest(t, srv, "acme", http.MethodPost, "/api/items", `{"name":"one too many"}`)
+	if code != http.StatusForbidden {
+		t.Fatalf("create over quota = %d %s, want 403", code, body)
+	}
+	var refused struct {
+		Quota string `json:"quota"`
+		Limit int    `json:"limit"`
+		Used  int    `json:"used"`
+	}
+	json.Unmarshal(body, &refused)
+	if refused.Quota != "max_items" || refused.Limit != 2 || refused.Used != 2 {
+		t.Errorf("refusal = %s", body)
+	}
+
+	// Other tenants have their own count
+	if code, _ := tenantRequest(t, srv, "globex", http.MethodPost, "/api/items", `{"name":"widget"}`); code != http.StatusCreated {
+		t.Errorf("create as globex = %d, want 201", code)
+	}
+
+	// An override raises acme's limit
+	if code, body := doRequest(t, srv, http.MethodPut, "/api/admin/quotas/acme", `{"max_items":3}`); code != http.StatusOK {
+		t.Fatalf("PUT quota = %d %s", code, body)
+	}
+	if code, _ := tenantRequest(t, srv, "acme", http.MethodPost, "/api/items", `{"name":"third"}`); code != http.StatusCreated {
+		t.Errorf("create after raising the quota = %d, want 201", code)
+	}
+
+	code, body = tenantRequest(t, srv, "acme", http.MethodGet, "/api/quota", "")
+	var usage QuotaUsage
+	if err := json.Unmarshal(body, &usage); err != nil || code != http.StatusOK {
+		t.Fatalf("GET /api/quota = %d %s", code, body)
+	}
+	if usage.Subject != "acme" || usage.Items != 3 || usage.Limits.MaxItems != 3 || usage.StorageBytes == 0 {
+		t.Errorf("usage = %+v", usage)
+	}
+}
+
+func TestQuota_MaxItemsConcurrentCreates(t *testing.T) {
+	withQuota(t, Quota{MaxItems: 5})
+	srv := newTestServer(t)
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	codes := map[int]int{}
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			code, _ := doRequestNoFatal(srv, http.MethodPost, "/api/items", `{"name":"racer"}`)
+			mu.Lock()
+			codes[code]++
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+
+	_, body := doRequest(t, srv, http.MethodGet, "/api/items", "")
+	var items []Item
+	json.Unmarshal(body, &items)
+	if len(items) != 5 || codes[http.StatusCreated] != 5 {
+		t.Errorf("%d items stored, responses %v; want exactly 5 created", len(items), codes)
+	}
+
+	// A batch that would go over is refused as a whole
+	code, body := doRequest(t, srv, http.MethodPost, "/api/items/batch", `{"operations":[{"op":"create","item":{"name":"x"}}]}`)
+	if code != http.StatusForbidden || !strings.Contains(string(body), "max_items") {
+		t.Errorf("batch over quota = %d %s, want 403", code, body)
+	}
+}
+
+func TestQuota_MaxStorageBytes(t *testing.T) {
+	withQuota(t, Quota{MaxStorageBytes: 200})
+	srv := newTestServer(t)
+
+	item := createTestItem(t, srv, `{"name":"small"}`)
+	code, body := doRequest(t, srv, http.MethodPost, "/api/items", `{"name":"big","description":"`+strings.Repeat("x", 300)+`"}`)
+	if code != http.StatusForbidden {
+		t.Fatalf("create over storage quota = %d %s, want 403", code, body)
+	}
+
+	// Updates that fit are still allowed
+	if code, body := doRequest(t, srv, http.MethodPut, "/api/items/"+strconv.FormatInt(item.ID, 10), `{"name":"renamed"}`); code != http.StatusOK {
+		t.Errorf("update under quota = %d %s", code, body)
+	}
+}
+
+func TestQuota_MaxRPS(t *testing.T) {
+	withQuota(t, Quota{MaxRPS: 1})
+	withAccessPolicy(t, "audit") // knows ops-secret and ci-secret, refuses nothing
+	srv := newTestServer(t)
+
+	req := func(key string) *http.Response {
+		r, _ := http.NewRequest(http.MethodGet, srv.URL+"/api/items", nil)
+		r.Header.Set(apiKeyHeader, key)
+		resp, err := http.DefaultClient.Do(r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		return resp
+	}
+	if resp := req("ops-secret"); resp.StatusCode != http.StatusOK {
+		t.Fatalf("first request = %d", resp.StatusCode)
+	}
+	resp := req("ops-secret")
+	if resp.StatusCode != http.StatusTooManyRequests || resp.Header.Get("Retry-After") == "" {
+		t.Errorf("second request = %d (Retry-After %q), want 429", resp.StatusCode, resp.Header.Get("Retry-After"))
+	}
+	if resp := req("ci-secret"); resp.StatusCode != http.StatusOK {
+		t.Errorf("another API key = %d, want 200", resp.StatusCode)
+	}
+
+	// Made-up keys all count against the caller's IP
+	if resp := req("made-up-1"); resp.StatusCode != http.StatusOK {
+		t.Errorf("first unknown key = %d, want 200", resp.StatusCode)
+	}
+	if resp := req("made-up-2"); resp.StatusCode != http.StatusTooManyRequests {
+		t.Errorf("second unknown key = %d, want 429 (same IP)", resp.StatusCode)
+	}
+
+	// The admin API isn't limited, so a throttled team can be fixed
+	if code, _ := doRequest(t, srv, http.MethodGet, "/api/admin/quotas", ""); code != http.StatusOK {
+		t.Errorf("admin quotas = %d", code)
+	}
+	if code, _ := doRequest(t, srv, http.MethodDelete, "/api/admin/quotas/ops", ""); code != http.StatusNotFound {
+		t.Errorf("DELETE without an override = %d, want 404", code)
+	}
+}
+
+func TestQuotaStore_FullKeepsBusyBuckets(t *testing.T) {
+	q := &quotaStore{overrides: map[string]Quota{}, buckets: map[string]*tokenBucket{}}
+	now := time.Now()
+
+	q.allow("busy", 1, now)
+	if q.allow("busy", 1, now) {
+		t.Fatal("second request in the same instant allowed at 1 rps")
+	}
+	for i := len(q.buckets); i < maxQuotaBuckets; i++ {
+		q.allow("filler-"+strconv.Itoa(i), 1, now.Add(-500*time.Millisecond))
+	}
+
+	// A flood of new subjects evicts the least recently used, one at a time
+	for i := 0; i < 10; i++ {
+		q.allow("new-"+strconv.Itoa(i), 1, now)
+	}
+	if len(q.buckets) != maxQuotaBuckets {
+		t.Errorf("buckets = %d, want %d", len(q.buckets), maxQuotaBuckets)
+	}
+	if q.allow("busy", 1, now) {
+		t.Error("busy subject's bucket was reset by the flood")
+	}
+	if q.throttled("busy") != 2 {
+		t.Errorf("busy throttled = %d, want 2", q.throttled("busy"))
+	}
+
+	// Idle buckets all go at once
+	q.allow("later", 1, now.Add(2*time.Second))
+	if len(q.buckets) != 1 {
+		t.Errorf("buckets after everyone went idle = %d, want 1", len(q.buckets))
+	}
+}
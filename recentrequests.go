@@ -0,0 +1,134 @@
+package main
+
+import (
+	"encoding/json"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// =============================================================================
+// Recent Requests
+// =============================================================================
+//
+// The last RECENT_REQUESTS requests this replica handled, kept in memory for
+// the status page (status.go) and the dashboard's Traffic panel.
+// loggingMiddleware adds one entry per request:
+//
+//	curl "http://localhost:8080/api/requests/recent?limit=5"
+//	[{"time":"...","method":"GET","path":"/api/items","status":200,
+//	  "latency_ms":0.4,"client":"10.0.0.7","instance":"demo-app-7d9f-abcde"}, ...]
+//
+// Each replica only knows its own traffic. Behind a load balancer, polling
+// this endpoint shows a different instance's list on each answer — which is
+// the point of the demo.
+//
+// A ring buffer is a fixed-size slice where the write position wraps around
+// to the start, overwriting the oldest entry — like collections.deque with
+// maxlen in Python. Memory stays constant no matter how much traffic comes in.
+
+// How many requests are kept, from RECENT_REQUESTS (set in main)
+const recentRequestsSize = 50
+
+// Not recorded, or the Traffic panel's polling would push everything else out
+const recentRequestsPath = "/api/requests/recent"
+
+// RecentRequest is one handled request
+type RecentRequest struct {
+	Time      time.Time `json:"time"`
+	Method    string    `json:"method"`
+	Path      string    `json:"path"`
+	Status    int       `json:"status"`
+	LatencyMs float64   `json:"latency_ms"`
+	Client    string    `json:"client"`
+	Instance  string    `json:"instance"` // hostname of the replica that served it
+}
+
+// requestRing holds the newest entries; next is where the next one goes.
+// A ring of size 0 records nothing.
+type requestRing struct {
+	mu       sync.Mutex
+	entries  []RecentRequest
+	next     int
+	full     bool
+	instance string // looked up once instead of per request
+}
+
+var recentRequests = newRequestRing(recentRequestsSize)
+
+func newRequestRing(size int) *requestRing {
+	hostname, err := os.Hostname()
+	if err != nil {
+		hostname = "unknown"
+	}
+	return &requestRing{entries: make([]RecentRequest, max(size, 0)), instance: hostname}
+}
+
+// add records a request, overwriting the oldest once the ring is full
+func (r *requestRing) add(entry RecentRequest) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if len(r.entries) == 0 {
+		return
+	}
+	entry.Instance = r.instance
+	r.entries[r.next] = entry
+	r.next++
+	if r.next == len(r.entries) {
+		r.next = 0
+		r.full = true
+	}
+}
+
+// list returns up to limit entries, newest first (limit <= 0 means all)
+func (r *requestRing) list(limit int) []RecentRequest {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	count := r.next
+	if r.full {
+		count = len(r.entries)
+	}
+	if limit > 0 && limit < count {
+		count = limit
+	}
+	out := make([]RecentRequest, 0, count)
+	for i := 1; i <= count; i++ {
+		// Walk backwards from the newest entry, wrapping around
+		out = append(out, r.entries[(r.next-i+len(r.entries))%len(r.entries)])
+	}
+	return out
+}
+
+// clientHost strips the port from a RemoteAddr ("10.0.0.7:51234" -> "10.0.0.7")
+func clientHost(remoteAddr string) string {
+	host, _, err := net.SplitHostPort(remoteAddr)
+	if err != nil {
+		return remoteAddr
+	}
+	return host
+}
+
+// recentRequestsHandler handles GET /api/requests/recent (?limit=N, newest first)
+func recentRequestsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, `{"error":"method not allowed"}`, http.StatusMethodNotAllowed)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+
+	limit := 0
+	if raw := r.URL.Query().Get("limit"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n < 1 {
+			http.Error(w, `{"error":"limit must be a positive integer"}`, http.StatusBadRequest)
+			return
+		}
+		limit = n
+	}
+
+	json.NewEncoder(w).Encode(recentRequests.list(limit))
+}
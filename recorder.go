@@ -0,0 +1,389 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	badger "github.com/dgraph-io/badger/v4"
+)
+
+// =============================================================================
+// Request Recorder and Replay
+// =============================================================================
+//
+// Record the writes a demo makes, then play them again — against this
+// instance or another environment. That's traffic mirroring and "promote
+// what we did in dev to staging" in two calls:
+//
+//	curl -X PUT http://localhost:8080/api/admin/recorder -d '{"enabled": true}'
+//	... create, update, and delete some items ...
+//	curl -X PUT http://localhost:8080/api/admin/recorder -d '{"enabled": false}'
+//	curl http://localhost:8080/api/admin/recorder/requests
+//	curl -X POST http://localhost:8080/api/admin/replay -d '{"target": "https://staging.example.com"}'
+//
+// While recording, every POST/PUT/PATCH/DELETE is saved to BadgerDB before
+// it is handled: method, path and query, headers, and body. Admin, cluster,
+// and standby shipment requests aren't recorded, and neither are replayed
+// ones (they carry X-Demo-Replay), so a replay against this instance can't
+// record itself. Authorization, Cookie, X-API-Key, and X-Internal-Key
+// headers are dropped rather than stored.
+//
+// Recordings belong to the replica that took the requests; they aren't
+// replicated.
+
+// Key prefix for recorded requests (a time-ordered number follows)
+const recordingKeyPrefix = "recording:"
+
+// Limits: recording stops at maxRecordings; bodies are kept up to
+// maxRecordedBody bytes (longer ones are marked truncated and not replayed)
+const (
+	maxRecordings   = 1000
+	maxRecordedBody = 1 << 20 // 1 MiB
+)
+
+// Set on replayed requests
+const replayHeader = "X-Demo-Replay"
+
+// Headers not worth (or not safe) storing, in canonical form. The API keys
+// are credentials, and recordings end up in analyze's exports.
+var unrecordedHeaders = map[string]bool{
+	"Authorization": true, "Cookie": true, "Connection": true,
+	"Content-Length": true, "Accept-Encoding": true, "Keep-Alive": true,
+	"Traceparent": true, http.CanonicalHeaderKey(apiKeyHeader): true,
+	http.CanonicalHeaderKey(internalKeyHeader): true,
+}
+
+// RecordedRequest is one captured request
+type RecordedRequest struct {
+	ID            int64       `json:"id"`
+	Time          time.Time   `json:"time"`
+	Method        string      `json:"method"`
+	Path          string      `json:"path"` // includes the query string
+	Header        http.Header `json:"header"`
+	Body          []byte      `json:"body"` // base64 in JSON
+	BodyTruncated bool        `json:"body_truncated,omitempty"`
+}
+
+// ReplayResult is the outcome of replaying one request
+type ReplayResult struct {
+	ID     int64  `json:"id"`
+	Method string `json:"method"`
+	Path   string `json:"path"`
+	Status int    `json:"status,omitempty"`
+	Error  string `json:"error,omitempty"`
+}
+
+// requestRecorder is the on/off switch plus the count of stored requests
+type requestRecorder struct {
+	mu      sync.Mutex
+	enabled bool
+	count   int
+	lastID  int64
+}
+
+var replayRecorder = &requestRecorder{}
+
+// recordable reports whether a request should be captured
+func recordable(r *http.Request) bool {
+	switch r.Method {
+	case http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete:
+	default:
+		return false
+	}
+	// Internal endpoints: a standby shipment is a whole Badger backup
+	// (standby.go), and replaying one would post it back to the server
+	if strings.HasPrefix(r.URL.Path, "/api/admin/") || strings.HasPrefix(r.URL.Path, "/api/cluster") ||
+		r.URL.Path == "/api/standby/receive" {
+		return false
+	}
+	// Replays, and copies of requests another replica already recorded
+	return r.Header.Get(replayHeader) == "" &&
+		r.Header.Get(clusterForwardedHeader) == "" &&
+		r.Header.Get(displayFromHeader) == ""
+}
+
+// capture saves the request if recording is on. The body is read and put
+// back, so the handler still sees all of it. Called by loggingMiddleware.
+func (rec *requestRecorder) capture(r *http.Request) {
+	rec.mu.Lock()
+	enabled := rec.enabled
+	rec.mu.Unlock()
+	if !enabled || !recordable(r) {
+		return
+	}
+
+	// Read outside the lock: a slow upload shouldn't hold up other requests
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxRecordedBody+1))
+	if err != nil {
+		slog.Warn("failed to read request body for recording", "error", err)
+		return
+	}
+	// The handler reads what we read, then whatever is left
+	r.Body = struct {
+		io.Reader
+		io.Closer
+	}{io.MultiReader(bytes.NewReader(body), r.Body), r.Body}
+
+	recorded := RecordedRequest{
+		Time:   time.Now().UTC(),
+		Method: r.Method,
+		Path:   r.URL.RequestURI(),
+		Header: http.Header{},
+		Body:   body,
+	}
+	if len(body) > maxRecordedBody {
+		recorded.Body, recorded.BodyTruncated = body[:maxRecordedBody], true
+	}
+	for name, values := range r.Header {
+		if !unrecordedHeaders[name] {
+			recorded.Header[name] = values
+		}
+	}
+
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+	if !rec.enabled {
+		return // switched off meanwhile
+	}
+	if rec.count >= maxRecordings {
+		rec.enabled = false
+		slog.Warn("request recorder is full, recording stopped", "max", maxRecordings)
+		return
+	}
+	// Nanosecond timestamps as IDs keep keys in arrival order
+	recorded.ID = max(time.Now().UnixNano(), rec.lastID+1)
+
+	data, err := json.Marshal(recorded)
+	if err == nil {
+		err = dbUpdate("recording_save", recordingKeyPrefix, func(txn *badger.Txn) error {
+			return txn.Set(recordingKey(recorded.ID), data)
+		})
+	}
+	if err != nil {
+		slog.Warn("failed to save recorded request", "error", err)
+		return
+	}
+	rec.lastID = recorded.ID
+	rec.count++
+}
+
+func recordingKey(id int64) []byte {
+	return []byte(fmt.Sprintf("%s%020d", recordingKeyPrefix, id))
+}
+
+// loadRecordings returns every stored request, oldest first
+func loadRecordings() ([]RecordedRequest, error) {
+	list := []RecordedRequest{}
+	err := dbView("recording_list", recordingKeyPrefix, func(txn *badger.Txn) error {
+		it := txn.NewIterator(badger.DefaultIteratorOptions)
+		defer it.Close()
+
+		prefix := []byte(recordingKeyPrefix)
+		for it.Seek(prefix); it.ValidForPrefix(prefix); it.Next() {
+			var recorded RecordedRequest
+			if err := it.Item().Value(func(val []byte) error { return json.Unmarshal(val, &recorded) }); err != nil {
+				return err
+			}
+			list = append(list, recorded)
+		}
+		return nil
+	})
+	return list, err
+}
+
+// status is what GET /api/admin/recorder reports
+func (rec *requestRecorder) status() map[string]any {
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+	return map[string]any{"enabled": rec.enabled, "count": rec.count, "max": maxRecordings}
+}
+
+// setEnabled turns recording on or off. Turning it on counts what's already
+// stored, so the limit holds across restarts and resets.
+func (rec *requestRecorder) setEnabled(enabled bool) error {
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+	if enabled && !rec.enabled {
+		count := 0
+		err := dbView("recording_count", recordingKeyPrefix, func(txn *badger.Txn) error {
+			opts := badger.DefaultIteratorOptions
+			opts.PrefetchValues = false
+			it := txn.NewIterator(opts)
+			defer it.Close()
+			prefix := []byte(recordingKeyPrefix)
+			for it.Seek(prefix); it.ValidForPrefix(prefix); it.Next() {
+				count++
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		rec.count = count
+	}
+	rec.enabled = enabled
+	return nil
+}
+
+// clear deletes every stored request
+func (rec *requestRecorder) clear() error {
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+	if err := db.DropPrefix([]byte(recordingKeyPrefix)); err != nil {
+		return err
+	}
+	rec.count = 0
+	return nil
+}
+
+// replay sends every stored request, in order, to target (a base URL),
+// giving up when ctx ends
+func replay(ctx context.Context, recordings []RecordedRequest, target string) []ReplayResult {
+	client := newOutboundClient(10 * time.Second) // outbound.go
+	if target == jobSelfURL {
+		client = newSelfClient(10 * time.Second) // in-process, see selfclient.go
+	}
+	// Report redirects instead of following them with a different method
+	client.CheckRedirect = func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }
+
+	results := make([]ReplayResult, 0, len(recordings))
+	for _, recorded := range recordings {
+		result := ReplayResult{ID: recorded.ID, Method: recorded.Method, Path: recorded.Path}
+		if recorded.BodyTruncated {
+			result.Error = "skipped: body was too large to record"
+			results = append(results, result)
+			continue
+		}
+
+		req, err := http.NewRequestWithContext(ctx, recorded.Method, target+recorded.Path, bytes.NewReader(recorded.Body))
+		if err != nil {
+			result.Error = err.Error()
+			results = append(results, result)
+			continue
+		}
+		req.Header = recorded.Header.Clone()
+		if req.Header == nil {
+			req.Header = http.Header{}
+		}
+		req.Header.Set(replayHeader, "true")
+
+		resp, err := client.Do(req)
+		if err != nil {
+			result.Error = err.Error()
+		} else {
+			io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+			result.Status = resp.StatusCode
+		}
+		results = append(results, result)
+	}
+	return results
+}
+
+// recorderAdminHandler handles /api/admin/recorder (GET status, PUT
+// {"enabled": bool}, DELETE to clear) and GET /api/admin/recorder/requests
+func recorderAdminHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	if r.URL.Path == "/api/admin/recorder/requests" {
+		if r.Method != http.MethodGet {
+			http.Error(w, `{"error":"method not allowed"}`, http.StatusMethodNotAllowed)
+			return
+		}
+		list, err := loadRecordings()
+		if err != nil {
+			logHandlerError(r.Context(), "recorder", "database", "failed to list recorded requests", "error", err)
+			http.Error(w, `{"error":"database error"}`, http.StatusInternalServerError)
+			return
+		}
+		json.NewEncoder(w).Encode(list)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPut:
+		var input struct {
+			Enabled *bool `json:"enabled"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&input); err != nil || input.Enabled == nil {
+			http.Error(w, `{"error":"body must be {\"enabled\": true|false}"}`, http.StatusBadRequest)
+			return
+		}
+		if err := replayRecorder.setEnabled(*input.Enabled); err != nil {
+			logHandlerError(r.Context(), "recorder", "database", "failed to count recorded requests", "error", err)
+			http.Error(w, `{"error":"database error"}`, http.StatusInternalServerError)
+			return
+		}
+		slog.InfoContext(r.Context(), "request recorder toggled", "enabled", *input.Enabled)
+	case http.MethodDelete:
+		if err := replayRecorder.clear(); err != nil {
+			logHandlerError(r.Context(), "recorder", "database", "failed to clear recorded requests", "error", err)
+			http.Error(w, `{"error":"database error"}`, http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+		return
+	default:
+		http.Error(w, `{"error":"method not allowed"}`, http.StatusMethodNotAllowed)
+		return
+	}
+	json.NewEncoder(w).Encode(replayRecorder.status())
+}
+
+// replayAdminHandler handles POST /api/admin/replay {"target": "https://..."}.
+// Without a target the requests go back to this instance.
+func replayAdminHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	if r.Method != http.MethodPost {
+		http.Error(w, `{"error":"method not allowed"}`, http.StatusMethodNotAllowed)
+		return
+	}
+
+	var input struct {
+		Target string `json:"target"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil && !errors.Is(err, io.EOF) {
+		http.Error(w, `{"error":"invalid json"}`, http.StatusBadRequest)
+		return
+	}
+	target := strings.TrimRight(input.Target, "/")
+	if target == "" {
+		target = jobSelfURL
+	} else if u, err := url.Parse(target); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		http.Error(w, `{"error":"target must be an http(s) URL"}`, http.StatusBadRequest)
+		return
+	}
+
+	recordings, err := loadRecordings()
+	if err != nil {
+		logHandlerError(r.Context(), "recorder", "database", "failed to load recorded requests", "error", err)
+		http.Error(w, `{"error":"database error"}`, http.StatusInternalServerError)
+		return
+	}
+
+	results := replay(r.Context(), recordings, target)
+	failed := 0
+	for _, result := range results {
+		if result.Error != "" || result.Status >= 400 {
+			failed++
+		}
+	}
+	slog.InfoContext(r.Context(), "recorded requests replayed", "target", target, "count", len(results), "failed", failed)
+	json.NewEncoder(w).Encode(map[string]any{
+		"target":   target,
+		"replayed": len(results),
+		"failed":   failed,
+		"results":  results,
+	})
+}
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+// withRecorder gives a test a fresh recorder, off when the test ends
+func withRecorder(t *testing.T) {
+	t.Helper()
+	prev := replayRecorder
+	replayRecorder = &requestRecorder{}
+	t.Cleanup(func() { replayRecorder = prev })
+}
+
+func TestRecorder_RecordAndReplay(t *testing.T) {
+	srv := newTestServer(t)
+	withRecorder(t)
+
+	if code, body := doRequest(t, srv, http.MethodPut, "/api/admin/recorder", `{"enabled":true}`); code != http.StatusOK || !strings.Contains(string(body), `"enabled":true`) {
+		t.Fatalf("enable = %d %s", code, body)
+	}
+
+	item := createTestItem(t, srv, `{"name":"recorded","tags":["a"]}`)
+	doRequest(t, srv, http.MethodGet, "/api/items", "") // reads aren't recorded
+	req, _ := http.NewRequest(http.MethodPut, srv.URL+"/api/kv/flags/x?note=1", strings.NewReader("on"))
+	req.Header.Set("Authorization", "Bearer secret")
+	req.Header.Set(apiKeyHeader, "key-secret")
+	req.Header.Set("X-Custom", "kept")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	doRequest(t, srv, http.MethodPut, "/api/admin/recorder", `{"enabled":false}`)
+	createTestItem(t, srv, `{"name":"not recorded"}`)
+
+	// The handler still got the whole body while it was being recorded
+	if item.Name != "recorded" {
+		t.Errorf("item = %+v", item)
+	}
+
+	_, body := doRequest(t, srv, http.MethodGet, "/api/admin/recorder/requests", "")
+	var list []RecordedRequest
+	json.Unmarshal(body, &list)
+	if len(list) != 2 {
+		t.Fatalf("recorded = %s", body)
+	}
+	if list[0].Method != http.MethodPost || list[0].Path != "/api/items" || !strings.Contains(string(list[0].Body), "recorded") {
+		t.Errorf("first = %+v", list[0])
+	}
+	if list[1].Path != "/api/kv/flags/x?note=1" || list[1].Header.Get("X-Custom") != "kept" || list[1].Header.Get("Authorization") != "" || list[1].Header.Get(apiKeyHeader) != "" {
+		t.Errorf("second = %+v", list[1])
+	}
+
+	// Replay against another "environment"
+	var got []string
+	var replayed atomic.Int32
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		replayed.Add(1)
+		got = append(got, r.Method+" "+r.URL.RequestURI()+" "+r.Header.Get(replayHeader))
+		w.WriteHeader(http.StatusCreated)
+	}))
+	defer target.Close()
+
+	code, body := doRequest(t, srv, http.MethodPost, "/api/admin/replay", `{"target":"`+target.URL+`/"}`)
+	if code != http.StatusOK || !strings.Contains(string(body), `"replayed":2`) || !strings.Contains(string(body), `"failed":0`) {
+		t.Fatalf("replay = %d %s", code, body)
+	}
+	want := []string{"POST /api/items true", "PUT /api/kv/flags/x?note=1 true"}
+	if strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Errorf("target saw %q, want %q", got, want)
+	}
+
+	// Replaying to ourselves with the recorder on doesn't record the replay
+	doRequest(t, srv, http.MethodPut, "/api/admin/recorder", `{"enabled":true}`)
+	doRequest(t, srv, http.MethodPost, "/api/admin/replay", `{"target":"`+srv.URL+`"}`)
+	_, body = doRequest(t, srv, http.MethodGet, "/api/admin/recorder", "")
+	if !strings.Contains(string(body), `"count":2`) {
+		t.Errorf("status after self-replay = %s, want count 2", body)
+	}
+	if code, _ := doRequest(t, srv, http.MethodGet, "/api/items?name=recorded", ""); code != http.StatusOK {
+		t.Errorf("list = %d", code)
+	}
+
+	if code, _ := doRequest(t, srv, http.MethodDelete, "/api/admin/recorder", ""); code != http.StatusNoContent {
+		t.Errorf("clear = %d, want 204", code)
+	}
+	if _, body := doRequest(t, srv, http.MethodGet, "/api/admin/recorder/requests", ""); string(body) != "[]\n" {
+		t.Errorf("after clear = %s", body)
+	}
+}
+
+func TestRecordable(t *testing.T) {
+	tests := []struct {
+		method, path, header string
+		want                 bool
+	}{
+		{http.MethodPost, "/api/items", "", true},
+		{http.MethodDelete, "/api/items/1", "", true},
+		{http.MethodGet, "/api/items", "", false},
+		{http.MethodPost, "/api/admin/reset", "", false},
+		{http.MethodPost, "/api/standby/receive", "", false},
+		{http.MethodPost, "/api/items", replayHeader, false},
+		{http.MethodPost, "/api/items", clusterForwardedHeader, false},
+		{http.MethodPost, "/api/display", displayFromHeader, false},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest(tt.method, tt.path, nil)
+		if tt.header != "" {
+			r.Header.Set(tt.header, "x")
+		}
+		if got := recordable(r); got != tt.want {
+			t.Errorf("%s %s (%s) = %v, want %v", tt.method, tt.path, tt.header, got, tt.want)
+		}
+	}
+}
+
+func TestRecorderAdmin_Errors(t *testing.T) {
+	srv := newTestServer(t)
+	withRecorder(t)
+
+	tests := []struct {
+		name       string
+		method     string
+		path       string
+		body       string
+		wantStatus int
+	}{
+		{"missing enabled", http.MethodPut, "/api/admin/recorder", `{}`, http.StatusBadRequest},
+		{"invalid json", http.MethodPut, "/api/admin/recorder", `{`, http.StatusBadRequest},
+		{"bad target", http.MethodPost, "/api/admin/replay", `{"target":"ftp://x"}`, http.StatusBadRequest},
+		{"replay GET", http.MethodGet, "/api/admin/replay", "", http.StatusMethodNotAllowed},
+		{"requests is read-only", http.MethodPost, "/api/admin/recorder/requests", "", http.StatusMethodNotAllowed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if code, body := doRequest(t, srv, tt.method, tt.path, tt.body); code != tt.wantStatus {
+				t.Errorf("status = %d %s, want %d", code, body, tt.wantStatus)
+			}
+		})
+	}
+}
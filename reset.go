@@ -0,0 +1,162 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// =============================================================================
+// Reset Endpoint
+// =============================================================================
+//
+// Back-to-back demo sessions should start clean without a redeploy.
+// POST /api/admin/reset wipes items and display data — but wiping
+// everything with one stray curl would be painful, so it's a two-step call:
+//
+//  1. POST /api/admin/reset                 -> 202 with a one-time confirm token
+//  2. POST /api/admin/reset?confirm=<token> -> performs the reset
+//
+// The token expires after resetTokenTTL. Like "type the repo name to delete
+// it" on GitHub — a speed bump, not a security control (that's ADMIN_TOKEN).
+
+// How long a reset confirmation token stays valid
+const resetTokenTTL = 60 * time.Second
+
+// The single outstanding confirmation token
+var (
+	resetMu          sync.Mutex
+	resetToken       string
+	resetTokenExpiry time.Time
+)
+
+// newResetToken creates and remembers a random confirmation token
+func newResetToken() (string, error) {
+	// crypto/rand, not math/rand: tokens shouldn't be guessable
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+
+	resetMu.Lock()
+	defer resetMu.Unlock()
+	resetToken = hex.EncodeToString(b)
+	resetTokenExpiry = time.Now().Add(resetTokenTTL)
+	return resetToken, nil
+}
+
+// consumeResetToken checks a token and invalidates it (one use only)
+func consumeResetToken(token string) bool {
+	resetMu.Lock()
+	defer resetMu.Unlock()
+
+	valid := resetToken != "" && token == resetToken && time.Now().Before(resetTokenExpiry)
+	if valid {
+		resetToken = ""
+	}
+	return valid
+}
+
+// resetStore drops all item data, counters, and KV entries, restarts the ID
+// sequence, clears the display panel, and zeroes the item gauge.
+func resetStore() error {
+	// Counter merge operators fold in the background; stop them before their
+	// keys go so a late fold can't bring a counter back (counters.go)
+	counters.stop()
+	hitCounter.stop()
+
+	// Item creates wait until the items are gone and IDs restart from zero:
+	// replaceItemSequence releases the sequence (which returns its unused
+	// lease), its key is dropped with the items, and a fresh one is leased.
+	//
+	// DropPrefix deletes every key with these prefixes in one efficient pass.
+	// Name/category indexes, attachments, links, quarantined records (fsck.go),
+	// idempotency records, and creation counts (timeseries.go) belong to
+	// items, so they go too. So do the tenants and their data (tenant.go),
+	// after their sequences are released and the cached registry forgotten.
+	err := replaceItemSequence(func() error {
+		tenants.reset()
+		return timeDBOp("reset", itemKeyPrefix, func() error {
+			return db.DropPrefix(
+				[]byte(itemKeyPrefix),
+				[]byte(nameIndexPrefix),
+				[]byte(categoryIndexPrefix),
+				[]byte(attachmentKeyPrefix),
+				[]byte(linkKeyPrefix),
+				[]byte(quarantineKeyPrefix),
+				[]byte(idempotencyKeyPrefix),
+				[]byte(counterKeyPrefix),
+				[]byte(hitCounterKeyPrefix),
+				[]byte(kvKeyPrefix),
+				[]byte(itemAggregatesKey),
+				[]byte(itemTimeseriesPrefix),
+				[]byte(tenantKeyPrefix),
+				[]byte(tenantRegistryPrefix),
+				[]byte("seq:items"),
+			)
+		})
+	})
+	if err != nil {
+		return err
+	}
+
+	// Followers in cluster mode start over on their next pull (cluster.go)
+	dataGeneration.Add(1)
+	// New ETag for the (now empty) item list (itemsetag.go)
+	if err := bumpItemsVersion(); err != nil {
+		return err
+	}
+
+	setDisplayData(nil)
+	itemsTotal.Set(0)
+	tenantItemsTotal.Reset()
+	return nil
+}
+
+// resetAdminHandler implements the two-step reset (POST only)
+func resetAdminHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, `{"error":"method not allowed"}`, http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+
+	confirm := r.URL.Query().Get("confirm")
+
+	// Step 1: no token yet — hand one out
+	if confirm == "" {
+		token, err := newResetToken()
+		if err != nil {
+			logHandlerError(r.Context(), "admin_reset", "token", "failed to create reset token", "error", err)
+			http.Error(w, `{"error":"internal error"}`, http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusAccepted)
+		json.NewEncoder(w).Encode(map[string]any{
+			"message":       "repeat with ?confirm=<token> to wipe all items and display data",
+			"confirm_token": token,
+			"expires_in":    int(resetTokenTTL.Seconds()),
+		})
+		return
+	}
+
+	// Step 2: token supplied — check it and reset
+	if !consumeResetToken(confirm) {
+		http.Error(w, `{"error":"invalid or expired confirm token"}`, http.StatusBadRequest)
+		return
+	}
+
+	if err := resetStore(); err != nil {
+		logHandlerError(r.Context(), "admin_reset", "database", "failed to reset store", "error", err)
+		http.Error(w, `{"error":"database error"}`, http.StatusInternalServerError)
+		return
+	}
+
+	slog.WarnContext(r.Context(), "demo environment reset", "client_ip", r.RemoteAddr)
+	json.NewEncoder(w).Encode(map[string]string{"status": "reset"})
+}
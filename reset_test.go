@@ -0,0 +1,118 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+)
+
+func TestResetAdmin_TwoStep(t *testing.T) {
+	insertItem(itemInput{Name: "Before Reset"}, "")
+	setDisplayData(json.RawMessage(`{"a":1}`))
+	counters.increment("clicks", 3)
+
+	// Wrong token is rejected and nothing is wiped
+	req := httptest.NewRequest("POST", "/api/admin/reset?confirm=nope", nil)
+	rr := httptest.NewRecorder()
+	resetAdminHandler(rr, req)
+	if rr.Code != http.StatusBadRequest {
+		t.Fatalf("expected status 400 for bad token, got %d", rr.Code)
+	}
+
+	// Step 1: get a token
+	req = httptest.NewRequest("POST", "/api/admin/reset", nil)
+	rr = httptest.NewRecorder()
+	resetAdminHandler(rr, req)
+	if rr.Code != http.StatusAccepted {
+		t.Fatalf("expected status 202, got %d", rr.Code)
+	}
+	var step1 map[string]any
+	json.Unmarshal(rr.Body.Bytes(), &step1)
+	token, _ := step1["confirm_token"].(string)
+
+	// Step 2: confirm
+	req = httptest.NewRequest("POST", "/api/admin/reset?confirm="+token, nil)
+	rr = httptest.NewRecorder()
+	resetAdminHandler(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+
+	empty, err := storeIsEmpty()
+	if err != nil || !empty {
+		t.Errorf("expected empty store after reset (err=%v)", err)
+	}
+	if getDisplayData() != nil {
+		t.Error("expected display data to be cleared")
+	}
+	if list, _ := counters.list(); len(list) != 0 {
+		t.Errorf("expected counters to be cleared, got %+v", list)
+	}
+
+	// IDs start over
+	item, _, _ := insertItem(itemInput{Name: "After Reset"}, "")
+	if item.ID != 0 {
+		t.Errorf("expected ID sequence to restart at 0, got %d", item.ID)
+	}
+
+	// Tokens are single-use
+	req = httptest.NewRequest("POST", "/api/admin/reset?confirm="+token, nil)
+	rr = httptest.NewRecorder()
+	resetAdminHandler(rr, req)
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("expected reused token to be rejected, got %d", rr.Code)
+	}
+}
+
+func TestResetStore_ConcurrentCreates(t *testing.T) {
+	newTestServer(t)
+	checkCreatesDuring(t, resetStore)
+}
+
+// checkCreatesDuring runs swap, which replaces the item ID sequence, three
+// times while items are being created, and checks no item got an ID the new
+// sequence will hand out again
+func checkCreatesDuring(t *testing.T, swap func() error) {
+	t.Helper()
+	stop := make(chan struct{})
+	var wg sync.WaitGroup
+	for range 4 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-stop:
+					return
+				default:
+					insertItem(itemInput{Name: "busy"}, "")
+				}
+			}
+		}()
+	}
+	for range 3 {
+		if err := swap(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	close(stop)
+	wg.Wait()
+
+	// An ID leased from a replaced sequence would sit above the new one's
+	// IDs, waiting to be overwritten
+	next, _, err := insertItem(itemInput{Name: "last"}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	items, err := loadAllItems()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, item := range items {
+		if item.ID > next.ID {
+			t.Errorf("item %d is above the next ID %d", item.ID, next.ID)
+		}
+	}
+}
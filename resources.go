@@ -0,0 +1,237 @@
+package main
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// =============================================================================
+// Resource Usage (for /api/system)
+// =============================================================================
+//
+// Resource-limit demos ("watch what happens when the pod hits its memory
+// limit") need live numbers. This collects:
+//   - the container's limits and usage, read from the cgroup filesystem
+//   - Go runtime and process stats (memory, CPU time, goroutines)
+//   - uptime and disk usage at DB_PATH
+//
+// Containers are just processes in a cgroup: Docker and Kubernetes write
+// the limits to files under /sys/fs/cgroup, and the kernel keeps usage
+// counters next to them. There are two layouts:
+//   - cgroup v2 (modern): one unified tree, e.g. memory.max, cpu.max
+//   - cgroup v1 (older hosts): one tree per controller, e.g.
+//     memory/memory.limit_in_bytes, cpu/cpu.cfs_quota_us
+
+// When the process started (for uptime)
+var startTime = time.Now()
+
+// Root of the cgroup filesystem inside a container
+const cgroupRoot = "/sys/fs/cgroup"
+
+// ResourceInfo is the "resources" section of /api/system
+type ResourceInfo struct {
+	StartedAt     time.Time   `json:"started_at"`
+	UptimeSeconds int64       `json:"uptime_seconds"`
+	Uptime        string      `json:"uptime"`
+	GOMAXPROCS    int         `json:"gomaxprocs"`
+	NumCPU        int         `json:"num_cpu"`
+	Goroutines    int         `json:"goroutines"`
+	Memory        MemoryInfo  `json:"memory"`
+	CPUSeconds    *float64    `json:"cpu_seconds,omitempty"` // process user+system CPU time
+	Cgroup        *CgroupInfo `json:"cgroup,omitempty"`      // nil outside a cgroup (e.g. macOS)
+	Disk          *DiskInfo   `json:"disk,omitempty"`        // nil for in-memory databases
+}
+
+// MemoryInfo is Go runtime memory usage
+type MemoryInfo struct {
+	HeapAllocBytes uint64 `json:"heap_alloc_bytes"` // live heap objects
+	SysBytes       uint64 `json:"sys_bytes"`        // total memory obtained from the OS
+	NumGC          uint32 `json:"num_gc"`
+	LeakedBytes    int64  `json:"leaked_bytes,omitempty"` // held by the leak simulation (memoryleak.go)
+}
+
+// CgroupInfo is the container's resource limits and usage.
+// nil limits mean "unlimited".
+type CgroupInfo struct {
+	Version          int      `json:"version"`
+	MemoryLimitBytes *uint64  `json:"memory_limit_bytes"`
+	MemoryUsageBytes *uint64  `json:"memory_usage_bytes,omitempty"`
+	CPULimitCores    *float64 `json:"cpu_limit_cores"`
+	CPUUsageSeconds  *float64 `json:"cpu_usage_seconds,omitempty"`
+}
+
+// DiskInfo is disk usage for the filesystem holding DB_PATH
+type DiskInfo struct {
+	Path        string  `json:"path"`
+	TotalBytes  uint64  `json:"total_bytes"`
+	FreeBytes   uint64  `json:"free_bytes"`
+	UsedPercent float64 `json:"used_percent"`
+	FillBytes   int64   `json:"chaos_fill_bytes,omitempty"` // junk from POST /api/chaos/disk (diskfill.go)
+}
+
+// getResourceInfo collects everything above. Sections that can't be read
+// on this platform are simply left out.
+func getResourceInfo() ResourceInfo {
+	uptime := time.Since(startTime)
+
+	// ReadMemStats briefly stops the world — fine for an on-demand endpoint
+	var mem runtime.MemStats
+	runtime.ReadMemStats(&mem)
+
+	info := ResourceInfo{
+		StartedAt:     startTime.UTC(),
+		UptimeSeconds: int64(uptime.Seconds()),
+		Uptime:        uptime.Truncate(time.Second).String(),
+		GOMAXPROCS:    runtime.GOMAXPROCS(0), // 0 = query without changing
+		NumCPU:        runtime.NumCPU(),
+		Goroutines:    runtime.NumGoroutine(),
+		Memory: MemoryInfo{
+			HeapAllocBytes: mem.HeapAlloc,
+			SysBytes:       mem.Sys,
+			NumGC:          mem.NumGC,
+			LeakedBytes:    leak.leakedBytes(),
+		},
+		Cgroup: readCgroupInfo(cgroupRoot),
+	}
+
+	if cpu, err := processCPUTime(); err == nil {
+		seconds := cpu.Seconds()
+		info.CPUSeconds = &seconds
+	}
+
+	info.Disk = getDiskInfo()
+	return info
+}
+
+// getDiskInfo is disk usage at DB_PATH, or nil for in-memory databases
+func getDiskInfo() *DiskInfo {
+	if storePath == "" || storePath == ":memory:" {
+		return nil
+	}
+	total, free, err := diskUsage(storePath)
+	if err != nil || total == 0 {
+		return nil
+	}
+	_, fillBytes := fill.usage(diskFillDir())
+	return &DiskInfo{
+		Path:        storePath,
+		TotalBytes:  total,
+		FreeBytes:   free,
+		UsedPercent: float64(total-free) / float64(total) * 100,
+		FillBytes:   fillBytes,
+	}
+}
+
+// readCgroupInfo detects the cgroup version under root and reads limits/usage
+func readCgroupInfo(root string) *CgroupInfo {
+	// cgroup.controllers only exists at the root of a v2 hierarchy
+	if _, err := os.Stat(filepath.Join(root, "cgroup.controllers")); err == nil {
+		return readCgroupV2(root)
+	}
+	if _, err := os.Stat(filepath.Join(root, "memory", "memory.limit_in_bytes")); err == nil {
+		return readCgroupV1(root)
+	}
+	return nil
+}
+
+// readCgroupV2 reads the unified hierarchy
+func readCgroupV2(root string) *CgroupInfo {
+	info := &CgroupInfo{Version: 2}
+
+	// memory.max: "max" or a byte count
+	if raw, err := readCgroupFile(root, "memory.max"); err == nil && raw != "max" {
+		if n, err := strconv.ParseUint(raw, 10, 64); err == nil {
+			info.MemoryLimitBytes = &n
+		}
+	}
+	if raw, err := readCgroupFile(root, "memory.current"); err == nil {
+		if n, err := strconv.ParseUint(raw, 10, 64); err == nil {
+			info.MemoryUsageBytes = &n
+		}
+	}
+
+	// cpu.max: "<quota> <period>" in microseconds, quota may be "max".
+	// "200000 100000" = 200ms of CPU every 100ms = 2 cores.
+	if raw, err := readCgroupFile(root, "cpu.max"); err == nil {
+		fields := strings.Fields(raw)
+		if len(fields) == 2 && fields[0] != "max" {
+			quota, err1 := strconv.ParseFloat(fields[0], 64)
+			period, err2 := strconv.ParseFloat(fields[1], 64)
+			if err1 == nil && err2 == nil && period > 0 {
+				cores := quota / period
+				info.CPULimitCores = &cores
+			}
+		}
+	}
+
+	// cpu.stat is "key value" lines; usage_usec is total CPU time
+	if f, err := os.Open(filepath.Join(root, "cpu.stat")); err == nil {
+		defer f.Close()
+		scanner := bufio.NewScanner(f)
+		for scanner.Scan() {
+			fields := strings.Fields(scanner.Text())
+			if len(fields) == 2 && fields[0] == "usage_usec" {
+				if usec, err := strconv.ParseFloat(fields[1], 64); err == nil {
+					seconds := usec / 1e6
+					info.CPUUsageSeconds = &seconds
+				}
+			}
+		}
+	}
+
+	return info
+}
+
+// readCgroupV1 reads the per-controller hierarchies
+func readCgroupV1(root string) *CgroupInfo {
+	info := &CgroupInfo{Version: 1}
+
+	// v1 has no "max" keyword: "unlimited" is a huge page-aligned number
+	// (close to 2^63), so treat anything that large as no limit
+	if raw, err := readCgroupFile(root, "memory/memory.limit_in_bytes"); err == nil {
+		if n, err := strconv.ParseUint(raw, 10, 64); err == nil && n < 1<<62 {
+			info.MemoryLimitBytes = &n
+		}
+	}
+	if raw, err := readCgroupFile(root, "memory/memory.usage_in_bytes"); err == nil {
+		if n, err := strconv.ParseUint(raw, 10, 64); err == nil {
+			info.MemoryUsageBytes = &n
+		}
+	}
+
+	// cfs_quota_us is -1 when unlimited
+	quotaRaw, err1 := readCgroupFile(root, "cpu/cpu.cfs_quota_us")
+	periodRaw, err2 := readCgroupFile(root, "cpu/cpu.cfs_period_us")
+	if err1 == nil && err2 == nil {
+		quota, err1 := strconv.ParseFloat(quotaRaw, 64)
+		period, err2 := strconv.ParseFloat(periodRaw, 64)
+		if err1 == nil && err2 == nil && quota > 0 && period > 0 {
+			cores := quota / period
+			info.CPULimitCores = &cores
+		}
+	}
+
+	// cpuacct.usage is total CPU time in nanoseconds
+	if raw, err := readCgroupFile(root, "cpuacct/cpuacct.usage"); err == nil {
+		if ns, err := strconv.ParseFloat(raw, 64); err == nil {
+			seconds := ns / 1e9
+			info.CPUUsageSeconds = &seconds
+		}
+	}
+
+	return info
+}
+
+// readCgroupFile reads a single-value cgroup file, trimmed
+func readCgroupFile(root, name string) (string, error) {
+	data, err := os.ReadFile(filepath.Join(root, name))
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(data)), nil
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// writeCgroupFiles creates a fake cgroup tree under a temp dir
+func writeCgroupFiles(t *testing.T, files map[string]string) string {
+	t.Helper()
+	root := t.TempDir()
+	for name, content := range files {
+		path := filepath.Join(root, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return root
+}
+
+func TestCgroup_V2Limits(t *testing.T) {
+	root := writeCgroupFiles(t, map[string]string{
+		"cgroup.controllers": "cpu memory\n",
+		"memory.max":         "536870912\n",
+		"memory.current":     "104857600\n",
+		"cpu.max":            "150000 100000\n",
+		"cpu.stat":           "usage_usec 2500000\nuser_usec 2000000\n",
+	})
+
+	info := readCgroupInfo(root)
+	if info == nil || info.Version != 2 {
+		t.Fatalf("expected cgroup v2, got %+v", info)
+	}
+	if info.MemoryLimitBytes == nil || *info.MemoryLimitBytes != 536870912 {
+		t.Errorf("unexpected memory limit: %v", info.MemoryLimitBytes)
+	}
+	if info.MemoryUsageBytes == nil || *info.MemoryUsageBytes != 104857600 {
+		t.Errorf("unexpected memory usage: %v", info.MemoryUsageBytes)
+	}
+	if info.CPULimitCores == nil || *info.CPULimitCores != 1.5 {
+		t.Errorf("unexpected cpu limit: %v", info.CPULimitCores)
+	}
+	if info.CPUUsageSeconds == nil || *info.CPUUsageSeconds != 2.5 {
+		t.Errorf("unexpected cpu usage: %v", info.CPUUsageSeconds)
+	}
+}
+
+func TestCgroup_V2Unlimited(t *testing.T) {
+	root := writeCgroupFiles(t, map[string]string{
+		"cgroup.controllers": "cpu memory\n",
+		"memory.max":         "max\n",
+		"cpu.max":            "max 100000\n",
+	})
+
+	info := readCgroupInfo(root)
+	if info.MemoryLimitBytes != nil || info.CPULimitCores != nil {
+		t.Errorf("expected no limits, got memory=%v cpu=%v", info.MemoryLimitBytes, info.CPULimitCores)
+	}
+}
+
+func TestCgroup_V1Limits(t *testing.T) {
+	root := writeCgroupFiles(t, map[string]string{
+		"memory/memory.limit_in_bytes": "268435456\n",
+		"memory/memory.usage_in_bytes": "1048576\n",
+		"cpu/cpu.cfs_quota_us":         "50000\n",
+		"cpu/cpu.cfs_period_us":        "100000\n",
+		"cpuacct/cpuacct.usage":        "3000000000\n",
+	})
+
+	info := readCgroupInfo(root)
+	if info == nil || info.Version != 1 {
+		t.Fatalf("expected cgroup v1, got %+v", info)
+	}
+	if info.MemoryLimitBytes == nil || *info.MemoryLimitBytes != 268435456 {
+		t.Errorf("unexpected memory limit: %v", info.MemoryLimitBytes)
+	}
+	if info.CPULimitCores == nil || *info.CPULimitCores != 0.5 {
+		t.Errorf("unexpected cpu limit: %v", info.CPULimitCores)
+	}
+	if info.CPUUsageSeconds == nil || *info.CPUUsageSeconds != 3 {
+		t.Errorf("unexpected cpu usage: %v", info.CPUUsageSeconds)
+	}
+}
+
+func TestCgroup_V1Unlimited(t *testing.T) {
+	root := writeCgroupFiles(t, map[string]string{
+		"memory/memory.limit_in_bytes": "9223372036854771712\n",
+		"cpu/cpu.cfs_quota_us":         "-1\n",
+		"cpu/cpu.cfs_period_us":        "100000\n",
+	})
+
+	info := readCgroupInfo(root)
+	if info.MemoryLimitBytes != nil || info.CPULimitCores != nil {
+		t.Errorf("expected no limits, got memory=%v cpu=%v", info.MemoryLimitBytes, info.CPULimitCores)
+	}
+}
+
+func TestCgroup_NotPresent(t *testing.T) {
+	if info := readCgroupInfo(t.TempDir()); info != nil {
+		t.Errorf("expected nil outside a cgroup, got %+v", info)
+	}
+}
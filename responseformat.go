@@ -0,0 +1,373 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.yaml.in/yaml/v2"
+)
+
+// =============================================================================
+// Response Formats (JSON, YAML, XML)
+// =============================================================================
+//
+// Infrastructure audiences often read YAML more easily than JSON, and some
+// tooling still wants XML. The items and system endpoints answer in any of
+// the three, picked by the Accept header or a ?format= override:
+//
+//	curl -H 'Accept: application/yaml' http://localhost:8080/api/items
+//	curl 'http://localhost:8080/api/system?format=yaml'
+//	curl -H 'Accept: application/xml' http://localhost:8080/api/items/1
+//
+// JSON is the default, and what a browser gets: browsers list
+// application/xml in their Accept header, so one that also takes text/html
+// is answered in JSON. Error responses are always JSON.
+//
+// writeResponse is the one place handlers go through. It encodes the value
+// to JSON first and converts from there, so the YAML and XML use the same
+// field names (the `json:"..."` tags) and the same order as the JSON.
+
+// responseFormat is one way to encode a response
+type responseFormat struct {
+	name        string   // for ?format=
+	contentType string   // sent in Content-Type
+	mediaTypes  []string // matched against Accept
+	encode      func(w io.Writer, v any) error
+}
+
+var responseFormats = []responseFormat{
+	{"json", "application/json", []string{"application/json"}, encodeJSONResponse},
+	{"yaml", "application/yaml", []string{"application/yaml", "application/x-yaml", "text/yaml", "text/x-yaml"}, encodeYAMLResponse},
+	{"xml", "application/xml", []string{"application/xml", "text/xml"}, encodeXMLResponse},
+}
+
+// negotiateFormat picks the response format: ?format= if given (an error
+// if it's unknown), else the Accept type with the highest q, else JSON
+func negotiateFormat(r *http.Request) (responseFormat, error) {
+	if name := r.URL.Query().Get("format"); name != "" {
+		for _, f := range responseFormats {
+			if strings.EqualFold(name, f.name) {
+				return f, nil
+			}
+		}
+		return responseFormat{}, fmt.Errorf("unknown format %q (want json, yaml, or xml)", name)
+	}
+
+	accept := r.Header.Get("Accept")
+	best, bestQ := responseFormats[0], 0.0
+	if strings.Contains(accept, "text/html") {
+		return best, nil // a browser
+	}
+	for _, part := range strings.Split(accept, ",") {
+		mediaType, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		mediaType = strings.ToLower(strings.TrimSpace(mediaType))
+		q := 1.0
+		if value, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			if parsed, err := strconv.ParseFloat(value, 64); err == nil {
+				q = parsed
+			}
+		}
+		for _, f := range responseFormats {
+			for _, candidate := range f.mediaTypes {
+				// Strictly greater: on a tie the first type listed wins
+				if mediaType == candidate && q > bestQ {
+					best, bestQ = f, q
+				}
+			}
+		}
+	}
+	return best, nil
+}
+
+// checkResponseFormat answers 400 for an unknown ?format=. Handlers that
+// change data call it first, so a typo doesn't leave a write behind.
+func checkResponseFormat(w http.ResponseWriter, r *http.Request) bool {
+	if _, err := negotiateFormat(r); err != nil {
+		writeJSONError(w, http.StatusBadRequest, err.Error())
+		return false
+	}
+	return true
+}
+
+// writeResponse encodes v in the format the client asked for, in an
+// envelope if one was asked for
+func writeResponse(w http.ResponseWriter, r *http.Request, status int, v any) {
+	format, err := negotiateFormat(r)
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if wantsEnvelope(r) {
+		v = wrapInEnvelope(w, r, v)
+	}
+
+	// Encode into a buffer so a failure can still become a clean 500
+	var body bytes.Buffer
+	if err := format.encode(&body, v); err != nil {
+		logHandlerError(r.Context(), "response", "encode", "failed to encode response", "format", format.name, "error", err)
+		http.Error(w, `{"error":"failed to encode response"}`, http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", format.contentType)
+	w.Header().Add("Vary", "Accept") // caches must keep the formats apart
+	w.WriteHeader(status)
+	w.Write(body.Bytes())
+}
+
+// encodeJSONResponse is what the handlers always wrote: compact JSON and
+// a newline
+func encodeJSONResponse(w io.Writer, v any) error {
+	return json.NewEncoder(w).Encode(v)
+}
+
+// orderedValue is decoded JSON that remembers the order of object keys
+// (a map[string]any would sort them)
+type orderedValue struct {
+	kind   byte // 'o' object, 'a' array, 's' scalar
+	keys   []string
+	values []orderedValue // object values (by keys) or array elements
+	scalar any            // string, json.Number, bool, or nil
+}
+
+// toOrdered turns v into an orderedValue by way of its JSON
+func toOrdered(v any) (orderedValue, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return orderedValue{}, err
+	}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber() // keep 12345678901234567890 as written
+	return readOrdered(decoder)
+}
+
+// readOrdered reads one JSON value from the decoder's tokens
+func readOrdered(decoder *json.Decoder) (orderedValue, error) {
+	token, err := decoder.Token()
+	if err != nil {
+		return orderedValue{}, err
+	}
+	switch token {
+	case json.Delim('{'):
+		node := orderedValue{kind: 'o'}
+		for decoder.More() {
+			key, err := decoder.Token()
+			if err != nil {
+				return orderedValue{}, err
+			}
+			value, err := readOrdered(decoder)
+			if err != nil {
+				return orderedValue{}, err
+			}
+			node.keys = append(node.keys, key.(string))
+			node.values = append(node.values, value)
+		}
+		_, err := decoder.Token() // }
+		return node, err
+	case json.Delim('['):
+		node := orderedValue{kind: 'a'}
+		for decoder.More() {
+			value, err := readOrdered(decoder)
+			if err != nil {
+				return orderedValue{}, err
+			}
+			node.values = append(node.values, value)
+		}
+		_, err := decoder.Token() // ]
+		return node, err
+	default:
+		return orderedValue{kind: 's', scalar: token}, nil
+	}
+}
+
+// encodeYAMLResponse writes block-style YAML. Objects become
+// yaml.MapSlice so their keys stay in the JSON's order.
+func encodeYAMLResponse(w io.Writer, v any) error {
+	node, err := toOrdered(v)
+	if err != nil {
+		return err
+	}
+	data, err := yaml.Marshal(node.yamlValue())
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(data)
+	return err
+}
+
+// yamlValue is the value for yaml.Marshal: a MapSlice for an object, a
+// slice for an array, and numbers as numbers (a json.Number is a string,
+// which YAML would quote)
+func (v orderedValue) yamlValue() any {
+	switch v.kind {
+	case 'o':
+		slice := make(yaml.MapSlice, len(v.keys))
+		for i, key := range v.keys {
+			slice[i] = yaml.MapItem{Key: key, Value: v.values[i].yamlValue()}
+		}
+		return slice
+	case 'a':
+		values := make([]any, len(v.values))
+		for i, value := range v.values {
+			values[i] = value.yamlValue()
+		}
+		return values
+	}
+	number, ok := v.scalar.(json.Number)
+	if !ok {
+		return v.scalar
+	}
+	if n, err := number.Int64(); err == nil {
+		return n
+	}
+	if n, err := strconv.ParseUint(number.String(), 10, 64); err == nil {
+		return n
+	}
+	if f, err := number.Float64(); err == nil {
+		return f
+	}
+	return number.String()
+}
+
+// encodeXMLResponse writes XML: objects become elements named after their
+// keys, array elements are <item>, and the whole is wrapped in <response>.
+// A key that isn't a valid element name (metadata can hold anything) is
+// written as <entry key="...">.
+func encodeXMLResponse(w io.Writer, v any) error {
+	node, err := toOrdered(v)
+	if err != nil {
+		return err
+	}
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+	if err := writeXML(encoder, xml.StartElement{Name: xml.Name{Local: "response"}}, node); err != nil {
+		return err
+	}
+	if err := encoder.Close(); err != nil {
+		return err
+	}
+	_, err = io.WriteString(w, "\n")
+	return err
+}
+
+// Element names we write as they are; "xml..." is reserved
+var xmlElementName = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9._-]*$`)
+
+// xmlElementFor is <key>, or <entry key="..."> when key can't be a name
+func xmlElementFor(key string) xml.StartElement {
+	if xmlElementName.MatchString(key) && !strings.HasPrefix(strings.ToLower(key), "xml") {
+		return xml.StartElement{Name: xml.Name{Local: key}}
+	}
+	return xml.StartElement{
+		Name: xml.Name{Local: "entry"},
+		Attr: []xml.Attr{{Name: xml.Name{Local: "key"}, Value: key}},
+	}
+}
+
+// writeXML writes node as the element start
+func writeXML(encoder *xml.Encoder, start xml.StartElement, node orderedValue) error {
+	if err := encoder.EncodeToken(start); err != nil {
+		return err
+	}
+	switch node.kind {
+	case 'o':
+		for i, key := range node.keys {
+			if err := writeXML(encoder, xmlElementFor(key), node.values[i]); err != nil {
+				return err
+			}
+		}
+	case 'a':
+		for _, value := range node.values {
+			if err := writeXML(encoder, xml.StartElement{Name: xml.Name{Local: "item"}}, value); err != nil {
+				return err
+			}
+		}
+	default:
+		// null is an empty element; everything else is its text
+		if node.scalar != nil {
+			text := fmt.Sprint(node.scalar)
+			if err := encoder.EncodeToken(xml.CharData(text)); err != nil {
+				return err
+			}
+		}
+	}
+	return encoder.EncodeToken(start.End())
+}
+
+// =============================================================================
+// Response Envelope
+// =============================================================================
+//
+// To show request routing in the payload itself (which replica answered,
+// how long it took) responses can be wrapped in an envelope:
+//
+//	curl 'http://localhost:8080/api/items?envelope=true'
+//	{"data":[{"id":1,...}],"meta":{"request_id":"5f1c...","instance":"demo-app-7d9f",
+//	 "duration_ms":0.42,"count":1}}
+//
+// RESPONSE_ENVELOPE=true makes it the default; ?envelope=false turns it off
+// for one request. It covers the responses writeResponse writes, in any
+// format. count is there for lists only. The request ID is the caller's
+// X-Request-ID if it sent one, and is returned in that header either way.
+
+// responseEnvelope wraps responses by default (RESPONSE_ENVELOPE, set in main)
+var responseEnvelope = false
+
+// Context key for when loggingMiddleware started the request
+type requestStartKey struct{}
+
+// envelope is the wrapper: the response as data, plus meta
+type envelope struct {
+	Data any          `json:"data"`
+	Meta envelopeMeta `json:"meta"`
+}
+
+type envelopeMeta struct {
+	RequestID  string  `json:"request_id"`
+	Instance   string  `json:"instance"`
+	DurationMs float64 `json:"duration_ms"`
+	Count      *int    `json:"count,omitempty"` // lists only
+}
+
+// wantsEnvelope is ?envelope= if it's a boolean, else RESPONSE_ENVELOPE
+func wantsEnvelope(r *http.Request) bool {
+	if on, err := strconv.ParseBool(r.URL.Query().Get("envelope")); err == nil {
+		return on
+	}
+	return responseEnvelope
+}
+
+// wrapInEnvelope builds the envelope for v and sets X-Request-ID
+func wrapInEnvelope(w http.ResponseWriter, r *http.Request, v any) envelope {
+	requestID := r.Header.Get(requestIDHeader)
+	if requestID == "" {
+		requestID = newRequestID()
+	}
+	w.Header().Set(requestIDHeader, requestID)
+
+	hostname, err := os.Hostname()
+	if err != nil {
+		hostname = "unknown"
+	}
+	meta := envelopeMeta{RequestID: requestID, Instance: hostname}
+	if start, ok := r.Context().Value(requestStartKey{}).(time.Time); ok {
+		meta.DurationMs = float64(time.Since(start).Microseconds()) / 1000
+	}
+	if value := reflect.ValueOf(v); value.Kind() == reflect.Slice || value.Kind() == reflect.Array {
+		count := value.Len()
+		meta.Count = &count
+	}
+	return envelope{Data: v, Meta: meta}
+}
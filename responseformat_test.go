@@ -0,0 +1,195 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestNegotiateFormat(t *testing.T) {
+	tests := []struct {
+		url, accept, want string
+	}{
+		{"/api/items", "", "json"},
+		{"/api/items", "*/*", "json"},
+		{"/api/items", "application/yaml", "yaml"},
+		{"/api/items", "text/x-yaml", "yaml"},
+		{"/api/items", "application/xml;q=0.5, application/yaml;q=0.9", "yaml"},
+		{"/api/items", "application/json, application/xml", "json"},
+		{"/api/items", "text/xml", "xml"},
+		// What Chrome sends when you open the URL: JSON, not XML
+		{"/api/items", "text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8", "json"},
+		{"/api/items?format=YAML", "application/xml", "yaml"},
+		{"/api/items?format=xml", "", "xml"},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest(http.MethodGet, tt.url, nil)
+		r.Header.Set("Accept", tt.accept)
+		got, err := negotiateFormat(r)
+		if err != nil || got.name != tt.want {
+			t.Errorf("%s with Accept %q = %q, %v; want %q", tt.url, tt.accept, got.name, err, tt.want)
+		}
+	}
+
+	r := httptest.NewRequest(http.MethodGet, "/api/items?format=toml", nil)
+	if _, err := negotiateFormat(r); err == nil {
+		t.Error("?format=toml: want an error")
+	}
+}
+
+func TestEncodeYAMLResponse(t *testing.T) {
+	value := map[string]any{
+		"name":  "Widget: large",
+		"tags":  []string{"blue", "yes"},
+		"empty": []string{},
+		"meta":  map[string]any{"count": 3, "owner": nil, "nested": []any{map[string]any{"a": 1, "b": true}}},
+	}
+	var buf bytes.Buffer
+	if err := encodeYAMLResponse(&buf, value); err != nil {
+		t.Fatal(err)
+	}
+	// Maps come out sorted (that's encoding/json); struct fields keep their order
+	want := `empty: []
+meta:
+  count: 3
+  nested:
+  - a: 1
+    b: true
+  owner: null
+name: 'Widget: large'
+tags:
+- blue
+- "yes"
+`
+	if buf.String() != want {
+		t.Errorf("YAML =\n%s\nwant\n%s", buf.String(), want)
+	}
+}
+
+func TestEncodeXMLResponse(t *testing.T) {
+	var buf bytes.Buffer
+	err := encodeXMLResponse(&buf, Item{ID: 7, Name: "a < b", Tags: []string{"x", "y"}, Metadata: map[string]any{"not a name": 1}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got struct {
+		ID    int64    `xml:"id"`
+		Name  string   `xml:"name"`
+		Tags  []string `xml:"tags>item"`
+		Entry struct {
+			Key   string `xml:"key,attr"`
+			Value string `xml:",chardata"`
+		} `xml:"metadata>entry"`
+	}
+	if err := xml.Unmarshal(buf.Bytes(), &got); err != nil {
+		t.Fatalf("not valid XML: %v\n%s", err, buf.String())
+	}
+	if got.ID != 7 || got.Name != "a < b" || len(got.Tags) != 2 || got.Entry.Key != "not a name" || got.Entry.Value != "1" {
+		t.Errorf("decoded %+v from\n%s", got, buf.String())
+	}
+}
+
+func TestResponseFormats_Endpoints(t *testing.T) {
+	srv := newTestServer(t)
+	item := createTestItem(t, srv, `{"name":"Widget"}`)
+
+	get := func(path, accept string) (*http.Response, string) {
+		t.Helper()
+		req, _ := http.NewRequest(http.MethodGet, srv.URL+path, nil)
+		req.Header.Set("Accept", accept)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		return resp, string(body)
+	}
+
+	resp, body := get("/api/items", "application/yaml")
+	if resp.Header.Get("Content-Type") != "application/yaml" || !strings.HasPrefix(body, "- id: ") || !strings.Contains(body, "  name: Widget\n") {
+		t.Errorf("items as YAML = %s %q", resp.Header.Get("Content-Type"), body)
+	}
+	if resp.Header.Get("Vary") != "Accept" {
+		t.Errorf("Vary = %q, want Accept", resp.Header.Get("Vary"))
+	}
+
+	resp, body = get("/api/items/"+strconv.FormatInt(item.ID, 10)+"?format=xml", "")
+	if resp.Header.Get("Content-Type") != "application/xml" || !strings.Contains(body, "<name>Widget</name>") {
+		t.Errorf("item as XML = %s %q", resp.Header.Get("Content-Type"), body)
+	}
+
+	resp, body = get("/api/system", "application/yaml")
+	if resp.StatusCode != http.StatusOK || !strings.Contains(body, "\nhostname: ") {
+		t.Errorf("system as YAML = %d %q", resp.StatusCode, body)
+	}
+
+	resp, _ = get("/api/system/diagnostics?format=xml", "")
+	if resp.Header.Get("Content-Type") != "application/xml" {
+		t.Errorf("diagnostics ?format=xml Content-Type = %q", resp.Header.Get("Content-Type"))
+	}
+
+	// An unknown format is refused before the item is created
+	code, data := doRequest(t, srv, http.MethodPost, "/api/items?format=toml", `{"name":"Gadget"}`)
+	if code != http.StatusBadRequest || !strings.Contains(string(data), "unknown format") {
+		t.Errorf("POST ?format=toml = %d %s, want 400", code, data)
+	}
+	if count, _ := countItems(); count != 1 {
+		t.Errorf("items after the refused POST = %d, want 1", count)
+	}
+}
+
+func TestResponseEnvelope(t *testing.T) {
+	srv := newTestServer(t)
+	createTestItem(t, srv, `{"name":"Widget"}`)
+
+	req, _ := http.NewRequest(http.MethodGet, srv.URL+"/api/items?envelope=true", nil)
+	req.Header.Set(requestIDHeader, "abc123")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got struct {
+		Data []Item
+		Meta map[string]any
+	}
+	json.NewDecoder(resp.Body).Decode(&got)
+	resp.Body.Close()
+	if len(got.Data) != 1 || got.Data[0].Name != "Widget" {
+		t.Errorf("data = %+v", got.Data)
+	}
+	if got.Meta["request_id"] != "abc123" || got.Meta["count"] != 1.0 || got.Meta["instance"] == "" {
+		t.Errorf("meta = %v", got.Meta)
+	}
+	if _, ok := got.Meta["duration_ms"].(float64); !ok {
+		t.Errorf("meta.duration_ms = %v", got.Meta["duration_ms"])
+	}
+	if resp.Header.Get(requestIDHeader) != "abc123" {
+		t.Errorf("X-Request-ID = %q", resp.Header.Get(requestIDHeader))
+	}
+
+	// Single objects have no count; works in YAML too
+	_, body := doRequest(t, srv, http.MethodGet, "/api/system?envelope=true&format=yaml", "")
+	if !strings.HasPrefix(string(body), "data:\n") || !strings.Contains(string(body), "\nmeta:\n  request_id: ") || strings.Contains(string(body), "count:") {
+		t.Errorf("system in an envelope as YAML = %s", body)
+	}
+
+	// RESPONSE_ENVELOPE=true is the default, ?envelope=false opts out
+	prev := responseEnvelope
+	responseEnvelope = true
+	t.Cleanup(func() { responseEnvelope = prev })
+	_, body = doRequest(t, srv, http.MethodGet, "/api/items", "")
+	if !strings.HasPrefix(string(body), `{"data":[`) {
+		t.Errorf("with RESPONSE_ENVELOPE = %s", body)
+	}
+	_, body = doRequest(t, srv, http.MethodGet, "/api/items?envelope=false", "")
+	if !strings.HasPrefix(string(body), `[{"id":`) {
+		t.Errorf("?envelope=false = %s", body)
+	}
+}
@@ -0,0 +1,362 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"path"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+)
+
+// =============================================================================
+// Response Rules Engine
+// =============================================================================
+//
+// Rules let you turn demo-app into a lightweight mock/chaos gateway without
+// writing new endpoints. Each rule has a MATCH half (which requests does it
+// apply to?) and a RESPONSE half (what should happen instead?).
+//
+// Example rule (JSON):
+//
+//	{
+//	  "id": "slow-items",
+//	  "match":    {"method": "GET", "path": "/api/items*"},
+//	  "response": {"delay_ms": 2000}
+//	}
+//
+// A rule with only a delay lets the request continue to the normal handler.
+// A rule with a status or body short-circuits and answers the request itself.
+//
+// Rules are evaluated in order; the first match wins.
+// They are loaded from RULES_FILE at startup (and again when it changes, see
+// configwatch.go) and managed via /api/admin/rules.
+
+// RuleMatch describes which requests a rule applies to.
+// Empty fields match everything.
+type RuleMatch struct {
+	Method  string            `json:"method,omitempty"`
+	Path    string            `json:"path,omitempty"`    // glob pattern, e.g. "/api/items/*"
+	Headers map[string]string `json:"headers,omitempty"` // header name -> exact value
+}
+
+// RuleResponse describes what to do with a matched request.
+type RuleResponse struct {
+	Status  int               `json:"status,omitempty"`
+	DelayMs int               `json:"delay_ms,omitempty"`
+	Headers map[string]string `json:"headers,omitempty"`
+	Body    string            `json:"body,omitempty"` // Go text/template, see ruleTemplateData
+}
+
+// Rule is a single match → response pair.
+type Rule struct {
+	ID       string       `json:"id"`
+	Match    RuleMatch    `json:"match"`
+	Response RuleResponse `json:"response"`
+
+	// Parsed body template, built once when the rule is added.
+	// The "-" tag keeps it out of JSON output.
+	bodyTmpl *template.Template `json:"-"`
+}
+
+// ruleTemplateData is what a rule's body template can reference.
+// Example body: {"you_called": "{{.Method}} {{.Path}}", "id": "{{.Query.id}}"}
+type ruleTemplateData struct {
+	Method  string
+	Path    string
+	Query   map[string]string
+	Headers map[string]string
+}
+
+// ruleStore holds the active rules.
+// A sync.RWMutex guards the slice because the admin API writes while
+// request goroutines read. RWMutex allows many readers OR one writer —
+// similar to a readers-writer lock in Python's threading world.
+type ruleStore struct {
+	mu    sync.RWMutex
+	rules []*Rule
+}
+
+// Package-level rule store, shared by middleware and admin handlers
+var rules = &ruleStore{}
+
+// compileRule validates a rule and parses its body template
+func compileRule(rule *Rule) error {
+	if rule.ID == "" {
+		return fmt.Errorf("id is required")
+	}
+	if rule.Match.Path != "" {
+		// path.Match returns ErrBadPattern for malformed globs — check it once up front
+		if _, err := path.Match(rule.Match.Path, "/"); err != nil {
+			return fmt.Errorf("invalid path pattern %q: %w", rule.Match.Path, err)
+		}
+	}
+	if rule.Response.Status != 0 && (rule.Response.Status < 100 || rule.Response.Status > 599) {
+		return fmt.Errorf("invalid status %d", rule.Response.Status)
+	}
+	if rule.Response.Body != "" {
+		tmpl, err := template.New(rule.ID).Parse(rule.Response.Body)
+		if err != nil {
+			return fmt.Errorf("invalid body template: %w", err)
+		}
+		rule.bodyTmpl = tmpl
+	}
+	return nil
+}
+
+// list returns a copy of the current rules (safe to use after the lock is released)
+func (s *ruleStore) list() []*Rule {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	out := make([]*Rule, len(s.rules))
+	copy(out, s.rules)
+	return out
+}
+
+// add appends a rule, or replaces an existing rule with the same ID
+func (s *ruleStore) add(rule *Rule) error {
+	if err := compileRule(rule); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i, existing := range s.rules {
+		if existing.ID == rule.ID {
+			s.rules[i] = rule
+			return nil
+		}
+	}
+	s.rules = append(s.rules, rule)
+	return nil
+}
+
+// replace swaps the whole rule set (all-or-nothing: one bad rule rejects the set)
+func (s *ruleStore) replace(newRules []*Rule) error {
+	for i, rule := range newRules {
+		if rule == nil { // a JSON null in the list
+			return fmt.Errorf("rule %d is null", i+1)
+		}
+		if err := compileRule(rule); err != nil {
+			return fmt.Errorf("rule %q: %w", rule.ID, err)
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.rules = newRules
+	return nil
+}
+
+// remove deletes a rule by ID, reporting whether it existed
+func (s *ruleStore) remove(id string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i, rule := range s.rules {
+		if rule.ID == id {
+			s.rules = append(s.rules[:i], s.rules[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// find returns the first rule matching the request, or nil
+func (s *ruleStore) find(r *http.Request) *Rule {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	for _, rule := range s.rules {
+		if rule.matches(r) {
+			return rule
+		}
+	}
+	return nil
+}
+
+// matches reports whether a request satisfies every condition in the rule
+func (rule *Rule) matches(r *http.Request) bool {
+	m := rule.Match
+	if m.Method != "" && !strings.EqualFold(m.Method, r.Method) {
+		return false
+	}
+	if m.Path != "" {
+		ok, _ := path.Match(m.Path, r.URL.Path)
+		if !ok {
+			return false
+		}
+	}
+	for name, value := range m.Headers {
+		if r.Header.Get(name) != value {
+			return false
+		}
+	}
+	return true
+}
+
+// loadRulesFile reads a JSON array of rules from disk
+func loadRulesFile(filename string) error {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return err
+	}
+
+	var fileRules []*Rule
+	if err := json.Unmarshal(data, &fileRules); err != nil {
+		return fmt.Errorf("invalid rules file: %w", err)
+	}
+	return rules.replace(fileRules)
+}
+
+// rulesMiddleware evaluates rules before the normal handlers run.
+//
+// Unlike loggingMiddleware (which wraps one route), this wraps the whole
+// router so rules can also mock paths that don't exist in the app at all.
+// Admin routes are never matched — otherwise a bad rule could lock you
+// out of the API you'd use to remove it.
+func rulesMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasPrefix(r.URL.Path, "/api/admin/") {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		rule := rules.find(r)
+		if rule == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		resp := rule.Response
+
+		// Inject delay, giving up early if the client disconnects
+		if resp.DelayMs > 0 {
+			select {
+			case <-time.After(time.Duration(resp.DelayMs) * time.Millisecond):
+			case <-r.Context().Done():
+				return
+			}
+		}
+
+		for name, value := range resp.Headers {
+			w.Header().Set(name, value)
+		}
+
+		// Delay/header-only rules fall through to the real handler
+		if resp.Status == 0 && rule.bodyTmpl == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		status := resp.Status
+		if status == 0 {
+			status = http.StatusOK
+		}
+
+		// Render the body template into a buffer first, so a template error
+		// can still become a clean 500 instead of a half-written response
+		var body bytes.Buffer
+		if rule.bodyTmpl != nil {
+			if err := rule.bodyTmpl.Execute(&body, newRuleTemplateData(r)); err != nil {
+				logHandlerError(r.Context(), "rules", "template", "failed to render rule body", "rule", rule.ID, "error", err)
+				http.Error(w, `{"error":"rule template error"}`, http.StatusInternalServerError)
+				return
+			}
+		}
+
+		if w.Header().Get("Content-Type") == "" {
+			w.Header().Set("Content-Type", "application/json")
+		}
+
+		slog.InfoContext(r.Context(), "rule matched",
+			"rule", rule.ID,
+			"method", r.Method,
+			"path", r.URL.Path,
+			"status", status,
+		)
+
+		w.WriteHeader(status)
+		w.Write(body.Bytes())
+	})
+}
+
+// newRuleTemplateData flattens the request into simple maps for templates
+func newRuleTemplateData(r *http.Request) ruleTemplateData {
+	data := ruleTemplateData{
+		Method:  r.Method,
+		Path:    r.URL.Path,
+		Query:   make(map[string]string),
+		Headers: getRequestHeaders(r),
+	}
+	for key := range r.URL.Query() {
+		data.Query[key] = r.URL.Query().Get(key)
+	}
+	return data
+}
+
+// =============================================================================
+// Admin API: /api/admin/rules
+// =============================================================================
+
+// rulesAdminHandler manages rules at runtime
+//
+//	GET    /api/admin/rules       -> list rules
+//	POST   /api/admin/rules       -> add (or replace by id) one rule
+//	PUT    /api/admin/rules       -> replace the whole rule set
+//	DELETE /api/admin/rules/:id   -> remove one rule
+func rulesAdminHandler(w http.ResponseWriter, r *http.Request) {
+	id := strings.TrimPrefix(r.URL.Path, "/api/admin/rules")
+	id = strings.TrimPrefix(id, "/")
+
+	w.Header().Set("Content-Type", "application/json")
+
+	if id != "" {
+		if r.Method != http.MethodDelete {
+			http.Error(w, `{"error":"method not allowed"}`, http.StatusMethodNotAllowed)
+			return
+		}
+		if !rules.remove(id) {
+			http.Error(w, `{"error":"not found"}`, http.StatusNotFound)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		json.NewEncoder(w).Encode(rules.list())
+
+	case http.MethodPost:
+		var rule Rule
+		if err := json.NewDecoder(r.Body).Decode(&rule); err != nil {
+			http.Error(w, `{"error":"invalid json"}`, http.StatusBadRequest)
+			return
+		}
+		if err := rules.add(&rule); err != nil {
+			writeJSONError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(rule)
+
+	case http.MethodPut:
+		var newRules []*Rule
+		if err := json.NewDecoder(r.Body).Decode(&newRules); err != nil {
+			http.Error(w, `{"error":"invalid json"}`, http.StatusBadRequest)
+			return
+		}
+		if err := rules.replace(newRules); err != nil {
+			writeJSONError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		json.NewEncoder(w).Encode(rules.list())
+
+	default:
+		http.Error(w, `{"error":"method not allowed"}`, http.StatusMethodNotAllowed)
+	}
+}
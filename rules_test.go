@@ -0,0 +1,105 @@
+package main
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// resetRules clears the rule store between tests
+func resetRules() {
+	rules.replace(nil)
+}
+
+func TestRules_ShortCircuitWithTemplate(t *testing.T) {
+	resetRules()
+	defer resetRules()
+
+	err := rules.add(&Rule{
+		ID:       "mock",
+		Match:    RuleMatch{Method: "GET", Path: "/mock/*"},
+		Response: RuleResponse{Status: 418, Body: `{"path":"{{.Path}}","q":"{{.Query.x}}"}`},
+	})
+	if err != nil {
+		t.Fatalf("failed to add rule: %v", err)
+	}
+
+	// The wrapped handler should never run when a rule short-circuits
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("expected rule to short-circuit the handler")
+	})
+
+	req := httptest.NewRequest("GET", "/mock/thing?x=1", nil)
+	rr := httptest.NewRecorder()
+	rulesMiddleware(next).ServeHTTP(rr, req)
+
+	if rr.Code != 418 {
+		t.Errorf("expected status 418, got %d", rr.Code)
+	}
+	if rr.Body.String() != `{"path":"/mock/thing","q":"1"}` {
+		t.Errorf("unexpected body: %s", rr.Body.String())
+	}
+}
+
+func TestRules_HeaderOnlyFallsThrough(t *testing.T) {
+	resetRules()
+	defer resetRules()
+
+	rules.add(&Rule{
+		ID:       "tag",
+		Match:    RuleMatch{Headers: map[string]string{"X-Canary": "true"}},
+		Response: RuleResponse{Headers: map[string]string{"X-Rule": "tag"}},
+	})
+
+	called := false
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		called = true
+	})
+
+	req := httptest.NewRequest("GET", "/health", nil)
+	req.Header.Set("X-Canary", "true")
+	rr := httptest.NewRecorder()
+	rulesMiddleware(next).ServeHTTP(rr, req)
+
+	if !called {
+		t.Error("expected header-only rule to fall through to the handler")
+	}
+	if rr.Header().Get("X-Rule") != "tag" {
+		t.Errorf("expected X-Rule header, got '%s'", rr.Header().Get("X-Rule"))
+	}
+}
+
+func TestRulesAdmin_RejectsInvalidRule(t *testing.T) {
+	resetRules()
+	defer resetRules()
+
+	body := bytes.NewBufferString(`{"id":"bad","response":{"status":42}}`)
+	req := httptest.NewRequest("POST", "/api/admin/rules", body)
+	rr := httptest.NewRecorder()
+	rulesAdminHandler(rr, req)
+
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("expected status 400, got %d", rr.Code)
+	}
+}
+
+func TestRules_RejectsNullEntries(t *testing.T) {
+	resetRules()
+	defer resetRules()
+
+	req := httptest.NewRequest("PUT", "/api/admin/rules", bytes.NewBufferString(`[null]`))
+	rr := httptest.NewRecorder()
+	rulesAdminHandler(rr, req)
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("PUT [null]: expected status 400, got %d", rr.Code)
+	}
+
+	file := filepath.Join(t.TempDir(), "rules.json")
+	os.WriteFile(file, []byte(`[{"id":"ok","match":{"path":"/x"}}, null]`), 0o644)
+	if err := loadRulesFile(file); err == nil {
+		t.Error("expected a RULES_FILE with a null entry to fail to load")
+	}
+}
@@ -0,0 +1,303 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+// =============================================================================
+// S3-Compatible Object Storage
+// =============================================================================
+//
+// Demo clusters get torn down; the data people built up for a demo shouldn't
+// have to go with them. With S3_BUCKET set, backups and item exports can be
+// pushed to any S3-compatible store — AWS S3, MinIO, Cloudflare R2, Ceph —
+// and restored from there into a fresh environment (see backup.go).
+//
+// This is a deliberately tiny client: PUT, GET, and list, signed with AWS
+// Signature Version 4. The official SDK would add dozens of dependencies
+// for three HTTP calls (AGENTS.md: prefer the standard library).
+//
+// Requests use path-style URLs (https://endpoint/bucket/key), which MinIO
+// needs and AWS still accepts.
+
+// s3Client talks to one bucket
+type s3Client struct {
+	endpoint  string // e.g. "https://s3.us-east-1.amazonaws.com" or "http://minio:9000"
+	bucket    string
+	region    string
+	accessKey string
+	secretKey string
+	prefix    string // prepended to every object key, e.g. "demo-app/"
+
+	httpClient *http.Client
+
+	// now is time.Now, replaceable in tests to check signatures
+	now func() time.Time
+}
+
+// Active client, nil unless S3_BUCKET is set
+var objectStore *s3Client
+
+// newS3Client creates a client. An empty endpoint means AWS in region.
+func newS3Client(endpoint, bucket, region, accessKey, secretKey, prefix string) (*s3Client, error) {
+	if bucket == "" {
+		return nil, fmt.Errorf("S3_BUCKET is required")
+	}
+	if accessKey == "" || secretKey == "" {
+		return nil, fmt.Errorf("S3_ACCESS_KEY_ID and S3_SECRET_ACCESS_KEY are required")
+	}
+	if endpoint == "" {
+		endpoint = "https://s3." + region + ".amazonaws.com"
+	}
+	if _, err := url.ParseRequestURI(endpoint); err != nil {
+		return nil, fmt.Errorf("invalid S3_ENDPOINT %q: %w", endpoint, err)
+	}
+	return &s3Client{
+		endpoint:   strings.TrimRight(endpoint, "/"),
+		bucket:     bucket,
+		region:     region,
+		accessKey:  accessKey,
+		secretKey:  secretKey,
+		prefix:     prefix,
+		httpClient: newOutboundClient(5 * time.Minute), // backups can be large; outbound.go
+		now:        time.Now,
+	}, nil
+}
+
+// s3Object is one entry in a bucket listing
+type s3Object struct {
+	Key          string    `json:"key" xml:"Key"`
+	Size         int64     `json:"size" xml:"Size"`
+	LastModified time.Time `json:"last_modified" xml:"LastModified"`
+}
+
+// putObject uploads body under key (the client prefix is added)
+func (c *s3Client) putObject(ctx context.Context, key string, body []byte, contentType string) error {
+	header := http.Header{}
+	header.Set("Content-Type", contentType)
+	resp, err := c.do(ctx, http.MethodPut, c.prefix+key, nil, header, body)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+// getObject downloads key (the client prefix is added)
+func (c *s3Client) getObject(ctx context.Context, key string) ([]byte, error) {
+	resp, err := c.do(ctx, http.MethodGet, c.prefix+key, nil, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	return io.ReadAll(resp.Body)
+}
+
+// listObjects returns the objects under the client prefix, with the prefix
+// stripped from their keys. Only the first page (up to 1000 keys) is read,
+// which is plenty for demo backups.
+func (c *s3Client) listObjects(ctx context.Context) ([]s3Object, error) {
+	query := url.Values{}
+	query.Set("list-type", "2")
+	query.Set("prefix", c.prefix)
+	resp, err := c.do(ctx, http.MethodGet, "", query, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	// ListObjectsV2 answers in XML; encoding/xml maps it like encoding/json does
+	var result struct {
+		Contents []s3Object `xml:"Contents"`
+	}
+	if err := xml.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("decode bucket listing: %w", err)
+	}
+	for i := range result.Contents {
+		result.Contents[i].Key = strings.TrimPrefix(result.Contents[i].Key, c.prefix)
+	}
+	return result.Contents, nil
+}
+
+// do sends a signed request for key in the bucket. Non-2xx responses are
+// turned into errors carrying S3's error code (e.g. NoSuchKey, AccessDenied).
+func (c *s3Client) do(ctx context.Context, method, key string, query url.Values, header http.Header, body []byte) (*http.Response, error) {
+	u := c.endpoint + "/" + c.bucket
+	if key != "" {
+		u += "/" + s3EscapePath(key)
+	}
+	if len(query) > 0 {
+		u += "?" + s3CanonicalQuery(query)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, u, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	for name, values := range header {
+		req.Header[name] = values
+	}
+	c.sign(req, body)
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode >= 300 {
+		defer resp.Body.Close()
+		var s3Err struct {
+			Code    string `xml:"Code"`
+			Message string `xml:"Message"`
+		}
+		xml.NewDecoder(io.LimitReader(resp.Body, 64<<10)).Decode(&s3Err)
+		if s3Err.Code == "" {
+			s3Err.Code = http.StatusText(resp.StatusCode)
+		}
+		return nil, &s3Error{Status: resp.StatusCode, Code: s3Err.Code, Message: s3Err.Message}
+	}
+	return resp, nil
+}
+
+// s3Error is a non-2xx answer from the object store
+type s3Error struct {
+	Status  int
+	Code    string
+	Message string
+}
+
+func (e *s3Error) Error() string {
+	if e.Message == "" {
+		return fmt.Sprintf("s3: %d %s", e.Status, e.Code)
+	}
+	return fmt.Sprintf("s3: %d %s: %s", e.Status, e.Code, e.Message)
+}
+
+// =============================================================================
+// Signature Version 4
+// =============================================================================
+//
+// Every request carries an Authorization header proving we know the secret
+// key without sending it. The recipe (AWS docs: "Signature Version 4 signing
+// process"):
+//
+//  1. canonical request: method, path, query, headers, and payload hash in a
+//     fixed format, so both sides build byte-identical strings
+//  2. string to sign: the algorithm, timestamp, scope, and sha256(1)
+//  3. signing key: HMAC chain secret -> date -> region -> "s3" -> "aws4_request"
+//  4. signature: hex(HMAC(signing key, 2))
+//
+// Python's botocore does exactly the same thing in botocore/auth.py.
+
+// sign adds the x-amz-* and Authorization headers to req
+func (c *s3Client) sign(req *http.Request, body []byte) {
+	now := c.now().UTC()
+	amzDate := now.Format("20060102T150405Z")
+	date := now.Format("20060102")
+
+	payloadHash := sha256Hex(body)
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+
+	// Sign host plus every header set so far (lowercase, sorted)
+	headers := map[string]string{"host": req.URL.Host}
+	for name, values := range req.Header {
+		headers[strings.ToLower(name)] = strings.TrimSpace(strings.Join(values, ","))
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalPath := req.URL.EscapedPath()
+	if canonicalPath == "" {
+		canonicalPath = "/"
+	}
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		canonicalPath,
+		req.URL.RawQuery, // already canonical: built by s3CanonicalQuery
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	scope := date + "/" + c.region + "/s3/aws4_request"
+	stringToSign := strings.Join([]string{
+		"AWS4-HMAC-SHA256",
+		amzDate,
+		scope,
+		sha256Hex([]byte(canonicalRequest)),
+	}, "\n")
+
+	key := hmacSHA256([]byte("AWS4"+c.secretKey), date)
+	key = hmacSHA256(key, c.region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		c.accessKey, scope, signedHeaders, signature))
+}
+
+// s3EscapePath percent-encodes a key the way SigV4 expects: everything except
+// unreserved characters, with "/" kept as the separator
+func s3EscapePath(key string) string {
+	segments := strings.Split(key, "/")
+	for i, s := range segments {
+		segments[i] = s3Escape(s)
+	}
+	return strings.Join(segments, "/")
+}
+
+// s3CanonicalQuery encodes query parameters sorted by name, as SigV4 requires
+func s3CanonicalQuery(query url.Values) string {
+	names := make([]string, 0, len(query))
+	for name := range query {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var parts []string
+	for _, name := range names {
+		for _, value := range query[name] {
+			parts = append(parts, s3Escape(name)+"="+s3Escape(value))
+		}
+	}
+	return strings.Join(parts, "&")
+}
+
+// s3Escape is RFC 3986 encoding. url.QueryEscape is close but turns spaces
+// into "+", which breaks the signature.
+func s3Escape(s string) string {
+	return strings.ReplaceAll(url.QueryEscape(s), "+", "%20")
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
@@ -0,0 +1,273 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+)
+
+// =============================================================================
+// Scheduled Tasks
+// =============================================================================
+//
+// Long-running demo environments go stale: the table empties out, the
+// display panel shows yesterday's data. Rather than needing an external cron,
+// demo-app can run a few built-in tasks on an interval.
+//
+// Schedules are defined as JSON, either inline (SCHEDULES) or in a file
+// (SCHEDULES_FILE):
+//
+//	[
+//	  {"name": "populate", "task": "create_items",    "every": "5m", "params": {"count": 3}},
+//	  {"name": "refresh",  "task": "refresh_display", "every": "1m", "params": {"url": "https://..."}},
+//	  {"name": "cleanup",  "task": "prune_items",     "every": "1h", "params": {"older_than": "24h"}}
+//	]
+//
+// Each schedule runs in its own goroutine driven by a time.Ticker —
+// think of it as a tiny in-process cron with fixed intervals.
+
+// Shortest allowed interval — protects against "every": "1ms" typos
+const minScheduleInterval = time.Second
+
+// Schedule is one recurring task
+type Schedule struct {
+	Name   string          `json:"name"`
+	Task   string          `json:"task"`
+	Every  string          `json:"every"`
+	Params json.RawMessage `json:"params,omitempty"`
+
+	// Runtime state (reported by GET /api/admin/schedules)
+	NextRun    time.Time  `json:"next_run"`
+	LastRun    *time.Time `json:"last_run,omitempty"`
+	LastStatus string     `json:"last_status,omitempty"`
+	LastError  string     `json:"last_error,omitempty"`
+	LastResult string     `json:"last_result,omitempty"`
+	Runs       int        `json:"runs"`
+
+	interval time.Duration
+}
+
+// scheduledTask does the work for one task type and returns a short summary
+type scheduledTask func(params json.RawMessage) (string, error)
+
+// scheduledTasks maps the "task" field to its implementation
+var scheduledTasks = map[string]scheduledTask{
+	"create_items":    runCreateItemsTask,
+	"refresh_display": runRefreshDisplayTask,
+	"prune_items":     runPruneItemsTask,
+}
+
+// Active schedules; scheduleMu guards their runtime state
+var (
+	scheduleMu sync.Mutex
+	schedules  []*Schedule
+)
+
+// loadSchedules parses schedule definitions from SCHEDULES or SCHEDULES_FILE
+func loadSchedules() ([]*Schedule, error) {
+	data := []byte(os.Getenv("SCHEDULES"))
+	if filename := os.Getenv("SCHEDULES_FILE"); filename != "" {
+		var err error
+		data, err = os.ReadFile(filename)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if len(data) == 0 {
+		return nil, nil
+	}
+
+	var defs []*Schedule
+	if err := json.Unmarshal(data, &defs); err != nil {
+		return nil, fmt.Errorf("invalid schedules JSON: %w", err)
+	}
+
+	for _, s := range defs {
+		if _, ok := scheduledTasks[s.Task]; !ok {
+			return nil, fmt.Errorf("schedule %q: unknown task %q", s.Name, s.Task)
+		}
+		interval, err := time.ParseDuration(s.Every)
+		if err != nil {
+			return nil, fmt.Errorf("schedule %q: invalid interval %q", s.Name, s.Every)
+		}
+		if interval < minScheduleInterval {
+			return nil, fmt.Errorf("schedule %q: interval must be at least %s", s.Name, minScheduleInterval)
+		}
+		s.interval = interval
+	}
+	return defs, nil
+}
+
+// startScheduler launches one goroutine per schedule
+func startScheduler(defs []*Schedule) {
+	scheduleMu.Lock()
+	schedules = defs
+	for _, s := range defs {
+		s.NextRun = time.Now().Add(s.interval).UTC()
+	}
+	scheduleMu.Unlock()
+
+	for _, s := range defs {
+		go func(s *Schedule) {
+			// A Ticker sends on its channel C every interval, forever
+			ticker := time.NewTicker(s.interval)
+			defer ticker.Stop()
+			for range ticker.C {
+				runSchedule(s)
+			}
+		}(s)
+		slog.Info("schedule registered", "name", s.Name, "task", s.Task, "every", s.Every)
+	}
+}
+
+// runSchedule executes one schedule's task and records the outcome
+func runSchedule(s *Schedule) {
+	start := time.Now()
+	summary, err := scheduledTasks[s.Task](s.Params)
+
+	scheduleMu.Lock()
+	defer scheduleMu.Unlock()
+
+	now := start.UTC()
+	s.LastRun = &now
+	s.NextRun = start.Add(s.interval).UTC()
+	s.Runs++
+	s.LastResult = summary
+	if err != nil {
+		s.LastStatus = "failed"
+		s.LastError = err.Error()
+		slog.Error("scheduled task failed", "name", s.Name, "task", s.Task, "error", err)
+		return
+	}
+	s.LastStatus = "ok"
+	s.LastError = ""
+	slog.Info("scheduled task ran", "name", s.Name, "task", s.Task,
+		"result", summary, "duration_ms", time.Since(start).Milliseconds())
+}
+
+// =============================================================================
+// Task Implementations
+// =============================================================================
+
+// runCreateItemsTask creates {"count": N} items (default 1)
+func runCreateItemsTask(params json.RawMessage) (string, error) {
+	p := struct {
+		Count  int    `json:"count"`
+		Prefix string `json:"prefix"`
+	}{Count: 1, Prefix: "Scheduled item"}
+	if len(params) > 0 {
+		if err := json.Unmarshal(params, &p); err != nil {
+			return "", fmt.Errorf("invalid params: %w", err)
+		}
+	}
+
+	created := 0
+	for i := 0; i < p.Count; i++ {
+		input := itemInput{
+			Name:        fmt.Sprintf("%s %s-%d", p.Prefix, time.Now().UTC().Format("20060102-150405"), i+1),
+			Description: "Created by the scheduler",
+			Tags:        []string{"scheduled"},
+		}
+		if _, _, err := insertItem(input, ""); err != nil {
+			return fmt.Sprintf("created %d", created), err
+		}
+		created++
+	}
+	return fmt.Sprintf("created %d", created), nil
+}
+
+// runRefreshDisplayTask fetches JSON from {"url": "..."} into the display panel
+func runRefreshDisplayTask(params json.RawMessage) (string, error) {
+	var p struct {
+		URL string `json:"url"`
+	}
+	if err := json.Unmarshal(params, &p); err != nil || p.URL == "" {
+		return "", errors.New("params.url is required")
+	}
+
+	client := newOutboundClient(10 * time.Second)
+	resp, err := client.Get(p.URL)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return "", fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+
+	// Cap the body at 1MB so a huge response can't exhaust memory
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return "", err
+	}
+	if !json.Valid(body) {
+		return "", errors.New("response is not valid JSON")
+	}
+
+	setDisplayData(body)
+	displayUpdatesTotal.Inc()
+	publishDisplayEvent(body)
+	return fmt.Sprintf("display updated (%d bytes)", len(body)), nil
+}
+
+// runPruneItemsTask deletes items older than {"older_than": "24h"}
+func runPruneItemsTask(params json.RawMessage) (string, error) {
+	var p struct {
+		OlderThan string `json:"older_than"`
+	}
+	if err := json.Unmarshal(params, &p); err != nil || p.OlderThan == "" {
+		return "", errors.New("params.older_than is required")
+	}
+	maxAge, err := time.ParseDuration(p.OlderThan)
+	if err != nil {
+		return "", fmt.Errorf("invalid older_than: %w", err)
+	}
+
+	items, err := loadAllItems()
+	if err != nil {
+		return "", err
+	}
+
+	cutoff := time.Now().Add(-maxAge)
+	pruned := 0
+	for _, item := range items {
+		if item.CreatedAt.Before(cutoff) {
+			if err := removeItem(item.ID); err != nil {
+				return fmt.Sprintf("pruned %d", pruned), err
+			}
+			pruned++
+		}
+	}
+	return fmt.Sprintf("pruned %d", pruned), nil
+}
+
+// =============================================================================
+// Admin Endpoint
+// =============================================================================
+
+// schedulesAdminHandler lists schedules with their next/last run times (GET only)
+func schedulesAdminHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, `{"error":"method not allowed"}`, http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+
+	// Encode while holding the lock so we don't read half-updated state
+	scheduleMu.Lock()
+	defer scheduleMu.Unlock()
+
+	list := schedules
+	if list == nil {
+		list = []*Schedule{}
+	}
+	json.NewEncoder(w).Encode(list)
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestLoadSchedules_Validation(t *testing.T) {
+	t.Setenv("SCHEDULES", `[{"name":"x","task":"nope","every":"1m"}]`)
+	if _, err := loadSchedules(); err == nil {
+		t.Error("expected error for unknown task")
+	}
+
+	t.Setenv("SCHEDULES", `[{"name":"x","task":"create_items","every":"1ms"}]`)
+	if _, err := loadSchedules(); err == nil {
+		t.Error("expected error for too-short interval")
+	}
+
+	t.Setenv("SCHEDULES", `[{"name":"x","task":"create_items","every":"1m"}]`)
+	defs, err := loadSchedules()
+	if err != nil || len(defs) != 1 {
+		t.Fatalf("expected 1 valid schedule, got %d (%v)", len(defs), err)
+	}
+}
+
+func TestScheduledTasks_RefreshDisplay(t *testing.T) {
+	resetDisplayData()
+	defer resetDisplayData()
+
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"source":"upstream"}`))
+	}))
+	defer upstream.Close()
+
+	params, _ := json.Marshal(map[string]string{"url": upstream.URL})
+	if _, err := runRefreshDisplayTask(params); err != nil {
+		t.Fatalf("refresh failed: %v", err)
+	}
+	if string(getDisplayData()) != `{"source":"upstream"}` {
+		t.Errorf("unexpected display data: %s", getDisplayData())
+	}
+}
+
+func TestScheduledTasks_PruneItems(t *testing.T) {
+	old, _, err := insertItem(itemInput{Name: "Prune Me"}, "")
+	if err != nil {
+		t.Fatalf("failed to create item: %v", err)
+	}
+
+	// A negative age puts the cutoff in the future, so every item qualifies
+	if _, err := runPruneItemsTask(json.RawMessage(`{"older_than":"-1h"}`)); err != nil {
+		t.Fatalf("prune failed: %v", err)
+	}
+
+	req := httptest.NewRequest("GET", fmt.Sprintf("/api/items/%d", old.ID), nil)
+	rr := httptest.NewRecorder()
+	itemsHandler(rr, req)
+	if rr.Code != http.StatusNotFound {
+		t.Errorf("expected pruned item to be gone, got %d", rr.Code)
+	}
+}
@@ -0,0 +1,72 @@
+// Writes a gzip-compressed copy next to every file in a directory, so the
+// server can send compressed static assets without compressing on each
+// request. HTML is skipped: the server rewrites it at startup and compresses
+// the result itself. Run by "go generate" (see static.go):
+//
+//	go generate
+//	go run ./scripts/gzipstatic static
+//
+// Output is deterministic (no file name or timestamp in the gzip header),
+// so an unchanged asset leaves its .gz file untouched.
+
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+func main() {
+	if len(os.Args) != 2 {
+		fmt.Fprintln(os.Stderr, "usage: gzipstatic <dir>")
+		os.Exit(2)
+	}
+	dir := os.Args[1]
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	for _, entry := range entries {
+		if entry.IsDir() || strings.HasSuffix(entry.Name(), ".gz") || strings.HasSuffix(entry.Name(), ".html") {
+			continue
+		}
+		path := filepath.Join(dir, entry.Name())
+		changed, err := compressFile(path)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %v\n", path, err)
+			os.Exit(1)
+		}
+		if changed {
+			fmt.Printf("wrote %s.gz\n", path)
+		}
+	}
+}
+
+// compressFile writes path.gz; reports whether it changed
+func compressFile(path string) (bool, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return false, err
+	}
+
+	var buf bytes.Buffer
+	zw, err := gzip.NewWriterLevel(&buf, gzip.BestCompression)
+	if err != nil {
+		return false, err
+	}
+	zw.Write(data)
+	if err := zw.Close(); err != nil {
+		return false, err
+	}
+
+	if old, err := os.ReadFile(path + ".gz"); err == nil && bytes.Equal(old, buf.Bytes()) {
+		return false, nil
+	}
+	return true, os.WriteFile(path+".gz", buf.Bytes(), 0o644)
+}
@@ -0,0 +1,393 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"maps"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// =============================================================================
+// Secrets
+// =============================================================================
+//
+// Secrets don't have to be plain environment variables. The app can read
+// them from a mounted secrets file or HashiCorp Vault, and re-reads both
+// every SECRETS_REFRESH_INTERVAL, so a rotated secret is picked up without a
+// restart:
+//
+//	# A Kubernetes Secret volume (one file per key) or a NAME=value file
+//	SECRETS_FILE=/etc/demo-app/secrets ./demo-app
+//
+//	# A Vault KV secret (v1 or v2)
+//	VAULT_ADDR=https://vault:8200 VAULT_TOKEN=hvs.xxx \
+//	VAULT_SECRET_PATH=secret/data/demo-app ./demo-app
+//
+// A secret is looked up by its usual variable name: ADMIN_TOKEN,
+// LOG_WEBHOOK_TOKEN, DB_ENCRYPTION_KEY, TLS_CERT and TLS_KEY (PEM), access
+// policy keys (key_secret), and the other credentials listed in
+// docs/CONFIGURATION.md. Vault wins over the file, and the file over the
+// environment, so env vars still work for everything.
+//
+// What a rotation changes right away: ADMIN_TOKEN (checked per request),
+// LOG_WEBHOOK_TOKEN (per webhook call), access policy keys, and TLS_CERT /
+// TLS_KEY (per TLS handshake). Everything else is read at startup, like
+// DB_ENCRYPTION_KEY, which BadgerDB can only take when it opens.
+//
+// If a source can't be read at startup the app exits; later failures keep
+// the last values from that source and are logged. GET /api/admin/secrets
+// lists the sources and the names they provide, never the values.
+
+// secretSource is somewhere secrets are read from
+type secretSource interface {
+	name() string     // "file" or "vault"
+	location() string // path or Vault URL, for the admin API
+	fetch() (map[string]string, error)
+}
+
+// secretSourceState is a source and what it gave last time
+type secretSourceState struct {
+	source      secretSource
+	values      map[string]string
+	lastRefresh time.Time
+	lastError   string
+}
+
+// secretStore holds the values from every source
+type secretStore struct {
+	mu       sync.RWMutex
+	sources  []*secretSourceState // lowest precedence first
+	merged   map[string]string
+	interval time.Duration
+}
+
+// Active secret sources; empty unless SECRETS_FILE or VAULT_ADDR is set
+var secrets = &secretStore{merged: map[string]string{}}
+
+// secret returns the named secret: from a secret source when one has it,
+// otherwise the environment variable of the same name
+func secret(name string) string {
+	if value, ok := secrets.lookup(name); ok {
+		return value
+	}
+	return os.Getenv(name)
+}
+
+// lookup returns a secret from the sources only
+func (s *secretStore) lookup(name string) (string, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	value, ok := s.merged[name]
+	return value, ok
+}
+
+// configureSecrets sets up the sources from SECRETS_FILE and VAULT_* and
+// reads them once; an error means a source couldn't be read
+func configureSecrets() error {
+	var sources []secretSource
+	if path := os.Getenv("SECRETS_FILE"); path != "" {
+		sources = append(sources, &fileSecretSource{path: path})
+	}
+	if addr := os.Getenv("VAULT_ADDR"); addr != "" {
+		vault, err := newVaultSecretSource(addr, os.Getenv("VAULT_SECRET_PATH"),
+			os.Getenv("VAULT_TOKEN"), os.Getenv("VAULT_TOKEN_FILE"))
+		if err != nil {
+			return err
+		}
+		sources = append(sources, vault)
+	}
+	return secrets.configure(sources, envDuration("SECRETS_REFRESH_INTERVAL", time.Minute))
+}
+
+// configure replaces the sources and reads each one
+func (s *secretStore) configure(sources []secretSource, interval time.Duration) error {
+	states := make([]*secretSourceState, 0, len(sources))
+	for _, source := range sources {
+		values, err := source.fetch()
+		secretRefreshesTotal.WithLabelValues(source.name(), refreshResult(err)).Inc()
+		if err != nil {
+			return fmt.Errorf("secrets from %s (%s): %w", source.name(), source.location(), err)
+		}
+		states = append(states, &secretSourceState{source: source, values: values, lastRefresh: time.Now().UTC()})
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sources, s.interval = states, interval
+	s.merged = mergeSecrets(states)
+	return nil
+}
+
+// refresh re-reads every source and logs the names whose values changed
+func (s *secretStore) refresh() {
+	s.mu.RLock()
+	states := s.sources
+	s.mu.RUnlock()
+
+	// Fetch without the lock, so lookups don't wait on Vault
+	fetched := make([]map[string]string, len(states))
+	errs := make([]error, len(states))
+	for i, state := range states {
+		fetched[i], errs[i] = state.source.fetch()
+		secretRefreshesTotal.WithLabelValues(state.source.name(), refreshResult(errs[i])).Inc()
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i, state := range states {
+		if errs[i] != nil {
+			// Keep the last good values; a Vault blip shouldn't lock everyone out
+			state.lastError = errs[i].Error()
+			slog.Warn("failed to refresh secrets", "source", state.source.name(), "error", errs[i])
+			continue
+		}
+		state.values, state.lastRefresh, state.lastError = fetched[i], time.Now().UTC(), ""
+	}
+
+	merged := mergeSecrets(s.sources)
+	var changed []string
+	for name := range merged {
+		if old, ok := s.merged[name]; !ok || old != merged[name] {
+			changed = append(changed, name)
+		}
+	}
+	for name := range s.merged {
+		if _, ok := merged[name]; !ok {
+			changed = append(changed, name)
+		}
+	}
+	s.merged = merged
+	if len(changed) > 0 {
+		slices.Sort(changed)
+		slog.Info("secrets changed", "names", changed)
+	}
+}
+
+// start re-reads the sources every interval, for as long as the app runs
+func (s *secretStore) start() {
+	if len(s.sources) == 0 || s.interval <= 0 {
+		return
+	}
+	go func() {
+		ticker := time.NewTicker(s.interval)
+		defer ticker.Stop()
+		for range ticker.C {
+			s.refresh()
+		}
+	}()
+}
+
+// mergeSecrets layers the sources, later ones winning
+func mergeSecrets(states []*secretSourceState) map[string]string {
+	merged := map[string]string{}
+	for _, state := range states {
+		maps.Copy(merged, state.values)
+	}
+	return merged
+}
+
+// refreshResult is the result label for demoapp_secret_refreshes_total
+func refreshResult(err error) string {
+	if err != nil {
+		return "error"
+	}
+	return "ok"
+}
+
+// =============================================================================
+// Sources
+// =============================================================================
+
+// fileSecretSource reads SECRETS_FILE: a directory with one file per
+// secret (how Kubernetes mounts a Secret), or a file of NAME=value lines
+type fileSecretSource struct {
+	path string
+}
+
+func (f *fileSecretSource) name() string     { return "file" }
+func (f *fileSecretSource) location() string { return f.path }
+
+func (f *fileSecretSource) fetch() (map[string]string, error) {
+	info, err := os.Stat(f.path)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		data, err := os.ReadFile(f.path)
+		if err != nil {
+			return nil, err
+		}
+		return parseSecretsFile(data)
+	}
+
+	entries, err := os.ReadDir(f.path)
+	if err != nil {
+		return nil, err
+	}
+	values := map[string]string{}
+	for _, entry := range entries {
+		// Kubernetes keeps the real files in "..data" and links to them;
+		// hidden entries are its bookkeeping
+		if strings.HasPrefix(entry.Name(), ".") {
+			continue
+		}
+		path := filepath.Join(f.path, entry.Name())
+		if info, err := os.Stat(path); err != nil || !info.Mode().IsRegular() {
+			continue
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		values[entry.Name()] = strings.TrimRight(string(data), "\r\n")
+	}
+	return values, nil
+}
+
+// parseSecretsFile reads NAME=value lines; blank lines and # comments are
+// skipped, and quotes around a value are removed
+func parseSecretsFile(data []byte) (map[string]string, error) {
+	values := map[string]string{}
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		name, value, ok := strings.Cut(strings.TrimPrefix(line, "export "), "=")
+		name = strings.TrimSpace(name)
+		if !ok || name == "" {
+			return nil, fmt.Errorf("line %d: want NAME=value", n)
+		}
+		value = strings.TrimSpace(value)
+		if len(value) >= 2 && (value[0] == '"' || value[0] == '\'') && value[len(value)-1] == value[0] {
+			value = value[1 : len(value)-1]
+		}
+		values[name] = value
+	}
+	return values, scanner.Err()
+}
+
+// vaultSecretSource reads one secret from Vault's KV engine over its HTTP API
+type vaultSecretSource struct {
+	url       string // full URL of the secret
+	token     string
+	tokenFile string // re-read on every fetch (e.g. a Vault Agent sink)
+	client    *http.Client
+}
+
+// newVaultSecretSource checks VAULT_ADDR and VAULT_SECRET_PATH and needs a
+// token or a token file
+func newVaultSecretSource(addr, secretPath, token, tokenFile string) (*vaultSecretSource, error) {
+	switch {
+	case secretPath == "":
+		return nil, fmt.Errorf("VAULT_ADDR is set but VAULT_SECRET_PATH is not (e.g. secret/data/demo-app)")
+	case token == "" && tokenFile == "":
+		return nil, fmt.Errorf("VAULT_ADDR is set but neither VAULT_TOKEN nor VAULT_TOKEN_FILE is")
+	}
+	return &vaultSecretSource{
+		url:       strings.TrimSuffix(addr, "/") + "/v1/" + strings.TrimPrefix(secretPath, "/"),
+		token:     token,
+		tokenFile: tokenFile,
+		client:    newOutboundClient(5 * time.Second),
+	}, nil
+}
+
+func (v *vaultSecretSource) name() string     { return "vault" }
+func (v *vaultSecretSource) location() string { return v.url }
+
+func (v *vaultSecretSource) fetch() (map[string]string, error) {
+	token := v.token
+	if v.tokenFile != "" {
+		data, err := os.ReadFile(v.tokenFile)
+		if err != nil {
+			return nil, fmt.Errorf("VAULT_TOKEN_FILE: %w", err)
+		}
+		token = strings.TrimSpace(string(data))
+	}
+
+	req, err := http.NewRequest(http.MethodGet, v.url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("X-Vault-Token", token)
+	resp, err := v.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("vault returned %d", resp.StatusCode)
+	}
+
+	// KV v1: {"data":{"ADMIN_TOKEN":"..."}}
+	// KV v2: {"data":{"data":{"ADMIN_TOKEN":"..."},"metadata":{...}}}
+	var body struct {
+		Data map[string]any `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return nil, fmt.Errorf("vault response: %w", err)
+	}
+	data := body.Data
+	if inner, ok := data["data"].(map[string]any); ok && data["metadata"] != nil {
+		data = inner
+	}
+	values := make(map[string]string, len(data))
+	for name, value := range data {
+		if s, ok := value.(string); ok {
+			values[name] = s
+			continue
+		}
+		encoded, _ := json.Marshal(value)
+		values[name] = string(encoded)
+	}
+	return values, nil
+}
+
+// =============================================================================
+// Admin API
+// =============================================================================
+
+// SecretSourceStatus is one source in GET /api/admin/secrets
+type SecretSourceStatus struct {
+	Source      string    `json:"source"`
+	Location    string    `json:"location"`
+	Names       []string  `json:"names"` // never the values
+	LastRefresh time.Time `json:"last_refresh"`
+	LastError   string    `json:"last_error,omitempty"`
+}
+
+// secretsAdminHandler handles GET /api/admin/secrets
+func secretsAdminHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	if r.Method != http.MethodGet {
+		http.Error(w, `{"error":"method not allowed"}`, http.StatusMethodNotAllowed)
+		return
+	}
+
+	secrets.mu.RLock()
+	defer secrets.mu.RUnlock()
+	sources := make([]SecretSourceStatus, 0, len(secrets.sources))
+	for _, state := range secrets.sources {
+		sources = append(sources, SecretSourceStatus{
+			Source:      state.source.name(),
+			Location:    state.source.location(),
+			Names:       slices.Sorted(maps.Keys(state.values)),
+			LastRefresh: state.lastRefresh,
+			LastError:   state.lastError,
+		})
+	}
+	json.NewEncoder(w).Encode(map[string]any{
+		"sources":          sources,
+		"refresh_interval": secrets.interval.String(),
+	})
+}
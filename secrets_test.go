@@ -0,0 +1,137 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// withSecrets reads secrets from sources for one test
+func withSecrets(t *testing.T, sources ...secretSource) {
+	t.Helper()
+	previous := secrets
+	secrets = &secretStore{merged: map[string]string{}}
+	t.Cleanup(func() { secrets = previous })
+	if err := secrets.configure(sources, time.Minute); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// fakeVault serves one KV v2 secret and counts the tokens it saw
+func fakeVault(t *testing.T, data *string) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/secret/data/demo-app" || r.Header.Get("X-Vault-Token") != "hvs.test" {
+			http.Error(w, `{"errors":["permission denied"]}`, http.StatusForbidden)
+			return
+		}
+		w.Write([]byte(`{"data":{"data":` + *data + `,"metadata":{"version":3}}}`))
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestSecrets_Precedence(t *testing.T) {
+	t.Setenv("ADMIN_TOKEN", "from-env")
+	t.Setenv("LOG_WEBHOOK_TOKEN", "from-env")
+	t.Setenv("DISPLAY_SOURCE_TOKEN", "from-env")
+
+	// A Kubernetes Secret volume: files are links into a hidden data directory
+	dir := t.TempDir()
+	os.MkdirAll(filepath.Join(dir, "..data"), 0o755)
+	os.WriteFile(filepath.Join(dir, "..data", "LOG_WEBHOOK_TOKEN"), []byte("from-file\n"), 0o600)
+	os.WriteFile(filepath.Join(dir, "..data", "ADMIN_TOKEN"), []byte("from-file\n"), 0o600)
+	os.Symlink(filepath.Join("..data", "LOG_WEBHOOK_TOKEN"), filepath.Join(dir, "LOG_WEBHOOK_TOKEN"))
+	os.Symlink(filepath.Join("..data", "ADMIN_TOKEN"), filepath.Join(dir, "ADMIN_TOKEN"))
+
+	data := `{"ADMIN_TOKEN":"from-vault","PORT":8080}`
+	vault, err := newVaultSecretSource(fakeVault(t, &data).URL, "secret/data/demo-app", "hvs.test", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	withSecrets(t, &fileSecretSource{path: dir}, vault)
+
+	for name, want := range map[string]string{
+		"ADMIN_TOKEN":          "from-vault",
+		"LOG_WEBHOOK_TOKEN":    "from-file",
+		"DISPLAY_SOURCE_TOKEN": "from-env",
+		"PORT":                 "8080", // non-strings as JSON
+	} {
+		if got := secret(name); got != want {
+			t.Errorf("secret(%s) = %q, want %q", name, got, want)
+		}
+	}
+}
+
+func TestSecrets_Refresh(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "secrets.env")
+	os.WriteFile(file, []byte("# rotated by ops\nADMIN_TOKEN=\"first\"\nexport API_KEY=abc\n"), 0o600)
+	withSecrets(t, &fileSecretSource{path: file})
+
+	srv := newTestServer(t)
+	call := func(token string) int {
+		req, _ := http.NewRequest(http.MethodGet, srv.URL+"/api/admin/secrets", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+	if code := call("first"); code != http.StatusOK {
+		t.Fatalf("ADMIN_TOKEN from SECRETS_FILE: %d", code)
+	}
+
+	// A rotated token works after the next refresh, without a restart
+	os.WriteFile(file, []byte("ADMIN_TOKEN=second\n"), 0o600)
+	secrets.refresh()
+	if call("first") != http.StatusUnauthorized || call("second") != http.StatusOK {
+		t.Error("rotated ADMIN_TOKEN wasn't picked up")
+	}
+	if _, ok := secrets.lookup("API_KEY"); ok {
+		t.Error("a secret removed from the file is still there")
+	}
+
+	// A source that fails keeps its last values
+	os.Remove(file)
+	secrets.refresh()
+	if secret("ADMIN_TOKEN") != "second" || secrets.sources[0].lastError == "" {
+		t.Errorf("after a failed refresh: token %q, error %q", secret("ADMIN_TOKEN"), secrets.sources[0].lastError)
+	}
+}
+
+func TestSecrets_AdminHidesValues(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "secrets.env")
+	os.WriteFile(file, []byte("BILLING_API_KEY=s3cr3t\n"), 0o600)
+	withSecrets(t, &fileSecretSource{path: file})
+	srv := newTestServer(t)
+
+	code, body := doRequest(t, srv, http.MethodGet, "/api/admin/secrets", "")
+	if code != http.StatusOK || !strings.Contains(string(body), `"names":["BILLING_API_KEY"]`) || strings.Contains(string(body), "s3cr3t") {
+		t.Errorf("GET /api/admin/secrets = %d %s", code, body)
+	}
+}
+
+func TestSecrets_Invalid(t *testing.T) {
+	if _, err := parseSecretsFile([]byte("no equals sign")); err == nil {
+		t.Error("line without = parsed")
+	}
+	if _, err := newVaultSecretSource("http://vault:8200", "", "hvs.test", ""); err == nil {
+		t.Error("VAULT_ADDR without VAULT_SECRET_PATH accepted")
+	}
+	if _, err := newVaultSecretSource("http://vault:8200", "secret/data/x", "", ""); err == nil {
+		t.Error("VAULT_ADDR without a token accepted")
+	}
+
+	data := `{}`
+	vault, _ := newVaultSecretSource(fakeVault(t, &data).URL, "secret/data/demo-app", "wrong", "")
+	store := &secretStore{merged: map[string]string{}}
+	if err := store.configure([]secretSource{vault}, time.Minute); err == nil || !strings.Contains(err.Error(), "403") {
+		t.Errorf("a source failing at startup: %v", err)
+	}
+}
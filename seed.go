@@ -0,0 +1,128 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+
+	badger "github.com/dgraph-io/badger/v4"
+)
+
+// =============================================================================
+// Seed Data
+// =============================================================================
+//
+// A fresh deployment starts with an empty items table, which makes for a
+// dull first screen. If SEED_FILE or SEED_COUNT is set, startup fills the
+// store — but only when it's empty, so restarts with a persistent DB_PATH
+// don't pile up duplicates.
+//
+// SEED_FILE accepts either format:
+//   - a JSON array:  [{"name": "A"}, {"name": "B"}]
+//   - NDJSON (one JSON object per line), which is what most export tools emit
+
+// storeIsEmpty reports whether there are no items stored
+func storeIsEmpty() (bool, error) {
+	empty := true
+	err := dbView("item_exists", itemKeyPrefix, func(txn *badger.Txn) error {
+		opts := badger.DefaultIteratorOptions
+		// We only care whether a key exists, so skip loading values
+		opts.PrefetchValues = false
+		it := txn.NewIterator(opts)
+		defer it.Close()
+
+		prefix := []byte(itemKeyPrefix)
+		it.Seek(prefix)
+		empty = !it.ValidForPrefix(prefix)
+		return nil
+	})
+	return empty, err
+}
+
+// readSeedFile parses a JSON array or NDJSON file into item inputs
+func readSeedFile(filename string) ([]itemInput, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	// A JSON array starts with '[' (after any whitespace); otherwise assume NDJSON
+	trimmed := bytes.TrimSpace(data)
+	if len(trimmed) > 0 && trimmed[0] == '[' {
+		var inputs []itemInput
+		if err := json.Unmarshal(trimmed, &inputs); err != nil {
+			return nil, fmt.Errorf("invalid JSON array: %w", err)
+		}
+		return inputs, nil
+	}
+
+	var inputs []itemInput
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	line := 0
+	for scanner.Scan() {
+		line++
+		text := bytes.TrimSpace(scanner.Bytes())
+		if len(text) == 0 {
+			continue // allow blank lines
+		}
+		var input itemInput
+		if err := json.Unmarshal(text, &input); err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		inputs = append(inputs, input)
+	}
+	return inputs, scanner.Err()
+}
+
+// seedStore loads SEED_FILE and/or generates SEED_COUNT fake items if the store is empty
+func seedStore(seedFile string, seedCount int) error {
+	if seedFile == "" && seedCount <= 0 {
+		return nil
+	}
+
+	empty, err := storeIsEmpty()
+	if err != nil {
+		return err
+	}
+	if !empty {
+		slog.Info("store not empty, skipping seed data")
+		return nil
+	}
+
+	var inputs []itemInput
+	if seedFile != "" {
+		inputs, err = readSeedFile(seedFile)
+		if err != nil {
+			return fmt.Errorf("seed file %s: %w", seedFile, err)
+		}
+	}
+	for i := 0; i < seedCount; i++ {
+		inputs = append(inputs, fakeItemInput())
+	}
+
+	created, skipped := seedItems(inputs)
+	slog.Info("seed data loaded", "file", seedFile, "generated", seedCount, "created", created, "skipped", skipped)
+	return nil
+}
+
+// seedItems inserts inputs, skipping the ones that fail validation.
+// "demo-app seed" (cli.go) calls it directly to add to a store that isn't empty.
+func seedItems(inputs []itemInput) (created, skipped int) {
+	for _, input := range inputs {
+		// Seed data goes through the same rules as API requests
+		if input.Name == "" || len(itemValidation.validate(input.Name, input.Description, input.Tags)) > 0 {
+			skipped++
+			continue
+		}
+		if _, _, err := insertItem(input, ""); err != nil {
+			slog.Warn("failed to insert seed item", "name", input.Name, "error", err)
+			skipped++
+			continue
+		}
+		created++
+	}
+	return created, skipped
+}
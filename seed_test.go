@@ -0,0 +1,80 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestReadSeedFile_Formats(t *testing.T) {
+	dir := t.TempDir()
+
+	cases := map[string]string{
+		"array.json":   `[{"name":"A"},{"name":"B","tags":["x"]}]`,
+		"items.ndjson": "{\"name\":\"A\"}\n\n{\"name\":\"B\",\"tags\":[\"x\"]}\n",
+	}
+
+	for filename, content := range cases {
+		path := filepath.Join(dir, filename)
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+
+		inputs, err := readSeedFile(path)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", filename, err)
+		}
+		if len(inputs) != 2 || inputs[1].Name != "B" || len(inputs[1].Tags) != 1 {
+			t.Errorf("%s: unexpected inputs: %+v", filename, inputs)
+		}
+	}
+}
+
+func TestReadSeedFile_BadLine(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "bad.ndjson")
+	os.WriteFile(path, []byte("{\"name\":\"A\"}\nnot json\n"), 0o644)
+
+	if _, err := readSeedFile(path); err == nil {
+		t.Error("expected error for malformed NDJSON line")
+	}
+}
+
+func TestFakeItemInput_LooksValid(t *testing.T) {
+	for i := 0; i < 50; i++ {
+		input := fakeItemInput()
+		if input.Name == "" || input.Description == "" {
+			t.Fatalf("expected name and description, got %+v", input)
+		}
+	}
+}
+
+func TestGenerateAdmin_CreatesItems(t *testing.T) {
+	req := httptest.NewRequest("POST", "/api/admin/generate?count=25&spread=1h", nil)
+	rr := httptest.NewRecorder()
+	generateAdminHandler(rr, req)
+
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("expected status 201, got %d: %s", rr.Code, rr.Body.String())
+	}
+
+	var result map[string]any
+	json.Unmarshal(rr.Body.Bytes(), &result)
+	if result["created"] != float64(25) {
+		t.Errorf("expected 25 created, got %v", result["created"])
+	}
+}
+
+func TestGenerateAdmin_RejectsBadCount(t *testing.T) {
+	for _, count := range []string{"0", "abc", "10001"} {
+		req := httptest.NewRequest("POST", "/api/admin/generate?count="+count, nil)
+		rr := httptest.NewRecorder()
+		generateAdminHandler(rr, req)
+
+		if rr.Code != http.StatusBadRequest {
+			t.Errorf("count=%s: expected status 400, got %d", count, rr.Code)
+		}
+	}
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync/atomic"
+	"time"
+)
+
+// =============================================================================
+// Calls Back to This Server
+// =============================================================================
+//
+// Load generation jobs (jobs.go), the queue consumer (queueconsumer.go), and
+// replay without a target (recorder.go) send requests to this same server.
+// They don't go over the network: with TLS on (tls.go) the listener only
+// speaks TLS and usually requires a client certificate the app doesn't have.
+// Instead their client hands each request to the router in-process, so it
+// still passes through every middleware, log line, and metric on the way.
+//
+// jobSelfURL (http://localhost:<port>) is still the base URL; only its path
+// and query reach the router. Before main has built the router, and in tests
+// (which serve the routes on their own httptest.Server), requests do go over
+// the network to the URL.
+
+// The router, set by main once it's built
+var selfHandler atomic.Pointer[http.Handler]
+
+// Where in-process requests appear to come from
+const selfRemoteAddr = "127.0.0.1:0"
+
+// newSelfClient returns a client for requests to jobSelfURL
+func newSelfClient(timeout time.Duration) *http.Client {
+	return &http.Client{Timeout: timeout, Transport: selfTransport{}}
+}
+
+// selfTransport is an http.RoundTripper that serves requests with the router
+type selfTransport struct{}
+
+// RoundTrip implements http.RoundTripper
+func (selfTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	handler := selfHandler.Load()
+	if handler == nil {
+		return outboundTransport.RoundTrip(req)
+	}
+
+	// The request as the server would have read it
+	in := req.Clone(req.Context())
+	in.URL = &url.URL{Path: req.URL.Path, RawPath: req.URL.RawPath, RawQuery: req.URL.RawQuery}
+	in.RequestURI = in.URL.RequestURI()
+	in.Host = req.URL.Host
+	in.RemoteAddr = selfRemoteAddr
+	in.Proto, in.ProtoMajor, in.ProtoMinor = "HTTP/1.1", 1, 1
+	if in.Body == nil {
+		in.Body = http.NoBody
+	}
+
+	w := &selfResponseWriter{header: http.Header{}}
+	(*handler).ServeHTTP(w, in)
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	return &http.Response{
+		Status:        strconv.Itoa(w.status) + " " + http.StatusText(w.status),
+		StatusCode:    w.status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        w.header,
+		Body:          io.NopCloser(&w.body),
+		ContentLength: int64(w.body.Len()),
+		Request:       req,
+	}, nil
+}
+
+// selfResponseWriter keeps the router's response for selfTransport
+type selfResponseWriter struct {
+	header http.Header
+	status int
+	body   bytes.Buffer
+}
+
+func (w *selfResponseWriter) Header() http.Header { return w.header }
+
+func (w *selfResponseWriter) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+}
+
+func (w *selfResponseWriter) Write(p []byte) (int, error) {
+	w.WriteHeader(http.StatusOK)
+	return w.body.Write(p)
+}
+
+// Flush is a no-op; streaming handlers get the whole body when they return
+func (w *selfResponseWriter) Flush() {}
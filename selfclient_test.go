@@ -0,0 +1,63 @@
+package main
+
+import (
+	"crypto/tls"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// withSelfHandler serves calls back to this server with h, as main does
+// with the router
+func withSelfHandler(t *testing.T, h http.Handler) {
+	t.Helper()
+	prev := selfHandler.Load()
+	selfHandler.Store(&h)
+	t.Cleanup(func() { selfHandler.Store(prev) })
+}
+
+// mutualTLSServer is a listener like TLS_CLIENT_CA_FILE makes: TLS only,
+// and no way in without a client certificate
+func mutualTLSServer(t *testing.T) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Errorf("self-call went over the network: %s %s", r.Method, r.URL)
+	}))
+	srv.TLS = &tls.Config{ClientAuth: tls.RequireAnyClientCert}
+	srv.StartTLS()
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestSelfClient_QueueConsumerWithMutualTLS(t *testing.T) {
+	srv := newTestServer(t)
+	withSelfHandler(t, srv.Config.Handler)
+	c := withQueueConsumer(t, mutualTLSServer(t))
+
+	if got := c.handle(queueMessage{id: "m1", body: []byte(`{"name":"over mTLS"}`)}); got != queueAck {
+		t.Fatalf("handle = %s (%s), want ack", got, c.status.LastError)
+	}
+	_, body := doRequest(t, srv, http.MethodGet, "/api/items", "")
+	if !strings.Contains(string(body), "over mTLS") {
+		t.Errorf("items = %s", body)
+	}
+}
+
+func TestSelfClient_LoadGenerationWithMutualTLS(t *testing.T) {
+	srv := newTestServer(t)
+	withSelfHandler(t, srv.Config.Handler)
+	prev := jobSelfURL
+	jobSelfURL = mutualTLSServer(t).URL
+	t.Cleanup(func() { jobSelfURL = prev })
+
+	job := &Job{Params: []byte(`{"requests":20,"concurrency":4,"path":"/api/items?limit=1"}`)}
+	result, err := runLoadGenerationJob(job, func(done, total int) {})
+	if err != nil {
+		t.Fatal(err)
+	}
+	statuses := result.(map[string]any)["statuses"].(map[string]int)
+	if statuses["200"] != 20 {
+		t.Errorf("status counts = %v, want 20 × 200", statuses)
+	}
+}
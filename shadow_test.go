@@ -0,0 +1,100 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+)
+
+// withShadow mirrors item writes to a fresh SQLite file for one test
+func withShadow(t *testing.T) *shadowStore {
+	t.Helper()
+	store, err := openShadowStore("sqlite:" + filepath.Join(t.TempDir(), "shadow.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	shadow = store
+	t.Cleanup(func() {
+		shadow = nil
+		store.close()
+	})
+	return store
+}
+
+// shadowRequest calls a shadow admin endpoint and decodes its report
+func shadowRequest(t *testing.T, srv *httptest.Server, method, path string) (ShadowReport, []byte) {
+	t.Helper()
+	code, body := doRequest(t, srv, method, path, "")
+	var report ShadowReport
+	if code != http.StatusOK || json.Unmarshal(body, &report) != nil {
+		t.Fatalf("%s %s = %d %s", method, path, code, body)
+	}
+	return report, body
+}
+
+func TestShadow_MirrorsWrites(t *testing.T) {
+	srv := newTestServer(t)
+	withShadow(t)
+
+	one := createTestItem(t, srv, `{"name":"one"}`)
+	two := createTestItem(t, srv, `{"name":"two"}`)
+	doRequest(t, srv, http.MethodPut, fmt.Sprintf("/api/items/%d", one.ID), `{"name":"one, renamed"}`)
+	doRequest(t, srv, http.MethodDelete, fmt.Sprintf("/api/items/%d", two.ID), "")
+	doRequest(t, srv, http.MethodPost, "/api/items/batch", `{"operations":[{"op":"create","item":{"name":"three"}}]}`)
+
+	report, body := shadowRequest(t, srv, http.MethodGet, "/api/admin/shadow")
+	if report.PrimaryItems != 2 || report.ShadowItems != 2 || report.Matching != 2 || report.Divergent != 0 {
+		t.Errorf("report = %s", body)
+	}
+	if report.Writes != 5 || report.FailedWrites != 0 {
+		t.Errorf("writes = %d, failed = %d, want 5 and 0", report.Writes, report.FailedWrites)
+	}
+}
+
+func TestShadow_ReportAndBackfill(t *testing.T) {
+	srv := newTestServer(t)
+
+	// Written before shadowing started: the backfill's job
+	before := createTestItem(t, srv, `{"name":"before"}`)
+	store := withShadow(t)
+	changed := createTestItem(t, srv, `{"name":"changed"}`)
+	gone := createTestItem(t, srv, `{"name":"gone"}`)
+
+	// Drift the shadow behind the app's back
+	if _, err := store.db.Exec(`UPDATE items SET data = '{}' WHERE id = ?`, changed.ID); err != nil {
+		t.Fatal(err)
+	}
+	shadow = nil
+	doRequest(t, srv, http.MethodDelete, fmt.Sprintf("/api/items/%d", gone.ID), "")
+	shadow = store
+
+	report, _ := shadowRequest(t, srv, http.MethodGet, "/api/admin/shadow")
+	kinds := map[int64]string{}
+	for _, d := range report.Divergences {
+		kinds[d.ID] = d.Kind
+	}
+	want := map[int64]string{before.ID: shadowMissing, changed.ID: shadowDifferent, gone.ID: primaryMissing}
+	if report.Divergent != 3 || fmt.Sprint(kinds) != fmt.Sprint(want) {
+		t.Errorf("divergences = %v, want %v", kinds, want)
+	}
+
+	if report, body := shadowRequest(t, srv, http.MethodPost, "/api/admin/shadow/backfill"); report.Repaired != 3 {
+		t.Errorf("backfill = %s", body)
+	}
+	if report, body := shadowRequest(t, srv, http.MethodGet, "/api/admin/shadow"); report.Divergent != 0 || report.Matching != 2 {
+		t.Errorf("after backfill = %s", body)
+	}
+}
+
+func TestShadow_Off(t *testing.T) {
+	srv := newTestServer(t)
+	if code, _ := doRequest(t, srv, http.MethodGet, "/api/admin/shadow", ""); code != http.StatusNotFound {
+		t.Errorf("GET without SHADOW_STORE = %d, want 404", code)
+	}
+	if _, err := openShadowStore("postgres://db/items"); err == nil {
+		t.Error("only sqlite: stores are supported")
+	}
+}
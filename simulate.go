@@ -0,0 +1,92 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// =============================================================================
+// Delay and Status Simulation Endpoints
+// =============================================================================
+//
+// httpbin-style endpoints for driving timeout, retry, and circuit-breaker
+// demos predictably — no rules or chaos configuration needed:
+//
+//	GET /api/delay/2.5        -> waits 2.5 seconds, then 200
+//	GET /api/status/503       -> responds 503
+//	GET /api/status/200,503   -> picks one of the codes at random per request
+//
+// Response rules (rules.go) can do the same for arbitrary paths; these
+// endpoints are the zero-setup version.
+
+// Longest allowed delay — long enough to trip any sane client timeout,
+// short enough that a typo can't tie up a goroutine for an hour
+const maxSimulatedDelay = 60 * time.Second
+
+// delayHandler sleeps for /api/delay/:seconds (fractions allowed)
+func delayHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, `{"error":"method not allowed"}`, http.StatusMethodNotAllowed)
+		return
+	}
+
+	raw := strings.TrimPrefix(r.URL.Path, "/api/delay/")
+	seconds, err := strconv.ParseFloat(raw, 64)
+	if err != nil || seconds < 0 || seconds > maxSimulatedDelay.Seconds() {
+		writeJSONError(w, http.StatusBadRequest,
+			fmt.Sprintf("delay must be a number of seconds between 0 and %d", int(maxSimulatedDelay.Seconds())))
+		return
+	}
+	delay := time.Duration(seconds * float64(time.Second))
+
+	// Stop waiting if the client gives up — that's the point of timeout demos
+	start := time.Now()
+	select {
+	case <-time.After(delay):
+	case <-r.Context().Done():
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]any{
+		"delay_seconds": seconds,
+		"waited_ms":     time.Since(start).Milliseconds(),
+	})
+}
+
+// statusHandler responds with /api/status/:code, or a random pick from a
+// comma-separated list of codes. Works with any method so it can stand in
+// for a failing POST/PUT as well.
+func statusHandler(w http.ResponseWriter, r *http.Request) {
+	raw := strings.TrimPrefix(r.URL.Path, "/api/status/")
+
+	var codes []int
+	for _, part := range strings.Split(raw, ",") {
+		code, err := strconv.Atoi(strings.TrimSpace(part))
+		// 1xx codes are protocol-level and can't be sent as a final response
+		if err != nil || code < 200 || code > 599 {
+			http.Error(w, `{"error":"status code must be between 200 and 599"}`, http.StatusBadRequest)
+			return
+		}
+		codes = append(codes, code)
+	}
+	code := codes[rand.IntN(len(codes))]
+
+	// 204 and 304 must not have a body
+	if code == http.StatusNoContent || code == http.StatusNotModified {
+		w.WriteHeader(code)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(map[string]any{
+		"status":      code,
+		"status_text": http.StatusText(code),
+	})
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestDelay_Waits(t *testing.T) {
+	req := httptest.NewRequest("GET", "/api/delay/0.05", nil)
+	rr := httptest.NewRecorder()
+
+	delayHandler(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", rr.Code)
+	}
+
+	var resp map[string]any
+	json.NewDecoder(rr.Body).Decode(&resp)
+	if resp["delay_seconds"] != 0.05 {
+		t.Errorf("expected delay_seconds 0.05, got %v", resp["delay_seconds"])
+	}
+}
+
+func TestDelay_RejectsOutOfRange(t *testing.T) {
+	for _, path := range []string{"/api/delay/abc", "/api/delay/-1", "/api/delay/61"} {
+		rr := httptest.NewRecorder()
+		delayHandler(rr, httptest.NewRequest("GET", path, nil))
+		if rr.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected status 400, got %d", path, rr.Code)
+		}
+	}
+}
+
+func TestStatus_ReturnsCode(t *testing.T) {
+	rr := httptest.NewRecorder()
+	statusHandler(rr, httptest.NewRequest("POST", "/api/status/503", nil))
+	if rr.Code != http.StatusServiceUnavailable {
+		t.Errorf("expected status 503, got %d", rr.Code)
+	}
+
+	rr = httptest.NewRecorder()
+	statusHandler(rr, httptest.NewRequest("GET", "/api/status/204", nil))
+	if rr.Code != http.StatusNoContent || rr.Body.Len() != 0 {
+		t.Errorf("expected empty 204, got %d with %q", rr.Code, rr.Body.String())
+	}
+}
+
+func TestStatus_PicksFromList(t *testing.T) {
+	seen := map[int]bool{}
+	for i := 0; i < 100; i++ {
+		rr := httptest.NewRecorder()
+		statusHandler(rr, httptest.NewRequest("GET", "/api/status/200,418", nil))
+		seen[rr.Code] = true
+	}
+	if len(seen) != 2 || !seen[200] || !seen[418] {
+		t.Errorf("expected both 200 and 418, got %v", seen)
+	}
+}
+
+func TestStatus_RejectsInvalid(t *testing.T) {
+	for _, path := range []string{"/api/status/abc", "/api/status/100", "/api/status/600", "/api/status/200,x"} {
+		rr := httptest.NewRecorder()
+		statusHandler(rr, httptest.NewRequest("GET", path, nil))
+		if rr.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected status 400, got %d", path, rr.Code)
+		}
+	}
+}
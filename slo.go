@@ -0,0 +1,236 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// =============================================================================
+// SLOs and Burn Rates
+// =============================================================================
+//
+// SLO demos usually need Prometheus recording rules and a Grafana dashboard.
+// GET /api/stats/slo works them out inside the app instead, for two SLIs:
+//
+//   - availability: the share of requests that didn't fail with a 5xx
+//   - latency: the share of requests answered within SLO_LATENCY_THRESHOLD
+//
+//	curl http://localhost:8080/api/stats/slo
+//	{"target_percent":99,"latency_threshold":"200ms","windows":[
+//	  {"window":"5m0s","requests":1200,
+//	   "availability":{"good":1188,"bad":12,"sli_percent":99,"burn_rate":1},
+//	   "latency":{"good":1200,"bad":0,"sli_percent":100,"burn_rate":0}}, ...]}
+//
+// The burn rate is how fast the error budget (100% - target) is being spent:
+// 1 spends exactly the budget over the SLO period, 14.4 over the last hour
+// is the classic "page someone" threshold. Add a rule that answers 500s
+// (rules.go) or turn on latency injection (latency.go) and watch the short
+// windows burn first. ?windows=1m,10m asks for other windows.
+//
+// Counts are kept in 10-second buckets for the last 24 hours (a fixed ring,
+// so memory doesn't grow with traffic). Health checks, /metrics, the stats
+// endpoints, and long polls (slow on purpose) don't count.
+
+// Resolution and reach of the counters
+const (
+	sloBucketSize = 10 * time.Second
+	maxSLOWindow  = 24 * time.Hour
+)
+
+// Settings, from SLO_TARGET, SLO_LATENCY_THRESHOLD and SLO_WINDOWS (set in main)
+var (
+	sloTarget           = 99.0 // percent, for both SLIs
+	sloLatencyThreshold = 200 * time.Millisecond
+	sloWindows          = []time.Duration{5 * time.Minute, 30 * time.Minute, time.Hour, 6 * time.Hour}
+)
+
+// sloBucket counts the requests of one 10-second slot
+type sloBucket struct {
+	slot   int64 // unix time / sloBucketSize; tells a fresh bucket from a stale one
+	total  int64
+	errors int64
+	slow   int64
+}
+
+// sloRecorder is the ring of buckets, indexed by slot
+type sloRecorder struct {
+	mu      sync.Mutex
+	buckets []sloBucket
+}
+
+var sloStats = newSLORecorder()
+
+func newSLORecorder() *sloRecorder {
+	return &sloRecorder{buckets: make([]sloBucket, maxSLOWindow/sloBucketSize)}
+}
+
+// SLI is one indicator over one window. SLIPercent and BurnRate are null
+// when the window saw no requests.
+type SLI struct {
+	Good       int64    `json:"good"`
+	Bad        int64    `json:"bad"`
+	SLIPercent *float64 `json:"sli_percent"`
+	BurnRate   *float64 `json:"burn_rate"`
+}
+
+// SLOWindow is one window in GET /api/stats/slo
+type SLOWindow struct {
+	Window       string `json:"window"`
+	Requests     int64  `json:"requests"`
+	Availability SLI    `json:"availability"`
+	Latency      SLI    `json:"latency"`
+}
+
+// sloSlot is the bucket slot for a time
+func sloSlot(at time.Time) int64 {
+	return at.UnixNano() / int64(sloBucketSize)
+}
+
+// record counts one request that finished at the given time
+func (s *sloRecorder) record(at time.Time, failed, slow bool) {
+	slot := sloSlot(at)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	b := &s.buckets[slot%int64(len(s.buckets))]
+	if b.slot != slot {
+		*b = sloBucket{slot: slot} // last used a day ago
+	}
+	b.total++
+	if failed {
+		b.errors++
+	}
+	if slow {
+		b.slow++
+	}
+}
+
+// window sums the buckets of the last d (rounded up to whole buckets)
+func (s *sloRecorder) window(now time.Time, d time.Duration) sloBucket {
+	last := sloSlot(now)
+	first := last - int64((d+sloBucketSize-1)/sloBucketSize) + 1
+
+	var sum sloBucket
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, b := range s.buckets {
+		if b.slot >= first && b.slot <= last {
+			sum.total += b.total
+			sum.errors += b.errors
+			sum.slow += b.slow
+		}
+	}
+	return sum
+}
+
+// summary reports both SLIs for each window against target (a percent)
+func (s *sloRecorder) summary(now time.Time, windows []time.Duration, target float64) []SLOWindow {
+	out := make([]SLOWindow, 0, len(windows))
+	for _, d := range windows {
+		sum := s.window(now, d)
+		out = append(out, SLOWindow{
+			Window:       d.String(),
+			Requests:     sum.total,
+			Availability: newSLI(sum.total, sum.errors, target),
+			Latency:      newSLI(sum.total, sum.slow, target),
+		})
+	}
+	return out
+}
+
+// reset forgets every count
+func (s *sloRecorder) reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	clear(s.buckets)
+}
+
+// newSLI turns counts into a percentage and a burn rate
+func newSLI(total, bad int64, target float64) SLI {
+	sli := SLI{Good: total - bad, Bad: bad}
+	if total == 0 {
+		return sli
+	}
+	percent := 100 * float64(total-bad) / float64(total)
+	burn := (100 - percent) / (100 - target)
+	sli.SLIPercent = roundTo(percent, 3)
+	sli.BurnRate = roundTo(burn, 2)
+	return sli
+}
+
+// roundTo rounds to the given decimal places, for readable JSON
+func roundTo(f float64, places int) *float64 {
+	scale := math.Pow(10, float64(places))
+	f = math.Round(f*scale) / scale
+	return &f
+}
+
+// sloCounts reports whether a finished request counts toward the SLIs
+func sloCounts(r *http.Request) bool {
+	switch path := r.URL.Path; {
+	case path == "/health", path == "/ready", path == "/metrics":
+		return false
+	case strings.HasPrefix(path, "/api/stats/"):
+		return false
+	case r.URL.Query().Has("wait"): // long poll (longpoll.go)
+		return false
+	}
+	return true
+}
+
+// recordSLO counts a finished request, called from loggingMiddleware
+func recordSLO(r *http.Request, status int, duration time.Duration) {
+	if sloCounts(r) {
+		sloStats.record(time.Now(), status >= 500, duration > sloLatencyThreshold)
+	}
+}
+
+// parseSLOWindows reads SLO_WINDOWS or ?windows=: durations from 10s to 24h
+func parseSLOWindows(values []string) ([]time.Duration, error) {
+	windows := make([]time.Duration, 0, len(values))
+	for _, value := range values {
+		d, err := time.ParseDuration(value)
+		if err != nil || d < sloBucketSize || d > maxSLOWindow {
+			return nil, fmt.Errorf("window %q must be a duration from %s to %s", value, sloBucketSize, maxSLOWindow)
+		}
+		windows = append(windows, d)
+	}
+	return windows, nil
+}
+
+// sloHandler handles GET /api/stats/slo (?windows=1m,10m) and DELETE to
+// start over, e.g. between two demo runs
+func sloHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodDelete:
+		sloStats.reset()
+		w.WriteHeader(http.StatusNoContent)
+		return
+	default:
+		http.Error(w, `{"error":"method not allowed"}`, http.StatusMethodNotAllowed)
+		return
+	}
+
+	windows := sloWindows
+	if raw := r.URL.Query().Get("windows"); raw != "" {
+		var err error
+		if windows, err = parseSLOWindows(strings.Split(raw, ",")); err != nil {
+			writeJSONError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+	}
+
+	json.NewEncoder(w).Encode(map[string]any{
+		"target_percent":    sloTarget,
+		"latency_threshold": sloLatencyThreshold.String(),
+		"windows":           sloStats.summary(time.Now(), windows, sloTarget),
+	})
+}
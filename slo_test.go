@@ -0,0 +1,78 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestSLORecorder(t *testing.T) {
+	s := newSLORecorder()
+	now := time.Now()
+	for i := range 100 {
+		s.record(now, i < 2, i < 5) // 2 errors, 5 slow
+	}
+	s.record(now.Add(-10*time.Minute), true, true)         // only in the 1h window
+	s.record(now.Add(-maxSLOWindow-time.Hour), true, true) // long gone
+
+	got := s.summary(now, []time.Duration{5 * time.Minute, time.Hour}, 99)
+	short, long := got[0], got[1]
+	if short.Requests != 100 || *short.Availability.SLIPercent != 98 || *short.Availability.BurnRate != 2 {
+		t.Errorf("5m availability = %+v", short)
+	}
+	if *short.Latency.SLIPercent != 95 || *short.Latency.BurnRate != 5 {
+		t.Errorf("5m latency = %+v", short.Latency)
+	}
+	if long.Requests != 101 || long.Availability.Bad != 3 {
+		t.Errorf("1h = %+v", long)
+	}
+
+	// An idle window has no SLI rather than a perfect one
+	s.reset()
+	if got := s.summary(now, []time.Duration{time.Minute}, 99); got[0].Availability.SLIPercent != nil {
+		t.Errorf("after reset = %+v", got[0])
+	}
+}
+
+func TestParseSLOWindows(t *testing.T) {
+	if got, err := parseSLOWindows([]string{"1m", "6h"}); err != nil || len(got) != 2 || got[1] != 6*time.Hour {
+		t.Errorf("parseSLOWindows = %v, %v", got, err)
+	}
+	for _, bad := range []string{"1s", "48h", "soon"} {
+		if _, err := parseSLOWindows([]string{bad}); err == nil {
+			t.Errorf("window %q: want an error", bad)
+		}
+	}
+}
+
+func TestSLOHandler(t *testing.T) {
+	srv := newTestServer(t)
+	sloStats.reset()
+	t.Cleanup(sloStats.reset)
+
+	for range 3 {
+		doRequest(t, srv, http.MethodGet, "/api/items", "")
+	}
+	doRequest(t, srv, http.MethodGet, "/health", "") // not counted
+
+	code, body := doRequest(t, srv, http.MethodGet, "/api/stats/slo?windows=1m", "")
+	if code != http.StatusOK {
+		t.Fatalf("status = %d %s", code, body)
+	}
+	var result struct {
+		Target  float64     `json:"target_percent"`
+		Windows []SLOWindow `json:"windows"`
+	}
+	json.Unmarshal(body, &result)
+	if result.Target != sloTarget || len(result.Windows) != 1 || result.Windows[0].Requests != 3 {
+		t.Errorf("result = %s", body)
+	}
+
+	if code, _ := doRequest(t, srv, http.MethodGet, "/api/stats/slo?windows=1s", ""); code != http.StatusBadRequest {
+		t.Errorf("windows=1s status = %d, want 400", code)
+	}
+	if code, _ := doRequest(t, srv, http.MethodDelete, "/api/stats/slo", ""); code != http.StatusNoContent {
+		t.Errorf("DELETE = %d, want 204", code)
+	}
+}
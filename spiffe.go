@@ -0,0 +1,376 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// =============================================================================
+// SPIFFE Workload Identity
+// =============================================================================
+//
+// Instead of certificate files (tls.go), a workload can get its identity from
+// a SPIFFE Workload API, such as the SPIRE agent's socket: an X.509 SVID
+// whose URI SAN is the workload's SPIFFE ID, plus the trust bundle to verify
+// its peers with. SPIFFE_ENDPOINT_SOCKET (the standard variable) turns it on:
+//
+//	SPIFFE_ENDPOINT_SOCKET=unix:///run/spire/sockets/agent.sock ./demo-app
+//	{"msg":"workload identity obtained","spiffe_id":"spiffe://demo.example/ns/prod/sa/demo-app",...}
+//
+// The SVID becomes the server certificate and the bundle verifies client
+// certificates, so every caller needs an SVID from the same trust domain
+// (TLS_CLIENT_AUTH=optional lets others in). SPIRE rotates SVIDs well before
+// they expire; the stream delivers each new one and the next handshake uses
+// it, no restart needed.
+//
+// The SPIFFE ID goes on every log line (spiffe_id), on the
+// demoapp_spiffe_info metric, and in /api/system; callers' IDs are logged
+// as client_spiffe_id and shown in client_cert.
+//
+// The Workload API is gRPC. Rather than pull in gRPC and go-spiffe for one
+// streaming call, FetchX509SVID is made by hand: HTTP/2 without TLS over the
+// socket (net/http does that since Go 1.24), gRPC's 5-byte message framing,
+// and protowire for the two small protobuf messages.
+
+// workloadAPITimeout is how long startup waits for the first SVID
+const workloadAPITimeout = 30 * time.Second
+
+// x509SVID is one identity from the Workload API
+type x509SVID struct {
+	id     string
+	chain  []*x509.Certificate // leaf first
+	key    crypto.Signer
+	bundle *x509.CertPool // trust domain CAs, to verify peers
+}
+
+// certificate is the SVID as a TLS certificate
+func (s *x509SVID) certificate() *tls.Certificate {
+	cert := &tls.Certificate{PrivateKey: s.key, Leaf: s.chain[0]}
+	for _, c := range s.chain {
+		cert.Certificate = append(cert.Certificate, c.Raw)
+	}
+	return cert
+}
+
+// SVIDInfo is the workload identity in /api/system
+type SVIDInfo struct {
+	SPIFFEID  string    `json:"spiffe_id"`
+	ExpiresAt time.Time `json:"expires_at"`
+	Rotations int64     `json:"rotations"` // SVIDs received after the first
+}
+
+// workloadIdentity keeps the latest SVID from the Workload API
+type workloadIdentity struct {
+	addr      string // SPIFFE_ENDPOINT_SOCKET
+	client    *http.Client
+	current   atomic.Pointer[x509SVID]
+	rotations atomic.Int64
+	ready     chan struct{} // closed on the first SVID
+}
+
+// Active workload identity, nil unless SPIFFE_ENDPOINT_SOCKET is set
+var spiffeIdentity *workloadIdentity
+
+// newWorkloadIdentity prepares an HTTP/2 client for the Workload API at
+// addr: "unix:///path/to/socket" or "tcp://host:port"
+func newWorkloadIdentity(addr string) (*workloadIdentity, error) {
+	u, err := url.Parse(addr)
+	if err != nil {
+		return nil, fmt.Errorf("SPIFFE_ENDPOINT_SOCKET: %w", err)
+	}
+	var network, address string
+	switch {
+	case u.Scheme == "unix" && u.Path != "":
+		network, address = "unix", u.Path
+	case u.Scheme == "tcp" && u.Host != "":
+		network, address = "tcp", u.Host
+	default:
+		return nil, fmt.Errorf(`SPIFFE_ENDPOINT_SOCKET must be "unix:///path" or "tcp://host:port", got %q`, addr)
+	}
+
+	protocols := new(http.Protocols)
+	protocols.SetUnencryptedHTTP2(true)
+	transport := &http.Transport{
+		Protocols: protocols,
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			return (&net.Dialer{}).DialContext(ctx, network, address)
+		},
+	}
+	return &workloadIdentity{addr: addr, client: &http.Client{Transport: transport}, ready: make(chan struct{})}, nil
+}
+
+// start watches the Workload API in the background and waits for the first
+// SVID
+func (w *workloadIdentity) start(ctx context.Context, timeout time.Duration) error {
+	go w.watch(ctx)
+	select {
+	case <-w.ready:
+		return nil
+	case <-time.After(timeout):
+		return fmt.Errorf("no SVID from %s after %s", w.addr, timeout)
+	}
+}
+
+// watch keeps a FetchX509SVID stream open, reconnecting with backoff
+func (w *workloadIdentity) watch(ctx context.Context) {
+	backoff := time.Second
+	for {
+		err := w.fetchX509SVIDs(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+		slog.Warn("workload API stream ended, reconnecting", "socket", w.addr, "error", err, "retry_in", backoff)
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			return
+		}
+		backoff = min(backoff*2, 30*time.Second)
+	}
+}
+
+// fetchX509SVIDs makes one FetchX509SVID call and applies every response
+// on the stream until it ends
+func (w *workloadIdentity) fetchX509SVIDs(ctx context.Context) error {
+	// An empty X509SVIDRequest: uncompressed flag, zero length
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost,
+		"http://localhost/SpiffeWorkloadAPI/FetchX509SVID", bytes.NewReader(make([]byte, 5)))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/grpc")
+	req.Header.Set("TE", "trailers")
+	// Required by the Workload API, so browsers can't be tricked into calling it
+	req.Header.Set("workload.spiffe.io", "true")
+
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("workload API: HTTP %d", resp.StatusCode)
+	}
+
+	body := bufio.NewReader(resp.Body)
+	for {
+		msg, err := readGRPCMessage(body)
+		if errors.Is(err, io.EOF) {
+			return grpcStatus(resp)
+		}
+		if err != nil {
+			return err
+		}
+		svid, err := parseX509SVIDResponse(msg)
+		if err != nil {
+			return err
+		}
+		w.update(svid)
+	}
+}
+
+// update makes svid the current identity
+func (w *workloadIdentity) update(svid *x509SVID) {
+	previous := w.current.Swap(svid)
+	leaf := svid.chain[0]
+	spiffeSVIDExpiry.Set(float64(leaf.NotAfter.Unix()))
+	if previous == nil {
+		spiffeInfo.WithLabelValues(svid.id).Set(1)
+		slog.Info("workload identity obtained", "spiffe_id", svid.id, "expires_at", leaf.NotAfter)
+		close(w.ready)
+		return
+	}
+	w.rotations.Add(1)
+	slog.Info("workload identity rotated", "spiffe_id", svid.id, "expires_at", leaf.NotAfter)
+}
+
+// info describes the current SVID for /api/system
+func (w *workloadIdentity) info() *SVIDInfo {
+	svid := w.current.Load()
+	if svid == nil {
+		return nil
+	}
+	return &SVIDInfo{SPIFFEID: svid.id, ExpiresAt: svid.chain[0].NotAfter.UTC(), Rotations: w.rotations.Load()}
+}
+
+// tlsConfig serves with the current SVID and verifies clients against the
+// current bundle, so rotations apply to the next handshake
+func (w *workloadIdentity) tlsConfig(clientAuth string) (*tls.Config, error) {
+	mode := tls.RequireAndVerifyClientCert
+	switch clientAuth {
+	case "", clientAuthRequire:
+	case clientAuthOptional:
+		mode = tls.VerifyClientCertIfGiven
+	default:
+		return nil, fmt.Errorf("TLS_CLIENT_AUTH must be require or optional, got %q", clientAuth)
+	}
+	return &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		GetConfigForClient: func(*tls.ClientHelloInfo) (*tls.Config, error) {
+			svid := w.current.Load()
+			return &tls.Config{
+				MinVersion:   tls.VersionTLS12,
+				Certificates: []tls.Certificate{*svid.certificate()},
+				ClientCAs:    svid.bundle,
+				ClientAuth:   mode,
+			}, nil
+		},
+	}, nil
+}
+
+// readGRPCMessage reads one length-prefixed gRPC message
+func readGRPCMessage(r io.Reader) ([]byte, error) {
+	var prefix [5]byte
+	if _, err := io.ReadFull(r, prefix[:]); err != nil {
+		return nil, err
+	}
+	if prefix[0] != 0 {
+		return nil, fmt.Errorf("workload API sent a compressed message")
+	}
+	size := binary.BigEndian.Uint32(prefix[1:])
+	if size > 16<<20 {
+		return nil, fmt.Errorf("workload API message too large (%d bytes)", size)
+	}
+	msg := make([]byte, size)
+	if _, err := io.ReadFull(r, msg); err != nil {
+		return nil, fmt.Errorf("workload API message cut short: %w", err)
+	}
+	return msg, nil
+}
+
+// grpcStatus turns the stream's grpc-status trailer into an error. The
+// Workload API ends streams with an error status (e.g. PermissionDenied
+// for an unattested workload); a clean end is still the end of updates.
+func grpcStatus(resp *http.Response) error {
+	status := resp.Trailer.Get("Grpc-Status")
+	message := resp.Trailer.Get("Grpc-Message")
+	if status == "" { // a trailers-only response puts them in the headers
+		status, message = resp.Header.Get("Grpc-Status"), resp.Header.Get("Grpc-Message")
+	}
+	if status == "" || status == "0" {
+		return errors.New("workload API closed the stream")
+	}
+	return fmt.Errorf("workload API: grpc status %s: %s", status, message)
+}
+
+// parseX509SVIDResponse decodes an X509SVIDResponse and returns its first
+// SVID, the workload's default identity:
+//
+//	message X509SVIDResponse { repeated X509SVID svids = 1; ... }
+//	message X509SVID {
+//	  string spiffe_id = 1;
+//	  bytes x509_svid = 2;     // ASN.1 DER certificates, leaf first
+//	  bytes x509_svid_key = 3; // PKCS#8 DER private key
+//	  bytes bundle = 4;        // ASN.1 DER CA certificates
+//	}
+func parseX509SVIDResponse(msg []byte) (*x509SVID, error) {
+	var first []byte
+	err := walkProtoBytes(msg, func(num protowire.Number, value []byte) {
+		if num == 1 && first == nil {
+			first = value
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	if first == nil {
+		return nil, errors.New("workload API sent no SVIDs")
+	}
+
+	var id string
+	var certDER, keyDER, bundleDER []byte
+	err = walkProtoBytes(first, func(num protowire.Number, value []byte) {
+		switch num {
+		case 1:
+			id = string(value)
+		case 2:
+			certDER = value
+		case 3:
+			keyDER = value
+		case 4:
+			bundleDER = value
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if !strings.HasPrefix(id, "spiffe://") {
+		return nil, fmt.Errorf("SVID has an invalid SPIFFE ID %q", id)
+	}
+	chain, err := x509.ParseCertificates(certDER)
+	if err != nil || len(chain) == 0 {
+		return nil, fmt.Errorf("SVID %s: bad certificates: %v", id, err)
+	}
+	key, err := x509.ParsePKCS8PrivateKey(keyDER)
+	if err != nil {
+		return nil, fmt.Errorf("SVID %s: bad private key: %w", id, err)
+	}
+	signer, ok := key.(crypto.Signer)
+	if !ok {
+		return nil, fmt.Errorf("SVID %s: unsupported private key type %T", id, key)
+	}
+	cas, err := x509.ParseCertificates(bundleDER)
+	if err != nil || len(cas) == 0 {
+		return nil, fmt.Errorf("SVID %s: bad trust bundle: %v", id, err)
+	}
+	bundle := x509.NewCertPool()
+	for _, ca := range cas {
+		bundle.AddCert(ca)
+	}
+	return &x509SVID{id: id, chain: chain, key: signer, bundle: bundle}, nil
+}
+
+// walkProtoBytes calls fn for every length-delimited field (strings, bytes,
+// messages) in a protobuf message and skips the rest
+func walkProtoBytes(msg []byte, fn func(protowire.Number, []byte)) error {
+	for len(msg) > 0 {
+		num, typ, n := protowire.ConsumeTag(msg)
+		if n < 0 {
+			return fmt.Errorf("bad protobuf from workload API: %w", protowire.ParseError(n))
+		}
+		msg = msg[n:]
+		if typ == protowire.BytesType {
+			value, n := protowire.ConsumeBytes(msg)
+			if n < 0 {
+				return fmt.Errorf("bad protobuf from workload API: %w", protowire.ParseError(n))
+			}
+			fn(num, value)
+			msg = msg[n:]
+			continue
+		}
+		n = protowire.ConsumeFieldValue(num, typ, msg)
+		if n < 0 {
+			return fmt.Errorf("bad protobuf from workload API: %w", protowire.ParseError(n))
+		}
+		msg = msg[n:]
+	}
+	return nil
+}
+
+// spiffeID is the first spiffe:// URI SAN of a certificate, or ""
+func spiffeID(cert *x509.Certificate) string {
+	for _, u := range cert.URIs {
+		if u.Scheme == "spiffe" {
+			return u.String()
+		}
+	}
+	return ""
+}
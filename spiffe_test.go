@@ -0,0 +1,188 @@
+package main
+
+import (
+	"context"
+	"crypto/x509"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// issueTestSVID signs an X.509 SVID for id with ca
+func issueTestSVID(t *testing.T, ca *testCert, id string) *testCert {
+	t.Helper()
+	u, _ := url.Parse(id)
+	return issueTestCert(t, t.TempDir(), "svid", ca, &x509.Certificate{
+		URIs:        []*url.URL{u},
+		IPAddresses: []net.IP{net.IPv4(127, 0, 0, 1)},
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	})
+}
+
+// x509SVIDResponse encodes an X509SVIDResponse with one SVID
+func x509SVIDResponse(t *testing.T, id string, svid, ca *testCert) []byte {
+	t.Helper()
+	key, err := x509.MarshalPKCS8PrivateKey(svid.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var msg []byte
+	msg = protowire.AppendTag(msg, 1, protowire.BytesType)
+	msg = protowire.AppendBytes(msg, []byte(id))
+	msg = protowire.AppendTag(msg, 2, protowire.BytesType)
+	msg = protowire.AppendBytes(msg, svid.cert.Raw)
+	msg = protowire.AppendTag(msg, 3, protowire.BytesType)
+	msg = protowire.AppendBytes(msg, key)
+	msg = protowire.AppendTag(msg, 4, protowire.BytesType)
+	msg = protowire.AppendBytes(msg, ca.cert.Raw)
+	msg = protowire.AppendTag(msg, 5, protowire.BytesType) // hint: skipped
+	msg = protowire.AppendBytes(msg, []byte("internal"))
+
+	var resp []byte
+	resp = protowire.AppendTag(resp, 1, protowire.BytesType)
+	return protowire.AppendBytes(resp, msg)
+}
+
+// fakeWorkloadAPI serves FetchX509SVID over h2c on a unix socket, sending
+// each message from updates on the stream. Returns SPIFFE_ENDPOINT_SOCKET.
+func fakeWorkloadAPI(t *testing.T, updates <-chan []byte) string {
+	t.Helper()
+	dir, err := os.MkdirTemp("", "spiffe") // short: socket paths are limited to ~100 bytes
+	if err != nil {
+		t.Fatal(err)
+	}
+	socket := filepath.Join(dir, "agent.sock")
+	listener, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/SpiffeWorkloadAPI/FetchX509SVID" || r.Header.Get("workload.spiffe.io") != "true" || r.ProtoMajor != 2 {
+			w.Header().Set("Grpc-Status", "3") // InvalidArgument
+			return
+		}
+		w.Header().Set("Content-Type", "application/grpc")
+		w.Header().Set("Trailer", "Grpc-Status")
+		for {
+			select {
+			case msg, ok := <-updates:
+				if !ok {
+					w.Header().Set("Grpc-Status", "0")
+					return
+				}
+				frame := []byte{0, 0, 0, 0, 0}
+				frame[1], frame[2], frame[3], frame[4] = byte(len(msg)>>24), byte(len(msg)>>16), byte(len(msg)>>8), byte(len(msg))
+				w.Write(append(frame, msg...))
+				w.(http.Flusher).Flush()
+			case <-r.Context().Done():
+				return
+			}
+		}
+	})
+	protocols := new(http.Protocols)
+	protocols.SetUnencryptedHTTP2(true)
+	srv := &http.Server{Handler: handler, Protocols: protocols}
+	go srv.Serve(listener)
+	t.Cleanup(func() {
+		srv.Close()
+		os.RemoveAll(dir)
+	})
+	return "unix://" + socket
+}
+
+func TestSPIFFE_FetchAndRotate(t *testing.T) {
+	ca := issueTestCert(t, t.TempDir(), "demo-ca", nil, &x509.Certificate{IsCA: true, BasicConstraintsValid: true,
+		KeyUsage: x509.KeyUsageCertSign})
+	const id = "spiffe://demo.example/ns/prod/sa/demo-app"
+	updates := make(chan []byte, 2)
+	updates <- x509SVIDResponse(t, id, issueTestSVID(t, ca, id), ca)
+
+	identity, err := newWorkloadIdentity(fakeWorkloadAPI(t, updates))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if err := identity.start(ctx, 5*time.Second); err != nil {
+		t.Fatal(err)
+	}
+	first := identity.current.Load()
+	if info := identity.info(); info.SPIFFEID != id || info.Rotations != 0 {
+		t.Errorf("info = %+v", info)
+	}
+
+	// A rotated SVID replaces the current one without a restart
+	updates <- x509SVIDResponse(t, id, issueTestSVID(t, ca, id), ca)
+	deadline := time.Now().Add(5 * time.Second)
+	for identity.rotations.Load() == 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if identity.current.Load() == first {
+		t.Error("SVID wasn't rotated")
+	}
+}
+
+func TestSPIFFE_MutualTLS(t *testing.T) {
+	ca := issueTestCert(t, t.TempDir(), "demo-ca", nil, &x509.Certificate{IsCA: true, BasicConstraintsValid: true,
+		KeyUsage: x509.KeyUsageCertSign})
+	const serverID, clientID = "spiffe://demo.example/demo-app", "spiffe://demo.example/billing"
+	updates := make(chan []byte, 1)
+	updates <- x509SVIDResponse(t, serverID, issueTestSVID(t, ca, serverID), ca)
+	identity, _ := newWorkloadIdentity(fakeWorkloadAPI(t, updates))
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if err := identity.start(ctx, 5*time.Second); err != nil {
+		t.Fatal(err)
+	}
+	config, err := identity.tlsConfig("")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(clientCert(r).SPIFFEID))
+	}))
+	srv.TLS = config
+	srv.StartTLS()
+	defer srv.Close()
+
+	if resp, err := mtlsClient(ca, nil).Get(srv.URL); err == nil {
+		resp.Body.Close()
+		t.Fatal("request without an SVID got through")
+	}
+	resp, err := mtlsClient(ca, issueTestSVID(t, ca, clientID)).Get(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if got := resp.TLS.PeerCertificates[0].URIs[0].String(); got != serverID {
+		t.Errorf("server presented %s, want %s", got, serverID)
+	}
+	body := make([]byte, 100)
+	n, _ := resp.Body.Read(body)
+	if string(body[:n]) != clientID {
+		t.Errorf("server saw client %q, want %s", body[:n], clientID)
+	}
+}
+
+func TestSPIFFE_BadInput(t *testing.T) {
+	for _, addr := range []string{"/run/agent.sock", "unix://", "http://agent:8081"} {
+		if _, err := newWorkloadIdentity(addr); err == nil {
+			t.Errorf("SPIFFE_ENDPOINT_SOCKET=%q accepted", addr)
+		}
+	}
+	if _, err := parseX509SVIDResponse([]byte{0x0a, 0x10}); err == nil {
+		t.Error("truncated protobuf parsed")
+	}
+	if _, err := parseX509SVIDResponse(nil); err == nil {
+		t.Error("response without SVIDs parsed")
+	}
+}
@@ -0,0 +1,440 @@
+package main
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	badger "github.com/dgraph-io/badger/v4"
+)
+
+// =============================================================================
+// Warm Standby
+// =============================================================================
+//
+// A simple disaster-recovery story: a second instance that holds a recent
+// copy of the primary's database and can take over when told to.
+//
+//	# standby: accepts shipments, refuses writes until promoted
+//	STANDBY_MODE=true PORT=8081 ./demo-app
+//	# primary: ships its changes to the standby every 30s
+//	STANDBY_URL=http://localhost:8081 ./demo-app
+//
+// Every STANDBY_INTERVAL the primary takes an incremental Badger backup,
+// the keys changed since the last shipment (deletes included), and POSTs it
+// to the standby's /api/standby/receive, which loads it. The first shipment,
+// and the one after a reset or restore (which leave no delete markers), is
+// a full backup that replaces the standby's data.
+//
+// The standby serves reads, so you can check the copy, but answers writes
+// with 503. When the primary is gone:
+//
+//	curl -X POST http://localhost:8081/api/admin/promote
+//	{"promoted":true,"applied_version":1289,"last_received_at":"...","lag_seconds":12.5}
+//
+// It now takes writes, with item IDs continuing after the highest copied one.
+// Anything the primary wrote after its last shipment is lost: that's the
+// recovery point, at most STANDBY_INTERVAL old. A promoted standby refuses
+// further shipments, so a primary that comes back can't overwrite it.
+//
+// GET /api/standby shows either side's state. Unlike cluster mode
+// (cluster.go) there's no election: promotion is a human decision.
+
+// Headers and query parameters of a shipment
+const (
+	standbyPrimaryHeader = "X-Standby-Primary" // the shipping process's ID
+	standbyVersionHeader = "X-Standby-Version" // highest version in the shipment
+)
+
+// standbyMode is STANDBY_MODE (set in main); promote turns it off
+var standbyMode bool
+
+// standbyState is the receiving side
+type standbyState struct {
+	mu             sync.Mutex
+	promoted       bool
+	primary        string // ID of the primary the copy comes from
+	nextSince      uint64 // the "since" the next incremental shipment must have
+	appliedVersion uint64
+	lastReceivedAt time.Time
+	shipments      int
+	promotedAt     time.Time
+}
+
+var standby = &standbyState{}
+
+// isStandby reports whether this instance is a standby that hasn't been
+// promoted
+func isStandby() bool {
+	standby.mu.Lock()
+	defer standby.mu.Unlock()
+	return standbyMode && !standby.promoted
+}
+
+// StandbyShipment is what the standby answers a shipment with
+type StandbyShipment struct {
+	AppliedVersion uint64 `json:"applied_version"`
+	NextSince      uint64 `json:"next_since"`
+	Full           bool   `json:"full"`
+	Bytes          int64  `json:"bytes"`
+}
+
+// receive loads one shipment into target. A shipment that doesn't continue
+// from the last one (the primary restarted, or the standby did) is refused
+// with errStandbyOutOfSync, and the primary sends a full one next.
+func (s *standbyState) receive(target *badger.DB, body io.Reader, primary string, since, version uint64, full bool) (StandbyShipment, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.promoted {
+		return StandbyShipment{}, errStandbyPromoted
+	}
+	if !full && (primary != s.primary || since != s.nextSince) {
+		return StandbyShipment{}, errStandbyOutOfSync
+	}
+
+	counted := &countingReader{r: body}
+	err := timeDBOp("standby_load", itemKeyPrefix, func() error {
+		if full {
+			// Merge operators fold in the background; stop them before
+			// their keys go, like resetStore does
+			counters.stop()
+			hitCounter.stop()
+			if err := target.DropAll(); err != nil {
+				return err
+			}
+		}
+		return target.Load(counted, 256)
+	})
+	if err != nil {
+		return StandbyShipment{}, err
+	}
+
+	// Badger's backups hold the versions after "since", so the last
+	// version loaded is the next shipment's since. An empty shipment
+	// reports version 0 and changes nothing.
+	if version > s.nextSince || full {
+		s.nextSince = version
+		s.appliedVersion = version
+	}
+	s.primary = primary
+	s.lastReceivedAt = time.Now().UTC()
+	s.shipments++
+	return StandbyShipment{AppliedVersion: s.appliedVersion, NextSince: s.nextSince, Full: full, Bytes: counted.n}, nil
+}
+
+// Reasons a standby refuses a shipment
+var (
+	errStandbyPromoted  = fmt.Errorf("this standby was promoted; it no longer accepts shipments")
+	errStandbyOutOfSync = fmt.Errorf("shipment doesn't continue from the last one; send a full backup")
+)
+
+// promote makes the standby a primary: no more shipments, writes allowed,
+// and the item ID sequence moved past the copied items
+func (s *standbyState) promote() (map[string]any, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !standbyMode {
+		return nil, fmt.Errorf("not a standby (STANDBY_MODE is off)")
+	}
+	if s.promoted {
+		return nil, fmt.Errorf("already promoted at %s", s.promotedAt.Format(time.RFC3339))
+	}
+	if err := advanceItemSequence(); err != nil { // cluster.go
+		return nil, err
+	}
+	if err := syncItemsGauge(); err != nil {
+		return nil, err
+	}
+	if _, err := rootKeyspace.reconcileAggregates(); err != nil { // aggregates.go
+		return nil, err
+	}
+	s.promoted = true
+	s.promotedAt = time.Now().UTC()
+
+	result := map[string]any{
+		"promoted":         true,
+		"applied_version":  s.appliedVersion,
+		"last_received_at": nil,
+		"lag_seconds":      nil,
+	}
+	if !s.lastReceivedAt.IsZero() {
+		result["last_received_at"] = s.lastReceivedAt
+		result["lag_seconds"] = *roundTo(time.Since(s.lastReceivedAt).Seconds(), 1)
+	}
+	return result, nil
+}
+
+// countingReader counts the bytes read through it
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// =============================================================================
+// Shipping (the primary)
+// =============================================================================
+
+// standbyShipper sends source's changes to a standby
+type standbyShipper struct {
+	target string     // standby base URL
+	source *badger.DB // the primary's database
+	id     string     // random per process, so the standby spots a restart
+	client *http.Client
+
+	mu            sync.Mutex
+	since         uint64
+	generation    int64 // dataGeneration at the last shipment (cluster.go)
+	shipped       bool
+	lastShippedAt time.Time
+	lastError     string
+	lastBytes     int64
+	shipments     int
+	failures      int
+}
+
+// Active shipper, nil unless STANDBY_URL is set
+var standbyShip *standbyShipper
+
+func newStandbyShipper(target string, source *badger.DB) *standbyShipper {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return &standbyShipper{
+		target: strings.TrimRight(target, "/"),
+		source: source,
+		id:     hex.EncodeToString(b),
+		client: newOutboundClient(time.Minute), // outbound.go
+	}
+}
+
+// start ships every interval. In cluster mode only the leader ships.
+func (s *standbyShipper) start(interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			if _, isLeader := clusterLeaderOrStandalone(); isLeader {
+				if err := s.ship(); err != nil {
+					slog.Warn("standby shipment failed", "standby", s.target, "error", err)
+				}
+			}
+			<-ticker.C
+		}
+	}()
+}
+
+// clusterLeaderOrStandalone is cluster.leader() that also works without a cluster
+func clusterLeaderOrStandalone() (string, bool) {
+	if cluster == nil {
+		return "", true
+	}
+	return cluster.leader()
+}
+
+// ship sends one shipment: incremental, or full the first time, after a
+// reset or restore, or when the standby asks for one
+func (s *standbyShipper) ship() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	full := !s.shipped || s.generation != dataGeneration.Load()
+	since := s.since
+	if full {
+		since = 0
+	}
+	generation := dataGeneration.Load()
+
+	var buf bytes.Buffer
+	version, err := s.source.Backup(&buf, since)
+	if err != nil {
+		return s.fail(fmt.Errorf("backup: %w", err))
+	}
+
+	query := fmt.Sprintf("?since=%d&full=%t", since, full)
+	req, err := http.NewRequest(http.MethodPost, s.target+"/api/standby/receive"+query, bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		return s.fail(err)
+	}
+	req.Header.Set("Content-Type", "application/octet-stream")
+	req.Header.Set(standbyPrimaryHeader, s.id)
+	req.Header.Set(standbyVersionHeader, strconv.FormatUint(version, 10))
+	// The receive endpoint is an admin endpoint; both sides share ADMIN_TOKEN
+	if token := secret("ADMIN_TOKEN"); token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	setInternalKey(req)
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return s.fail(err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+	if resp.StatusCode == http.StatusConflict {
+		s.shipped = false // out of sync or promoted: full next time
+	}
+	if resp.StatusCode != http.StatusOK {
+		return s.fail(fmt.Errorf("standby answered %d: %s", resp.StatusCode, strings.TrimSpace(string(body))))
+	}
+
+	var answer StandbyShipment
+	if err := json.Unmarshal(body, &answer); err != nil {
+		return s.fail(fmt.Errorf("standby answer: %w", err))
+	}
+	standbyShipmentsTotal.WithLabelValues("ok").Inc()
+	s.since = answer.NextSince
+	s.generation = generation
+	s.shipped = true
+	s.lastShippedAt = time.Now().UTC()
+	s.lastBytes = int64(buf.Len())
+	s.lastError = ""
+	s.shipments++
+	slog.Debug("standby shipment sent", "standby", s.target, "full", full, "bytes", buf.Len(), "version", version)
+	return nil
+}
+
+// fail records a failed shipment; s.mu is held
+func (s *standbyShipper) fail(err error) error {
+	standbyShipmentsTotal.WithLabelValues("error").Inc()
+	s.failures++
+	s.lastError = err.Error()
+	return err
+}
+
+// =============================================================================
+// Handlers and Middleware
+// =============================================================================
+
+// standbyMiddleware answers writes with 503 on a standby that hasn't been
+// promoted. It refuses what maintenance mode refuses (maintenance.go), so
+// admin endpoints, shipments and promotion included, still work.
+func standbyMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !isStandby() || !maintenanceBlocks(r) || r.URL.Path == "/api/standby/receive" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		http.Error(w, `{"error":"this is a standby; promote it (POST /api/admin/promote) before writing"}`, http.StatusServiceUnavailable)
+	})
+}
+
+// standbyReceiveHandler handles POST /api/standby/receive
+func standbyReceiveHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	if r.Method != http.MethodPost {
+		http.Error(w, `{"error":"method not allowed"}`, http.StatusMethodNotAllowed)
+		return
+	}
+	if !standbyMode {
+		http.Error(w, `{"error":"not a standby (STANDBY_MODE is off)"}`, http.StatusNotFound)
+		return
+	}
+	query := r.URL.Query()
+	since, err := strconv.ParseUint(query.Get("since"), 10, 64)
+	if err != nil {
+		http.Error(w, `{"error":"since must be a version"}`, http.StatusBadRequest)
+		return
+	}
+	version, err := strconv.ParseUint(r.Header.Get(standbyVersionHeader), 10, 64)
+	primary := r.Header.Get(standbyPrimaryHeader)
+	if err != nil || primary == "" {
+		http.Error(w, `{"error":"X-Standby-Primary and X-Standby-Version are required"}`, http.StatusBadRequest)
+		return
+	}
+	full := query.Get("full") == "true"
+
+	shipment, err := standby.receive(db, r.Body, primary, since, version, full)
+	switch {
+	case err == errStandbyPromoted || err == errStandbyOutOfSync:
+		writeJSONError(w, http.StatusConflict, err.Error())
+		return
+	case err != nil:
+		logHandlerError(r.Context(), "standby", "database", "failed to load shipment", "error", err)
+		http.Error(w, `{"error":"database error"}`, http.StatusInternalServerError)
+		return
+	}
+	if err := syncItemsGauge(); err != nil {
+		slog.Warn("failed to count items after standby shipment", "error", err)
+	}
+	json.NewEncoder(w).Encode(shipment)
+}
+
+// promoteAdminHandler handles POST /api/admin/promote
+func promoteAdminHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	if r.Method != http.MethodPost {
+		http.Error(w, `{"error":"method not allowed"}`, http.StatusMethodNotAllowed)
+		return
+	}
+	result, err := standby.promote()
+	if err != nil {
+		writeJSONError(w, http.StatusConflict, err.Error())
+		return
+	}
+	slog.WarnContext(r.Context(), "standby promoted; now accepting writes", "applied_version", result["applied_version"])
+	json.NewEncoder(w).Encode(result)
+}
+
+// standbyStatusHandler handles GET /api/standby
+func standbyStatusHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	if r.Method != http.MethodGet {
+		http.Error(w, `{"error":"method not allowed"}`, http.StatusMethodNotAllowed)
+		return
+	}
+
+	status := map[string]any{"role": "none"}
+	if ship := standbyShip; ship != nil {
+		ship.mu.Lock()
+		status = map[string]any{
+			"role":            "primary",
+			"standby":         ship.target,
+			"shipments":       ship.shipments,
+			"failures":        ship.failures,
+			"next_since":      ship.since,
+			"last_shipped_at": nullTime(ship.lastShippedAt),
+			"last_bytes":      ship.lastBytes,
+			"last_error":      ship.lastError,
+		}
+		ship.mu.Unlock()
+	}
+	if standbyMode {
+		standby.mu.Lock()
+		role := "standby"
+		if standby.promoted {
+			role = "promoted"
+		}
+		status = map[string]any{
+			"role":             role,
+			"primary":          standby.primary,
+			"shipments":        standby.shipments,
+			"applied_version":  standby.appliedVersion,
+			"last_received_at": nullTime(standby.lastReceivedAt),
+			"promoted_at":      nullTime(standby.promotedAt),
+		}
+		standby.mu.Unlock()
+	}
+	json.NewEncoder(w).Encode(status)
+}
+
+// nullTime is t, or nil (JSON null) when it's zero
+func nullTime(t time.Time) any {
+	if t.IsZero() {
+		return nil
+	}
+	return t
+}
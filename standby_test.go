@@ -0,0 +1,122 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	badger "github.com/dgraph-io/badger/v4"
+)
+
+// withStandby makes the test server's database an unpromoted standby and
+// returns a server with the standby middleware in front
+func withStandby(t *testing.T, srv *httptest.Server) *httptest.Server {
+	t.Helper()
+	standbyMode, standby = true, &standbyState{}
+	t.Cleanup(func() { standbyMode, standby = false, &standbyState{} })
+	front := httptest.NewServer(standbyMiddleware(srv.Config.Handler))
+	t.Cleanup(front.Close)
+	return front
+}
+
+// putItem stores an item straight into a primary's database
+func putItem(t *testing.T, primary *badger.DB, item Item) {
+	t.Helper()
+	val, err := encodeItem(item)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := primary.Update(func(txn *badger.Txn) error { return txn.Set(itemKey(item.ID), val) }); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestStandby_ShipAndPromote(t *testing.T) {
+	front := withStandby(t, newTestServer(t))
+
+	primary, err := initStore(":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer primary.Close()
+	// Like main, the primary leases item IDs, so its backups carry the sequence
+	primarySeq, err := primary.GetSequence([]byte("seq:items"), 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer primarySeq.Release()
+	putItem(t, primary, Item{ID: 41, Name: "one", Version: 1})
+	putItem(t, primary, Item{ID: 42, Name: "two", Version: 1})
+
+	ship := newStandbyShipper(front.URL, primary)
+	if err := ship.ship(); err != nil {
+		t.Fatalf("first shipment: %v", err)
+	}
+	if keys := itemKeysIn(t, db); len(keys) != 2 {
+		t.Fatalf("standby has %v after first shipment, want 2 items", keys)
+	}
+
+	// The next one is incremental and carries the delete
+	if err := primary.Update(func(txn *badger.Txn) error { return txn.Delete(itemKey(41)) }); err != nil {
+		t.Fatal(err)
+	}
+	if err := ship.ship(); err != nil {
+		t.Fatalf("second shipment: %v", err)
+	}
+	if keys := itemKeysIn(t, db); len(keys) != 1 || keys[0] != string(itemKey(42)) {
+		t.Fatalf("standby has %v after incremental shipment, want item 42", keys)
+	}
+
+	// Reads work, writes wait for promotion
+	if code, _ := doRequest(t, front, http.MethodGet, "/api/items/42", ""); code != http.StatusOK {
+		t.Errorf("GET on standby = %d, want 200", code)
+	}
+	if code, _ := doRequest(t, front, http.MethodPost, "/api/items", `{"name":"early"}`); code != http.StatusServiceUnavailable {
+		t.Errorf("POST on standby = %d, want 503", code)
+	}
+
+	code, body := doRequest(t, front, http.MethodPost, "/api/admin/promote", "")
+	if code != http.StatusOK {
+		t.Fatalf("promote = %d %s", code, body)
+	}
+	created := createTestItem(t, front, `{"name":"after"}`)
+	if created.ID <= 42 {
+		t.Errorf("first ID after promotion = %d, want > 42", created.ID)
+	}
+
+	// A promoted standby is fenced off from its old primary
+	if err := ship.ship(); err == nil {
+		t.Error("shipment to a promoted standby should fail")
+	}
+	if code, _ := doRequest(t, front, http.MethodPost, "/api/admin/promote", ""); code != http.StatusConflict {
+		t.Errorf("second promote = %d, want 409", code)
+	}
+}
+
+func TestStandby_OutOfSyncShipment(t *testing.T) {
+	front := withStandby(t, newTestServer(t))
+
+	// An incremental shipment the standby has no base for
+	req, _ := http.NewRequest(http.MethodPost, front.URL+"/api/standby/receive?since=17&full=false", nil)
+	req.Header.Set(standbyPrimaryHeader, "elsewhere")
+	req.Header.Set(standbyVersionHeader, "20")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusConflict {
+		t.Errorf("out-of-sync shipment = %d, want 409", resp.StatusCode)
+	}
+
+	code, body := doRequest(t, front, http.MethodGet, "/api/standby", "")
+	var status map[string]any
+	if err := json.Unmarshal(body, &status); err != nil || code != http.StatusOK {
+		t.Fatalf("GET /api/standby = %d %s", code, body)
+	}
+	if status["role"] != "standby" || fmt.Sprint(status["shipments"]) != "0" {
+		t.Errorf("status = %s", body)
+	}
+}
@@ -0,0 +1,241 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"io/fs"
+	"mime"
+	"net/http"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// =============================================================================
+// Static Assets
+// =============================================================================
+//
+// The dashboard's files are embedded in the binary (main.go). Serving them
+// well matters on slow demo Wi-Fi, so instead of a plain http.FileServer:
+//
+//   - Fingerprinted names: every asset is also served as name.<hash>.ext,
+//     e.g. /static/app.3f9c2a1b.js, and index.html links to those. The hash
+//     changes whenever the file does, so these are cached for a year
+//     ("immutable") and a new release is still picked up at once.
+//   - ETag and Last-Modified: plain names (index.html, or app.js from an
+//     old bookmark) are sent with "Cache-Control: no-cache", so browsers
+//     revalidate and get a 304 Not Modified when nothing changed.
+//   - Pre-compressed gzip: "go generate" writes a .gz next to every CSS and
+//     JS file (scripts/gzipstatic), and clients sending
+//     "Accept-Encoding: gzip" get those bytes as they are, with no
+//     compression work per request.
+//
+//	curl -sI http://localhost:8080/static/index.html
+//	curl -s http://localhost:8080/static/index.html | grep app.
+//	curl -sI -H 'Accept-Encoding: gzip' http://localhost:8080/static/app.3f9c2a1b.js
+//
+// index.html is rewritten to point at the fingerprinted names, so its
+// gzip copy is made at startup instead (it's 2 KB).
+
+//go:generate go run ./scripts/gzipstatic static
+
+// How long fingerprinted assets may be cached: a year, the usual maximum
+const staticImmutableMaxAge = "public, max-age=31536000, immutable"
+
+// staticAsset is one file ready to serve
+type staticAsset struct {
+	name    string // used for the Content-Type
+	content []byte
+	gzipped []byte // nil when there's no compressed copy
+	etag    string
+}
+
+// staticRoute is what one path under /static/ serves
+type staticRoute struct {
+	asset     *staticAsset
+	immutable bool // fingerprinted name, cache for a year
+}
+
+// staticAssets serves the embedded files
+type staticAssets struct {
+	routes  map[string]staticRoute
+	modTime time.Time
+}
+
+// loadStaticAssets reads every file in fsys (the static directory),
+// fingerprints them, and rewrites the HTML to use the fingerprinted names
+func loadStaticAssets(fsys fs.FS) (*staticAssets, error) {
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, err
+	}
+
+	assets := &staticAssets{routes: map[string]staticRoute{}, modTime: staticModTime()}
+	fingerprints := map[string]string{} // "app.js" -> "app.3f9c2a1b.js"
+	var pages []*staticAsset
+
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || strings.HasSuffix(name, ".gz") {
+			continue
+		}
+		content, err := fs.ReadFile(fsys, name)
+		if err != nil {
+			return nil, err
+		}
+		asset := &staticAsset{name: name, content: content}
+		assets.routes[name] = staticRoute{asset: asset}
+
+		if strings.HasSuffix(name, ".html") {
+			pages = append(pages, asset) // rewritten below, once every fingerprint is known
+			continue
+		}
+		// The .gz from go generate, if there is one (static_test.go checks it's current)
+		if gzipped, err := fs.ReadFile(fsys, name+".gz"); err == nil {
+			asset.gzipped = gzipped
+		}
+		asset.etag = contentETag(content)
+
+		ext := path.Ext(name)
+		fingerprinted := strings.TrimSuffix(name, ext) + "." + strings.Trim(asset.etag, `"`)[:8] + ext
+		fingerprints[name] = fingerprinted
+		assets.routes[fingerprinted] = staticRoute{asset: asset, immutable: true}
+	}
+
+	for _, page := range pages {
+		for name, fingerprinted := range fingerprints {
+			page.content = bytes.ReplaceAll(page.content, []byte(`"/static/`+name+`"`), []byte(`"/static/`+fingerprinted+`"`))
+		}
+		page.etag = contentETag(page.content)
+		page.gzipped, err = gzipBytes(page.content)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return assets, nil
+}
+
+// ServeHTTP serves one asset; the path has "/static/" stripped already
+func (s *staticAssets) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.serve(w, r, strings.TrimPrefix(r.URL.Path, "/"))
+}
+
+// serve writes the asset with this name, or a 404
+func (s *staticAssets) serve(w http.ResponseWriter, r *http.Request, name string) {
+	route, ok := s.routes[name]
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	asset := route.asset
+
+	if route.immutable {
+		w.Header().Set("Cache-Control", staticImmutableMaxAge)
+	} else {
+		w.Header().Set("Cache-Control", "no-cache")
+	}
+	// Caches must keep the gzip and plain copies apart
+	w.Header().Set("Vary", "Accept-Encoding")
+	contentType := mime.TypeByExtension(path.Ext(asset.name))
+	if contentType == "" {
+		contentType = http.DetectContentType(asset.content)
+	}
+	w.Header().Set("Content-Type", contentType)
+
+	content, etag := asset.content, asset.etag
+	if asset.gzipped != nil && acceptsGzip(r) {
+		// A different encoding is a different representation, so a different ETag
+		content, etag = asset.gzipped, strings.TrimSuffix(etag, `"`)+`-gzip"`
+		w.Header().Set("Content-Encoding", "gzip")
+	}
+	w.Header().Set("ETag", etag)
+
+	// ServeContent answers If-None-Match / If-Modified-Since with 304,
+	// handles HEAD and Range, and sets Last-Modified
+	http.ServeContent(w, r, asset.name, s.modTime, bytes.NewReader(content))
+}
+
+// contentETag is a strong ETag from the content's SHA-256
+func contentETag(content []byte) string {
+	sum := sha256.Sum256(content)
+	return `"` + hex.EncodeToString(sum[:8]) + `"`
+}
+
+// gzipBytes compresses data in memory
+func gzipBytes(data []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	zw, err := gzip.NewWriterLevel(&buf, gzip.BestCompression)
+	if err != nil {
+		return nil, err
+	}
+	zw.Write(data)
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// acceptsGzip reports whether the client takes gzip ("gzip;q=0" means no)
+func acceptsGzip(r *http.Request) bool {
+	for _, part := range strings.Split(r.Header.Get("Accept-Encoding"), ",") {
+		coding, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		if !strings.EqualFold(strings.TrimSpace(coding), "gzip") {
+			continue
+		}
+		if q, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			weight, err := strconv.ParseFloat(q, 64)
+			return err == nil && weight > 0
+		}
+		return true
+	}
+	return false
+}
+
+// staticModTime is the Last-Modified for every asset. Embedded files have no
+// modification time, so use the binary's: it changes when they can.
+func staticModTime() time.Time {
+	if exe, err := os.Executable(); err == nil {
+		if info, err := os.Stat(exe); err == nil {
+			return info.ModTime().UTC()
+		}
+	}
+	return startTime.UTC()
+}
+
+// =============================================================================
+// Dashboard Deep Links
+// =============================================================================
+//
+// A single-page app routes on the client: /items/42 is a view inside
+// index.html, not a file. Opened directly (a bookmark, a shared link, a
+// reload), that path reaches the server, which has nothing there. With
+// SPA_FALLBACK on (the default) the server answers it with index.html and
+// the dashboard's own code takes over:
+//
+//	curl -H 'Accept: text/html' http://localhost:8080/items/42   # index.html
+//	curl http://localhost:8080/items/42                          # 404
+//
+// Only browser page loads get the fallback: GET or HEAD, "text/html" in
+// Accept, and not under /api/ or /static/ or a name with an extension like
+// /favicon.ico. Scripts and API clients still see a real 404.
+
+// spaFallback enables the fallback (SPA_FALLBACK, set in main)
+var spaFallback = true
+
+// wantsDashboard reports whether r is a page load the fallback should answer
+func wantsDashboard(r *http.Request) bool {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		return false
+	}
+	if !strings.Contains(r.Header.Get("Accept"), "text/html") {
+		return false
+	}
+	if strings.HasPrefix(r.URL.Path, "/api/") || strings.HasPrefix(r.URL.Path, "/static/") {
+		return false
+	}
+	return path.Ext(r.URL.Path) == ""
+}
@@ -24,6 +24,50 @@ async function fetchSystem() {
     }
 }
 
+async function fetchKubernetes() {
+    try {
+        const response = await fetch('/api/system/kubernetes');
+        return await response.json();
+    } catch (error) {
+        console.error('Failed to fetch kubernetes info:', error);
+        return null;
+    }
+}
+
+async function fetchVariant() {
+    try {
+        const response = await fetch('/api/variant');
+        return await response.json();
+    } catch (error) {
+        console.error('Failed to fetch variant:', error);
+        return null;
+    }
+}
+
+async function fetchBranding() {
+    try {
+        const response = await fetch('/api/branding');
+        return await response.json();
+    } catch (error) {
+        console.error('Failed to fetch branding:', error);
+        return null;
+    }
+}
+
+// ?lang=xx in the page URL picks a language; otherwise the server goes by
+// the browser's Accept-Language header
+async function fetchTranslations() {
+    const lang = new URLSearchParams(window.location.search).get('lang');
+    try {
+        const response = await fetch(lang ? `/api/i18n/${encodeURIComponent(lang)}` : '/api/i18n');
+        if (!response.ok) return null;
+        return await response.json();
+    } catch (error) {
+        console.error('Failed to fetch translations:', error);
+        return null;
+    }
+}
+
 async function fetchItems() {
     try {
         const response = await fetch('/api/items');
@@ -34,20 +78,20 @@ async function fetchItems() {
     }
 }
 
-async function createItem(name, description) {
+async function createItem(name, description, tags, category) {
     const response = await fetch('/api/items', {
         method: 'POST',
         headers: { 'Content-Type': 'application/json' },
-        body: JSON.stringify({ name, description })
+        body: JSON.stringify({ name, description, tags, category })
     });
     return await response.json();
 }
 
-async function updateItem(id, name, description) {
+async function updateItem(id, name, description, tags, category) {
     const response = await fetch(`/api/items/${id}`, {
         method: 'PUT',
         headers: { 'Content-Type': 'application/json' },
-        body: JSON.stringify({ name, description })
+        body: JSON.stringify({ name, description, tags, category })
     });
     return await response.json();
 }
@@ -75,6 +119,43 @@ async function updateDisplay(data) {
     return await response.json();
 }
 
+async function fetchClickCounter() {
+    try {
+        const response = await fetch('/api/counters');
+        const counters = await response.json();
+        return counters.find(c => c.name === 'clicks') || null;
+    } catch (error) {
+        console.error('Failed to fetch counters:', error);
+        return null;
+    }
+}
+
+async function fetchRecentRequests() {
+    try {
+        const response = await fetch('/api/requests/recent?limit=15');
+        return await response.json();
+    } catch (error) {
+        console.error('Failed to fetch recent requests:', error);
+        return null;
+    }
+}
+
+async function fetchTimeseries(range) {
+    try {
+        const response = await fetch(`/api/items/timeseries?window=${range.window}&step=${range.step}`);
+        if (!response.ok) return null;
+        return await response.json();
+    } catch (error) {
+        console.error('Failed to fetch item time series:', error);
+        return null;
+    }
+}
+
+async function incrementClickCounter() {
+    const response = await fetch('/api/counters/clicks/increment', { method: 'POST' });
+    return await response.json();
+}
+
 // =============================================================================
 // Render Functions
 // =============================================================================
@@ -86,7 +167,7 @@ function renderHealth(data) {
         container.innerHTML = `
             <div class="status">
                 <span class="status-indicator error"></span>
-                <span>Unable to connect</span>
+                <span>${escapeHtml(t('health.unreachable'))}</span>
             </div>
         `;
         return;
@@ -101,50 +182,90 @@ function renderHealth(data) {
     `;
 }
 
-function renderSystem(data) {
+function renderSystem(data, k8s) {
     const container = document.getElementById('system-content');
 
     if (!data) {
-        container.innerHTML = '<div class="empty-state">Unable to load system info</div>';
+        container.innerHTML = `<div class="empty-state">${escapeHtml(t('system.unavailable'))}</div>`;
         return;
     }
 
     const ips = data.ips && data.ips.length > 0
         ? data.ips.join(', ')
-        : 'none detected';
+        : t('system.ips_none');
 
     const envVars = data.environment && Object.keys(data.environment).length > 0
         ? Object.entries(data.environment)
-            .map(([k, v]) => `<div class="info-row"><span class="info-label">${k}</span><span class="info-value">${v}</span></div>`)
+            .map(([k, v]) => `<div class="info-row"><span class="info-label">${escapeHtml(k)}</span><span class="info-value">${escapeHtml(v)}</span></div>`)
+            .join('')
+        : '';
+
+    // Only shown when running in a cluster
+    const k8sRows = k8s && k8s.in_cluster
+        ? [
+            [t('system.namespace'), k8s.namespace],
+            [t('system.pod'), k8s.pod.name],
+            [t('system.node'), k8s.pod.node_name],
+        ]
+            .filter(([, v]) => v)
+            .map(([k, v]) => `<div class="info-row"><span class="info-label">${escapeHtml(k)}</span><span class="info-value">${escapeHtml(v)}</span></div>`)
             .join('')
         : '';
 
     container.innerHTML = `
         <div class="info-row">
-            <span class="info-label">Hostname</span>
-            <span class="info-value">${data.hostname || 'unknown'}</span>
+            <span class="info-label">${escapeHtml(t('system.hostname'))}</span>
+            <span class="info-value">${escapeHtml(data.hostname || t('common.unknown'))}</span>
         </div>
         <div class="info-row">
-            <span class="info-label">IPs</span>
-            <span class="info-value">${ips}</span>
+            <span class="info-label">${escapeHtml(t('system.ips'))}</span>
+            <span class="info-value">${escapeHtml(ips)}</span>
         </div>
         <div class="info-row">
-            <span class="info-label">Client IP</span>
-            <span class="info-value">${data.client_ip || 'unknown'}</span>
+            <span class="info-label">${escapeHtml(t('system.client_ip'))}</span>
+            <span class="info-value">${escapeHtml(data.client_ip || t('common.unknown'))}</span>
         </div>
         <div class="info-row">
-            <span class="info-label">User Agent</span>
-            <span class="info-value">${escapeHtml(data.user_agent || 'unknown')}</span>
+            <span class="info-label">${escapeHtml(t('system.user_agent'))}</span>
+            <span class="info-value">${escapeHtml(data.user_agent || t('common.unknown'))}</span>
         </div>
+        ${k8sRows}
         ${envVars}
     `;
 }
 
+// Show a badge when a VARIANT is set (its color arrives with the branding)
+function renderVariant(data) {
+    if (!data) return;
+    const badge = document.getElementById('variant-badge');
+    badge.hidden = !data.variant;
+    badge.textContent = data.variant || '';
+}
+
+// Apply the title, logo, color, and footer from PUT /api/admin/branding.
+// primary_color falls back to the VARIANT color on the server.
+function renderBranding(data) {
+    if (!data) return;
+    document.documentElement.style.setProperty('--accent', data.primary_color);
+    document.getElementById('brand-title').textContent = data.title;
+    document.title = data.title;
+
+    const logo = document.getElementById('brand-logo');
+    logo.hidden = !data.logo_url;
+    if (data.logo_url && logo.getAttribute('src') !== data.logo_url) {
+        logo.src = data.logo_url;
+    }
+
+    const footer = document.getElementById('brand-footer');
+    footer.hidden = !data.footer_text;
+    footer.textContent = data.footer_text || '';
+}
+
 function renderItems(items) {
     const container = document.getElementById('items-content');
 
     if (!items || items.length === 0) {
-        container.innerHTML = '<div class="empty-state">No items yet. Click "+ New Item" to create one.</div>';
+        container.innerHTML = `<div class="empty-state">${escapeHtml(t('items.empty'))}</div>`;
         return;
     }
 
@@ -153,12 +274,14 @@ function renderItems(items) {
             ${items.map(item => `
                 <li class="item-row" data-id="${item.id}">
                     <div class="item-info">
+                        ${item.category ? `<div class="item-category">${escapeHtml(item.category)}</div>` : ''}
                         <div class="item-name">${escapeHtml(item.name)}</div>
                         ${item.description ? `<div class="item-description">${escapeHtml(item.description)}</div>` : ''}
+                        ${item.tags && item.tags.length > 0 ? `<div class="item-tags">${item.tags.map(tag => `<span class="tag">${escapeHtml(tag)}</span>`).join('')}</div>` : ''}
                     </div>
                     <div class="item-actions">
-                        <button class="secondary edit-btn" data-id="${item.id}">Edit</button>
-                        <button class="danger delete-btn" data-id="${item.id}">Delete</button>
+                        <button class="secondary edit-btn" data-id="${item.id}">${escapeHtml(t('button.edit'))}</button>
+                        <button class="danger delete-btn" data-id="${item.id}">${escapeHtml(t('button.delete'))}</button>
                     </div>
                 </li>
             `).join('')}
@@ -179,13 +302,90 @@ function renderDisplay(data) {
     const container = document.getElementById('display-content');
 
     if (!data || Object.keys(data).length === 0) {
-        container.innerHTML = '<div class="empty-state">No display data. Click "Update Display Data" to add some.</div>';
+        container.innerHTML = `<div class="empty-state">${escapeHtml(t('display.empty'))}</div>`;
         return;
     }
 
     container.innerHTML = `<pre>${escapeHtml(JSON.stringify(data, null, 2))}</pre>`;
 }
 
+// Shared count (all instances) next to the count this instance served —
+// reload behind a load balancer and watch the instance number change
+function renderCounter(counter) {
+    const container = document.getElementById('counter-content');
+    const shared = counter ? counter.value : 0;
+    const instance = counter ? counter.instance_value : 0;
+
+    container.innerHTML = `
+        <div class="counter-value">${shared}</div>
+        <div class="counter-detail">${escapeHtml(t('counter.on_instance', { count: instance }))}${counter && counter.instance ? ` (${escapeHtml(counter.instance)})` : ''}</div>
+    `;
+}
+
+// Latest requests on whichever instance answered the poll — behind a load
+// balancer the instance column changes from one refresh to the next
+function renderTraffic(requests) {
+    const container = document.getElementById('traffic-content');
+
+    if (!requests) {
+        container.innerHTML = `<div class="empty-state">${escapeHtml(t('traffic.unavailable'))}</div>`;
+        return;
+    }
+    if (requests.length === 0) {
+        container.innerHTML = `<div class="empty-state">${escapeHtml(t('traffic.empty'))}</div>`;
+        return;
+    }
+
+    container.innerHTML = `
+        <table class="traffic-table">
+            <thead>
+                <tr>${['time', 'request', 'status', 'latency', 'client', 'instance'].map(col => `<th>${escapeHtml(t('traffic.' + col))}</th>`).join('')}</tr>
+            </thead>
+            <tbody>
+                ${requests.map(req => `
+                    <tr>
+                        <td>${new Date(req.time).toLocaleTimeString()}</td>
+                        <td>${escapeHtml(req.method)} ${escapeHtml(req.path)}</td>
+                        <td class="${req.status >= 500 ? 'status-error' : req.status >= 400 ? 'status-warn' : ''}">${req.status}</td>
+                        <td>${req.latency_ms.toFixed(1)} ms</td>
+                        <td>${escapeHtml(req.client)}</td>
+                        <td>${escapeHtml(req.instance)}</td>
+                    </tr>
+                `).join('')}
+            </tbody>
+        </table>
+    `;
+}
+
+// Creations per step as an SVG bar chart, oldest on the left. Bars are
+// scaled to the busiest step, which the tooltip (hover) shows exactly.
+function renderTimeseries(series) {
+    const container = document.getElementById('creations-content');
+
+    if (!series) {
+        container.innerHTML = `<div class="empty-state">${escapeHtml(t('creations.unavailable'))}</div>`;
+        return;
+    }
+
+    const max = Math.max(1, ...series.points.map(p => p.count));
+    const width = 100 / series.points.length;
+    const bars = series.points.map((p, i) => {
+        const height = (p.count / max) * 100;
+        return `<rect x="${i * width}" y="${100 - height}" width="${width * 0.8}" height="${height}">
+            <title>${new Date(p.time).toLocaleString()}: ${p.count}</title>
+        </rect>`;
+    }).join('');
+
+    container.innerHTML = `
+        <div class="creations-total">${escapeHtml(t('creations.total', { count: series.total }))}</div>
+        <svg class="creations-chart" viewBox="0 0 100 100" preserveAspectRatio="none">${bars}</svg>
+        <div class="creations-axis">
+            <span>${new Date(series.start).toLocaleTimeString()}</span>
+            <span>${new Date(series.end).toLocaleTimeString()}</span>
+        </div>
+    `;
+}
+
 // =============================================================================
 // Modal Functions
 // =============================================================================
@@ -194,30 +394,35 @@ function showModal(title, fields, onSave) {
     const overlay = document.createElement('div');
     overlay.className = 'modal-overlay';
 
+    // Labels are translations (admins can override them) and values are
+    // item data: escape both, and set values through the DOM below
     const fieldHtml = fields.map(field => {
         if (field.type === 'textarea') {
             return `
-                <label for="${field.name}">${field.label}</label>
-                <textarea id="${field.name}" name="${field.name}">${field.value || ''}</textarea>
+                <label for="${field.name}">${escapeHtml(field.label)}</label>
+                <textarea id="${field.name}" name="${field.name}"></textarea>
             `;
         }
         return `
-            <label for="${field.name}">${field.label}</label>
-            <input type="text" id="${field.name}" name="${field.name}" value="${field.value || ''}">
+            <label for="${field.name}">${escapeHtml(field.label)}</label>
+            <input type="text" id="${field.name}" name="${field.name}">
         `;
     }).join('');
 
     overlay.innerHTML = `
         <div class="modal">
-            <h3>${title}</h3>
+            <h3>${escapeHtml(title)}</h3>
             ${fieldHtml}
             <div class="modal-actions">
-                <button class="secondary cancel-btn">Cancel</button>
-                <button class="save-btn">Save</button>
+                <button class="secondary cancel-btn">${escapeHtml(t('button.cancel'))}</button>
+                <button class="save-btn">${escapeHtml(t('button.save'))}</button>
             </div>
         </div>
     `;
 
+    fields.forEach(field => {
+        overlay.querySelector(`#${field.name}`).value = field.value || '';
+    });
     document.body.appendChild(overlay);
 
     // Focus first input
@@ -249,15 +454,18 @@ function showModal(title, fields, onSave) {
 // =============================================================================
 
 async function handleAddItem() {
-    showModal('New Item', [
-        { name: 'name', label: 'Name', type: 'text' },
-        { name: 'description', label: 'Description', type: 'text' }
+    showModal(t('items.new_title'), [
+        { name: 'name', label: t('field.name'), type: 'text' },
+        { name: 'description', label: t('field.description'), type: 'text' },
+        { name: 'tags', label: t('field.tags'), type: 'text' },
+        { name: 'category', label: t('field.category'), type: 'text' }
     ], async (values) => {
         if (!values.name.trim()) {
-            alert('Name is required');
+            alert(t('items.name_required'));
             return;
         }
-        await createItem(values.name, values.description);
+        const result = await createItem(values.name, values.description, parseTags(values.tags), values.category);
+        showApiError(result);
         await refreshItems();
     });
 }
@@ -266,21 +474,24 @@ async function handleEditItem(id, items) {
     const item = items.find(i => i.id == id);
     if (!item) return;
 
-    showModal('Edit Item', [
-        { name: 'name', label: 'Name', type: 'text', value: item.name },
-        { name: 'description', label: 'Description', type: 'text', value: item.description || '' }
+    showModal(t('items.edit_title'), [
+        { name: 'name', label: t('field.name'), type: 'text', value: item.name },
+        { name: 'description', label: t('field.description'), type: 'text', value: item.description || '' },
+        { name: 'tags', label: t('field.tags'), type: 'text', value: (item.tags || []).join(', ') },
+        { name: 'category', label: t('field.category'), type: 'text', value: item.category || '' }
     ], async (values) => {
         if (!values.name.trim()) {
-            alert('Name is required');
+            alert(t('items.name_required'));
             return;
         }
-        await updateItem(id, values.name, values.description);
+        const result = await updateItem(id, values.name, values.description, parseTags(values.tags), values.category);
+        showApiError(result);
         await refreshItems();
     });
 }
 
 async function handleDeleteItem(id) {
-    if (!confirm('Delete this item?')) return;
+    if (!confirm(t('items.delete_confirm'))) return;
     await deleteItem(id);
     await refreshItems();
 }
@@ -291,15 +502,15 @@ async function handleUpdateDisplay() {
         ? JSON.stringify(currentData, null, 2)
         : '{\n  \n}';
 
-    showModal('Update Display Data', [
-        { name: 'json', label: 'JSON Data', type: 'textarea', value: currentJson }
+    showModal(t('button.update_display'), [
+        { name: 'json', label: t('display.json'), type: 'textarea', value: currentJson }
     ], async (values) => {
         try {
             const data = JSON.parse(values.json);
             await updateDisplay(data);
             await refreshDisplay();
         } catch (e) {
-            alert('Invalid JSON: ' + e.message);
+            alert(t('display.invalid_json', { error: e.message }));
         }
     });
 }
@@ -314,8 +525,8 @@ async function refreshHealth() {
 }
 
 async function refreshSystem() {
-    const data = await fetchSystem();
-    renderSystem(data);
+    const [data, k8s] = await Promise.all([fetchSystem(), fetchKubernetes()]);
+    renderSystem(data, k8s);
 }
 
 async function refreshItems() {
@@ -328,19 +539,108 @@ async function refreshDisplay() {
     renderDisplay(data);
 }
 
+async function refreshCounter() {
+    const counter = await fetchClickCounter();
+    renderCounter(counter);
+}
+
+async function refreshTraffic() {
+    const requests = await fetchRecentRequests();
+    renderTraffic(requests);
+}
+
+// The range the chart shows; the buttons in the panel switch it
+let timeseriesRange = { window: '1h', step: '1m' };
+
+async function refreshTimeseries() {
+    const series = await fetchTimeseries(timeseriesRange);
+    renderTimeseries(series);
+}
+
+function handleRangeClick(event) {
+    const button = event.currentTarget;
+    document.querySelectorAll('.range-btn').forEach(b => b.classList.toggle('active', b === button));
+    timeseriesRange = { window: button.dataset.window, step: button.dataset.step };
+    refreshTimeseries();
+}
+
+async function handleClick() {
+    const counter = await incrementClickCounter();
+    if (counter.error) {
+        showApiError(counter);
+        return;
+    }
+    renderCounter(counter);
+}
+
+async function refreshVariant() {
+    const data = await fetchVariant();
+    renderVariant(data);
+}
+
+async function refreshBranding() {
+    const data = await fetchBranding();
+    renderBranding(data);
+}
+
 async function refreshAll() {
     await Promise.all([
+        refreshBranding(),
+        refreshVariant(),
         refreshHealth(),
         refreshSystem(),
         refreshItems(),
-        refreshDisplay()
+        refreshDisplay(),
+        refreshCounter(),
+        refreshTraffic(),
+        refreshTimeseries()
     ]);
 }
 
+// =============================================================================
+// Translations
+// =============================================================================
+
+// UI strings from /api/i18n (i18n.go), keyed like "panel.items"
+let translations = {};
+
+// Look up a string and fill in {placeholders}: t('counter.on_instance', { count: 3 }).
+// Shows the key itself if the strings failed to load.
+function t(key, params = {}) {
+    const text = translations[key] || key;
+    return text.replace(/\{(\w+)\}/g, (match, name) => name in params ? params[name] : match);
+}
+
+// Load the strings and translate the page's static text (data-i18n elements)
+async function loadTranslations() {
+    const data = await fetchTranslations();
+    if (!data) return;
+    translations = data.strings;
+    document.documentElement.lang = data.lang;
+    document.querySelectorAll('[data-i18n]').forEach(el => {
+        el.textContent = t(el.dataset.i18n);
+    });
+}
+
 // =============================================================================
 // Utilities
 // =============================================================================
 
+// Split "a, b,,c" into ["a", "b", "c"]
+function parseTags(text) {
+    return (text || '').split(',').map(t => t.trim()).filter(t => t !== '');
+}
+
+// Alert the user if an API response is an error, including per-field
+// validation details when the server sends them (422 responses)
+function showApiError(result) {
+    if (!result || !result.error) return;
+    const details = (result.fields || [])
+        .map(f => `- ${f.field}: ${f.message}`)
+        .join('\n');
+    alert(details ? `${result.error}\n${details}` : result.error);
+}
+
 function escapeHtml(text) {
     const div = document.createElement('div');
     div.textContent = text;
@@ -351,14 +651,28 @@ function escapeHtml(text) {
 // Initialization
 // =============================================================================
 
-document.addEventListener('DOMContentLoaded', () => {
+document.addEventListener('DOMContentLoaded', async () => {
+    // Strings first, so panels render in the right language
+    await loadTranslations();
+
     // Initial load
     refreshAll();
 
     // Button event listeners
     document.getElementById('add-item-btn').addEventListener('click', handleAddItem);
     document.getElementById('update-display-btn').addEventListener('click', handleUpdateDisplay);
+    document.getElementById('click-btn').addEventListener('click', handleClick);
+    document.querySelectorAll('.range-btn').forEach(b => b.addEventListener('click', handleRangeClick));
 
     // Auto-refresh health every 10 seconds
     setInterval(refreshHealth, 10000);
+
+    // Branding can change mid-demo; pick it up without a reload
+    setInterval(refreshBranding, 10000);
+
+    // Traffic moves fast during load-balancing demos
+    setInterval(refreshTraffic, 2000);
+
+    // Watch the creation rate climb while a load test runs
+    setInterval(refreshTimeseries, 5000);
 });
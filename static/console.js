@@ -0,0 +1,217 @@
+// Demo App - API Console
+//
+// Builds a form for every operation in /api/openapi.json, sends the request
+// from the browser, and shows the response next to the equivalent curl
+// command, so workshop attendees can try the API without a terminal.
+
+// =============================================================================
+// Helpers
+// =============================================================================
+
+function escapeHtml(value) {
+    const div = document.createElement('div');
+    div.textContent = String(value);
+    return div.innerHTML;
+}
+
+// Quote a string for a POSIX shell
+function shellQuote(value) {
+    return `'${String(value).replace(/'/g, `'\\''`)}'`;
+}
+
+// The example for a parameter or request body, as form text
+function exampleText(example) {
+    if (example === undefined) return '';
+    return typeof example === 'string' ? example : JSON.stringify(example, null, 2);
+}
+
+// The first content type and example of a request body
+function requestBodyExample(operation) {
+    const content = operation.requestBody && operation.requestBody.content;
+    if (!content) return null;
+    const [contentType, media] = Object.entries(content)[0];
+    return { contentType, text: exampleText(media.example) };
+}
+
+// Headers every request gets, from the settings panel. The admin token is
+// kept for this browser tab only.
+function commonHeaders() {
+    const headers = {};
+    const token = document.getElementById('admin-token').value.trim();
+    const tenant = document.getElementById('tenant').value.trim();
+    if (token) headers['Authorization'] = `Bearer ${token}`;
+    if (tenant) headers['X-Tenant'] = tenant;
+    if (document.getElementById('debug-trace').checked) headers['X-Debug'] = '1';
+    return headers;
+}
+
+// =============================================================================
+// Rendering
+// =============================================================================
+
+// renderOperation returns the form for one method + path
+function renderOperation(path, method, operation, index) {
+    const params = (operation.parameters || []).map(param => `
+        <label for="param-${index}-${escapeHtml(param.name)}">${escapeHtml(param.name)}
+            <span class="param-in">${escapeHtml(param.in)}${param.required ? ', required' : ''}</span></label>
+        <input id="param-${index}-${escapeHtml(param.name)}" data-name="${escapeHtml(param.name)}" data-in="${escapeHtml(param.in)}"
+            value="${escapeHtml(exampleText(param.example))}" placeholder="${escapeHtml(param.description || '')}">
+    `).join('');
+
+    const body = requestBodyExample(operation);
+    const bodyField = body ? `
+        <label for="body-${index}">Body <span class="param-in">${escapeHtml(body.contentType)}</span></label>
+        <textarea id="body-${index}" data-content-type="${escapeHtml(body.contentType)}">${escapeHtml(body.text)}</textarea>
+    ` : '';
+
+    const badge = operation.security ? '<span class="op-admin">admin</span>' : '';
+    return `
+        <details class="operation" data-index="${index}" data-method="${method}" data-path="${escapeHtml(path)}">
+            <summary>
+                <span class="op-method op-${method}">${method.toUpperCase()}</span>
+                <code>${escapeHtml(path)}</code>
+                <span class="op-summary">${escapeHtml(operation.summary || '')}</span>${badge}
+            </summary>
+            <div class="op-form">
+                ${operation.description ? `<p class="op-description">${escapeHtml(operation.description)}</p>` : ''}
+                ${params}${bodyField}
+                <button class="send-btn">Send</button>
+                <div class="op-result" hidden>
+                    <div class="op-status"></div>
+                    <pre class="op-curl"></pre>
+                    <pre class="op-headers"></pre>
+                    <pre class="op-body"></pre>
+                </div>
+            </div>
+        </details>
+    `;
+}
+
+// renderSpec lists every operation, grouped by tag in the spec's order
+function renderSpec(spec) {
+    document.getElementById('api-title').textContent = `${spec.info.title} ${spec.info.version}`;
+    document.title = `API Console - ${spec.info.title}`;
+
+    const groups = new Map((spec.tags || []).map(tag => [tag.name, []]));
+    let index = 0;
+    for (const [path, methods] of Object.entries(spec.paths)) {
+        for (const [method, operation] of Object.entries(methods)) {
+            const tag = (operation.tags && operation.tags[0]) || 'Other';
+            if (!groups.has(tag)) groups.set(tag, []);
+            groups.get(tag).push(renderOperation(path, method, operation, index++));
+        }
+    }
+
+    document.getElementById('operations').innerHTML = [...groups.entries()]
+        .filter(([, operations]) => operations.length > 0)
+        .map(([tag, operations]) => `<h3 class="op-group">${escapeHtml(tag)}</h3>${operations.join('')}`)
+        .join('');
+}
+
+// =============================================================================
+// Sending
+// =============================================================================
+
+// buildRequest reads an operation's form into a URL, headers, and body
+function buildRequest(form) {
+    let path = form.dataset.path;
+    const query = new URLSearchParams();
+    const headers = commonHeaders();
+
+    form.querySelectorAll('input[data-name]').forEach(input => {
+        const value = input.value.trim();
+        if (value === '') return;
+        switch (input.dataset.in) {
+            case 'path':
+                // Key-value keys may contain slashes, so only encode the rest
+                path = path.replace(`{${input.dataset.name}}`, value.split('/').map(encodeURIComponent).join('/'));
+                break;
+            case 'query':
+                query.set(input.dataset.name, value);
+                break;
+            case 'header':
+                headers[input.dataset.name] = value;
+                break;
+        }
+    });
+
+    let body;
+    const textarea = form.querySelector('textarea');
+    if (textarea) {
+        body = textarea.value;
+        headers['Content-Type'] = textarea.dataset.contentType;
+    }
+    const search = query.toString();
+    return { method: form.dataset.method.toUpperCase(), url: path + (search ? `?${search}` : ''), headers, body };
+}
+
+// curlCommand is the same request for a terminal
+function curlCommand(request) {
+    const parts = ['curl -i'];
+    if (request.method !== 'GET') parts.push(`-X ${request.method}`);
+    for (const [name, value] of Object.entries(request.headers)) {
+        parts.push(`-H ${shellQuote(`${name}: ${value}`)}`);
+    }
+    if (request.body !== undefined) parts.push(`-d ${shellQuote(request.body)}`);
+    parts.push(shellQuote(window.location.origin + request.url));
+    return parts.join(' ');
+}
+
+// send runs an operation and shows what came back
+async function send(form) {
+    const request = buildRequest(form);
+    const result = form.querySelector('.op-result');
+    const status = result.querySelector('.op-status');
+    result.hidden = false;
+    result.querySelector('.op-curl').textContent = curlCommand(request);
+    result.querySelector('.op-headers').textContent = '';
+    result.querySelector('.op-body').textContent = '';
+    status.className = 'op-status';
+    status.textContent = 'Sending...';
+
+    const start = performance.now();
+    try {
+        const response = await fetch(request.url, { method: request.method, headers: request.headers, body: request.body });
+        const elapsed = Math.round(performance.now() - start);
+        let text = await response.text();
+        // Pretty-print JSON; anything else is shown as it came
+        if ((response.headers.get('Content-Type') || '').includes('json')) {
+            try { text = JSON.stringify(JSON.parse(text), null, 2); } catch { /* NDJSON or broken JSON */ }
+        }
+
+        status.textContent = `${response.status} ${response.statusText} in ${elapsed} ms`;
+        status.classList.add(response.ok ? 'status-ok' : 'status-error');
+        result.querySelector('.op-headers').textContent = [...response.headers.entries()]
+            .map(([name, value]) => `${name}: ${value}`)
+            .join('\n');
+        result.querySelector('.op-body').textContent = text || '(empty body)';
+    } catch (error) {
+        status.textContent = `Request failed: ${error.message}`;
+        status.classList.add('status-error');
+    }
+}
+
+// =============================================================================
+// Startup
+// =============================================================================
+
+document.addEventListener('DOMContentLoaded', async () => {
+    const token = document.getElementById('admin-token');
+    token.value = sessionStorage.getItem('adminToken') || '';
+    token.addEventListener('change', () => sessionStorage.setItem('adminToken', token.value));
+
+    try {
+        const response = await fetch('/api/openapi.json');
+        renderSpec(await response.json());
+    } catch (error) {
+        document.getElementById('operations').textContent = `Failed to load the API spec: ${error.message}`;
+        return;
+    }
+
+    // One listener for every Send button
+    document.getElementById('operations').addEventListener('click', event => {
+        if (event.target.classList.contains('send-btn')) {
+            send(event.target.closest('.operation'));
+        }
+    });
+});
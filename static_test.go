@@ -0,0 +1,142 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"io/fs"
+	"net/http"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+// staticGet fetches a path with extra headers and returns the response and body
+func staticGet(t *testing.T, url string, headers map[string]string) (*http.Response, []byte) {
+	t.Helper()
+	req, _ := http.NewRequest(http.MethodGet, url, nil)
+	for name, value := range headers {
+		req.Header.Set(name, value)
+	}
+	// A Transport with compression off, so we see Content-Encoding as sent
+	client := &http.Client{Transport: &http.Transport{DisableCompression: true}}
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	return resp, body
+}
+
+func TestStatic_FingerprintedAssets(t *testing.T) {
+	srv := newTestServer(t)
+
+	resp, page := staticGet(t, srv.URL+"/static/index.html", nil)
+	if resp.StatusCode != http.StatusOK || resp.Header.Get("Cache-Control") != "no-cache" || resp.Header.Get("ETag") == "" {
+		t.Fatalf("index.html: %d %v", resp.StatusCode, resp.Header)
+	}
+	script := regexp.MustCompile(`/static/app\.[0-9a-f]{8}\.js`).Find(page)
+	if script == nil || !strings.Contains(string(page), "/static/style.") {
+		t.Fatalf("index.html doesn't link fingerprinted assets:\n%s", page)
+	}
+
+	resp, body := staticGet(t, srv.URL+string(script), nil)
+	if resp.StatusCode != http.StatusOK || resp.Header.Get("Cache-Control") != staticImmutableMaxAge {
+		t.Errorf("%s: %d Cache-Control %q", script, resp.StatusCode, resp.Header.Get("Cache-Control"))
+	}
+	if !strings.HasPrefix(resp.Header.Get("Content-Type"), "text/javascript") || resp.Header.Get("Last-Modified") == "" {
+		t.Errorf("%s headers = %v", script, resp.Header)
+	}
+	// The plain name serves the same bytes, but must be revalidated
+	plain, plainBody := staticGet(t, srv.URL+"/static/app.js", nil)
+	if !bytes.Equal(body, plainBody) || plain.Header.Get("Cache-Control") != "no-cache" {
+		t.Errorf("/static/app.js: Cache-Control %q", plain.Header.Get("Cache-Control"))
+	}
+
+	if resp, _ := staticGet(t, srv.URL+"/static/app.00000000.js", nil); resp.StatusCode != http.StatusNotFound {
+		t.Errorf("unknown fingerprint: status = %d, want 404", resp.StatusCode)
+	}
+}
+
+func TestStatic_ConditionalAndGzip(t *testing.T) {
+	srv := newTestServer(t)
+
+	resp, plain := staticGet(t, srv.URL+"/static/style.css", nil)
+	etag := resp.Header.Get("ETag")
+	if resp, _ := staticGet(t, srv.URL+"/static/style.css", map[string]string{"If-None-Match": etag}); resp.StatusCode != http.StatusNotModified {
+		t.Errorf("If-None-Match: status = %d, want 304", resp.StatusCode)
+	}
+
+	resp, compressed := staticGet(t, srv.URL+"/static/style.css", map[string]string{"Accept-Encoding": "gzip, deflate"})
+	if resp.Header.Get("Content-Encoding") != "gzip" || resp.Header.Get("ETag") == etag || resp.Header.Get("Vary") != "Accept-Encoding" {
+		t.Fatalf("gzip headers = %v", resp.Header)
+	}
+	zr, err := gzip.NewReader(bytes.NewReader(compressed))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if unzipped, _ := io.ReadAll(zr); !bytes.Equal(unzipped, plain) {
+		t.Error("gzip body doesn't match the plain file")
+	}
+
+	if resp, _ := staticGet(t, srv.URL+"/static/style.css", map[string]string{"Accept-Encoding": "gzip;q=0"}); resp.Header.Get("Content-Encoding") != "" {
+		t.Error("gzip;q=0 still got gzip")
+	}
+}
+
+// The .gz files are generated; this fails when one is missing or stale
+func TestStatic_GzipFilesUpToDate(t *testing.T) {
+	staticFS, _ := fs.Sub(staticFiles, "static")
+	entries, _ := fs.ReadDir(staticFS, ".")
+	for _, entry := range entries {
+		name := entry.Name()
+		if strings.HasSuffix(name, ".gz") || strings.HasSuffix(name, ".html") {
+			continue
+		}
+		plain, _ := fs.ReadFile(staticFS, name)
+		compressed, err := fs.ReadFile(staticFS, name+".gz")
+		if err != nil {
+			t.Errorf("%s.gz is missing: run go generate", name)
+			continue
+		}
+		zr, err := gzip.NewReader(bytes.NewReader(compressed))
+		if err != nil {
+			t.Errorf("%s.gz: %v", name, err)
+			continue
+		}
+		if unzipped, _ := io.ReadAll(zr); !bytes.Equal(unzipped, plain) {
+			t.Errorf("%s.gz is stale: run go generate", name)
+		}
+	}
+}
+
+func TestStatic_SPAFallback(t *testing.T) {
+	srv := newTestServer(t)
+	html := map[string]string{"Accept": "text/html,application/xhtml+xml"}
+
+	resp, page := staticGet(t, srv.URL+"/items/42", html)
+	if resp.StatusCode != http.StatusOK || !strings.Contains(string(page), "<title>Demo App</title>") {
+		t.Errorf("deep link: %d %.60s", resp.StatusCode, page)
+	}
+
+	for _, tt := range []struct {
+		path    string
+		headers map[string]string
+	}{
+		{"/items/42", nil}, // not a browser page load
+		{"/api/nothing", html},
+		{"/favicon.ico", html},
+		{"/static/missing.js", html},
+	} {
+		if resp, _ := staticGet(t, srv.URL+tt.path, tt.headers); resp.StatusCode != http.StatusNotFound {
+			t.Errorf("%s %v: status = %d, want 404", tt.path, tt.headers, resp.StatusCode)
+		}
+	}
+
+	spaFallback = false
+	t.Cleanup(func() { spaFallback = true })
+	if resp, _ := staticGet(t, srv.URL+"/items/42", html); resp.StatusCode != http.StatusNotFound {
+		t.Errorf("SPA_FALLBACK=false: status = %d, want 404", resp.StatusCode)
+	}
+}
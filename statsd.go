@@ -0,0 +1,189 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"log/slog"
+	"math"
+	"net"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+// =============================================================================
+// StatsD Metrics Export
+// =============================================================================
+//
+// Not every demo environment runs Prometheus. With METRICS_EXPORTER=statsd
+// the same demoapp_* metrics are also pushed to a StatsD agent over UDP —
+// the Datadog agent, Telegraf, or statsd_exporter all understand it.
+//
+// Rather than instrumenting every call site twice, the exporter reads the
+// Prometheus registry on an interval and translates:
+//
+//	counter   demoapp_http_requests_total{method="GET"} -> demoapp.http_requests_total:3|c|#method:GET
+//	gauge     demoapp_items_total                       -> demoapp.items_total:42|g
+//	histogram demoapp_db_operation_duration_seconds     -> .count and .sum counters
+//
+// Counters are sent as the increase since the last push (StatsD counters are
+// deltas; Prometheus counters are running totals). Labels become DogStatsD
+// tags (the "|#key:value" suffix), which Datadog and Telegraf both support.
+//
+// Request latency is also sent per request as a timer (http.request.duration)
+// from loggingMiddleware, so the agent can compute its own percentiles.
+
+// Keep packets under a typical MTU so they aren't fragmented
+const statsdMaxPacketSize = 1432
+
+// statsdClient sends metrics to a StatsD agent over UDP.
+// UDP is fire-and-forget: if the agent is down, metrics are silently lost
+// and the app never blocks — exactly what you want from telemetry.
+type statsdClient struct {
+	conn   net.Conn
+	prefix string
+	tags   []string // global tags added to every metric
+
+	mu   sync.Mutex
+	buf  bytes.Buffer
+	last map[string]float64 // previous counter totals, for deltas
+}
+
+// Active client, nil unless METRICS_EXPORTER=statsd
+var statsd *statsdClient
+
+// newStatsdClient "connects" to addr. For UDP this only resolves the
+// address — no packets are exchanged until the first metric is sent.
+func newStatsdClient(addr, prefix string, tags []string) (*statsdClient, error) {
+	conn, err := net.Dial("udp", addr)
+	if err != nil {
+		return nil, err
+	}
+	return &statsdClient{
+		conn:   conn,
+		prefix: prefix,
+		tags:   tags,
+		last:   make(map[string]float64),
+	}, nil
+}
+
+// send buffers one metric line, flushing first if the packet would get too big
+func (c *statsdClient) send(name, value, kind string, tags []string) {
+	line := c.prefix + name + ":" + value + "|" + kind
+	if all := append(append([]string{}, c.tags...), tags...); len(all) > 0 {
+		line += "|#" + strings.Join(all, ",")
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.buf.Len() > 0 && c.buf.Len()+1+len(line) > statsdMaxPacketSize {
+		c.flushLocked()
+	}
+	if c.buf.Len() > 0 {
+		c.buf.WriteByte('\n')
+	}
+	c.buf.WriteString(line)
+}
+
+// flush sends any buffered metrics
+func (c *statsdClient) flush() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.flushLocked()
+}
+
+func (c *statsdClient) flushLocked() {
+	if c.buf.Len() == 0 {
+		return
+	}
+	// Errors are ignored on purpose (see the statsdClient comment)
+	c.conn.Write(c.buf.Bytes())
+	c.buf.Reset()
+}
+
+// timing sends a duration in milliseconds
+func (c *statsdClient) timing(name string, d time.Duration, tags []string) {
+	c.send(name, formatStatsdValue(float64(d.Microseconds())/1000), "ms", tags)
+}
+
+// exportRegistry pushes every demoapp_* metric in the registry once
+func (c *statsdClient) exportRegistry(gatherer prometheus.Gatherer) error {
+	families, err := gatherer.Gather()
+	if err != nil {
+		return err
+	}
+
+	for _, family := range families {
+		if !strings.HasPrefix(family.GetName(), "demoapp_") {
+			continue // skip go_* and process_* runtime metrics
+		}
+		name := strings.TrimPrefix(family.GetName(), "demoapp_")
+
+		for _, m := range family.GetMetric() {
+			tags := statsdTags(m.GetLabel())
+			switch family.GetType() {
+			case dto.MetricType_COUNTER:
+				c.sendDelta(name, tags, m.GetCounter().GetValue())
+			case dto.MetricType_GAUGE:
+				c.send(name, formatStatsdValue(m.GetGauge().GetValue()), "g", tags)
+			case dto.MetricType_HISTOGRAM:
+				h := m.GetHistogram()
+				c.sendDelta(name+".count", tags, float64(h.GetSampleCount()))
+				c.sendDelta(name+".sum", tags, h.GetSampleSum())
+			}
+		}
+	}
+
+	c.flush()
+	return nil
+}
+
+// sendDelta sends the increase of a running total since the last export
+func (c *statsdClient) sendDelta(name string, tags []string, total float64) {
+	key := name + "|" + strings.Join(tags, ",")
+
+	c.mu.Lock()
+	delta := total - c.last[key]
+	c.last[key] = total
+	c.mu.Unlock()
+
+	// A negative delta means the counter was reset; send nothing this round
+	if delta > 0 {
+		c.send(name, formatStatsdValue(delta), "c", tags)
+	}
+}
+
+// start pushes the registry every interval until the process exits
+func (c *statsdClient) start(interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for range ticker.C {
+			if err := c.exportRegistry(prometheus.DefaultGatherer); err != nil {
+				slog.Warn("failed to export statsd metrics", "error", err)
+			}
+		}
+	}()
+}
+
+// statsdTags turns Prometheus labels into sorted "key:value" tags
+func statsdTags(labels []*dto.LabelPair) []string {
+	tags := make([]string, 0, len(labels))
+	for _, l := range labels {
+		tags = append(tags, l.GetName()+":"+l.GetValue())
+	}
+	sort.Strings(tags)
+	return tags
+}
+
+// formatStatsdValue prints whole numbers without a decimal point
+func formatStatsdValue(v float64) string {
+	if v == math.Trunc(v) && math.Abs(v) < 1e15 {
+		return fmt.Sprintf("%d", int64(v))
+	}
+	return fmt.Sprintf("%g", v)
+}
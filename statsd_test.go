@@ -0,0 +1,86 @@
+package main
+
+import (
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// listenStatsd starts a UDP listener and returns it with its address
+func listenStatsd(t *testing.T) *net.UDPConn {
+	t.Helper()
+	conn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+// readPacket reads one UDP packet (or fails after a second)
+func readPacket(t *testing.T, conn *net.UDPConn) string {
+	t.Helper()
+	buf := make([]byte, 64*1024)
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+	n, err := conn.Read(buf)
+	if err != nil {
+		t.Fatalf("failed to read packet: %v", err)
+	}
+	return string(buf[:n])
+}
+
+func TestStatsd_ExportsRegistry(t *testing.T) {
+	server := listenStatsd(t)
+	client, err := newStatsdClient(server.LocalAddr().String(), "demoapp.", []string{"env:test"})
+	if err != nil {
+		t.Fatalf("failed to create client: %v", err)
+	}
+
+	// A private registry keeps the test independent of global metrics
+	registry := prometheus.NewRegistry()
+	requests := prometheus.NewCounterVec(prometheus.CounterOpts{Name: "demoapp_requests_total"}, []string{"method"})
+	items := prometheus.NewGauge(prometheus.GaugeOpts{Name: "demoapp_items_total"})
+	other := prometheus.NewGauge(prometheus.GaugeOpts{Name: "go_ignored"})
+	registry.MustRegister(requests, items, other)
+
+	requests.WithLabelValues("GET").Add(3)
+	items.Set(42)
+
+	client.exportRegistry(registry)
+	packet := readPacket(t, server)
+
+	for _, want := range []string{
+		"demoapp.requests_total:3|c|#env:test,method:GET",
+		"demoapp.items_total:42|g|#env:test",
+	} {
+		if !strings.Contains(packet, want) {
+			t.Errorf("expected %q in packet:\n%s", want, packet)
+		}
+	}
+	if strings.Contains(packet, "ignored") {
+		t.Errorf("expected non-demoapp metrics to be skipped:\n%s", packet)
+	}
+
+	// Counters are sent as deltas: only the increase since the last export
+	requests.WithLabelValues("GET").Add(2)
+	client.exportRegistry(registry)
+	packet = readPacket(t, server)
+	if !strings.Contains(packet, "demoapp.requests_total:2|c") {
+		t.Errorf("expected counter delta of 2:\n%s", packet)
+	}
+}
+
+func TestStatsd_Timing(t *testing.T) {
+	server := listenStatsd(t)
+	client, _ := newStatsdClient(server.LocalAddr().String(), "demoapp.", nil)
+
+	client.timing("http.request.duration", 12500*time.Microsecond, []string{"path:/api/items"})
+	client.flush()
+
+	if got := readPacket(t, server); got != "demoapp.http.request.duration:12.5|ms|#path:/api/items" {
+		t.Errorf("unexpected packet: %q", got)
+	}
+}
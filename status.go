@@ -0,0 +1,166 @@
+package main
+
+import (
+	"bytes"
+	"html/template"
+	"net/http"
+	"os"
+	"time"
+)
+
+// =============================================================================
+// Status Page
+// =============================================================================
+//
+// GET /status is a plain HTML page rendered on the server: who is serving,
+// how long it has been up, how many items there are, the latest requests,
+// and the health checks. No JavaScript and no /static files, so it works
+//
+//   - from a terminal: curl http://localhost:8080/status
+//   - when static assets are blocked (a broken CDN, a strict proxy) — the
+//     "graceful degradation" demo: the dashboard goes blank, /status doesn't
+//
+// Everything is built in one pass with html/template, like server-side
+// rendering in Flask/Jinja.
+
+// How many recent requests the page lists
+const statusPageRequests = 20
+
+// statusPageData is what the template renders
+type statusPageData struct {
+	Status    string // worst check status: ok, warn, or fail
+	Hostname  string
+	Version   string
+	Variant   string
+	Accent    string
+	Branding  Branding // title, logo, and footer (branding.go)
+	IPs       []string
+	Cluster   string
+	StartedAt time.Time
+	Uptime    time.Duration
+	Items     int
+	Checks    []DiagnosticCheck
+	Requests  []RecentRequest
+	Generated time.Time
+}
+
+var statusTemplate = template.Must(template.New("status").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<meta http-equiv="refresh" content="5">
+<title>{{.Branding.Title}} Status: {{.Status}}</title>
+<style>
+body { font-family: -apple-system, BlinkMacSystemFont, "Segoe UI", Roboto, sans-serif;
+       background: #1a1a2e; color: #eee; margin: 0; padding: 1.5rem 2rem;
+       border-top: 6px solid {{.Accent}}; }
+h1 { font-weight: 400; margin: 0 0 1rem; display: flex; align-items: center; gap: 0.75rem; }
+h1 img { height: 2rem; }
+h2 { font-weight: 500; font-size: 1.1rem; color: #aaa; margin: 1.5rem 0 0.5rem; }
+table { border-collapse: collapse; }
+th, td { text-align: left; padding: 0.3rem 0.75rem; border-bottom: 1px solid #0f3460; }
+th { color: #aaa; font-weight: 500; }
+.ok { color: #2ecc71; } .warn { color: #f1c40f; } .fail { color: #e74c3c; } .skipped { color: #777; }
+footer { margin-top: 2rem; color: #777; font-size: 0.85rem; }
+</style>
+</head>
+<body>
+<h1>{{with .Branding.LogoURL}}<img src="{{.}}" alt="">{{end}}{{.Branding.Title}} <span class="{{.Status}}">{{.Status}}</span></h1>
+
+<h2>Instance</h2>
+<table>
+<tr><th>Hostname</th><td>{{.Hostname}}</td></tr>
+<tr><th>Version</th><td>{{.Version}}</td></tr>
+{{- with .Variant}}
+<tr><th>Variant</th><td>{{.}}</td></tr>
+{{- end}}
+<tr><th>IP addresses</th><td>{{range $i, $ip := .IPs}}{{if $i}}, {{end}}{{$ip}}{{else}}none{{end}}</td></tr>
+<tr><th>Cluster</th><td>{{.Cluster}}</td></tr>
+<tr><th>Started</th><td>{{.StartedAt.Format "2006-01-02 15:04:05 MST"}}</td></tr>
+<tr><th>Uptime</th><td>{{.Uptime}}</td></tr>
+<tr><th>Items</th><td>{{.Items}}</td></tr>
+</table>
+
+<h2>Health checks</h2>
+<table>
+<tr><th>Check</th><th>Status</th><th>Detail</th></tr>
+{{- range .Checks}}
+<tr><td>{{.Name}}</td><td class="{{.Status}}">{{.Status}}</td><td>{{.Detail}}</td></tr>
+{{- end}}
+</table>
+
+<h2>Recent requests</h2>
+<table>
+<tr><th>Time</th><th>Method</th><th>Path</th><th>Status</th><th>Latency</th><th>Client</th></tr>
+{{- range .Requests}}
+<tr><td>{{.Time.Format "15:04:05"}}</td><td>{{.Method}}</td><td>{{.Path}}</td><td>{{.Status}}</td><td>{{printf "%.1f" .LatencyMs}} ms</td><td>{{.Client}}</td></tr>
+{{- else}}
+<tr><td colspan="6">No requests yet</td></tr>
+{{- end}}
+</table>
+
+<footer>{{with .Branding.FooterText}}{{.}} &middot; {{end}}Generated {{.Generated.Format "2006-01-02 15:04:05 MST"}} &middot; refreshes every 5s &middot; JSON: /api/system, /api/system/diagnostics</footer>
+</body>
+</html>
+`))
+
+// statusPageHandler renders GET /status
+func statusPageHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, `{"error":"method not allowed"}`, http.StatusMethodNotAllowed)
+		return
+	}
+
+	hostname, err := os.Hostname()
+	if err != nil {
+		hostname = "unknown"
+	}
+	branding := currentBranding()
+	data := statusPageData{
+		Hostname:  hostname,
+		Version:   version,
+		Variant:   variant,
+		Accent:    branding.PrimaryColor,
+		Branding:  branding,
+		IPs:       getIPAddresses(),
+		Cluster:   "disabled",
+		StartedAt: startTime.UTC(),
+		Uptime:    time.Since(startTime).Truncate(time.Second),
+		Requests:  recentRequests.list(statusPageRequests),
+		Generated: time.Now().UTC(),
+	}
+
+	if cluster != nil {
+		leaderURL, isLeader := cluster.leader()
+		switch {
+		case isLeader:
+			data.Cluster = "leader"
+		case leaderURL != "":
+			data.Cluster = "follower of " + leaderURL
+		default:
+			data.Cluster = "follower (no leader yet)"
+		}
+	}
+
+	// A live database check first, then the startup diagnostics (diagnostics.go)
+	dbCheck := timeCheck("database", func() (string, string) {
+		if data.Items, err = countItems(); err != nil {
+			return diagFail, "read failed: " + err.Error()
+		}
+		return diagOK, "read succeeded"
+	})
+	diagMu.RLock()
+	data.Checks = append([]DiagnosticCheck{dbCheck}, diagReport.Checks...)
+	diagMu.RUnlock()
+	data.Status = worstStatus(data.Checks)
+
+	var page bytes.Buffer
+	if err := statusTemplate.Execute(&page, data); err != nil {
+		logHandlerError(r.Context(), "status", "template", "failed to render status page", "error", err)
+		http.Error(w, "failed to render status page", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Write(page.Bytes())
+}
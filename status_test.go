@@ -0,0 +1,103 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestStatusPage(t *testing.T) {
+	srv := newTestServer(t)
+	createTestItem(t, srv, `{"name":"status-item"}`)
+	doRequest(t, srv, http.MethodGet, "/api/items/999", "")
+
+	code, body := doRequest(t, srv, http.MethodGet, "/status", "")
+	if code != http.StatusOK {
+		t.Fatalf("status = %d", code)
+	}
+	page := string(body)
+	for _, want := range []string{
+		"<title>Demo App Status: ok</title>",
+		"<tr><th>Items</th><td>1</td></tr>",
+		"<tr><th>Cluster</th><td>disabled</td></tr>",
+		`<td>database</td><td class="ok">ok</td>`,
+		"<td>GET</td><td>/api/items/999</td><td>404</td>",
+	} {
+		if !strings.Contains(page, want) {
+			t.Errorf("page missing %q", want)
+		}
+	}
+	if strings.Contains(page, "<script") || strings.Contains(page, "/static/") {
+		t.Error("status page depends on scripts or static assets")
+	}
+
+	if code, _ := doRequest(t, srv, http.MethodPost, "/status", ""); code != http.StatusMethodNotAllowed {
+		t.Errorf("POST status = %d, want 405", code)
+	}
+}
+
+func TestRequestRing(t *testing.T) {
+	ring := newRequestRing(3)
+	if got := ring.list(0); len(got) != 0 {
+		t.Errorf("empty ring = %v", got)
+	}
+
+	for _, path := range []string{"/a", "/b", "/c", "/d"} {
+		ring.add(RecentRequest{Path: path})
+	}
+	var paths []string
+	for _, e := range ring.list(0) {
+		paths = append(paths, e.Path)
+	}
+	if strings.Join(paths, " ") != "/d /c /b" {
+		t.Errorf("list = %v, want newest first without /a", paths)
+	}
+	if got := ring.list(2); len(got) != 2 || got[0].Path != "/d" {
+		t.Errorf("list(2) = %v", got)
+	}
+}
+
+func TestRecentRequestsAPI(t *testing.T) {
+	srv := newTestServer(t)
+	prev := recentRequests
+	recentRequests = newRequestRing(3)
+	t.Cleanup(func() { recentRequests = prev })
+
+	for _, path := range []string{"/health", "/api/items", "/api/items/42", "/api/display"} {
+		doRequest(t, srv, http.MethodGet, path, "")
+	}
+
+	code, body := doRequest(t, srv, http.MethodGet, "/api/requests/recent", "")
+	if code != http.StatusOK {
+		t.Fatalf("status = %d", code)
+	}
+	var got []RecentRequest
+	if err := json.Unmarshal(body, &got); err != nil {
+		t.Fatal(err)
+	}
+	// Only the newest 3, newest first; the API's own requests aren't recorded
+	if len(got) != 3 || got[0].Path != "/api/display" || got[2].Path != "/api/items" {
+		t.Fatalf("recent = %+v", got)
+	}
+	if got[1].Status != http.StatusNotFound || got[1].Client != "127.0.0.1" || got[1].Instance == "" {
+		t.Errorf("entry = %+v", got[1])
+	}
+
+	_, body = doRequest(t, srv, http.MethodGet, "/api/requests/recent?limit=1", "")
+	json.Unmarshal(body, &got)
+	if len(got) != 1 || got[0].Path != "/api/display" {
+		t.Errorf("limit=1 = %+v", got)
+	}
+
+	if code, _ := doRequest(t, srv, http.MethodGet, "/api/requests/recent?limit=0", ""); code != http.StatusBadRequest {
+		t.Errorf("limit=0 status = %d, want 400", code)
+	}
+
+	// RECENT_REQUESTS=0 turns recording off
+	recentRequests = newRequestRing(0)
+	doRequest(t, srv, http.MethodGet, "/health", "")
+	if _, body := doRequest(t, srv, http.MethodGet, "/api/requests/recent", ""); string(body) != "[]\n" {
+		t.Errorf("disabled ring = %s, want []", body)
+	}
+}
@@ -1,38 +1,165 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
 
 	badger "github.com/dgraph-io/badger/v4"
 )
 
 // Key prefix for items in BadgerDB
-// All item keys look like: "item:1", "item:2", etc.
+// All item keys look like: "item:00000000000000000001", "item:00000000000000000002", etc.
 // Using a prefix lets us iterate over just items (not other data we might store)
 const itemKeyPrefix = "item:"
 
+// Item IDs in keys are zero-padded to 20 digits (enough for any int64).
+// BadgerDB sorts keys byte by byte, so unpadded "item:10" would sort before
+// "item:2"; padded keys sort in ID order, so listings come back ordered and
+// ID ranges can be scanned with Seek. Older databases are converted at
+// startup by migrateItemKeys (migration 1, see migrations.go).
+const itemKeyDigits = 20
+
+// itemKey builds the BadgerDB key for an item
+func itemKey(id int64) []byte {
+	return []byte(fmt.Sprintf("%s%0*d", itemKeyPrefix, itemKeyDigits, id))
+}
+
+// Key prefix for the item name index (only maintained when UNIQUE_ITEM_NAMES=true)
+// Keys look like: "name:widget" -> value "42" (the owning item's ID)
+const nameIndexPrefix = "name:"
+
+// uniqueItemNames enables the name index and 409 Conflict on duplicate names
+// Set from the UNIQUE_ITEM_NAMES env var in main()
+var uniqueItemNames bool
+
+// nameConflictError is returned when a name is already taken by another item.
+// It carries the existing item's ID so clients can look it up — handy for
+// "create or get" style idempotent clients.
+type nameConflictError struct {
+	ExistingID int64
+}
+
+func (e *nameConflictError) Error() string {
+	return fmt.Sprintf("name already used by item %d", e.ExistingID)
+}
+
 // Package-level database connection
 // Handlers need access to this to read/write data
 var db *badger.DB
 
+// Path the database was opened with (":memory:" or a directory),
+// reported by /api/system
+var storePath string
+
 // Sequence for auto-incrementing item IDs
-// BadgerDB sequences are atomic and safe for concurrent access
+// BadgerDB sequences are atomic and safe for concurrent access, but reset,
+// restore, fsck, and cluster promotion replace the sequence itself, so the
+// variable is guarded by itemSeqMu (see replaceItemSequence)
 var itemSeq *badger.Sequence
 
+// itemSeqMu is held for reading by item creates, from leasing an ID until
+// the item is written, and for writing while the sequence is replaced. An
+// ID leased from the old sequence can't land after the swap, where the new
+// one would hand it out again.
+var itemSeqMu sync.RWMutex
+
+// lockItemCreates keeps the sequence from being replaced until the returned
+// func is called
+func lockItemCreates() func() {
+	itemSeqMu.RLock()
+	return itemSeqMu.RUnlock
+}
+
+// replaceItemSequence pauses item creates, hands back the sequence's unused
+// lease (Release writes its position to "seq:items"), runs change, which may
+// rewrite or delete that key, and leases again. It leases again even if
+// change failed, so item creation keeps working.
+func replaceItemSequence(change func() error) error {
+	itemSeqMu.Lock()
+	defer itemSeqMu.Unlock()
+	if err := itemSeq.Release(); err != nil {
+		return err
+	}
+	err := change()
+	var seqErr error
+	itemSeq, seqErr = db.GetSequence([]byte("seq:items"), 100)
+	if err != nil {
+		return err
+	}
+	return seqErr
+}
+
 // Package-level display data (in-memory, transient)
 // This is NOT stored in BadgerDB — it resets when the app restarts
 // json.RawMessage holds arbitrary JSON without parsing it
-var displayData json.RawMessage
+//
+// Background tasks (like the scheduler) update it too, so access goes
+// through getDisplayData/setDisplayData which hold displayMu.
+//
+// displayVersion is when the data was last set (UnixNano). Replicas compare
+// versions so the newest panel wins in cluster mode (displaysync.go).
+var (
+	displayMu      sync.RWMutex
+	displayData    json.RawMessage
+	displayVersion int64
+)
+
+// getDisplayData returns the current display JSON (nil if never set)
+func getDisplayData() json.RawMessage {
+	data, _ := getDisplayDataVersion()
+	return data
+}
+
+// getDisplayDataVersion returns the display JSON and its version
+func getDisplayDataVersion() (json.RawMessage, int64) {
+	displayMu.RLock()
+	defer displayMu.RUnlock()
+	return displayData, displayVersion
+}
+
+// setDisplayData replaces the display JSON and returns its new version
+func setDisplayData(data json.RawMessage) int64 {
+	displayMu.Lock()
+	defer displayMu.Unlock()
+	displayData = data
+	// Versions only move forward, even if the clock doesn't
+	displayVersion = max(time.Now().UnixNano(), displayVersion+1)
+	return displayVersion
+}
+
+// setDisplayDataIfNewer replaces the display JSON with a copy from another
+// replica, unless what we have is newer. Reports whether it was applied.
+func setDisplayDataIfNewer(data json.RawMessage, version int64) bool {
+	displayMu.Lock()
+	defer displayMu.Unlock()
+	if version <= displayVersion {
+		return false
+	}
+	displayData = data
+	displayVersion = version
+	return true
+}
 
 // Item represents a generic item in the database
 // The struct tags (json:"...") control how Go marshals/unmarshals JSON
 // omitempty means the field is excluded from JSON if it's empty
 type Item struct {
-	ID          int64     `json:"id"`
-	Name        string    `json:"name"`
-	Description string    `json:"description,omitempty"`
-	CreatedAt   time.Time `json:"created_at"`
+	ID          int64           `json:"id"`
+	Name        string          `json:"name"`
+	Description string          `json:"description,omitempty"`
+	Tags        []string        `json:"tags,omitempty"`
+	Category    string          `json:"category,omitempty"`   // e.g. "hardware/laptops" (categories.go)
+	Metadata    map[string]any  `json:"metadata,omitempty"`   // free-form JSON object (metadata.go)
+	Attachment  *AttachmentInfo `json:"attachment,omitempty"` // set by attachments.go
+	Version     int64           `json:"version"`              // 1 when created, +1 per change (itemversion.go)
+	CreatedAt   time.Time       `json:"created_at"`
 }
 
 // initStore opens the BadgerDB database
@@ -52,16 +179,668 @@ func initStore(dbPath string) (*badger.DB, error) {
 	} else {
 		// File-based mode: persistent, data survives restarts
 		opts = badger.DefaultOptions(dbPath)
+
+		// Optional encryption at rest (encryption.go)
+		if dbEncryptionKey != nil {
+			if dbEncryptionKeyPrevious != nil {
+				rotated, err := rotateEncryptionKey(dbPath, dbEncryptionKeyPrevious, dbEncryptionKey)
+				if err != nil {
+					return nil, fmt.Errorf("rotate encryption key: %w", err)
+				}
+				if rotated {
+					slog.Info("database encryption key rotated")
+				}
+			}
+			opts = opts.WithEncryptionKey(dbEncryptionKey).WithIndexCacheSize(encryptedIndexCacheSize)
+		}
 	}
 
+	// DB_SYNC_WRITES, DB_COMPRESSION, and friends (dboptions.go)
+	opts = dbOptions.apply(opts)
+
 	// Reduce logging noise from BadgerDB (it's verbose by default)
 	opts = opts.WithLoggingLevel(badger.WARNING)
 
 	// Open the database
 	database, err := badger.Open(opts)
 	if err != nil {
-		return nil, err
+		return nil, explainOpenError(err)
 	}
 
+	storePath = dbPath
+	writeDurability = durabilityOf(database.Opts()) // durability.go
 	return database, nil
 }
+
+// =============================================================================
+// Instrumented Transactions
+// =============================================================================
+//
+// dbView and dbUpdate are db.View and db.Update plus timing: each call is
+// recorded in demoapp_db_operation_duration_seconds{op}, and any transaction
+// slower than dbSlowThreshold is logged as a warning with its key. During a
+// load demo that makes storage stalls visible instead of mysterious.
+//
+// op is a metric label, so use a small fixed name ("item_get"), never an ID.
+// key is only logged — use the item key, or the prefix for scans.
+
+// Transactions at least this slow are logged (DB_SLOW_THRESHOLD, 0 = off)
+var dbSlowThreshold = 100 * time.Millisecond
+
+// dbView runs a read-only transaction and records its duration
+func dbView(op, key string, fn func(txn *badger.Txn) error) error {
+	return timeDBOp(op, key, func() error { return db.View(fn) })
+}
+
+// dbUpdate runs a read-write transaction and records its duration, also
+// by durability mode (durability.go)
+func dbUpdate(op, key string, fn func(txn *badger.Txn) error) error {
+	start := time.Now()
+	err := timeDBOp(op, key, func() error { return db.Update(fn) })
+	dbWriteDuration.WithLabelValues(writeDurability, op).Observe(time.Since(start).Seconds())
+	return err
+}
+
+// dbViewContext is dbView for request handlers: it returns ctx's error
+// instead of starting once the request has timed out or the client left.
+// Loops over many keys should check ctx.Err() themselves.
+func dbViewContext(ctx context.Context, op, key string, fn func(txn *badger.Txn) error) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	traceDBTxn(ctx, op, key, false) // X-Debug headers (dbtrace.go)
+	return dbView(op, key, fn)
+}
+
+// dbUpdateContext is dbUpdate for request handlers. A transaction still
+// running when ctx ends is discarded rather than committed, so a request
+// answered with 504 leaves nothing behind.
+func dbUpdateContext(ctx context.Context, op, key string, fn func(txn *badger.Txn) error) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	traceDBTxn(ctx, op, key, true)
+	return dbUpdate(op, key, func(txn *badger.Txn) error {
+		if err := fn(txn); err != nil {
+			return err
+		}
+		return ctx.Err()
+	})
+}
+
+// timeDBOp runs fn, observes its duration, and warns if it was slow
+func timeDBOp(op, key string, fn func() error) error {
+	start := time.Now()
+	err := fn()
+	duration := time.Since(start)
+
+	dbOperationDuration.WithLabelValues(op).Observe(duration.Seconds())
+	if dbSlowThreshold > 0 && duration >= dbSlowThreshold {
+		slog.Warn("slow database transaction",
+			"op", op,
+			"key", key,
+			"duration_ms", duration.Milliseconds(),
+			"threshold_ms", dbSlowThreshold.Milliseconds(),
+		)
+	}
+	return err
+}
+
+// insertItem stores a new item and returns it.
+//
+// This is the one place items get created — the HTTP handler, background
+// jobs, and seeders all call it, so unique names and metrics behave the same
+// no matter where an item comes from. The input should already be validated.
+//
+// idemKey is an optional Idempotency-Key (idempotency.go). If the key was
+// seen before, the original item is returned with replayed=true and nothing
+// new is written.
+func insertItem(input itemInput, idemKey string) (item Item, replayed bool, err error) {
+	return insertItemAt(input, idemKey, time.Now().UTC())
+}
+
+// insertItemAt is insertItem with an explicit creation time.
+// Used by the demo data generator to backdate items.
+func insertItemAt(input itemInput, idemKey string, createdAt time.Time) (item Item, replayed bool, err error) {
+	return rootKeyspace.insertItem(context.Background(), input, idemKey, createdAt, false)
+}
+
+// insertItem is insertItemAt for a tenant's items (tenant.go). A tenant's
+// first item creates the tenant. ctx is the request's, if any (see
+// dbUpdateContext). With limited set, the tenant's max_items quota applies
+// (quota.go): over it, the error is a *quotaError.
+func (k keyspace) insertItem(ctx context.Context, input itemInput, idemKey string, createdAt time.Time, limited bool) (item Item, replayed bool, err error) {
+	// Jobs and seeders pass input straight through, so normalize here too
+	category, err := normalizeCategory(input.Category)
+	if err != nil {
+		return Item{}, false, fmt.Errorf("category %w", err)
+	}
+	if err := tenants.register(k); err != nil {
+		return Item{}, false, err
+	}
+
+	// Get next ID from the sequence
+	// This is atomic and safe for concurrent access; the lock only keeps a
+	// reset from swapping the sequence until this item is written
+	defer lockItemCreates()()
+	seq, err := k.sequence()
+	if err != nil {
+		return Item{}, false, fmt.Errorf("item sequence: %w", err)
+	}
+	id, err := seq.Next()
+	if err != nil {
+		return Item{}, false, fmt.Errorf("next item ID: %w", err)
+	}
+
+	item = Item{
+		ID:          int64(id),
+		Name:        input.Name,
+		Description: input.Description,
+		Tags:        input.Tags,
+		Category:    category,
+		Metadata:    input.Metadata,
+		Version:     1,
+		CreatedAt:   createdAt,
+	}
+
+	key := k.itemKey(int64(id))
+
+	var requestHash string
+	if idemKey != "" {
+		requestHash = hashItemInput(input)
+	}
+
+	// db.Update() starts a read-write transaction (dbUpdate times it, see below)
+	// Multiple Update transactions are serialized, but this is fast for K/V operations
+	insert := func(txn *badger.Txn) error {
+		// A retry with a known Idempotency-Key returns the original item.
+		// Checking inside the same transaction means two racing retries
+		// can't both create an item — BadgerDB fails one with ErrConflict.
+		if idemKey != "" {
+			record, err := getIdempotencyRecord(txn, k, idemKey)
+			if err != nil {
+				return err
+			}
+			if record != nil {
+				if record.RequestHash != requestHash {
+					return errIdempotencyMismatch
+				}
+				item = record.Item
+				replayed = true
+				return nil
+			}
+		}
+
+		if limited {
+			if err := k.checkItemQuota(txn, 1); err != nil {
+				return err
+			}
+		}
+		if err := k.putNewItem(txn, item); err != nil {
+			return err
+		}
+
+		if idemKey != "" {
+			return putIdempotencyRecord(txn, k, idemKey, idempotencyRecord{RequestHash: requestHash, Item: item})
+		}
+		return nil
+	}
+	err = dbUpdateContext(ctx, "item_insert", string(key), insert)
+	// The quota check conflicts with every racing item write; try again
+	// against the new count rather than answer 409
+	for retries := 0; limited && errors.Is(err, badger.ErrConflict) && retries < quotaConflictRetries; retries++ {
+		err = dbUpdateContext(ctx, "item_insert", string(key), insert)
+	}
+	if err != nil {
+		return Item{}, false, err
+	}
+
+	// Update Prometheus metrics (defined in metrics.go) — only for real inserts
+	if !replayed {
+		k.itemsGauge().Inc()
+		mirrorItem(k, item) // shadow.go
+	}
+	return item, replayed, nil
+}
+
+// putNewItem writes a new item and its index entries inside txn
+func (k keyspace) putNewItem(txn *badger.Txn, item Item) error {
+	value, err := encodeItem(item)
+	if err != nil {
+		return err
+	}
+	// In unique-names mode, claim the name in the same transaction
+	if uniqueItemNames {
+		if err := claimName(txn, k, item.Name, item.ID); err != nil {
+			return err
+		}
+	}
+	// The list's ETag changes with every item write (itemsetag.go)
+	if err := k.touchItemsVersion(txn); err != nil {
+		return err
+	}
+	if err := txn.Set(k.itemKey(item.ID), value); err != nil {
+		return err
+	}
+	if item.Category != "" {
+		if err := txn.Set(k.key(string(categoryIndexKey(item.Category, item.ID))), nil); err != nil {
+			return err
+		}
+	}
+	// Creations per minute, for the dashboard chart (timeseries.go)
+	if err := k.recordItemCreation(txn, item.CreatedAt); err != nil {
+		return err
+	}
+	// Item count and tag totals (aggregates.go)
+	return k.recordItemChange(txn, nil, &item)
+}
+
+// applyItemUpdate replaces an item's fields inside txn and returns it.
+// expected, when set, must be the item's current version (itemversion.go).
+// Returns badger.ErrKeyNotFound if there is no such item.
+func (k keyspace) applyItemUpdate(txn *badger.Txn, id int64, input itemInput, expected *int64) (Item, error) {
+	key := k.itemKey(id)
+	var item Item
+
+	if err := k.touchItemsVersion(txn); err != nil { // itemsetag.go
+		return item, err
+	}
+	// First, read the existing item
+	dbItem, err := txn.Get(key)
+	if err != nil {
+		return item, err // badger.ErrKeyNotFound if doesn't exist
+	}
+
+	// Get current value and unmarshal
+	err = dbItem.Value(func(val []byte) error {
+		return decodeItem(val, &item)
+	})
+	if err != nil {
+		return item, err
+	}
+	if expected != nil && *expected != item.Version {
+		return item, &versionConflictError{Expected: *expected, Current: item}
+	}
+	before := item
+
+	// If the name is changing in unique-names mode, move the index entry
+	if uniqueItemNames && !strings.EqualFold(item.Name, input.Name) {
+		if err := claimName(txn, k, input.Name, item.ID); err != nil {
+			return item, err
+		}
+		if err := releaseName(txn, k, item.Name, item.ID); err != nil {
+			return item, err
+		}
+	}
+
+	// Moving to another category moves the index entry (categories.go)
+	if item.Category != input.Category {
+		if item.Category != "" {
+			if err := txn.Delete(k.key(string(categoryIndexKey(item.Category, item.ID)))); err != nil {
+				return item, err
+			}
+		}
+		if input.Category != "" {
+			if err := txn.Set(k.key(string(categoryIndexKey(input.Category, item.ID))), nil); err != nil {
+				return item, err
+			}
+		}
+	}
+
+	// Update fields (preserve CreatedAt and ID)
+	item.Name = input.Name
+	item.Description = input.Description
+	item.Tags = input.Tags
+	item.Category = input.Category
+	item.Metadata = input.Metadata
+	item.Version++
+
+	// New tags or description change the totals (aggregates.go)
+	if err := k.recordItemChange(txn, &before, &item); err != nil {
+		return item, err
+	}
+
+	// Marshal and save
+	value, err := encodeItem(item)
+	if err != nil {
+		return item, err
+	}
+	return item, txn.Set(key, value)
+}
+
+// loadAllItems reads every item into memory.
+// Fine for background tasks at demo scale; listItems streams its own loop.
+func loadAllItems() ([]Item, error) {
+	var items []Item
+	err := dbView("item_list", itemKeyPrefix, func(txn *badger.Txn) error {
+		it := txn.NewIterator(badger.DefaultIteratorOptions)
+		defer it.Close()
+
+		prefix := []byte(itemKeyPrefix)
+		for it.Seek(prefix); it.ValidForPrefix(prefix); it.Next() {
+			err := it.Item().Value(func(val []byte) error {
+				var i Item
+				if err := decodeItem(val, &i); err != nil {
+					return nil // skip malformed items, same as listItems
+				}
+				items = append(items, i)
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	return items, err
+}
+
+// countItems counts stored items by iterating over keys only.
+// With PrefetchValues off BadgerDB never reads the values from the value
+// log, so this stays cheap even with many (or large) items.
+func countItems() (int, error) {
+	return rootKeyspace.countItems()
+}
+
+// countItems counts a tenant's items (tenant.go)
+func (k keyspace) countItems() (int, error) {
+	count := 0
+	prefix := k.key(itemKeyPrefix)
+	err := dbView("item_count", string(prefix), func(txn *badger.Txn) error {
+		opts := badger.DefaultIteratorOptions
+		opts.PrefetchValues = false
+		it := txn.NewIterator(opts)
+		defer it.Close()
+
+		for it.Seek(prefix); it.ValidForPrefix(prefix); it.Next() {
+			count++
+		}
+		return nil
+	})
+	return count, err
+}
+
+// syncItemsGauge sets demoapp_items_total to the real item count.
+//
+// The gauge is otherwise only nudged up/down on create/delete, so it starts
+// at zero on a persistent DB that already has items, and can drift if a
+// write fails halfway. Called at startup and by the reconciler below.
+func syncItemsGauge() error {
+	count, err := countItems()
+	if err != nil {
+		return err
+	}
+	itemsTotal.Set(float64(count))
+	return nil
+}
+
+// startItemsGaugeReconciler re-syncs the gauge every interval (0 = never)
+func startItemsGaugeReconciler(interval time.Duration) {
+	if interval <= 0 {
+		return
+	}
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for range ticker.C {
+			if err := syncItemsGauge(); err != nil {
+				slog.Warn("failed to reconcile item count", "error", err)
+			}
+		}
+	}()
+}
+
+// removeItem deletes an item by ID, freeing its name in unique-names mode.
+// Returns badger.ErrKeyNotFound if there is no such item.
+func removeItem(id int64) error {
+	return rootKeyspace.removeItem(id)
+}
+
+// removeItem deletes one of a tenant's items (tenant.go)
+func (k keyspace) removeItem(id int64) error {
+	key := k.itemKey(id)
+
+	err := dbUpdate("item_delete", string(key), func(txn *badger.Txn) error {
+		_, err := k.deleteItemTxn(txn, id)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	// Update Prometheus metrics (defined in metrics.go)
+	k.itemsGauge().Dec()
+	mirrorItemDelete(k, id) // shadow.go
+	return nil
+}
+
+// deleteItemTxn deletes an item and everything that belongs to it inside
+// txn, returning what it was (empty if the record was malformed).
+// Returns badger.ErrKeyNotFound if there is no such item.
+func (k keyspace) deleteItemTxn(txn *badger.Txn, id int64) (Item, error) {
+	key := k.itemKey(id)
+	var item Item
+
+	// Reading the item first gives us the 404 check AND the name and
+	// category index entries to remove
+	dbItem, err := txn.Get(key)
+	if err != nil {
+		return item, err
+	}
+
+	err = dbItem.Value(func(val []byte) error {
+		return decodeItem(val, &item)
+	})
+	if err == nil {
+		// Free up the name in unique-names mode so it can be reused
+		if uniqueItemNames {
+			if err := releaseName(txn, k, item.Name, id); err != nil {
+				return item, err
+			}
+		}
+		if item.Category != "" {
+			if err := txn.Delete(k.key(string(categoryIndexKey(item.Category, id)))); err != nil {
+				return item, err
+			}
+		}
+		if item.Attachment != nil {
+			if err := txn.Delete(k.key(string(attachmentKey(id)))); err != nil {
+				return item, err
+			}
+		}
+		if err := k.recordItemChange(txn, &item, nil); err != nil { // aggregates.go
+			return item, err
+		}
+	} else {
+		item = Item{}
+	}
+	// Links from and to this item go with it (links.go); tenants
+	// have no links, and their IDs overlap the default tenant's
+	if k == rootKeyspace {
+		if err := deleteItemLinks(txn, id); err != nil {
+			return item, err
+		}
+	}
+	if err := k.touchItemsVersion(txn); err != nil {
+		return item, err
+	}
+	return item, txn.Delete(key)
+}
+
+// nameIndexKey builds the index key for an item name.
+// Names are compared case-insensitively: "Widget" and "widget" collide.
+func nameIndexKey(name string) []byte {
+	return []byte(nameIndexPrefix + strings.ToLower(name))
+}
+
+// claimName points the name index at itemID inside an existing transaction.
+// Returns *nameConflictError if a different item already owns the name.
+//
+// Doing the check and the write in the SAME transaction matters: BadgerDB
+// detects when two transactions read/write the same key concurrently and
+// fails one with ErrConflict, so two racing creates can't both win.
+func claimName(txn *badger.Txn, k keyspace, name string, itemID int64) error {
+	key := k.key(string(nameIndexKey(name)))
+
+	existing, err := txn.Get(key)
+	if err == nil {
+		ownerID, err := nameIndexOwner(existing)
+		if err != nil {
+			return err
+		}
+		if ownerID != itemID {
+			return &nameConflictError{ExistingID: ownerID}
+		}
+		return nil // we already own it
+	}
+	if !errors.Is(err, badger.ErrKeyNotFound) {
+		return err
+	}
+
+	return txn.Set(key, []byte(strconv.FormatInt(itemID, 10)))
+}
+
+// releaseName removes the name index entry if (and only if) itemID owns it
+func releaseName(txn *badger.Txn, k keyspace, name string, itemID int64) error {
+	key := k.key(string(nameIndexKey(name)))
+
+	existing, err := txn.Get(key)
+	if errors.Is(err, badger.ErrKeyNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	ownerID, err := nameIndexOwner(existing)
+	if err != nil {
+		return err
+	}
+	if ownerID != itemID {
+		return nil // someone else's entry — leave it alone
+	}
+	return txn.Delete(key)
+}
+
+// nameIndexOwner reads the item ID stored in a name index entry
+func nameIndexOwner(entry *badger.Item) (int64, error) {
+	var ownerID int64
+	err := entry.Value(func(val []byte) error {
+		var parseErr error
+		ownerID, parseErr = strconv.ParseInt(string(val), 10, 64)
+		return parseErr
+	})
+	return ownerID, err
+}
+
+// rebuildNameIndex drops and recreates every tenant's name index from the
+// stored items. Run at startup when UNIQUE_ITEM_NAMES is enabled, because
+// items created while the mode was off have no index entries. Existing
+// duplicates are logged (the first item keeps the name) rather than deleted.
+func rebuildNameIndex() error {
+	total := 0
+	for _, k := range tenants.keyspaces() {
+		n, err := k.rebuildNameIndex()
+		if err != nil {
+			return fmt.Errorf("tenant %s: %w", dataSubject(k), err)
+		}
+		total += n
+	}
+
+	slog.Info("item name index built", "items", total)
+	return nil
+}
+
+// rebuildNameIndex rebuilds one tenant's name index, returning how many
+// items it went through
+func (k keyspace) rebuildNameIndex() (int, error) {
+	if err := db.DropPrefix(k.key(nameIndexPrefix)); err != nil {
+		return 0, err
+	}
+
+	var items []Item
+	err := k.scanItems(context.Background(), func(item Item) error {
+		items = append(items, item)
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	for _, item := range items {
+		key := k.key(string(nameIndexKey(item.Name)))
+		err := dbUpdate("name_index", string(key), func(txn *badger.Txn) error {
+			return claimName(txn, k, item.Name, item.ID)
+		})
+		var conflict *nameConflictError
+		if errors.As(err, &conflict) {
+			slog.Warn("duplicate item name found while building index",
+				"tenant", dataSubject(k), "name", item.Name, "id", item.ID, "kept_id", conflict.ExistingID)
+			continue
+		}
+		if err != nil {
+			return 0, err
+		}
+	}
+	return len(items), nil
+}
+
+// migrateItemKeys rewrites old-style unpadded item keys ("item:42") to the
+// padded format ("item:00000000000000000042"). Safe to run on every startup:
+// once everything is converted it finds nothing to do. Returns how many
+// items were converted.
+func migrateItemKeys() (int, error) {
+	type legacyItem struct {
+		oldKey, newKey, value []byte
+	}
+	var legacy []legacyItem
+
+	err := dbView("item_migrate", itemKeyPrefix, func(txn *badger.Txn) error {
+		it := txn.NewIterator(badger.DefaultIteratorOptions)
+		defer it.Close()
+
+		prefix := []byte(itemKeyPrefix)
+		for it.Seek(prefix); it.ValidForPrefix(prefix); it.Next() {
+			entry := it.Item()
+			suffix := strings.TrimPrefix(string(entry.Key()), itemKeyPrefix)
+			if len(suffix) == itemKeyDigits {
+				continue // already padded
+			}
+			id, err := strconv.ParseInt(suffix, 10, 64)
+			if err != nil {
+				slog.Warn("skipping item with unexpected key", "key", string(entry.Key()))
+				continue
+			}
+			// KeyCopy/ValueCopy: the iterator reuses its buffers on Next()
+			value, err := entry.ValueCopy(nil)
+			if err != nil {
+				return err
+			}
+			legacy = append(legacy, legacyItem{oldKey: entry.KeyCopy(nil), newKey: itemKey(id), value: value})
+		}
+		return nil
+	})
+	if err != nil || len(legacy) == 0 {
+		return 0, err
+	}
+
+	// A WriteBatch splits the work into as many transactions as needed,
+	// so large databases don't hit BadgerDB's per-transaction size limit
+	err = timeDBOp("item_migrate", itemKeyPrefix, func() error {
+		batch := db.NewWriteBatch()
+		defer batch.Cancel()
+		for _, item := range legacy {
+			if err := batch.Set(item.newKey, item.value); err != nil {
+				return err
+			}
+			if err := batch.Delete(item.oldKey); err != nil {
+				return err
+			}
+		}
+		return batch.Flush()
+	})
+	if err != nil {
+		return 0, err
+	}
+	return len(legacy), nil
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"bytes"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+
+	badger "github.com/dgraph-io/badger/v4"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestTimeDBOp_RecordsAndLogsSlow(t *testing.T) {
+	// Capture log output to check the slow-transaction warning
+	var logs bytes.Buffer
+	oldLogger := slog.Default()
+	slog.SetDefault(slog.New(slog.NewJSONHandler(&logs, nil)))
+	defer slog.SetDefault(oldLogger)
+
+	oldThreshold := dbSlowThreshold
+	dbSlowThreshold = 10 * time.Millisecond
+	defer func() { dbSlowThreshold = oldThreshold }()
+
+	before := testutil.CollectAndCount(dbOperationDuration)
+
+	// Fast operation: recorded, not logged
+	err := dbView("test_fast", "test:1", func(txn *badger.Txn) error { return nil })
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if logs.Len() != 0 {
+		t.Errorf("expected no log for a fast transaction, got %s", logs.String())
+	}
+
+	// Slow operation: logged with op and key
+	timeDBOp("test_slow", "test:2", func() error {
+		time.Sleep(15 * time.Millisecond)
+		return nil
+	})
+	if !strings.Contains(logs.String(), `"msg":"slow database transaction"`) ||
+		!strings.Contains(logs.String(), `"key":"test:2"`) {
+		t.Errorf("expected slow transaction warning, got %s", logs.String())
+	}
+
+	if got := testutil.CollectAndCount(dbOperationDuration); got != before+2 {
+		t.Errorf("expected 2 new op series, got %d", got-before)
+	}
+}
+
+func TestMigrateItemKeys(t *testing.T) {
+	newTestServer(t)
+
+	// Items as an older version stored them: unpadded keys
+	err := db.Update(func(txn *badger.Txn) error {
+		for _, id := range []string{"2", "10"} {
+			value := []byte(`{"id":` + id + `,"name":"Legacy ` + id + `"}`)
+			if err := txn.Set([]byte("item:"+id), value); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("failed to write legacy items: %v", err)
+	}
+
+	converted, err := migrateItemKeys()
+	if err != nil {
+		t.Fatalf("migration failed: %v", err)
+	}
+	if converted != 2 {
+		t.Errorf("expected 2 items converted, got %d", converted)
+	}
+
+	// Items are now under padded keys, in ID order ("item:10" used to sort first)
+	items, err := loadAllItems()
+	if err != nil {
+		t.Fatalf("failed to load items: %v", err)
+	}
+	if len(items) != 2 || items[0].ID != 2 || items[1].ID != 10 {
+		t.Fatalf("expected items 2 and 10 in order, got %+v", items)
+	}
+	err = db.View(func(txn *badger.Txn) error {
+		if _, err := txn.Get(itemKey(10)); err != nil {
+			return err
+		}
+		_, err := txn.Get([]byte("item:10"))
+		return err
+	})
+	if err != badger.ErrKeyNotFound {
+		t.Errorf("expected old key gone and new key present, got %v", err)
+	}
+
+	// Running again is a no-op
+	if converted, err := migrateItemKeys(); err != nil || converted != 0 {
+		t.Errorf("expected nothing to migrate on second run, got %d, %v", converted, err)
+	}
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+)
+
+// =============================================================================
+// Strict JSON
+// =============================================================================
+//
+// By default the API is lenient, like most real ones: any Content-Type is
+// read as JSON, and fields it doesn't know are ignored. STRICT_JSON=true
+// turns it into a strict contract, so a demo can show a client typo being
+// silently dropped, then caught:
+//
+//	curl -X POST -d '{"name":"Widget","descripton":"typo"}' localhost:8080/api/items
+//	# lenient: 201, the description is lost
+//	# strict:  415 {"error":"Content-Type must be application/json"}
+//
+//	curl -X POST -H 'Content-Type: application/json' \
+//	  -d '{"name":"Widget","descripton":"typo"}' localhost:8080/api/items
+//	# strict:  400 {"error":"invalid json","fields":[{"field":"descripton","message":"unknown field"}]}
+//
+// Strict mode also names the field behind a type mismatch ("tags": "must be
+// []string") and rejects trailing data after the JSON value. It covers the
+// JSON bodies of the public API: items, the display panel, links, and jobs.
+// Endpoints that take other formats (attachments, /api/kv, imports) and the
+// admin API aren't affected.
+
+// strictJSON is STRICT_JSON (set in main)
+var strictJSON bool
+
+// isJSONContentType accepts application/json and any +json type
+// (application/merge-patch+json), with or without parameters
+func isJSONContentType(value string) bool {
+	mediaType, _, err := mime.ParseMediaType(value)
+	if err != nil {
+		return false
+	}
+	return mediaType == "application/json" || strings.HasSuffix(mediaType, "+json")
+}
+
+// decodeJSONBody decodes the request body into v, answering 415 or 400 and
+// returning false when it can't. In lenient mode it behaves exactly like
+// json.NewDecoder(r.Body).Decode(v).
+func decodeJSONBody(w http.ResponseWriter, r *http.Request, v any) bool {
+	if !strictJSON {
+		if err := json.NewDecoder(r.Body).Decode(v); err != nil {
+			http.Error(w, `{"error":"invalid json"}`, http.StatusBadRequest)
+			return false
+		}
+		return true
+	}
+
+	if !isJSONContentType(r.Header.Get("Content-Type")) {
+		http.Error(w, `{"error":"Content-Type must be application/json"}`, http.StatusUnsupportedMediaType)
+		return false
+	}
+
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+	err := dec.Decode(v)
+	if err == nil {
+		if _, next := dec.Token(); next != io.EOF {
+			err = errors.New("unexpected data after the JSON value")
+		}
+	}
+	if err != nil {
+		writeJSONDecodeError(w, err)
+		return false
+	}
+	return true
+}
+
+// writeJSONDecodeError explains a strict decode failure: which field, when
+// the error names one, or where the syntax went wrong
+func writeJSONDecodeError(w http.ResponseWriter, err error) {
+	var typeErr *json.UnmarshalTypeError
+	var syntaxErr *json.SyntaxError
+	var field FieldError
+
+	switch {
+	case errors.As(err, &typeErr) && typeErr.Field != "":
+		field = FieldError{typeErr.Field, fmt.Sprintf("must be %s, not %s", typeErr.Type, typeErr.Value)}
+	case strings.HasPrefix(err.Error(), "json: unknown field "):
+		// encoding/json has no typed error for this one
+		name := strings.Trim(strings.TrimPrefix(err.Error(), "json: unknown field "), `"`)
+		field = FieldError{name, "unknown field"}
+	case errors.As(err, &syntaxErr):
+		writeJSONError(w, http.StatusBadRequest, fmt.Sprintf("invalid json at byte %d: %s", syntaxErr.Offset, syntaxErr))
+		return
+	case errors.Is(err, io.EOF):
+		http.Error(w, `{"error":"request body is empty"}`, http.StatusBadRequest)
+		return
+	default:
+		writeJSONError(w, http.StatusBadRequest, "invalid json: "+err.Error())
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusBadRequest)
+	json.NewEncoder(w).Encode(map[string]any{
+		"error":  "invalid json",
+		"fields": []FieldError{field},
+	})
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+// withStrictJSON turns STRICT_JSON on for one test
+func withStrictJSON(t *testing.T) {
+	t.Helper()
+	prev := strictJSON
+	strictJSON = true
+	t.Cleanup(func() { strictJSON = prev })
+}
+
+func TestIsJSONContentType(t *testing.T) {
+	tests := map[string]bool{
+		"application/json":                  true,
+		"application/json; charset=utf-8":   true,
+		"Application/JSON":                  true,
+		"application/merge-patch+json":      true,
+		"":                                  false,
+		"text/plain":                        false,
+		"application/x-www-form-urlencoded": false,
+	}
+	for value, want := range tests {
+		if got := isJSONContentType(value); got != want {
+			t.Errorf("isJSONContentType(%q) = %v, want %v", value, got, want)
+		}
+	}
+}
+
+func TestStrictJSON(t *testing.T) {
+	srv := newTestServer(t)
+
+	post := func(contentType, body string) (int, string) {
+		t.Helper()
+		req, _ := http.NewRequest(http.MethodPost, srv.URL+"/api/items", strings.NewReader(body))
+		if contentType != "" {
+			req.Header.Set("Content-Type", contentType)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		data, _ := io.ReadAll(resp.Body)
+		return resp.StatusCode, string(data)
+	}
+
+	// Lenient by default: no Content-Type, unknown fields ignored
+	if code, body := post("", `{"name":"a","descripton":"typo"}`); code != http.StatusCreated {
+		t.Fatalf("lenient create = %d %s", code, body)
+	}
+
+	withStrictJSON(t)
+	tests := []struct {
+		name        string
+		contentType string
+		body        string
+		wantCode    int
+		wantField   string
+	}{
+		{"no content type", "", `{"name":"a"}`, http.StatusUnsupportedMediaType, ""},
+		{"form content type", "application/x-www-form-urlencoded", `{"name":"a"}`, http.StatusUnsupportedMediaType, ""},
+		{"unknown field", "application/json", `{"name":"a","descripton":"typo"}`, http.StatusBadRequest, "descripton"},
+		{"wrong type", "application/json", `{"name":"a","tags":"x"}`, http.StatusBadRequest, "tags"},
+		{"trailing data", "application/json", `{"name":"a"} {"name":"b"}`, http.StatusBadRequest, ""},
+		{"syntax error", "application/json", `{"name":`, http.StatusBadRequest, ""},
+		{"valid", "application/json; charset=utf-8", `{"name":"a"}`, http.StatusCreated, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, body := post(tt.contentType, tt.body)
+			if code != tt.wantCode {
+				t.Fatalf("status = %d %s, want %d", code, body, tt.wantCode)
+			}
+			if tt.wantField == "" {
+				return
+			}
+			var resp struct {
+				Fields []FieldError `json:"fields"`
+			}
+			json.Unmarshal([]byte(body), &resp)
+			if len(resp.Fields) != 1 || resp.Fields[0].Field != tt.wantField {
+				t.Errorf("fields = %s, want %q", body, tt.wantField)
+			}
+		})
+	}
+}
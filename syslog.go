@@ -0,0 +1,201 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net"
+	"os"
+	"strings"
+	"time"
+)
+
+// =============================================================================
+// Syslog Output
+// =============================================================================
+//
+// Traditional SIEM tooling (rsyslog, syslog-ng, QRadar, ArcSight, ...) ingests
+// logs over the syslog protocol rather than HTTP. With LOG_SYSLOG_ADDR set,
+// every log record is also sent to a syslog server:
+//
+//	LOG_SYSLOG_ADDR=udp://siem.example.com:514   (plain host:port means UDP)
+//	LOG_SYSLOG_ADDR=tcp://siem.example.com:601
+//
+// Messages use the RFC 5424 format, with the same JSON body the webhook sends:
+//
+//	<134>1 2024-01-15T10:30:00Z web-1 demo-app 4242 - - {"level":"INFO","msg":"request",...}
+//
+// <134> is the "priority": facility local0 (16) * 8 + severity (6 = info).
+//
+// Go's log/syslog package isn't available on Windows, so the few lines of
+// formatting are done here instead.
+//
+// Like the webhook, this wraps another slog.Handler (see webhook.go for a
+// walkthrough of the slog.Handler interface). Sending happens in a background
+// goroutine so a slow or unreachable syslog server never blocks a request.
+
+// Facility local0 is reserved for custom applications
+const syslogFacilityLocal0 = 16
+
+// How many messages can wait for the sender before new ones are dropped
+const syslogQueueSize = 1000
+
+// syslogHandler wraps another slog.Handler and also ships records to syslog
+type syslogHandler struct {
+	underlying slog.Handler
+	sender     *syslogSender // shared by WithAttrs/WithGroup copies
+}
+
+// syslogSender owns the connection and the queue of formatted messages
+type syslogSender struct {
+	network  string // "udp" or "tcp"
+	addr     string // host:port
+	hostname string
+	queue    chan string
+	conn     net.Conn // nil until connected (or after a failed write)
+}
+
+// newSyslogHandler parses addr and starts the background sender.
+// No connection is made yet, so a syslog server that's down at startup
+// doesn't stop the app — it's retried on each message.
+func newSyslogHandler(underlying slog.Handler, addr string) (*syslogHandler, error) {
+	network, hostport, err := parseSyslogAddr(addr)
+	if err != nil {
+		return nil, err
+	}
+
+	hostname, _ := os.Hostname()
+	sender := &syslogSender{
+		network:  network,
+		addr:     hostport,
+		hostname: hostname,
+		queue:    make(chan string, syslogQueueSize),
+	}
+	go sender.run()
+
+	return &syslogHandler{underlying: underlying, sender: sender}, nil
+}
+
+// parseSyslogAddr splits "udp://host:port" into ("udp", "host:port").
+// No scheme means UDP, the traditional syslog transport.
+func parseSyslogAddr(addr string) (network, hostport string, err error) {
+	network, hostport, found := strings.Cut(addr, "://")
+	if !found {
+		network, hostport = "udp", addr
+	}
+	if network != "udp" && network != "tcp" {
+		return "", "", fmt.Errorf("unsupported syslog network %q (use udp or tcp)", network)
+	}
+	if _, _, err := net.SplitHostPort(hostport); err != nil {
+		return "", "", fmt.Errorf("invalid syslog address %q: %w", addr, err)
+	}
+	return network, hostport, nil
+}
+
+// =============================================================================
+// slog.Handler interface implementation
+// =============================================================================
+
+// Enabled delegates to the underlying handler
+func (s *syslogHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return s.underlying.Enabled(ctx, level)
+}
+
+// Handle writes to the underlying handler, then queues the record for syslog
+func (s *syslogHandler) Handle(ctx context.Context, record slog.Record) error {
+	if err := s.underlying.Handle(ctx, record); err != nil {
+		return err
+	}
+
+	// Non-blocking send: if the queue is full (server slow or down),
+	// drop the message rather than slow down the app
+	select {
+	case s.sender.queue <- s.sender.format(record):
+	default:
+	}
+	return nil
+}
+
+// WithAttrs wraps the underlying handler's result, keeping the same sender
+func (s *syslogHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &syslogHandler{underlying: s.underlying.WithAttrs(attrs), sender: s.sender}
+}
+
+// WithGroup wraps the underlying handler's result, keeping the same sender
+func (s *syslogHandler) WithGroup(name string) slog.Handler {
+	return &syslogHandler{underlying: s.underlying.WithGroup(name), sender: s.sender}
+}
+
+// =============================================================================
+// Syslog logic
+// =============================================================================
+
+// syslogSeverity maps slog levels to syslog severities (RFC 5424 section 6.2.1)
+func syslogSeverity(level slog.Level) int {
+	switch {
+	case level >= slog.LevelError:
+		return 3 // error
+	case level >= slog.LevelWarn:
+		return 4 // warning
+	case level >= slog.LevelInfo:
+		return 6 // informational
+	default:
+		return 7 // debug
+	}
+}
+
+// format builds one RFC 5424 message:
+//
+//	<PRI>VERSION TIMESTAMP HOSTNAME APP-NAME PROCID MSGID STRUCTURED-DATA MSG
+//
+// "-" means "no value" for MSGID and STRUCTURED-DATA.
+func (s *syslogSender) format(record slog.Record) string {
+	body, err := json.Marshal(buildLogEntry(record))
+	if err != nil {
+		body = []byte(record.Message)
+	}
+
+	priority := syslogFacilityLocal0*8 + syslogSeverity(record.Level)
+	return fmt.Sprintf("<%d>1 %s %s demo-app %d - - %s",
+		priority,
+		record.Time.UTC().Format(time.RFC3339Nano),
+		s.hostname,
+		os.Getpid(),
+		body,
+	)
+}
+
+// run sends queued messages until the process exits
+func (s *syslogSender) run() {
+	for msg := range s.queue {
+		s.send(msg)
+	}
+}
+
+// send writes one message, (re)connecting if needed.
+// Failures go to stderr — logging them with slog would loop back here.
+func (s *syslogSender) send(msg string) {
+	if s.conn == nil {
+		conn, err := net.DialTimeout(s.network, s.addr, 5*time.Second)
+		if err != nil {
+			println("syslog: failed to connect:", err.Error())
+			return
+		}
+		s.conn = conn
+	}
+
+	// UDP: one message per datagram. TCP is a stream, so messages are
+	// separated by newlines (RFC 6587 "non-transparent framing").
+	if s.network == "tcp" {
+		msg += "\n"
+	}
+
+	s.conn.SetWriteDeadline(time.Now().Add(5 * time.Second))
+	if _, err := s.conn.Write([]byte(msg)); err != nil {
+		println("syslog: failed to send:", err.Error())
+		// Drop the connection so the next message reconnects
+		s.conn.Close()
+		s.conn = nil
+	}
+}
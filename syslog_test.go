@@ -0,0 +1,104 @@
+package main
+
+import (
+	"bufio"
+	"io"
+	"log/slog"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseSyslogAddr(t *testing.T) {
+	tests := []struct {
+		addr     string
+		network  string
+		hostport string
+		wantErr  bool
+	}{
+		{"localhost:514", "udp", "localhost:514", false},
+		{"udp://siem:514", "udp", "siem:514", false},
+		{"tcp://siem:601", "tcp", "siem:601", false},
+		{"http://siem:514", "", "", true},
+		{"siem", "", "", true},
+	}
+
+	for _, tt := range tests {
+		network, hostport, err := parseSyslogAddr(tt.addr)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: expected error=%v, got %v", tt.addr, tt.wantErr, err)
+			continue
+		}
+		if network != tt.network || hostport != tt.hostport {
+			t.Errorf("%s: expected %s %s, got %s %s", tt.addr, tt.network, tt.hostport, network, hostport)
+		}
+	}
+}
+
+func TestSyslogHandler_UDP(t *testing.T) {
+	server, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	defer server.Close()
+
+	handler, err := newSyslogHandler(slog.NewJSONHandler(io.Discard, nil), server.LocalAddr().String())
+	if err != nil {
+		t.Fatalf("failed to create handler: %v", err)
+	}
+	slog.New(handler).Warn("disk almost full", "percent", 95)
+
+	buf := make([]byte, 4096)
+	server.SetReadDeadline(time.Now().Add(2 * time.Second))
+	n, err := server.Read(buf)
+	if err != nil {
+		t.Fatalf("no syslog message received: %v", err)
+	}
+	msg := string(buf[:n])
+
+	// local0 (16) * 8 + warning (4) = 132
+	if !strings.HasPrefix(msg, "<132>1 ") {
+		t.Errorf("expected <132>1 priority prefix, got %q", msg)
+	}
+	for _, want := range []string{" demo-app ", `"msg":"disk almost full"`, `"percent":95`} {
+		if !strings.Contains(msg, want) {
+			t.Errorf("expected %q in message, got %q", want, msg)
+		}
+	}
+}
+
+func TestSyslogHandler_TCP(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	defer listener.Close()
+
+	handler, err := newSyslogHandler(slog.NewJSONHandler(io.Discard, nil), "tcp://"+listener.Addr().String())
+	if err != nil {
+		t.Fatalf("failed to create handler: %v", err)
+	}
+	logger := slog.New(handler)
+	logger.Info("first")
+	logger.Error("second")
+
+	conn, err := listener.Accept()
+	if err != nil {
+		t.Fatalf("accept failed: %v", err)
+	}
+	defer conn.Close()
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+
+	// Messages are newline-framed on TCP
+	reader := bufio.NewReader(conn)
+	for _, want := range []string{"<134>1 ", "<131>1 "} {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			t.Fatalf("failed to read message: %v", err)
+		}
+		if !strings.HasPrefix(line, want) {
+			t.Errorf("expected prefix %q, got %q", want, line)
+		}
+	}
+}
@@ -0,0 +1,485 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	badger "github.com/dgraph-io/badger/v4"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// =============================================================================
+// Multi-Tenancy
+// =============================================================================
+//
+// One instance, many customers: with MULTI_TENANT=true every request belongs
+// to a tenant, named by its X-Tenant header or, with TENANT_DOMAIN set, by
+// its subdomain (acme.demo.example.com -> acme). A tenant sees only its own
+// items, item IDs, and display panel, so a SaaS isolation demo runs on a
+// single pod:
+//
+//	curl -X POST -H 'X-Tenant: acme' -d '{"name":"Widget"}' http://localhost:8080/api/items
+//	curl -H 'X-Tenant: globex' http://localhost:8080/api/items     # [] — acme's item isn't here
+//	curl http://localhost:8080/api/admin/tenants
+//	curl -X DELETE http://localhost:8080/api/admin/tenants/acme    # wipe everything of acme's
+//
+// Requests without a tenant belong to "default", which is the data the app
+// has always had, so turning the feature on changes nothing for them.
+// Everything another tenant stores lives under "tenant:<name>:" in BadgerDB
+// (items, the ID sequence, name and category index entries, idempotency
+// records, the list's ETag version), so a wipe is one DropPrefix. A tenant
+// comes into being with its first write, up to MAX_TENANTS of them.
+//
+// Endpoints whose data isn't split by tenant yet (categories, attachments,
+// links, counters, the K/V store, jobs, the display's sub-resources, and the
+// admin API) answer tenant requests with 400 instead of showing the default
+// tenant's data.
+
+// Requests without a tenant (or naming this one) use the unprefixed keys
+const defaultTenant = "default"
+
+// Header naming the tenant
+const tenantHeader = "X-Tenant"
+
+// Key prefixes: a tenant's data, and the list of tenants
+// ("tenants:acme" -> {"created_at": ...})
+const (
+	tenantKeyPrefix      = "tenant:"
+	tenantRegistryPrefix = "tenants:"
+)
+
+// Settings, from MULTI_TENANT, TENANT_DOMAIN and MAX_TENANTS (set in main)
+var (
+	multiTenant  bool
+	tenantDomain string
+	maxTenants   = 100
+)
+
+// Tenant names go into keys and metric labels: keep them short and plain
+var tenantNamePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9-]{0,62}$`)
+
+var (
+	errTenantLimit   = errors.New("tenant limit reached")
+	errTenantUnknown = errors.New("no such tenant")
+)
+
+// keyspace is where one tenant's data lives in BadgerDB. The zero value is
+// the default tenant, whose keys have no prefix.
+type keyspace struct {
+	tenant string
+}
+
+var rootKeyspace keyspace
+
+// keyspaceFor is the keyspace of a tenant name ("default" is the root)
+func keyspaceFor(tenant string) keyspace {
+	if tenant == defaultTenant {
+		return rootKeyspace
+	}
+	return keyspace{tenant: tenant}
+}
+
+// prefix is prepended to every key of the tenant
+func (k keyspace) prefix() string {
+	if k.tenant == "" {
+		return ""
+	}
+	return tenantKeyPrefix + k.tenant + ":"
+}
+
+// key is the tenant's copy of a key: "item:..." -> "tenant:acme:item:..."
+func (k keyspace) key(key string) []byte {
+	return []byte(k.prefix() + key)
+}
+
+// itemKey is itemKey (store.go) inside the tenant
+func (k keyspace) itemKey(id int64) []byte {
+	return k.key(string(itemKey(id)))
+}
+
+// sequence hands out the tenant's item IDs
+func (k keyspace) sequence() (*badger.Sequence, error) {
+	if k.tenant == "" {
+		return itemSeq, nil
+	}
+	return tenants.sequence(k)
+}
+
+// itemsGauge counts the tenant's items: demoapp_items_total for the
+// default tenant, demoapp_tenant_items_total for the others
+func (k keyspace) itemsGauge() prometheus.Gauge {
+	if k.tenant == "" {
+		return itemsTotal
+	}
+	return tenantItemsTotal.WithLabelValues(k.tenant)
+}
+
+type tenantKey struct{}
+
+// keyspaceFrom is the request's tenant, set by tenantMiddleware
+func keyspaceFrom(ctx context.Context) keyspace {
+	k, _ := ctx.Value(tenantKey{}).(keyspace)
+	return k
+}
+
+// requestTenant names the request's tenant: "" for the default one
+func requestTenant(r *http.Request) (string, error) {
+	if !multiTenant {
+		return "", nil
+	}
+	name := r.Header.Get(tenantHeader)
+	if name == "" && tenantDomain != "" {
+		host, _, err := net.SplitHostPort(r.Host)
+		if err != nil {
+			host = r.Host
+		}
+		// acme.demo.example.com -> acme; deeper subdomains don't count
+		if sub, ok := strings.CutSuffix(strings.ToLower(host), "."+tenantDomain); ok && !strings.Contains(sub, ".") {
+			name = sub
+		}
+	}
+	name = strings.ToLower(name)
+	if name == "" || name == defaultTenant {
+		return "", nil
+	}
+	if !tenantNamePattern.MatchString(name) {
+		return "", fmt.Errorf("tenant must be up to 63 lowercase letters, digits, and dashes")
+	}
+	return name, nil
+}
+
+// Routes whose data isn't split by tenant yet
+var tenantUnawarePrefixes = []string{
+	"/api/categories", "/api/counters", "/api/kv", "/api/jobs", "/api/display/", "/api/admin/",
+}
+
+// tenantAware reports whether a tenant request may use path
+func tenantAware(path string) bool {
+	// /api/items/:id is fine; its attachment and links aren't
+	if rest, ok := strings.CutPrefix(path, "/api/items/"); ok && strings.Contains(rest, "/") {
+		return false
+	}
+	for _, prefix := range tenantUnawarePrefixes {
+		if strings.HasPrefix(path, prefix) {
+			return false
+		}
+	}
+	return true
+}
+
+// tenantMiddleware puts the request's tenant in its context (see
+// keyspaceFrom). Called from loggingMiddleware, so every route gets it.
+func tenantMiddleware(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		tenant, err := requestTenant(r)
+		if err != nil {
+			w.Header().Set("Content-Type", "application/json")
+			writeJSONError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		if tenant != "" && !tenantAware(r.URL.Path) {
+			w.Header().Set("Content-Type", "application/json")
+			writeJSONError(w, http.StatusBadRequest, r.URL.Path+" isn't available per tenant")
+			return
+		}
+		next(w, r.WithContext(context.WithValue(r.Context(), tenantKey{}, keyspace{tenant: tenant})))
+	}
+}
+
+// recordTenantRequest counts a finished request for its tenant. Names that
+// were never written to share the "other" label, so a client can't grow
+// the metric by making names up.
+func recordTenantRequest(r *http.Request, status int) {
+	if !multiTenant {
+		return
+	}
+	tenant, err := requestTenant(r)
+	if err != nil {
+		return
+	}
+	label := defaultTenant
+	if tenant != "" {
+		label = "other"
+		if tenants.known(tenant) {
+			label = tenant
+		}
+	}
+	tenantRequestsTotal.WithLabelValues(label, statusClass(status)).Inc()
+}
+
+// tenantDisplay is a tenant's display panel, like displayData in store.go
+type tenantDisplay struct {
+	data    json.RawMessage
+	version int64
+}
+
+// tenantRegistry tracks the tenants: which exist, their ID sequences, and
+// their display panels
+type tenantRegistry struct {
+	mu      sync.Mutex
+	loaded  bool                 // names read from the database
+	created map[string]time.Time // known tenants
+	seqs    map[string]*badger.Sequence
+	display map[string]tenantDisplay
+}
+
+var tenants = &tenantRegistry{}
+
+// load reads the tenant list once per database; call with t.mu held
+func (t *tenantRegistry) load() {
+	if t.loaded {
+		return
+	}
+	if t.seqs == nil {
+		t.seqs = map[string]*badger.Sequence{}
+		t.display = map[string]tenantDisplay{}
+	}
+	created := map[string]time.Time{}
+	err := dbView("tenant_list", tenantRegistryPrefix, func(txn *badger.Txn) error {
+		it := txn.NewIterator(badger.DefaultIteratorOptions)
+		defer it.Close()
+		prefix := []byte(tenantRegistryPrefix)
+		for it.Seek(prefix); it.ValidForPrefix(prefix); it.Next() {
+			var info TenantInfo
+			it.Item().Value(func(val []byte) error { return json.Unmarshal(val, &info) })
+			created[strings.TrimPrefix(string(it.Item().Key()), tenantRegistryPrefix)] = info.CreatedAt
+		}
+		return nil
+	})
+	if err != nil {
+		slog.Warn("failed to load tenants", "error", err)
+		if t.created == nil {
+			t.created = created
+		}
+		return
+	}
+	t.created, t.loaded = created, true
+}
+
+// known reports whether the tenant exists
+func (t *tenantRegistry) known(tenant string) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.load()
+	_, ok := t.created[tenant]
+	return ok
+}
+
+// register creates the tenant on its first write, unless that would pass
+// MAX_TENANTS
+func (t *tenantRegistry) register(k keyspace) error {
+	if k.tenant == "" {
+		return nil
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.load()
+	if _, ok := t.created[k.tenant]; ok {
+		return nil
+	}
+	if len(t.created) >= maxTenants {
+		return fmt.Errorf("%w (MAX_TENANTS is %d)", errTenantLimit, maxTenants)
+	}
+
+	info := TenantInfo{Name: k.tenant, CreatedAt: time.Now().UTC()}
+	value, _ := json.Marshal(info) // a plain struct always marshals
+	key := tenantRegistryPrefix + k.tenant
+	if err := dbUpdate("tenant_create", key, func(txn *badger.Txn) error {
+		return txn.Set([]byte(key), value)
+	}); err != nil {
+		return err
+	}
+	t.created[k.tenant] = info.CreatedAt
+	slog.Info("tenant created", "tenant", k.tenant)
+	return nil
+}
+
+// sequence returns the tenant's ID sequence, opening it on first use
+func (t *tenantRegistry) sequence(k keyspace) (*badger.Sequence, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.load()
+	if seq, ok := t.seqs[k.tenant]; ok {
+		return seq, nil
+	}
+	seq, err := db.GetSequence(k.key("seq:items"), 100)
+	if err != nil {
+		return nil, err
+	}
+	t.seqs[k.tenant] = seq
+	return seq, nil
+}
+
+// displayData is the tenant's display panel and its version
+func (t *tenantRegistry) displayData(k keyspace) (json.RawMessage, int64) {
+	if k.tenant == "" {
+		return getDisplayDataVersion()
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.load()
+	d := t.display[k.tenant]
+	return d.data, d.version
+}
+
+// setDisplayData replaces the tenant's display panel and returns its version
+func (t *tenantRegistry) setDisplayData(k keyspace, data json.RawMessage) int64 {
+	if k.tenant == "" {
+		return setDisplayData(data)
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.load()
+	d := t.display[k.tenant]
+	d.data = data
+	d.version = max(time.Now().UnixNano(), d.version+1)
+	t.display[k.tenant] = d
+	return d.version
+}
+
+// wipe deletes the tenant and everything it stored
+func (t *tenantRegistry) wipe(tenant string) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.load()
+	if _, ok := t.created[tenant]; !ok {
+		return errTenantUnknown
+	}
+
+	k := keyspace{tenant: tenant}
+	if seq, ok := t.seqs[tenant]; ok {
+		// Release writes the unused lease back; do it before the drop
+		if err := seq.Release(); err != nil {
+			return err
+		}
+		delete(t.seqs, tenant)
+	}
+	err := timeDBOp("tenant_wipe", k.prefix(), func() error {
+		// The data prefix ends in ":", so "acme" doesn't take "acme-corp"
+		// with it. The registry key doesn't, so it's deleted by name.
+		if err := db.DropPrefix([]byte(k.prefix())); err != nil {
+			return err
+		}
+		return db.Update(func(txn *badger.Txn) error {
+			return txn.Delete([]byte(tenantRegistryPrefix + tenant))
+		})
+	})
+	if err != nil {
+		return err
+	}
+	delete(t.created, tenant)
+	delete(t.display, tenant)
+	tenantItemsTotal.DeleteLabelValues(tenant)
+	tenantRequestsTotal.DeletePartialMatch(map[string]string{"tenant": tenant})
+
+	// Followers in cluster mode start over (DropPrefix leaves nothing to pull)
+	dataGeneration.Add(1)
+	slog.Warn("tenant wiped", "tenant", tenant)
+	return nil
+}
+
+// reset forgets the cached tenants, releasing their sequences; the next
+// call reads them again from db
+func (t *tenantRegistry) reset() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for _, seq := range t.seqs {
+		seq.Release()
+	}
+	t.created, t.seqs, t.display = nil, nil, nil
+	t.loaded = false
+}
+
+// TenantInfo is one tenant in GET /api/admin/tenants
+type TenantInfo struct {
+	Name      string    `json:"name"`
+	Items     int       `json:"items"`
+	CreatedAt time.Time `json:"created_at,omitzero"`
+}
+
+// keyspaces are every tenant's keyspace, the default one first
+func (t *tenantRegistry) keyspaces() []keyspace {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.load()
+	ks := []keyspace{rootKeyspace}
+	for name := range t.created {
+		ks = append(ks, keyspace{tenant: name})
+	}
+	return ks
+}
+
+// list describes every tenant, the default one first
+func (t *tenantRegistry) list() ([]TenantInfo, error) {
+	t.mu.Lock()
+	t.loaded = false // read again: on a follower, the leader adds tenants
+	t.load()
+	list := []TenantInfo{{Name: defaultTenant}}
+	for name, created := range t.created {
+		list = append(list, TenantInfo{Name: name, CreatedAt: created})
+	}
+	t.mu.Unlock()
+
+	others := list[1:]
+	sort.Slice(others, func(i, j int) bool { return others[i].Name < others[j].Name })
+	for i := range list {
+		k := keyspaceFor(list[i].Name)
+		count, err := k.countItems()
+		if err != nil {
+			return nil, err
+		}
+		list[i].Items = count
+		if k != rootKeyspace {
+			tenantItemsTotal.WithLabelValues(k.tenant).Set(float64(count)) // resync the gauge
+		}
+	}
+	return list, nil
+}
+
+// tenantsAdminHandler handles /api/admin/tenants:
+//
+//	GET    /api/admin/tenants        -> every tenant with its item count
+//	DELETE /api/admin/tenants/:name  -> wipe one tenant
+func tenantsAdminHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	name := strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/admin/tenants"), "/")
+
+	switch {
+	case name == "" && r.Method == http.MethodGet:
+		list, err := tenants.list()
+		if err != nil {
+			logHandlerError(r.Context(), "tenants", "database", "failed to list tenants", "error", err)
+			http.Error(w, `{"error":"database error"}`, http.StatusInternalServerError)
+			return
+		}
+		json.NewEncoder(w).Encode(list)
+	case name != "" && r.Method == http.MethodDelete:
+		if name == defaultTenant {
+			http.Error(w, `{"error":"the default tenant can't be wiped; use /api/admin/reset"}`, http.StatusBadRequest)
+			return
+		}
+		err := tenants.wipe(name)
+		if errors.Is(err, errTenantUnknown) {
+			http.Error(w, `{"error":"not found"}`, http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			logHandlerError(r.Context(), "tenants", "database", "failed to wipe tenant", "tenant", name, "error", err)
+			http.Error(w, `{"error":"database error"}`, http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		http.Error(w, `{"error":"method not allowed"}`, http.StatusMethodNotAllowed)
+	}
+}
@@ -0,0 +1,259 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+// withMultiTenant turns MULTI_TENANT on for one test
+func withMultiTenant(t *testing.T) {
+	t.Helper()
+	prev, prevMax := multiTenant, maxTenants
+	multiTenant = true
+	t.Cleanup(func() { multiTenant, maxTenants = prev, prevMax })
+}
+
+// tenantRequest sends a request as a tenant ("" for none)
+func tenantRequest(t *testing.T, srv *httptest.Server, tenant, method, path, body string) (int, []byte) {
+	t.Helper()
+	req, _ := http.NewRequest(method, srv.URL+path, strings.NewReader(body))
+	if tenant != "" {
+		req.Header.Set(tenantHeader, tenant)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	data, _ := io.ReadAll(resp.Body)
+	return resp.StatusCode, data
+}
+
+func TestRequestTenant(t *testing.T) {
+	withMultiTenant(t)
+	prevDomain := tenantDomain
+	tenantDomain = "demo.example.com"
+	t.Cleanup(func() { tenantDomain = prevDomain })
+
+	tests := []struct {
+		header, host string
+		want         string
+		wantErr      bool
+	}{
+		{"", "localhost:8080", "", false},
+		{"Acme", "localhost", "acme", false},
+		{"default", "localhost", "", false},
+		{"", "globex.demo.example.com:8080", "globex", false},
+		{"", "a.b.demo.example.com", "", false},
+		{"acme", "globex.demo.example.com", "acme", false}, // the header wins
+		{"no:colons", "localhost", "", true},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest(http.MethodGet, "/api/items", nil)
+		r.Host = tt.host
+		if tt.header != "" {
+			r.Header.Set(tenantHeader, tt.header)
+		}
+		got, err := requestTenant(r)
+		if got != tt.want || (err != nil) != tt.wantErr {
+			t.Errorf("header %q host %q = %q, %v; want %q", tt.header, tt.host, got, err, tt.want)
+		}
+	}
+}
+
+func TestTenantIsolation(t *testing.T) {
+	withMultiTenant(t)
+	srv := newTestServer(t)
+
+	first := createTestItem(t, srv, `{"name":"default item"}`)
+	code, body := tenantRequest(t, srv, "acme", http.MethodPost, "/api/items", `{"name":"acme item"}`)
+	if code != http.StatusCreated {
+		t.Fatalf("create as acme = %d %s", code, body)
+	}
+	var created Item
+	json.Unmarshal(body, &created)
+	if created.ID != first.ID {
+		t.Errorf("acme's first item ID = %d, want %d (its own sequence)", created.ID, first.ID)
+	}
+
+	list := func(tenant string) []Item {
+		code, body := tenantRequest(t, srv, tenant, http.MethodGet, "/api/items", "")
+		if code != http.StatusOK {
+			t.Fatalf("list as %q = %d %s", tenant, code, body)
+		}
+		var items []Item
+		json.Unmarshal(body, &items)
+		return items
+	}
+	if items := list("acme"); len(items) != 1 || items[0].Name != "acme item" {
+		t.Errorf("acme sees %+v", items)
+	}
+	if items := list(""); len(items) != 1 || items[0].Name != "default item" {
+		t.Errorf("default tenant sees %+v", items)
+	}
+	if items := list("globex"); len(items) != 0 {
+		t.Errorf("globex sees %+v", items)
+	}
+
+	// Same ID, different tenants: each sees its own item
+	itemPath := fmt.Sprintf("/api/items/%d", created.ID)
+	if code, _ := tenantRequest(t, srv, "globex", http.MethodGet, itemPath, ""); code != http.StatusNotFound {
+		t.Errorf("globex GET %s = %d, want 404", itemPath, code)
+	}
+	if code, _ := tenantRequest(t, srv, "acme", http.MethodDelete, itemPath, ""); code != http.StatusNoContent {
+		t.Errorf("acme DELETE = %d", code)
+	}
+	if items := list(""); len(items) != 1 {
+		t.Errorf("acme's delete reached the default tenant: %+v", items)
+	}
+
+	// Display panels are per tenant too
+	tenantRequest(t, srv, "acme", http.MethodPost, "/api/display", `{"who":"acme"}`)
+	if _, body := doRequest(t, srv, http.MethodGet, "/api/display", ""); string(body) != "{}" {
+		t.Errorf("default display = %s", body)
+	}
+	if _, body := tenantRequest(t, srv, "acme", http.MethodGet, "/api/display", ""); !strings.Contains(string(body), "acme") {
+		t.Errorf("acme display = %s", body)
+	}
+
+	// Endpoints that don't know about tenants refuse rather than leak
+	if code, _ := tenantRequest(t, srv, "acme", http.MethodGet, "/api/categories", ""); code != http.StatusBadRequest {
+		t.Errorf("acme GET /api/categories = %d, want 400", code)
+	}
+	if code, _ := tenantRequest(t, srv, "Not Valid", http.MethodGet, "/api/items", ""); code != http.StatusBadRequest {
+		t.Errorf("invalid tenant = %d, want 400", code)
+	}
+}
+
+func TestTenantsAdmin(t *testing.T) {
+	withMultiTenant(t)
+	srv := newTestServer(t)
+	maxTenants = 2
+	before := testutil.ToFloat64(tenantRequestsTotal.WithLabelValues("acme", "2xx"))
+
+	for _, tenant := range []string{"acme", "globex"} {
+		tenantRequest(t, srv, tenant, http.MethodPost, "/api/items", `{"name":"x"}`)
+	}
+	if code, body := tenantRequest(t, srv, "initech", http.MethodPost, "/api/items", `{"name":"x"}`); code != http.StatusForbidden {
+		t.Errorf("third tenant with MAX_TENANTS=2 = %d %s, want 403", code, body)
+	}
+	if got := testutil.ToFloat64(tenantRequestsTotal.WithLabelValues("acme", "2xx")); got != before+1 {
+		t.Errorf("demoapp_tenant_requests_total{tenant=acme} = %v, want %v", got, before+1)
+	}
+
+	code, body := doRequest(t, srv, http.MethodGet, "/api/admin/tenants", "")
+	var list []TenantInfo
+	json.Unmarshal(body, &list)
+	if code != http.StatusOK || len(list) != 3 || list[0].Name != defaultTenant || list[1].Name != "acme" || list[1].Items != 1 {
+		t.Fatalf("GET /api/admin/tenants = %d %s", code, body)
+	}
+
+	if code, _ := doRequest(t, srv, http.MethodDelete, "/api/admin/tenants/acme", ""); code != http.StatusNoContent {
+		t.Errorf("wipe = %d, want 204", code)
+	}
+	if _, body := tenantRequest(t, srv, "acme", http.MethodGet, "/api/items", ""); string(body) != "[]\n" {
+		t.Errorf("acme after wipe = %s", body)
+	}
+	for path, want := range map[string]int{
+		"/api/admin/tenants/acme":    http.StatusNotFound,
+		"/api/admin/tenants/default": http.StatusBadRequest,
+	} {
+		if code, _ := doRequest(t, srv, http.MethodDelete, path, ""); code != want {
+			t.Errorf("DELETE %s = %d, want %d", path, code, want)
+		}
+	}
+}
+
+func TestTenantWipe_KeepsSimilarNames(t *testing.T) {
+	withMultiTenant(t)
+	srv := newTestServer(t)
+	tenantRequest(t, srv, "acme", http.MethodPost, "/api/items", `{"name":"x"}`)
+	tenantRequest(t, srv, "acme-corp", http.MethodPost, "/api/items", `{"name":"y"}`)
+
+	if err := tenants.wipe("acme"); err != nil {
+		t.Fatal(err)
+	}
+	tenants.reset() // read the registry back from the database
+	if !tenants.known("acme-corp") || tenants.known("acme") {
+		t.Errorf("after wiping acme: acme-corp known = %v, acme known = %v", tenants.known("acme-corp"), tenants.known("acme"))
+	}
+	if _, body := tenantRequest(t, srv, "acme-corp", http.MethodGet, "/api/items", ""); !strings.Contains(string(body), `"name":"y"`) {
+		t.Errorf("acme-corp items = %s", body)
+	}
+}
+
+func TestTenantRestoreAndReset(t *testing.T) {
+	withMultiTenant(t)
+	srv := newTestServer(t)
+	tenantRequest(t, srv, "acme", http.MethodPost, "/api/items", `{"name":"backed up"}`)
+	var backup bytes.Buffer
+	if _, err := db.Backup(&backup, 0); err != nil {
+		t.Fatal(err)
+	}
+
+	// Written after the backup: gone once it's restored
+	tenantRequest(t, srv, "acme", http.MethodPost, "/api/items", `{"name":"later"}`)
+	tenantRequest(t, srv, "globex", http.MethodPost, "/api/items", `{"name":"later"}`)
+	if err := restoreBadgerBackup(backup.Bytes()); err != nil {
+		t.Fatal(err)
+	}
+	if tenants.known("globex") {
+		t.Error("globex survived a restore from before it existed")
+	}
+	_, body := tenantRequest(t, srv, "acme", http.MethodGet, "/api/items", "")
+	var items []Item
+	json.Unmarshal(body, &items)
+	if len(items) != 1 || items[0].Name != "backed up" {
+		t.Fatalf("acme after restore = %s", body)
+	}
+
+	// acme's sequence continues from the restored value, past the item
+	_, body = tenantRequest(t, srv, "acme", http.MethodPost, "/api/items", `{"name":"new"}`)
+	var created Item
+	json.Unmarshal(body, &created)
+	if created.ID == items[0].ID {
+		t.Errorf("new acme item reused ID %d", created.ID)
+	}
+
+	if err := resetStore(); err != nil {
+		t.Fatal(err)
+	}
+	if tenants.known("acme") {
+		t.Error("acme survived a reset")
+	}
+}
+
+func TestRebuildNameIndex_Tenants(t *testing.T) {
+	withMultiTenant(t)
+	srv := newTestServer(t)
+
+	// Created while UNIQUE_ITEM_NAMES was off, so nothing is indexed yet
+	for _, tenant := range []string{"acme", "globex"} {
+		if code, body := tenantRequest(t, srv, tenant, http.MethodPost, "/api/items", `{"name":"Widget"}`); code != http.StatusCreated {
+			t.Fatalf("%s create = %d %s", tenant, code, body)
+		}
+	}
+
+	uniqueItemNames = true
+	t.Cleanup(func() { uniqueItemNames = false })
+	if err := rebuildNameIndex(); err != nil {
+		t.Fatalf("rebuild: %v", err)
+	}
+
+	for _, tenant := range []string{"acme", "globex"} {
+		if code, body := tenantRequest(t, srv, tenant, http.MethodPost, "/api/items", `{"name":"widget"}`); code != http.StatusConflict {
+			t.Errorf("%s duplicate after rebuild = %d %s, want 409", tenant, code, body)
+		}
+	}
+	if code, body := tenantRequest(t, srv, "", http.MethodPost, "/api/items", `{"name":"widget"}`); code != http.StatusCreated {
+		t.Errorf("default tenant = %d %s, want 201 (names are per tenant)", code, body)
+	}
+}
@@ -0,0 +1,161 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// =============================================================================
+// Request Deadlines
+// =============================================================================
+//
+// Every request gets a deadline (REQUEST_TIMEOUT, 30s by default), set on
+// its context with context.WithTimeout. Handlers pass that context on:
+// database reads stop before starting, writes are discarded instead of
+// committed, and outbound calls (leader, downstreams, S3) are cancelled.
+// A request that runs out of time answers 504:
+//
+//	REQUEST_TIMEOUT=2s ./demo-app
+//	curl -i http://localhost:8080/api/delay/5
+//	HTTP/1.1 504 Gateway Timeout
+//	{"error":"request timed out","timeout":"2s"}
+//
+// REQUEST_TIMEOUTS sets other limits by path prefix (the longest match wins,
+// 0 means none), for slow routes or to demo a tight one:
+//
+//	REQUEST_TIMEOUTS="/api/items=500ms,/api/admin/backup=0"
+//
+// Backup and restore get five minutes unless configured otherwise. The 504
+// only replaces a response that hasn't started: a stream already sending
+// (?format=ndjson) just ends. Long polls (longpoll.go) answer before the
+// deadline instead of running into it.
+
+// Deadline for requests without a route of their own (REQUEST_TIMEOUT, set
+// in main; 0 = no limit)
+var requestTimeout = 30 * time.Second
+
+// Deadlines by path prefix; REQUEST_TIMEOUTS adds to and overrides these
+var routeTimeouts = map[string]time.Duration{
+	"/api/admin/backup":  5 * time.Minute,
+	"/api/admin/restore": 5 * time.Minute,
+}
+
+// parseRouteTimeouts reads REQUEST_TIMEOUTS entries ("/prefix=duration") into
+// routeTimeouts. Entries that don't parse are skipped with a warning.
+func parseRouteTimeouts(entries []string) {
+	for _, entry := range entries {
+		prefix, value, ok := strings.Cut(entry, "=")
+		prefix = strings.TrimSpace(prefix)
+		timeout, err := time.ParseDuration(strings.TrimSpace(value))
+		if !ok || !strings.HasPrefix(prefix, "/") || err != nil || timeout < 0 {
+			slog.Warn("invalid route timeout, expected /path=duration", "entry", entry)
+			continue
+		}
+		routeTimeouts[prefix] = timeout
+	}
+}
+
+// timeoutFor is the deadline for a path: the longest matching prefix in
+// routeTimeouts, else requestTimeout
+func timeoutFor(path string) time.Duration {
+	timeout, longest := requestTimeout, -1
+	for prefix, t := range routeTimeouts {
+		if strings.HasPrefix(path, prefix) && len(prefix) > longest {
+			timeout, longest = t, len(prefix)
+		}
+	}
+	return timeout
+}
+
+// timeoutWriter swaps the handler's response for a 504 when the deadline
+// passed before the handler started answering. Whatever the handler writes
+// after that is dropped.
+type timeoutWriter struct {
+	http.ResponseWriter
+	r           *http.Request
+	timeout     time.Duration
+	wroteHeader bool
+	timedOut    bool
+}
+
+// WriteHeader answers 504 instead, if the deadline has passed
+func (tw *timeoutWriter) WriteHeader(code int) {
+	if tw.timedOut || tw.wroteHeader {
+		return
+	}
+	if errors.Is(tw.r.Context().Err(), context.DeadlineExceeded) {
+		tw.writeTimeout()
+		return
+	}
+	tw.wroteHeader = true
+	tw.ResponseWriter.WriteHeader(code)
+}
+
+// Write fails with http.ErrHandlerTimeout once the 504 is out, so loops
+// writing a response stop
+func (tw *timeoutWriter) Write(b []byte) (int, error) {
+	if !tw.wroteHeader && !tw.timedOut {
+		tw.WriteHeader(http.StatusOK)
+	}
+	if tw.timedOut {
+		return 0, http.ErrHandlerTimeout
+	}
+	return tw.ResponseWriter.Write(b)
+}
+
+// Unwrap lets http.NewResponseController reach Flush (see responseRecorder)
+func (tw *timeoutWriter) Unwrap() http.ResponseWriter {
+	return tw.ResponseWriter
+}
+
+// writeTimeout sends the 504, without the headers meant for the response
+// it replaces
+func (tw *timeoutWriter) writeTimeout() {
+	tw.timedOut = true
+	requestTimeoutsTotal.WithLabelValues(normalizePath(tw.r.URL.Path)).Inc()
+	slog.WarnContext(tw.r.Context(), "request timed out",
+		"method", tw.r.Method,
+		"path", tw.r.URL.Path,
+		"timeout", tw.timeout.String(),
+	)
+
+	header := tw.Header()
+	for _, name := range []string{"Content-Length", "Content-Encoding", "ETag", "Last-Modified", "Cache-Control"} {
+		header.Del(name)
+	}
+	header.Set("Content-Type", "application/json")
+	tw.ResponseWriter.WriteHeader(http.StatusGatewayTimeout)
+	json.NewEncoder(tw.ResponseWriter).Encode(map[string]string{
+		"error":   "request timed out",
+		"timeout": tw.timeout.String(),
+	})
+}
+
+// timeoutMiddleware gives the request its deadline and answers 504 when
+// the handler runs past it. loggingMiddleware calls it, so the 504 is
+// logged and counted like any other response.
+func timeoutMiddleware(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		timeout := timeoutFor(r.URL.Path)
+		if timeout <= 0 {
+			next(w, r)
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(r.Context(), timeout)
+		defer cancel()
+		r = r.WithContext(ctx)
+		tw := &timeoutWriter{ResponseWriter: w, r: r, timeout: timeout}
+		next(tw, r)
+
+		// A handler that gave up without answering (a cancelled sleep, say)
+		if !tw.wroteHeader && !tw.timedOut && errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			tw.writeTimeout()
+		}
+	}
+}
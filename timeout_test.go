@@ -0,0 +1,106 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	badger "github.com/dgraph-io/badger/v4"
+)
+
+// withRequestTimeout sets REQUEST_TIMEOUT for one test
+func withRequestTimeout(t *testing.T, timeout time.Duration) {
+	prev := requestTimeout
+	requestTimeout = timeout
+	t.Cleanup(func() { requestTimeout = prev })
+}
+
+func TestTimeoutFor(t *testing.T) {
+	withRequestTimeout(t, 30*time.Second)
+	prev := routeTimeouts
+	routeTimeouts = map[string]time.Duration{"/api/admin/backup": 5 * time.Minute}
+	t.Cleanup(func() { routeTimeouts = prev })
+
+	parseRouteTimeouts([]string{"/api/items=500ms", "/api/items/count=0", "/api/admin/backup=1m", "no-slash=1s", "/api/x=soon"})
+	tests := map[string]time.Duration{
+		"/api/items":        500 * time.Millisecond,
+		"/api/items/7":      500 * time.Millisecond,
+		"/api/items/count":  0,
+		"/api/admin/backup": time.Minute,
+		"/api/system":       30 * time.Second,
+		"/api/x":            30 * time.Second,
+	}
+	for path, want := range tests {
+		if got := timeoutFor(path); got != want {
+			t.Errorf("timeoutFor(%s) = %v, want %v", path, got, want)
+		}
+	}
+}
+
+func TestTimeoutMiddleware(t *testing.T) {
+	srv := newTestServer(t)
+	withRequestTimeout(t, 200*time.Millisecond)
+
+	// A handler that gives up when the context ends
+	code, body := doRequest(t, srv, http.MethodGet, "/api/delay/5", "")
+	var got map[string]string
+	json.Unmarshal(body, &got)
+	if code != http.StatusGatewayTimeout || got["error"] != "request timed out" || got["timeout"] != "200ms" {
+		t.Errorf("GET /api/delay/5 = %d %s, want 504", code, body)
+	}
+
+	// Fast requests are untouched
+	if code, body := doRequest(t, srv, http.MethodGet, "/api/delay/0", ""); code != http.StatusOK {
+		t.Errorf("GET /api/delay/0 = %d %s", code, body)
+	}
+
+	// A handler that ignores the context: its late answer is replaced
+	slow := timeoutMiddleware(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(300 * time.Millisecond)
+		w.Header().Set("ETag", `"late"`)
+		w.Write([]byte("late"))
+	})
+	rec := httptest.NewRecorder()
+	slow(rec, httptest.NewRequest(http.MethodGet, "/api/slow", nil))
+	if rec.Code != http.StatusGatewayTimeout || rec.Header().Get("ETag") != "" {
+		t.Errorf("late handler = %d (ETag %q) %s, want 504", rec.Code, rec.Header().Get("ETag"), rec.Body)
+	}
+}
+
+func TestDBUpdateContext_DiscardsWriteAfterDeadline(t *testing.T) {
+	newTestServer(t)
+	ctx, cancel := context.WithCancel(context.Background())
+
+	err := dbUpdateContext(ctx, "test", "test:key", func(txn *badger.Txn) error {
+		cancel() // the request ends while the transaction runs
+		return txn.Set([]byte("test:key"), []byte("value"))
+	})
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("dbUpdateContext = %v, want context.Canceled", err)
+	}
+	err = dbView("test", "test:key", func(txn *badger.Txn) error {
+		_, err := txn.Get([]byte("test:key"))
+		return err
+	})
+	if !errors.Is(err, badger.ErrKeyNotFound) {
+		t.Errorf("key after a cancelled update: %v, want not found", err)
+	}
+
+	if err := dbViewContext(ctx, "test", "test:key", func(*badger.Txn) error { return nil }); !errors.Is(err, context.Canceled) {
+		t.Errorf("dbViewContext after cancel = %v", err)
+	}
+}
+
+func TestLongPoll_EndsBeforeDeadline(t *testing.T) {
+	srv := newTestServer(t)
+	withRequestTimeout(t, 1500*time.Millisecond)
+
+	code, _, took := longPollGet(t, srv.URL+"/api/items?wait=30s", itemsVersionHeader)
+	if code != http.StatusOK || took > time.Second {
+		t.Errorf("long poll under a 1.5s deadline = %d after %v, want 200 after about 500ms", code, took)
+	}
+}
@@ -0,0 +1,252 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	badger "github.com/dgraph-io/badger/v4"
+)
+
+// =============================================================================
+// Item Creation Time Series
+// =============================================================================
+//
+// How fast are items being created? During a load demo the dashboard charts
+// it from GET /api/items/timeseries, which counts creations per step:
+//
+//	curl 'http://localhost:8080/api/items/timeseries?window=24h&step=1h'
+//	{"window":"24h0m0s","step":"1h0m0s","start":"2026-05-01T11:00:00Z",
+//	 "end":"2026-05-02T11:00:00Z","total":5230,
+//	 "points":[{"time":"2026-05-01T11:00:00Z","count":0},...,
+//	           {"time":"2026-05-02T10:00:00Z","count":4100}]}
+//
+// window defaults to 1h and step to 1m. The last point is the step that's
+// still going, so it fills up as you watch.
+//
+// Counts are kept per minute (UTC), in keys named after the minute:
+//
+//	ts:items:2026-05-02T10:41            folded count for the minute
+//	ts:items:2026-05-02T10:41:<time>-<n> one per creation, not yet folded
+//
+// Like the item aggregates (aggregates.go), each creation writes its own key
+// in the item's transaction instead of incrementing the minute's count, so
+// a burst of creates never conflicts over it. The aggregates job folds them
+// into the minute's count every 10s, and a read adds up both. Steps are
+// whole minutes, so any step is a sum of minutes.
+//
+// Every key expires ITEM_TIMESERIES_RETENTION (default 7 days) after its
+// minute (a BadgerDB TTL, see idempotency.go), which is also the longest
+// window. Deleting or updating items leaves the counts alone: this is how
+// many were created, not how many are left.
+
+// Keys: ts:items:<minute>, and ts:items:<minute>:<time>-<n> per creation
+const (
+	itemTimeseriesPrefix = "ts:items:"
+	timeseriesMinute     = "2006-01-02T15:04"
+)
+
+// Defaults and limits for GET /api/items/timeseries
+const (
+	defaultTimeseriesWindow = time.Hour
+	defaultTimeseriesStep   = time.Minute
+	maxTimeseriesPoints     = 1440 // a day of minutes
+)
+
+// itemTimeseriesRetention is ITEM_TIMESERIES_RETENTION (set in main)
+var itemTimeseriesRetention = 7 * 24 * time.Hour
+
+// timeseriesSeq makes creation keys written in the same nanosecond unique
+var timeseriesSeq atomic.Uint64
+
+// timeseriesBucketKey is the folded count key of the minute t falls in
+func timeseriesBucketKey(t time.Time) string {
+	return itemTimeseriesPrefix + t.UTC().Format(timeseriesMinute)
+}
+
+// parseTimeseriesKey splits a key (without the tenant prefix) into its
+// minute, and whether it's a folded count rather than one creation
+func parseTimeseriesKey(key string) (time.Time, bool, error) {
+	rest := key[len(itemTimeseriesPrefix):]
+	if len(rest) < len(timeseriesMinute) {
+		return time.Time{}, false, fmt.Errorf("bad time series key %q", key)
+	}
+	minute, err := time.Parse(timeseriesMinute, rest[:len(timeseriesMinute)])
+	return minute, len(rest) == len(timeseriesMinute), err
+}
+
+// recordItemCreation counts an item created at createdAt, inside the
+// transaction that creates it. Items older than the retention (an import
+// of old data) aren't counted; their minute has already expired.
+func (k keyspace) recordItemCreation(txn *badger.Txn, createdAt time.Time) error {
+	ttl := time.Until(createdAt.Truncate(time.Minute).Add(itemTimeseriesRetention))
+	if ttl <= 0 {
+		return nil
+	}
+	key := fmt.Sprintf("%s:%019d-%06d", timeseriesBucketKey(createdAt), time.Now().UnixNano(), timeseriesSeq.Add(1)%1_000_000)
+	return txn.SetEntry(badger.NewEntry(k.key(key), nil).WithTTL(ttl))
+}
+
+// timeseriesKeys walks the tenant's time series keys from the minute from
+// falls in, calling fn with each key's minute and whether it's a folded
+// count. Values aren't read unless fn asks the item for one.
+func (k keyspace) timeseriesKeys(txn *badger.Txn, from time.Time, fn func(minute time.Time, folded bool, item *badger.Item) (bool, error)) error {
+	prefix := k.key(itemTimeseriesPrefix)
+	it := txn.NewIterator(badger.IteratorOptions{Prefix: prefix})
+	defer it.Close()
+	for it.Seek(k.key(timeseriesBucketKey(from))); it.ValidForPrefix(prefix); it.Next() {
+		minute, folded, err := parseTimeseriesKey(string(it.Item().Key()[len(k.prefix()):]))
+		if err != nil {
+			return err
+		}
+		more, err := fn(minute, folded, it.Item())
+		if err != nil || !more {
+			return err
+		}
+	}
+	return nil
+}
+
+// foldItemTimeseries folds up to maxAggregateFold creation keys into their
+// minutes' counts, deleting them. Only the aggregates job calls it, so it's
+// the only writer of the counts.
+func (k keyspace) foldItemTimeseries() error {
+	return dbUpdate("timeseries_fold", itemTimeseriesPrefix, func(txn *badger.Txn) error {
+		folded := map[time.Time]uint64{}  // counts so far
+		pending := map[time.Time]uint64{} // creations to add
+		var keys [][]byte
+		err := k.timeseriesKeys(txn, time.Time{}, func(minute time.Time, isCount bool, item *badger.Item) (bool, error) {
+			if isCount {
+				return true, item.Value(func(val []byte) error {
+					folded[minute] = decodeUint64(val)
+					return nil
+				})
+			}
+			if len(keys) >= maxAggregateFold {
+				return false, nil
+			}
+			pending[minute]++
+			keys = append(keys, item.KeyCopy(nil))
+			return true, nil
+		})
+		if err != nil || len(keys) == 0 {
+			return err
+		}
+
+		for _, key := range keys {
+			if err := txn.Delete(key); err != nil {
+				return err
+			}
+		}
+		for minute, count := range pending {
+			ttl := time.Until(minute.Add(itemTimeseriesRetention))
+			if ttl <= 0 {
+				continue
+			}
+			entry := badger.NewEntry(k.key(timeseriesBucketKey(minute)), encodeUint64(folded[minute]+count)).WithTTL(ttl)
+			if err := txn.SetEntry(entry); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// TimeseriesPoint is one step in GET /api/items/timeseries
+type TimeseriesPoint struct {
+	Time  time.Time `json:"time"` // start of the step
+	Count uint64    `json:"count"`
+}
+
+// ItemTimeseries is GET /api/items/timeseries
+type ItemTimeseries struct {
+	Window string            `json:"window"`
+	Step   string            `json:"step"`
+	Start  time.Time         `json:"start"`
+	End    time.Time         `json:"end"`
+	Total  uint64            `json:"total"`
+	Points []TimeseriesPoint `json:"points"`
+}
+
+// itemTimeseries counts the tenant's creations per step over the window
+// ending with the step now falls in
+func (k keyspace) itemTimeseries(window, step time.Duration, now time.Time) (ItemTimeseries, error) {
+	n := int((window + step - 1) / step)
+	end := now.UTC().Truncate(step).Add(step)
+	start := end.Add(-time.Duration(n) * step)
+	series := ItemTimeseries{
+		Window: window.String(),
+		Step:   step.String(),
+		Start:  start,
+		End:    end,
+		Points: make([]TimeseriesPoint, n),
+	}
+	for i := range series.Points {
+		series.Points[i].Time = start.Add(time.Duration(i) * step)
+	}
+
+	err := dbView("timeseries_read", itemTimeseriesPrefix, func(txn *badger.Txn) error {
+		return k.timeseriesKeys(txn, start, func(minute time.Time, folded bool, item *badger.Item) (bool, error) {
+			if !minute.Before(end) {
+				return false, nil
+			}
+			count := uint64(1)
+			if folded {
+				if err := item.Value(func(val []byte) error {
+					count = decodeUint64(val)
+					return nil
+				}); err != nil {
+					return false, err
+				}
+			}
+			series.Points[int(minute.Sub(start)/step)].Count += count
+			series.Total += count
+			return true, nil
+		})
+	})
+	return series, err
+}
+
+// itemsTimeseriesHandler handles GET /api/items/timeseries?window=&step=
+func itemsTimeseriesHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, `{"error":"method not allowed"}`, http.StatusMethodNotAllowed)
+		return
+	}
+
+	window, step := defaultTimeseriesWindow, defaultTimeseriesStep
+	for name, value := range map[string]*time.Duration{"window": &window, "step": &step} {
+		if raw := r.URL.Query().Get(name); raw != "" {
+			d, err := time.ParseDuration(raw)
+			if err != nil || d <= 0 {
+				writeJSONError(w, http.StatusBadRequest, name+" must be a positive duration, like 1h")
+				return
+			}
+			*value = d
+		}
+	}
+	switch {
+	case step%time.Minute != 0:
+		writeJSONError(w, http.StatusBadRequest, "step must be a whole number of minutes")
+		return
+	case window < step || window > itemTimeseriesRetention:
+		writeJSONError(w, http.StatusBadRequest, "window must be at least step and at most "+itemTimeseriesRetention.String())
+		return
+	case window/step > maxTimeseriesPoints:
+		writeJSONError(w, http.StatusBadRequest, fmt.Sprintf("window/step must be at most %d points", maxTimeseriesPoints))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+
+	series, err := keyspaceFrom(r.Context()).itemTimeseries(window, step, time.Now())
+	if err != nil {
+		logHandlerError(r.Context(), "items", "database", "failed to read item time series", "error", err)
+		http.Error(w, `{"error":"database error"}`, http.StatusInternalServerError)
+		return
+	}
+
+	json.NewEncoder(w).Encode(series)
+}
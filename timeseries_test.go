@@ -0,0 +1,99 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestItemTimeseries(t *testing.T) {
+	srv := newTestServer(t)
+
+	// Now, earlier today, yesterday, and past the 7-day retention
+	now := time.Now().UTC()
+	for _, ago := range []time.Duration{0, 0, 2 * time.Hour, 30 * time.Hour, 8 * 24 * time.Hour} {
+		if _, _, err := insertItemAt(itemInput{Name: "x"}, "", now.Add(-ago)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	series := func(query string) ItemTimeseries {
+		t.Helper()
+		code, body := doRequest(t, srv, http.MethodGet, "/api/items/timeseries"+query, "")
+		if code != http.StatusOK {
+			t.Fatalf("expected 200, got %d: %s", code, body)
+		}
+		var s ItemTimeseries
+		if err := json.Unmarshal(body, &s); err != nil {
+			t.Fatal(err)
+		}
+		return s
+	}
+	check := func(when string) {
+		t.Helper()
+		day := series("?window=24h&step=1h")
+		if len(day.Points) != 24 || day.Total != 3 || day.Points[23].Count != 2 {
+			t.Errorf("%s: 24h by hour = total %d, %d points, last %+v", when, day.Total, len(day.Points), day.Points[len(day.Points)-1])
+		}
+		if !day.End.Equal(day.Points[23].Time.Add(time.Hour)) || day.End.Sub(day.Start) != 24*time.Hour {
+			t.Errorf("%s: start %v, end %v", when, day.Start, day.End)
+		}
+		week := series("?window=168h&step=24h")
+		if week.Total != 4 {
+			t.Errorf("%s: 7d by day = total %d, want 4", when, week.Total)
+		}
+		// Defaults: the last hour by minute
+		hour := series("")
+		if len(hour.Points) != 60 || hour.Total != 2 || hour.Step != "1m0s" {
+			t.Errorf("%s: default = total %d, %d points, step %s", when, hour.Total, len(hour.Points), hour.Step)
+		}
+	}
+
+	check("before folding")
+	if err := rootKeyspace.foldItemTimeseries(); err != nil {
+		t.Fatal(err)
+	}
+	check("after folding")
+
+	// A later creation adds to the folded minute
+	createTestItem(t, srv, `{"name":"y"}`)
+	if got := series("?window=1h&step=1h"); got.Total != 3 {
+		t.Errorf("after another create: total %d, want 3", got.Total)
+	}
+	rootKeyspace.foldItemTimeseries()
+	if got := series("?window=1h&step=1h"); got.Total != 3 {
+		t.Errorf("after folding again: total %d, want 3", got.Total)
+	}
+}
+
+func TestItemTimeseries_BadParams(t *testing.T) {
+	srv := newTestServer(t)
+	for _, query := range []string{
+		"?window=soon",
+		"?step=-1m",
+		"?step=30s",           // not whole minutes
+		"?window=1m&step=1h",  // window shorter than a step
+		"?window=720h",        // longer than the retention
+		"?window=48h&step=1m", // too many points
+	} {
+		if code, body := doRequest(t, srv, http.MethodGet, "/api/items/timeseries"+query, ""); code != http.StatusBadRequest {
+			t.Errorf("%s: expected 400, got %d: %s", query, code, body)
+		}
+	}
+}
+
+func TestItemTimeseries_PerTenant(t *testing.T) {
+	withMultiTenant(t)
+	srv := newTestServer(t)
+
+	createTestItem(t, srv, `{"name":"a"}`)
+	tenantRequest(t, srv, "acme", http.MethodPost, "/api/items", `{"name":"b"}`)
+	tenantRequest(t, srv, "acme", http.MethodPost, "/api/items", `{"name":"c"}`)
+
+	root, _ := rootKeyspace.itemTimeseries(time.Hour, time.Minute, time.Now())
+	acme, _ := keyspaceFor("acme").itemTimeseries(time.Hour, time.Minute, time.Now())
+	if root.Total != 1 || acme.Total != 2 {
+		t.Errorf("root total = %d, acme total = %d", root.Total, acme.Total)
+	}
+}
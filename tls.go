@@ -0,0 +1,188 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// =============================================================================
+// TLS and Mutual TLS
+// =============================================================================
+//
+// Without a service mesh, the app can terminate TLS itself and, for
+// zero-trust demos, require every client to present a certificate signed by
+// a CA you choose (mutual TLS):
+//
+//	TLS_CERT_FILE=server.pem TLS_KEY_FILE=server-key.pem \
+//	TLS_CLIENT_CA_FILE=ca.pem ./demo-app
+//	curl --cacert ca.pem https://localhost:8080/api/system                 # handshake fails
+//	curl --cacert ca.pem --cert client.pem --key client-key.pem \
+//	  https://localhost:8080/api/system
+//	{..., "client_cert": {"subject": "CN=billing,O=demo", "issuer": "CN=demo-ca", ...}}
+//
+// TLS_CLIENT_AUTH=optional asks for a certificate but lets clients without
+// one in (a browser on the dashboard, a kubelet probe); a certificate that
+// is sent must still verify. The verified subject is in /api/system and on
+// every request log line, so "who called" is visible next to "what".
+//
+// The certificate files are watched (configwatch.go), so a renewed
+// certificate (cert-manager rewriting its Secret, say) is used from the next
+// handshake on. Instead of files, the certificate and key can be PEM secrets
+// named TLS_CERT and TLS_KEY in Vault or SECRETS_FILE (secrets.go), which
+// rotate the same way.
+//
+// Kubelet HTTP probes can't present a certificate, so with the default
+// (require) use an exec probe instead:
+//
+//	./demo-app healthcheck --cert client.pem --key client-key.pem --ca ca.pem
+
+// Client certificate modes for TLS_CLIENT_AUTH
+const (
+	clientAuthRequire  = "require"
+	clientAuthOptional = "optional"
+)
+
+// loadServerTLS builds the listener's TLS config from TLS_CERT_FILE,
+// TLS_KEY_FILE, TLS_CLIENT_CA_FILE and TLS_CLIENT_AUTH, or the TLS_CERT and
+// TLS_KEY secrets (secrets.go). It returns nil when no certificate is
+// configured (plain HTTP).
+func loadServerTLS(certFile, keyFile, clientCAFile, clientAuth string) (*tls.Config, error) {
+	config := &tls.Config{MinVersion: tls.VersionTLS12}
+	switch {
+	case certFile != "" || keyFile != "":
+		if err := loadCertificateFiles(certFile, keyFile); err != nil {
+			return nil, err
+		}
+		config.GetCertificate = func(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+			return fileCert.Load(), nil
+		}
+	case secret("TLS_CERT") != "":
+		// PEM from Vault or SECRETS_FILE; each handshake uses the current one
+		if _, err := secretCertificate(); err != nil {
+			return nil, fmt.Errorf("TLS_CERT and TLS_KEY secrets: %w", err)
+		}
+		config.GetCertificate = func(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+			return secretCertificate()
+		}
+	default:
+		if clientCAFile != "" {
+			return nil, fmt.Errorf("TLS_CLIENT_CA_FILE needs TLS_CERT_FILE and TLS_KEY_FILE")
+		}
+		return nil, nil
+	}
+	if clientCAFile == "" {
+		return config, nil
+	}
+
+	pool, err := loadCertPool(clientCAFile)
+	if err != nil {
+		return nil, fmt.Errorf("TLS_CLIENT_CA_FILE: %w", err)
+	}
+	config.ClientCAs = pool
+	switch clientAuth {
+	case "", clientAuthRequire:
+		config.ClientAuth = tls.RequireAndVerifyClientCert
+	case clientAuthOptional:
+		config.ClientAuth = tls.VerifyClientCertIfGiven
+	default:
+		return nil, fmt.Errorf("TLS_CLIENT_AUTH must be require or optional, got %q", clientAuth)
+	}
+	return config, nil
+}
+
+// The certificate from TLS_CERT_FILE and TLS_KEY_FILE
+var fileCert atomic.Pointer[tls.Certificate]
+
+// loadCertificateFiles reads the certificate and key files and makes them
+// the server certificate. On error the current one is kept.
+func loadCertificateFiles(certFile, keyFile string) error {
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return fmt.Errorf("server certificate: %w", err)
+	}
+	fileCert.Store(&cert)
+	return nil
+}
+
+// The certificate parsed from the TLS_CERT and TLS_KEY secrets, kept until
+// they change
+var secretCert struct {
+	mu              sync.Mutex
+	certPEM, keyPEM string
+	cert            *tls.Certificate
+}
+
+// secretCertificate returns the certificate in the TLS_CERT and TLS_KEY
+// secrets. A rotated pair that doesn't parse is logged, and the last good
+// certificate is kept.
+func secretCertificate() (*tls.Certificate, error) {
+	certPEM, keyPEM := secret("TLS_CERT"), secret("TLS_KEY")
+	secretCert.mu.Lock()
+	defer secretCert.mu.Unlock()
+	if certPEM == secretCert.certPEM && keyPEM == secretCert.keyPEM {
+		return secretCert.cert, nil
+	}
+	cert, err := tls.X509KeyPair([]byte(certPEM), []byte(keyPEM))
+	if err != nil && secretCert.cert == nil {
+		return nil, err
+	}
+	secretCert.certPEM, secretCert.keyPEM = certPEM, keyPEM // don't retry a bad pair every handshake
+	if err != nil {
+		slog.Warn("new TLS_CERT/TLS_KEY secrets are invalid, keeping the old certificate", "error", err)
+		return secretCert.cert, nil
+	}
+	secretCert.cert = &cert
+	return secretCert.cert, nil
+}
+
+// loadCertPool reads PEM certificates into a pool
+func loadCertPool(filename string) (*x509.CertPool, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(data) {
+		return nil, fmt.Errorf("no PEM certificates in %s", filename)
+	}
+	return pool, nil
+}
+
+// ClientCert is the verified client certificate on a mutual TLS request
+type ClientCert struct {
+	Subject  string    `json:"subject"`
+	Issuer   string    `json:"issuer"`
+	Serial   string    `json:"serial"`
+	NotAfter time.Time `json:"not_after"`
+	DNSNames []string  `json:"dns_names,omitempty"`
+	URIs     []string  `json:"uris,omitempty"`
+	SPIFFEID string    `json:"spiffe_id,omitempty"` // the spiffe:// URI, if any (spiffe.go)
+}
+
+// clientCert describes the request's client certificate, or nil without
+// one. Only verified certificates count, so the subject can be trusted.
+func clientCert(r *http.Request) *ClientCert {
+	if r.TLS == nil || len(r.TLS.VerifiedChains) == 0 {
+		return nil
+	}
+	leaf := r.TLS.VerifiedChains[0][0]
+	cert := &ClientCert{
+		Subject:  leaf.Subject.String(),
+		Issuer:   leaf.Issuer.String(),
+		Serial:   leaf.SerialNumber.String(),
+		NotAfter: leaf.NotAfter.UTC(),
+		DNSNames: leaf.DNSNames,
+	}
+	for _, u := range leaf.URIs {
+		cert.URIs = append(cert.URIs, u.String())
+	}
+	cert.SPIFFEID = spiffeID(leaf)
+	return cert
+}
@@ -0,0 +1,220 @@
+package main
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testCert is a certificate and key, in memory and as PEM files
+type testCert struct {
+	cert              *x509.Certificate
+	key               *ecdsa.PrivateKey
+	certFile, keyFile string
+}
+
+// issueTestCert signs a certificate with parent (self-signed when nil) and
+// writes it to dir
+func issueTestCert(t *testing.T, dir, name string, parent *testCert, template *x509.Certificate) *testCert {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template.SerialNumber = big.NewInt(time.Now().UnixNano())
+	template.Subject = pkix.Name{CommonName: name, Organization: []string{"demo"}}
+	template.NotBefore = time.Now().Add(-time.Hour)
+	template.NotAfter = time.Now().Add(time.Hour)
+	signer, signerKey := template, key
+	if parent != nil {
+		signer, signerKey = parent.cert, parent.key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, signer, &key.PublicKey, signerKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, _ := x509.ParseCertificate(der)
+	keyDER, _ := x509.MarshalECPrivateKey(key)
+
+	tc := &testCert{cert: cert, key: key,
+		certFile: filepath.Join(dir, name+".pem"), keyFile: filepath.Join(dir, name+"-key.pem")}
+	os.WriteFile(tc.certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600)
+	os.WriteFile(tc.keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0o600)
+	return tc
+}
+
+// newMTLSServer serves the app's routes over TLS with TLS_CLIENT_AUTH set
+// to clientAuth, and returns the CA and a client certificate it signed
+func newMTLSServer(t *testing.T, clientAuth string) (srv *httptest.Server, ca, client *testCert) {
+	t.Helper()
+	newTestServer(t)
+	mux := http.NewServeMux()
+	if err := registerRoutes(mux); err != nil {
+		t.Fatal(err)
+	}
+
+	dir := t.TempDir()
+	ca = issueTestCert(t, dir, "demo-ca", nil, &x509.Certificate{IsCA: true, BasicConstraintsValid: true,
+		KeyUsage: x509.KeyUsageCertSign})
+	server := issueTestCert(t, dir, "server", ca, &x509.Certificate{IPAddresses: []net.IP{net.IPv4(127, 0, 0, 1)},
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth}})
+	spiffeID, _ := url.Parse("spiffe://demo.example/billing")
+	client = issueTestCert(t, dir, "billing", ca, &x509.Certificate{URIs: []*url.URL{spiffeID},
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth}})
+
+	config, err := loadServerTLS(server.certFile, server.keyFile, ca.certFile, clientAuth)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Wrap the listener like main does: StartTLS would add httptest's own
+	// certificate, which wins over GetCertificate for clients without SNI
+	srv = httptest.NewUnstartedServer(mux)
+	srv.Listener = tls.NewListener(srv.Listener, config)
+	srv.Start()
+	srv.URL = strings.Replace(srv.URL, "http://", "https://", 1)
+	t.Cleanup(srv.Close)
+	return srv, ca, client
+}
+
+// mtlsClient trusts ca and presents cert, if given
+func mtlsClient(ca, cert *testCert) *http.Client {
+	pool := x509.NewCertPool()
+	pool.AddCert(ca.cert)
+	config := &tls.Config{RootCAs: pool}
+	if cert != nil {
+		// Always send it, even when the server asked for another CA
+		config.GetClientCertificate = func(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+			return &tls.Certificate{Certificate: [][]byte{cert.cert.Raw}, PrivateKey: cert.key}, nil
+		}
+	}
+	return &http.Client{Transport: &http.Transport{TLSClientConfig: config}}
+}
+
+// systemClientCert fetches /api/system and returns its client_cert
+func systemClientCert(t *testing.T, client *http.Client, srv *httptest.Server) *ClientCert {
+	t.Helper()
+	resp, err := client.Get(srv.URL + "/api/system")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	var body struct {
+		ClientCert *ClientCert `json:"client_cert"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		t.Fatal(err)
+	}
+	return body.ClientCert
+}
+
+func TestTLS_RequireClientCert(t *testing.T) {
+	srv, ca, client := newMTLSServer(t, "")
+
+	if resp, err := mtlsClient(ca, nil).Get(srv.URL + "/health"); err == nil {
+		resp.Body.Close()
+		t.Fatal("request without a client certificate got through")
+	}
+
+	cert := systemClientCert(t, mtlsClient(ca, client), srv)
+	if cert == nil || cert.Subject != "CN=billing,O=demo" || cert.Issuer != "CN=demo-ca,O=demo" ||
+		len(cert.URIs) != 1 || cert.URIs[0] != "spiffe://demo.example/billing" {
+		t.Errorf("client_cert = %+v", cert)
+	}
+
+	// The healthcheck subcommand can present a certificate too
+	var out bytes.Buffer
+	args := []string{"--url", srv.URL, "--cert", client.certFile, "--key", client.keyFile, "--ca", ca.certFile}
+	if code := runHealthcheck(args, &out); code != 0 {
+		t.Errorf("healthcheck with a client certificate: exit %d, %s", code, out.String())
+	}
+}
+
+func TestTLS_OptionalClientCert(t *testing.T) {
+	srv, ca, client := newMTLSServer(t, clientAuthOptional)
+
+	if cert := systemClientCert(t, mtlsClient(ca, nil), srv); cert != nil {
+		t.Errorf("client_cert without a certificate = %+v, want null", cert)
+	}
+	if cert := systemClientCert(t, mtlsClient(ca, client), srv); cert == nil {
+		t.Error("client_cert is null with a certificate")
+	}
+
+	// A certificate that is sent must still verify
+	stranger := issueTestCert(t, t.TempDir(), "stranger", nil, &x509.Certificate{})
+	if resp, err := mtlsClient(ca, stranger).Get(srv.URL + "/health"); err == nil {
+		resp.Body.Close()
+		t.Error("self-signed client certificate got through")
+	}
+}
+
+func TestTLS_Config(t *testing.T) {
+	if config, err := loadServerTLS("", "", "", ""); config != nil || err != nil {
+		t.Errorf("no files: %v, %v; want plain HTTP", config, err)
+	}
+	if _, err := loadServerTLS("", "", "ca.pem", ""); err == nil {
+		t.Error("a client CA without a server certificate should fail")
+	}
+	dir := t.TempDir()
+	server := issueTestCert(t, dir, "server", nil, &x509.Certificate{})
+	if _, err := loadServerTLS(server.certFile, server.keyFile, server.certFile, "sometimes"); err == nil {
+		t.Error("unknown TLS_CLIENT_AUTH should fail")
+	}
+}
+
+func TestTLS_CertificateFromSecrets(t *testing.T) {
+	dir := t.TempDir()
+	install := func(cert *testCert) {
+		certPEM, _ := os.ReadFile(cert.certFile)
+		keyPEM, _ := os.ReadFile(cert.keyFile)
+		os.WriteFile(filepath.Join(dir, "TLS_CERT"), certPEM, 0o600)
+		os.WriteFile(filepath.Join(dir, "TLS_KEY"), keyPEM, 0o600)
+	}
+	first := issueTestCert(t, t.TempDir(), "first", nil, &x509.Certificate{})
+	install(first)
+	withSecrets(t, &fileSecretSource{path: dir})
+
+	config, err := loadServerTLS("", "", "", "")
+	if err != nil || config == nil || config.GetCertificate == nil {
+		t.Fatalf("TLS_CERT/TLS_KEY secrets: %v, %v", config, err)
+	}
+	served := func() string {
+		cert, err := config.GetCertificate(nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return cert.Leaf.Subject.CommonName
+	}
+	if got := served(); got != "first" {
+		t.Fatalf("serving %q, want first", got)
+	}
+
+	// Rotated in the secret store: the next handshake gets the new one
+	install(issueTestCert(t, t.TempDir(), "second", nil, &x509.Certificate{}))
+	secrets.refresh()
+	if got := served(); got != "second" {
+		t.Errorf("after rotation serving %q, want second", got)
+	}
+
+	// A broken pair keeps the last good certificate
+	os.WriteFile(filepath.Join(dir, "TLS_KEY"), []byte("not a key"), 0o600)
+	secrets.refresh()
+	if got := served(); got != "second" {
+		t.Errorf("after a bad rotation serving %q, want second", got)
+	}
+}
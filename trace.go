@@ -0,0 +1,161 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"log/slog"
+	"strconv"
+	"strings"
+)
+
+// =============================================================================
+// Trace ID Log Correlation
+// =============================================================================
+//
+// demo-app doesn't run its own tracer (see PLAN.md: Prometheus over
+// OpenTelemetry), but it often sits behind things that do: an OTel-instrumented
+// frontend, an ingress, a service mesh, or Grafana Beyla. Those send the W3C
+// Trace Context header with each request:
+//
+//	traceparent: 00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01
+//	             |  |                                |                |
+//	          version  trace-id (16 bytes)      parent span-id    flags (01 = sampled)
+//
+// When a request carries one, every log line written while handling it gets
+// trace_id and span_id attributes, so "jump from trace to logs" in Grafana
+// (Tempo -> Loki) works without any extra setup. Requests without the header
+// log exactly as before.
+//
+// span_id is a new ID for this server's part of the trace, as a tracer would
+// create; the caller's span is logged as parent_span_id.
+
+// traceContext is the trace a request belongs to
+type traceContext struct {
+	TraceID      string
+	SpanID       string
+	ParentSpanID string
+	Flags        string // "01" = sampled
+}
+
+// Context key type: an unexported type means no other package can collide with it
+type traceContextKey struct{}
+
+// parseTraceparent validates a traceparent header (version 00) and returns
+// a traceContext with a freshly generated span ID
+func parseTraceparent(header string) (traceContext, bool) {
+	parts := strings.Split(strings.TrimSpace(header), "-")
+	if len(parts) < 4 || parts[0] == "ff" || len(parts[0]) != 2 {
+		return traceContext{}, false
+	}
+	traceID, parentID := parts[1], parts[2]
+	if !isLowerHex(traceID, 32) || !isLowerHex(parentID, 16) || !isLowerHex(parts[3], 2) {
+		return traceContext{}, false
+	}
+	// All-zero IDs are explicitly invalid in the spec
+	if traceID == strings.Repeat("0", 32) || parentID == strings.Repeat("0", 16) {
+		return traceContext{}, false
+	}
+
+	return traceContext{TraceID: traceID, SpanID: newSpanID(), ParentSpanID: parentID, Flags: parts[3]}, true
+}
+
+// traceparent is the header to send on outgoing calls: same trace, with this
+// server's span as the parent
+func (tc traceContext) traceparent() string {
+	return "00-" + tc.TraceID + "-" + tc.SpanID + "-" + tc.Flags
+}
+
+// sampled reports whether the caller's tracer is recording this trace (the
+// low bit of the flags); exemplars for unsampled traces would lead nowhere
+func (tc traceContext) sampled() bool {
+	flags, err := strconv.ParseUint(tc.Flags, 16, 8)
+	return err == nil && flags&1 == 1
+}
+
+// isLowerHex reports whether s is exactly n lowercase hex characters
+func isLowerHex(s string, n int) bool {
+	if len(s) != n {
+		return false
+	}
+	for _, c := range s {
+		if !(c >= '0' && c <= '9' || c >= 'a' && c <= 'f') {
+			return false
+		}
+	}
+	return true
+}
+
+// newSpanID returns 8 random bytes as hex
+func newSpanID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// newTraceContext starts a trace for a request that didn't arrive with one.
+// No tracer records it, so it isn't sampled: downstreams can log the IDs,
+// but it gets no exemplars here or elsewhere.
+func newTraceContext() traceContext {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return traceContext{TraceID: hex.EncodeToString(b), SpanID: newSpanID(), Flags: "00"}
+}
+
+// withTraceContext returns a copy of ctx carrying tc
+func withTraceContext(ctx context.Context, tc traceContext) context.Context {
+	return context.WithValue(ctx, traceContextKey{}, tc)
+}
+
+// traceContextFrom returns the trace stored in ctx, if any
+func traceContextFrom(ctx context.Context) (traceContext, bool) {
+	tc, ok := ctx.Value(traceContextKey{}).(traceContext)
+	return tc, ok
+}
+
+// =============================================================================
+// slog.Handler that adds trace IDs
+// =============================================================================
+
+// traceHandler wraps another slog.Handler and adds trace_id/span_id to
+// records logged with a request context — slog.InfoContext(r.Context(), ...)
+// or logHandlerError. It's the outermost handler, so the webhook and syslog
+// outputs get the IDs too.
+type traceHandler struct {
+	underlying slog.Handler
+}
+
+// newTraceHandler wraps underlying
+func newTraceHandler(underlying slog.Handler) *traceHandler {
+	return &traceHandler{underlying: underlying}
+}
+
+// Enabled delegates to the underlying handler
+func (h *traceHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.underlying.Enabled(ctx, level)
+}
+
+// Handle adds the trace attributes when ctx has a trace
+func (h *traceHandler) Handle(ctx context.Context, record slog.Record) error {
+	if tc, ok := traceContextFrom(ctx); ok {
+		// Records share attribute storage with their callers; Clone before
+		// modifying (the slog docs ask handlers to do this)
+		record = record.Clone()
+		record.AddAttrs(
+			slog.String("trace_id", tc.TraceID),
+			slog.String("span_id", tc.SpanID),
+			slog.String("parent_span_id", tc.ParentSpanID),
+		)
+	}
+	return h.underlying.Handle(ctx, record)
+}
+
+// WithAttrs wraps the underlying handler's result
+func (h *traceHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &traceHandler{underlying: h.underlying.WithAttrs(attrs)}
+}
+
+// WithGroup wraps the underlying handler's result
+func (h *traceHandler) WithGroup(name string) slog.Handler {
+	return &traceHandler{underlying: h.underlying.WithGroup(name)}
+}
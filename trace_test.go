@@ -0,0 +1,85 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestParseTraceparent(t *testing.T) {
+	tests := []struct {
+		header string
+		valid  bool
+	}{
+		{"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", true},
+		{"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00", true},
+		{"", false},
+		{"garbage", false},
+		{"00-4BF92F3577B34DA6A3CE929D0E0E4736-00f067aa0ba902b7-01", false}, // uppercase
+		{"00-00000000000000000000000000000000-00f067aa0ba902b7-01", false}, // zero trace ID
+		{"00-4bf92f3577b34da6a3ce929d0e0e4736-0000000000000000-01", false}, // zero span ID
+		{"ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", false}, // invalid version
+		{"00-4bf92f3577b34da6a3ce929d0e0e473-00f067aa0ba902b7-01", false},  // short trace ID
+	}
+
+	for _, tt := range tests {
+		tc, ok := parseTraceparent(tt.header)
+		if ok != tt.valid {
+			t.Errorf("%q: expected valid=%v, got %v", tt.header, tt.valid, ok)
+			continue
+		}
+		if !ok {
+			continue
+		}
+		if tc.TraceID != "4bf92f3577b34da6a3ce929d0e0e4736" || tc.ParentSpanID != "00f067aa0ba902b7" {
+			t.Errorf("%q: unexpected IDs %+v", tt.header, tc)
+		}
+		if !isLowerHex(tc.SpanID, 16) || tc.SpanID == tc.ParentSpanID {
+			t.Errorf("%q: expected a new 16-char span ID, got %q", tt.header, tc.SpanID)
+		}
+	}
+}
+
+func TestTraceHandler_AddsIDsFromContext(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(newTraceHandler(slog.NewJSONHandler(&buf, nil)))
+
+	tc := traceContext{TraceID: "4bf92f3577b34da6a3ce929d0e0e4736", SpanID: "1111111111111111", ParentSpanID: "00f067aa0ba902b7"}
+	logger.InfoContext(withTraceContext(context.Background(), tc), "traced")
+	logger.Info("untraced")
+
+	lines := bytes.Split(bytes.TrimSpace(buf.Bytes()), []byte("\n"))
+	if len(lines) != 2 {
+		t.Fatalf("expected 2 log lines, got %d", len(lines))
+	}
+
+	var traced, untraced map[string]any
+	json.Unmarshal(lines[0], &traced)
+	json.Unmarshal(lines[1], &untraced)
+
+	if traced["trace_id"] != tc.TraceID || traced["span_id"] != tc.SpanID || traced["parent_span_id"] != tc.ParentSpanID {
+		t.Errorf("expected trace attributes, got %v", traced)
+	}
+	if _, ok := untraced["trace_id"]; ok {
+		t.Errorf("expected no trace_id without a trace, got %v", untraced)
+	}
+}
+
+func TestLoggingMiddleware_PropagatesTrace(t *testing.T) {
+	var got traceContext
+	handler := loggingMiddleware(func(w http.ResponseWriter, r *http.Request) {
+		got, _ = traceContextFrom(r.Context())
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/health", nil)
+	req.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	handler(httptest.NewRecorder(), req)
+
+	if got.TraceID != "4bf92f3577b34da6a3ce929d0e0e4736" {
+		t.Errorf("expected trace in handler context, got %+v", got)
+	}
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"regexp"
+	"slices"
+	"unicode/utf8"
+)
+
+// =============================================================================
+// Item Validation
+// =============================================================================
+//
+// By default any non-empty name is accepted. For input-validation demos,
+// constraints can be switched on via a JSON file (ITEM_VALIDATION_FILE)
+// and/or individual environment variables (env vars win over the file).
+//
+// Violations return 422 Unprocessable Entity with one error per field:
+//
+//	{
+//	  "error": "validation failed",
+//	  "fields": [
+//	    {"field": "name", "message": "must be at most 20 characters"},
+//	    {"field": "tags", "message": "missing required tag \"env\""}
+//	  ]
+//	}
+//
+// Why 422 and not 400? 400 means "I couldn't parse that" (bad JSON).
+// 422 means "I parsed it fine, but the content breaks the rules".
+
+// ItemValidationRules holds the configurable constraints (zero value = no constraint)
+type ItemValidationRules struct {
+	NameMaxLength        int      `json:"name_max_length"`
+	NamePattern          string   `json:"name_pattern"` // regex the whole name must match
+	DescriptionMaxLength int      `json:"description_max_length"`
+	RequiredTags         []string `json:"required_tags"`
+
+	namePatternRe *regexp.Regexp
+}
+
+// FieldError describes one invalid field
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// Package-level validation rules, loaded once at startup by loadItemValidation
+var itemValidation ItemValidationRules
+
+// loadItemValidation builds the rules from ITEM_VALIDATION_FILE and env vars
+func loadItemValidation() error {
+	var rules ItemValidationRules
+
+	if filename := os.Getenv("ITEM_VALIDATION_FILE"); filename != "" {
+		data, err := os.ReadFile(filename)
+		if err != nil {
+			return err
+		}
+		if err := json.Unmarshal(data, &rules); err != nil {
+			return fmt.Errorf("invalid validation file: %w", err)
+		}
+	}
+
+	// Individual env vars override the file
+	rules.NameMaxLength = envInt("ITEM_NAME_MAX_LENGTH", rules.NameMaxLength)
+	rules.NamePattern = envString("ITEM_NAME_PATTERN", rules.NamePattern)
+	rules.DescriptionMaxLength = envInt("ITEM_DESCRIPTION_MAX_LENGTH", rules.DescriptionMaxLength)
+	if tags := envList("ITEM_REQUIRED_TAGS"); len(tags) > 0 {
+		rules.RequiredTags = tags
+	}
+
+	if rules.NamePattern != "" {
+		// Anchor the pattern so it has to match the WHOLE name,
+		// not just some substring of it
+		re, err := regexp.Compile("^(?:" + rules.NamePattern + ")$")
+		if err != nil {
+			return fmt.Errorf("invalid ITEM_NAME_PATTERN: %w", err)
+		}
+		rules.namePatternRe = re
+	}
+
+	itemValidation = rules
+	return nil
+}
+
+// validate checks an item's fields against the rules.
+// It collects ALL problems instead of stopping at the first, so the client
+// can fix everything in one round trip.
+func (v ItemValidationRules) validate(name, description string, tags []string) []FieldError {
+	var errs []FieldError
+
+	// utf8.RuneCountInString counts characters, not bytes —
+	// len("héllo") is 6 in Go, but it's 5 characters to a human
+	if v.NameMaxLength > 0 && utf8.RuneCountInString(name) > v.NameMaxLength {
+		errs = append(errs, FieldError{"name", fmt.Sprintf("must be at most %d characters", v.NameMaxLength)})
+	}
+	if v.namePatternRe != nil && !v.namePatternRe.MatchString(name) {
+		errs = append(errs, FieldError{"name", fmt.Sprintf("must match pattern %q", v.NamePattern)})
+	}
+	if v.DescriptionMaxLength > 0 && utf8.RuneCountInString(description) > v.DescriptionMaxLength {
+		errs = append(errs, FieldError{"description", fmt.Sprintf("must be at most %d characters", v.DescriptionMaxLength)})
+	}
+	for _, required := range v.RequiredTags {
+		if !slices.Contains(tags, required) {
+			errs = append(errs, FieldError{"tags", fmt.Sprintf("missing required tag %q", required)})
+		}
+	}
+
+	return errs
+}
+
+// writeValidationErrors responds with 422 and the per-field error list
+func writeValidationErrors(w http.ResponseWriter, errs []FieldError) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusUnprocessableEntity)
+	json.NewEncoder(w).Encode(map[string]any{
+		"error":  "validation failed",
+		"fields": errs,
+	})
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestItems_ValidationErrors(t *testing.T) {
+	t.Setenv("ITEM_NAME_MAX_LENGTH", "5")
+	t.Setenv("ITEM_NAME_PATTERN", "[a-z]+")
+	t.Setenv("ITEM_REQUIRED_TAGS", "env")
+	if err := loadItemValidation(); err != nil {
+		t.Fatalf("failed to load validation rules: %v", err)
+	}
+	defer func() { itemValidation = ItemValidationRules{} }()
+
+	body := bytes.NewBufferString(`{"name":"Too Long Name","tags":["demo"]}`)
+	req := httptest.NewRequest("POST", "/api/items", body)
+	rr := httptest.NewRecorder()
+	itemsHandler(rr, req)
+
+	if rr.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("expected status 422, got %d: %s", rr.Code, rr.Body.String())
+	}
+
+	var result struct {
+		Fields []FieldError `json:"fields"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &result); err != nil {
+		t.Fatalf("failed to parse response: %v", err)
+	}
+
+	// Length, pattern, and required tag should all be reported at once
+	if len(result.Fields) != 3 {
+		t.Errorf("expected 3 field errors, got %d: %+v", len(result.Fields), result.Fields)
+	}
+
+	// A valid item still goes through
+	body = bytes.NewBufferString(`{"name":"ok","tags":["env"]}`)
+	req = httptest.NewRequest("POST", "/api/items", body)
+	rr = httptest.NewRecorder()
+	itemsHandler(rr, req)
+
+	if rr.Code != http.StatusCreated {
+		t.Errorf("expected status 201, got %d: %s", rr.Code, rr.Body.String())
+	}
+}
+
+func TestLoadItemValidation_InvalidPattern(t *testing.T) {
+	t.Setenv("ITEM_NAME_PATTERN", "[unclosed")
+	if err := loadItemValidation(); err == nil {
+		t.Error("expected error for invalid pattern")
+	}
+}
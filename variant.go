@@ -0,0 +1,93 @@
+package main
+
+import (
+	"encoding/json"
+	"hash/fnv"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// =============================================================================
+// Blue/Green and Canary Variants
+// =============================================================================
+//
+// Progressive-delivery demos need the two versions to LOOK different, without
+// building two images. Set VARIANT (e.g. "blue", "green", "v2") and the same
+// binary:
+//   - recolors the dashboard accent
+//   - adds an X-Variant header to every response
+//   - exports demoapp_variant_info{variant="blue"} 1
+//   - answers GET /api/variant
+//
+// Watching the dashboard (or `curl -I`) while shifting traffic shows exactly
+// which variant is serving.
+
+// Dashboard accent when no VARIANT is set (matches style.css)
+const defaultAccentColor = "#e94560"
+
+// Accent colors for well-known variant names; anything else gets a stable
+// color picked from variantPalette (override either with VARIANT_COLOR)
+var variantColors = map[string]string{
+	"blue":   "#3498db",
+	"green":  "#2ecc71",
+	"canary": "#f1c40f",
+	"yellow": "#f1c40f",
+	"red":    "#e74c3c",
+	"orange": "#e67e22",
+	"purple": "#9b59b6",
+	"stable": "#3498db",
+}
+
+var variantPalette = []string{"#1abc9c", "#9b59b6", "#e67e22", "#f1c40f", "#3498db", "#2ecc71"}
+
+// Active variant, set from VARIANT/VARIANT_COLOR at startup (loadVariant)
+var (
+	variant      string
+	variantColor = defaultAccentColor
+)
+
+// loadVariant reads VARIANT and VARIANT_COLOR and publishes the info metric
+func loadVariant() {
+	variant = strings.TrimSpace(os.Getenv("VARIANT"))
+	variantColor = pickVariantColor(variant, os.Getenv("VARIANT_COLOR"))
+
+	if variant != "" {
+		variantInfo.WithLabelValues(variant).Set(1)
+	}
+}
+
+// pickVariantColor returns the override, a well-known color, or a palette
+// color chosen by hashing the name (so "v2" is the same color on every pod)
+func pickVariantColor(name, override string) string {
+	if override != "" {
+		return override
+	}
+	if name == "" {
+		return defaultAccentColor
+	}
+	if color, ok := variantColors[strings.ToLower(name)]; ok {
+		return color
+	}
+	h := fnv.New32a()
+	h.Write([]byte(name))
+	return variantPalette[h.Sum32()%uint32(len(variantPalette))]
+}
+
+// variantHandler handles GET /api/variant
+func variantHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, `{"error":"method not allowed"}`, http.StatusMethodNotAllowed)
+		return
+	}
+
+	hostname, _ := os.Hostname()
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{
+		"variant":  variant,
+		"color":    variantColor,
+		"version":  version,
+		"hostname": hostname,
+	})
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestVariant_Colors(t *testing.T) {
+	cases := []struct{ name, override, want string }{
+		{"", "", defaultAccentColor},
+		{"blue", "", "#3498db"},
+		{"Green", "", "#2ecc71"},
+		{"blue", "#123456", "#123456"},
+	}
+	for _, c := range cases {
+		if got := pickVariantColor(c.name, c.override); got != c.want {
+			t.Errorf("pickVariantColor(%q, %q) = %q, want %q", c.name, c.override, got, c.want)
+		}
+	}
+
+	// Unknown names get a stable palette color
+	if pickVariantColor("v2", "") != pickVariantColor("v2", "") {
+		t.Error("expected the same color for the same variant name")
+	}
+}
+
+func TestVariant_HandlerAndMetric(t *testing.T) {
+	t.Setenv("VARIANT", "green")
+	loadVariant()
+	defer func() {
+		variant, variantColor = "", defaultAccentColor
+		variantInfo.Reset()
+	}()
+
+	rr := httptest.NewRecorder()
+	variantHandler(rr, httptest.NewRequest("GET", "/api/variant", nil))
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", rr.Code)
+	}
+	var resp map[string]string
+	json.NewDecoder(rr.Body).Decode(&resp)
+	if resp["variant"] != "green" || resp["color"] != "#2ecc71" {
+		t.Errorf("unexpected response: %v", resp)
+	}
+
+	if got := testutil.ToFloat64(variantInfo.WithLabelValues("green")); got != 1 {
+		t.Errorf("expected demoapp_variant_info{variant=green} 1, got %v", got)
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"encoding/json"
 	"log/slog"
 	"net/http"
+	"sync/atomic"
 	"time"
 )
 
@@ -20,9 +21,13 @@ import (
 // The struct holds DATA, the methods define BEHAVIOR.
 type webhookHandler struct {
 	underlying slog.Handler // the wrapped handler (JSONHandler for stdout)
-	webhookURL string       // where to POST logs (empty = disabled)
 	token      string       // optional auth token
 	client     *http.Client // reusable HTTP client
+
+	// Where to POST logs (empty = disabled). A pointer shared by the
+	// WithAttrs/WithGroup copies, so a reload (configwatch.go) reaches every
+	// logger at once.
+	webhookURL *atomic.Pointer[string]
 }
 
 // newWebhookHandler creates a handler that writes to stdout AND posts to a webhook.
@@ -34,15 +39,28 @@ type webhookHandler struct {
 //
 // Returns a handler that satisfies slog.Handler interface.
 func newWebhookHandler(underlying slog.Handler, webhookURL, token string) *webhookHandler {
-	return &webhookHandler{
+	w := &webhookHandler{
 		underlying: underlying,
-		webhookURL: webhookURL,
+		webhookURL: &atomic.Pointer[string]{},
 		token:      token,
-		// Custom HTTP client with timeout — don't let slow webhooks hang forever
-		client: &http.Client{
-			Timeout: 5 * time.Second,
-		},
+		// Shared outbound client (outbound.go) with a timeout — don't let slow
+		// webhooks hang forever — and a circuit breaker, so a dead webhook
+		// isn't called for every log line
+		client: newOutboundClient(5 * time.Second),
 	}
+	w.setURL(webhookURL)
+	return w
+}
+
+// setURL changes where logs are POSTed, for this handler and every copy
+// made with WithAttrs/WithGroup. An empty URL turns the webhook off.
+func (w *webhookHandler) setURL(webhookURL string) {
+	w.webhookURL.Store(&webhookURL)
+}
+
+// url is where logs are POSTed right now ("" when off)
+func (w *webhookHandler) url() string {
+	return *w.webhookURL.Load()
 }
 
 // =============================================================================
@@ -71,9 +89,9 @@ func (w *webhookHandler) Handle(ctx context.Context, record slog.Record) error {
 	}
 
 	// Step 2: If webhook is configured, POST asynchronously
-	if w.webhookURL != "" {
+	if w.url() != "" {
 		// Build the log entry as a map
-		entry := w.buildLogEntry(record)
+		entry := buildLogEntry(record)
 
 		// Launch goroutine — don't block the request waiting for webhook
 		// This is "fire and forget" — we don't wait for the result
@@ -116,13 +134,14 @@ func (w *webhookHandler) WithGroup(name string) slog.Handler {
 // =============================================================================
 
 // buildLogEntry converts a slog.Record into a map for JSON serialization.
+// Shared with the syslog handler (syslog.go), which sends the same fields.
 //
 // slog.Record contains:
 //   - Time: when the log was created
 //   - Level: INFO, WARN, ERROR, etc.
 //   - Message: the log message
 //   - Attrs: key-value pairs added via slog.Info("msg", "key", "value")
-func (w *webhookHandler) buildLogEntry(record slog.Record) map[string]any {
+func buildLogEntry(record slog.Record) map[string]any {
 	entry := map[string]any{
 		"time":  record.Time.Format(time.RFC3339),
 		"level": record.Level.String(),
@@ -139,6 +158,16 @@ func (w *webhookHandler) buildLogEntry(record slog.Record) map[string]any {
 	return entry
 }
 
+// authToken is the Authorization header value: LOG_WEBHOOK_TOKEN from
+// Vault or SECRETS_FILE (secrets.go) when they have it, so a rotated token
+// is used on the next call, otherwise the token from startup.
+func (w *webhookHandler) authToken() string {
+	if token, ok := secrets.lookup("LOG_WEBHOOK_TOKEN"); ok {
+		return token
+	}
+	return w.token
+}
+
 // postToWebhook sends a log entry to the configured webhook URL.
 //
 // This runs in a goroutine (async), so it:
@@ -155,8 +184,13 @@ func (w *webhookHandler) postToWebhook(entry map[string]any) {
 		return
 	}
 
-	// Create the request
-	req, err := http.NewRequest(http.MethodPost, w.webhookURL, bytes.NewReader(body))
+	// Create the request (the URL may have been turned off by a reload since
+	// Handle checked it)
+	webhookURL := w.url()
+	if webhookURL == "" {
+		return
+	}
+	req, err := http.NewRequest(http.MethodPost, webhookURL, bytes.NewReader(body))
 	if err != nil {
 		println("webhook: failed to create request:", err.Error())
 		return
@@ -164,8 +198,8 @@ func (w *webhookHandler) postToWebhook(entry map[string]any) {
 
 	// Set headers
 	req.Header.Set("Content-Type", "application/json")
-	if w.token != "" {
-		req.Header.Set("Authorization", w.token)
+	if token := w.authToken(); token != "" {
+		req.Header.Set("Authorization", token)
 	}
 
 	// Send the request